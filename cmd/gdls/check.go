@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/lsp"
+)
+
+// checkExtensions are the file extensions analyzed by `gdls check`.
+var checkExtensions = map[string]bool{
+	".tscn":        true,
+	".escn":        true,
+	".tres":        true,
+	".gdshader":    true,
+	".gdshaderinc": true,
+	".glsl":        true,
+	".godot":       true,
+	".gdextension": true,
+	".import":      true,
+}
+
+// checkResult is a single diagnostic as reported by `gdls check --format json`.
+// Lines and columns are 1-based.
+type checkResult struct {
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Source    string `json:"source,omitempty"`
+}
+
+// runCheck implements the `gdls check` subcommand and returns the exit code:
+// 0 when no errors were found, 1 when errors were found and 2 on usage or
+// I/O failures.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(name+" check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text or json")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, `Usage:
+  %s check [--format text|json] [paths...]
+
+Parses every .tscn, .escn, .tres, .gdshader, .gdshaderinc, .glsl, .godot,
+.gdextension and .import file under the given paths (default: the current
+directory), runs the analyzers and prints the diagnostics. Exits with status 1 if any errors are found.
+`, name)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "unknown format %q (expected text or json)\n", *format)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := collectFiles(paths, func(path string) bool {
+		return checkExtensions[strings.ToLower(filepath.Ext(path))]
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	server := lsp.NewServer(name, version)
+	results := []checkResult{}
+	errorCount := 0
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+
+		for _, diag := range server.Check(fileURI(file), string(content)) {
+			result := checkResult{
+				File:      file,
+				Line:      int(diag.Range.Start.Line) + 1,
+				Column:    int(diag.Range.Start.Character) + 1,
+				EndLine:   int(diag.Range.End.Line) + 1,
+				EndColumn: int(diag.Range.End.Character) + 1,
+				Severity:  severityName(diag.Severity),
+				Message:   diag.Message,
+			}
+			if diag.Source != nil {
+				result.Source = *diag.Source
+			}
+			if result.Severity == "error" {
+				errorCount++
+			}
+			results = append(results, result)
+		}
+	}
+
+	if *format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	} else {
+		for _, r := range results {
+			fmt.Fprintf(stdout, "%s:%d:%d: %s: %s\n", r.File, r.Line, r.Column, r.Severity, r.Message)
+		}
+		fmt.Fprintf(stderr, "checked %d files: %d errors, %d other diagnostics\n", len(files), errorCount, len(results)-errorCount)
+	}
+
+	if errorCount > 0 {
+		return 1
+	}
+	return 0
+}
+
+// collectFiles expands the given paths into a sorted list of files.
+// Directories are walked recursively, keeping only files accepted by match
+// and skipping hidden directories such as .godot and .git. Files named
+// explicitly are always kept.
+func collectFiles(paths []string, match func(path string) bool) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			if !seen[root] {
+				seen[root] = true
+				files = append(files, root)
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if match(path) && !seen[path] {
+				seen[path] = true
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// fileURI returns the file:// URI for a local path.
+func fileURI(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	abs = filepath.ToSlash(abs)
+	if !strings.HasPrefix(abs, "/") {
+		// Windows drive paths, e.g. C:/project
+		abs = "/" + abs
+	}
+	return "file://" + abs
+}
+
+// severityName returns the lowercase name of a diagnostic severity.
+func severityName(severity *protocol.DiagnosticSeverity) string {
+	if severity == nil {
+		return "error"
+	}
+	switch *severity {
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation:
+		return "info"
+	case protocol.DiagnosticSeverityHint:
+		return "hint"
+	default:
+		return "error"
+	}
+}
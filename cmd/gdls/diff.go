@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// edit is one line of a diff.
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	text string
+	ai   int // line index in a
+	bi   int // line index in b
+}
+
+// unifiedDiff returns a unified diff between two texts, or "" if they are
+// equal. Lines are compared with Myers' algorithm in linear space, so large
+// files with few changes diff quickly.
+func unifiedDiff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	var d differ
+	d.diff(splitLines(a), splitLines(b))
+	edits := d.edits
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+
+	// Group changes into hunks with surrounding context
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+
+		start := max(k-diffContext, 0)
+		end := k
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			// Stop once the run of unchanged lines is too long to bridge
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(edits))
+				break
+			}
+			end = run
+		}
+
+		aStart, bStart := edits[start].ai, edits[start].bi
+		aCount, bCount := 0, 0
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", aStart+1, aCount, bStart+1, bCount)
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.op)
+			out.WriteString(e.text)
+			if !strings.HasSuffix(e.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		k = end
+	}
+
+	return out.String()
+}
+
+// differ collects the edits turning one list of lines into another.
+type differ struct {
+	edits  []edit
+	ai, bi int
+}
+
+// add appends an edit at the current line of both texts.
+func (d *differ) add(op byte, text string) {
+	d.edits = append(d.edits, edit{op, text, d.ai, d.bi})
+	if op != '+' {
+		d.ai++
+	}
+	if op != '-' {
+		d.bi++
+	}
+}
+
+// diff adds the edits turning x into y. The common prefix and suffix are
+// kept, and what remains is split at the middle snake of a shortest edit
+// script and diffed in halves.
+func (d *differ) diff(x, y []string) {
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		d.add(' ', x[prefix])
+		prefix++
+	}
+	x, y = x[prefix:], y[prefix:]
+	suffix := 0
+	for suffix < len(x) && suffix < len(y) && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+	common := x[len(x)-suffix:]
+	x, y = x[:len(x)-suffix], y[:len(y)-suffix]
+
+	if i, j, ok := middleSnake(x, y); ok {
+		d.diff(x[:i], y[:j])
+		d.diff(x[i:], y[j:])
+	} else {
+		for _, line := range x {
+			d.add('-', line)
+		}
+		for _, line := range y {
+			d.add('+', line)
+		}
+	}
+	for _, line := range common {
+		d.add(' ', line)
+	}
+}
+
+// middleSnake finds where the forward and backward searches of Myers'
+// algorithm meet, returning a point that splits x and y into two smaller
+// problems. It reports false when x and y share no line to split around.
+func middleSnake(x, y []string) (int, int, bool) {
+	n, m := len(x), len(y)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward := make([]int, 2*maxD+2)
+	backward := make([]int, 2*maxD+2)
+	for k := range forward {
+		forward[k] = -1
+		backward[k] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that ran off the edges are skipped in later rounds
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			var i int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				i = forward[offset+k+1]
+			} else {
+				i = forward[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			forward[offset+k] = i
+			switch {
+			case i > n:
+				fEnd += 2
+			case j > m:
+				fStart += 2
+			case odd:
+				if b := offset + delta - k; b >= 0 && b < len(backward) && backward[b] != -1 && i >= n-backward[b] {
+					return split(i, j, n, m)
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			var i int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				i = backward[offset+k+1]
+			} else {
+				i = backward[offset+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[n-1-i] == y[m-1-j] {
+				i++
+				j++
+			}
+			backward[offset+k] = i
+			switch {
+			case i > n:
+				bEnd += 2
+			case j > m:
+				bStart += 2
+			case !odd:
+				if f := offset + delta - k; f >= 0 && f < len(forward) && forward[f] != -1 {
+					fi := forward[f]
+					if fi >= n-i {
+						return split(fi, fi-(f-offset), n, m)
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// split returns a split point unless it would leave one of the two
+// problems as large as the original.
+func split(i, j, n, m int) (int, int, bool) {
+	if (i == 0 && j == 0) || (i == n && j == m) {
+		return 0, 0, false
+	}
+	return i, j, true
+}
+
+// splitLines splits text into lines with their terminators, so that a last
+// line missing its newline differs from the same line with one.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	if diff := unifiedDiff("a.tscn", "x\n", "x\n"); diff != "" {
+		t.Errorf("expected no diff for equal texts, got %q", diff)
+	}
+
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+	want := `--- a.tscn
++++ a.tscn
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,3 +10,4 @@
+ 10
+ 11
+ 12
++13
+`
+	if diff := unifiedDiff("a.tscn", a, b); diff != want {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+
+	// A missing final newline is a change of the last line, as in diff -u
+	want = `--- f
++++ f
+@@ -1,1 +1,1 @@
+-shader_type spatial;
+\ No newline at end of file
++shader_type spatial;
+`
+	if diff := unifiedDiff("f", "shader_type spatial;", "shader_type spatial;\n"); diff != want {
+		t.Errorf("unexpected diff for a missing final newline:\n%s", diff)
+	}
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	// A full LCS table for these texts would take tens of gigabytes
+	const lines = 200000
+	var a, b strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&a, "line %d\n", i)
+		if i%1000 == 500 {
+			fmt.Fprintf(&b, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+	}
+
+	start := time.Now()
+	diff := unifiedDiff("big.tscn", a.String(), b.String())
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the diff to be fast, took %v", elapsed)
+	}
+	if removed, added := strings.Count(diff, "\n-line"), strings.Count(diff, "\n+changed"); removed != lines/1000 || added != lines/1000 {
+		t.Errorf("expected %d changed lines, got %d removed and %d added", lines/1000, removed, added)
+	}
+	if hunks := strings.Count(diff, "\n@@ "); hunks != lines/1000 {
+		t.Errorf("expected %d hunks, got %d", lines/1000, hunks)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// fileFormatters maps file extensions to the formatter used by `gdls fmt`.
+func fileFormatters(opts parser.FormatOptions) map[string]func(string) string {
+	formatScene := func(src string) string {
+		return parser.Format(src, opts)
+	}
+	return map[string]func(string) string{
+		".gdshader":    gdshader.Format,
+		".gdshaderinc": gdshader.Format,
+		".tscn":        formatScene,
+		".escn":        formatScene,
+		".tres":        formatScene,
+	}
+}
+
+// runFmt implements the `gdls fmt` subcommand and returns the exit code.
+// Like gofmt it formats standard input when no paths are given. For files it
+// prints a diff of the needed changes, or rewrites them in place with -w.
+// Without -w the exit code is 1 if any file is not formatted, which makes it
+// usable as a CI check.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet(name+" fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write result to the source file instead of printing a diff")
+	list := flags.Bool("l", false, "list files whose formatting differs")
+	align := flags.Bool("align", false, "align the '=' of the properties of each scene section")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, `Usage:
+  %s fmt [-w] [-l] [-align] [paths...]
+
+Formats .gdshader, .gdshaderinc, .tscn, .escn and .tres files. Directories
+are walked recursively. With no paths, formats standard input to standard
+output, as a scene when it starts with a [gd_scene] or [gd_resource]
+header and as a shader otherwise.
+
+Flags:
+  -w      Write the result to the source file instead of printing a diff
+  -l      List files whose formatting differs instead of printing a diff
+  -align  Align the '=' of the properties of each scene section
+`, name)
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	formatters := fileFormatters(parser.FormatOptions{AlignProperties: *align})
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		format := formatters[".gdshader"]
+		if strings.HasPrefix(strings.TrimSpace(string(src)), "[gd_") {
+			format = formatters[".tscn"]
+		}
+		fmt.Fprint(stdout, format(string(src)))
+		return 0
+	}
+
+	files, err := collectFiles(flags.Args(), func(path string) bool {
+		return formatters[strings.ToLower(filepath.Ext(path))] != nil
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	unformatted := 0
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+
+		format := formatters[strings.ToLower(filepath.Ext(file))]
+		if format == nil {
+			fmt.Fprintf(stderr, "%s: no formatter for this file type\n", file)
+			return 2
+		}
+
+		formatted := format(string(src))
+		if formatted == string(src) {
+			continue
+		}
+
+		switch {
+		case *write:
+			info, err := os.Stat(file)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+			if err := os.WriteFile(file, []byte(formatted), info.Mode().Perm()); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 2
+			}
+			if *list {
+				fmt.Fprintln(stdout, file)
+			}
+		case *list:
+			fmt.Fprintln(stdout, file)
+			unformatted++
+		default:
+			fmt.Fprint(stdout, unifiedDiff(file, string(src), formatted))
+			unformatted++
+		}
+	}
+
+	if unformatted > 0 {
+		return 1
+	}
+	return 0
+}
@@ -2,13 +2,19 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/tliron/commonlog"
 	_ "github.com/tliron/commonlog/simple"
 
 	"github.com/andresperezl/gdls/internal/lsp"
+	"github.com/andresperezl/gdls/internal/telemetry"
 )
 
 const name = "gdls"
@@ -21,32 +27,110 @@ var (
 )
 
 func main() {
-	// Handle version flag
+	// Headless subcommands
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "--version", "-v":
-			fmt.Printf("%s %s\n", name, version)
-			fmt.Printf("commit: %s\n", commit)
-			fmt.Printf("built:  %s\n", buildTime)
-			os.Exit(0)
-		case "--help", "-h":
-			printHelp()
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+		case "fmt":
+			os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+		}
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = printHelp
+
+	var showVersion, showHelp, verbose bool
+	var listen, allowOrigin, otlpEndpoint, logFile string
+	var parentPID int
+	flags.BoolVar(&showVersion, "version", false, "")
+	flags.BoolVar(&showVersion, "v", false, "")
+	flags.BoolVar(&showHelp, "help", false, "")
+	flags.BoolVar(&showHelp, "h", false, "")
+	flags.StringVar(&listen, "listen", "", "")
+	flags.StringVar(&allowOrigin, "allow-origin", "", "")
+	flags.StringVar(&otlpEndpoint, "otlp-endpoint", "", "")
+	flags.StringVar(&logFile, "log-file", "", "")
+	flags.BoolVar(&verbose, "verbose", false, "")
+	flags.IntVar(&parentPID, "parent-pid", 0, "")
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
 			os.Exit(0)
 		}
+		os.Exit(2)
 	}
 
-	// Configure logging - verbosity can be increased for debugging
-	commonlog.Configure(1, nil)
+	// Handle version flag
+	if showVersion {
+		fmt.Printf("%s %s\n", name, version)
+		fmt.Printf("commit: %s\n", commit)
+		fmt.Printf("built:  %s\n", buildTime)
+		os.Exit(0)
+	}
+	if showHelp {
+		printHelp()
+		os.Exit(0)
+	}
 
-	server := lsp.NewServer(name, version)
+	// Log to stderr unless a file is given; --verbose adds a line for every
+	// message handled, with its duration
+	verbosity := 1
+	if verbose {
+		verbosity = 2
+	}
+	if logFile != "" {
+		commonlog.Configure(verbosity, &logFile)
+	} else {
+		commonlog.Configure(verbosity, nil)
+	}
 
-	// Run the server on stdio
-	if err := server.RunStdio(); err != nil {
+	var allowOrigins []string
+	if allowOrigin != "" {
+		allowOrigins = strings.Split(allowOrigin, ",")
+	}
+	if err := serve(listen, allowOrigins, otlpEndpoint, parentPID); err != nil {
 		commonlog.GetLogger(name).Errorf("Server error: %v", err)
 		os.Exit(1)
 	}
 }
 
+// serve runs the language server on stdio, or over the network when listen
+// is set, accepting WebSocket clients from loopback pages and allowOrigins,
+// exporting traces when an OTLP endpoint is configured. On stdio it exits
+// once the process parentPID is gone, if set.
+func serve(listen string, allowOrigins []string, otlpEndpoint string, parentPID int) error {
+	shutdownTracing, err := telemetry.Setup(context.Background(), telemetry.Config{
+		Endpoint:       otlpEndpoint,
+		ServiceName:    name,
+		ServiceVersion: version,
+	})
+	if err != nil {
+		return fmt.Errorf("tracing setup: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			commonlog.GetLogger(name).Errorf("Tracing shutdown failed: %v", err)
+		}
+	}()
+
+	// Serve clients over the network until interrupted
+	if listen != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		newServer := func() *lsp.Server { return lsp.NewServer(name, version) }
+		return lsp.ListenAndServe(ctx, listen, allowOrigins, newServer)
+	}
+
+	// Run the server on stdio
+	server := lsp.NewServer(name, version)
+	if parentPID > 0 {
+		server.WatchParent(parentPID)
+	}
+	return server.RunStdio()
+}
+
 func printHelp() {
 	fmt.Printf(`%s - Godot Language Server
 
@@ -55,14 +139,37 @@ A Language Server Protocol implementation for Godot files.
 Supported file types:
   - Text Scene files (.tscn, .escn)
   - Shader files (.gdshader, .gdshaderinc)
+  - RenderingDevice shaders, such as compute shaders (.glsl)
+  - Project settings (project.godot)
+  - Import and GDExtension files (.import, .gdextension)
 
 Usage:
   %s [options]
+  %s check [--format text|json] [paths...]
+  %s fmt [-w] [-l] [-align] [paths...]
 
 Options:
-  -v, --version    Print version information
-  -h, --help       Print this help message
+  -v, --version         Print version information
+  -h, --help            Print this help message
+  --listen <address>    Listen for clients on tcp://host:port or ws://host:port[/path]
+                        instead of using stdio. Clients are not authenticated:
+                        anyone who can reach the address gets a full session
+  --allow-origin <list> Comma-separated origins, e.g. https://editor.example.com,
+                        whose pages may connect over ws://; by default only
+                        pages served from localhost can
+  --otlp-endpoint <url> Export OpenTelemetry traces over OTLP/HTTP, e.g.
+                        http://localhost:4318 (also enabled by the standard
+                        OTEL_EXPORTER_OTLP_ENDPOINT environment variable)
+  --log-file <path>     Write logs to a file instead of stderr
+  --verbose             Log every request and notification with its duration
+  --parent-pid <pid>    Exit when this process, usually the editor, is gone
+                        (defaults to the processId sent by the client)
+
+By default the server communicates via stdio using the Language Server Protocol.
+With --listen, every connecting client gets its own session.
 
-The server communicates via stdio using the Language Server Protocol.
-`, name, name)
+Commands:
+  check    Analyze files without an editor and print their diagnostics
+  fmt      Format shader and scene files, printing a diff or rewriting them with -w
+`, name, name, name, name)
 }
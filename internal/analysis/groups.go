@@ -0,0 +1,161 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// GroupMember is a node of a scene that belongs to a group, as listed in
+// the groups=[...] of its [node] header.
+type GroupMember struct {
+	Group string
+	URI   string // Of the scene
+	Path  string // Node path from the root, "." for the root itself
+	Name  string
+	Type  string       // Declared type, empty for instances
+	Range parser.Range // The group string in the header
+}
+
+// SceneGroups returns the group memberships of the nodes of a scene, in
+// document order. Empty group names are left out.
+func SceneGroups(uri string, ast *parser.Document) []GroupMember {
+	if ast == nil {
+		return nil
+	}
+	var members []GroupMember
+	tree := NewSceneTree(ast)
+	for _, node := range ast.Nodes {
+		sn := tree.NodeFor(node)
+		if sn == nil {
+			continue
+		}
+		for i, group := range node.Groups {
+			if group == "" {
+				continue // Being typed
+			}
+			members = append(members, GroupMember{
+				Group: group,
+				URI:   uri,
+				Path:  sn.Path,
+				Name:  node.Name,
+				Type:  node.Type,
+				Range: node.GroupRanges[i],
+			})
+		}
+	}
+	return members
+}
+
+// ReadSceneGroups reads the group memberships of the scenes at paths, in
+// parallel, and returns them by path. Files that are not scenes are
+// skipped, as only scenes have nodes.
+func ReadSceneGroups(paths []string, uris []string) map[string][]GroupMember {
+	found := make([][]GroupMember, len(paths))
+	parallel(context.Background(), len(paths), func(i int) {
+		if !isSceneFile(paths[i]) {
+			return
+		}
+		if content, err := os.ReadFile(paths[i]); err == nil {
+			found[i] = SceneGroups(uris[i], parser.Parse(string(content)))
+		}
+	}, nil)
+
+	groups := make(map[string][]GroupMember)
+	for i, members := range found {
+		if len(members) > 0 {
+			groups[uris[i]] = members
+		}
+	}
+	return groups
+}
+
+// isSceneFile reports whether path names a scene, whose nodes can have
+// groups.
+func isSceneFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".tscn" || ext == ".escn"
+}
+
+// SetFolderGroups records the group memberships of the scenes of a
+// workspace folder, by scene URI.
+func (w *Workspace) SetFolderGroups(folderURI string, groups map[string][]GroupMember) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for uri := range w.groups {
+		if inFolder(folderURI, uri) {
+			delete(w.groups, uri)
+		}
+	}
+	for uri, members := range groups {
+		w.groups[uri] = members
+	}
+}
+
+// SetSceneGroups records the group memberships of one scene on disk, e.g.
+// after it changed outside the editor. nil forgets the scene.
+func (w *Workspace) SetSceneGroups(uri string, members []GroupMember) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(members) == 0 {
+		delete(w.groups, uri)
+		return
+	}
+	w.groups[uri] = members
+}
+
+// Groups returns the group memberships of the nodes of every scene in the
+// project of the document at uri, sorted by group, scene and position. Open
+// scenes are read from the editor's content, so unsaved edits count.
+func (w *Workspace) Groups(uri string) []GroupMember {
+	w.mu.RLock()
+	folder := w.folderOf(uri)
+	var members []GroupMember
+	for sceneURI, indexed := range w.groups {
+		if _, open := w.documents[sceneURI]; !open && (folder == "" || inFolder(folder, sceneURI)) {
+			members = append(members, indexed...)
+		}
+	}
+	var open []string
+	for docURI, doc := range w.documents {
+		if doc.Type == DocumentTypeTSCN && (folder == "" || inFolder(folder, docURI)) {
+			open = append(open, docURI)
+		}
+	}
+	w.mu.RUnlock()
+
+	// Open documents may need parsing, which takes the lock
+	for _, docURI := range open {
+		if doc := w.GetDocument(docURI); doc != nil {
+			members = append(members, SceneGroups(docURI, doc.TSCNAST)...)
+		}
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		a, b := members[i], members[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+		return a.Range.Start.Offset < b.Range.Start.Offset
+	})
+	return members
+}
+
+// GroupMembers returns the nodes of the project of the document at uri
+// that belong to group.
+func (w *Workspace) GroupMembers(uri, group string) []GroupMember {
+	var members []GroupMember
+	for _, m := range w.Groups(uri) {
+		if m.Group == group {
+			members = append(members, m)
+		}
+	}
+	return members
+}
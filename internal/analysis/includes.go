@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// IncludeLoader returns the URI and the content of the file an #include in
+// the shader at from names, or false when it cannot be read.
+type IncludeLoader func(from, path string) (uri, content string, ok bool)
+
+// SetIncludeLoader sets how the files shaders include are read. Without a
+// loader, included declarations are unknown to the shaders including them.
+func (w *Workspace) SetIncludeLoader(load IncludeLoader) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.loadInclude = load
+}
+
+// includeResolver returns the resolver of the #include directives of
+// shaders, or nil without a loader.
+func (w *Workspace) includeResolver() gdshader.IncludeResolver {
+	w.mu.RLock()
+	load := w.loadInclude
+	w.mu.RUnlock()
+	if load == nil {
+		return nil
+	}
+	return func(from, path string) (string, *gdshader.ShaderDocument) {
+		uri, content, ok := load(from, path)
+		if !ok {
+			return uri, nil
+		}
+		return uri, gdshader.NewParser(content).Parse()
+	}
+}
+
+// DocumentContent returns the content of an open document without parsing
+// it, e.g. for reading a file included by the document being parsed.
+func (w *Workspace) DocumentContent(uri string) (string, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if doc := w.documents[uri]; doc != nil {
+		return doc.Content, true
+	}
+	return "", false
+}
+
+// ShaderDependents returns the URIs of the open shaders including the file
+// at uri, directly or through other includes, sorted.
+func (w *Workspace) ShaderDependents(uri string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var dependents []string
+	for docURI, doc := range w.documents {
+		if slices.Contains(doc.ShaderIncludes, uri) {
+			dependents = append(dependents, docURI)
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// InvalidateShaders leaves open shaders to be analyzed again on their next
+// use, e.g. after a file they include changed.
+func (w *Workspace) InvalidateShaders(uris []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, uri := range uris {
+		if doc := w.documents[uri]; doc != nil && doc.Type == DocumentTypeGDShader {
+			w.markStale(uri)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package analysis
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// parallel calls fn for each index below n on a pool of GOMAXPROCS
+// workers. fn must only write to state of its own index, such as an
+// element of a results slice, so that no locking is needed; the caller
+// merges the results once parallel returns. report, if set, is called from
+// the calling goroutine each time fn returns, with the number of calls
+// done so far. Once ctx is canceled no more indexes are handed out, and
+// parallel returns after the calls in flight finish, with their number.
+func parallel(ctx context.Context, n int, fn func(i int), report func(done int)) int {
+	indexes := make(chan int)
+	finished := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+				finished <- struct{}{}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(indexes)
+		for i := range n {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for range finished {
+		done++
+		if report != nil {
+			report(done)
+		}
+	}
+	return done
+}
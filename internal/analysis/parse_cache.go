@@ -0,0 +1,140 @@
+package analysis
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/andresperezl/gdls/internal/glsl"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// Parsed documents are never modified, so documents with the same content
+// can share what was parsed. The parse cache keeps the ASTs of the contents
+// parsed last, by a hash of the content, which saves parsing a file saved,
+// reopened or read from disk again unchanged, e.g. the scenes instanced by
+// a scene, read for every request following a path into them. Shaders are
+// not cached: their analysis depends on the files they include. GLSL files
+// are, as only their own syntax is looked at.
+
+// ParseCacheSize is the number of parsed contents the parse cache keeps.
+const ParseCacheSize = 256
+
+// parseKey identifies a parsed content.
+type parseKey struct {
+	docType DocumentType
+	sum     [sha256.Size]byte
+}
+
+// parseEntry is a parsed content of the cache.
+type parseEntry struct {
+	key       parseKey
+	tscn      *parser.Document
+	positions *PositionIndex
+	config    *parser.ConfigDocument
+	glsl      *glsl.Document
+}
+
+// parseCache is a least recently used cache of parsed contents.
+type parseCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // Of *parseEntry, most recently used first
+	entries map[parseKey]*list.Element
+	hits    int64
+	misses  int64
+}
+
+// newParseCache creates a parse cache keeping size contents.
+func newParseCache(size int) *parseCache {
+	return &parseCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[parseKey]*list.Element),
+	}
+}
+
+// get returns the parsed content of a document of the given type, parsing
+// it on a miss.
+func (c *parseCache) get(docType DocumentType, content string) *parseEntry {
+	key := parseKey{docType: docType, sum: sha256.Sum256([]byte(content))}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.MoveToFront(elem)
+		c.hits++
+		c.mu.Unlock()
+		return elem.Value.(*parseEntry)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// Parse outside the lock; a content parsed twice meanwhile is stored once
+	entry := &parseEntry{key: key}
+	switch docType {
+	case DocumentTypeTSCN:
+		entry.tscn = parser.Parse(content)
+		entry.positions = NewPositionIndex(entry.tscn)
+	case DocumentTypeConfig:
+		entry.config = parser.ParseConfig(content)
+	case DocumentTypeGLSL:
+		entry.glsl = glsl.Parse(content)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		return elem.Value.(*parseEntry)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*parseEntry).key)
+	}
+	return entry
+}
+
+// counts returns the hits and misses of the cache.
+func (c *parseCache) counts() (hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// derived holds what handlers compute from a document, e.g. its symbols or
+// semantic tokens. It lives as long as the document, which every edit
+// replaces, so it never needs to be invalidated.
+type derived struct {
+	mu     sync.Mutex
+	values map[string]any
+}
+
+// Derived returns the value compute returns for the document, computing it
+// the first time key is asked for. Errors are not kept, so a computation
+// cancelled by its request runs again for the next one. Values are shared
+// by every caller and must not be modified.
+func (d *Document) Derived(key string, compute func() (any, error)) (any, error) {
+	if d.derived == nil {
+		return compute() // Waiting to be parsed
+	}
+
+	d.derived.mu.Lock()
+	value, ok := d.derived.values[key]
+	d.derived.mu.Unlock()
+	if ok {
+		return value, nil
+	}
+
+	value, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	d.derived.mu.Lock()
+	defer d.derived.mu.Unlock()
+	if existing, ok := d.derived.values[key]; ok {
+		return existing, nil // Computed meanwhile
+	}
+	d.derived.values[key] = value
+	return value, nil
+}
@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"sort"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// PositionIndex locates the elements of a scene at a position without
+// scanning the whole document: its descriptor, sections, properties, values
+// and dictionary entries, sorted by where they start. The ranges of a scene
+// nest, so the elements containing a position are the last element starting
+// before it and the elements enclosing that one.
+type PositionIndex struct {
+	entries []indexEntry
+}
+
+// indexEntry is an element of a scene in a PositionIndex.
+type indexEntry struct {
+	rng    parser.Range
+	elem   any
+	parent int // Index of the innermost entry enclosing this one, -1 for none
+}
+
+// NewPositionIndex indexes the elements of a scene.
+func NewPositionIndex(ast *parser.Document) *PositionIndex {
+	idx := &PositionIndex{}
+	if ast == nil {
+		return idx
+	}
+
+	if ast.Descriptor != nil {
+		idx.add(ast.Descriptor.Range, ast.Descriptor)
+	}
+	for _, ext := range ast.ExtResources {
+		idx.add(ext.Range, ext)
+	}
+	for _, sub := range ast.SubResources {
+		idx.add(sub.Range, sub)
+		idx.addProperties(sub.Properties)
+	}
+	for _, node := range ast.Nodes {
+		idx.add(node.Range, node)
+		idx.addValue(node.Instance)
+		idx.addProperties(node.Properties)
+	}
+	for _, conn := range ast.Connections {
+		idx.add(conn.Range, conn)
+		for _, bind := range conn.Binds {
+			idx.addValue(bind)
+		}
+	}
+	if ast.Resource != nil {
+		idx.add(ast.Resource.Range, ast.Resource)
+		idx.addProperties(ast.Resource.Properties)
+	}
+
+	// Enclosing elements go first among those starting at the same position
+	sort.SliceStable(idx.entries, func(i, j int) bool {
+		a, b := idx.entries[i].rng, idx.entries[j].rng
+		if c := comparePositions(a.Start, b.Start); c != 0 {
+			return c < 0
+		}
+		return comparePositions(a.End, b.End) > 0
+	})
+
+	// Link each entry to the innermost entry before it that encloses it
+	var open []int
+	for i := range idx.entries {
+		e := &idx.entries[i]
+		for len(open) > 0 && comparePositions(idx.entries[open[len(open)-1]].rng.End, e.rng.End) < 0 {
+			open = open[:len(open)-1]
+		}
+		e.parent = -1
+		if len(open) > 0 {
+			e.parent = open[len(open)-1]
+		}
+		open = append(open, i)
+	}
+	return idx
+}
+
+// add adds an element to the index.
+func (idx *PositionIndex) add(rng parser.Range, elem any) {
+	idx.entries = append(idx.entries, indexEntry{rng: rng, elem: elem})
+}
+
+// addProperties adds properties and their values to the index.
+func (idx *PositionIndex) addProperties(props []*parser.Property) {
+	for _, prop := range props {
+		idx.add(prop.Range, prop)
+		idx.addValue(prop.Value)
+	}
+}
+
+// addValue adds a value and the values nested in it to the index.
+func (idx *PositionIndex) addValue(v parser.Value) {
+	if v == nil {
+		return
+	}
+	idx.add(v.GetRange(), v)
+	switch val := v.(type) {
+	case *parser.ArrayValue:
+		for _, elem := range val.Values {
+			idx.addValue(elem)
+		}
+	case *parser.DictValue:
+		for _, entry := range val.Entries {
+			idx.add(entry.Range, entry)
+			idx.addValue(entry.Key)
+			idx.addValue(entry.Value)
+		}
+	case *parser.TypedValue:
+		for _, param := range val.TypeParams {
+			idx.addValue(param)
+		}
+		for _, arg := range val.Arguments {
+			idx.addValue(arg)
+		}
+	}
+}
+
+// At returns the elements whose ranges contain the position, innermost
+// first. Ranges include their end, as the cursor sits right after the
+// element it was moved past.
+func (idx *PositionIndex) At(line, col int) []any {
+	if idx == nil {
+		return nil
+	}
+	pos := parser.Position{Line: line, Column: col}
+
+	// The last entry starting at or before the position
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return comparePositions(idx.entries[i].rng.Start, pos) > 0
+	}) - 1
+
+	// Every entry containing the position encloses that one
+	for i >= 0 && comparePositions(idx.entries[i].rng.End, pos) < 0 {
+		i = idx.entries[i].parent
+	}
+	var elems []any
+	for ; i >= 0; i = idx.entries[i].parent {
+		elems = append(elems, idx.entries[i].elem)
+	}
+	return elems
+}
+
+// ElementAt returns the innermost element of type T at the position, and false
+// when no element of that type contains it.
+func ElementAt[T any](idx *PositionIndex, line, col int) (T, bool) {
+	for _, elem := range idx.At(line, col) {
+		if t, ok := elem.(T); ok {
+			return t, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// comparePositions compares two positions by line, then column.
+func comparePositions(a, b parser.Position) int {
+	if a.Line != b.Line {
+		return a.Line - b.Line
+	}
+	return a.Column - b.Column
+}
@@ -0,0 +1,109 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// describeElements names the elements returned by PositionIndex.At.
+func describeElements(elems []any) string {
+	names := make([]string, len(elems))
+	for i, elem := range elems {
+		switch elem := elem.(type) {
+		case *parser.GdScene:
+			names[i] = "descriptor"
+		case *parser.ExtResource:
+			names[i] = "ext_resource " + elem.ID
+		case *parser.SubResource:
+			names[i] = "sub_resource " + elem.ID
+		case *parser.Node:
+			names[i] = "node " + elem.Name
+		case *parser.Connection:
+			names[i] = "connection " + elem.Signal
+		case *parser.Property:
+			names[i] = "property " + elem.Key
+		case *parser.DictEntry:
+			names[i] = "entry"
+		case *parser.StringValue:
+			names[i] = fmt.Sprintf("string %q", elem.Value)
+		case *parser.TypedValue:
+			names[i] = elem.TypeName
+		case *parser.ResourceRef:
+			names[i] = elem.RefType + " " + elem.ID
+		default:
+			names[i] = fmt.Sprintf("%T", elem)
+		}
+	}
+	return strings.Join(names, " < ")
+}
+
+func TestPositionIndex(t *testing.T) {
+	content := `[gd_scene load_steps=3 format=3]
+
+[ext_resource type="Script" path="res://player.gd" id="1_a"]
+
+[sub_resource type="BoxShape3D" id="Box_b"]
+size = Vector3(1, 2, 3)
+
+[node name="Player" type="Node3D"]
+script = ExtResource("1_a")
+meta = {"target": NodePath("Camera")}
+
+[node name="Camera" type="Camera3D" parent="."]
+
+[connection signal="ready" from="." to="Camera" method="_on_ready"]
+`
+	ast := parser.Parse(content)
+	if len(ast.Errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", ast.Errors)
+	}
+	idx := NewPositionIndex(ast)
+
+	// at returns the position of the n-th byte of the first occurrence of
+	// marker
+	at := func(marker string, n int) (int, int) {
+		t.Helper()
+		i := strings.Index(content, marker)
+		if i < 0 {
+			t.Fatalf("marker %q not found", marker)
+		}
+		i += n
+		return strings.Count(content[:i], "\n"), i - strings.LastIndex(content[:i], "\n") - 1
+	}
+
+	tests := []struct {
+		name   string
+		marker string
+		n      int
+		want   string
+	}{
+		{"descriptor", "load_steps", 0, "descriptor"},
+		{"ext_resource", `path="res://`, 0, "ext_resource 1_a"},
+		{"sub_resource property", "size", 1, "property size < sub_resource Box_b"},
+		{"typed value argument", "2, 3", 0, "*parser.NumberValue < Vector3 < property size < sub_resource Box_b"},
+		{"resource reference", `ExtResource("1_a")`, 3, "ExtResource 1_a < property script < node Player"},
+		{"nested node path", `"Camera")}`, 2, `string "Camera" < NodePath < entry < *parser.DictValue < property meta < node Player`},
+		{"node header", `type="Camera3D"`, 0, "node Camera"},
+		{"connection", `to="Camera"`, 0, "connection ready"},
+		{"blank line between sections", "\n\n[node", 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, col := at(tt.marker, tt.n)
+			if got := describeElements(idx.At(line, col)); got != tt.want {
+				t.Errorf("At(%d, %d) = %s, want %s", line, col, got, tt.want)
+			}
+		})
+	}
+
+	line, col := at("size", 1)
+	if sub, ok := ElementAt[*parser.SubResource](idx, line, col); !ok || sub.ID != "Box_b" {
+		t.Errorf("ElementAt[*parser.SubResource] = %v, %v, want Box_b", sub, ok)
+	}
+	if _, ok := ElementAt[*parser.Node](idx, line, col); ok {
+		t.Error("expected no node around a sub_resource property")
+	}
+}
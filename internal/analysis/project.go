@@ -0,0 +1,231 @@
+package analysis
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// ProjectFileName is the name of the file marking the root of a Godot project.
+const ProjectFileName = "project.godot"
+
+// FindFilesNamed returns the paths of the files named name under root, in
+// lexical order. Hidden directories such as .godot and .git are not entered,
+// as in ScanFolder.
+func FindFilesNamed(root, name string) []string {
+	var found []string
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return nil // Unreadable entries are left out
+		case d.IsDir() && path != root && strings.HasPrefix(d.Name(), "."):
+			return filepath.SkipDir
+		case !d.IsDir() && d.Name() == name:
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found
+}
+
+// Autoload is a node or script registered in the [autoload] section of
+// project.godot, added under /root when the game starts.
+type Autoload struct {
+	Name      string
+	Path      string // res:// path of the script or scene
+	Singleton bool   // Accessible by name as a global ("*" prefix)
+	URI       string // URI of the project file declaring it
+	Range     parser.Range
+}
+
+// ParseAutoload decodes an [autoload] value such as "*res://global.gd".
+func ParseAutoload(value string) (path string, singleton bool) {
+	if strings.HasPrefix(value, "*") {
+		return value[1:], true
+	}
+	return value, false
+}
+
+// IndexProjectFile records the autoloads and the legacy script class list
+// of a project file that is not open in the editor, e.g. one read from
+// disk when a folder is added.
+func (w *Workspace) IndexProjectFile(uri, content string) {
+	cfg := parser.ParseConfig(content)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.autoloads[uri] = autoloadsFromConfig(uri, cfg)
+	w.setClassList(uri, cfg)
+}
+
+// RemoveProjectFile forgets the autoloads and script classes of a deleted
+// project file.
+func (w *Workspace) RemoveProjectFile(uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.autoloads, uri)
+	delete(w.classes, uri)
+}
+
+// Autoloads returns the autoloads of the project of the document at uri,
+// sorted by name. Outside every workspace folder, the autoloads of every
+// known project file are returned.
+func (w *Workspace) Autoloads(uri string) []Autoload {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	folder := w.folderOf(uri)
+	var all []Autoload
+	for projectURI, autoloads := range w.autoloads {
+		if folder == "" || w.folderOf(projectURI) == folder {
+			all = append(all, autoloads...)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Name != all[j].Name {
+			return all[i].Name < all[j].Name
+		}
+		return all[i].URI < all[j].URI
+	})
+	return all
+}
+
+// indexDocument updates the workspace index from a parsed document.
+// Callers must hold the write lock.
+func (w *Workspace) indexDocument(doc *Document) {
+	if doc.ConfigAST == nil || !isProjectFile(doc.URI) {
+		return
+	}
+	w.autoloads[doc.URI] = autoloadsFromConfig(doc.URI, doc.ConfigAST)
+	w.setClassList(doc.URI, doc.ConfigAST)
+}
+
+// setClassList records the script classes a project file lists. Callers
+// must hold the write lock.
+func (w *Workspace) setClassList(uri string, cfg *parser.ConfigDocument) {
+	if classes := classListFromConfig(uri, cfg); len(classes) > 0 {
+		w.classes[uri] = classes
+	} else {
+		delete(w.classes, uri)
+	}
+}
+
+// isProjectFile reports whether uri names a project.godot file.
+func isProjectFile(uri string) bool {
+	return strings.HasSuffix(uri, "/"+ProjectFileName) || uri == ProjectFileName
+}
+
+// UserDataDir returns the directory Godot maps user:// to for a project,
+// following the application/config/name and use_custom_user_dir settings
+// of its project.godot. It returns "" when the base data directory of the
+// platform is unknown.
+func UserDataDir(cfg *parser.ConfigDocument) string {
+	name := configString(cfg, "application", "config/name")
+	name = safeDirName(name)
+	if name == "" {
+		name = "[unnamed project]"
+	}
+
+	base, godotDir := platformDataDir()
+	if base == "" {
+		return ""
+	}
+
+	if useCustom, ok := configBool(cfg, "application", "config/use_custom_user_dir"); ok && useCustom {
+		custom := safeDirName(configString(cfg, "application", "config/custom_user_dir_name"))
+		if custom == "" {
+			custom = name
+		}
+		return filepath.Join(base, custom)
+	}
+	return filepath.Join(base, godotDir, "app_userdata", name)
+}
+
+// platformDataDir returns the per-user data directory of the platform and
+// the name Godot gives its own folder inside it.
+func platformDataDir() (base, godotDir string) {
+	switch runtime.GOOS {
+	case "windows":
+		return os.Getenv("APPDATA"), "Godot"
+	case "darwin":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		return filepath.Join(home, "Library", "Application Support"), "Godot"
+	default:
+		if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+			return dir, "godot"
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", ""
+		}
+		return filepath.Join(home, ".local", "share"), "godot"
+	}
+}
+
+// safeDirName replaces the characters Godot does not allow in directory
+// names, mirroring String::validate_filename.
+func safeDirName(name string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*"|%<>`, r) {
+			return '_'
+		}
+		return r
+	}, name))
+}
+
+// configString returns a string setting of a project file, or "".
+func configString(cfg *parser.ConfigDocument, section, key string) string {
+	if sec := cfg.Section(section); sec != nil {
+		if prop := sec.Get(key); prop != nil {
+			if sv, ok := prop.Value.(*parser.StringValue); ok {
+				return sv.Value
+			}
+		}
+	}
+	return ""
+}
+
+// configBool returns a boolean setting of a project file.
+func configBool(cfg *parser.ConfigDocument, section, key string) (value, ok bool) {
+	if sec := cfg.Section(section); sec != nil {
+		if prop := sec.Get(key); prop != nil {
+			if bv, isBool := prop.Value.(*parser.BoolValue); isBool {
+				return bv.Value, true
+			}
+		}
+	}
+	return false, false
+}
+
+// autoloadsFromConfig extracts the [autoload] entries of a project file.
+func autoloadsFromConfig(uri string, cfg *parser.ConfigDocument) []Autoload {
+	var autoloads []Autoload
+	for _, section := range cfg.Sections {
+		if section.Name != "autoload" {
+			continue
+		}
+		for _, prop := range section.Properties {
+			sv, ok := prop.Value.(*parser.StringValue)
+			if !ok {
+				continue
+			}
+			path, singleton := ParseAutoload(sv.Value)
+			autoloads = append(autoloads, Autoload{
+				Name:      prop.Key,
+				Path:      path,
+				Singleton: singleton,
+				URI:       uri,
+				Range:     prop.Range,
+			})
+		}
+	}
+	return autoloads
+}
@@ -0,0 +1,302 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/andresperezl/gdls/internal/telemetry"
+)
+
+// DefaultMaxFileSize is the size above which scanned files are skipped.
+const DefaultMaxFileSize = 16 << 20
+
+// sniffSize is how much of a file is read to tell text from binary content.
+const sniffSize = 8000
+
+// SkipReason explains why the scanner left a file out.
+type SkipReason string
+
+const (
+	SkipSymlinkCycle SkipReason = "symlink cycle"
+	SkipTooLarge     SkipReason = "too large"
+	SkipBinary       SkipReason = "binary"
+	SkipUnreadable   SkipReason = "unreadable"
+)
+
+// binaryExtensions are the binary counterparts of the text formats gdls
+// understands. They are reported as skipped rather than silently ignored
+// since they are often mistaken for their text versions.
+var binaryExtensions = map[string]bool{
+	".scn": true, // Binary scene
+	".res": true, // Binary resource
+}
+
+// binaryMagic are file signatures of binary content saved under a text
+// extension, e.g. a resource exported as binary but named .tres.
+var binaryMagic = [][]byte{
+	[]byte("RSRC"), // Godot binary resource
+	[]byte("RSCC"), // Godot compressed binary resource
+	[]byte("GDSC"), // Compiled GDScript
+	[]byte("\x89PNG"),
+}
+
+// ScanOptions configures a workspace folder scan.
+type ScanOptions struct {
+	MaxFileSize int64 // 0 uses DefaultMaxFileSize
+
+	// Progress, if set, is called after each document found is checked,
+	// with the number checked so far and the number found.
+	Progress func(done, total int)
+}
+
+// SkippedFile is a file or directory the scanner did not index.
+type SkippedFile struct {
+	Path   string
+	Reason SkipReason
+}
+
+// ScanResult lists the files found by a scan.
+type ScanResult struct {
+	Files    []string // Sorted paths of the documents gdls can parse
+	UIDFiles []string // Paths of the *.uid sidecar files
+	Scripts  []string // Paths of the GDScript and C# scripts, which may declare classes
+	Skipped  []SkippedFile
+	Canceled bool // The scan stopped early; the lists hold what was checked
+}
+
+// ScanFolder walks root looking for documents gdls can parse. Symlinks are
+// followed, but every directory is scanned once however many links point to
+// it, and a link back to a directory being walked is reported as a cycle
+// instead of hanging the walk. Hidden
+// directories such as .godot and .git are not entered. Files over the size
+// limit and files with binary content are skipped and reported.
+//
+// The folder is walked first, then the documents found are checked
+// concurrently, reporting opts.Progress as each one is. Canceling ctx stops
+// the checks and returns what was found so far with Canceled set.
+func ScanFolder(ctx context.Context, root string, opts ScanOptions) ScanResult {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+
+	_, span := telemetry.Start(ctx, "scan", attribute.String("folder.path", root))
+	defer span.End()
+
+	s := &scanner{
+		opts:    opts,
+		visited: make(map[string]bool),
+		active:  make(map[string]bool),
+	}
+	s.scanDir(root)
+
+	s.checkFiles(ctx)
+
+	sort.Strings(s.result.Files)
+	span.SetAttributes(
+		attribute.Int("scan.files", len(s.result.Files)),
+		attribute.Int("scan.skipped", len(s.result.Skipped)),
+		attribute.Bool("scan.canceled", s.result.Canceled))
+	return s.result
+}
+
+// ScanFile reports whether ScanFolder would index the file at path when
+// scanning root, e.g. for a file created after the folder was scanned.
+func ScanFile(root, path string, opts ScanOptions) bool {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+
+	// Hidden directories are not entered
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	for _, dir := range strings.Split(filepath.ToSlash(rel), "/") {
+		if dir == ".." || (dir != "." && strings.HasPrefix(dir, ".")) {
+			return false
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	s := &scanner{opts: opts}
+	s.scanFile(path, info)
+	s.checkFiles(context.Background())
+	return len(s.result.Files) == 1
+}
+
+type scanner struct {
+	opts    ScanOptions
+	visited map[string]bool // Real paths of the directories entered
+	active  map[string]bool // Real paths of the directories being walked
+	pending []foundFile     // Documents found by the walk, to be checked
+	result  ScanResult
+}
+
+// foundFile is a document found by the walk.
+type foundFile struct {
+	path    string
+	info    os.FileInfo
+	checked bool       // Set once checkFile ran
+	reason  SkipReason // Why the check left it out, or "" to index it
+}
+
+func (s *scanner) skip(path string, reason SkipReason) {
+	s.result.Skipped = append(s.result.Skipped, SkippedFile{Path: path, Reason: reason})
+}
+
+func (s *scanner) scanDir(dir string) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		s.skip(dir, SkipUnreadable)
+		return
+	}
+	if s.active[real] {
+		s.skip(dir, SkipSymlinkCycle)
+		return
+	}
+	if s.visited[real] {
+		return // Reached again through another symlink
+	}
+	s.visited[real] = true
+	s.active[real] = true
+	defer delete(s.active, real)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		s.skip(dir, SkipUnreadable)
+		return
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+
+		// Stat follows symlinks, so a link is treated as what it points to
+		info, err := os.Stat(path)
+		if err != nil {
+			if entry.Type()&os.ModeSymlink == 0 {
+				s.skip(path, SkipUnreadable)
+			}
+			continue // Dangling symlinks are ignored
+		}
+
+		if info.IsDir() {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				s.scanDir(path)
+			}
+			continue
+		}
+		s.scanFile(path, info)
+	}
+}
+
+// scanFile sorts a file found by the walk by its name, leaving documents
+// to be checked by checkFiles.
+func (s *scanner) scanFile(path string, info os.FileInfo) {
+	if binaryExtensions[strings.ToLower(filepath.Ext(path))] {
+		s.skip(path, SkipBinary)
+		return
+	}
+	if strings.EqualFold(filepath.Ext(path), UIDFileExt) {
+		s.result.UIDFiles = append(s.result.UIDFiles, path)
+		return
+	}
+	if IsScriptFile(path) {
+		if info.Size() <= s.opts.MaxFileSize {
+			s.result.Scripts = append(s.result.Scripts, path)
+		}
+		return
+	}
+	if GetDocumentType(path) == DocumentTypeUnknown {
+		return
+	}
+	s.pending = append(s.pending, foundFile{path: path, info: info})
+}
+
+// checkFiles checks the documents found by the walk in parallel, since
+// sniffing their content is what a scan of a large project spends its time
+// on, then adds them to the result in the order they were found.
+func (s *scanner) checkFiles(ctx context.Context) {
+	var report func(done int)
+	if s.opts.Progress != nil {
+		report = func(done int) { s.opts.Progress(done, len(s.pending)) }
+	}
+	parallel(ctx, len(s.pending), func(i int) {
+		s.pending[i].reason = s.checkFile(s.pending[i].path, s.pending[i].info)
+		s.pending[i].checked = true
+	}, report)
+
+	for _, file := range s.pending {
+		switch {
+		case !file.checked:
+			s.result.Canceled = true
+		case file.reason != "":
+			s.skip(file.path, file.reason)
+		default:
+			s.result.Files = append(s.result.Files, file.path)
+		}
+	}
+}
+
+// checkFile returns why a document is left out of the index: it is too
+// large or binary. It returns "" for documents to index.
+func (s *scanner) checkFile(path string, info os.FileInfo) SkipReason {
+	if info.Size() > s.opts.MaxFileSize {
+		return SkipTooLarge
+	}
+
+	binary, err := isBinaryFile(path)
+	if err != nil {
+		return SkipUnreadable
+	}
+	if binary {
+		return SkipBinary
+	}
+	return ""
+}
+
+// isBinaryFile reports whether the start of a file looks like binary data:
+// a known binary signature or a NUL byte, which text formats never contain.
+func isBinaryFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return IsBinaryContent(buf[:n]), nil
+}
+
+// IsBinaryContent reports whether data looks like binary rather than text.
+func IsBinaryContent(data []byte) bool {
+	for _, magic := range binaryMagic {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	if len(data) > sniffSize {
+		data = data[:sniffSize]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// StripByteOrderMark removes the UTF-8 byte order mark some Windows editors
+// start files with. Editors do not show it, so documents are kept without
+// it and the columns of their first line match the editor's.
+func StripByteOrderMark(content string) string {
+	return strings.TrimPrefix(content, "\uFEFF")
+}
@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScanFolder(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("project.godot", "config_version=5\n")
+	write("scenes/main.tscn", "[gd_scene format=3]\n")
+	write("scenes/level.scn", "RSRC\x00\x00")
+	write("shaders/water.gdshader", "shader_type spatial;\n")
+	write("shaders/big.gdshader", strings.Repeat("// padding\n", 20))
+	write("data/exported.tres", "RSRC\x00binary")
+	write("icon.png", "\x89PNG\r\n")
+	write(".godot/imported/cache.tres", "[gd_resource]\n")
+
+	// A link back to the root and a second link to an already scanned folder
+	if err := os.Symlink(root, filepath.Join(root, "scenes", "loop")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(root, "shaders"), filepath.Join(root, "shaders-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var progress []int
+	result := ScanFolder(context.Background(), root, ScanOptions{
+		MaxFileSize: 100,
+		Progress:    func(done, total int) { progress = append(progress, done, total) },
+	})
+
+	var files []string
+	for _, path := range result.Files {
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, filepath.ToSlash(rel))
+	}
+	expected := []string{"project.godot", "scenes/main.tscn", "shaders/water.gdshader"}
+	if strings.Join(files, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected files %v, got %v", expected, files)
+	}
+
+	skipped := make(map[string]SkipReason)
+	for _, s := range result.Skipped {
+		rel, _ := filepath.Rel(root, s.Path)
+		skipped[filepath.ToSlash(rel)] = s.Reason
+	}
+	expectedSkipped := map[string]SkipReason{
+		"scenes/loop":          SkipSymlinkCycle,
+		"scenes/level.scn":     SkipBinary,
+		"data/exported.tres":   SkipBinary,
+		"shaders/big.gdshader": SkipTooLarge,
+	}
+	if len(skipped) != len(expectedSkipped) {
+		t.Errorf("expected skipped %v, got %v", expectedSkipped, skipped)
+	}
+	for path, reason := range expectedSkipped {
+		if skipped[path] != reason {
+			t.Errorf("expected %s to be skipped as %q, got %q", path, reason, skipped[path])
+		}
+	}
+
+	// Every document found is reported once checked: the project, the main
+	// scene, both shaders and the exported resource
+	if len(progress) != 10 || progress[8] != 5 || progress[9] != 5 {
+		t.Errorf("expected progress up to 5/5, got %v", progress)
+	}
+
+	// A canceled scan stops before checking documents
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := ScanFolder(ctx, root, ScanOptions{}); !result.Canceled || len(result.Files) != 0 {
+		t.Errorf("expected a canceled scan with no files, got %+v", result)
+	}
+
+	// A single file is checked the same way, e.g. when a watcher reports it
+	for name, indexed := range map[string]bool{
+		"scenes/main.tscn":           true,
+		"scenes/level.scn":           false,
+		"shaders/big.gdshader":       false,
+		"icon.png":                   false,
+		".godot/imported/cache.tres": false,
+	} {
+		if got := ScanFile(root, filepath.Join(root, name), ScanOptions{MaxFileSize: 100}); got != indexed {
+			t.Errorf("ScanFile(%s) = %v, want %v", name, got, indexed)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package analysis
+
+import (
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// SceneTree is the node hierarchy of a scene, built from the flat list of
+// [node] sections and their parent= paths.
+type SceneTree struct {
+	Root  *SceneNode
+	Nodes []*SceneNode // In document order
+
+	byPath map[string]*SceneNode
+	byNode map[*parser.Node]*SceneNode
+}
+
+// SceneNode is a node in the scene tree.
+type SceneNode struct {
+	Node     *parser.Node
+	Path     string // Path relative to the root, "." for the root itself
+	Parent   *SceneNode
+	Children []*SceneNode
+}
+
+// NodePathSegment is one name of a node path resolved against the scene tree.
+type NodePathSegment struct {
+	Name   string
+	Offset int        // Byte offset of the name within the path
+	Node   *SceneNode // nil for "." and ".." or when the name does not resolve
+}
+
+// NewSceneTree builds the scene tree of a parsed document. Nodes are linked
+// to their parents after every path is known, so a node declared before its
+// parent still ends up under it.
+func NewSceneTree(doc *parser.Document) *SceneTree {
+	tree := &SceneTree{
+		byPath: make(map[string]*SceneNode),
+		byNode: make(map[*parser.Node]*SceneNode),
+	}
+	if doc == nil {
+		return tree
+	}
+
+	for _, node := range doc.Nodes {
+		sn := &SceneNode{Node: node}
+		switch parent := ParentPath(node.Parent); {
+		case node.Parent == "":
+			if tree.Root != nil {
+				continue // A second root is an error reported elsewhere
+			}
+			sn.Path = "."
+			tree.Root = sn
+		case parent == ".":
+			sn.Path = node.Name
+		default:
+			sn.Path = parent + "/" + node.Name
+		}
+
+		tree.byPath[sn.Path] = sn
+		tree.byNode[node] = sn
+		tree.Nodes = append(tree.Nodes, sn)
+	}
+
+	for _, sn := range tree.Nodes {
+		if sn == tree.Root {
+			continue
+		}
+		if parent, ok := tree.byPath[ParentPath(sn.Node.Parent)]; ok {
+			sn.Parent = parent
+			parent.Children = append(parent.Children, sn)
+		}
+	}
+
+	return tree
+}
+
+// ParentPath returns the parent= path of a node relative to the root, "."
+// for the root. Godot resolves it from the root like a NodePath, so
+// "./Arm/Hand" names the same node as "Arm/Hand".
+func ParentPath(parent string) string {
+	for strings.HasPrefix(parent, "./") {
+		parent = parent[2:]
+	}
+	parent = strings.TrimSuffix(parent, "/")
+	if parent == "" {
+		return "."
+	}
+	return parent
+}
+
+// Lookup returns the node at a path relative to the root ("." for the root).
+func (t *SceneTree) Lookup(path string) *SceneNode {
+	return t.byPath[path]
+}
+
+// NodeFor returns the scene node for a parsed [node] section.
+func (t *SceneTree) NodeFor(node *parser.Node) *SceneNode {
+	return t.byNode[node]
+}
+
+// ResolvePath walks a NodePath from base, resolving each name it contains.
+// Property subpaths (":position") and "%Unique" names are handled; absolute
+// paths cannot be resolved inside a single scene and yield no segments.
+func (t *SceneTree) ResolvePath(base *SceneNode, path string) []NodePathSegment {
+	_, segments := t.walkPath(base, path)
+	return segments
+}
+
+// Resolve returns the node a NodePath points to from base, or nil when it
+// does not resolve inside the scene.
+func (t *SceneTree) Resolve(base *SceneNode, path string) *SceneNode {
+	node, _ := t.walkPath(base, path)
+	return node
+}
+
+// ResolvePrefix follows path from base as far as the scene allows. It
+// returns the last node reached and the rest of the path, which is empty
+// when the whole path resolved. A non-empty rest names nodes outside this
+// scene, e.g. the children of an instanced scene; the node is nil when the
+// path leaves the scene through "..".
+func (t *SceneTree) ResolvePrefix(base *SceneNode, path string) (*SceneNode, string) {
+	if i := strings.IndexByte(path, ':'); i >= 0 {
+		path = path[:i]
+	}
+
+	node, segments := t.walkPath(base, path)
+	for i, seg := range segments {
+		if seg.Node != nil {
+			continue
+		}
+		if i > 0 {
+			return segments[i-1].Node, path[seg.Offset:]
+		}
+		// Only "." and ".." come before the first name
+		prefix := strings.Trim(path[:seg.Offset], "/")
+		if prefix == "" || prefix == "." {
+			return base, path[seg.Offset:]
+		}
+		reached, _ := t.walkPath(base, prefix)
+		return reached, path[seg.Offset:]
+	}
+	return node, ""
+}
+
+// walkPath follows path from base, returning the node it ends at and the
+// names it passed through.
+func (t *SceneTree) walkPath(base *SceneNode, path string) (*SceneNode, []NodePathSegment) {
+	if base == nil || path == "" || strings.HasPrefix(path, "/") {
+		return nil, nil
+	}
+	if i := strings.IndexByte(path, ':'); i >= 0 {
+		path = path[:i]
+	}
+
+	var segments []NodePathSegment
+	current := base
+	offset := 0
+	for _, name := range strings.Split(path, "/") {
+		start := offset
+		offset += len(name) + 1
+
+		switch {
+		case name == "" || name == ".":
+			continue
+		case name == "..":
+			if current != nil {
+				current = current.Parent
+			}
+			continue
+		case strings.HasPrefix(name, "%"):
+			current = t.uniqueNode(name[1:])
+		default:
+			// An unresolved name leaves current nil for the rest of the path
+			current = current.child(name)
+		}
+		segments = append(segments, NodePathSegment{Name: name, Offset: start, Node: current})
+	}
+	return current, segments
+}
+
+// child returns the direct child with the given name.
+func (n *SceneNode) child(name string) *SceneNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.Children {
+		if c.Node.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// uniqueNode returns the node marked unique_name_in_owner with the given name.
+func (t *SceneTree) uniqueNode(name string) *SceneNode {
+	for _, sn := range t.Nodes {
+		if sn.Node.Name != name {
+			continue
+		}
+		for _, prop := range sn.Node.Properties {
+			if b, ok := prop.Value.(*parser.BoolValue); ok && prop.Key == "unique_name_in_owner" && b.Value {
+				return sn
+			}
+		}
+	}
+	return nil
+}
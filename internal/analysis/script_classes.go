@@ -0,0 +1,241 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// Scripts declaring a class_name, and C# classes marked [GlobalClass], add
+// a class to the project, which scenes and resources can use as a type like
+// the classes of the engine.
+
+// ScriptClass is a class a script of the project declares.
+type ScriptClass struct {
+	Name    string
+	Extends string // As written: a class, another script class or a script path; empty when unknown
+	Path    string // res:// path of the script, when known
+	URI     string // Of the script, or of the project file listing it
+}
+
+// scriptExtensions are the extensions of the scripts declaring classes.
+var scriptExtensions = map[string]bool{".gd": true, ".cs": true}
+
+// IsScriptFile reports whether path names a GDScript or C# script.
+func IsScriptFile(path string) bool {
+	return scriptExtensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// GDScriptHeader returns the class_name and extends of a GDScript, which may
+// share a line as in "class_name Player extends CharacterBody3D".
+func GDScriptHeader(content string) (className, extends string) {
+	for line := range strings.Lines(StripByteOrderMark(content)) {
+		fields := strings.Fields(strings.SplitN(line, "#", 2)[0])
+		for i := 0; i+1 < len(fields); i++ {
+			switch {
+			case fields[i] == "class_name" && className == "":
+				className = strings.TrimSuffix(fields[i+1], ":")
+			case fields[i] == "extends" && extends == "":
+				extends = strings.Trim(strings.TrimSuffix(fields[i+1], ":"), `"`)
+			}
+		}
+		if strings.HasPrefix(line, "func ") || strings.HasPrefix(line, "var ") {
+			break // The header is over
+		}
+	}
+	return className, extends
+}
+
+// globalClassPattern matches a C# class marked [GlobalClass], with the type
+// it derives from, e.g. "[GlobalClass] public partial class Enemy : Node2D".
+var globalClassPattern = regexp.MustCompile(`\[GlobalClass\][^{;]*?\bclass\s+(\w+)(?:\s*:\s*([\w.]+))?`)
+
+// CSharpGlobalClass returns the name and base type of the [GlobalClass] a
+// C# script declares, or "" when it declares none.
+func CSharpGlobalClass(content string) (className, extends string) {
+	m := globalClassPattern.FindStringSubmatch(content)
+	if m == nil {
+		return "", ""
+	}
+	extends = m[2]
+	if i := strings.LastIndexByte(extends, '.'); i >= 0 {
+		extends = extends[i+1:] // Godot.Node2D
+	}
+	return m[1], extends
+}
+
+// ReadScriptClasses reads the classes the scripts at paths declare, in
+// parallel, and returns them by URI. root is the project the scripts
+// belong to, for their res:// paths.
+func ReadScriptClasses(root string, paths, uris []string) map[string][]ScriptClass {
+	found := make([]*ScriptClass, len(paths))
+	parallel(context.Background(), len(paths), func(i int) {
+		content, err := os.ReadFile(paths[i])
+		if err != nil {
+			return
+		}
+		var name, extends string
+		if strings.EqualFold(filepath.Ext(paths[i]), ".cs") {
+			name, extends = CSharpGlobalClass(string(content))
+		} else {
+			name, extends = GDScriptHeader(string(content))
+		}
+		if name == "" {
+			return
+		}
+		res, _ := ResPath(root, paths[i])
+		found[i] = &ScriptClass{Name: name, Extends: extends, Path: res, URI: uris[i]}
+	}, nil)
+
+	classes := make(map[string][]ScriptClass)
+	for i, class := range found {
+		if class != nil {
+			classes[uris[i]] = []ScriptClass{*class}
+		}
+	}
+	return classes
+}
+
+// classListFromConfig extracts the _global_script_classes list Godot 3
+// kept at the top of project.godot, which Godot 4 moved to a cache file.
+func classListFromConfig(uri string, cfg *parser.ConfigDocument) []ScriptClass {
+	var classes []ScriptClass
+	for _, prop := range cfg.Properties {
+		arr, ok := prop.Value.(*parser.ArrayValue)
+		if prop.Key != "_global_script_classes" || !ok {
+			continue
+		}
+		for _, v := range arr.Values {
+			dict, ok := v.(*parser.DictValue)
+			if !ok {
+				continue
+			}
+			class := ScriptClass{URI: uri}
+			for _, entry := range dict.Entries {
+				key, _ := entry.Key.(*parser.StringValue)
+				value, _ := entry.Value.(*parser.StringValue)
+				if key == nil || value == nil {
+					continue
+				}
+				switch key.Value {
+				case "class":
+					class.Name = value.Value
+				case "base":
+					class.Extends = value.Value
+				case "path":
+					class.Path = value.Value
+				}
+			}
+			if class.Name != "" {
+				classes = append(classes, class)
+			}
+		}
+	}
+	return classes
+}
+
+// SetFolderScriptClasses records the classes declared by the scripts of a
+// workspace folder, by script URI.
+func (w *Workspace) SetFolderScriptClasses(folderURI string, classes map[string][]ScriptClass) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for uri := range w.classes {
+		if inFolder(folderURI, uri) && !isProjectFile(uri) {
+			delete(w.classes, uri)
+		}
+	}
+	for uri, declared := range classes {
+		w.classes[uri] = declared
+	}
+}
+
+// SetScriptClasses records the classes one script declares, e.g. after it
+// changed on disk. nil forgets the script.
+func (w *Workspace) SetScriptClasses(uri string, classes []ScriptClass) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(classes) == 0 {
+		delete(w.classes, uri)
+		return
+	}
+	w.classes[uri] = classes
+}
+
+// ScriptClasses returns the script classes of the project of the document
+// at uri, sorted by name. A class declared by a script takes precedence
+// over the same class in the list of a project file.
+func (w *Workspace) ScriptClasses(uri string) []ScriptClass {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	folder := w.folderOf(uri)
+	byName := make(map[string]ScriptClass)
+	for sourceURI, classes := range w.classes {
+		if folder != "" && w.folderOf(sourceURI) != folder {
+			continue
+		}
+		for _, class := range classes {
+			if existing, ok := byName[class.Name]; ok && !isProjectFile(existing.URI) {
+				continue
+			}
+			byName[class.Name] = class
+		}
+	}
+
+	all := make([]ScriptClass, 0, len(byName))
+	for _, class := range byName {
+		all = append(all, class)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// ScriptClass returns the script class of the project of the document at
+// uri with the given name.
+func (w *Workspace) ScriptClass(uri, name string) (ScriptClass, bool) {
+	for _, class := range w.ScriptClasses(uri) {
+		if class.Name == name {
+			return class, true
+		}
+	}
+	return ScriptClass{}, false
+}
+
+// ScriptClassBase returns the engine class a script class of the project of
+// the document at uri derives from, following the script classes and
+// script paths it extends, or "" when the chain cannot be followed.
+func (w *Workspace) ScriptClassBase(uri, name string) string {
+	classes := w.ScriptClasses(uri)
+	byName := make(map[string]ScriptClass, len(classes))
+	byPath := make(map[string]ScriptClass, len(classes))
+	for _, class := range classes {
+		byName[class.Name] = class
+		if class.Path != "" {
+			byPath[class.Path] = class
+		}
+	}
+
+	class, ok := byName[name]
+	for range len(classes) {
+		if !ok || class.Extends == "" {
+			return ""
+		}
+		next, isScript := byName[class.Extends]
+		if !isScript {
+			next, isScript = byPath[class.Extends]
+		}
+		if !isScript {
+			if strings.Contains(class.Extends, "/") {
+				return "" // A script without a class_name
+			}
+			return class.Extends
+		}
+		class = next
+	}
+	return "" // A cycle
+}
@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestScriptClasses(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"enemy.gd":       "class_name Enemy extends \"res://base.gd\"\n",
+		"base.gd":        "extends CharacterBody2D\nclass_name EnemyBase\n\nvar speed := 1.0\n",
+		"tool.gd":        "@tool\nextends Node\n",
+		"Item.cs":        "using Godot;\n\n[GlobalClass]\npublic partial class ItemData : Godot.Resource\n{\n}\n",
+		"Helper.cs":      "public partial class Helper : Node\n{\n}\n",
+		"loop.gd":        "class_name Loop extends Loop\n",
+		"project.godot":  "config_version=4\n\n_global_script_classes=[{\n\"base\": \"Node2D\",\n\"class\": \"Legacy\",\n\"language\": \"GDScript\",\n\"path\": \"res://legacy.gd\"\n}, {\n\"base\": \"Node\",\n\"class\": \"Enemy\",\n\"language\": \"GDScript\",\n\"path\": \"res://old_enemy.gd\"\n}]\n",
+		"level.tscn":     "[gd_scene format=3]\n",
+		"notes/a.gd.txt": "class_name NotAScript\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scan := ScanFolder(t.Context(), dir, ScanOptions{})
+	if len(scan.Scripts) != 6 {
+		t.Fatalf("expected the 6 scripts in the scan, got %v", scan.Scripts)
+	}
+	uris := make([]string, len(scan.Scripts))
+	for i, path := range scan.Scripts {
+		uris[i] = "file://" + filepath.ToSlash(path)
+	}
+	folder := "file://" + filepath.ToSlash(dir)
+
+	w := NewWorkspace()
+	w.AddFolder(folder)
+	w.SetFolderScriptClasses(folder, ReadScriptClasses(dir, scan.Scripts, uris))
+	project, _ := os.ReadFile(filepath.Join(dir, "project.godot"))
+	w.IndexProjectFile(folder+"/project.godot", string(project))
+
+	level := folder + "/level.tscn"
+	var names []string
+	for _, class := range w.ScriptClasses(level) {
+		names = append(names, class.Name)
+	}
+	if got, want := names, []string{"Enemy", "EnemyBase", "ItemData", "Legacy", "Loop"}; !slices.Equal(got, want) {
+		t.Errorf("expected classes %v, got %v", want, got)
+	}
+
+	// The script declaring a class takes precedence over the legacy list
+	if enemy, _ := w.ScriptClass(level, "Enemy"); enemy.Path != "res://enemy.gd" {
+		t.Errorf("expected Enemy from enemy.gd, got %+v", enemy)
+	}
+
+	for name, want := range map[string]string{
+		"Enemy":     "CharacterBody2D", // Through the path of the script it extends
+		"EnemyBase": "CharacterBody2D",
+		"ItemData":  "Resource",
+		"Legacy":    "Node2D",
+		"Loop":      "",
+		"Unknown":   "",
+	} {
+		if got := w.ScriptClassBase(level, name); got != want {
+			t.Errorf("ScriptClassBase(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	// A script losing its class_name
+	w.SetScriptClasses(folder+"/loop.gd", nil)
+	if _, ok := w.ScriptClass(level, "Loop"); ok {
+		t.Error("expected Loop to be forgotten")
+	}
+}
@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Scripts are not parsed: gdls only reads, line by line, the class a
+// GDScript or C# script declares, what it extends and the methods it
+// declares, which is what scenes refer to.
+
+// Script is what gdls reads of a GDScript or C# script.
+type Script struct {
+	ClassName string // class_name of a GDScript, class of a C# script
+	Extends   string // As written: a class, a script class or a script path
+	ClassLine int    // Line declaring the class, or -1
+	Methods   []ScriptMethod
+}
+
+// ScriptMethod is a method a script declares.
+type ScriptMethod struct {
+	Name   string
+	Line   int
+	Column int // Of the name
+}
+
+// Method returns the method of the script with the given name.
+func (s *Script) Method(name string) (ScriptMethod, bool) {
+	for _, m := range s.Methods {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return ScriptMethod{}, false
+}
+
+var (
+	// gdscriptFuncPattern matches a function declaration, e.g.
+	// "static func spawn(at: Vector2) -> Enemy:", including the annotations
+	// it may follow on the same line, as in "@rpc("any_peer") func sync():".
+	gdscriptFuncPattern = regexp.MustCompile(`^\s*(?:@\w+(?:\([^)]*\))?\s+)*(?:static\s+)?func\s+(\w+)`)
+
+	// gdscriptClassNamePattern matches a class_name declaration.
+	gdscriptClassNamePattern = regexp.MustCompile(`^\s*(?:@\w+(?:\([^)]*\))?\s+)*class_name\s+\w+`)
+
+	// csharpClassPattern matches a class declaration with the type it
+	// derives from, e.g. "public partial class Player : CharacterBody2D".
+	csharpClassPattern = regexp.MustCompile(`^\s*(?:(?:public|internal|private|protected|static|abstract|sealed|partial)\s+)*class\s+(\w+)(?:\s*<[^>]*>)?(?:\s*:\s*([\w.]+))?`)
+
+	// csharpMethodPattern matches a method declaration: modifiers, a
+	// return type and a name followed by its parameters or type
+	// parameters, e.g. "private async void OnBodyEntered(Node2D body)".
+	csharpMethodPattern = regexp.MustCompile(`^\s*(?:\[[^\]]*\]\s*)*(?:(?:public|private|protected|internal|static|virtual|override|abstract|async|partial|sealed|new|extern|unsafe)\s+)*([\w.]+(?:<[^()]*>)?(?:\[\])*\??)\s+(\w+)\s*[(<]`)
+)
+
+// csharpStatementWords are the keywords that can precede a call where a
+// method declaration would have its return type, as in "return Spawn(...)".
+var csharpStatementWords = map[string]bool{
+	"return": true, "new": true, "await": true, "throw": true, "else": true,
+	"yield": true, "case": true, "goto": true, "in": true, "is": true,
+	"as": true, "out": true, "ref": true, "using": true, "lock": true,
+	"nameof": true, "typeof": true, "sizeof": true, "when": true,
+}
+
+// ParseScript reads a GDScript or C# script, telling them apart by the
+// extension of path. Scripts of other languages give nil.
+func ParseScript(path, content string) *Script {
+	content = StripByteOrderMark(content)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".gd":
+		return parseGDScript(content)
+	case ".cs":
+		return parseCSharp(path, content)
+	}
+	return nil
+}
+
+// parseGDScript reads the header and the functions of a GDScript.
+func parseGDScript(content string) *Script {
+	script := &Script{ClassLine: -1}
+	script.ClassName, script.Extends = GDScriptHeader(content)
+	for i, line := range strings.Split(content, "\n") {
+		if script.ClassLine < 0 && gdscriptClassNamePattern.MatchString(line) {
+			script.ClassLine = i
+		}
+		if m := gdscriptFuncPattern.FindStringSubmatchIndex(line); m != nil {
+			script.Methods = append(script.Methods, ScriptMethod{Name: line[m[2]:m[3]], Line: i, Column: m[2]})
+		}
+	}
+	return script
+}
+
+// parseCSharp reads the class and the methods of a C# script. Godot uses
+// the class named after the file, or the first one when none is.
+func parseCSharp(path, content string) *Script {
+	script := &Script{ClassLine: -1}
+	fileClass := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	for i, line := range strings.Split(content, "\n") {
+		if m := csharpClassPattern.FindStringSubmatch(line); m != nil {
+			if script.ClassLine < 0 || (m[1] == fileClass && script.ClassName != fileClass) {
+				script.ClassName, script.Extends, script.ClassLine = m[1], m[2], i
+				if j := strings.LastIndexByte(script.Extends, '.'); j >= 0 {
+					script.Extends = script.Extends[j+1:] // Godot.Node2D
+				}
+			}
+			continue
+		}
+		m := csharpMethodPattern.FindStringSubmatchIndex(line)
+		if m == nil || csharpStatementWords[line[m[2]:m[3]]] {
+			continue // A call
+		}
+		script.Methods = append(script.Methods, ScriptMethod{Name: line[m[4]:m[5]], Line: i, Column: m[4]})
+	}
+	return script
+}
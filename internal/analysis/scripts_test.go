@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	gd := ParseScript("player.gd", `@tool
+class_name Player extends "res://actor.gd"
+
+func _ready() -> void:
+	pass
+
+static func spawn(at: Vector2) -> Player:
+	return null
+
+@rpc("any_peer") func _on_timer_timeout():
+	pass
+`)
+	if gd.ClassName != "Player" || gd.Extends != "res://actor.gd" || gd.ClassLine != 1 {
+		t.Errorf("unexpected GDScript header: %+v", gd)
+	}
+	if m, ok := gd.Method("spawn"); !ok || m.Line != 6 || m.Column != 12 {
+		t.Errorf("expected spawn at 6:12, got %+v", m)
+	}
+	if m, ok := gd.Method("_on_timer_timeout"); !ok || m.Line != 9 || m.Column != 22 {
+		t.Errorf("expected the annotated _on_timer_timeout at 9:22, got %+v", m)
+	}
+
+	cs := ParseScript("scripts/Door.cs", `using Godot;
+
+public class Helper
+{
+}
+
+public partial class Door : Godot.Area2D
+{
+	[Export] public int Keys { get; set; }
+
+	public override void _Ready()
+	{
+		if (Keys > 0) Open();
+		return;
+	}
+
+	private async void OnBodyEntered(Node2D body)
+	{
+		await ToSignal(GetTree(), "process_frame");
+		var list = new List<int>();
+	}
+
+	public static T Find<T>(Node from) where T : Node
+	{
+		return Find<T>(from.GetParent());
+	}
+}
+`)
+	if cs.ClassName != "Door" || cs.Extends != "Area2D" || cs.ClassLine != 6 {
+		t.Errorf("expected the class named after the file, got %+v", cs)
+	}
+	var names []string
+	for _, m := range cs.Methods {
+		names = append(names, m.Name)
+	}
+	if want := []string{"_Ready", "OnBodyEntered", "Find"}; !slices.Equal(names, want) {
+		t.Errorf("expected methods %v, got %v", want, names)
+	}
+
+	if ParseScript("shader.gdshader", "") != nil {
+		t.Error("expected no script for a shader")
+	}
+}
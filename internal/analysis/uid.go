@@ -0,0 +1,266 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// UIDCacheFile is where Godot caches the uid:// to res:// mapping of a
+// project, relative to the project root.
+const UIDCacheFile = ".godot/uid_cache.bin"
+
+// UIDFileExt is the extension of the sidecar files Godot 4.4 writes next to
+// resources that cannot store their own uid, e.g. player.gd.uid.
+const UIDFileExt = ".uid"
+
+// uidBase is the base of the uid:// text encoding. Godot computes it as
+// 'z' - 'a' + 10, so the digits are 0-9 followed by a-y.
+const uidBase = 'z' - 'a' + 10
+
+// headerUIDPattern finds the uid of a scene or resource in its header line.
+var headerUIDPattern = regexp.MustCompile(`^\[gd_(?:scene|resource)\b[^\]]*\buid="(uid://[a-z0-9]+)"`)
+
+// UIDToText encodes a numeric resource UID as a uid:// string.
+func UIDToText(id int64) string {
+	if id < 0 {
+		return "uid://<invalid>"
+	}
+	var digits []byte
+	for {
+		c := byte(id % uidBase)
+		if c < 10 {
+			digits = append(digits, '0'+c)
+		} else {
+			digits = append(digits, 'a'+c-10)
+		}
+		id /= uidBase
+		if id == 0 {
+			break
+		}
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return "uid://" + string(digits)
+}
+
+// TextToUID decodes a uid:// string into its numeric UID.
+func TextToUID(text string) (int64, bool) {
+	digits, ok := strings.CutPrefix(text, "uid://")
+	if !ok || digits == "" {
+		return 0, false
+	}
+	var id uint64
+	for _, c := range []byte(digits) {
+		id *= uidBase
+		switch {
+		case c >= '0' && c <= '9':
+			id += uint64(c - '0')
+		case c >= 'a' && c <= 'z':
+			id += uint64(c-'a') + 10
+		default:
+			return 0, false
+		}
+	}
+	return int64(id & 0x7FFFFFFFFFFFFFFF), true
+}
+
+// ParseUIDCache decodes .godot/uid_cache.bin: a little-endian entry count
+// followed by, for each entry, a 64-bit UID, a 32-bit length and that many
+// bytes of res:// path.
+func ParseUIDCache(data []byte) (map[string]string, error) {
+	if len(data) < 4 {
+		return nil, errors.New("uid cache is truncated")
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+
+	uids := make(map[string]string)
+	for range count {
+		if len(data) < 12 {
+			return uids, errors.New("uid cache is truncated")
+		}
+		id := int64(binary.LittleEndian.Uint64(data))
+		length := int(binary.LittleEndian.Uint32(data[8:]))
+		data = data[12:]
+		if length < 0 || length > len(data) {
+			return uids, errors.New("uid cache is truncated")
+		}
+		uids[UIDToText(id)] = strings.TrimRight(string(data[:length]), "\x00")
+		data = data[length:]
+	}
+	return uids, nil
+}
+
+// ResPath returns the res:// path of a file inside the project at root.
+func ResPath(root, path string) (string, bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return "res://" + filepath.ToSlash(rel), true
+}
+
+// BuildUIDIndex maps the uid:// identifiers of the project at root to res://
+// paths. It reads Godot's uid cache, then the uid sidecar files and the
+// headers of the scenes and resources found by a scan, which are more
+// recent than the cache when the editor has not run since they changed.
+func BuildUIDIndex(root string, scan ScanResult) map[string]string {
+	uids := make(map[string]string)
+	if data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(UIDCacheFile))); err == nil {
+		// Keep what was decoded before any truncation
+		uids, _ = ParseUIDCache(data)
+		if uids == nil {
+			uids = make(map[string]string)
+		}
+	}
+
+	// Scene and resource headers take precedence over sidecar files
+	var paths []string
+	paths = append(paths, scan.UIDFiles...)
+	for _, path := range scan.Files {
+		if GetDocumentType(path) == DocumentTypeTSCN {
+			paths = append(paths, path)
+		}
+	}
+
+	// Read the files in parallel, then merge them in order
+	type fileUID struct {
+		uid, res string
+		ok       bool
+	}
+	found := make([]fileUID, len(paths))
+	parallel(context.Background(), len(paths), func(i int) {
+		found[i].uid, found[i].res, found[i].ok = FileUID(root, paths[i])
+	}, nil)
+	for _, f := range found {
+		if f.ok {
+			uids[f.uid] = f.res
+		}
+	}
+
+	return uids
+}
+
+// FileUID reads the uid a file gives a resource of the project at root: the
+// uid in the header of a scene or resource, or the content of a uid sidecar
+// file, which belongs to the resource it is named after.
+func FileUID(root, path string) (uid, res string, ok bool) {
+	if strings.EqualFold(filepath.Ext(path), UIDFileExt) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", "", false
+		}
+		uid = strings.TrimSpace(string(content))
+		if _, ok := TextToUID(uid); !ok {
+			return "", "", false
+		}
+		path = path[:len(path)-len(UIDFileExt)]
+	} else if GetDocumentType(path) == DocumentTypeTSCN {
+		uid = headerUID(path)
+	}
+	if uid == "" {
+		return "", "", false
+	}
+	res, ok = ResPath(root, path)
+	return uid, res, ok
+}
+
+// headerUID returns the uid declared in the header of a scene or resource.
+func headerUID(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	if m := headerUIDPattern.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// SetFolderUIDs records the uid:// index of a workspace folder.
+func (w *Workspace) SetFolderUIDs(folderURI string, uids map[string]string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.uids[folderURI] = uids
+}
+
+// SetFolderUID maps a uid:// identifier to a res:// path in the index of a
+// workspace folder, dropping any other uid the path had.
+func (w *Workspace) SetFolderUID(folderURI, uid, res string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	uids := w.uids[folderURI]
+	if uids == nil {
+		uids = make(map[string]string)
+		w.uids[folderURI] = uids
+	}
+	removeUIDsOf(uids, res)
+	uids[uid] = res
+}
+
+// RemoveFolderUIDs drops the uid:// identifiers mapped to a res:// path in
+// the index of a workspace folder, e.g. when the resource is deleted.
+func (w *Workspace) RemoveFolderUIDs(folderURI, res string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	removeUIDsOf(w.uids[folderURI], res)
+}
+
+func removeUIDsOf(uids map[string]string, res string) {
+	for uid, path := range uids {
+		if path == res {
+			delete(uids, uid)
+		}
+	}
+}
+
+// ResolveUID returns the res:// path a uid:// identifier maps to in the
+// project of the document at uri. Outside every workspace folder, the
+// indexes of all folders are searched.
+func (w *Workspace) ResolveUID(uri, uid string) (string, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, uids := range w.uidIndexes(uri) {
+		if path, ok := uids[uid]; ok {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// HasUIDIndex reports whether the project of the document at uri has
+// uid:// mappings, i.e. whether an unknown uid means a dangling reference
+// rather than a missing index.
+func (w *Workspace) HasUIDIndex(uri string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, uids := range w.uidIndexes(uri) {
+		if len(uids) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// uidIndexes returns the uid:// indexes that apply to the document at uri:
+// that of its folder, or all of them outside every folder. Callers must
+// hold the lock.
+func (w *Workspace) uidIndexes(uri string) []map[string]string {
+	if folder := w.folderOf(uri); folder != "" {
+		return []map[string]string{w.uids[folder]}
+	}
+	return slices.Collect(maps.Values(w.uids))
+}
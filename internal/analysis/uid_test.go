@@ -0,0 +1,80 @@
+package analysis
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUIDText(t *testing.T) {
+	for _, id := range []int64{0, 1, 34, 35, 123456789, 1<<63 - 1} {
+		text := UIDToText(id)
+		back, ok := TextToUID(text)
+		if !ok || back != id {
+			t.Errorf("%d: round trip through %q gave %d", id, text, back)
+		}
+	}
+	if got := UIDToText(35); got != "uid://10" {
+		t.Errorf("expected base 35 encoding, got %q", got)
+	}
+	for _, text := range []string{"uid://", "res://a", "uid://ABC", "uid://a-b"} {
+		if _, ok := TextToUID(text); ok {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
+
+// uidCache encodes entries in the .godot/uid_cache.bin format.
+func uidCache(entries map[int64]string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len(entries)))
+	for id, path := range entries {
+		data = binary.LittleEndian.AppendUint64(data, uint64(id))
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(path)))
+		data = append(data, path...)
+	}
+	return data
+}
+
+func TestBuildUIDIndex(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string, content []byte) {
+		t.Helper()
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(UIDCacheFile, uidCache(map[int64]string{
+		1000: "res://icon.svg",
+		2000: "res://old/player.tscn",
+	}))
+	write("player.gd.uid", []byte("uid://abc\n"))
+	write("scenes/player.tscn", []byte("[gd_scene load_steps=2 format=3 uid=\"uid://"+UIDToText(2000)[6:]+"\"]\n"))
+	write("project.godot", []byte("config_version=5\n"))
+
+	uids := BuildUIDIndex(root, ScanFolder(context.Background(), root, ScanOptions{}))
+
+	expected := map[string]string{
+		UIDToText(1000): "res://icon.svg",
+		UIDToText(2000): "res://scenes/player.tscn", // The header is newer than the cache
+		"uid://abc":     "res://player.gd",
+	}
+	if len(uids) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, uids)
+	}
+	for uid, path := range expected {
+		if uids[uid] != path {
+			t.Errorf("%s: expected %s, got %q", uid, path, uids[uid])
+		}
+	}
+
+	if _, err := ParseUIDCache(uidCache(map[int64]string{1: "res://a"})[:10]); err == nil {
+		t.Error("expected an error for a truncated cache")
+	}
+}
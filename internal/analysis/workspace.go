@@ -2,11 +2,22 @@
 package analysis
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/glsl"
 	"github.com/andresperezl/gdls/internal/parser"
+	"github.com/andresperezl/gdls/internal/telemetry"
 )
 
 // DocumentType represents the type of document.
@@ -15,14 +26,67 @@ type DocumentType int
 const (
 	DocumentTypeTSCN DocumentType = iota
 	DocumentTypeGDShader
+	DocumentTypeConfig // project.godot, .import, .gdextension and other ConfigFile documents
+	DocumentTypeGLSL   // Compute and other RenderingDevice shaders
 	DocumentTypeUnknown
 )
 
-// Workspace manages all open documents and workspace folders.
+// Workspace manages all open documents and workspace folders. Each folder
+// is indexed as its own Godot project, so that uid:// identifiers and
+// autoloads resolve within the project a document belongs to.
+//
+// Documents are immutable once stored: every edit stores a new Document, so
+// a handler holding one never sees it change under it. A document waiting
+// to be parsed is parsed once, by the first caller that needs it, while
+// callers for other documents carry on.
 type Workspace struct {
-	mu        sync.RWMutex
-	documents map[string]*Document
-	folders   []string
+	mu          sync.RWMutex
+	documents   map[string]*Document
+	folders     []string
+	autoloads   map[string][]Autoload        // By the URI of the project file declaring them
+	files       map[string][]string          // Scanned document URIs by workspace folder
+	uids        map[string]map[string]string // uid:// to res:// paths by workspace folder
+	groups      map[string][]GroupMember     // Group memberships of the scenes on disk, by URI
+	classes     map[string][]ScriptClass     // By the URI of the script or project file declaring them
+	version     gdshader.Version             // Godot version shaders are analyzed for
+	loadInclude IncludeLoader
+	parsed      *parseCache // ASTs by content, shared by the documents with that content
+
+	parses     atomic.Int64 // Documents parsed
+	parseNanos atomic.Int64 // Time spent parsing them
+	lazyHits   atomic.Int64 // GetDocument calls served without parsing
+	lazyMisses atomic.Int64 // GetDocument calls that waited for a parse
+}
+
+// Stats are the counters of a workspace, to diagnose slowness.
+type Stats struct {
+	OpenDocuments int
+	IndexedFiles  int
+	Parses        int64
+	ParseTime     time.Duration
+	LazyHits      int64 // Documents returned already parsed
+	LazyMisses    int64 // Documents parsed on demand after an edit
+	ParseHits     int64 // Contents found in the parse cache
+	ParseMisses   int64 // Contents parsed for the parse cache
+}
+
+// Stats returns the counters of the workspace.
+func (w *Workspace) Stats() Stats {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	stats := Stats{
+		OpenDocuments: len(w.documents),
+		Parses:        w.parses.Load(),
+		ParseTime:     time.Duration(w.parseNanos.Load()),
+		LazyHits:      w.lazyHits.Load(),
+		LazyMisses:    w.lazyMisses.Load(),
+	}
+	stats.ParseHits, stats.ParseMisses = w.parsed.counts()
+	for _, uris := range w.files {
+		stats.IndexedFiles += len(uris)
+	}
+	return stats
 }
 
 // Document represents an open document with its parsed AST.
@@ -30,17 +94,44 @@ type Document struct {
 	URI        string
 	Content    string
 	Type       DocumentType
-	TSCNAST    *parser.Document          // For TSCN/ESCN files
+	TSCNAST    *parser.Document          // For TSCN/ESCN/TRES files
+	Positions  *PositionIndex            // Elements of TSCNAST by position
 	ShaderAST  *gdshader.ShaderDocument  // For GDShader files
 	ShaderErrs []*gdshader.SemanticError // Semantic errors from GDShader analysis
-	Version    int
+	ConfigAST  *parser.ConfigDocument    // For project.godot and .cfg files
+	GLSLAST    *glsl.Document            // For .glsl files
+	Version    int                       // Version given by the client, which increases with each change
+
+	// ShaderIncludes are the URIs of the files a shader includes, directly
+	// or through other includes.
+	ShaderIncludes []string
+
+	pending *pendingParse // set while the content is waiting to be parsed
+	derived *derived      // What handlers computed from the parsed document
+}
+
+// pendingParse parses the content of a document once, however many
+// callers are waiting for it.
+type pendingParse struct {
+	once   sync.Once
+	parsed *Document
 }
 
+// ErrStaleVersion is returned for a change older than the stored document.
+var ErrStaleVersion = errors.New("stale document version")
+
 // NewWorkspace creates a new workspace.
 func NewWorkspace() *Workspace {
 	return &Workspace{
 		documents: make(map[string]*Document),
 		folders:   []string{},
+		autoloads: make(map[string][]Autoload),
+		files:     make(map[string][]string),
+		uids:      make(map[string]map[string]string),
+		groups:    make(map[string][]GroupMember),
+		classes:   make(map[string][]ScriptClass),
+		version:   gdshader.LatestVersion,
+		parsed:    newParseCache(ParseCacheSize),
 	}
 }
 
@@ -48,7 +139,36 @@ func NewWorkspace() *Workspace {
 func (w *Workspace) AddFolder(uri string) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.folders = append(w.folders, uri)
+	if !slices.Contains(w.folders, uri) {
+		w.folders = append(w.folders, uri)
+	}
+}
+
+// RemoveFolder removes a workspace folder along with its index: its files,
+// its uid:// identifiers, the groups of its scenes, its script classes and
+// the autoloads of project files no other folder contains. Open documents stay open.
+func (w *Workspace) RemoveFolder(uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.folders = slices.DeleteFunc(w.folders, func(folder string) bool { return folder == uri })
+	delete(w.files, uri)
+	delete(w.uids, uri)
+	for projectURI := range w.autoloads {
+		if inFolder(uri, projectURI) && w.folderOf(projectURI) == "" && w.documents[projectURI] == nil {
+			delete(w.autoloads, projectURI)
+		}
+	}
+	for sceneURI := range w.groups {
+		if inFolder(uri, sceneURI) && w.folderOf(sceneURI) == "" {
+			delete(w.groups, sceneURI)
+		}
+	}
+	for sourceURI := range w.classes {
+		if inFolder(uri, sourceURI) && w.folderOf(sourceURI) == "" && w.documents[sourceURI] == nil {
+			delete(w.classes, sourceURI)
+		}
+	}
 }
 
 // GetFolders returns all workspace folders.
@@ -61,66 +181,236 @@ func (w *Workspace) GetFolders() []string {
 	return folders
 }
 
+// SetFolderFiles records the documents found by scanning a workspace folder.
+func (w *Workspace) SetFolderFiles(folderURI string, uris []string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[folderURI] = uris
+}
+
+// AddFolderFile records a document created in a workspace folder after it
+// was scanned.
+func (w *Workspace) AddFolderFile(folderURI, uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	uris := w.files[folderURI]
+	i := sort.SearchStrings(uris, uri)
+	if i < len(uris) && uris[i] == uri {
+		return
+	}
+	w.files[folderURI] = slices.Insert(uris, i, uri)
+}
+
+// RemoveFolderFile forgets a document deleted from a workspace folder.
+func (w *Workspace) RemoveFolderFile(folderURI, uri string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	uris := w.files[folderURI]
+	if i := sort.SearchStrings(uris, uri); i < len(uris) && uris[i] == uri {
+		w.files[folderURI] = slices.Delete(uris, i, i+1)
+	}
+}
+
+// FolderOf returns the innermost workspace folder containing uri, or "".
+func (w *Workspace) FolderOf(uri string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.folderOf(uri)
+}
+
+// folderOf is FolderOf for callers holding the lock.
+func (w *Workspace) folderOf(uri string) string {
+	var best string
+	for _, folder := range w.folders {
+		if inFolder(folder, uri) && len(folder) > len(best) {
+			best = folder
+		}
+	}
+	return best
+}
+
+// inFolder reports whether uri is inside the folder folderURI.
+func inFolder(folderURI, uri string) bool {
+	return strings.HasPrefix(uri, strings.TrimSuffix(folderURI, "/")+"/")
+}
+
+// Files returns the URIs of every scanned document in the workspace, sorted.
+func (w *Workspace) Files() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var all []string
+	for _, uris := range w.files {
+		all = append(all, uris...)
+	}
+	sort.Strings(all)
+	return all
+}
+
+// ImportFileExt is the extension of the files the editor writes next to
+// imported assets, e.g. icon.svg.import.
+const ImportFileExt = ".import"
+
+// GDExtensionFileExt is the extension of the files declaring the native
+// library of a GDExtension.
+const GDExtensionFileExt = ".gdextension"
+
 // GetDocumentType determines the document type from URI.
 func GetDocumentType(uri string) DocumentType {
 	lowerURI := strings.ToLower(uri)
-	if strings.HasSuffix(lowerURI, ".tscn") || strings.HasSuffix(lowerURI, ".escn") {
+	if strings.HasSuffix(lowerURI, ".tscn") || strings.HasSuffix(lowerURI, ".escn") || strings.HasSuffix(lowerURI, ".tres") {
 		return DocumentTypeTSCN
 	}
 	if strings.HasSuffix(lowerURI, ".gdshader") || strings.HasSuffix(lowerURI, ".gdshaderinc") {
 		return DocumentTypeGDShader
 	}
+	if strings.HasSuffix(lowerURI, ".godot") || strings.HasSuffix(lowerURI, ".cfg") ||
+		strings.HasSuffix(lowerURI, ImportFileExt) || strings.HasSuffix(lowerURI, GDExtensionFileExt) {
+		return DocumentTypeConfig
+	}
+	if strings.HasSuffix(lowerURI, ".glsl") {
+		return DocumentTypeGLSL
+	}
 	return DocumentTypeUnknown
 }
 
-// OpenDocument opens a document and parses it.
+// OpenDocument opens a document at version 1 and parses it.
 func (w *Workspace) OpenDocument(uri, content string) *Document {
+	return w.OpenDocumentVersion(uri, content, 1)
+}
+
+// OpenDocumentVersion opens a document at the version given by the client
+// and parses it.
+func (w *Workspace) OpenDocumentVersion(uri, content string, version int) *Document {
+	shaderVersion := w.ShaderVersion()
+	doc := w.parseDocument(uri, content, shaderVersion)
+	doc.Version = version
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
-
-	doc := parseDocument(uri, content)
-	doc.Version = 1
-	w.documents[uri] = doc
+	w.storeParsed(doc, shaderVersion)
 	return doc
 }
 
-// UpdateDocument updates a document's content and re-parses it.
+// UpdateDocument replaces a document's content, e.g. with the text of a
+// saved file, and re-parses it. Its version is kept.
 func (w *Workspace) UpdateDocument(uri, content string) *Document {
+	shaderVersion := w.ShaderVersion()
+	doc := w.parseDocument(uri, content, shaderVersion)
+
 	w.mu.Lock()
 	defer w.mu.Unlock()
+	doc.Version = 1
+	if existingDoc, exists := w.documents[uri]; exists {
+		doc.Version = existingDoc.Version
+	}
+	w.storeParsed(doc, shaderVersion)
+	return doc
+}
+
+// storeParsed stores a document parsed outside the lock for the given
+// Godot version, leaving it to be parsed again if the version changed
+// meanwhile. The caller holds the lock.
+func (w *Workspace) storeParsed(doc *Document, shaderVersion gdshader.Version) {
+	stored := doc
+	if doc.Type == DocumentTypeGDShader && shaderVersion != w.version {
+		stale := *doc
+		stale.pending = &pendingParse{}
+		stored = &stale
+	}
+	w.documents[doc.URI] = stored
+	w.indexDocument(doc)
+}
 
-	existingDoc, exists := w.documents[uri]
-	doc := parseDocument(uri, content)
+// SetDocumentContent replaces a document's content at the given version
+// without parsing it. The document is parsed lazily by the next
+// GetDocument call, which lets callers defer the cost of parsing while
+// edits are still arriving. A version no newer than the stored one is
+// rejected with ErrStaleVersion, so that a late change cannot undo a newer
+// one.
+func (w *Workspace) SetDocumentContent(uri, content string, version int) (*Document, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 
-	if exists {
-		doc.Version = existingDoc.Version + 1
-	} else {
-		doc.Version = 1
+	if existingDoc, exists := w.documents[uri]; exists && version <= existingDoc.Version {
+		return nil, fmt.Errorf("%w: %s is at version %d, got %d", ErrStaleVersion, uri, existingDoc.Version, version)
+	}
+	doc := &Document{
+		URI:     uri,
+		Content: StripByteOrderMark(content),
+		Type:    GetDocumentType(uri),
+		Version: version,
+		pending: &pendingParse{},
 	}
 	w.documents[uri] = doc
-	return doc
+	return doc, nil
+}
+
+// IsCurrent reports whether doc is still the stored version of its
+// document, so that results computed from it can be dropped once a newer
+// change arrived.
+func (w *Workspace) IsCurrent(doc *Document) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	current := w.documents[doc.URI]
+	return current != nil && current.Version == doc.Version
 }
 
-// parseDocument parses a document based on its type.
-func parseDocument(uri, content string) *Document {
+// ParseDocument parses content as the document at uri without opening it,
+// e.g. to check a file that is not open in the editor.
+func (w *Workspace) ParseDocument(uri, content string) *Document {
+	return w.parseDocument(uri, content, w.ShaderVersion())
+}
+
+// parseDocument parses a document based on its type, analyzing shaders for
+// the given Godot version.
+func (w *Workspace) parseDocument(uri, content string, version gdshader.Version) *Document {
+	start := time.Now()
+	defer func() {
+		w.parses.Add(1)
+		w.parseNanos.Add(int64(time.Since(start)))
+	}()
+
+	content = StripByteOrderMark(content)
 	docType := GetDocumentType(uri)
 	doc := &Document{
 		URI:     uri,
 		Content: content,
 		Type:    docType,
+		derived: &derived{values: make(map[string]any)},
 	}
 
+	// Parsing is lazy and not tied to a request, so each parse is its own trace
+	ctx, span := telemetry.Start(context.Background(), "parse",
+		attribute.String("document.uri", uri),
+		attribute.Int("document.size", len(content)))
+	defer span.End()
+
 	switch docType {
 	case DocumentTypeTSCN:
-		doc.TSCNAST = parser.Parse(content)
+		entry := w.parsed.get(docType, content)
+		doc.TSCNAST, doc.Positions = entry.tscn, entry.positions
 	case DocumentTypeGDShader:
 		p := gdshader.NewParser(content)
 		doc.ShaderAST = p.Parse()
 		// Run semantic analysis
 		if doc.ShaderAST != nil {
+			_, analyzeSpan := telemetry.Start(ctx, "analyze")
 			analyzer := gdshader.NewAnalyzer(doc.ShaderAST)
+			analyzer.SetVersion(version)
+			if resolve := w.includeResolver(); resolve != nil {
+				analyzer.SetIncludeResolver(uri, resolve)
+			}
 			doc.ShaderErrs = analyzer.Analyze()
+			doc.ShaderIncludes = analyzer.IncludedURIs()
+			analyzeSpan.End()
 		}
+	case DocumentTypeConfig:
+		doc.ConfigAST = w.parsed.get(docType, content).config
+	case DocumentTypeGLSL:
+		doc.GLSLAST = w.parsed.get(docType, content).glsl
 	}
 
 	return doc
@@ -133,11 +423,72 @@ func (w *Workspace) CloseDocument(uri string) {
 	delete(w.documents, uri)
 }
 
-// GetDocument returns a document by URI.
+// GetDocument returns a document by URI, parsing it first if its content
+// was set without parsing.
 func (w *Workspace) GetDocument(uri string) *Document {
+	w.mu.RLock()
+	doc := w.documents[uri]
+	version := w.version
+	w.mu.RUnlock()
+
+	if doc == nil {
+		return nil
+	}
+	if doc.pending == nil {
+		w.lazyHits.Add(1)
+		return doc
+	}
+	w.lazyMisses.Add(1)
+
+	// Parse outside the lock so other documents stay available. Callers
+	// asking for the same version wait for the first one's parse.
+	doc.pending.once.Do(func() {
+		doc.pending.parsed = w.parseDocument(uri, doc.Content, version)
+		doc.pending.parsed.Version = doc.Version
+	})
+	parsed := doc.pending.parsed
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if current := w.documents[uri]; current != doc {
+		// A newer edit arrived while parsing; leave it for its own parse
+		return parsed
+	}
+	w.documents[uri] = parsed
+	w.indexDocument(parsed)
+	return parsed
+}
+
+// SetShaderVersion sets the Godot version shaders are analyzed for. Open
+// shaders are analyzed again on their next use.
+func (w *Workspace) SetShaderVersion(version gdshader.Version) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if version == w.version {
+		return
+	}
+	w.version = version
+	for uri, doc := range w.documents {
+		if doc.Type == DocumentTypeGDShader {
+			w.markStale(uri)
+		}
+	}
+}
+
+// markStale leaves a document to be parsed again on its next use. The
+// caller holds the lock.
+func (w *Workspace) markStale(uri string) {
+	stale := *w.documents[uri]
+	stale.pending = &pendingParse{}
+	w.documents[uri] = &stale
+}
+
+// ShaderVersion returns the Godot version shaders are analyzed for.
+func (w *Workspace) ShaderVersion() gdshader.Version {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
-	return w.documents[uri]
+	return w.version
 }
 
 // GetAllDocuments returns all open documents.
@@ -162,7 +513,7 @@ func (d *Document) PositionToOffset(line, character uint32) int {
 	}
 
 	if int(line) < len(lines) {
-		lineContent := lines[line]
+		lineContent := strings.TrimSuffix(lines[line], "\r")
 		if int(character) <= len(lineContent) {
 			offset += int(character)
 		} else {
@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDocumentVersions(t *testing.T) {
+	w := NewWorkspace()
+	uri := "file:///tmp/test.tscn"
+
+	w.OpenDocumentVersion(uri, "[gd_scene format=3]\n", 5)
+	if _, err := w.SetDocumentContent(uri, "[gd_scene format=2]\n", 5); !errors.Is(err, ErrStaleVersion) {
+		t.Errorf("expected ErrStaleVersion, got %v", err)
+	}
+	if doc := w.GetDocument(uri); doc.Content != "[gd_scene format=3]\n" || doc.Version != 5 {
+		t.Errorf("expected the stale change to be dropped, got version %d: %q", doc.Version, doc.Content)
+	}
+
+	if _, err := w.SetDocumentContent(uri, "[gd_scene format=3]\n[node name=\"Root\" type=\"Node\"]\n", 6); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Concurrent readers share a single parse of the new content
+	docs := make([]*Document, 8)
+	var wg sync.WaitGroup
+	for i := range docs {
+		wg.Go(func() { docs[i] = w.GetDocument(uri) })
+	}
+	wg.Wait()
+	for _, doc := range docs {
+		if doc != docs[0] || doc.TSCNAST == nil || doc.Version != 6 {
+			t.Fatalf("expected every reader to get the same parsed version 6, got %+v", doc)
+		}
+	}
+	if !w.IsCurrent(docs[0]) {
+		t.Error("expected the parsed document to be current")
+	}
+
+	// Saving keeps the version
+	if doc := w.UpdateDocument(uri, docs[0].Content); doc.Version != 6 || !w.IsCurrent(docs[0]) {
+		t.Errorf("expected a save to keep version 6, got %d", doc.Version)
+	}
+}
+
+func TestParseCache(t *testing.T) {
+	w := NewWorkspace()
+	content := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node\"]\n"
+
+	// Saving an open document unchanged, or reading it from disk, reuses its AST
+	opened := w.OpenDocument("file:///tmp/a.tscn", content)
+	saved := w.UpdateDocument("file:///tmp/a.tscn", content)
+	copied := w.ParseDocument("file:///tmp/b.tscn", content)
+	if saved.TSCNAST != opened.TSCNAST || copied.TSCNAST != opened.TSCNAST {
+		t.Error("expected documents with the same content to share their AST")
+	}
+	if stats := w.Stats(); stats.ParseHits != 2 || stats.ParseMisses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d and %d", stats.ParseHits, stats.ParseMisses)
+	}
+
+	// The least recently used content is evicted
+	c := newParseCache(2)
+	first := c.get(DocumentTypeTSCN, "[gd_scene format=3]\n")
+	c.get(DocumentTypeTSCN, "[gd_scene format=2]\n")
+	c.get(DocumentTypeTSCN, "[gd_scene format=3]\n")
+	c.get(DocumentTypeConfig, "[gd_scene format=3]\n") // Not a scene
+	if c.get(DocumentTypeTSCN, "[gd_scene format=3]\n") != first {
+		t.Error("expected the content used last to be kept")
+	}
+	if hits, misses := c.counts(); hits != 2 || misses != 3 {
+		t.Errorf("expected 2 hits and 3 misses, got %d and %d", hits, misses)
+	}
+
+	// Derived values live as long as the document version
+	calls := 0
+	compute := func() (any, error) {
+		calls++
+		return calls, nil
+	}
+	saved.Derived("symbols", compute)
+	if v, _ := saved.Derived("symbols", compute); v != 1 {
+		t.Errorf("expected the value computed first, got %v", v)
+	}
+	if _, err := saved.Derived("tokens", func() (any, error) { return nil, errors.New("cancelled") }); err == nil {
+		t.Error("expected the error of the computation")
+	}
+	if v, _ := saved.Derived("tokens", compute); v != 2 {
+		t.Errorf("expected a failed computation to run again, got %v", v)
+	}
+	edited := w.OpenDocumentVersion("file:///tmp/a.tscn", content+"\n", 2)
+	if v, _ := edited.Derived("symbols", compute); v != 3 {
+		t.Errorf("expected an edit to compute the value again, got %v", v)
+	}
+}
+
+func TestDocumentByteOrderMarkAndCRLF(t *testing.T) {
+	w := NewWorkspace()
+	doc := w.OpenDocument("file:///project/bom.tscn", "\uFEFF[gd_scene format=3]\r\n\r\n[node name=\"Root\" type=\"Node\"]\r\n")
+	if strings.HasPrefix(doc.Content, "\uFEFF") {
+		t.Error("expected the byte order mark to be stripped")
+	}
+	if doc.TSCNAST == nil || len(doc.TSCNAST.Errors) > 0 || len(doc.TSCNAST.Nodes) != 1 {
+		t.Fatalf("expected one node and no errors, got %+v", doc.TSCNAST)
+	}
+
+	// A position past the end of a line is before its "\r\n"
+	if got, want := doc.PositionToOffset(0, 100), len("[gd_scene format=3]"); got != want {
+		t.Errorf("expected offset %d, got %d", want, got)
+	}
+}
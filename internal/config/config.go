@@ -0,0 +1,185 @@
+// Package config holds the user settings of the language server. Settings
+// are read from the initialization options and updated by
+// workspace/didChangeConfiguration, so every key is optional and a partial
+// object only changes the settings it mentions.
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// DefaultDiagnosticsDelay is how long to wait after the last edit before
+// parsing a document and publishing its diagnostics.
+const DefaultDiagnosticsDelay = 300 * time.Millisecond
+
+// DefaultMaxFileSize is the size above which workspace files are not
+// indexed.
+const DefaultMaxFileSize = analysis.DefaultMaxFileSize
+
+// GodotVersions are the Godot versions that can be targeted, oldest first.
+var GodotVersions = []string{"4.0", "4.1", "4.2", "4.3", "4.4", "4.5", "4.6"}
+
+// DefaultGodotVersion is the Godot version targeted unless configured, the
+// one the bundled class reference documents.
+const DefaultGodotVersion = "4.6"
+
+// Severity overrides the severity of a kind of diagnostic.
+type Severity string
+
+const (
+	SeverityError       Severity = "error"
+	SeverityWarning     Severity = "warning"
+	SeverityInformation Severity = "information"
+	SeverityHint        Severity = "hint"
+	SeverityOff         Severity = "off" // The diagnostic is not reported
+)
+
+var severities = []Severity{SeverityError, SeverityWarning, SeverityInformation, SeverityHint, SeverityOff}
+
+// Settings are the user settings of the server.
+type Settings struct {
+	// DiagnosticsDelay is the debounce applied to diagnostics after an edit.
+	DiagnosticsDelay time.Duration
+
+	// MaxFileSize is the size above which workspace files are not indexed.
+	MaxFileSize int64
+
+	// AlignProperties aligns the '=' of scene properties when formatting.
+	AlignProperties bool
+
+	// GodotVersion is the Godot version the project targets, e.g. "4.3".
+	GodotVersion string
+
+	// GodotPath is the Godot executable gdls/compileCheck runs, "" when
+	// none is configured.
+	GodotPath string
+
+	// ShaderSnippets offers snippets of whole shader constructs, such as
+	// processor functions and loops, among the shader completions.
+	ShaderSnippets bool
+
+	// Severities overrides the severity of diagnostics by their code.
+	Severities map[string]Severity
+
+	// Capabilities holds the capability modules turned on or off by name.
+	Capabilities map[string]bool
+}
+
+// Default returns the settings used when the client configures nothing.
+func Default() Settings {
+	return Settings{
+		DiagnosticsDelay: DefaultDiagnosticsDelay,
+		MaxFileSize:      DefaultMaxFileSize,
+		GodotVersion:     DefaultGodotVersion,
+		ShaderSnippets:   true,
+		Severities:       map[string]Severity{},
+		Capabilities:     map[string]bool{},
+	}
+}
+
+// Apply updates the settings from a decoded JSON settings object such as
+//
+//	{"maxFileSize": 1048576, "godotVersion": "4.3",
+//	 "diagnosticSeverity": {"unknown-property": "off"}}
+//
+// Settings the object does not mention are left unchanged, while an object
+// setting such as diagnosticSeverity replaces the previous one as a whole.
+// Invalid values are skipped and returned as errors. The maps of s are
+// replaced rather than modified, so copies of the previous settings stay
+// valid.
+func (s *Settings) Apply(opts map[string]any) []error {
+	var errs []error
+	invalid := func(key string, value any, want string) {
+		errs = append(errs, fmt.Errorf("invalid %s %v: expected %s", key, value, want))
+	}
+
+	if value, ok := opts["diagnosticsDelay"]; ok {
+		if delay, ok := value.(float64); ok && delay >= 0 {
+			s.DiagnosticsDelay = time.Duration(delay) * time.Millisecond
+		} else {
+			invalid("diagnosticsDelay", value, "a number of milliseconds")
+		}
+	}
+	if value, ok := opts["maxFileSize"]; ok {
+		if size, ok := value.(float64); ok && size > 0 {
+			s.MaxFileSize = int64(size)
+		} else {
+			invalid("maxFileSize", value, "a positive number of bytes")
+		}
+	}
+	if value, ok := opts["alignProperties"]; ok {
+		if align, ok := value.(bool); ok {
+			s.AlignProperties = align
+		} else {
+			invalid("alignProperties", value, "a boolean")
+		}
+	}
+	if value, ok := opts["godotPath"]; ok {
+		if path, ok := value.(string); ok {
+			s.GodotPath = path
+		} else {
+			invalid("godotPath", value, "a path")
+		}
+	}
+	if value, ok := opts["shaderSnippets"]; ok {
+		if snippets, ok := value.(bool); ok {
+			s.ShaderSnippets = snippets
+		} else {
+			invalid("shaderSnippets", value, "a boolean")
+		}
+	}
+	if value, ok := opts["godotVersion"]; ok {
+		if version, ok := value.(string); ok && slices.Contains(GodotVersions, version) {
+			s.GodotVersion = version
+		} else {
+			invalid("godotVersion", value, fmt.Sprintf("one of %v", GodotVersions))
+		}
+	}
+
+	if value, ok := opts["diagnosticSeverity"]; ok {
+		overrides, ok := value.(map[string]any)
+		if ok {
+			s.Severities = make(map[string]Severity, len(overrides))
+			for _, code := range slices.Sorted(maps.Keys(overrides)) {
+				name, _ := overrides[code].(string)
+				if severity := Severity(name); slices.Contains(severities, severity) {
+					s.Severities[code] = severity
+				} else {
+					invalid("severity for "+code, overrides[code], fmt.Sprintf("one of %v", severities))
+				}
+			}
+		} else {
+			invalid("diagnosticSeverity", value, "an object of diagnostic codes")
+		}
+	}
+
+	if value, ok := opts["capabilities"]; ok {
+		flags, ok := value.(map[string]any)
+		if ok {
+			s.Capabilities = make(map[string]bool, len(flags))
+			for _, name := range slices.Sorted(maps.Keys(flags)) {
+				if enabled, ok := flags[name].(bool); ok {
+					s.Capabilities[name] = enabled
+				} else {
+					invalid("capability "+name, flags[name], "a boolean")
+				}
+			}
+		} else {
+			invalid("capabilities", value, "an object of capability names")
+		}
+	}
+
+	return errs
+}
+
+// CapabilityEnabled reports whether the named capability is enabled.
+// Capabilities are enabled unless turned off.
+func (s Settings) CapabilityEnabled(name string) bool {
+	enabled, ok := s.Capabilities[name]
+	return !ok || enabled
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApply(t *testing.T) {
+	settings := Default()
+	previous := settings
+
+	errs := settings.Apply(map[string]any{
+		"diagnosticsDelay":   float64(50),
+		"godotVersion":       "4.2",
+		"diagnosticSeverity": map[string]any{"unknown-property": "off", "duplicate-id": "loud"},
+		"capabilities":       map[string]any{"hover": false},
+	})
+	if len(errs) != 1 {
+		t.Errorf("expected one error for the invalid severity, got %v", errs)
+	}
+	if settings.DiagnosticsDelay != 50*time.Millisecond || settings.GodotVersion != "4.2" {
+		t.Errorf("unexpected settings: %+v", settings)
+	}
+	if settings.Severities["unknown-property"] != SeverityOff {
+		t.Errorf("expected unknown-property to be off, got %v", settings.Severities)
+	}
+	if _, ok := settings.Severities["duplicate-id"]; ok {
+		t.Error("expected the invalid severity to be skipped")
+	}
+	if settings.CapabilityEnabled("hover") || !settings.CapabilityEnabled("definition") {
+		t.Errorf("unexpected capabilities: %v", settings.Capabilities)
+	}
+	if len(previous.Severities) != 0 || len(previous.Capabilities) != 0 {
+		t.Error("expected the previous settings to be left unchanged")
+	}
+
+	// A partial update keeps everything it does not mention
+	errs = settings.Apply(map[string]any{"maxFileSize": float64(1024), "godotVersion": "3.5", "shaderSnippets": false, "godotPath": "/usr/bin/godot"})
+	if len(errs) != 1 {
+		t.Errorf("expected one error for the unsupported version, got %v", errs)
+	}
+	if settings.MaxFileSize != 1024 || settings.GodotVersion != "4.2" || settings.DiagnosticsDelay != 50*time.Millisecond || settings.ShaderSnippets || settings.GodotPath != "/usr/bin/godot" {
+		t.Errorf("unexpected settings after a partial update: %+v", settings)
+	}
+
+	// Values that are not objects keep the previous overrides
+	errs = settings.Apply(map[string]any{"diagnosticSeverity": nil, "capabilities": "none"})
+	if len(errs) != 2 {
+		t.Errorf("expected two errors for the invalid objects, got %v", errs)
+	}
+	if settings.Severities["unknown-property"] != SeverityOff || settings.CapabilityEnabled("hover") {
+		t.Errorf("expected the previous overrides to be kept, got %v and %v", settings.Severities, settings.Capabilities)
+	}
+}
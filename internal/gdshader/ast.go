@@ -27,6 +27,7 @@ type ShaderDocument struct {
 	Constants   []*ConstDecl
 	Functions   []*FunctionDecl
 	Comments    []*Comment
+	Directives  []*Directive
 	Errors      []ParseError
 }
 
@@ -43,6 +44,17 @@ type Comment struct {
 	IsDoc bool // true for /** */ doc comments
 }
 
+// Directive represents a preprocessor directive such as #define or #pragma.
+// Directives are not expanded; when a conditional has several branches only
+// the first one is parsed.
+type Directive struct {
+	Range     Range
+	Name      string // Directive name without the #, e.g. "define"
+	NameRange Range
+	Args      string   // Rest of the line, without comments and line continuations
+	ArgsStart Position // Where Args starts in the source
+}
+
 // ShaderTypeDecl represents a shader_type declaration.
 type ShaderTypeDecl struct {
 	Range Range
@@ -53,26 +65,31 @@ func (s *ShaderTypeDecl) GetRange() Range { return s.Range }
 
 // RenderModeDecl represents a render_mode declaration.
 type RenderModeDecl struct {
-	Range Range
-	Modes []string
+	Range      Range
+	Modes      []string
+	ModeRanges []Range // Range of each mode name
 }
 
 func (r *RenderModeDecl) GetRange() Range { return r.Range }
 
 // StructDecl represents a struct declaration.
 type StructDecl struct {
-	Range   Range
-	Name    string
-	Members []*StructMember
+	Range     Range
+	DeclRange Range // The whole declaration, through its closing ';' or '}'
+	Name      string
+	NameRange Range
+	Members   []*StructMember
 }
 
 func (s *StructDecl) GetRange() Range { return s.Range }
 
 // StructMember represents a member of a struct.
 type StructMember struct {
-	Range Range
-	Type  *TypeSpec
-	Name  string
+	Range     Range
+	DeclRange Range // The whole member, through its ';'
+	Type      *TypeSpec
+	Name      string
+	NameRange Range
 }
 
 func (s *StructMember) GetRange() Range { return s.Range }
@@ -80,13 +97,16 @@ func (s *StructMember) GetRange() Range { return s.Range }
 // UniformDecl represents a uniform variable declaration.
 type UniformDecl struct {
 	Range        Range
-	IsGlobal     bool // global uniform
+	DeclRange    Range // The whole declaration, through its closing ';' or '}'
+	IsGlobal     bool  // global uniform
 	Type         *TypeSpec
 	Name         string
+	NameRange    Range
 	Hints        []*Hint
 	DefaultValue Expr
 	DocComment   string // From /** */ comments
 	GroupName    string // From group_uniforms
+	SubgroupName string // From group_uniforms Group.Subgroup
 }
 
 func (u *UniformDecl) GetRange() Range { return u.Range }
@@ -103,19 +123,23 @@ func (h *Hint) GetRange() Range { return h.Range }
 // VaryingDecl represents a varying variable declaration.
 type VaryingDecl struct {
 	Range         Range
+	DeclRange     Range  // The whole declaration, through its closing ';' or '}'
 	Interpolation string // "flat", "smooth", ""
 	Type          *TypeSpec
 	Name          string
+	NameRange     Range
 }
 
 func (v *VaryingDecl) GetRange() Range { return v.Range }
 
 // ConstDecl represents a constant declaration.
 type ConstDecl struct {
-	Range Range
-	Type  *TypeSpec
-	Name  string
-	Value Expr
+	Range     Range
+	DeclRange Range // The whole declaration, through its closing ';' or '}'
+	Type      *TypeSpec
+	Name      string
+	NameRange Range
+	Value     Expr
 }
 
 func (c *ConstDecl) GetRange() Range { return c.Range }
@@ -123,8 +147,10 @@ func (c *ConstDecl) GetRange() Range { return c.Range }
 // FunctionDecl represents a function declaration.
 type FunctionDecl struct {
 	Range      Range
+	DeclRange  Range // The whole declaration, through its closing ';' or '}'
 	ReturnType *TypeSpec
 	Name       string
+	NameRange  Range
 	Params     []*ParamDecl
 	Body       *BlockStmt
 }
@@ -137,6 +163,7 @@ type ParamDecl struct {
 	Qualifier string // "in", "out", "inout", "const", ""
 	Type      *TypeSpec
 	Name      string
+	NameRange Range
 }
 
 func (p *ParamDecl) GetRange() Range { return p.Range }
@@ -147,10 +174,29 @@ type TypeSpec struct {
 	Precision string // "lowp", "mediump", "highp", ""
 	Name      string // "vec3", "mat4", "MyStruct", etc.
 	ArraySize Expr   // nil if not array
+	Unsized   bool   // "[]": the size comes from the initializer
 }
 
 func (t *TypeSpec) GetRange() Range { return t.Range }
 
+// String returns the type as written, including the array size when it is
+// a literal or a named constant (e.g. "sampler2D[4]").
+func (t *TypeSpec) String() string {
+	if t.ArraySize == nil {
+		if t.Unsized {
+			return t.Name + "[]"
+		}
+		return t.Name
+	}
+	switch size := t.ArraySize.(type) {
+	case *LiteralExpr:
+		return t.Name + "[" + size.Value + "]"
+	case *IdentExpr:
+		return t.Name + "[" + size.Name + "]"
+	}
+	return t.Name + "[]"
+}
+
 // Expr is the interface for all expression nodes.
 type Expr interface {
 	Node
@@ -160,7 +206,7 @@ type Expr interface {
 // LiteralExpr represents a literal value.
 type LiteralExpr struct {
 	Range Range
-	Kind  string // "int", "float", "bool"
+	Kind  string // "int", "float", "bool", "string"
 	Value string
 }
 
@@ -239,9 +285,10 @@ type TernaryExpr struct {
 func (t *TernaryExpr) GetRange() Range { return t.Range }
 func (t *TernaryExpr) exprNode()       {}
 
-// ArrayExpr represents an array literal.
+// ArrayExpr represents an array literal or array constructor.
 type ArrayExpr struct {
 	Range    Range
+	Type     *TypeSpec // Array constructor type (float[3](...)), nil for { ... }
 	Elements []Expr
 }
 
@@ -288,6 +335,7 @@ type VarDecl struct {
 	Range     Range
 	Name      string
 	ArraySize Expr // nil if not array
+	Unsized   bool // "[]": the size comes from the initializer
 	Init      Expr // nil if no initializer
 }
 
@@ -1,16 +1,50 @@
 package gdshader
 
+import (
+	"slices"
+	"strings"
+)
+
 // BuiltinFunction represents a built-in shader function.
 type BuiltinFunction struct {
 	Name        string
 	Description string
 	Signatures  []FunctionSig
+	Stages      []string // Processor functions it can be called in; nil for any function
 }
 
+// PixelStages are the processor functions run for each pixel, where the
+// derivatives between neighbouring pixels, and what needs them, are known.
+var PixelStages = []string{"fragment", "light", "sky"}
+
 // FunctionSig represents a function signature.
 type FunctionSig struct {
-	Params []string // Parameter types
-	Return string   // Return type
+	Params     []string // Parameter types
+	Return     string   // Return type
+	Qualifiers []string // Qualifier of each parameter, "out" or "inout"; nil when all are in
+	Stages     []string // Processor functions this overload can be called in, as in BuiltinFunction
+}
+
+// Qualifier returns the qualifier of the i-th parameter, or "" for in
+// parameters.
+func (sig FunctionSig) Qualifier(i int) string {
+	if i < len(sig.Qualifiers) {
+		return sig.Qualifiers[i]
+	}
+	return ""
+}
+
+// ParamList returns the parameters as written in a declaration, with their
+// qualifiers, as in "out float".
+func (sig FunctionSig) ParamList() []string {
+	params := make([]string, len(sig.Params))
+	for i, param := range sig.Params {
+		if q := sig.Qualifier(i); q != "" {
+			param = q + " " + param
+		}
+		params[i] = param
+	}
+	return params
 }
 
 // BuiltinVariable represents a built-in shader variable.
@@ -18,8 +52,9 @@ type BuiltinVariable struct {
 	Name        string
 	Type        string
 	Description string
-	Stage       string // "vertex", "fragment", "light", or "" for global
-	ReadWrite   string // "in", "out", "inout"
+	Stage       string  // "vertex", "fragment", "light", or "" for global
+	ReadWrite   string  // "in", "out", "inout"
+	Since       Version // Godot version that added it; zero for 4.0
 }
 
 // BuiltinConstant represents a built-in constant.
@@ -41,6 +76,18 @@ const (
 	ShaderTypeFog        ShaderType = "fog"
 )
 
+// ShaderTypes lists the shader types in the order of the Godot documentation.
+var ShaderTypes = []ShaderType{ShaderTypeSpatial, ShaderTypeCanvasItem, ShaderTypeParticles, ShaderTypeSky, ShaderTypeFog}
+
+// ShaderTypeDescriptions describes what each shader type is used for.
+var ShaderTypeDescriptions = map[ShaderType]string{
+	ShaderTypeSpatial:    "3D shader for MeshInstance3D and other 3D nodes. Supports vertex, fragment, and light functions.",
+	ShaderTypeCanvasItem: "2D shader for CanvasItem nodes like Sprite2D, Control, etc. Supports vertex, fragment, and light functions.",
+	ShaderTypeParticles:  "Shader for GPUParticles2D/3D. Supports start and process functions for particle behavior.",
+	ShaderTypeSky:        "Shader for Sky resource. Used for rendering sky backgrounds.",
+	ShaderTypeFog:        "Shader for FogVolume. Used for volumetric fog effects.",
+}
+
 // BuiltinFunctions contains all built-in GLSL-like functions.
 var BuiltinFunctions = map[string]*BuiltinFunction{
 	// Trigonometric functions
@@ -119,10 +166,13 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Returns the arc-tangent of the parameter(s)",
 		Signatures: []FunctionSig{
 			{Params: []string{"float"}, Return: "float"},
-			{Params: []string{"float", "float"}, Return: "float"},
 			{Params: []string{"vec2"}, Return: "vec2"},
 			{Params: []string{"vec3"}, Return: "vec3"},
 			{Params: []string{"vec4"}, Return: "vec4"},
+			{Params: []string{"float", "float"}, Return: "float"},
+			{Params: []string{"vec2", "vec2"}, Return: "vec2"},
+			{Params: []string{"vec3", "vec3"}, Return: "vec3"},
+			{Params: []string{"vec4", "vec4"}, Return: "vec4"},
 		},
 	},
 	"sinh": {
@@ -352,12 +402,42 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Returns x modulo y",
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float"}, Return: "float"},
-			{Params: []string{"vec2", "float"}, Return: "vec2"},
 			{Params: []string{"vec2", "vec2"}, Return: "vec2"},
-			{Params: []string{"vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec3", "vec3"}, Return: "vec3"},
-			{Params: []string{"vec4", "float"}, Return: "vec4"},
 			{Params: []string{"vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"vec2", "float"}, Return: "vec2"},
+			{Params: []string{"vec3", "float"}, Return: "vec3"},
+			{Params: []string{"vec4", "float"}, Return: "vec4"},
+		},
+	},
+	"modf": {
+		Name:        "modf",
+		Description: "Splits x into its fractional part, which it returns, and its integer part, stored in i",
+		Signatures: []FunctionSig{
+			{Params: []string{"float", "float"}, Return: "float", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec2", "vec2"}, Return: "vec2", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec3", "vec3"}, Return: "vec3", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec4", "vec4"}, Return: "vec4", Qualifiers: []string{"", "out"}},
+		},
+	},
+	"frexp": {
+		Name:        "frexp",
+		Description: "Splits x into a significand in [0.5, 1.0), which it returns, and an exponent of two, stored in exp",
+		Signatures: []FunctionSig{
+			{Params: []string{"float", "int"}, Return: "float", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec2", "ivec2"}, Return: "vec2", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec3", "ivec3"}, Return: "vec3", Qualifiers: []string{"", "out"}},
+			{Params: []string{"vec4", "ivec4"}, Return: "vec4", Qualifiers: []string{"", "out"}},
+		},
+	},
+	"ldexp": {
+		Name:        "ldexp",
+		Description: "Returns x multiplied by two to the power of exp",
+		Signatures: []FunctionSig{
+			{Params: []string{"float", "int"}, Return: "float"},
+			{Params: []string{"vec2", "ivec2"}, Return: "vec2"},
+			{Params: []string{"vec3", "ivec3"}, Return: "vec3"},
+			{Params: []string{"vec4", "ivec4"}, Return: "vec4"},
 		},
 	},
 	"min": {
@@ -366,13 +446,25 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float"}, Return: "float"},
 			{Params: []string{"vec2", "vec2"}, Return: "vec2"},
-			{Params: []string{"vec2", "float"}, Return: "vec2"},
 			{Params: []string{"vec3", "vec3"}, Return: "vec3"},
-			{Params: []string{"vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"vec2", "float"}, Return: "vec2"},
+			{Params: []string{"vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "float"}, Return: "vec4"},
 			{Params: []string{"int", "int"}, Return: "int"},
+			{Params: []string{"ivec2", "ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "ivec4"}, Return: "ivec4"},
+			{Params: []string{"ivec2", "int"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "int"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "int"}, Return: "ivec4"},
 			{Params: []string{"uint", "uint"}, Return: "uint"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "uvec4"},
+			{Params: []string{"uvec2", "uint"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uint"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uint"}, Return: "uvec4"},
 		},
 	},
 	"max": {
@@ -381,13 +473,25 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float"}, Return: "float"},
 			{Params: []string{"vec2", "vec2"}, Return: "vec2"},
-			{Params: []string{"vec2", "float"}, Return: "vec2"},
 			{Params: []string{"vec3", "vec3"}, Return: "vec3"},
-			{Params: []string{"vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"vec2", "float"}, Return: "vec2"},
+			{Params: []string{"vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "float"}, Return: "vec4"},
 			{Params: []string{"int", "int"}, Return: "int"},
+			{Params: []string{"ivec2", "ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "ivec4"}, Return: "ivec4"},
+			{Params: []string{"ivec2", "int"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "int"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "int"}, Return: "ivec4"},
 			{Params: []string{"uint", "uint"}, Return: "uint"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "uvec4"},
+			{Params: []string{"uvec2", "uint"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uint"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uint"}, Return: "uvec4"},
 		},
 	},
 	"clamp": {
@@ -396,13 +500,25 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float", "float"}, Return: "float"},
 			{Params: []string{"vec2", "vec2", "vec2"}, Return: "vec2"},
-			{Params: []string{"vec2", "float", "float"}, Return: "vec2"},
 			{Params: []string{"vec3", "vec3", "vec3"}, Return: "vec3"},
-			{Params: []string{"vec3", "float", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"vec2", "float", "float"}, Return: "vec2"},
+			{Params: []string{"vec3", "float", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "float", "float"}, Return: "vec4"},
 			{Params: []string{"int", "int", "int"}, Return: "int"},
+			{Params: []string{"ivec2", "ivec2", "ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "ivec3", "ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "ivec4", "ivec4"}, Return: "ivec4"},
+			{Params: []string{"ivec2", "int", "int"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "int", "int"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "int", "int"}, Return: "ivec4"},
 			{Params: []string{"uint", "uint", "uint"}, Return: "uint"},
+			{Params: []string{"uvec2", "uvec2", "uvec2"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uvec3", "uvec3"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uvec4", "uvec4"}, Return: "uvec4"},
+			{Params: []string{"uvec2", "uint", "uint"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uint", "uint"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uint", "uint"}, Return: "uvec4"},
 		},
 	},
 	"mix": {
@@ -410,11 +526,25 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Linearly interpolates between x and y",
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float", "float"}, Return: "float"},
-			{Params: []string{"vec2", "vec2", "float"}, Return: "vec2"},
 			{Params: []string{"vec2", "vec2", "vec2"}, Return: "vec2"},
-			{Params: []string{"vec3", "vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec3", "vec3", "vec3"}, Return: "vec3"},
+			{Params: []string{"vec4", "vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"vec2", "vec2", "float"}, Return: "vec2"},
+			{Params: []string{"vec3", "vec3", "float"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4", "float"}, Return: "vec4"},
+			{Params: []string{"float", "float", "bool"}, Return: "float"},
+			{Params: []string{"vec2", "vec2", "bvec2"}, Return: "vec2"},
+			{Params: []string{"vec3", "vec3", "bvec3"}, Return: "vec3"},
+			{Params: []string{"vec4", "vec4", "bvec4"}, Return: "vec4"},
+		},
+	},
+	"fma": {
+		Name:        "fma",
+		Description: "Computes a * b + c in a single operation",
+		Signatures: []FunctionSig{
+			{Params: []string{"float", "float", "float"}, Return: "float"},
+			{Params: []string{"vec2", "vec2", "vec2"}, Return: "vec2"},
+			{Params: []string{"vec3", "vec3", "vec3"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4", "vec4"}, Return: "vec4"},
 		},
 	},
@@ -423,12 +553,12 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Returns 0.0 if x < edge, otherwise 1.0",
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float"}, Return: "float"},
-			{Params: []string{"float", "vec2"}, Return: "vec2"},
-			{Params: []string{"float", "vec3"}, Return: "vec3"},
-			{Params: []string{"float", "vec4"}, Return: "vec4"},
 			{Params: []string{"vec2", "vec2"}, Return: "vec2"},
 			{Params: []string{"vec3", "vec3"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"float", "vec2"}, Return: "vec2"},
+			{Params: []string{"float", "vec3"}, Return: "vec3"},
+			{Params: []string{"float", "vec4"}, Return: "vec4"},
 		},
 	},
 	"smoothstep": {
@@ -436,12 +566,32 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Performs smooth Hermite interpolation",
 		Signatures: []FunctionSig{
 			{Params: []string{"float", "float", "float"}, Return: "float"},
-			{Params: []string{"float", "float", "vec2"}, Return: "vec2"},
-			{Params: []string{"float", "float", "vec3"}, Return: "vec3"},
-			{Params: []string{"float", "float", "vec4"}, Return: "vec4"},
 			{Params: []string{"vec2", "vec2", "vec2"}, Return: "vec2"},
 			{Params: []string{"vec3", "vec3", "vec3"}, Return: "vec3"},
 			{Params: []string{"vec4", "vec4", "vec4"}, Return: "vec4"},
+			{Params: []string{"float", "float", "vec2"}, Return: "vec2"},
+			{Params: []string{"float", "float", "vec3"}, Return: "vec3"},
+			{Params: []string{"float", "float", "vec4"}, Return: "vec4"},
+		},
+	},
+	"isnan": {
+		Name:        "isnan",
+		Description: "Returns true for each component that is NaN",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "bool"},
+			{Params: []string{"vec2"}, Return: "bvec2"},
+			{Params: []string{"vec3"}, Return: "bvec3"},
+			{Params: []string{"vec4"}, Return: "bvec4"},
+		},
+	},
+	"isinf": {
+		Name:        "isinf",
+		Description: "Returns true for each component that is positive or negative infinity",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "bool"},
+			{Params: []string{"vec2"}, Return: "bvec2"},
+			{Params: []string{"vec3"}, Return: "bvec3"},
+			{Params: []string{"vec4"}, Return: "bvec4"},
 		},
 	},
 
@@ -577,20 +727,53 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Samples a texture",
 		Signatures: []FunctionSig{
 			{Params: []string{"sampler2D", "vec2"}, Return: "vec4"},
-			{Params: []string{"sampler2D", "vec2", "float"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec2", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler2D", "vec2"}, Return: "ivec4"},
+			{Params: []string{"isampler2D", "vec2", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler2D", "vec2"}, Return: "uvec4"},
+			{Params: []string{"usampler2D", "vec2", "float"}, Return: "uvec4", Stages: PixelStages},
 			{Params: []string{"sampler2DArray", "vec3"}, Return: "vec4"},
+			{Params: []string{"sampler2DArray", "vec3", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler2DArray", "vec3"}, Return: "ivec4"},
+			{Params: []string{"isampler2DArray", "vec3", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler2DArray", "vec3"}, Return: "uvec4"},
+			{Params: []string{"usampler2DArray", "vec3", "float"}, Return: "uvec4", Stages: PixelStages},
 			{Params: []string{"sampler3D", "vec3"}, Return: "vec4"},
+			{Params: []string{"sampler3D", "vec3", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler3D", "vec3"}, Return: "ivec4"},
+			{Params: []string{"isampler3D", "vec3", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler3D", "vec3"}, Return: "uvec4"},
+			{Params: []string{"usampler3D", "vec3", "float"}, Return: "uvec4", Stages: PixelStages},
 			{Params: []string{"samplerCube", "vec3"}, Return: "vec4"},
+			{Params: []string{"samplerCube", "vec3", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"samplerCubeArray", "vec4"}, Return: "vec4"},
+			{Params: []string{"samplerCubeArray", "vec4", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"samplerExternalOES", "vec2"}, Return: "vec4"},
+			{Params: []string{"samplerExternalOES", "vec2", "float"}, Return: "vec4", Stages: PixelStages},
 		},
 	},
-	"textureSize": {
-		Name:        "textureSize",
-		Description: "Returns the size of a texture",
+	"textureProj": {
+		Name:        "textureProj",
+		Description: "Samples a texture with projection",
 		Signatures: []FunctionSig{
-			{Params: []string{"sampler2D", "int"}, Return: "ivec2"},
-			{Params: []string{"sampler2DArray", "int"}, Return: "ivec3"},
-			{Params: []string{"sampler3D", "int"}, Return: "ivec3"},
-			{Params: []string{"samplerCube", "int"}, Return: "ivec2"},
+			{Params: []string{"sampler2D", "vec3"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec3", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler2D", "vec3"}, Return: "ivec4"},
+			{Params: []string{"isampler2D", "vec3", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler2D", "vec3"}, Return: "uvec4"},
+			{Params: []string{"usampler2D", "vec3", "float"}, Return: "uvec4", Stages: PixelStages},
+			{Params: []string{"sampler2D", "vec4"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec4", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler2D", "vec4"}, Return: "ivec4"},
+			{Params: []string{"isampler2D", "vec4", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler2D", "vec4"}, Return: "uvec4"},
+			{Params: []string{"usampler2D", "vec4", "float"}, Return: "uvec4", Stages: PixelStages},
+			{Params: []string{"sampler3D", "vec4"}, Return: "vec4"},
+			{Params: []string{"sampler3D", "vec4", "float"}, Return: "vec4", Stages: PixelStages},
+			{Params: []string{"isampler3D", "vec4"}, Return: "ivec4"},
+			{Params: []string{"isampler3D", "vec4", "float"}, Return: "ivec4", Stages: PixelStages},
+			{Params: []string{"usampler3D", "vec4"}, Return: "uvec4"},
+			{Params: []string{"usampler3D", "vec4", "float"}, Return: "uvec4", Stages: PixelStages},
 		},
 	},
 	"textureLod": {
@@ -598,17 +781,63 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Samples a texture with explicit LOD",
 		Signatures: []FunctionSig{
 			{Params: []string{"sampler2D", "vec2", "float"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec2", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec2", "float"}, Return: "uvec4"},
 			{Params: []string{"sampler2DArray", "vec3", "float"}, Return: "vec4"},
+			{Params: []string{"isampler2DArray", "vec3", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler2DArray", "vec3", "float"}, Return: "uvec4"},
 			{Params: []string{"sampler3D", "vec3", "float"}, Return: "vec4"},
+			{Params: []string{"isampler3D", "vec3", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler3D", "vec3", "float"}, Return: "uvec4"},
 			{Params: []string{"samplerCube", "vec3", "float"}, Return: "vec4"},
+			{Params: []string{"samplerCubeArray", "vec4", "float"}, Return: "vec4"},
 		},
 	},
-	"textureProj": {
-		Name:        "textureProj",
-		Description: "Samples a texture with projection",
+	"textureProjLod": {
+		Name:        "textureProjLod",
+		Description: "Samples a texture with projection and explicit LOD",
 		Signatures: []FunctionSig{
-			{Params: []string{"sampler2D", "vec3"}, Return: "vec4"},
-			{Params: []string{"sampler2D", "vec4"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec3", "float"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec3", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec3", "float"}, Return: "uvec4"},
+			{Params: []string{"sampler2D", "vec4", "float"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec4", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec4", "float"}, Return: "uvec4"},
+			{Params: []string{"sampler3D", "vec4", "float"}, Return: "vec4"},
+			{Params: []string{"isampler3D", "vec4", "float"}, Return: "ivec4"},
+			{Params: []string{"usampler3D", "vec4", "float"}, Return: "uvec4"},
+		},
+	},
+	"textureGrad": {
+		Name:        "textureGrad",
+		Description: "Samples a texture with explicit gradients",
+		Signatures: []FunctionSig{
+			{Params: []string{"sampler2D", "vec2", "vec2", "vec2"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec2", "vec2", "vec2"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec2", "vec2", "vec2"}, Return: "uvec4"},
+			{Params: []string{"sampler2DArray", "vec3", "vec2", "vec2"}, Return: "vec4"},
+			{Params: []string{"isampler2DArray", "vec3", "vec2", "vec2"}, Return: "ivec4"},
+			{Params: []string{"usampler2DArray", "vec3", "vec2", "vec2"}, Return: "uvec4"},
+			{Params: []string{"sampler3D", "vec3", "vec3", "vec3"}, Return: "vec4"},
+			{Params: []string{"isampler3D", "vec3", "vec3", "vec3"}, Return: "ivec4"},
+			{Params: []string{"usampler3D", "vec3", "vec3", "vec3"}, Return: "uvec4"},
+			{Params: []string{"samplerCube", "vec3", "vec3", "vec3"}, Return: "vec4"},
+			{Params: []string{"samplerCubeArray", "vec4", "vec3", "vec3"}, Return: "vec4"},
+		},
+	},
+	"textureProjGrad": {
+		Name:        "textureProjGrad",
+		Description: "Samples a texture with projection and explicit gradients",
+		Signatures: []FunctionSig{
+			{Params: []string{"sampler2D", "vec3", "vec2", "vec2"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec3", "vec2", "vec2"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec3", "vec2", "vec2"}, Return: "uvec4"},
+			{Params: []string{"sampler2D", "vec4", "vec2", "vec2"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec4", "vec2", "vec2"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec4", "vec2", "vec2"}, Return: "uvec4"},
+			{Params: []string{"sampler3D", "vec4", "vec3", "vec3"}, Return: "vec4"},
+			{Params: []string{"isampler3D", "vec4", "vec3", "vec3"}, Return: "ivec4"},
+			{Params: []string{"usampler3D", "vec4", "vec3", "vec3"}, Return: "uvec4"},
 		},
 	},
 	"texelFetch": {
@@ -616,17 +845,84 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Fetches a single texel",
 		Signatures: []FunctionSig{
 			{Params: []string{"sampler2D", "ivec2", "int"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "ivec2", "int"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "ivec2", "int"}, Return: "uvec4"},
 			{Params: []string{"sampler2DArray", "ivec3", "int"}, Return: "vec4"},
+			{Params: []string{"isampler2DArray", "ivec3", "int"}, Return: "ivec4"},
+			{Params: []string{"usampler2DArray", "ivec3", "int"}, Return: "uvec4"},
 			{Params: []string{"sampler3D", "ivec3", "int"}, Return: "vec4"},
+			{Params: []string{"isampler3D", "ivec3", "int"}, Return: "ivec4"},
+			{Params: []string{"usampler3D", "ivec3", "int"}, Return: "uvec4"},
 		},
 	},
-	"textureGrad": {
-		Name:        "textureGrad",
-		Description: "Samples a texture with explicit gradients",
+	"textureGather": {
+		Name:        "textureGather",
+		Description: "Gathers one component, red by default, of the four texels a bilinear sample would use",
 		Signatures: []FunctionSig{
-			{Params: []string{"sampler2D", "vec2", "vec2", "vec2"}, Return: "vec4"},
-			{Params: []string{"sampler3D", "vec3", "vec3", "vec3"}, Return: "vec4"},
-			{Params: []string{"samplerCube", "vec3", "vec3", "vec3"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec2"}, Return: "vec4"},
+			{Params: []string{"sampler2D", "vec2", "int"}, Return: "vec4"},
+			{Params: []string{"isampler2D", "vec2"}, Return: "ivec4"},
+			{Params: []string{"isampler2D", "vec2", "int"}, Return: "ivec4"},
+			{Params: []string{"usampler2D", "vec2"}, Return: "uvec4"},
+			{Params: []string{"usampler2D", "vec2", "int"}, Return: "uvec4"},
+			{Params: []string{"sampler2DArray", "vec3"}, Return: "vec4"},
+			{Params: []string{"sampler2DArray", "vec3", "int"}, Return: "vec4"},
+			{Params: []string{"isampler2DArray", "vec3"}, Return: "ivec4"},
+			{Params: []string{"isampler2DArray", "vec3", "int"}, Return: "ivec4"},
+			{Params: []string{"usampler2DArray", "vec3"}, Return: "uvec4"},
+			{Params: []string{"usampler2DArray", "vec3", "int"}, Return: "uvec4"},
+			{Params: []string{"samplerCube", "vec3"}, Return: "vec4"},
+			{Params: []string{"samplerCube", "vec3", "int"}, Return: "vec4"},
+		},
+	},
+	"textureSize": {
+		Name:        "textureSize",
+		Description: "Returns the size of a texture",
+		Signatures: []FunctionSig{
+			{Params: []string{"sampler2D", "int"}, Return: "ivec2"},
+			{Params: []string{"isampler2D", "int"}, Return: "ivec2"},
+			{Params: []string{"usampler2D", "int"}, Return: "ivec2"},
+			{Params: []string{"sampler2DArray", "int"}, Return: "ivec3"},
+			{Params: []string{"isampler2DArray", "int"}, Return: "ivec3"},
+			{Params: []string{"usampler2DArray", "int"}, Return: "ivec3"},
+			{Params: []string{"sampler3D", "int"}, Return: "ivec3"},
+			{Params: []string{"isampler3D", "int"}, Return: "ivec3"},
+			{Params: []string{"usampler3D", "int"}, Return: "ivec3"},
+			{Params: []string{"samplerCube", "int"}, Return: "ivec2"},
+			{Params: []string{"samplerCubeArray", "int"}, Return: "ivec3"},
+		},
+	},
+	"textureQueryLod": {
+		Name:        "textureQueryLod",
+		Description: "Returns the mipmap level a sample at p would use and the level of detail relative to the base level",
+		Signatures: []FunctionSig{
+			{Params: []string{"sampler2D", "vec2"}, Return: "vec2"},
+			{Params: []string{"isampler2D", "vec2"}, Return: "vec2"},
+			{Params: []string{"usampler2D", "vec2"}, Return: "vec2"},
+			{Params: []string{"sampler2DArray", "vec2"}, Return: "vec2"},
+			{Params: []string{"isampler2DArray", "vec2"}, Return: "vec2"},
+			{Params: []string{"usampler2DArray", "vec2"}, Return: "vec2"},
+			{Params: []string{"sampler3D", "vec3"}, Return: "vec2"},
+			{Params: []string{"isampler3D", "vec3"}, Return: "vec2"},
+			{Params: []string{"usampler3D", "vec3"}, Return: "vec2"},
+			{Params: []string{"samplerCube", "vec3"}, Return: "vec2"},
+		},
+		Stages: PixelStages,
+	},
+	"textureQueryLevels": {
+		Name:        "textureQueryLevels",
+		Description: "Returns the number of mipmap levels of a texture",
+		Signatures: []FunctionSig{
+			{Params: []string{"sampler2D"}, Return: "int"},
+			{Params: []string{"isampler2D"}, Return: "int"},
+			{Params: []string{"usampler2D"}, Return: "int"},
+			{Params: []string{"sampler2DArray"}, Return: "int"},
+			{Params: []string{"isampler2DArray"}, Return: "int"},
+			{Params: []string{"usampler2DArray"}, Return: "int"},
+			{Params: []string{"sampler3D"}, Return: "int"},
+			{Params: []string{"isampler3D"}, Return: "int"},
+			{Params: []string{"usampler3D"}, Return: "int"},
+			{Params: []string{"samplerCube"}, Return: "int"},
 		},
 	},
 
@@ -640,6 +936,29 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"vec3"}, Return: "vec3"},
 			{Params: []string{"vec4"}, Return: "vec4"},
 		},
+		Stages: PixelStages,
+	},
+	"dFdxCoarse": {
+		Name:        "dFdxCoarse",
+		Description: "Returns the derivative in x using local differencing, computed once per group of fragments",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
+	},
+	"dFdxFine": {
+		Name:        "dFdxFine",
+		Description: "Returns the derivative in x using local differencing, computed for each fragment",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
 	},
 	"dFdy": {
 		Name:        "dFdy",
@@ -650,6 +969,29 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"vec3"}, Return: "vec3"},
 			{Params: []string{"vec4"}, Return: "vec4"},
 		},
+		Stages: PixelStages,
+	},
+	"dFdyCoarse": {
+		Name:        "dFdyCoarse",
+		Description: "Returns the derivative in y using local differencing, computed once per group of fragments",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
+	},
+	"dFdyFine": {
+		Name:        "dFdyFine",
+		Description: "Returns the derivative in y using local differencing, computed for each fragment",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
 	},
 	"fwidth": {
 		Name:        "fwidth",
@@ -660,6 +1002,29 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"vec3"}, Return: "vec3"},
 			{Params: []string{"vec4"}, Return: "vec4"},
 		},
+		Stages: PixelStages,
+	},
+	"fwidthCoarse": {
+		Name:        "fwidthCoarse",
+		Description: "Returns the sum of the absolute coarse derivatives in x and y",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
+	},
+	"fwidthFine": {
+		Name:        "fwidthFine",
+		Description: "Returns the sum of the absolute fine derivatives in x and y",
+		Signatures: []FunctionSig{
+			{Params: []string{"float"}, Return: "float"},
+			{Params: []string{"vec2"}, Return: "vec2"},
+			{Params: []string{"vec3"}, Return: "vec3"},
+			{Params: []string{"vec4"}, Return: "vec4"},
+		},
+		Stages: PixelStages,
 	},
 
 	// Boolean functions
@@ -673,6 +1038,9 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
 			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
 			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
 		},
 	},
 	"greaterThan": {
@@ -685,6 +1053,39 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
 			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
 			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
+		},
+	},
+	"lessThanEqual": {
+		Name:        "lessThanEqual",
+		Description: "Component-wise less-than-or-equal comparison",
+		Signatures: []FunctionSig{
+			{Params: []string{"vec2", "vec2"}, Return: "bvec2"},
+			{Params: []string{"vec3", "vec3"}, Return: "bvec3"},
+			{Params: []string{"vec4", "vec4"}, Return: "bvec4"},
+			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
+			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
+			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
+		},
+	},
+	"greaterThanEqual": {
+		Name:        "greaterThanEqual",
+		Description: "Component-wise greater-than-or-equal comparison",
+		Signatures: []FunctionSig{
+			{Params: []string{"vec2", "vec2"}, Return: "bvec2"},
+			{Params: []string{"vec3", "vec3"}, Return: "bvec3"},
+			{Params: []string{"vec4", "vec4"}, Return: "bvec4"},
+			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
+			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
+			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
 		},
 	},
 	"equal": {
@@ -697,6 +1098,9 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
 			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
 			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
 			{Params: []string{"bvec2", "bvec2"}, Return: "bvec2"},
 			{Params: []string{"bvec3", "bvec3"}, Return: "bvec3"},
 			{Params: []string{"bvec4", "bvec4"}, Return: "bvec4"},
@@ -712,6 +1116,9 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"ivec2", "ivec2"}, Return: "bvec2"},
 			{Params: []string{"ivec3", "ivec3"}, Return: "bvec3"},
 			{Params: []string{"ivec4", "ivec4"}, Return: "bvec4"},
+			{Params: []string{"uvec2", "uvec2"}, Return: "bvec2"},
+			{Params: []string{"uvec3", "uvec3"}, Return: "bvec3"},
+			{Params: []string{"uvec4", "uvec4"}, Return: "bvec4"},
 			{Params: []string{"bvec2", "bvec2"}, Return: "bvec2"},
 			{Params: []string{"bvec3", "bvec3"}, Return: "bvec3"},
 			{Params: []string{"bvec4", "bvec4"}, Return: "bvec4"},
@@ -786,6 +1193,130 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 			{Params: []string{"uvec4"}, Return: "vec4"},
 		},
 	},
+	"bitfieldExtract": {
+		Name:        "bitfieldExtract",
+		Description: "Extracts bits [offset, offset + bits) of value, sign-extended for signed types",
+		Signatures: []FunctionSig{
+			{Params: []string{"int", "int", "int"}, Return: "int"},
+			{Params: []string{"ivec2", "int", "int"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "int", "int"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "int", "int"}, Return: "ivec4"},
+			{Params: []string{"uint", "int", "int"}, Return: "uint"},
+			{Params: []string{"uvec2", "int", "int"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "int", "int"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "int", "int"}, Return: "uvec4"},
+		},
+	},
+	"bitfieldInsert": {
+		Name:        "bitfieldInsert",
+		Description: "Replaces bits [offset, offset + bits) of base with the low bits of insert",
+		Signatures: []FunctionSig{
+			{Params: []string{"int", "int", "int", "int"}, Return: "int"},
+			{Params: []string{"ivec2", "ivec2", "int", "int"}, Return: "ivec2"},
+			{Params: []string{"ivec3", "ivec3", "int", "int"}, Return: "ivec3"},
+			{Params: []string{"ivec4", "ivec4", "int", "int"}, Return: "ivec4"},
+			{Params: []string{"uint", "uint", "int", "int"}, Return: "uint"},
+			{Params: []string{"uvec2", "uvec2", "int", "int"}, Return: "uvec2"},
+			{Params: []string{"uvec3", "uvec3", "int", "int"}, Return: "uvec3"},
+			{Params: []string{"uvec4", "uvec4", "int", "int"}, Return: "uvec4"},
+		},
+	},
+	"bitfieldReverse": {
+		Name:        "bitfieldReverse",
+		Description: "Reverses the order of the bits of value",
+		Signatures: []FunctionSig{
+			{Params: []string{"int"}, Return: "int"},
+			{Params: []string{"ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4"}, Return: "ivec4"},
+			{Params: []string{"uint"}, Return: "uint"},
+			{Params: []string{"uvec2"}, Return: "uvec2"},
+			{Params: []string{"uvec3"}, Return: "uvec3"},
+			{Params: []string{"uvec4"}, Return: "uvec4"},
+		},
+	},
+	"bitCount": {
+		Name:        "bitCount",
+		Description: "Returns the number of bits set in value",
+		Signatures: []FunctionSig{
+			{Params: []string{"int"}, Return: "int"},
+			{Params: []string{"ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4"}, Return: "ivec4"},
+			{Params: []string{"uint"}, Return: "int"},
+			{Params: []string{"uvec2"}, Return: "ivec2"},
+			{Params: []string{"uvec3"}, Return: "ivec3"},
+			{Params: []string{"uvec4"}, Return: "ivec4"},
+		},
+	},
+	"findLSB": {
+		Name:        "findLSB",
+		Description: "Returns the index of the least significant bit set, or -1 when none is",
+		Signatures: []FunctionSig{
+			{Params: []string{"int"}, Return: "int"},
+			{Params: []string{"ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4"}, Return: "ivec4"},
+			{Params: []string{"uint"}, Return: "int"},
+			{Params: []string{"uvec2"}, Return: "ivec2"},
+			{Params: []string{"uvec3"}, Return: "ivec3"},
+			{Params: []string{"uvec4"}, Return: "ivec4"},
+		},
+	},
+	"findMSB": {
+		Name:        "findMSB",
+		Description: "Returns the index of the most significant bit set, or -1 when none is; for negative signed values, of the most significant bit cleared",
+		Signatures: []FunctionSig{
+			{Params: []string{"int"}, Return: "int"},
+			{Params: []string{"ivec2"}, Return: "ivec2"},
+			{Params: []string{"ivec3"}, Return: "ivec3"},
+			{Params: []string{"ivec4"}, Return: "ivec4"},
+			{Params: []string{"uint"}, Return: "int"},
+			{Params: []string{"uvec2"}, Return: "ivec2"},
+			{Params: []string{"uvec3"}, Return: "ivec3"},
+			{Params: []string{"uvec4"}, Return: "ivec4"},
+		},
+	},
+	"uaddCarry": {
+		Name:        "uaddCarry",
+		Description: "Adds x and y, returning the sum modulo 2^32 and storing the carry in carry",
+		Signatures: []FunctionSig{
+			{Params: []string{"uint", "uint", "uint"}, Return: "uint", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec2", "uvec2", "uvec2"}, Return: "uvec2", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec3", "uvec3", "uvec3"}, Return: "uvec3", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec4", "uvec4", "uvec4"}, Return: "uvec4", Qualifiers: []string{"", "", "out"}},
+		},
+	},
+	"usubBorrow": {
+		Name:        "usubBorrow",
+		Description: "Subtracts y from x, returning the difference modulo 2^32 and storing the borrow in borrow",
+		Signatures: []FunctionSig{
+			{Params: []string{"uint", "uint", "uint"}, Return: "uint", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec2", "uvec2", "uvec2"}, Return: "uvec2", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec3", "uvec3", "uvec3"}, Return: "uvec3", Qualifiers: []string{"", "", "out"}},
+			{Params: []string{"uvec4", "uvec4", "uvec4"}, Return: "uvec4", Qualifiers: []string{"", "", "out"}},
+		},
+	},
+	"umulExtended": {
+		Name:        "umulExtended",
+		Description: "Multiplies x and y, storing the high 32 bits of the product in msb and the low ones in lsb",
+		Signatures: []FunctionSig{
+			{Params: []string{"uint", "uint", "uint", "uint"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"uvec2", "uvec2", "uvec2", "uvec2"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"uvec3", "uvec3", "uvec3", "uvec3"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"uvec4", "uvec4", "uvec4", "uvec4"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+		},
+	},
+	"imulExtended": {
+		Name:        "imulExtended",
+		Description: "Multiplies x and y, storing the high 32 bits of the product in msb and the low ones in lsb",
+		Signatures: []FunctionSig{
+			{Params: []string{"int", "int", "int", "int"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"ivec2", "ivec2", "ivec2", "ivec2"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"ivec3", "ivec3", "ivec3", "ivec3"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+			{Params: []string{"ivec4", "ivec4", "ivec4", "ivec4"}, Return: "void", Qualifiers: []string{"", "", "out", "out"}},
+		},
+	},
 
 	// Pack/unpack functions
 	"packHalf2x16": {
@@ -818,6 +1349,26 @@ var BuiltinFunctions = map[string]*BuiltinFunction{
 		Description: "Unpacks a uint into two signed normalized floats",
 		Signatures:  []FunctionSig{{Params: []string{"uint"}, Return: "vec2"}},
 	},
+	"packUnorm4x8": {
+		Name:        "packUnorm4x8",
+		Description: "Packs a vec4 of values in [0, 1] into four 8-bit unsigned normalized integers",
+		Signatures:  []FunctionSig{{Params: []string{"vec4"}, Return: "uint"}},
+	},
+	"unpackUnorm4x8": {
+		Name:        "unpackUnorm4x8",
+		Description: "Unpacks four 8-bit unsigned normalized integers into a vec4",
+		Signatures:  []FunctionSig{{Params: []string{"uint"}, Return: "vec4"}},
+	},
+	"packSnorm4x8": {
+		Name:        "packSnorm4x8",
+		Description: "Packs a vec4 of values in [-1, 1] into four 8-bit signed normalized integers",
+		Signatures:  []FunctionSig{{Params: []string{"vec4"}, Return: "uint"}},
+	},
+	"unpackSnorm4x8": {
+		Name:        "unpackSnorm4x8",
+		Description: "Unpacks four 8-bit signed normalized integers into a vec4",
+		Signatures:  []FunctionSig{{Params: []string{"uint"}, Return: "vec4"}},
+	},
 }
 
 // BuiltinConstants contains all built-in constants.
@@ -857,6 +1408,12 @@ func GetSpatialVertexBuiltins() map[string]*BuiltinVariable {
 		"INSTANCE_ID":     {Name: "INSTANCE_ID", Type: "int", Description: "Instance ID for instanced rendering", Stage: "vertex", ReadWrite: "in"},
 		"VERTEX_ID":       {Name: "VERTEX_ID", Type: "int", Description: "Vertex ID", Stage: "vertex", ReadWrite: "in"},
 		"INSTANCE_CUSTOM": {Name: "INSTANCE_CUSTOM", Type: "vec4", Description: "Instance custom data", Stage: "vertex", ReadWrite: "in"},
+		"BONE_INDICES":    {Name: "BONE_INDICES", Type: "uvec4", Description: "Indices of the bones influencing the vertex", Stage: "vertex", ReadWrite: "in"},
+		"BONE_WEIGHTS":    {Name: "BONE_WEIGHTS", Type: "vec4", Description: "Weights of the bones influencing the vertex", Stage: "vertex", ReadWrite: "in"},
+		"CUSTOM0":         {Name: "CUSTOM0", Type: "vec4", Description: "Custom vertex data 0", Stage: "vertex", ReadWrite: "in"},
+		"CUSTOM1":         {Name: "CUSTOM1", Type: "vec4", Description: "Custom vertex data 1", Stage: "vertex", ReadWrite: "in"},
+		"CUSTOM2":         {Name: "CUSTOM2", Type: "vec4", Description: "Custom vertex data 2", Stage: "vertex", ReadWrite: "in"},
+		"CUSTOM3":         {Name: "CUSTOM3", Type: "vec4", Description: "Custom vertex data 3", Stage: "vertex", ReadWrite: "in"},
 		// Matrices
 		"MODEL_MATRIX":            {Name: "MODEL_MATRIX", Type: "mat4", Description: "Model matrix (world transform)", Stage: "vertex", ReadWrite: "in"},
 		"MODEL_NORMAL_MATRIX":     {Name: "MODEL_NORMAL_MATRIX", Type: "mat3", Description: "Normal matrix", Stage: "vertex", ReadWrite: "in"},
@@ -864,17 +1421,23 @@ func GetSpatialVertexBuiltins() map[string]*BuiltinVariable {
 		"INV_VIEW_MATRIX":         {Name: "INV_VIEW_MATRIX", Type: "mat4", Description: "Inverse view matrix", Stage: "vertex", ReadWrite: "in"},
 		"PROJECTION_MATRIX":       {Name: "PROJECTION_MATRIX", Type: "mat4", Description: "Projection matrix", Stage: "vertex", ReadWrite: "inout"},
 		"INV_PROJECTION_MATRIX":   {Name: "INV_PROJECTION_MATRIX", Type: "mat4", Description: "Inverse projection matrix", Stage: "vertex", ReadWrite: "in"},
-		"MODELVIEW_MATRIX":        {Name: "MODELVIEW_MATRIX", Type: "mat4", Description: "Model-view matrix", Stage: "vertex", ReadWrite: "in"},
-		"MODELVIEW_NORMAL_MATRIX": {Name: "MODELVIEW_NORMAL_MATRIX", Type: "mat3", Description: "Model-view normal matrix", Stage: "vertex", ReadWrite: "in"},
+		"MODELVIEW_MATRIX":        {Name: "MODELVIEW_MATRIX", Type: "mat4", Description: "Model-view matrix", Stage: "vertex", ReadWrite: "inout"},
+		"MODELVIEW_NORMAL_MATRIX": {Name: "MODELVIEW_NORMAL_MATRIX", Type: "mat3", Description: "Model-view normal matrix", Stage: "vertex", ReadWrite: "inout"},
 		// Camera
 		"VIEWPORT_SIZE":          {Name: "VIEWPORT_SIZE", Type: "vec2", Description: "Viewport size in pixels", Stage: "vertex", ReadWrite: "in"},
 		"OUTPUT_IS_SRGB":         {Name: "OUTPUT_IS_SRGB", Type: "bool", Description: "True if output is sRGB", Stage: "vertex", ReadWrite: "in"},
 		"NODE_POSITION_WORLD":    {Name: "NODE_POSITION_WORLD", Type: "vec3", Description: "Node position in world space", Stage: "vertex", ReadWrite: "in"},
+		"NODE_POSITION_VIEW":     {Name: "NODE_POSITION_VIEW", Type: "vec3", Description: "Node position in view space", Stage: "vertex", ReadWrite: "in"},
 		"CAMERA_POSITION_WORLD":  {Name: "CAMERA_POSITION_WORLD", Type: "vec3", Description: "Camera position in world space", Stage: "vertex", ReadWrite: "in"},
 		"CAMERA_DIRECTION_WORLD": {Name: "CAMERA_DIRECTION_WORLD", Type: "vec3", Description: "Camera direction in world space", Stage: "vertex", ReadWrite: "in"},
 		"CAMERA_VISIBLE_LAYERS":  {Name: "CAMERA_VISIBLE_LAYERS", Type: "uint", Description: "Camera visible layers bitmask", Stage: "vertex", ReadWrite: "in"},
+		"VIEW_INDEX":             {Name: "VIEW_INDEX", Type: "int", Description: "View being rendered, VIEW_MONO_LEFT or VIEW_RIGHT", Stage: "vertex", ReadWrite: "in"},
+		"VIEW_MONO_LEFT":         {Name: "VIEW_MONO_LEFT", Type: "int", Description: "View index of mono cameras and of the left eye", Stage: "vertex", ReadWrite: "in"},
+		"VIEW_RIGHT":             {Name: "VIEW_RIGHT", Type: "int", Description: "View index of the right eye", Stage: "vertex", ReadWrite: "in"},
+		"EYE_OFFSET":             {Name: "EYE_OFFSET", Type: "vec3", Description: "Position offset of the eye being rendered, in view space", Stage: "vertex", ReadWrite: "in"},
 		// Outputs
-		"POSITION": {Name: "POSITION", Type: "vec4", Description: "Output position in clip space", Stage: "vertex", ReadWrite: "out"},
+		"POSITION":  {Name: "POSITION", Type: "vec4", Description: "Output position in clip space", Stage: "vertex", ReadWrite: "out"},
+		"ROUGHNESS": {Name: "ROUGHNESS", Type: "float", Description: "Roughness for vertex lighting", Stage: "vertex", ReadWrite: "out"},
 		// Time
 		"TIME": {Name: "TIME", Type: "float", Description: "Time since start", Stage: "vertex", ReadWrite: "in"},
 	}
@@ -888,11 +1451,13 @@ func GetSpatialFragmentBuiltins() map[string]*BuiltinVariable {
 		"FRAGCOORD":    {Name: "FRAGCOORD", Type: "vec4", Description: "Fragment coordinates", Stage: "fragment", ReadWrite: "in"},
 		"FRONT_FACING": {Name: "FRONT_FACING", Type: "bool", Description: "True if front face", Stage: "fragment", ReadWrite: "in"},
 		"NORMAL":       {Name: "NORMAL", Type: "vec3", Description: "Normal in view space", Stage: "fragment", ReadWrite: "inout"},
-		"TANGENT":      {Name: "TANGENT", Type: "vec3", Description: "Tangent in view space", Stage: "fragment", ReadWrite: "in"},
-		"BINORMAL":     {Name: "BINORMAL", Type: "vec3", Description: "Binormal in view space", Stage: "fragment", ReadWrite: "in"},
+		"TANGENT":      {Name: "TANGENT", Type: "vec3", Description: "Tangent in view space", Stage: "fragment", ReadWrite: "inout"},
+		"BINORMAL":     {Name: "BINORMAL", Type: "vec3", Description: "Binormal in view space", Stage: "fragment", ReadWrite: "inout"},
 		"UV":           {Name: "UV", Type: "vec2", Description: "Primary UV coordinates", Stage: "fragment", ReadWrite: "in"},
 		"UV2":          {Name: "UV2", Type: "vec2", Description: "Secondary UV coordinates", Stage: "fragment", ReadWrite: "in"},
 		"COLOR":        {Name: "COLOR", Type: "vec4", Description: "Vertex color", Stage: "fragment", ReadWrite: "in"},
+		"POINT_COORD":  {Name: "POINT_COORD", Type: "vec2", Description: "Point coordinates when drawing points", Stage: "fragment", ReadWrite: "in"},
+		"LIGHT_VERTEX": {Name: "LIGHT_VERTEX", Type: "vec3", Description: "Position used for lighting, VERTEX by default", Stage: "fragment", ReadWrite: "inout", Since: Version{4, 1}},
 		// PBR outputs
 		"ALBEDO":                   {Name: "ALBEDO", Type: "vec3", Description: "Albedo color", Stage: "fragment", ReadWrite: "out"},
 		"ALPHA":                    {Name: "ALPHA", Type: "float", Description: "Alpha value", Stage: "fragment", ReadWrite: "out"},
@@ -919,7 +1484,10 @@ func GetSpatialFragmentBuiltins() map[string]*BuiltinVariable {
 		"ALPHA_HASH_SCALE":         {Name: "ALPHA_HASH_SCALE", Type: "float", Description: "Alpha hash scale", Stage: "fragment", ReadWrite: "out"},
 		"ALPHA_ANTIALIASING_EDGE":  {Name: "ALPHA_ANTIALIASING_EDGE", Type: "float", Description: "Alpha antialiasing edge", Stage: "fragment", ReadWrite: "out"},
 		"ALPHA_TEXTURE_COORDINATE": {Name: "ALPHA_TEXTURE_COORDINATE", Type: "vec2", Description: "Alpha texture coordinate", Stage: "fragment", ReadWrite: "out"},
+		"PREMUL_ALPHA_FACTOR":      {Name: "PREMUL_ALPHA_FACTOR", Type: "float", Description: "Factor the albedo is multiplied by with premultiplied alpha blending", Stage: "fragment", ReadWrite: "out"},
 		"FOG":                      {Name: "FOG", Type: "vec4", Description: "Fog color and density", Stage: "fragment", ReadWrite: "out"},
+		"RADIANCE":                 {Name: "RADIANCE", Type: "vec4", Description: "Radiance of the environment, replacing the sky reflection", Stage: "fragment", ReadWrite: "out"},
+		"IRRADIANCE":               {Name: "IRRADIANCE", Type: "vec4", Description: "Irradiance of the environment, replacing the ambient light", Stage: "fragment", ReadWrite: "out"},
 		// Matrices
 		"MODEL_MATRIX":          {Name: "MODEL_MATRIX", Type: "mat4", Description: "Model matrix", Stage: "fragment", ReadWrite: "in"},
 		"MODEL_NORMAL_MATRIX":   {Name: "MODEL_NORMAL_MATRIX", Type: "mat3", Description: "Model normal matrix", Stage: "fragment", ReadWrite: "in"},
@@ -930,10 +1498,16 @@ func GetSpatialFragmentBuiltins() map[string]*BuiltinVariable {
 		// Camera
 		"VIEWPORT_SIZE":          {Name: "VIEWPORT_SIZE", Type: "vec2", Description: "Viewport size", Stage: "fragment", ReadWrite: "in"},
 		"NODE_POSITION_WORLD":    {Name: "NODE_POSITION_WORLD", Type: "vec3", Description: "Node world position", Stage: "fragment", ReadWrite: "in"},
+		"NODE_POSITION_VIEW":     {Name: "NODE_POSITION_VIEW", Type: "vec3", Description: "Node view position", Stage: "fragment", ReadWrite: "in"},
 		"CAMERA_POSITION_WORLD":  {Name: "CAMERA_POSITION_WORLD", Type: "vec3", Description: "Camera world position", Stage: "fragment", ReadWrite: "in"},
 		"CAMERA_DIRECTION_WORLD": {Name: "CAMERA_DIRECTION_WORLD", Type: "vec3", Description: "Camera world direction", Stage: "fragment", ReadWrite: "in"},
 		"CAMERA_VISIBLE_LAYERS":  {Name: "CAMERA_VISIBLE_LAYERS", Type: "uint", Description: "Camera visible layers", Stage: "fragment", ReadWrite: "in"},
 		"VIEW":                   {Name: "VIEW", Type: "vec3", Description: "View direction", Stage: "fragment", ReadWrite: "in"},
+		"OUTPUT_IS_SRGB":         {Name: "OUTPUT_IS_SRGB", Type: "bool", Description: "True if output is sRGB", Stage: "fragment", ReadWrite: "in"},
+		"VIEW_INDEX":             {Name: "VIEW_INDEX", Type: "int", Description: "View being rendered, VIEW_MONO_LEFT or VIEW_RIGHT", Stage: "fragment", ReadWrite: "in"},
+		"VIEW_MONO_LEFT":         {Name: "VIEW_MONO_LEFT", Type: "int", Description: "View index of mono cameras and of the left eye", Stage: "fragment", ReadWrite: "in"},
+		"VIEW_RIGHT":             {Name: "VIEW_RIGHT", Type: "int", Description: "View index of the right eye", Stage: "fragment", ReadWrite: "in"},
+		"EYE_OFFSET":             {Name: "EYE_OFFSET", Type: "vec3", Description: "Position offset of the eye being rendered, in view space", Stage: "fragment", ReadWrite: "in"},
 		// Time
 		"TIME": {Name: "TIME", Type: "float", Description: "Time since start", Stage: "fragment", ReadWrite: "in"},
 		// Screen
@@ -963,6 +1537,19 @@ func GetSpatialLightBuiltins() map[string]*BuiltinVariable {
 		// View info
 		"VIEW":   {Name: "VIEW", Type: "vec3", Description: "View direction", Stage: "light", ReadWrite: "in"},
 		"NORMAL": {Name: "NORMAL", Type: "vec3", Description: "Normal in view space", Stage: "light", ReadWrite: "in"},
+		// Surface and camera
+		"FRAGCOORD":             {Name: "FRAGCOORD", Type: "vec4", Description: "Fragment coordinates", Stage: "light", ReadWrite: "in"},
+		"UV":                    {Name: "UV", Type: "vec2", Description: "Primary UV coordinates", Stage: "light", ReadWrite: "in"},
+		"UV2":                   {Name: "UV2", Type: "vec2", Description: "Secondary UV coordinates", Stage: "light", ReadWrite: "in"},
+		"SCREEN_UV":             {Name: "SCREEN_UV", Type: "vec2", Description: "Screen UV coordinates", Stage: "light", ReadWrite: "in"},
+		"VIEWPORT_SIZE":         {Name: "VIEWPORT_SIZE", Type: "vec2", Description: "Viewport size", Stage: "light", ReadWrite: "in"},
+		"MODEL_MATRIX":          {Name: "MODEL_MATRIX", Type: "mat4", Description: "Model matrix", Stage: "light", ReadWrite: "in"},
+		"VIEW_MATRIX":           {Name: "VIEW_MATRIX", Type: "mat4", Description: "View matrix", Stage: "light", ReadWrite: "in"},
+		"INV_VIEW_MATRIX":       {Name: "INV_VIEW_MATRIX", Type: "mat4", Description: "Inverse view matrix", Stage: "light", ReadWrite: "in"},
+		"PROJECTION_MATRIX":     {Name: "PROJECTION_MATRIX", Type: "mat4", Description: "Projection matrix", Stage: "light", ReadWrite: "in"},
+		"INV_PROJECTION_MATRIX": {Name: "INV_PROJECTION_MATRIX", Type: "mat4", Description: "Inverse projection matrix", Stage: "light", ReadWrite: "in"},
+		"OUTPUT_IS_SRGB":        {Name: "OUTPUT_IS_SRGB", Type: "bool", Description: "True if output is sRGB", Stage: "light", ReadWrite: "in"},
+		"TIME":                  {Name: "TIME", Type: "float", Description: "Time since start", Stage: "light", ReadWrite: "in"},
 		// Outputs
 		"DIFFUSE_LIGHT":  {Name: "DIFFUSE_LIGHT", Type: "vec3", Description: "Diffuse light output", Stage: "light", ReadWrite: "out"},
 		"SPECULAR_LIGHT": {Name: "SPECULAR_LIGHT", Type: "vec3", Description: "Specular light output", Stage: "light", ReadWrite: "out"},
@@ -1034,26 +1621,63 @@ func GetCanvasItemLightBuiltins() map[string]*BuiltinVariable {
 	}
 }
 
-// GetParticlesBuiltins returns built-in variables for particles shader.
-func GetParticlesBuiltins() map[string]*BuiltinVariable {
-	return map[string]*BuiltinVariable{
-		"COLOR":              {Name: "COLOR", Type: "vec4", Description: "Particle color", Stage: "start", ReadWrite: "inout"},
-		"VELOCITY":           {Name: "VELOCITY", Type: "vec3", Description: "Particle velocity", Stage: "start", ReadWrite: "inout"},
-		"MASS":               {Name: "MASS", Type: "float", Description: "Particle mass", Stage: "start", ReadWrite: "inout"},
-		"ACTIVE":             {Name: "ACTIVE", Type: "bool", Description: "Is particle active", Stage: "start", ReadWrite: "inout"},
-		"RESTART":            {Name: "RESTART", Type: "bool", Description: "Restart flag", Stage: "start", ReadWrite: "in"},
-		"CUSTOM":             {Name: "CUSTOM", Type: "vec4", Description: "Custom data", Stage: "start", ReadWrite: "inout"},
-		"TRANSFORM":          {Name: "TRANSFORM", Type: "mat4", Description: "Particle transform", Stage: "start", ReadWrite: "inout"},
-		"LIFETIME":           {Name: "LIFETIME", Type: "float", Description: "Particle lifetime", Stage: "start", ReadWrite: "in"},
-		"DELTA":              {Name: "DELTA", Type: "float", Description: "Delta time", Stage: "start", ReadWrite: "in"},
-		"NUMBER":             {Name: "NUMBER", Type: "uint", Description: "Particle number", Stage: "start", ReadWrite: "in"},
-		"INDEX":              {Name: "INDEX", Type: "int", Description: "Particle index", Stage: "start", ReadWrite: "in"},
-		"EMISSION_TRANSFORM": {Name: "EMISSION_TRANSFORM", Type: "mat4", Description: "Emission transform", Stage: "start", ReadWrite: "in"},
-		"RANDOM_SEED":        {Name: "RANDOM_SEED", Type: "uint", Description: "Random seed", Stage: "start", ReadWrite: "in"},
-		"TIME":               {Name: "TIME", Type: "float", Description: "Time", Stage: "start", ReadWrite: "in"},
-		"INTERPOLATE_TO_END": {Name: "INTERPOLATE_TO_END", Type: "float", Description: "Interpolation to end", Stage: "start", ReadWrite: "in"},
-		"AMOUNT_RATIO":       {Name: "AMOUNT_RATIO", Type: "float", Description: "Amount ratio", Stage: "start", ReadWrite: "in"},
+// GetParticlesStartBuiltins returns built-in variables for the start
+// function of particles shaders, run when a particle is emitted.
+func GetParticlesStartBuiltins() map[string]*BuiltinVariable {
+	builtins := particlesBuiltins("start")
+	for name, description := range map[string]string{
+		"RESTART_POSITION":  "True if the particle is restarted, or emitted without a custom position",
+		"RESTART_ROT_SCALE": "True if the particle is restarted, or emitted without a custom rotation or scale",
+		"RESTART_VELOCITY":  "True if the particle is restarted, or emitted without a custom velocity",
+		"RESTART_COLOR":     "True if the particle is restarted, or emitted without a custom color",
+		"RESTART_CUSTOM":    "True if the particle is restarted, or emitted without a custom property",
+	} {
+		builtins[name] = &BuiltinVariable{Name: name, Type: "bool", Description: description, Stage: "start", ReadWrite: "in"}
 	}
+	return builtins
+}
+
+// GetParticlesProcessBuiltins returns built-in variables for the process
+// function of particles shaders, run every frame for each particle.
+func GetParticlesProcessBuiltins() map[string]*BuiltinVariable {
+	builtins := particlesBuiltins("process")
+	for _, v := range []*BuiltinVariable{
+		{Name: "RESTART", Type: "bool", Description: "True in the first process frame of the particle"},
+		{Name: "COLLIDED", Type: "bool", Description: "True if the particle collided with a particle collider"},
+		{Name: "COLLISION_NORMAL", Type: "vec3", Description: "Normal of the last collision, or vec3(0.0) without one"},
+		{Name: "COLLISION_DEPTH", Type: "float", Description: "Length of the normal of the last collision"},
+		{Name: "ATTRACTOR_FORCE", Type: "vec3", Description: "Combined force of the particle attractors at the particle"},
+	} {
+		v.Stage, v.ReadWrite = "process", "in"
+		builtins[v.Name] = v
+	}
+	return builtins
+}
+
+// particlesBuiltins returns the built-in variables shared by the start and
+// process functions of particles shaders.
+func particlesBuiltins(stage string) map[string]*BuiltinVariable {
+	builtins := map[string]*BuiltinVariable{
+		"COLOR":              {Name: "COLOR", Type: "vec4", Description: "Particle color", ReadWrite: "inout"},
+		"VELOCITY":           {Name: "VELOCITY", Type: "vec3", Description: "Particle velocity", ReadWrite: "inout"},
+		"MASS":               {Name: "MASS", Type: "float", Description: "Particle mass", ReadWrite: "inout"},
+		"ACTIVE":             {Name: "ACTIVE", Type: "bool", Description: "Is particle active", ReadWrite: "inout"},
+		"CUSTOM":             {Name: "CUSTOM", Type: "vec4", Description: "Custom data", ReadWrite: "inout"},
+		"TRANSFORM":          {Name: "TRANSFORM", Type: "mat4", Description: "Particle transform", ReadWrite: "inout"},
+		"LIFETIME":           {Name: "LIFETIME", Type: "float", Description: "Particle lifetime", ReadWrite: "in"},
+		"DELTA":              {Name: "DELTA", Type: "float", Description: "Delta time", ReadWrite: "in"},
+		"NUMBER":             {Name: "NUMBER", Type: "uint", Description: "Particle number", ReadWrite: "in"},
+		"INDEX":              {Name: "INDEX", Type: "uint", Description: "Particle index", ReadWrite: "in"},
+		"EMISSION_TRANSFORM": {Name: "EMISSION_TRANSFORM", Type: "mat4", Description: "Emission transform", ReadWrite: "in"},
+		"RANDOM_SEED":        {Name: "RANDOM_SEED", Type: "uint", Description: "Random seed", ReadWrite: "in"},
+		"TIME":               {Name: "TIME", Type: "float", Description: "Time", ReadWrite: "in"},
+		"INTERPOLATE_TO_END": {Name: "INTERPOLATE_TO_END", Type: "float", Description: "Interpolation to end", ReadWrite: "in"},
+		"AMOUNT_RATIO":       {Name: "AMOUNT_RATIO", Type: "float", Description: "Amount ratio", ReadWrite: "in", Since: Version{4, 2}},
+	}
+	for _, v := range builtins {
+		v.Stage = stage
+	}
+	return builtins
 }
 
 // GetSkyBuiltins returns built-in variables for sky shader.
@@ -1110,45 +1734,150 @@ func GetFogBuiltins() map[string]*BuiltinVariable {
 	}
 }
 
-// GetBuiltinsForShaderType returns all built-in variables for a given shader type.
-func GetBuiltinsForShaderType(shaderType string) map[string]*BuiltinVariable {
-	result := make(map[string]*BuiltinVariable)
+// RemovedBuiltin is a built-in variable of Godot 3 that Godot 4 removed,
+// either renamed or replaced by a uniform with a hint.
+type RemovedBuiltin struct {
+	Replacement string // Built-in replacing it, or "" when a uniform does
+	Uniform     string // Name of the sampler2D uniform to declare instead
+	Hint        string // Hint of that uniform
+}
 
-	switch shaderType {
-	case "spatial":
-		for k, v := range GetSpatialVertexBuiltins() {
-			result[k] = v
+// Declaration returns the declaration of the uniform replacing a removed
+// texture built-in.
+func (r RemovedBuiltin) Declaration() string {
+	return "uniform sampler2D " + r.Uniform + " : " + r.Hint + ";"
+}
+
+// RemovedBuiltins are the built-in variables removed in Godot 4, which
+// shaders written for Godot 3 still use.
+var RemovedBuiltins = map[string]RemovedBuiltin{
+	"SCREEN_TEXTURE":           {Uniform: "screen_texture", Hint: "hint_screen_texture"},
+	"DEPTH_TEXTURE":            {Uniform: "depth_texture", Hint: "hint_depth_texture"},
+	"NORMAL_ROUGHNESS_TEXTURE": {Uniform: "normal_roughness_texture", Hint: "hint_normal_roughness_texture"},
+	"WORLD_MATRIX":             {Replacement: "MODEL_MATRIX"},
+	"WORLD_NORMAL_MATRIX":      {Replacement: "MODEL_NORMAL_MATRIX"},
+	"CAMERA_MATRIX":            {Replacement: "INV_VIEW_MATRIX"},
+	"INV_CAMERA_MATRIX":        {Replacement: "VIEW_MATRIX"},
+	"NORMALMAP":                {Replacement: "NORMAL_MAP"},
+	"NORMALMAP_DEPTH":          {Replacement: "NORMAL_MAP_DEPTH"},
+	"TRANSMISSION":             {Replacement: "BACKLIGHT"},
+	"ALPHA_SCISSOR":            {Replacement: "ALPHA_SCISSOR_THRESHOLD"},
+}
+
+// JoinStages joins the names of processor functions for messages, as in
+// "fragment, light and sky".
+func JoinStages(stages []string) string {
+	if len(stages) < 2 {
+		return strings.Join(stages, "")
+	}
+	return strings.Join(stages[:len(stages)-1], ", ") + " and " + stages[len(stages)-1]
+}
+
+// ShaderStages lists the processor functions of each shader type, in the
+// order the engine runs them.
+var ShaderStages = map[ShaderType][]string{
+	ShaderTypeSpatial:    {"vertex", "fragment", "light"},
+	ShaderTypeCanvasItem: {"vertex", "fragment", "light"},
+	ShaderTypeParticles:  {"start", "process"},
+	ShaderTypeSky:        {"sky"},
+	ShaderTypeFog:        {"fog"},
+}
+
+// GuessShaderType returns the shader type a document without shader_type
+// most likely has: the one with the most of its processor functions and
+// built-in variables used, spatial when nothing tells them apart.
+func GuessShaderType(doc *ShaderDocument, version Version) ShaderType {
+	used := make(map[string]bool)
+	InspectDocument(doc, func(n Node) bool {
+		if ident, ok := n.(*IdentExpr); ok {
+			used[ident.Name] = true
 		}
-		for k, v := range GetSpatialFragmentBuiltins() {
-			result[k] = v
+		return true
+	})
+
+	best, bestScore := ShaderTypeSpatial, 0
+	for _, shaderType := range ShaderTypes {
+		score := 0
+		for _, fn := range doc.Functions {
+			if slices.Contains(ShaderStages[shaderType], fn.Name) {
+				score += 2 // Weighs more than a built-in shared by types
+			}
 		}
-		for k, v := range GetSpatialLightBuiltins() {
-			result[k] = v
+		for name := range GetBuiltinsForShaderType(version, string(shaderType)) {
+			if used[name] {
+				score++
+			}
 		}
-	case "canvas_item":
-		for k, v := range GetCanvasItemVertexBuiltins() {
-			result[k] = v
+		if score > bestScore {
+			best, bestScore = shaderType, score
 		}
-		for k, v := range GetCanvasItemFragmentBuiltins() {
-			result[k] = v
+	}
+	return best
+}
+
+// GetStageBuiltins returns the built-in variables available in a processor
+// function of a shader type in a Godot version, or nil for any other
+// function.
+func GetStageBuiltins(version Version, shaderType ShaderType, stage string) map[string]*BuiltinVariable {
+	builtins := stageBuiltins(shaderType, stage)
+	for name, builtin := range builtins {
+		if !version.available(builtin.Since) {
+			delete(builtins, name)
 		}
-		for k, v := range GetCanvasItemLightBuiltins() {
-			result[k] = v
+	}
+	return builtins
+}
+
+// stageBuiltins returns the built-in variables of a processor function in
+// every Godot version.
+func stageBuiltins(shaderType ShaderType, stage string) map[string]*BuiltinVariable {
+	switch shaderType {
+	case ShaderTypeSpatial:
+		switch stage {
+		case "vertex":
+			return GetSpatialVertexBuiltins()
+		case "fragment":
+			return GetSpatialFragmentBuiltins()
+		case "light":
+			return GetSpatialLightBuiltins()
 		}
-	case "particles":
-		for k, v := range GetParticlesBuiltins() {
-			result[k] = v
+	case ShaderTypeCanvasItem:
+		switch stage {
+		case "vertex":
+			return GetCanvasItemVertexBuiltins()
+		case "fragment":
+			return GetCanvasItemFragmentBuiltins()
+		case "light":
+			return GetCanvasItemLightBuiltins()
 		}
-	case "sky":
-		for k, v := range GetSkyBuiltins() {
-			result[k] = v
+	case ShaderTypeParticles:
+		switch stage {
+		case "start":
+			return GetParticlesStartBuiltins()
+		case "process":
+			return GetParticlesProcessBuiltins()
 		}
-	case "fog":
-		for k, v := range GetFogBuiltins() {
-			result[k] = v
+	case ShaderTypeSky:
+		if stage == "sky" {
+			return GetSkyBuiltins()
+		}
+	case ShaderTypeFog:
+		if stage == "fog" {
+			return GetFogBuiltins()
 		}
 	}
+	return nil
+}
 
+// GetBuiltinsForShaderType returns all built-in variables for a given
+// shader type in a Godot version.
+func GetBuiltinsForShaderType(version Version, shaderType string) map[string]*BuiltinVariable {
+	result := make(map[string]*BuiltinVariable)
+	for _, stage := range ShaderStages[ShaderType(shaderType)] {
+		for k, v := range GetStageBuiltins(version, ShaderType(shaderType), stage) {
+			result[k] = v
+		}
+	}
 	return result
 }
 
@@ -1179,4 +1908,11 @@ var UniformHints = map[string]string{
 	"repeat_enable":                     "Enable texture repeat",
 	"repeat_disable":                    "Disable texture repeat",
 	"hint_enum":                         "Display as dropdown: hint_enum(\"Option1\", \"Option2\", ...)",
+	"instance_index":                    "Index of an instance uniform: instance_index(index)",
+}
+
+// uniformHintSince holds the Godot version that added the uniform hints
+// newer than 4.0.
+var uniformHintSince = map[string]Version{
+	"hint_enum": {4, 4},
 }
@@ -0,0 +1,273 @@
+package gdshader
+
+import (
+	"strconv"
+	"strings"
+)
+
+// constValue is the value of a folded constant expression, an int or a
+// float.
+type constValue struct {
+	isFloat bool
+	i       int
+	f       float64
+}
+
+// float returns the value as a float.
+func (v constValue) float() float64 {
+	if v.isFloat {
+		return v.f
+	}
+	return float64(v.i)
+}
+
+// evaluateConstExpr evaluates a constant expression and returns its integer value.
+func (a *Analyzer) evaluateConstExpr(expr Expr) int {
+	if val, ok := a.constIntValue(expr); ok {
+		return val
+	}
+	return -1 // Unsized or error
+}
+
+// constIntValue folds an integer constant expression.
+func (a *Analyzer) constIntValue(expr Expr) (int, bool) {
+	val, ok := a.constValue(expr, 0)
+	if !ok || val.isFloat {
+		return 0, false
+	}
+	return val.i, true
+}
+
+// checkArraySize reports an array size that is not a positive integer
+// constant. Unsized arrays are checked where they are declared.
+func (a *Analyzer) checkArraySize(size Expr) {
+	if size == nil || !a.requireConstant(size, "array size") {
+		return
+	}
+	if val, ok := a.constIntValue(size); !ok || val <= 0 {
+		a.addError(size.GetRange(), "array size must be a positive integer constant")
+	}
+}
+
+// requireConstant reports the first part of expr that is not constant,
+// where what is the position that requires a constant.
+func (a *Analyzer) requireConstant(expr Expr, what string) bool {
+	if sub := a.nonConstantExpr(expr); sub != nil {
+		a.addError(sub.GetRange(), "%s must be a constant expression", what).Code = CodeNotConstant
+		return false
+	}
+	return true
+}
+
+// nonConstantExpr returns the first sub-expression of expr that is not a
+// compile-time constant, or nil when expr is constant. Constants are
+// literals, const variables, and operators, constructors and calls applied
+// to them. Unknown names are reported on their own and count as constant.
+func (a *Analyzer) nonConstantExpr(expr Expr) Expr {
+	switch e := expr.(type) {
+	case nil, *LiteralExpr:
+		return nil
+	case *IdentExpr:
+		if sym := a.currentScope.lookup(e.Name); sym != nil && !sym.Constant {
+			return e
+		}
+		return nil
+	case *UnaryExpr:
+		if !e.Prefix || e.Operator == "++" || e.Operator == "--" {
+			return e
+		}
+		return a.nonConstantExpr(e.Operand)
+	case *BinaryExpr:
+		if isAssignOperator(e.Operator) {
+			return e
+		}
+		return a.firstNonConstant(e.Left, e.Right)
+	case *TernaryExpr:
+		return a.firstNonConstant(e.Cond, e.Then, e.Else)
+	case *CallExpr:
+		return a.firstNonConstant(e.Args...)
+	case *ArrayExpr:
+		return a.firstNonConstant(e.Elements...)
+	case *IndexExpr:
+		return a.firstNonConstant(e.Expr, e.Index)
+	case *MemberExpr:
+		return a.nonConstantExpr(e.Expr)
+	}
+	return expr
+}
+
+// firstNonConstant returns the first non-constant part of exprs.
+func (a *Analyzer) firstNonConstant(exprs ...Expr) Expr {
+	for _, expr := range exprs {
+		if sub := a.nonConstantExpr(expr); sub != nil {
+			return sub
+		}
+	}
+	return nil
+}
+
+// isAssignOperator reports whether op assigns, as in = or +=.
+func isAssignOperator(op string) bool {
+	switch op {
+	case "==", "!=", "<=", ">=":
+		return false
+	}
+	return strings.HasSuffix(op, "=")
+}
+
+// constValue folds a constant expression built from number literals,
+// constants, conversions and arithmetic operators.
+func (a *Analyzer) constValue(expr Expr, depth int) (constValue, bool) {
+	if depth > 16 {
+		return constValue{}, false
+	}
+
+	switch e := expr.(type) {
+	case *LiteralExpr:
+		switch e.Kind {
+		case "int":
+			val, err := strconv.ParseInt(strings.TrimRight(e.Value, "uU"), 0, 64)
+			return constValue{i: int(val)}, err == nil
+		case "float":
+			val, err := strconv.ParseFloat(strings.TrimRight(e.Value, "fF"), 64)
+			return constValue{isFloat: true, f: val}, err == nil
+		}
+	case *IdentExpr:
+		return a.constSymbolValue(e.Name, depth)
+	case *CallExpr:
+		// Scalar conversions, as in int(SIZE * 0.5)
+		ident, ok := e.Func.(*IdentExpr)
+		if !ok || len(e.Args) != 1 {
+			return constValue{}, false
+		}
+		val, ok := a.constValue(e.Args[0], depth+1)
+		if !ok {
+			return constValue{}, false
+		}
+		switch ident.Name {
+		case "int", "uint":
+			if val.isFloat {
+				return constValue{i: int(val.f)}, true
+			}
+			return val, true
+		case "float":
+			return constValue{isFloat: true, f: val.float()}, true
+		}
+	case *UnaryExpr:
+		val, ok := a.constValue(e.Operand, depth+1)
+		if !ok || !e.Prefix {
+			return constValue{}, false
+		}
+		switch e.Operator {
+		case "-":
+			return constValue{isFloat: val.isFloat, i: -val.i, f: -val.f}, true
+		case "+":
+			return val, true
+		case "~":
+			return constValue{i: ^val.i}, !val.isFloat
+		}
+	case *BinaryExpr:
+		left, ok := a.constValue(e.Left, depth+1)
+		if !ok {
+			return constValue{}, false
+		}
+		right, ok := a.constValue(e.Right, depth+1)
+		if !ok {
+			return constValue{}, false
+		}
+		if left.isFloat || right.isFloat {
+			return foldFloat(e.Operator, left.float(), right.float())
+		}
+		return foldInt(e.Operator, left.i, right.i)
+	}
+	return constValue{}, false
+}
+
+// constSymbolValue folds the value of the constant named name, converted to
+// its declared type.
+func (a *Analyzer) constSymbolValue(name string, depth int) (constValue, bool) {
+	var value Expr
+	var t *Type
+	if sym := a.currentScope.lookup(name); sym != nil {
+		if !sym.Constant {
+			return constValue{}, false
+		}
+		value, t = sym.Value, sym.Type
+	} else {
+		// Uniforms are registered before constants, so resolve the
+		// declaration directly rather than through the scope.
+		for _, c := range a.doc.Constants {
+			if c.Name == name {
+				value, t = c.Value, TypeFromName(c.Type.Name)
+				break
+			}
+		}
+	}
+	if value == nil {
+		return constValue{}, false
+	}
+
+	val, ok := a.constValue(value, depth+1)
+	if !ok || t == nil {
+		return val, ok
+	}
+	switch t.Kind {
+	case TypeKindFloat:
+		return constValue{isFloat: true, f: val.float()}, true
+	case TypeKindInt, TypeKindUint:
+		return val, !val.isFloat
+	}
+	return constValue{}, false
+}
+
+// foldInt applies an integer operator.
+func foldInt(op string, left, right int) (constValue, bool) {
+	switch op {
+	case "+":
+		return constValue{i: left + right}, true
+	case "-":
+		return constValue{i: left - right}, true
+	case "*":
+		return constValue{i: left * right}, true
+	case "/":
+		if right != 0 {
+			return constValue{i: left / right}, true
+		}
+	case "%":
+		if right != 0 {
+			return constValue{i: left % right}, true
+		}
+	case "<<":
+		if right >= 0 {
+			return constValue{i: left << right}, true
+		}
+	case ">>":
+		if right >= 0 {
+			return constValue{i: left >> right}, true
+		}
+	case "&":
+		return constValue{i: left & right}, true
+	case "|":
+		return constValue{i: left | right}, true
+	case "^":
+		return constValue{i: left ^ right}, true
+	}
+	return constValue{}, false
+}
+
+// foldFloat applies a float operator.
+func foldFloat(op string, left, right float64) (constValue, bool) {
+	switch op {
+	case "+":
+		return constValue{isFloat: true, f: left + right}, true
+	case "-":
+		return constValue{isFloat: true, f: left - right}, true
+	case "*":
+		return constValue{isFloat: true, f: left * right}, true
+	case "/":
+		if right != 0 {
+			return constValue{isFloat: true, f: left / right}, true
+		}
+	}
+	return constValue{}, false
+}
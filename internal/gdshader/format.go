@@ -0,0 +1,128 @@
+package gdshader
+
+import (
+	"strings"
+)
+
+// Format returns src with normalized whitespace. It only changes layout,
+// never tokens:
+//   - lines are re-indented with tabs according to brace depth, with one
+//     extra level for lines continuing an open parenthesis or bracket
+//   - preprocessor directives start at column zero
+//   - trailing whitespace is removed and runs of blank lines are collapsed
+//   - the file ends with exactly one newline
+//
+// The contents of block comments spanning several lines are kept as is, and
+// so are a byte order mark and the line breaks of the file: "\r\n" when
+// most lines end with it, and "\n" otherwise.
+func Format(src string) string {
+	src, bom := strings.CutPrefix(src, byteOrderMark)
+	crlf := strings.Count(src, "\r\n")
+	out := format(strings.ReplaceAll(src, "\r\n", "\n"))
+	if crlf > strings.Count(src, "\n")-crlf {
+		out = strings.ReplaceAll(out, "\n", "\r\n")
+	}
+	if bom && out != "" {
+		out = byteOrderMark + out
+	}
+	return out
+}
+
+// format formats src with "\n" line breaks.
+func format(src string) string {
+	lines := strings.Split(src, "\n")
+
+	var out []string
+	braceDepth := 0
+	parenDepth := 0
+	inBlockComment := false
+	blankRun := 0
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// Lines inside a multi-line block comment are kept verbatim
+		if inBlockComment {
+			out = append(out, strings.TrimRight(line, " \t"))
+			if strings.Contains(line, "*/") {
+				inBlockComment = false
+				rest := line[strings.Index(line, "*/")+2:]
+				braceDepth, parenDepth, inBlockComment = scanDepth(rest, braceDepth, parenDepth)
+			}
+			blankRun = 0
+			continue
+		}
+
+		if trimmed == "" {
+			blankRun++
+			if blankRun > 1 || len(out) == 0 {
+				continue
+			}
+			out = append(out, "")
+			continue
+		}
+		blankRun = 0
+
+		if strings.HasPrefix(trimmed, "#") {
+			out = append(out, trimmed)
+			continue
+		}
+
+		indent := braceDepth
+		if strings.HasPrefix(trimmed, "}") {
+			indent--
+		}
+		if parenDepth > 0 && !strings.HasPrefix(trimmed, ")") && !strings.HasPrefix(trimmed, "]") {
+			indent++
+		}
+		if indent < 0 {
+			indent = 0
+		}
+
+		out = append(out, strings.Repeat("\t", indent)+trimmed)
+		braceDepth, parenDepth, inBlockComment = scanDepth(trimmed, braceDepth, parenDepth)
+	}
+
+	// Drop trailing blank lines
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return ""
+	}
+
+	return strings.Join(out, "\n") + "\n"
+}
+
+// scanDepth updates the brace and parenthesis depth with the code on a line,
+// skipping comments. It reports whether the line ends inside a block comment.
+func scanDepth(line string, braceDepth, parenDepth int) (int, int, bool) {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '/':
+			if i+1 < len(line) && line[i+1] == '/' {
+				return braceDepth, parenDepth, false
+			}
+			if i+1 < len(line) && line[i+1] == '*' {
+				end := strings.Index(line[i+2:], "*/")
+				if end < 0 {
+					return braceDepth, parenDepth, true
+				}
+				i += end + 3
+			}
+		case '{':
+			braceDepth++
+		case '}':
+			if braceDepth > 0 {
+				braceDepth--
+			}
+		case '(', '[':
+			parenDepth++
+		case ')', ']':
+			if parenDepth > 0 {
+				parenDepth--
+			}
+		}
+	}
+	return braceDepth, parenDepth, false
+}
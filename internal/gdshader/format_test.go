@@ -0,0 +1,45 @@
+package gdshader
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatIndentation(t *testing.T) {
+	input := "shader_type spatial;\r\n\r\n\r\nvoid fragment() {   \n  if (true) {\nALBEDO = vec3(1.0);\n        } else {\n   ALBEDO = vec3(\n0.0,\n0.0,\n0.0);\n}\n}\n\n\n"
+	expected := "shader_type spatial;\n\nvoid fragment() {\n\tif (true) {\n\t\tALBEDO = vec3(1.0);\n\t} else {\n\t\tALBEDO = vec3(\n\t\t\t0.0,\n\t\t\t0.0,\n\t\t\t0.0);\n\t}\n}\n"
+
+	if got := Format(input); got != expected {
+		t.Errorf("unexpected output:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestFormatPreservesComments(t *testing.T) {
+	input := "shader_type spatial;\n/**\n * Doc comment { with braces }\n   indented\n */\nuniform float x; // trailing {\n  #define FOO 1\nvoid vertex() {\n/* { */ VERTEX.x += x;\n}\n"
+	expected := "shader_type spatial;\n/**\n * Doc comment { with braces }\n   indented\n */\nuniform float x; // trailing {\n#define FOO 1\nvoid vertex() {\n\t/* { */ VERTEX.x += x;\n}\n"
+
+	if got := Format(input); got != expected {
+		t.Errorf("unexpected output:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	input := "shader_type canvas_item;\n\nvoid fragment() {\n\tCOLOR = texture(TEXTURE, UV);\n}\n"
+	if got := Format(input); got != input {
+		t.Errorf("expected formatted input to be unchanged, got:\n%q", got)
+	}
+	if got := Format(""); got != "" {
+		t.Errorf("expected empty output, got %q", got)
+	}
+}
+
+func TestFormatKeepsLineEndings(t *testing.T) {
+	input := "\uFEFFshader_type spatial;\r\n\r\n\r\nvoid fragment() {\r\nALBEDO = vec3(1.0);\r\n}\r\n"
+	expected := "\uFEFFshader_type spatial;\r\n\r\nvoid fragment() {\r\n\tALBEDO = vec3(1.0);\r\n}\r\n"
+	if got := Format(input); got != expected {
+		t.Errorf("expected CRLF line endings and the byte order mark to be kept, got %q", got)
+	}
+	if got := Format(strings.ReplaceAll(expected, "\r\n", "\n")); strings.Contains(got, "\r") {
+		t.Errorf("expected LF line endings to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,120 @@
+package gdshader
+
+import (
+	"slices"
+	"strings"
+)
+
+// Like GLSL, the shading language has no forward declarations and no
+// recursion: a function can only call the functions declared above it, and
+// never itself, directly or through others. The engine calls the processor
+// functions of the shader type, of which a shader defines at least one.
+
+// functionCall is a call of a user function from the body of another.
+type functionCall struct {
+	ident  *IdentExpr
+	callee *FunctionDecl
+}
+
+// checkFunctions reports recursion, calls of functions declared further
+// down, and shaders defining none of their processor functions. It runs
+// after the function bodies have been analyzed.
+func (a *Analyzer) checkFunctions() {
+	a.checkEntryPoints()
+
+	decls := make(map[*Symbol]*FunctionDecl, len(a.doc.Functions))
+	for _, decl := range a.doc.Functions {
+		sym := a.globalScope.lookup(decl.Name)
+		if sym == nil || sym.Kind != SymbolFunction {
+			continue
+		}
+		for _, overload := range sym.overloads() {
+			if overload.URI == "" && overload.NameRange == decl.NameRange {
+				decls[overload] = decl
+			}
+		}
+	}
+
+	calls := make(map[*FunctionDecl][]functionCall, len(a.doc.Functions))
+	for _, decl := range a.doc.Functions {
+		if decl.Body == nil {
+			continue
+		}
+		Inspect(decl.Body, func(n Node) bool {
+			if call, ok := n.(*CallExpr); ok {
+				if ident, ok := call.Func.(*IdentExpr); ok && decls[a.symbols[ident]] != nil {
+					calls[decl] = append(calls[decl], functionCall{ident: ident, callee: decls[a.symbols[ident]]})
+				}
+			}
+			return true
+		})
+	}
+
+	for _, decl := range a.doc.Functions {
+		for _, call := range calls[decl] {
+			switch {
+			case call.callee == decl:
+				a.addError(call.ident.Range, "function '%s' calls itself; recursion is not allowed", decl.Name).Code = CodeRecursion
+			case positionBefore(decl.Range.Start, call.callee.Range.Start):
+				// Every cycle has a call to a function declared further down,
+				// where it is reported
+				if path := callPath(calls, call.callee, decl); path != nil {
+					names := make([]string, 0, len(path)+1)
+					for _, fn := range append([]*FunctionDecl{decl}, path...) {
+						names = append(names, "'"+fn.Name+"'")
+					}
+					a.addError(call.ident.Range, "recursion is not allowed: %s", strings.Join(names, " calls ")).Code = CodeRecursion
+					continue
+				}
+				err := a.addError(call.ident.Range, "function '%s' is called before it is declared", call.callee.Name)
+				err.Code = CodeDeclarationOrder
+				err.Related = append(err.Related, RelatedInfo{Range: call.callee.NameRange, Message: "Declared here"})
+			}
+		}
+	}
+}
+
+// checkEntryPoints warns about a shader defining none of the processor
+// functions of its type, which are the only ones the engine calls. Shaders
+// including other files may define them there.
+func (a *Analyzer) checkEntryPoints() {
+	stages := ShaderStages[a.shaderType]
+	if a.doc.ShaderType == nil || len(stages) == 0 {
+		return
+	}
+	for _, d := range a.doc.Directives {
+		if d.Name == "include" {
+			return
+		}
+	}
+	for _, decl := range a.doc.Functions {
+		if slices.Contains(stages, decl.Name) {
+			return
+		}
+	}
+	a.addWarning(a.doc.ShaderType.Range, "%s shader defines none of its processor functions: %s",
+		a.shaderType, strings.Join(stages, ", ")).Code = CodeMissingEntryPoint
+}
+
+// callPath returns the functions on a chain of calls from one function to
+// another, both included, or nil when from never leads to to.
+func callPath(calls map[*FunctionDecl][]functionCall, from, to *FunctionDecl) []*FunctionDecl {
+	visited := make(map[*FunctionDecl]bool)
+	var visit func(fn *FunctionDecl) []*FunctionDecl
+	visit = func(fn *FunctionDecl) []*FunctionDecl {
+		if fn == to {
+			return []*FunctionDecl{fn}
+		}
+		if visited[fn] {
+			return nil
+		}
+		visited[fn] = true
+		for _, call := range calls[fn] {
+			if path := visit(call.callee); path != nil {
+				return append([]*FunctionDecl{fn}, path...)
+			}
+		}
+		return nil
+	}
+	return visit(from)
+}
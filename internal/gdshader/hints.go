@@ -0,0 +1,163 @@
+package gdshader
+
+import "strings"
+
+// hintCategory groups the uniform hints of which a uniform can have at most
+// one, e.g. a single filter mode.
+type hintCategory int
+
+const (
+	hintCategoryNone hintCategory = iota
+	hintCategoryTexture
+	hintCategoryFilter
+	hintCategoryRepeat
+)
+
+// textureHints are the hints describing what a sampler holds, which also
+// decide its default texture.
+var textureHints = map[string]bool{
+	"source_color":                  true,
+	"hint_normal":                   true,
+	"hint_default_white":            true,
+	"hint_default_black":            true,
+	"hint_default_transparent":      true,
+	"hint_anisotropy":               true,
+	"hint_roughness_r":              true,
+	"hint_roughness_g":              true,
+	"hint_roughness_b":              true,
+	"hint_roughness_a":              true,
+	"hint_roughness_normal":         true,
+	"hint_roughness_gray":           true,
+	"hint_screen_texture":           true,
+	"hint_depth_texture":            true,
+	"hint_normal_roughness_texture": true,
+}
+
+// screenHints read from the renderer instead of a texture set on the
+// material, which only works for 2D samplers.
+var screenHints = map[string]bool{
+	"hint_screen_texture":           true,
+	"hint_depth_texture":            true,
+	"hint_normal_roughness_texture": true,
+}
+
+// hintCategoryOf returns the category a hint belongs to.
+func hintCategoryOf(name string) hintCategory {
+	switch {
+	case textureHints[name]:
+		return hintCategoryTexture
+	case strings.HasPrefix(name, "filter_"):
+		return hintCategoryFilter
+	case strings.HasPrefix(name, "repeat_"):
+		return hintCategoryRepeat
+	}
+	return hintCategoryNone
+}
+
+// checkHints validates the hints of a uniform against its type, following
+// the rules of Godot's shader compiler.
+func (a *Analyzer) checkHints(decl *UniformDecl, varType *Type) {
+	if varType == TypeError {
+		return
+	}
+	elemType := varType
+	if varType.Kind == TypeKindArray {
+		elemType = varType.ElementType
+	}
+
+	seen := make(map[hintCategory]string)
+	for _, hint := range decl.Hints {
+		if _, ok := UniformHints[hint.Name]; !ok {
+			a.addError(hint.Range, "unknown uniform hint '%s'", hint.Name)
+			continue
+		}
+		if since := uniformHintSince[hint.Name]; !a.version.available(since) {
+			a.addError(hint.Range, "uniform hint '%s' requires Godot %s or later", hint.Name, since).Code = CodeRequiresVersion
+			continue
+		}
+
+		if category := hintCategoryOf(hint.Name); category != hintCategoryNone {
+			if previous, ok := seen[category]; ok {
+				a.addError(hint.Range, "hint '%s' conflicts with '%s'", hint.Name, previous)
+				continue
+			}
+			seen[category] = hint.Name
+		}
+
+		switch {
+		case hint.Name == "hint_range":
+			if elemType.Kind != TypeKindFloat && elemType.Kind != TypeKindInt {
+				a.addError(hint.Range, "hint_range is only for 'float' and 'int' uniforms, not '%s'", varType)
+			} else if len(hint.Args) != 2 && len(hint.Args) != 3 {
+				a.addError(hint.Range, "hint_range expects 2 or 3 arguments (min, max[, step]), got %d", len(hint.Args))
+			} else {
+				a.checkHintRange(hint)
+			}
+		case hint.Name == "hint_enum":
+			if elemType.Kind != TypeKindInt {
+				a.addError(hint.Range, "hint_enum is only for 'int' uniforms, not '%s'", varType)
+			} else if len(hint.Args) == 0 {
+				a.addError(hint.Range, "hint_enum expects at least one option")
+			} else {
+				for _, arg := range hint.Args {
+					if lit, ok := arg.(*LiteralExpr); !ok || lit.Kind != "string" {
+						a.addError(arg.GetRange(), "hint_enum options must be strings")
+					}
+				}
+			}
+		case hint.Name == "instance_index":
+			if len(hint.Args) != 1 {
+				a.addError(hint.Range, "instance_index expects 1 argument, got %d", len(hint.Args))
+			}
+		case hint.Name == "source_color":
+			if elemType.Kind != TypeKindVec3 && elemType.Kind != TypeKindVec4 && !elemType.IsSampler() {
+				a.addError(hint.Range, "source_color is only for 'vec3', 'vec4' and sampler uniforms, not '%s'", varType)
+			}
+		case screenHints[hint.Name]:
+			if elemType.Kind != TypeKindSampler2D {
+				a.addError(hint.Range, "%s is only for 'sampler2D' uniforms, not '%s'", hint.Name, varType)
+			}
+		default:
+			// Texture hints, filter and repeat modes apply to samplers
+			if !elemType.IsSampler() {
+				a.addError(hint.Range, "%s is only for sampler uniforms, not '%s'", hint.Name, varType)
+			}
+		}
+
+		if len(hint.Args) > 0 && hint.Name != "hint_range" && hint.Name != "hint_enum" && hint.Name != "instance_index" {
+			a.addError(hint.Range, "hint '%s' takes no arguments", hint.Name)
+		}
+	}
+}
+
+// checkHintRange checks that the arguments of hint_range are number literals
+// and that they describe a non-empty range with a positive step.
+func (a *Analyzer) checkHintRange(hint *Hint) {
+	values := make([]float64, 0, len(hint.Args))
+	for _, arg := range hint.Args {
+		val, ok := a.constValue(arg, 0)
+		if !isNumberLiteral(arg) || !ok {
+			a.addError(arg.GetRange(), "hint_range arguments must be number literals")
+			continue
+		}
+		values = append(values, val.float())
+	}
+	if len(values) != len(hint.Args) {
+		return
+	}
+	if values[0] >= values[1] {
+		a.addError(hint.Range, "hint_range min (%g) must be less than max (%g)", values[0], values[1])
+	}
+	if len(values) == 3 && values[2] <= 0 {
+		a.addError(hint.Args[2].GetRange(), "hint_range step must be positive")
+	}
+}
+
+// isNumberLiteral reports whether expr is a number, optionally negated.
+func isNumberLiteral(expr Expr) bool {
+	if unary, ok := expr.(*UnaryExpr); ok && unary.Prefix && (unary.Operator == "-" || unary.Operator == "+") {
+		expr = unary.Operand
+	}
+	lit, ok := expr.(*LiteralExpr)
+	return ok && (lit.Kind == "int" || lit.Kind == "float")
+}
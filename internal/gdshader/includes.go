@@ -0,0 +1,128 @@
+package gdshader
+
+import (
+	"path"
+	"strings"
+)
+
+// An #include directive inserts the content of another file where it
+// stands, so the declarations of included files are visible to the shader.
+// Like Godot, a file included twice is only inserted once, and a file
+// including itself, directly or through others, is an error.
+
+// Include is an #include directive with a well-formed path.
+type Include struct {
+	Path  string // As written, without the quotes
+	Range Range  // The directive
+}
+
+// Includes returns the #include directives of the document, in order.
+func (doc *ShaderDocument) Includes() []Include {
+	var includes []Include
+	for _, d := range doc.Directives {
+		if d.Name == "include" && includePathPattern.MatchString(d.Args) {
+			includes = append(includes, Include{Path: strings.Trim(d.Args, `"`), Range: d.Range})
+		}
+	}
+	return includes
+}
+
+// IncludeResolver loads the file an #include in the file at from names,
+// returning its URI and its parsed content, or a nil document when it
+// cannot be read.
+type IncludeResolver func(from, path string) (uri string, doc *ShaderDocument)
+
+// SetIncludeResolver makes Analyze resolve the #include directives of the
+// document, whose URI is uri, and declare what the included files declare.
+// Without a resolver, included declarations are unknown.
+func (a *Analyzer) SetIncludeResolver(uri string, resolve IncludeResolver) {
+	a.uri = uri
+	a.resolve = resolve
+}
+
+// IncludedURIs returns the URIs of the files the document includes,
+// directly or through other includes, as resolved by the last Analyze.
+func (a *Analyzer) IncludedURIs() []string {
+	return a.included
+}
+
+// registerIncludes declares the globals of the included files, in the order
+// they are inserted, and reports circular includes on the directive of the
+// document leading to them.
+func (a *Analyzer) registerIncludes() {
+	a.included = nil
+	if a.resolve == nil {
+		return
+	}
+	visited := map[string]bool{a.uri: true}
+	for _, inc := range a.doc.Includes() {
+		a.include(inc, a.uri, inc.Path, []string{a.uri}, visited)
+	}
+}
+
+// include inserts the file an #include in the file at from names. stack
+// holds the files being inserted, outermost first.
+func (a *Analyzer) include(directive Include, from, includePath string, stack []string, visited map[string]bool) {
+	uri, doc := a.resolve(from, includePath)
+	if doc == nil {
+		return
+	}
+	for i, inserting := range stack {
+		if inserting == uri {
+			names := make([]string, 0, len(stack)-i+1)
+			for _, u := range append(stack[i:], uri) {
+				names = append(names, "'"+path.Base(u)+"'")
+			}
+			a.addError(directive.Range, "circular include: %s", strings.Join(names, " includes ")).Code = CodeCircularInclude
+			return
+		}
+	}
+	if visited[uri] {
+		return
+	}
+	visited[uri] = true
+
+	for _, inc := range doc.Includes() {
+		a.include(directive, uri, inc.Path, append(stack, uri), visited)
+	}
+	a.included = append(a.included, uri)
+	a.registerIncluded(doc, uri)
+}
+
+// registerIncluded declares the globals of an included file. Its errors are
+// reported when the file itself is analyzed, so they are dropped here.
+func (a *Analyzer) registerIncluded(doc *ShaderDocument, uri string) {
+	errCount := len(a.errors)
+	known := make(map[*Symbol]bool)
+	for _, sym := range a.globalScope.symbols {
+		for _, overload := range sym.overloads() {
+			known[overload] = true
+		}
+	}
+
+	a.registerMacros(doc)
+	for _, decl := range doc.Structs {
+		a.registerStruct(decl)
+	}
+	for _, decl := range doc.Uniforms {
+		a.registerUniform(decl)
+	}
+	for _, decl := range doc.Varyings {
+		a.registerVarying(decl)
+	}
+	for _, decl := range doc.Constants {
+		a.registerConstant(decl)
+	}
+	for _, decl := range doc.Functions {
+		a.registerFunction(decl)
+	}
+
+	a.errors = a.errors[:errCount]
+	for _, sym := range a.globalScope.symbols {
+		for _, overload := range sym.overloads() {
+			if !known[overload] {
+				overload.URI = uri
+			}
+		}
+	}
+}
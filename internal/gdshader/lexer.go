@@ -15,6 +15,10 @@ type Lexer struct {
 	column  int  // current column number (1-indexed)
 }
 
+// byteOrderMark starts files saved by some Windows editors. It is skipped
+// without counting as a column, as editors do not show it.
+const byteOrderMark = "\uFEFF"
+
 // NewLexer creates a new Lexer for the given input.
 func NewLexer(input string) *Lexer {
 	l := &Lexer{
@@ -22,6 +26,9 @@ func NewLexer(input string) *Lexer {
 		line:   1,
 		column: 0,
 	}
+	if strings.HasPrefix(input, byteOrderMark) {
+		l.readPos = len(byteOrderMark)
+	}
 	l.readChar()
 	return l
 }
@@ -42,6 +49,12 @@ func (l *Lexer) readChar() {
 	}
 }
 
+// atLineEnd reports whether the current character starts a line break,
+// "\n" or "\r\n".
+func (l *Lexer) atLineEnd() bool {
+	return l.ch == '\n' || (l.ch == '\r' && l.peekChar() == '\n')
+}
+
 // peekChar returns the next character without advancing the position.
 func (l *Lexer) peekChar() byte {
 	if l.readPos >= len(l.input) {
@@ -63,9 +76,15 @@ func (l *Lexer) NextToken() Token {
 	case 0:
 		tok.Type = TokenEOF
 		tok.Literal = ""
-	case '\n':
+	case '\n', '\r':
+		// Only the "\r" of a "\r\n" is left by skipWhitespace; the token
+		// is where the "\n" is
 		tok.Type = TokenNewline
 		tok.Literal = "\n"
+		if l.ch == '\r' {
+			l.readChar()
+			tok.Line, tok.Column = l.line, l.column
+		}
 		l.readChar()
 	case '(':
 		tok.Type = TokenLParen
@@ -285,6 +304,16 @@ func (l *Lexer) NextToken() Token {
 			tok.Literal = ">"
 			l.readChar()
 		}
+	case '"':
+		tok = l.readString()
+	case '#':
+		if l.atLineStart() {
+			tok = l.readDirective()
+		} else {
+			tok.Type = TokenError
+			tok.Literal = "#"
+			l.readChar()
+		}
 	default:
 		if isLetter(l.ch) || l.ch == '_' {
 			tok = l.readIdentifier()
@@ -302,7 +331,7 @@ func (l *Lexer) NextToken() Token {
 
 // skipWhitespace skips spaces and tabs (but not newlines).
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\r' {
+	for l.ch == ' ' || l.ch == '\t' || (l.ch == '\r' && !l.atLineEnd()) {
 		l.readChar()
 	}
 }
@@ -393,6 +422,29 @@ func (l *Lexer) readNumber() Token {
 	return tok
 }
 
+// readString reads a string literal. Strings cannot span lines or contain
+// escapes; an unterminated string is returned as an error token.
+func (l *Lexer) readString() Token {
+	tok := Token{
+		Line:   l.line,
+		Column: l.column,
+	}
+	startPos := l.pos
+	l.readChar() // consume opening quote
+	for l.ch != '"' && l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+	if l.ch != '"' {
+		tok.Type = TokenError
+		tok.Literal = l.input[startPos:l.pos]
+		return tok
+	}
+	l.readChar() // consume closing quote
+	tok.Type = TokenStringLit
+	tok.Literal = l.input[startPos:l.pos]
+	return tok
+}
+
 // readLineComment reads a // comment.
 func (l *Lexer) readLineComment() Token {
 	tok := Token{
@@ -405,7 +457,7 @@ func (l *Lexer) readLineComment() Token {
 	l.readChar()
 	l.readChar()
 	// Read until end of line or EOF
-	for l.ch != '\n' && l.ch != 0 {
+	for !l.atLineEnd() && l.ch != 0 {
 		l.readChar()
 	}
 	tok.Literal = l.input[startPos:l.pos]
@@ -449,6 +501,47 @@ func (l *Lexer) readBlockComment() Token {
 	return tok
 }
 
+// atLineStart reports whether only whitespace precedes the current
+// character on its line.
+func (l *Lexer) atLineStart() bool {
+	for i := l.pos - 1; i >= 0; i-- {
+		switch l.input[i] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// readDirective reads a preprocessor directive up to the end of the line,
+// following backslash line continuations.
+func (l *Lexer) readDirective() Token {
+	tok := Token{
+		Type:   TokenPreprocessor,
+		Line:   l.line,
+		Column: l.column,
+	}
+	startPos := l.pos
+	for !l.atLineEnd() && l.ch != 0 {
+		if l.ch == '\\' {
+			l.readChar()
+			if l.ch == '\r' && l.peekChar() == '\n' {
+				l.readChar()
+			}
+			if l.ch == '\n' {
+				l.readChar() // Continued on the next line
+			}
+			continue
+		}
+		l.readChar()
+	}
+	tok.Literal = l.input[startPos:l.pos]
+	return tok
+}
+
 // Tokenize tokenizes the entire input and returns all tokens.
 func (l *Lexer) Tokenize() []Token {
 	var tokens []Token
@@ -0,0 +1,41 @@
+package gdshader
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLexCRLFAndByteOrderMark(t *testing.T) {
+	raw, err := os.ReadFile("../../testdata/crlf.gdshader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlf := string(raw)
+	if !strings.Contains(crlf, "\r\n") {
+		t.Fatal("expected the fixture to use CRLF line endings")
+	}
+	lf := strings.ReplaceAll(crlf, "\r\n", "\n")
+
+	// Tokens, comments and directives included, are at the same lines and
+	// columns with the same text, with or without a byte order mark. Only
+	// block comments spanning lines keep their line breaks.
+	want := NewLexer(lf).Tokenize()
+	for _, content := range []string{crlf, byteOrderMark + crlf} {
+		got := NewLexer(content).Tokenize()
+		if len(got) != len(want) {
+			t.Fatalf("expected %d tokens, got %d", len(want), len(got))
+		}
+		for i := range want {
+			got[i].Literal = strings.ReplaceAll(got[i].Literal, "\r\n", "\n")
+			if got[i] != want[i] {
+				t.Errorf("token %d: expected %+v, got %+v", i, want[i], got[i])
+			}
+		}
+	}
+
+	doc := Parse(crlf)
+	if len(doc.Errors) > 0 {
+		t.Errorf("unexpected errors: %v", doc.Errors)
+	}
+}
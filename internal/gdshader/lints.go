@@ -0,0 +1,96 @@
+package gdshader
+
+import "strings"
+
+// Lints warn about shaders that the analyzer accepts but that the Godot
+// editor rejects or that behave differently across renderers, such as the
+// GLES3 Compatibility renderer. Each has a code, so editors can turn it off.
+
+// lintConversion warns about an int or uint value implicitly converted to a
+// float type, as in float x = 1.
+func (a *Analyzer) lintConversion(expr Expr, from, to *Type) {
+	if !isIntBased(from) || !isFloatBased(to) {
+		return
+	}
+	if literal, ok := intLiteralText(expr); ok {
+		a.addWarning(expr.GetRange(), "integer literal '%s' used as '%s'; write '%s.0'",
+			literal, to.String(), literal).Code = CodeImplicitConversion
+		return
+	}
+	a.addWarning(expr.GetRange(), "implicit conversion from '%s' to '%s'; convert it explicitly",
+		from.String(), to.String()).Code = CodeImplicitConversion
+}
+
+// lintOperands warns about the integer operand of an operation on floats,
+// as in x * 2, and about floats compared with == or !=.
+func (a *Analyzer) lintOperands(e *BinaryExpr, left, right *Type) {
+	switch {
+	case isFloatBased(left) && isIntBased(right):
+		a.lintConversion(e.Right, right, floatTypeLike(right))
+	case isIntBased(left) && isFloatBased(right):
+		a.lintConversion(e.Left, left, floatTypeLike(left))
+	}
+
+	if (e.Operator == "==" || e.Operator == "!=") && isFloatBased(left) && isFloatBased(right) {
+		a.addWarning(e.Range, "comparing floats with '%s' is unreliable; compare their difference with an epsilon",
+			e.Operator).Code = CodeFloatEquality
+	}
+}
+
+// lintTruncation warns about constructors that silently drop components:
+// a scalar built from a vector or matrix, or a matrix built from a larger
+// one.
+func (a *Analyzer) lintTruncation(e *CallExpr, target *Type, argTypes []*Type) {
+	if len(argTypes) != 1 {
+		return
+	}
+	arg := argTypes[0]
+	switch {
+	case target.IsScalar() && (arg.IsVector() || arg.IsMatrix()):
+		a.addWarning(e.Range, "'%s(%s)' keeps only the first component; use a swizzle such as '.x'",
+			target.String(), arg.String()).Code = CodeVectorTruncation
+	case target.IsMatrix() && arg.IsMatrix() && arg.MatrixSize() > target.MatrixSize():
+		a.addWarning(e.Range, "'%s(%s)' drops the components outside the upper-left %dx%d",
+			target.String(), arg.String(), target.MatrixSize(), target.MatrixSize()).Code = CodeVectorTruncation
+	}
+}
+
+// isIntBased reports whether t is an int or uint scalar or vector.
+func isIntBased(t *Type) bool {
+	switch t.Kind {
+	case TypeKindInt, TypeKindUint:
+		return true
+	}
+	return t.IsVector() && t.ComponentType().IsInteger()
+}
+
+// isFloatBased reports whether t is a float scalar or vector.
+func isFloatBased(t *Type) bool {
+	return t.Kind == TypeKindFloat || (t.IsVector() && t.ComponentType().Kind == TypeKindFloat)
+}
+
+// floatTypeLike returns the float type with as many components as t.
+func floatTypeLike(t *Type) *Type {
+	if t.IsVector() {
+		return VectorTypeForSize(TypeFloat, t.VectorSize())
+	}
+	return TypeFloat
+}
+
+// intLiteralText returns the text of a decimal int literal, optionally
+// negated, for suggesting the float literal to write instead.
+func intLiteralText(expr Expr) (string, bool) {
+	sign := ""
+	if unary, ok := expr.(*UnaryExpr); ok && unary.Prefix && unary.Operator == "-" {
+		sign, expr = "-", unary.Operand
+	}
+	lit, ok := expr.(*LiteralExpr)
+	if !ok || lit.Kind != "int" {
+		return "", false
+	}
+	digits := strings.TrimRight(lit.Value, "uU")
+	if strings.Trim(digits, "0123456789") != "" || (len(digits) > 1 && digits[0] == '0') {
+		return "", false // Hex and octal literals
+	}
+	return sign + digits, true
+}
@@ -2,6 +2,7 @@ package gdshader
 
 import (
 	"fmt"
+	"strings"
 )
 
 // Parser parses GDShader source code into an AST.
@@ -12,6 +13,11 @@ type Parser struct {
 	errors   []ParseError
 	comments []*Comment
 	lastDoc  string // last doc comment for uniform documentation
+
+	directives []*Directive
+
+	// group_uniforms in effect for the uniforms that follow
+	group, subgroup string
 }
 
 // Parse parses the input and returns a ShaderDocument.
@@ -23,12 +29,112 @@ func Parse(input string) *ShaderDocument {
 // NewParser creates a new Parser for the given input.
 func NewParser(input string) *Parser {
 	lexer := NewLexer(input)
-	tokens := lexer.Tokenize()
-	return &Parser{
-		lexer:  lexer,
-		tokens: tokens,
-		pos:    0,
+	p := &Parser{lexer: lexer}
+	p.tokens = p.collectDirectives(lexer.Tokenize())
+	return p
+}
+
+// collectDirectives takes the preprocessor directives out of tokens, along
+// with the tokens of every branch of a conditional but the first, so that
+// the declarations of alternative branches do not clash.
+func (p *Parser) collectDirectives(tokens []Token) []Token {
+	kept := make([]Token, 0, len(tokens))
+	var skips []bool // Whether the current branch of each open conditional is skipped
+	skipping := func() bool {
+		return len(skips) > 0 && skips[len(skips)-1]
+	}
+	for _, tok := range tokens {
+		if tok.Type != TokenPreprocessor {
+			if !skipping() || tok.Type == TokenEOF {
+				kept = append(kept, tok)
+			}
+			continue
+		}
+		d := parseDirective(tok)
+		p.directives = append(p.directives, d)
+		switch d.Name {
+		case "if", "ifdef", "ifndef":
+			skips = append(skips, skipping())
+		case "elif", "else":
+			if len(skips) > 0 {
+				skips[len(skips)-1] = true
+			}
+		case "endif":
+			if len(skips) > 0 {
+				skips = skips[:len(skips)-1]
+			}
+		}
 	}
+	return kept
+}
+
+// parseDirective splits a preprocessor token into its name and arguments.
+func parseDirective(tok Token) *Directive {
+	lit := tok.Literal
+	i, line, col := 0, tok.Line-1, tok.Column-1
+	pos := func() Position {
+		return Position{Line: line, Column: col}
+	}
+	advance := func() {
+		if lit[i] == '\n' {
+			line++
+			col = 0
+		} else {
+			col++
+		}
+		i++
+	}
+	skipSpaces := func() {
+		for i < len(lit) && (lit[i] == ' ' || lit[i] == '\t') {
+			advance()
+		}
+	}
+
+	d := &Directive{Range: Range{Start: pos()}}
+	advance() // consume #
+	skipSpaces()
+	start := i
+	d.NameRange.Start = pos()
+	for i < len(lit) && (isLetter(lit[i]) || isDigit(lit[i]) || lit[i] == '_') {
+		advance()
+	}
+	d.Name = lit[start:i]
+	d.NameRange.End = pos()
+	skipSpaces()
+	d.ArgsStart = pos()
+	d.Args = directiveArgs(lit[i:])
+	for i < len(lit) {
+		advance()
+	}
+	d.Range.End = pos()
+	return d
+}
+
+// directiveArgs returns the arguments of a directive without comments and
+// line continuations.
+func directiveArgs(s string) string {
+	s = strings.NewReplacer("\\\r\n", " ", "\\\n", " ").Replace(s)
+	var sb strings.Builder
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"':
+			inString = !inString
+		case inString:
+		case strings.HasPrefix(s[i:], "//"):
+			return strings.TrimSpace(sb.String())
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				return strings.TrimSpace(sb.String())
+			}
+			i += end + 3
+			sb.WriteByte(' ')
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return strings.TrimSpace(sb.String())
 }
 
 // Parse parses the input and returns a ShaderDocument.
@@ -57,6 +163,12 @@ func (p *Parser) Parse() *ShaderDocument {
 			break
 		}
 
+		if p.check(TokenGroupUniforms) {
+			p.parseGroupUniforms()
+			continue
+		}
+
+		start := p.tokenRange(p.current()).Start
 		decl := p.parseDeclaration()
 		if decl == nil {
 			// Skip to next semicolon or newline on error
@@ -64,22 +176,30 @@ func (p *Parser) Parse() *ShaderDocument {
 			continue
 		}
 
+		// From the first keyword, e.g. the global of a global uniform
+		declRange := Range{Start: start, End: p.previousEnd()}
 		switch d := decl.(type) {
 		case *StructDecl:
+			d.DeclRange = declRange
 			doc.Structs = append(doc.Structs, d)
 		case *UniformDecl:
+			d.DeclRange = declRange
 			doc.Uniforms = append(doc.Uniforms, d)
 		case *VaryingDecl:
+			d.DeclRange = declRange
 			doc.Varyings = append(doc.Varyings, d)
 		case *ConstDecl:
+			d.DeclRange = declRange
 			doc.Constants = append(doc.Constants, d)
 		case *FunctionDecl:
+			d.DeclRange = declRange
 			doc.Functions = append(doc.Functions, d)
 		}
 	}
 
 	doc.Errors = p.errors
 	doc.Comments = p.comments
+	doc.Directives = p.directives
 	return doc
 }
 
@@ -91,6 +211,15 @@ func (p *Parser) current() Token {
 	return p.tokens[p.pos]
 }
 
+// previousEnd returns the end of the token consumed last, e.g. the ';'
+// ending a declaration.
+func (p *Parser) previousEnd() Position {
+	if p.pos == 0 || p.pos > len(p.tokens) {
+		return p.tokenRange(p.current()).Start
+	}
+	return p.tokenRange(p.tokens[p.pos-1]).End
+}
+
 // peek returns the next token without consuming.
 func (p *Parser) peek() Token {
 	if p.pos+1 >= len(p.tokens) {
@@ -244,6 +373,7 @@ func (p *Parser) parseRenderMode() *RenderModeDecl {
 	for {
 		if p.check(TokenIdent) {
 			decl.Modes = append(decl.Modes, p.current().Literal)
+			decl.ModeRanges = append(decl.ModeRanges, p.tokenRange(p.current()))
 			p.advance()
 		} else {
 			p.error("expected render mode identifier")
@@ -261,23 +391,6 @@ func (p *Parser) parseRenderMode() *RenderModeDecl {
 
 // parseDeclaration parses a top-level declaration.
 func (p *Parser) parseDeclaration() interface{} {
-	// Check for group_uniforms
-	if p.check(TokenGroupUniforms) {
-		// Just skip group_uniforms for now, but we could track it
-		p.advance()
-		if p.check(TokenIdent) {
-			p.advance() // group name
-			if p.check(TokenDot) {
-				p.advance()
-				if p.check(TokenIdent) {
-					p.advance() // subgroup name
-				}
-			}
-		}
-		p.expect(TokenSemicolon, "expected ';' after group_uniforms")
-		return nil
-	}
-
 	// Check for struct
 	if p.check(TokenStruct) {
 		return p.parseStructDecl()
@@ -333,6 +446,7 @@ func (p *Parser) parseStructDecl() *StructDecl {
 
 	if p.check(TokenIdent) {
 		decl.Name = p.current().Literal
+		decl.NameRange = p.tokenRange(p.current())
 		p.advance()
 	} else {
 		p.error("expected struct name")
@@ -349,10 +463,14 @@ func (p *Parser) parseStructDecl() *StructDecl {
 			break
 		}
 
+		before := p.pos
 		member := p.parseStructMember()
 		if member != nil {
 			decl.Members = append(decl.Members, member)
 		}
+		if p.pos == before {
+			p.advance() // Skip the token no member can start with
+		}
 		p.skipNewlinesAndComments()
 	}
 
@@ -374,18 +492,56 @@ func (p *Parser) parseStructMember() *StructMember {
 		Type:  typeSpec,
 	}
 
+	// Array size may follow the type (vec3[4] colors) or the name
+	sizedType := false
+	if p.check(TokenLBracket) {
+		typeSpec.ArraySize, typeSpec.Unsized = p.parseArraySuffix()
+		sizedType = true
+	}
+
 	if p.check(TokenIdent) {
 		member.Name = p.current().Literal
+		member.NameRange = p.tokenRange(p.current())
+		member.Range.End = member.NameRange.End
 		p.advance()
 	} else {
 		p.error("expected member name")
 		return nil
 	}
 
+	if p.check(TokenLBracket) {
+		if sizedType {
+			p.error("array size already specified on the member type")
+		}
+		typeSpec.ArraySize, typeSpec.Unsized = p.parseArraySuffix()
+	}
+
 	p.expect(TokenSemicolon, "expected ';' after struct member")
+	member.DeclRange = Range{Start: member.Range.Start, End: p.previousEnd()}
 	return member
 }
 
+// parseGroupUniforms parses "group_uniforms;", which ends the current
+// group, "group_uniforms Group;", which starts one, or
+// "group_uniforms Group.Subgroup;", which starts a subgroup of it. The
+// uniforms that follow are given that group.
+func (p *Parser) parseGroupUniforms() {
+	p.advance() // consume group_uniforms
+	p.group, p.subgroup = "", ""
+	if p.check(TokenIdent) {
+		p.group = p.current().Literal
+		p.advance()
+		if p.check(TokenDot) {
+			p.advance()
+			if p.check(TokenIdent) {
+				p.subgroup = p.current().Literal
+				p.advance()
+			}
+		}
+	}
+	p.expect(TokenSemicolon, "expected ';' after group_uniforms")
+}
+
 // parseUniformDecl parses a uniform declaration.
 func (p *Parser) parseUniformDecl(isGlobal bool) *UniformDecl {
 	start := p.current()
@@ -395,9 +551,11 @@ func (p *Parser) parseUniformDecl(isGlobal bool) *UniformDecl {
 	p.lastDoc = ""
 
 	decl := &UniformDecl{
-		Range:      p.tokenRange(start),
-		IsGlobal:   isGlobal,
-		DocComment: docComment,
+		Range:        p.tokenRange(start),
+		IsGlobal:     isGlobal,
+		DocComment:   docComment,
+		GroupName:    p.group,
+		SubgroupName: p.subgroup,
 	}
 
 	typeSpec := p.parseTypeSpec()
@@ -406,21 +564,28 @@ func (p *Parser) parseUniformDecl(isGlobal bool) *UniformDecl {
 	}
 	decl.Type = typeSpec
 
+	// Array size may follow the type (sampler2D[4] textures)
+	sizedType := false
+	if p.check(TokenLBracket) {
+		decl.Type.ArraySize, decl.Type.Unsized = p.parseArraySuffix()
+		sizedType = true
+	}
+
 	if p.check(TokenIdent) {
 		decl.Name = p.current().Literal
+		decl.NameRange = p.tokenRange(p.current())
 		p.advance()
 	} else {
 		p.error("expected uniform name")
 		return decl
 	}
 
-	// Parse array size if present
+	// ...or the name (sampler2D textures[4])
 	if p.check(TokenLBracket) {
-		p.advance()
-		if !p.check(TokenRBracket) {
-			decl.Type.ArraySize = p.parseExpression()
+		if sizedType {
+			p.error("array size already specified on the uniform type")
 		}
-		p.expect(TokenRBracket, "expected ']' after array size")
+		decl.Type.ArraySize, decl.Type.Unsized = p.parseArraySuffix()
 	}
 
 	// Parse hints
@@ -439,6 +604,19 @@ func (p *Parser) parseUniformDecl(isGlobal bool) *UniformDecl {
 	return decl
 }
 
+// parseArraySuffix parses the brackets of an array type or declarator,
+// returning the size expression, or nil and unsized for "[]".
+func (p *Parser) parseArraySuffix() (size Expr, unsized bool) {
+	p.advance() // consume '['
+	if p.check(TokenRBracket) {
+		unsized = true
+	} else {
+		size = p.parseExpression()
+	}
+	p.expect(TokenRBracket, "expected ']' after array size")
+	return size, unsized
+}
+
 // parseHints parses uniform hints.
 func (p *Parser) parseHints() []*Hint {
 	var hints []*Hint
@@ -463,6 +641,9 @@ func (p *Parser) parseHints() []*Hint {
 					break
 				}
 			}
+			if p.check(TokenRParen) {
+				hint.Range.End = p.tokenRange(p.current()).End
+			}
 			p.expect(TokenRParen, "expected ')' after hint arguments")
 		}
 
@@ -486,6 +667,12 @@ func (p *Parser) parseVaryingDecl(interpolation string) *VaryingDecl {
 	start := p.current()
 	p.advance() // consume varying
 
+	// Godot writes the qualifier after the keyword, as in varying flat int
+	if interpolation == "" && (p.check(TokenFlat) || p.check(TokenSmooth)) {
+		interpolation = p.current().Literal
+		p.advance()
+	}
+
 	decl := &VaryingDecl{
 		Range:         p.tokenRange(start),
 		Interpolation: interpolation,
@@ -499,6 +686,7 @@ func (p *Parser) parseVaryingDecl(interpolation string) *VaryingDecl {
 
 	if p.check(TokenIdent) {
 		decl.Name = p.current().Literal
+		decl.NameRange = p.tokenRange(p.current())
 		p.advance()
 	} else {
 		p.error("expected varying name")
@@ -533,14 +721,29 @@ func (p *Parser) parseConstDecl() *ConstDecl {
 	}
 	decl.Type = typeSpec
 
+	// Array size may follow the type (float[3] WEIGHTS) or the name
+	sizedType := false
+	if p.check(TokenLBracket) {
+		decl.Type.ArraySize, decl.Type.Unsized = p.parseArraySuffix()
+		sizedType = true
+	}
+
 	if p.check(TokenIdent) {
 		decl.Name = p.current().Literal
+		decl.NameRange = p.tokenRange(p.current())
 		p.advance()
 	} else {
 		p.error("expected constant name")
 		return decl
 	}
 
+	if p.check(TokenLBracket) {
+		if sizedType {
+			p.error("array size already specified on the constant type")
+		}
+		decl.Type.ArraySize, decl.Type.Unsized = p.parseArraySuffix()
+	}
+
 	if !p.expect(TokenAssign, "expected '=' in constant declaration") {
 		return decl
 	}
@@ -585,6 +788,7 @@ func (p *Parser) parseFunctionDecl(returnType *TypeSpec, name string, nameRange
 		},
 		ReturnType: returnType,
 		Name:       name,
+		NameRange:  nameRange,
 	}
 
 	p.advance() // consume '('
@@ -632,8 +836,16 @@ func (p *Parser) parseParamDecl() *ParamDecl {
 	}
 	param.Type = typeSpec
 
+	sizedType := false
+	if p.check(TokenLBracket) {
+		param.Type.ArraySize, param.Type.Unsized = p.parseArraySuffix()
+		sizedType = true
+	}
+
 	if p.check(TokenIdent) {
 		param.Name = p.current().Literal
+		param.NameRange = p.tokenRange(p.current())
+		param.Range.End = p.tokenRange(p.current()).End
 		p.advance()
 	} else {
 		p.error("expected parameter name")
@@ -642,11 +854,10 @@ func (p *Parser) parseParamDecl() *ParamDecl {
 
 	// Parse array size if present
 	if p.check(TokenLBracket) {
-		p.advance()
-		if !p.check(TokenRBracket) {
-			param.Type.ArraySize = p.parseExpression()
+		if sizedType {
+			p.error("array size already specified on the parameter type")
 		}
-		p.expect(TokenRBracket, "expected ']' after array size")
+		param.Type.ArraySize, param.Type.Unsized = p.parseArraySuffix()
 	}
 
 	return param
@@ -667,10 +878,12 @@ func (p *Parser) parseTypeSpec() *TypeSpec {
 	// Parse type name
 	if p.current().Type.IsType() {
 		spec.Name = p.current().Literal
+		spec.Range.End = p.tokenRange(p.current()).End
 		p.advance()
 	} else if p.check(TokenIdent) {
 		// Custom type (struct)
 		spec.Name = p.current().Literal
+		spec.Range.End = p.tokenRange(p.current()).End
 		p.advance()
 	} else {
 		p.error("expected type name")
@@ -695,11 +908,13 @@ func (p *Parser) parseBlockStmt() *BlockStmt {
 			break
 		}
 
-		stmt := p.parseStatement()
-		block.Stmts = append(block.Stmts, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Stmts = append(block.Stmts, stmt)
+		}
 		p.skipNewlinesAndComments()
 	}
 
+	block.Range.End = p.tokenRange(p.current()).End
 	p.expect(TokenRBrace, "expected '}' after block")
 	return block
 }
@@ -718,7 +933,10 @@ func (p *Parser) parseStatement() Stmt {
 	case TokenDo:
 		return p.parseDoWhileStmt()
 	case TokenSwitch:
-		return p.parseSwitchStmt()
+		if stmt := p.parseSwitchStmt(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case TokenReturn:
 		return p.parseReturnStmt()
 	case TokenBreak:
@@ -731,11 +949,11 @@ func (p *Parser) parseStatement() Stmt {
 		tok := p.advance()
 		return &EmptyStmt{Range: p.tokenRange(tok)}
 	case TokenConst:
-		return p.parseVarDeclStmt(true)
+		return p.varDeclStmt(true)
 	default:
 		// Could be a variable declaration or expression statement
 		if p.isTypeStart() {
-			return p.parseVarDeclStmt(false)
+			return p.varDeclStmt(false)
 		}
 		return p.parseExprStmt()
 	}
@@ -753,6 +971,15 @@ func (p *Parser) isTypeStart() bool {
 	return false
 }
 
+// varDeclStmt parses a variable declaration statement, returning a nil
+// Stmt rather than a nil *VarDeclStmt when there is none.
+func (p *Parser) varDeclStmt(isConst bool) Stmt {
+	if stmt := p.parseVarDeclStmt(isConst); stmt != nil {
+		return stmt
+	}
+	return nil
+}
+
 // parseVarDeclStmt parses a variable declaration statement.
 func (p *Parser) parseVarDeclStmt(isConst bool) *VarDeclStmt {
 	start := p.current()
@@ -771,6 +998,14 @@ func (p *Parser) parseVarDeclStmt(isConst bool) *VarDeclStmt {
 		return nil
 	}
 
+	// Array size may follow the type (float[3] a, b) and then applies to
+	// every declarator
+	sizedType := false
+	if p.check(TokenLBracket) {
+		stmt.Type.ArraySize, stmt.Type.Unsized = p.parseArraySuffix()
+		sizedType = true
+	}
+
 	// Parse variable declarators
 	for {
 		decl := &VarDecl{
@@ -787,11 +1022,10 @@ func (p *Parser) parseVarDeclStmt(isConst bool) *VarDeclStmt {
 
 		// Parse array size if present
 		if p.check(TokenLBracket) {
-			p.advance()
-			if !p.check(TokenRBracket) {
-				decl.ArraySize = p.parseExpression()
+			if sizedType {
+				p.error("array size already specified on the variable type")
 			}
-			p.expect(TokenRBracket, "expected ']' after array size")
+			decl.ArraySize, decl.Unsized = p.parseArraySuffix()
 		}
 
 		// Parse initializer
@@ -850,7 +1084,7 @@ func (p *Parser) parseForStmt() *ForStmt {
 	// Parse init
 	if !p.check(TokenSemicolon) {
 		if p.isTypeStart() {
-			stmt.Init = p.parseVarDeclStmt(false)
+			stmt.Init = p.varDeclStmt(false)
 		} else {
 			stmt.Init = p.parseExprStmt()
 		}
@@ -975,8 +1209,9 @@ func (p *Parser) parseCaseClause() *CaseClause {
 			break
 		}
 
-		stmt := p.parseStatement()
-		clause.Body = append(clause.Body, stmt)
+		if stmt := p.parseStatement(); stmt != nil {
+			clause.Body = append(clause.Body, stmt)
+		}
 		p.skipNewlinesAndComments()
 	}
 
@@ -1391,10 +1626,19 @@ func (p *Parser) parsePostfix() Expr {
 				Expr:  expr,
 				Index: index,
 			}
-		} else if p.match(TokenDot) {
+		} else if p.check(TokenDot) {
 			// Member access
+			dotTok := p.advance()
 			if !p.check(TokenIdent) {
 				p.error("expected member name after '.'")
+				// Keep the access being typed for completion
+				expr = &MemberExpr{
+					Range: Range{
+						Start: expr.GetRange().Start,
+						End:   p.tokenRange(dotTok).End,
+					},
+					Expr: expr,
+				}
 				break
 			}
 			member := p.current().Literal
@@ -1403,7 +1647,7 @@ func (p *Parser) parsePostfix() Expr {
 			expr = &MemberExpr{
 				Range: Range{
 					Start: expr.GetRange().Start,
-					End:   Position{Line: endTok.Line - 1, Column: endTok.Column + len(member)},
+					End:   Position{Line: endTok.Line - 1, Column: endTok.Column - 1 + len(member)},
 				},
 				Expr:   expr,
 				Member: member,
@@ -1479,6 +1723,13 @@ func (p *Parser) parsePrimary() Expr {
 			Kind:  "float",
 			Value: tok.Literal,
 		}
+	case TokenStringLit:
+		p.advance()
+		return &LiteralExpr{
+			Range: p.tokenRange(tok),
+			Kind:  "string",
+			Value: strings.Trim(tok.Literal, `"`),
+		}
 	case TokenIdent:
 		p.advance()
 		return &IdentExpr{
@@ -1497,6 +1748,9 @@ func (p *Parser) parsePrimary() Expr {
 		// Check if it's a type constructor
 		if tok.Type.IsType() {
 			p.advance()
+			if p.check(TokenLBracket) {
+				return p.parseArrayConstructor(tok)
+			}
 			// Must be followed by '(' for constructor
 			if p.check(TokenLParen) {
 				p.advance()
@@ -1537,6 +1791,36 @@ func (p *Parser) parsePrimary() Expr {
 	}
 }
 
+// parseArrayConstructor parses an array constructor such as
+// float[3](1.0, 2.0, 3.0) or vec2[](a, b), after its element type.
+func (p *Parser) parseArrayConstructor(typeTok Token) *ArrayExpr {
+	typeSpec := &TypeSpec{
+		Range: p.tokenRange(typeTok),
+		Name:  typeTok.Literal,
+	}
+	typeSpec.ArraySize, typeSpec.Unsized = p.parseArraySuffix()
+
+	arr := &ArrayExpr{
+		Range: p.tokenRange(typeTok),
+		Type:  typeSpec,
+	}
+	if !p.expect(TokenLParen, "expected '(' after array constructor type") {
+		return arr
+	}
+
+	for !p.check(TokenRParen) && !p.isAtEnd() {
+		arr.Elements = append(arr.Elements, p.parseExpression())
+		if !p.match(TokenComma) {
+			break
+		}
+	}
+
+	endTok := p.current()
+	p.expect(TokenRParen, "expected ')' after array constructor arguments")
+	arr.Range.End = Position{Line: endTok.Line - 1, Column: endTok.Column}
+	return arr
+}
+
 // parseArrayInitializer parses an array initializer { ... }.
 func (p *Parser) parseArrayInitializer() *ArrayExpr {
 	start := p.current()
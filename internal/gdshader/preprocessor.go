@@ -0,0 +1,132 @@
+package gdshader
+
+import (
+	"regexp"
+	"strings"
+)
+
+// knownPragmas are the #pragma directives Godot understands.
+var knownPragmas = map[string]bool{
+	"disable_preprocessor": true,
+}
+
+var (
+	macroNamePattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	macroParamsPattern = regexp.MustCompile(`^\(\s*(?:[A-Za-z_][A-Za-z0-9_]*\s*(?:,\s*[A-Za-z_][A-Za-z0-9_]*\s*)*)?\)`)
+	includePathPattern = regexp.MustCompile(`^"[^"]+"$`)
+)
+
+// registerMacros defines the macros of the #define directives of a
+// document, so that their uses are not reported as undefined. Macros are not
+// expanded, their uses have no type.
+func (a *Analyzer) registerMacros(doc *ShaderDocument) {
+	for _, d := range doc.Directives {
+		if d.Name != "define" {
+			continue
+		}
+		name := macroNamePattern.FindString(d.Args)
+		if name == "" {
+			continue
+		}
+		nameRange := Range{Start: d.ArgsStart, End: d.ArgsStart}
+		nameRange.End.Column += len(name)
+		// The same macro is often defined in several branches of a conditional
+		_ = a.globalScope.define(&Symbol{
+			Name:      name,
+			Type:      TypeError,
+			Kind:      SymbolMacro,
+			Range:     d.Range,
+			NameRange: nameRange,
+			Constant:  true,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// checkDirectives validates the syntax of the preprocessor directives and
+// the nesting of the conditionals, even though they are not expanded.
+func (a *Analyzer) checkDirectives() {
+	type conditional struct {
+		directive *Directive
+		sawElse   bool
+	}
+	var open []*conditional
+
+	for _, d := range a.doc.Directives {
+		switch d.Name {
+		case "":
+			a.addError(d.Range, "expected a preprocessor directive after '#'").Code = CodePreprocessor
+		case "define":
+			name := macroNamePattern.FindString(d.Args)
+			if name == "" {
+				a.addError(d.Range, "expected a macro name after #define").Code = CodePreprocessor
+				break
+			}
+			if rest := d.Args[len(name):]; strings.HasPrefix(rest, "(") && !macroParamsPattern.MatchString(rest) {
+				a.addError(d.Range, "invalid parameter list for macro '%s'", name).Code = CodePreprocessor
+			}
+		case "undef", "ifdef", "ifndef":
+			a.checkMacroNameArg(d)
+			if d.Name != "undef" {
+				open = append(open, &conditional{directive: d})
+			}
+		case "if":
+			if d.Args == "" {
+				a.addError(d.Range, "expected a condition after #if").Code = CodePreprocessor
+			}
+			open = append(open, &conditional{directive: d})
+		case "elif":
+			if d.Args == "" {
+				a.addError(d.Range, "expected a condition after #elif").Code = CodePreprocessor
+			}
+			if len(open) == 0 {
+				a.addError(d.Range, "#elif without #if").Code = CodePreprocessor
+			} else if open[len(open)-1].sawElse {
+				a.addError(d.Range, "#elif after #else").Code = CodePreprocessor
+			}
+		case "else", "endif":
+			if d.Args != "" {
+				a.addError(d.Range, "unexpected '%s' after #%s", d.Args, d.Name).Code = CodePreprocessor
+			}
+			switch {
+			case len(open) == 0:
+				a.addError(d.Range, "#%s without #if", d.Name).Code = CodePreprocessor
+			case d.Name == "endif":
+				open = open[:len(open)-1]
+			case open[len(open)-1].sawElse:
+				a.addError(d.Range, "#else after #else").Code = CodePreprocessor
+			default:
+				open[len(open)-1].sawElse = true
+			}
+		case "include":
+			if !includePathPattern.MatchString(d.Args) {
+				a.addError(d.Range, "expected a quoted path after #include").Code = CodePreprocessor
+			}
+		case "pragma":
+			if d.Args == "" {
+				a.addError(d.Range, "expected a pragma name after #pragma").Code = CodePreprocessor
+			} else if !knownPragmas[d.Args] {
+				a.addError(d.Range, "unknown pragma '%s'", d.Args).Code = CodePreprocessor
+			}
+		case "error":
+			// The message is free text
+		default:
+			a.addError(d.NameRange, "unknown preprocessor directive '#%s'", d.Name).Code = CodePreprocessor
+		}
+	}
+
+	for _, c := range open {
+		a.addError(c.directive.Range, "#%s without #endif", c.directive.Name).Code = CodePreprocessor
+	}
+}
+
+// checkMacroNameArg checks that a directive has a single macro name as its
+// argument.
+func (a *Analyzer) checkMacroNameArg(d *Directive) {
+	name := macroNamePattern.FindString(d.Args)
+	if name == "" {
+		a.addError(d.Range, "expected a macro name after #%s", d.Name).Code = CodePreprocessor
+	} else if rest := strings.TrimSpace(d.Args[len(name):]); rest != "" {
+		a.addError(d.Range, "unexpected '%s' after the macro name", rest).Code = CodePreprocessor
+	}
+}
@@ -0,0 +1,79 @@
+package gdshader
+
+// analyzeStmts analyzes the statements of a block or case clause, warning
+// once about the statements that follow one that always leaves the block.
+func (a *Analyzer) analyzeStmts(stmts []Stmt) {
+	for i, st := range stmts {
+		a.capture(st.GetRange().Start)
+		a.analyzeStmt(st)
+		if i+1 < len(stmts) && terminates(st) {
+			a.addWarning(Range{
+				Start: stmts[i+1].GetRange().Start,
+				End:   stmts[len(stmts)-1].GetRange().End,
+			}, "unreachable code").Code = CodeUnreachable
+			for _, rest := range stmts[i+1:] {
+				a.analyzeStmt(rest)
+			}
+			return
+		}
+	}
+}
+
+// terminates reports whether control never reaches the statement after
+// stmt: it returns, discards, breaks or continues on every path.
+func terminates(stmt Stmt) bool {
+	switch s := stmt.(type) {
+	case *ReturnStmt, *DiscardStmt, *BreakStmt, *ContinueStmt:
+		return true
+	case *BlockStmt:
+		for _, st := range s.Stmts {
+			if terminates(st) {
+				return true
+			}
+		}
+	case *IfStmt:
+		return s.Else != nil && terminates(s.Then) && terminates(s.Else)
+	}
+	return false
+}
+
+// constBool returns the value of a condition that is a boolean literal,
+// possibly negated. Named constants are not folded, as toggling a debug
+// constant is a common way to disable code.
+func constBool(expr Expr) (value, ok bool) {
+	switch e := expr.(type) {
+	case *LiteralExpr:
+		if e.Kind == "bool" {
+			return e.Value == "true", true
+		}
+	case *UnaryExpr:
+		if e.Operator == "!" {
+			if v, ok := constBool(e.Operand); ok {
+				return !v, true
+			}
+		}
+	}
+	return false, false
+}
+
+// checkIfCondition warns about an if statement with a constant condition,
+// which leaves one of its branches dead.
+func (a *Analyzer) checkIfCondition(s *IfStmt) {
+	value, ok := constBool(s.Cond)
+	switch {
+	case !ok:
+	case !value:
+		a.addWarning(s.Cond.GetRange(), "condition is always false; the branch is never taken").Code = CodeConstantCondition
+	case s.Else != nil:
+		a.addWarning(s.Cond.GetRange(), "condition is always true; the else branch is never taken").Code = CodeConstantCondition
+	}
+}
+
+// checkLoopCondition warns about a while or for loop whose condition is
+// always false. A do-while loop still runs once, so do { } while (false)
+// is left alone.
+func (a *Analyzer) checkLoopCondition(cond Expr) {
+	if value, ok := constBool(cond); ok && !value {
+		a.addWarning(cond.GetRange(), "loop condition is always false; the body never runs").Code = CodeConstantCondition
+	}
+}
@@ -0,0 +1,108 @@
+package gdshader
+
+// RenderMode is a mode a shader type accepts in its render_mode declaration.
+type RenderMode struct {
+	Name        string
+	Description string
+	Since       Version // Godot version that added it; zero for 4.0
+}
+
+// renderModes lists the render modes of each shader type.
+var renderModes = map[ShaderType][]*RenderMode{
+	ShaderTypeSpatial: {
+		{Name: "blend_mix", Description: "Mix blend mode (alpha is transparency), default"},
+		{Name: "blend_add", Description: "Additive blend mode"},
+		{Name: "blend_sub", Description: "Subtractive blend mode"},
+		{Name: "blend_mul", Description: "Multiplicative blend mode"},
+		{Name: "blend_premul_alpha", Description: "Premultiplied alpha blend mode"},
+		{Name: "depth_draw_opaque", Description: "Only draw depth for opaque geometry, default"},
+		{Name: "depth_draw_always", Description: "Always draw depth (opaque and transparent)"},
+		{Name: "depth_draw_never", Description: "Never draw depth"},
+		{Name: "depth_prepass_alpha", Description: "Do opaque depth pre-pass for transparent geometry"},
+		{Name: "depth_test_disabled", Description: "Disable depth testing"},
+		{Name: "sss_mode_skin", Description: "Subsurface scattering mode for skin"},
+		{Name: "cull_back", Description: "Cull back faces, default"},
+		{Name: "cull_front", Description: "Cull front faces"},
+		{Name: "cull_disabled", Description: "Culling disabled (double sided)"},
+		{Name: "unshaded", Description: "Result is just albedo, no lighting or shading"},
+		{Name: "wireframe", Description: "Geometry draws using lines"},
+		{Name: "debug_shadow_splits", Description: "Directional shadows are drawn using different colors for each split"},
+		{Name: "diffuse_burley", Description: "Burley (Disney PBS) for diffuse, default"},
+		{Name: "diffuse_lambert", Description: "Lambert shading for diffuse"},
+		{Name: "diffuse_lambert_wrap", Description: "Lambert wrapping (roughness dependent) for diffuse"},
+		{Name: "diffuse_toon", Description: "Toon shading for diffuse"},
+		{Name: "specular_schlick_ggx", Description: "Schlick-GGX for direct light specular lobes, default"},
+		{Name: "specular_toon", Description: "Toon for direct light specular lobes"},
+		{Name: "specular_disabled", Description: "Disable direct light specular lobes"},
+		{Name: "skip_vertex_transform", Description: "VERTEX, NORMAL, TANGENT and BITANGENT need to be transformed manually in the vertex function"},
+		{Name: "world_vertex_coords", Description: "VERTEX, NORMAL, TANGENT and BITANGENT are modified in world space instead of model space"},
+		{Name: "ensure_correct_normals", Description: "Use when non-uniform scale is applied to the mesh"},
+		{Name: "shadows_disabled", Description: "Disable computing shadows in the shader"},
+		{Name: "ambient_light_disabled", Description: "Disable contribution from ambient light and radiance map"},
+		{Name: "shadow_to_opacity", Description: "Lighting modifies the alpha so shadowed areas are opaque and non-shadowed areas are transparent"},
+		{Name: "vertex_lighting", Description: "Use vertex-based lighting instead of per-pixel lighting"},
+		{Name: "particle_trails", Description: "Enables the trails when used on particles geometry"},
+		{Name: "alpha_to_coverage", Description: "Alpha antialiasing mode"},
+		{Name: "alpha_to_coverage_and_one", Description: "Alpha antialiasing mode"},
+		{Name: "fog_disabled", Description: "Disable receiving depth-based or volumetric fog"},
+		{Name: "specular_occlusion_disabled", Description: "Disable specular occlusion", Since: Version{4, 4}},
+	},
+	ShaderTypeCanvasItem: {
+		{Name: "blend_mix", Description: "Mix blend mode (alpha is transparency), default"},
+		{Name: "blend_add", Description: "Additive blend mode"},
+		{Name: "blend_sub", Description: "Subtractive blend mode"},
+		{Name: "blend_mul", Description: "Multiplicative blend mode"},
+		{Name: "blend_premul_alpha", Description: "Premultiplied alpha blend mode"},
+		{Name: "blend_disabled", Description: "Disable blending, values (including alpha) are written as-is"},
+		{Name: "unshaded", Description: "Result is just albedo, no lighting or shading"},
+		{Name: "light_only", Description: "Only draw on light pass"},
+		{Name: "skip_vertex_transform", Description: "VERTEX needs to be transformed manually in the vertex function"},
+		{Name: "world_vertex_coords", Description: "VERTEX is modified in world coordinates instead of local"},
+	},
+	ShaderTypeParticles: {
+		{Name: "keep_data", Description: "Do not clear previous data on restart"},
+		{Name: "disable_force", Description: "Disable attractor force"},
+		{Name: "disable_velocity", Description: "Ignore VELOCITY value"},
+		{Name: "collision_use_scale", Description: "Scale the particle's size for collisions"},
+	},
+	ShaderTypeSky: {
+		{Name: "use_half_res_pass", Description: "Allows the shader to write to and access the half resolution pass"},
+		{Name: "use_quarter_res_pass", Description: "Allows the shader to write to and access the quarter resolution pass"},
+		{Name: "disable_fog", Description: "Fog will not affect the sky"},
+	},
+}
+
+// GetRenderModes returns the render modes a shader type accepts in a Godot
+// version, by name.
+func GetRenderModes(version Version, shaderType ShaderType) map[string]*RenderMode {
+	modes := make(map[string]*RenderMode)
+	for _, mode := range renderModes[shaderType] {
+		if version.available(mode.Since) {
+			modes[mode.Name] = mode
+		}
+	}
+	return modes
+}
+
+// RenderModeShaderTypes returns the shader types that accept a render mode
+// in a Godot version, in the order of ShaderTypes.
+func RenderModeShaderTypes(version Version, name string) []ShaderType {
+	var types []ShaderType
+	for _, shaderType := range ShaderTypes {
+		if _, ok := GetRenderModes(version, shaderType)[name]; ok {
+			types = append(types, shaderType)
+		}
+	}
+	return types
+}
+
+// renderModeSince returns the version that added a render mode of a shader
+// type, if it is one.
+func renderModeSince(shaderType ShaderType, name string) (Version, bool) {
+	for _, mode := range renderModes[shaderType] {
+		if mode.Name == name {
+			return mode.Since, true
+		}
+	}
+	return Version{}, false
+}
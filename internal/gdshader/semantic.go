@@ -1,8 +1,10 @@
 package gdshader
 
 import (
+	"errors"
 	"fmt"
-	"strconv"
+	"path"
+	"slices"
 	"strings"
 )
 
@@ -10,8 +12,44 @@ import (
 type SemanticError struct {
 	Message string
 	Range   Range
+	Warning bool          // Valid code that is likely a mistake, e.g. unreachable code
+	Code    string        // Kind of error, one of the Code constants, or empty
+	Related []RelatedInfo // Other places the error is about
 }
 
+// RelatedInfo is a place in the shader that explains an error, such as the
+// first definition of a symbol defined twice.
+type RelatedInfo struct {
+	URI     string // Document of the range, "" for the analyzed one
+	Range   Range
+	Message string
+}
+
+// Codes of the kinds of errors editors may want to tell apart, e.g. to
+// offer fixes or change their severity. Other errors have no code.
+const (
+	CodeUndefined         = "undefined-symbol"    // Unknown variable, function or type
+	CodeRedefined         = "redefined-symbol"    // Name already defined in the scope
+	CodeUnavailable       = "unavailable-builtin" // Built-in used outside its stage
+	CodeRequiresVersion   = "requires-version"    // Feature newer than the targeted Godot version
+	CodeUnreachable       = "unreachable-code"
+	CodeConstantCondition = "constant-condition"
+	CodePreprocessor      = "preprocessor"  // Malformed or unknown directive
+	CodeNotConstant       = "not-constant"  // Non-constant value where a constant is required
+	CodeVaryingStage      = "varying-stage" // Varying assigned or read in the wrong processor function
+	CodeRecursion         = "recursion"
+	CodeDeclarationOrder  = "declaration-order"   // Function called above its declaration
+	CodeMissingEntryPoint = "missing-entry-point" // No processor function for the shader type
+	CodeRemovedBuiltin    = "removed-builtin"     // Godot 3 built-in removed in Godot 4
+	CodeMissingShaderType = "missing-shader-type"
+	CodeCircularInclude   = "circular-include" // File including itself through #include
+
+	// Lints, see lints.go
+	CodeImplicitConversion = "implicit-conversion"
+	CodeFloatEquality      = "float-equality"
+	CodeVectorTruncation   = "vector-truncation"
+)
+
 func (e *SemanticError) Error() string {
 	return fmt.Sprintf("%d:%d: %s", e.Range.Start.Line+1, e.Range.Start.Column+1, e.Message)
 }
@@ -22,11 +60,20 @@ type Symbol struct {
 	Type       *Type
 	Kind       SymbolKind
 	Range      Range
+	NameRange  Range           // The declared name, where go-to-definition lands
 	Constant   bool            // For const variables
+	Value      Expr            // The initializer of a constant, for folding
 	ReadOnly   bool            // For built-in input variables
 	WriteOnly  bool            // For built-in output variables
 	Qualifiers []string        // in, out, inout, uniform, varying, etc.
 	Function   *FunctionSymbol // For function symbols
+	Overloads  []*Symbol       // Further declarations of an overloaded function
+	URI        string          // File declaring it when included, "" for the analyzed one
+}
+
+// overloads returns every declaration of a function symbol, in order.
+func (s *Symbol) overloads() []*Symbol {
+	return append([]*Symbol{s}, s.Overloads...)
 }
 
 // SymbolKind represents the kind of symbol.
@@ -42,6 +89,7 @@ const (
 	SymbolParameter
 	SymbolBuiltinVariable
 	SymbolBuiltinFunction
+	SymbolMacro // #define
 )
 
 // FunctionSymbol holds additional information for function symbols.
@@ -66,12 +114,24 @@ func newScope(parent *Scope) *Scope {
 
 func (s *Scope) define(sym *Symbol) error {
 	if existing, ok := s.symbols[sym.Name]; ok {
-		return fmt.Errorf("symbol '%s' already defined at line %d", sym.Name, existing.Range.Start.Line+1)
+		return &redefinedError{existing: existing}
 	}
 	s.symbols[sym.Name] = sym
 	return nil
 }
 
+// redefinedError is returned when a name is defined twice in a scope.
+type redefinedError struct {
+	existing *Symbol
+}
+
+func (e *redefinedError) Error() string {
+	if e.existing.URI != "" {
+		return fmt.Sprintf("symbol '%s' already defined in '%s'", e.existing.Name, path.Base(e.existing.URI))
+	}
+	return fmt.Sprintf("symbol '%s' already defined at line %d", e.existing.Name, e.existing.Range.Start.Line+1)
+}
+
 func (s *Scope) lookup(name string) *Symbol {
 	if sym, ok := s.symbols[name]; ok {
 		return sym
@@ -90,10 +150,20 @@ type Analyzer struct {
 	globalScope  *Scope
 	errors       []*SemanticError
 	currentFunc  *FunctionDecl
-	currentStage string // "vertex", "fragment", "light", etc.
+	currentStage string  // "vertex", "fragment", "light", etc.
+	version      Version // Godot version the built-ins are checked against
 	structs      map[string]*Type
 	loopDepth    int
 	switchDepth  int
+	captureAt    *Position // Where SymbolsAt collects the visible symbols
+	captured     []*Symbol
+	memberAt     *Position // Where MemberBaseTypeAt looks for a member access
+	memberBase   *Type
+	types        map[Expr]*Type   // Type of each analyzed expression
+	symbols      map[Node]*Symbol // Symbol each identifier or declaration refers to
+	uri          string           // URI of the document, for resolving includes
+	resolve      IncludeResolver
+	included     []string // URIs of the included files
 }
 
 // NewAnalyzer creates a new semantic analyzer.
@@ -101,19 +171,29 @@ func NewAnalyzer(doc *ShaderDocument) *Analyzer {
 	a := &Analyzer{
 		doc:     doc,
 		structs: make(map[string]*Type),
+		types:   make(map[Expr]*Type),
+		symbols: make(map[Node]*Symbol),
+		version: LatestVersion,
 	}
 	a.globalScope = newScope(nil)
 	a.currentScope = a.globalScope
 	return a
 }
 
+// SetVersion sets the Godot version whose built-ins, render modes and
+// uniform hints are available. It defaults to LatestVersion.
+func (a *Analyzer) SetVersion(v Version) {
+	a.version = v
+}
+
 // Analyze performs semantic analysis and returns any errors.
 func (a *Analyzer) Analyze() []*SemanticError {
 	// Determine shader type
 	if a.doc.ShaderType != nil {
 		a.shaderType = ShaderType(a.doc.ShaderType.Type)
+		a.checkRenderModes()
 	} else {
-		a.addError(Range{Start: Position{Line: 0, Column: 0}}, "missing shader_type declaration")
+		a.addError(Range{Start: Position{Line: 0, Column: 0}}, "missing shader_type declaration").Code = CodeMissingShaderType
 	}
 
 	// Register built-in constants (ignore redefinition errors for builtins)
@@ -127,6 +207,10 @@ func (a *Analyzer) Analyze() []*SemanticError {
 		})
 	}
 
+	a.checkDirectives()
+	a.registerMacros(a.doc)
+	a.registerIncludes()
+
 	// First pass: register all struct types
 	for _, structDecl := range a.doc.Structs {
 		a.registerStruct(structDecl)
@@ -150,15 +234,60 @@ func (a *Analyzer) Analyze() []*SemanticError {
 	for _, funcDecl := range a.doc.Functions {
 		a.analyzeFunction(funcDecl)
 	}
+	a.checkVaryings()
+	a.checkFunctions()
 
 	return a.errors
 }
 
-func (a *Analyzer) addError(rng Range, format string, args ...interface{}) {
-	a.errors = append(a.errors, &SemanticError{
+// checkRenderModes checks that the render modes exist for the shader type
+// in the targeted Godot version.
+func (a *Analyzer) checkRenderModes() {
+	decl := a.doc.RenderModes
+	if decl == nil {
+		return
+	}
+	modes := GetRenderModes(a.version, a.shaderType)
+	for i, mode := range decl.Modes {
+		if _, ok := modes[mode]; ok {
+			continue
+		}
+		rng := decl.Range
+		if i < len(decl.ModeRanges) {
+			rng = decl.ModeRanges[i]
+		}
+		if since, ok := renderModeSince(a.shaderType, mode); ok {
+			a.addError(rng, "render mode '%s' requires Godot %s or later", mode, since).Code = CodeRequiresVersion
+		} else {
+			a.addError(rng, "unknown render mode '%s' for %s shaders", mode, a.shaderType)
+		}
+	}
+}
+
+func (a *Analyzer) addError(rng Range, format string, args ...interface{}) *SemanticError {
+	err := &SemanticError{
 		Message: fmt.Sprintf(format, args...),
 		Range:   rng,
-	})
+	}
+	a.errors = append(a.errors, err)
+	return err
+}
+
+func (a *Analyzer) addWarning(rng Range, format string, args ...interface{}) *SemanticError {
+	err := a.addError(rng, format, args...)
+	err.Warning = true
+	return err
+}
+
+// addRedefinition reports a symbol defined twice, pointing at the first
+// definition unless it is a built-in.
+func (a *Analyzer) addRedefinition(rng Range, err error) {
+	e := a.addError(rng, "%s", err.Error())
+	e.Code = CodeRedefined
+	var redefined *redefinedError
+	if errors.As(err, &redefined) && redefined.existing.NameRange != (Range{}) {
+		e.Related = append(e.Related, RelatedInfo{URI: redefined.existing.URI, Range: redefined.existing.NameRange, Message: "First defined here"})
+	}
 }
 
 func (a *Analyzer) enterScope() {
@@ -172,7 +301,14 @@ func (a *Analyzer) exitScope() {
 // registerStruct registers a struct type.
 func (a *Analyzer) registerStruct(decl *StructDecl) {
 	if _, exists := a.structs[decl.Name]; exists {
-		a.addError(decl.Range, "struct '%s' already defined", decl.Name)
+		err := a.addError(decl.Range, "struct '%s' already defined", decl.Name)
+		err.Code = CodeRedefined
+		for _, other := range a.doc.Structs {
+			if other.Name == decl.Name && other != decl {
+				err.Related = append(err.Related, RelatedInfo{Range: other.NameRange, Message: "First defined here"})
+				break
+			}
+		}
 		return
 	}
 
@@ -180,9 +316,14 @@ func (a *Analyzer) registerStruct(decl *StructDecl) {
 	for _, member := range decl.Members {
 		fieldType := a.resolveType(member.Type)
 		if fieldType == nil {
-			a.addError(member.Type.Range, "unknown type '%s'", member.Type.Name)
+			a.addError(member.Type.Range, "unknown type '%s'", member.Type.Name).Code = CodeUndefined
 			fieldType = TypeError
 		}
+		a.checkPrecision(member.Type, fieldType)
+		a.checkArraySize(member.Type.ArraySize)
+		if member.Type.Unsized {
+			a.addError(member.Range, "array member '%s' must have a size", member.Name)
+		}
 		fields = append(fields, &Field{
 			Name: member.Name,
 			Type: fieldType,
@@ -193,10 +334,11 @@ func (a *Analyzer) registerStruct(decl *StructDecl) {
 	a.structs[decl.Name] = structType
 
 	_ = a.globalScope.define(&Symbol{
-		Name:  decl.Name,
-		Type:  structType,
-		Kind:  SymbolStruct,
-		Range: decl.Range,
+		Name:      decl.Name,
+		Type:      structType,
+		Kind:      SymbolStruct,
+		Range:     decl.Range,
+		NameRange: decl.NameRange,
 	})
 }
 
@@ -204,30 +346,57 @@ func (a *Analyzer) registerStruct(decl *StructDecl) {
 func (a *Analyzer) registerUniform(decl *UniformDecl) {
 	varType := a.resolveType(decl.Type)
 	if varType == nil {
-		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name)
+		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name).Code = CodeUndefined
 		varType = TypeError
 	}
+	a.checkPrecision(decl.Type, varType)
+	a.checkArraySize(decl.Type.ArraySize)
+
+	if varType.Kind == TypeKindArray {
+		if decl.Type.ArraySize == nil {
+			a.addError(decl.Type.Range, "uniform array size must be a positive integer constant")
+		}
+		if varType.ElementType.IsSampler() && decl.DefaultValue != nil {
+			a.addError(decl.DefaultValue.GetRange(), "sampler arrays cannot have a default value")
+		}
+	}
+	if decl.DefaultValue != nil {
+		a.requireConstant(decl.DefaultValue, "uniform default value")
+		// Defaults are not analyzed, as they may refer to later constants
+		if _, ok := intLiteralText(decl.DefaultValue); ok {
+			a.lintConversion(decl.DefaultValue, TypeInt, varType)
+		}
+	}
+
+	a.checkHints(decl, varType)
 
 	if err := a.globalScope.define(&Symbol{
 		Name:       decl.Name,
 		Type:       varType,
 		Kind:       SymbolUniform,
 		Range:      decl.Range,
+		NameRange:  decl.NameRange,
 		ReadOnly:   true,
 		Qualifiers: []string{"uniform"},
 	}); err != nil {
-		a.addError(decl.Range, "%s", err.Error())
+		a.addRedefinition(decl.Range, err)
 	}
 }
 
 // registerVarying registers a varying variable.
 func (a *Analyzer) registerVarying(decl *VaryingDecl) {
+	if decl.Type == nil {
+		return // Parse error
+	}
 	varType := a.resolveType(decl.Type)
 	if varType == nil {
-		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name)
+		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name).Code = CodeUndefined
 		varType = TypeError
 	}
 
+	a.checkPrecision(decl.Type, varType)
+	a.checkArraySize(decl.Type.ArraySize)
+
 	qualifiers := []string{"varying"}
 	if decl.Interpolation != "" {
 		qualifiers = append(qualifiers, decl.Interpolation)
@@ -238,9 +407,10 @@ func (a *Analyzer) registerVarying(decl *VaryingDecl) {
 		Type:       varType,
 		Kind:       SymbolVarying,
 		Range:      decl.Range,
+		NameRange:  decl.NameRange,
 		Qualifiers: qualifiers,
 	}); err != nil {
-		a.addError(decl.Range, "%s", err.Error())
+		a.addRedefinition(decl.Range, err)
 	}
 }
 
@@ -248,16 +418,22 @@ func (a *Analyzer) registerVarying(decl *VaryingDecl) {
 func (a *Analyzer) registerConstant(decl *ConstDecl) {
 	varType := a.resolveType(decl.Type)
 	if varType == nil {
-		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name)
+		a.addError(decl.Type.Range, "unknown type '%s'", decl.Type.Name).Code = CodeUndefined
 		varType = TypeError
 	}
+	a.checkPrecision(decl.Type, varType)
+	a.checkArraySize(decl.Type.ArraySize)
 
 	// Analyze the initializer
 	if decl.Value != nil {
 		initType := a.analyzeExpr(decl.Value)
-		if !varType.Equals(initType) && !CanImplicitlyConvert(initType, varType) {
+		a.requireConstant(decl.Value, "const initializer")
+		varType = sizeFromInit(varType, initType)
+		if initType.Kind != TypeKindError && !varType.Equals(initType) && !CanImplicitlyConvert(initType, varType) {
 			a.addError(decl.Range, "cannot initialize '%s' of type '%s' with '%s'",
 				decl.Name, varType.String(), initType.String())
+		} else {
+			a.lintConversion(decl.Value, initType, varType)
 		}
 	}
 
@@ -266,11 +442,13 @@ func (a *Analyzer) registerConstant(decl *ConstDecl) {
 		Type:       varType,
 		Kind:       SymbolConstant,
 		Range:      decl.Range,
+		NameRange:  decl.NameRange,
 		Constant:   true,
 		ReadOnly:   true,
+		Value:      decl.Value,
 		Qualifiers: []string{"const"},
 	}); err != nil {
-		a.addError(decl.Range, "%s", err.Error())
+		a.addRedefinition(decl.Range, err)
 	}
 }
 
@@ -278,63 +456,92 @@ func (a *Analyzer) registerConstant(decl *ConstDecl) {
 func (a *Analyzer) registerFunction(decl *FunctionDecl) {
 	returnType := a.resolveType(decl.ReturnType)
 	if returnType == nil {
-		a.addError(decl.ReturnType.Range, "unknown type '%s'", decl.ReturnType.Name)
+		a.addError(decl.ReturnType.Range, "unknown type '%s'", decl.ReturnType.Name).Code = CodeUndefined
 		returnType = TypeError
 	}
+	a.checkPrecision(decl.ReturnType, returnType)
+	a.checkArraySize(decl.ReturnType.ArraySize)
 
 	params := make([]*Symbol, 0, len(decl.Params))
 	for _, param := range decl.Params {
 		paramType := a.resolveType(param.Type)
 		if paramType == nil {
-			a.addError(param.Type.Range, "unknown type '%s'", param.Type.Name)
+			a.addError(param.Type.Range, "unknown type '%s'", param.Type.Name).Code = CodeUndefined
 			paramType = TypeError
 		}
+		a.checkPrecision(param.Type, paramType)
+		a.checkArraySize(param.Type.ArraySize)
 		params = append(params, &Symbol{
 			Name:       param.Name,
 			Type:       paramType,
 			Kind:       SymbolParameter,
 			Range:      param.Range,
+			NameRange:  param.NameRange,
 			Qualifiers: []string{param.Qualifier},
 		})
 	}
 
 	funcSym := &Symbol{
-		Name:  decl.Name,
-		Type:  returnType,
-		Kind:  SymbolFunction,
-		Range: decl.Range,
+		Name:      decl.Name,
+		Type:      returnType,
+		Kind:      SymbolFunction,
+		Range:     decl.Range,
+		NameRange: decl.NameRange,
 		Function: &FunctionSymbol{
 			Params:     params,
 			ReturnType: returnType,
 		},
 	}
 
+	// Functions may be overloaded with different parameter types
+	if existing, ok := a.globalScope.symbols[decl.Name]; ok && existing.Kind == SymbolFunction {
+		for _, overload := range existing.overloads() {
+			if sameParamTypes(overload.Function.Params, params) {
+				var err *SemanticError
+				if overload.URI != "" {
+					err = a.addError(decl.Range, "function '%s' with the same parameters already defined in '%s'",
+						decl.Name, path.Base(overload.URI))
+				} else {
+					err = a.addError(decl.Range, "function '%s' with the same parameters already defined at line %d",
+						decl.Name, overload.Range.Start.Line+1)
+				}
+				err.Code = CodeRedefined
+				err.Related = append(err.Related, RelatedInfo{URI: overload.URI, Range: overload.NameRange, Message: "First defined here"})
+				return
+			}
+		}
+		existing.Overloads = append(existing.Overloads, funcSym)
+		return
+	}
+
 	if err := a.globalScope.define(funcSym); err != nil {
-		a.addError(decl.Range, "%s", err.Error())
+		a.addRedefinition(decl.Range, err)
 	}
 }
 
+// sameParamTypes reports whether two parameter lists have the same types,
+// which overloads of a function may not.
+func sameParamTypes(a, b []*Symbol) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Type.Equals(b[i].Type) {
+			return false
+		}
+	}
+	return true
+}
+
 // analyzeFunction analyzes a function body.
 func (a *Analyzer) analyzeFunction(decl *FunctionDecl) {
 	a.currentFunc = decl
 	a.enterScope()
 
-	// Determine the stage from function name
-	switch decl.Name {
-	case "vertex":
-		a.currentStage = "vertex"
-	case "fragment":
-		a.currentStage = "fragment"
-	case "light":
-		a.currentStage = "light"
-	case "start", "process":
-		a.currentStage = "compute"
-	case "sky":
-		a.currentStage = "sky"
-	case "fog":
-		a.currentStage = "fog"
-	default:
-		a.currentStage = ""
+	// Processor functions are named after their stage
+	a.currentStage = ""
+	if slices.Contains(ShaderStages[a.shaderType], decl.Name) {
+		a.currentStage = decl.Name
 	}
 
 	// Register built-in variables for this stage
@@ -346,15 +553,18 @@ func (a *Analyzer) analyzeFunction(decl *FunctionDecl) {
 		if paramType == nil {
 			paramType = TypeError
 		}
-		_ = a.currentScope.define(&Symbol{
+		sym := &Symbol{
 			Name:       param.Name,
 			Type:       paramType,
 			Kind:       SymbolParameter,
 			Range:      param.Range,
+			NameRange:  param.NameRange,
 			Qualifiers: []string{param.Qualifier},
 			ReadOnly:   param.Qualifier == "in",
 			WriteOnly:  param.Qualifier == "out",
-		})
+		}
+		a.symbols[param] = sym
+		_ = a.currentScope.define(sym)
 	}
 
 	// Analyze function body
@@ -369,44 +579,7 @@ func (a *Analyzer) analyzeFunction(decl *FunctionDecl) {
 
 // registerBuiltinVariables registers built-in variables for the current shader type and stage.
 func (a *Analyzer) registerBuiltinVariables() {
-	var builtins map[string]*BuiltinVariable
-
-	switch a.shaderType {
-	case ShaderTypeSpatial:
-		switch a.currentStage {
-		case "vertex":
-			builtins = GetSpatialVertexBuiltins()
-		case "fragment":
-			builtins = GetSpatialFragmentBuiltins()
-		case "light":
-			builtins = GetSpatialLightBuiltins()
-		}
-	case ShaderTypeCanvasItem:
-		switch a.currentStage {
-		case "vertex":
-			builtins = GetCanvasItemVertexBuiltins()
-		case "fragment":
-			builtins = GetCanvasItemFragmentBuiltins()
-		case "light":
-			builtins = GetCanvasItemLightBuiltins()
-		}
-	case ShaderTypeParticles:
-		switch a.currentStage {
-		case "start", "process":
-			builtins = GetParticlesBuiltins()
-		}
-	case ShaderTypeSky:
-		switch a.currentStage {
-		case "sky":
-			builtins = GetSkyBuiltins()
-		}
-	case ShaderTypeFog:
-		switch a.currentStage {
-		case "fog":
-			builtins = GetFogBuiltins()
-		}
-	}
-
+	builtins := GetStageBuiltins(a.version, a.shaderType, a.currentStage)
 	for name, builtin := range builtins {
 		varType := TypeFromName(builtin.Type)
 		if varType == nil {
@@ -427,43 +600,53 @@ func (a *Analyzer) resolveType(typeSpec *TypeSpec) *Type {
 		return nil
 	}
 
-	// Check for built-in types
-	if t := TypeFromName(typeSpec.Name); t != nil {
-		if typeSpec.ArraySize != nil {
-			size := a.evaluateConstExpr(typeSpec.ArraySize)
-			return MakeArrayType(t, size)
+	t := TypeFromName(typeSpec.Name)
+	if t == nil {
+		structType, ok := a.structs[typeSpec.Name]
+		if !ok {
+			return nil
 		}
-		return t
+		t = structType
 	}
 
-	// Check for struct types
-	if structType, ok := a.structs[typeSpec.Name]; ok {
-		if typeSpec.ArraySize != nil {
-			size := a.evaluateConstExpr(typeSpec.ArraySize)
-			return MakeArrayType(structType, size)
-		}
-		return structType
+	if typeSpec.ArraySize != nil || typeSpec.Unsized {
+		return MakeArrayType(t, a.evaluateConstExpr(typeSpec.ArraySize))
 	}
+	return t
+}
 
-	return nil
+// checkPrecision reports a precision qualifier on a type it does not apply
+// to. Like the engine, only numeric and sampler types take one.
+func (a *Analyzer) checkPrecision(typeSpec *TypeSpec, t *Type) {
+	if typeSpec.Precision == "" || t == nil {
+		return
+	}
+	if t.Kind == TypeKindArray {
+		t = t.ElementType
+	}
+	switch t.Kind {
+	case TypeKindBool, TypeKindBvec2, TypeKindBvec3, TypeKindBvec4:
+		a.addError(typeSpec.Range, "precision qualifier '%s' cannot be used on boolean types", typeSpec.Precision)
+	case TypeKindStruct:
+		a.addError(typeSpec.Range, "precision qualifier '%s' cannot be used on structs", typeSpec.Precision)
+	case TypeKindVoid:
+		a.addError(typeSpec.Range, "precision qualifier '%s' cannot be used on void", typeSpec.Precision)
+	}
 }
 
-// evaluateConstExpr evaluates a constant expression and returns its integer value.
-func (a *Analyzer) evaluateConstExpr(expr Expr) int {
-	switch e := expr.(type) {
-	case *LiteralExpr:
-		if e.Kind == "int" {
-			val, _ := strconv.Atoi(e.Value)
-			return val
-		}
-	case *IdentExpr:
-		sym := a.currentScope.lookup(e.Name)
-		if sym != nil && sym.Constant {
-			// For now, just return -1 for non-literal constants
-			return -1
-		}
+// sizeFromInit gives an unsized array type the length of its initializer,
+// as in float a[] = {1.0, 2.0}.
+func sizeFromInit(declType, initType *Type) *Type {
+	if declType.Kind != TypeKindArray || declType.ArraySize >= 0 ||
+		initType == nil || initType.Kind != TypeKindArray {
+		return declType
 	}
-	return -1 // Unsized or error
+	return MakeArrayType(declType.ElementType, initType.ArraySize)
+}
+
+// isConstantExpr reports whether expr is a constant expression.
+func (a *Analyzer) isConstantExpr(expr Expr) bool {
+	return a.nonConstantExpr(expr) == nil
 }
 
 // analyzeStmt analyzes a statement.
@@ -475,9 +658,8 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 	switch s := stmt.(type) {
 	case *BlockStmt:
 		a.enterScope()
-		for _, st := range s.Stmts {
-			a.analyzeStmt(st)
-		}
+		a.analyzeStmts(s.Stmts)
+		a.capture(s.Range.End)
 		a.exitScope()
 
 	case *VarDeclStmt:
@@ -491,6 +673,7 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 		if condType.Kind != TypeKindBool {
 			a.addError(s.Cond.GetRange(), "condition must be a boolean expression, got '%s'", condType.String())
 		}
+		a.checkIfCondition(s)
 		a.analyzeStmt(s.Then)
 		if s.Else != nil {
 			a.analyzeStmt(s.Else)
@@ -507,6 +690,7 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 			if condType.Kind != TypeKindBool {
 				a.addError(s.Cond.GetRange(), "for condition must be a boolean expression")
 			}
+			a.checkLoopCondition(s.Cond)
 		}
 		if s.Post != nil {
 			a.analyzeExpr(s.Post)
@@ -520,6 +704,7 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 		if condType.Kind != TypeKindBool {
 			a.addError(s.Cond.GetRange(), "while condition must be a boolean expression")
 		}
+		a.checkLoopCondition(s.Cond)
 		a.loopDepth++
 		a.analyzeStmt(s.Body)
 		a.loopDepth--
@@ -544,11 +729,11 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 				caseType := a.analyzeExpr(val)
 				if !caseType.IsInteger() {
 					a.addError(val.GetRange(), "case value must be an integer")
+				} else {
+					a.requireConstant(val, "case label")
 				}
 			}
-			for _, st := range c.Body {
-				a.analyzeStmt(st)
-			}
+			a.analyzeStmts(c.Body)
 		}
 		a.switchDepth--
 
@@ -562,9 +747,11 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 			exprType := a.analyzeExpr(s.Value)
 			if returnType.Kind == TypeKindVoid {
 				a.addError(s.Range, "void function should not return a value")
-			} else if !returnType.Equals(exprType) && !CanImplicitlyConvert(exprType, returnType) {
+			} else if exprType.Kind != TypeKindError && !returnType.Equals(exprType) && !CanImplicitlyConvert(exprType, returnType) {
 				a.addError(s.Range, "cannot return '%s' from function returning '%s'",
 					exprType.String(), returnType.String())
+			} else {
+				a.lintConversion(s.Value, exprType, returnType)
 			}
 		} else if returnType.Kind != TypeKindVoid {
 			a.addError(s.Range, "non-void function must return a value")
@@ -582,7 +769,7 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 
 	case *DiscardStmt:
 		if a.currentStage != "fragment" {
-			a.addError(s.Range, "discard can only be used in fragment stage")
+			a.addError(s.Range, "discard can only be used in fragment stage").Code = CodeUnavailable
 		}
 
 	case *EmptyStmt:
@@ -594,14 +781,17 @@ func (a *Analyzer) analyzeStmt(stmt Stmt) {
 func (a *Analyzer) analyzeVarDeclStmt(s *VarDeclStmt) {
 	varType := a.resolveType(s.Type)
 	if varType == nil {
-		a.addError(s.Type.Range, "unknown type '%s'", s.Type.Name)
+		a.addError(s.Type.Range, "unknown type '%s'", s.Type.Name).Code = CodeUndefined
 		varType = TypeError
 	}
+	a.checkPrecision(s.Type, varType)
+	a.checkArraySize(s.Type.ArraySize)
 
 	for _, decl := range s.Decls {
 		declType := varType
 		// Handle array declaration
-		if decl.ArraySize != nil {
+		if decl.ArraySize != nil || decl.Unsized {
+			a.checkArraySize(decl.ArraySize)
 			size := a.evaluateConstExpr(decl.ArraySize)
 			declType = MakeArrayType(varType, size)
 		}
@@ -609,31 +799,50 @@ func (a *Analyzer) analyzeVarDeclStmt(s *VarDeclStmt) {
 		// Check for initializer
 		if decl.Init != nil {
 			initType := a.analyzeExpr(decl.Init)
-			if !declType.Equals(initType) && !CanImplicitlyConvert(initType, declType) {
+			if s.Const {
+				a.requireConstant(decl.Init, "const initializer")
+			}
+			declType = sizeFromInit(declType, initType)
+			if initType.Kind != TypeKindError && !declType.Equals(initType) && !CanImplicitlyConvert(initType, declType) {
 				a.addError(decl.Range, "cannot initialize '%s' of type '%s' with '%s'",
 					decl.Name, declType.String(), initType.String())
+			} else {
+				a.lintConversion(decl.Init, initType, declType)
 			}
 		}
 
-		if err := a.currentScope.define(&Symbol{
-			Name:     decl.Name,
-			Type:     declType,
-			Kind:     SymbolVariable,
-			Range:    decl.Range,
-			Constant: s.Const,
-			ReadOnly: s.Const,
-		}); err != nil {
-			a.addError(decl.Range, "%s", err.Error())
+		sym := &Symbol{
+			Name:      decl.Name,
+			Type:      declType,
+			Kind:      SymbolVariable,
+			Range:     decl.Range,
+			NameRange: decl.Range,
+			Constant:  s.Const,
+			ReadOnly:  s.Const,
+		}
+		if s.Const {
+			sym.Value = decl.Init
+		}
+		a.symbols[decl] = sym
+		if err := a.currentScope.define(sym); err != nil {
+			a.addRedefinition(decl.Range, err)
 		}
 	}
 }
 
-// analyzeExpr analyzes an expression and returns its type.
+// analyzeExpr analyzes an expression and returns its type, recording it
+// for TypeOf.
 func (a *Analyzer) analyzeExpr(expr Expr) *Type {
 	if expr == nil {
 		return TypeError
 	}
+	t := a.exprType(expr)
+	a.types[expr] = t
+	return t
+}
 
+// exprType analyzes an expression by kind.
+func (a *Analyzer) exprType(expr Expr) *Type {
 	switch e := expr.(type) {
 	case *LiteralExpr:
 		return a.analyzeLiteral(e)
@@ -690,12 +899,56 @@ func (a *Analyzer) analyzeIdent(e *IdentExpr) *Type {
 
 	sym := a.currentScope.lookup(e.Name)
 	if sym == nil {
-		a.addError(e.Range, "undefined symbol '%s'", e.Name)
+		if a.isStageBuiltin(e.Name) {
+			if a.currentFunc != nil {
+				a.addError(e.Range, "built-in '%s' is not available in the %s function", e.Name, a.currentFunc.Name).Code = CodeUnavailable
+			} else {
+				a.addError(e.Range, "built-in '%s' is only available in processor functions", e.Name).Code = CodeUnavailable
+			}
+			return TypeError
+		}
+		if since, ok := a.newerBuiltin(e.Name); ok {
+			a.addError(e.Range, "built-in '%s' requires Godot %s or later", e.Name, since).Code = CodeRequiresVersion
+			return TypeError
+		}
+		if removed, ok := RemovedBuiltins[e.Name]; ok {
+			if removed.Replacement != "" {
+				a.addError(e.Range, "built-in '%s' was renamed to '%s' in Godot 4", e.Name, removed.Replacement).Code = CodeRemovedBuiltin
+			} else {
+				a.addError(e.Range, "built-in '%s' was removed in Godot 4; declare '%s' and sample it instead",
+					e.Name, removed.Declaration()).Code = CodeRemovedBuiltin
+			}
+			return TypeError
+		}
+		a.addError(e.Range, "undefined symbol '%s'", e.Name).Code = CodeUndefined
 		return TypeError
 	}
+	a.symbols[e] = sym
 	return sym.Type
 }
 
+// isStageBuiltin reports whether name is a built-in variable of any
+// processor function of the shader type.
+func (a *Analyzer) isStageBuiltin(name string) bool {
+	for _, stage := range ShaderStages[a.shaderType] {
+		if _, ok := GetStageBuiltins(a.version, a.shaderType, stage)[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newerBuiltin returns the Godot version that added name, when it is a
+// built-in variable of the shader type the targeted version does not have.
+func (a *Analyzer) newerBuiltin(name string) (Version, bool) {
+	for _, stage := range ShaderStages[a.shaderType] {
+		if builtin, ok := stageBuiltins(a.shaderType, stage)[name]; ok && !a.version.available(builtin.Since) {
+			return builtin.Since, true
+		}
+	}
+	return Version{}, false
+}
+
 // analyzeBinary analyzes a binary expression.
 func (a *Analyzer) analyzeBinary(e *BinaryExpr) *Type {
 	leftType := a.analyzeExpr(e.Left)
@@ -706,9 +959,14 @@ func (a *Analyzer) analyzeBinary(e *BinaryExpr) *Type {
 	// Handle assignment operators
 	if isAssignOp(op) {
 		a.checkAssignable(e.Left)
+		if leftType.Kind == TypeKindError || rightType.Kind == TypeKindError {
+			return leftType // Error already reported
+		}
 		if op == TokenAssign {
 			if !leftType.Equals(rightType) && !CanImplicitlyConvert(rightType, leftType) {
 				a.addError(e.Range, "cannot assign '%s' to '%s'", rightType.String(), leftType.String())
+			} else {
+				a.lintConversion(e.Right, rightType, leftType)
 			}
 		} else {
 			// Compound assignment - check the underlying operation is valid
@@ -717,15 +975,22 @@ func (a *Analyzer) analyzeBinary(e *BinaryExpr) *Type {
 			if resultType.Kind == TypeKindError {
 				a.addError(e.Range, "invalid operands for '%s': '%s' and '%s'",
 					e.Operator, leftType.String(), rightType.String())
+			} else {
+				a.lintOperands(e, leftType, rightType)
 			}
 		}
 		return leftType
 	}
 
+	if leftType.Kind == TypeKindError || rightType.Kind == TypeKindError {
+		return TypeError // Error already reported, or an unexpanded macro
+	}
 	resultType := BinaryOpResultType(op, leftType, rightType)
 	if resultType.Kind == TypeKindError {
 		a.addError(e.Range, "invalid operands for '%s': '%s' and '%s'",
 			e.Operator, leftType.String(), rightType.String())
+	} else {
+		a.lintOperands(e, leftType, rightType)
 	}
 	return resultType
 }
@@ -740,6 +1005,9 @@ func (a *Analyzer) analyzeUnary(e *UnaryExpr) *Type {
 		a.checkAssignable(e.Operand)
 	}
 
+	if operandType.Kind == TypeKindError {
+		return TypeError
+	}
 	resultType := UnaryOpResultType(op, operandType)
 	if resultType.Kind == TypeKindError {
 		a.addError(e.Range, "invalid operand for '%s': '%s'",
@@ -751,7 +1019,7 @@ func (a *Analyzer) analyzeUnary(e *UnaryExpr) *Type {
 // analyzeTernary analyzes a ternary expression.
 func (a *Analyzer) analyzeTernary(e *TernaryExpr) *Type {
 	condType := a.analyzeExpr(e.Cond)
-	if condType.Kind != TypeKindBool {
+	if condType.Kind != TypeKindBool && condType.Kind != TypeKindError {
 		a.addError(e.Cond.GetRange(), "ternary condition must be boolean, got '%s'", condType.String())
 	}
 
@@ -775,13 +1043,12 @@ func (a *Analyzer) analyzeTernary(e *TernaryExpr) *Type {
 
 // analyzeCall analyzes a function call expression.
 func (a *Analyzer) analyzeCall(e *CallExpr) *Type {
-	funcName := ""
-	if ident, ok := e.Func.(*IdentExpr); ok {
-		funcName = ident.Name
-	} else {
+	ident, ok := e.Func.(*IdentExpr)
+	if !ok {
 		a.addError(e.Range, "expected function name")
 		return TypeError
 	}
+	funcName := ident.Name
 
 	// Check for type constructor
 	if IsBuiltinTypeName(funcName) {
@@ -796,7 +1063,18 @@ func (a *Analyzer) analyzeCall(e *CallExpr) *Type {
 	// Check for user-defined function
 	sym := a.currentScope.lookup(funcName)
 	if sym == nil {
-		a.addError(e.Range, "undefined function '%s'", funcName)
+		a.addError(e.Range, "undefined function '%s'", funcName).Code = CodeUndefined
+		return TypeError
+	}
+	if sym.Kind == SymbolStruct {
+		a.symbols[ident] = sym
+		return a.analyzeStructConstructor(sym.Type, e)
+	}
+	if sym.Kind == SymbolMacro {
+		a.symbols[ident] = sym
+		for _, arg := range e.Args {
+			a.analyzeExpr(arg)
+		}
 		return TypeError
 	}
 	if sym.Kind != SymbolFunction || sym.Function == nil {
@@ -804,6 +1082,30 @@ func (a *Analyzer) analyzeCall(e *CallExpr) *Type {
 		return TypeError
 	}
 
+	argTypes := make([]*Type, len(e.Args))
+	for i, arg := range e.Args {
+		argTypes[i] = a.analyzeExpr(arg)
+	}
+
+	if len(sym.Overloads) > 0 {
+		overload := resolveOverload(sym.overloads(), argTypes)
+		if overload == nil && hasErrorType(argTypes) {
+			overload = sym
+		}
+		if overload == nil {
+			names := make([]string, len(argTypes))
+			for i, t := range argTypes {
+				names[i] = t.String()
+			}
+			a.addError(e.Range, "no matching overload for '%s(%s)'",
+				funcName, strings.Join(names, ", "))
+			a.symbols[ident] = sym
+			return sym.Function.ReturnType
+		}
+		sym = overload
+	}
+	a.symbols[ident] = sym
+
 	// Check argument count
 	if len(e.Args) != len(sym.Function.Params) {
 		a.addError(e.Range, "function '%s' expects %d arguments, got %d",
@@ -813,7 +1115,7 @@ func (a *Analyzer) analyzeCall(e *CallExpr) *Type {
 
 	// Check argument types
 	for i, arg := range e.Args {
-		argType := a.analyzeExpr(arg)
+		argType := argTypes[i]
 		paramType := sym.Function.Params[i].Type
 		qualifier := ""
 		if len(sym.Function.Params[i].Qualifiers) > 0 {
@@ -824,15 +1126,84 @@ func (a *Analyzer) analyzeCall(e *CallExpr) *Type {
 			a.checkAssignable(arg)
 		}
 
-		if !paramType.Equals(argType) && !CanImplicitlyConvert(argType, paramType) {
+		if argType.Kind != TypeKindError && !paramType.Equals(argType) && !CanImplicitlyConvert(argType, paramType) {
 			a.addError(arg.GetRange(), "argument %d: cannot convert '%s' to '%s'",
 				i+1, argType.String(), paramType.String())
+		} else {
+			a.lintConversion(arg, argType, paramType)
 		}
 	}
 
 	return sym.Function.ReturnType
 }
 
+// hasErrorType reports whether any of types is the error type, in which
+// case the error was already reported.
+func hasErrorType(types []*Type) bool {
+	for _, t := range types {
+		if t.Kind == TypeKindError {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveOverload picks the declaration of an overloaded function a call
+// refers to: the one whose parameters match the argument types exactly,
+// else the first one they implicitly convert to.
+func resolveOverload(candidates []*Symbol, argTypes []*Type) *Symbol {
+	var converted *Symbol
+	for _, candidate := range candidates {
+		params := candidate.Function.Params
+		if len(params) != len(argTypes) {
+			continue
+		}
+		exact, convertible := true, true
+		for i, param := range params {
+			if param.Type.Equals(argTypes[i]) {
+				continue
+			}
+			exact = false
+			if !CanImplicitlyConvert(argTypes[i], param.Type) {
+				convertible = false
+				break
+			}
+		}
+		if exact {
+			return candidate
+		}
+		if convertible && converted == nil {
+			converted = candidate
+		}
+	}
+	return converted
+}
+
+// analyzeStructConstructor analyzes a struct constructor call, which takes
+// one argument per member, in declaration order.
+func (a *Analyzer) analyzeStructConstructor(structType *Type, e *CallExpr) *Type {
+	argTypes := make([]*Type, len(e.Args))
+	for i, arg := range e.Args {
+		argTypes[i] = a.analyzeExpr(arg)
+	}
+
+	if len(e.Args) != len(structType.Fields) {
+		a.addError(e.Range, "struct '%s' constructor expects %d arguments, got %d",
+			structType.Name, len(structType.Fields), len(e.Args))
+		return structType
+	}
+
+	for i, field := range structType.Fields {
+		if argTypes[i].Kind != TypeKindError && !field.Type.Equals(argTypes[i]) && !CanImplicitlyConvert(argTypes[i], field.Type) {
+			a.addError(e.Args[i].GetRange(), "argument %d: cannot convert '%s' to '%s'",
+				i+1, argTypes[i].String(), field.Type.String())
+		} else {
+			a.lintConversion(e.Args[i], argTypes[i], field.Type)
+		}
+	}
+	return structType
+}
+
 // analyzeTypeConstructor analyzes a type constructor call.
 func (a *Analyzer) analyzeTypeConstructor(typeName string, e *CallExpr) *Type {
 	targetType := TypeFromName(typeName)
@@ -850,6 +1221,11 @@ func (a *Analyzer) analyzeTypeConstructor(typeName string, e *CallExpr) *Type {
 	for i, arg := range e.Args {
 		argTypes[i] = a.analyzeExpr(arg)
 	}
+	if hasErrorType(argTypes) {
+		return targetType
+	}
+
+	a.lintTruncation(e, targetType, argTypes)
 
 	// For scalar types, accept exactly one argument
 	if targetType.IsScalar() {
@@ -917,27 +1293,50 @@ func (a *Analyzer) analyzeBuiltinCall(builtin *BuiltinFunction, e *CallExpr) *Ty
 	for i, arg := range e.Args {
 		argTypes[i] = a.analyzeExpr(arg)
 	}
+	if hasErrorType(argTypes) {
+		return TypeError
+	}
 
-	// Find a matching signature
+	// Find a matching signature, preferring one that needs no conversion.
+	// Arguments of out and inout parameters are written back, so they must
+	// have the exact type.
+	var converted *FunctionSig
 	for _, sig := range builtin.Signatures {
 		if len(sig.Params) != len(argTypes) {
 			continue
 		}
-		matches := true
+		matches, exact := true, true
 		for i, paramTypeName := range sig.Params {
 			paramType := TypeFromName(paramTypeName)
 			if paramType == nil {
 				matches = false
 				break
 			}
-			if !paramType.Equals(argTypes[i]) && !CanImplicitlyConvert(argTypes[i], paramType) {
+			if paramType.Equals(argTypes[i]) {
+				continue
+			}
+			exact = false
+			if sig.Qualifier(i) != "" || !CanImplicitlyConvert(argTypes[i], paramType) {
 				matches = false
 				break
 			}
 		}
-		if matches {
+		if matches && exact {
+			a.checkOutArguments(sig, e)
+			a.checkFunctionStage(builtin, sig, e)
 			return TypeFromName(sig.Return)
 		}
+		if matches && converted == nil {
+			converted = &sig
+		}
+	}
+	if converted != nil {
+		for i, arg := range e.Args {
+			a.lintConversion(arg, argTypes[i], TypeFromName(converted.Params[i]))
+		}
+		a.checkOutArguments(*converted, e)
+		a.checkFunctionStage(builtin, *converted, e)
+		return TypeFromName(converted.Return)
 	}
 
 	// No matching signature found
@@ -955,6 +1354,49 @@ func (a *Analyzer) analyzeBuiltinCall(builtin *BuiltinFunction, e *CallExpr) *Ty
 	return TypeError
 }
 
+// checkOutArguments checks that the arguments of the out and inout
+// parameters of a built-in function can be assigned.
+func (a *Analyzer) checkOutArguments(sig FunctionSig, e *CallExpr) {
+	for i, arg := range e.Args {
+		if q := sig.Qualifier(i); q == "out" || q == "inout" {
+			a.checkAssignable(arg)
+		}
+	}
+}
+
+// checkFunctionStage checks that a built-in function, or the overload of it
+// called, is available in the processor function it is called in. Calls in
+// other functions are not checked, as they may be called from any stage.
+func (a *Analyzer) checkFunctionStage(builtin *BuiltinFunction, sig FunctionSig, e *CallExpr) {
+	stages := builtin.Stages
+	if sig.Stages != nil {
+		stages = sig.Stages
+	}
+	if a.currentStage == "" || stages == nil || slices.Contains(stages, a.currentStage) {
+		return
+	}
+
+	var available []string
+	for _, stage := range ShaderStages[a.shaderType] {
+		if slices.Contains(stages, stage) {
+			available = append(available, stage)
+		}
+	}
+	what := "'" + builtin.Name + "'"
+	if sig.Stages != nil {
+		what = fmt.Sprintf("'%s(%s)'", builtin.Name, strings.Join(sig.Params, ", "))
+	}
+	if len(available) == 0 {
+		a.addError(e.Range, "%s is not available in %s shaders", what, a.shaderType).Code = CodeUnavailable
+		return
+	}
+	functions := "function"
+	if len(available) > 1 {
+		functions = "functions"
+	}
+	a.addError(e.Range, "%s is only available in the %s %s", what, JoinStages(available), functions).Code = CodeUnavailable
+}
+
 // analyzeIndex analyzes an index expression.
 func (a *Analyzer) analyzeIndex(e *IndexExpr) *Type {
 	baseType := a.analyzeExpr(e.Expr)
@@ -967,6 +1409,20 @@ func (a *Analyzer) analyzeIndex(e *IndexExpr) *Type {
 	resultType := IndexResultType(baseType)
 	if resultType.Kind == TypeKindError {
 		a.addError(e.Range, "cannot index type '%s'", baseType.String())
+		return resultType
+	}
+
+	// Sampler arrays are opaque: backends only accept constant indices
+	if baseType.Kind == TypeKindArray && baseType.ElementType.IsSampler() && !a.isConstantExpr(e.Index) {
+		a.addError(e.Index.GetRange(), "sampler arrays can only be indexed with a constant expression")
+	}
+
+	length := baseType.VectorSize() + baseType.MatrixSize()
+	if baseType.Kind == TypeKindArray {
+		length = baseType.ArraySize
+	}
+	if idx, ok := a.constIntValue(e.Index); ok && length >= 0 && (idx < 0 || idx >= length) {
+		a.addError(e.Index.GetRange(), "index %d is out of bounds for '%s'", idx, baseType.String())
 	}
 	return resultType
 }
@@ -974,6 +1430,16 @@ func (a *Analyzer) analyzeIndex(e *IndexExpr) *Type {
 // analyzeMember analyzes a member access expression.
 func (a *Analyzer) analyzeMember(e *MemberExpr) *Type {
 	baseType := a.analyzeExpr(e.Expr)
+	if a.memberAt != nil && a.memberBase == nil {
+		base, pos := e.Expr.GetRange().End, *a.memberAt
+		if pos.Line == base.Line && pos.Column > base.Column &&
+			(pos.Line < e.Range.End.Line || pos.Column <= e.Range.End.Column) {
+			a.memberBase = baseType
+		}
+	}
+	if baseType.Kind == TypeKindError || e.Member == "" {
+		return TypeError // Error already reported
+	}
 
 	// Check for swizzle on vector types
 	if baseType.IsVector() {
@@ -1009,6 +1475,9 @@ func (a *Analyzer) analyzeMember(e *MemberExpr) *Type {
 
 // analyzeArrayExpr analyzes an array initialization expression.
 func (a *Analyzer) analyzeArrayExpr(e *ArrayExpr) *Type {
+	if e.Type != nil {
+		return a.analyzeArrayConstructor(e)
+	}
 	if len(e.Elements) == 0 {
 		a.addError(e.Range, "empty array initializer")
 		return TypeError
@@ -1021,6 +1490,44 @@ func (a *Analyzer) analyzeArrayExpr(e *ArrayExpr) *Type {
 		if !t.Equals(elemType) && !CanImplicitlyConvert(t, elemType) {
 			a.addError(e.Elements[i].GetRange(), "array element type mismatch: expected '%s', got '%s'",
 				elemType.String(), t.String())
+		} else {
+			a.lintConversion(e.Elements[i], t, elemType)
+		}
+	}
+
+	return MakeArrayType(elemType, len(e.Elements))
+}
+
+// analyzeArrayConstructor analyzes an array constructor such as
+// float[3](1.0, 2.0, 3.0), whose elements convert to its element type.
+func (a *Analyzer) analyzeArrayConstructor(e *ArrayExpr) *Type {
+	arrayType := a.resolveType(e.Type)
+	if arrayType == nil {
+		a.addError(e.Type.Range, "unknown type '%s'", e.Type.Name).Code = CodeUndefined
+		for _, elem := range e.Elements {
+			a.analyzeExpr(elem)
+		}
+		return TypeError
+	}
+	a.checkArraySize(e.Type.ArraySize)
+	elemType := arrayType.ElementType
+
+	if len(e.Elements) == 0 {
+		a.addError(e.Range, "empty array constructor")
+		return TypeError
+	}
+	if arrayType.ArraySize >= 0 && arrayType.ArraySize != len(e.Elements) {
+		a.addError(e.Range, "array constructor expects %d elements, got %d",
+			arrayType.ArraySize, len(e.Elements))
+	}
+
+	for _, elem := range e.Elements {
+		t := a.analyzeExpr(elem)
+		if !t.Equals(elemType) && !CanImplicitlyConvert(t, elemType) {
+			a.addError(elem.GetRange(), "array element type mismatch: expected '%s', got '%s'",
+				elemType.String(), t.String())
+		} else {
+			a.lintConversion(elem, t, elemType)
 		}
 	}
 
@@ -1035,7 +1542,10 @@ func (a *Analyzer) checkAssignable(expr Expr) {
 		if sym == nil {
 			return // Error already reported
 		}
-		if sym.Constant || sym.ReadOnly {
+		switch {
+		case sym.Kind == SymbolBuiltinVariable && sym.ReadOnly:
+			a.addError(e.Range, "built-in '%s' is read-only in the %s function", e.Name, a.currentStage)
+		case sym.Constant || sym.ReadOnly:
 			a.addError(e.Range, "cannot assign to '%s' (read-only)", e.Name)
 		}
 
@@ -1043,8 +1553,12 @@ func (a *Analyzer) checkAssignable(expr Expr) {
 		a.checkAssignable(e.Expr)
 
 	case *MemberExpr:
-		// Swizzle assignment is valid for single components or all different components
+		// Swizzle assignment is valid for single components or all different
+		// components. The base was analyzed with the whole assignment, so
+		// errors from analyzing it again are dropped.
+		errCount := len(a.errors)
 		baseType := a.analyzeExpr(e.Expr)
+		a.errors = a.errors[:errCount]
 		if baseType.IsVector() {
 			// Check for duplicate swizzle components
 			seen := make(map[rune]bool)
@@ -1272,6 +1786,151 @@ func (a *Analyzer) GetSymbols() []*Symbol {
 	return symbols
 }
 
+// SymbolsAt analyzes the document and returns the symbols visible at pos:
+// the globals and, inside a function, its parameters, the built-ins of its
+// stage and the locals declared before pos. Inner declarations shadow outer
+// ones.
+func (a *Analyzer) SymbolsAt(pos Position) []*Symbol {
+	a.captureAt = &pos
+	a.Analyze()
+	if a.captured == nil {
+		a.captured = visibleSymbols(a.globalScope)
+	}
+	return a.captured
+}
+
+// MemberBaseTypeAt analyzes the document and returns the type of the
+// expression before the '.' of the member access at pos, e.g. vec3 for
+// "color.rg" with pos after the dot, or nil if pos is not in a member name.
+func (a *Analyzer) MemberBaseTypeAt(pos Position) *Type {
+	a.memberAt = &pos
+	a.Analyze()
+	return a.memberBase
+}
+
+// TypeOf returns the type inferred for expr by Analyze, or nil if the
+// expression was not analyzed.
+func (a *Analyzer) TypeOf(expr Expr) *Type {
+	return a.types[expr]
+}
+
+// ExpectedType returns the type the context of an expression expects of
+// it, as analyzed by Analyze: the parameter it is passed to, the variable
+// it initializes or is assigned to, or the other operand of an operator.
+// It returns nil when the context does not tell.
+func (a *Analyzer) ExpectedType(expr Expr) *Type {
+	var expected *Type
+	known := func(t *Type) *Type {
+		if t == nil || t.Kind == TypeKindError || t.Kind == TypeKindVoid {
+			return nil
+		}
+		return t
+	}
+	InspectDocument(a.doc, func(n Node) bool {
+		if expected != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *CallExpr:
+			if i := slices.Index(n.Args, expr); i >= 0 {
+				expected = known(a.expectedArgType(n, i))
+			}
+		case *BinaryExpr:
+			switch {
+			case n.Operator == "&&" || n.Operator == "||":
+				if n.Left == expr || n.Right == expr {
+					expected = TypeBool
+				}
+			case n.Right == expr:
+				expected = known(a.types[n.Left])
+			case n.Left == expr && !isAssignOperator(n.Operator):
+				expected = known(a.types[n.Right])
+			}
+		case *VarDecl:
+			if n.Init == expr {
+				if sym := a.symbols[n]; sym != nil {
+					expected = known(sym.Type)
+				}
+			}
+		}
+		return true
+	})
+	return expected
+}
+
+// expectedArgType returns the type of the i-th parameter of the function
+// a call resolves to, or of the first overload taking the other arguments.
+func (a *Analyzer) expectedArgType(call *CallExpr, i int) *Type {
+	ident, ok := call.Func.(*IdentExpr)
+	if !ok {
+		return nil
+	}
+	if IsBuiltinTypeName(ident.Name) {
+		if t := TypeFromName(ident.Name); t != nil && (t.IsVector() || t.IsMatrix()) {
+			return t.ComponentType()
+		}
+		return TypeFromName(ident.Name)
+	}
+	if sym := a.symbols[ident]; sym != nil && sym.Function != nil && i < len(sym.Function.Params) {
+		return sym.Function.Params[i].Type
+	}
+	builtin, ok := BuiltinFunctions[ident.Name]
+	if !ok {
+		return nil
+	}
+	for _, sig := range builtin.Signatures {
+		if len(sig.Params) != len(call.Args) {
+			continue
+		}
+		matches := true
+		for j, arg := range call.Args {
+			argType, paramType := a.types[arg], TypeFromName(sig.Params[j])
+			if j != i && argType != nil && argType.Kind != TypeKindError && !paramType.Equals(argType) && !CanImplicitlyConvert(argType, paramType) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return TypeFromName(sig.Params[i])
+		}
+	}
+	return nil
+}
+
+// SymbolOf returns the symbol an identifier or called function name refers
+// to, or the one declared by a *VarDecl or *ParamDecl, as resolved by
+// Analyze. Built-in constants and functions have no symbol.
+func (a *Analyzer) SymbolOf(node Node) *Symbol {
+	return a.symbols[node]
+}
+
+// capture records the symbols in scope when the analysis reaches the first
+// statement or block end after the SymbolsAt position.
+func (a *Analyzer) capture(at Position) {
+	if a.captureAt == nil || a.captured != nil {
+		return
+	}
+	if a.captureAt.Line < at.Line || (a.captureAt.Line == at.Line && a.captureAt.Column < at.Column) {
+		a.captured = visibleSymbols(a.currentScope)
+	}
+}
+
+// visibleSymbols lists the symbols of a scope and its parents by name.
+func visibleSymbols(scope *Scope) []*Symbol {
+	seen := make(map[string]bool)
+	var symbols []*Symbol
+	for ; scope != nil; scope = scope.parent {
+		for name, sym := range scope.symbols {
+			if !seen[name] {
+				seen[name] = true
+				symbols = append(symbols, sym)
+			}
+		}
+	}
+	slices.SortFunc(symbols, func(x, y *Symbol) int { return strings.Compare(x.Name, y.Name) })
+	return symbols
+}
+
 // GetStructs returns all defined struct types.
 func (a *Analyzer) GetStructs() map[string]*Type {
 	return a.structs
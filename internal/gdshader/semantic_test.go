@@ -0,0 +1,1214 @@
+package gdshader
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func analyze(src string) ([]ParseError, []*SemanticError) {
+	doc := Parse(src)
+	return doc.Errors, NewAnalyzer(doc).Analyze()
+}
+
+func hasError(errs []*SemanticError, substr string) bool {
+	for _, err := range errs {
+		if strings.Contains(err.Message, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSamplerArrayUniform(t *testing.T) {
+	src := `shader_type spatial;
+const int LAYERS = 2 * 2;
+uniform sampler2D textures[LAYERS] : source_color, filter_linear;
+uniform sampler2D[2] masks;
+
+void fragment() {
+	vec4 a = texture(textures[0], UV);
+	vec4 b = texture(textures[LAYERS - 1], UV);
+	vec4 c = texture(masks[1], UV);
+	ALBEDO = a.rgb + b.rgb + c.rgb;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	doc := Parse(src)
+	if got := doc.Uniforms[0].Type.String(); got != "sampler2D[LAYERS]" {
+		t.Errorf("expected type 'sampler2D[LAYERS]', got %q", got)
+	}
+	if got := doc.Uniforms[1].Type.String(); got != "sampler2D[2]" {
+		t.Errorf("expected type 'sampler2D[2]', got %q", got)
+	}
+}
+
+func TestSamplerArrayIndexing(t *testing.T) {
+	src := `shader_type spatial;
+uniform sampler2D textures[4];
+uniform int layer;
+
+void fragment() {
+	vec4 a = texture(textures[layer], UV);
+	vec4 b = texture(textures[4], UV);
+	vec4 c = texture(textures, UV);
+	ALBEDO = a.rgb + b.rgb + c.rgb;
+}
+`
+	_, errs := analyze(src)
+	for _, want := range []string{
+		"sampler arrays can only be indexed with a constant expression",
+		"index 4 is out of bounds for 'sampler2D[4]'",
+		"no matching overload for 'texture(sampler2D[4], vec2)'",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestSamplerArrayDeclarationErrors(t *testing.T) {
+	src := `shader_type spatial;
+uniform sampler2D empty[0];
+uniform sampler2D[2] twice[2];
+`
+	parseErrs, semErrs := analyze(src)
+	if !hasError(semErrs, "array size must be a positive integer constant") {
+		t.Errorf("expected array size error, got %v", semErrs)
+	}
+
+	found := false
+	for _, err := range parseErrs {
+		if strings.Contains(err.Message, "array size already specified") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected duplicate array size error, got %v", parseErrs)
+	}
+}
+
+func TestUniformHints(t *testing.T) {
+	valid := `shader_type spatial;
+uniform float roughness : hint_range(0.0, 1.0) = 0.5;
+uniform int steps : hint_range(-4, 4, 1);
+uniform int mode : hint_enum("Linear", "Smooth") = 0;
+uniform vec4 tint : source_color = vec4(1.0);
+uniform sampler2D albedo : source_color, filter_linear_mipmap, repeat_enable;
+uniform sampler2D normal_map : hint_normal;
+uniform sampler2D screen : hint_screen_texture, filter_nearest;
+uniform vec3 colors[2] : source_color;
+
+void fragment() {}
+`
+	parseErrs, semErrs := analyze(valid)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	invalid := `shader_type spatial;
+uniform vec2 offset : hint_range(0.0, 1.0);
+uniform float amount : hint_range(0.0);
+uniform float speed : hint_range(0.0, max_speed);
+uniform float mode : hint_enum("A", "B");
+uniform int choice : hint_enum(1, 2);
+uniform float brightness : source_color;
+uniform vec4 color : filter_linear;
+uniform samplerCube sky : hint_screen_texture;
+uniform sampler2D tex : filter_linear, filter_nearest;
+uniform sampler2D mask : hint_normal, source_color;
+uniform sampler2D noise : hint_roughness_x;
+uniform sampler2D detail : repeat_enable(1);
+
+void fragment() {}
+`
+	_, semErrs = analyze(invalid)
+	expected := []string{
+		"hint_range is only for 'float' and 'int' uniforms, not 'vec2'",
+		"hint_range expects 2 or 3 arguments (min, max[, step]), got 1",
+		"hint_range arguments must be number literals",
+		"hint_enum is only for 'int' uniforms, not 'float'",
+		"hint_enum options must be strings",
+		"source_color is only for 'vec3', 'vec4' and sampler uniforms, not 'float'",
+		"filter_linear is only for sampler uniforms, not 'vec4'",
+		"hint_screen_texture is only for 'sampler2D' uniforms, not 'samplerCube'",
+		"hint 'filter_nearest' conflicts with 'filter_linear'",
+		"hint 'source_color' conflicts with 'hint_normal'",
+		"unknown uniform hint 'hint_roughness_x'",
+		"hint 'repeat_enable' takes no arguments",
+	}
+	for _, want := range expected {
+		if !hasError(semErrs, want) {
+			t.Errorf("expected error %q, got %v", want, semErrs)
+		}
+	}
+	if len(semErrs) != len(expected)+1 {
+		// hint_enum(1, 2) reports each option
+		t.Errorf("expected %d errors, got %d: %v", len(expected)+1, len(semErrs), semErrs)
+	}
+}
+
+func TestUniformHintRange(t *testing.T) {
+	src := "shader_type spatial;\nuniform vec2 offset : hint_range(0.0, 1.0);\nvoid fragment() {}\n"
+	_, semErrs := analyze(src)
+	if len(semErrs) != 1 {
+		t.Fatalf("expected 1 error, got %v", semErrs)
+	}
+	r := semErrs[0].Range
+	if r.Start.Line != 1 || r.Start.Column != 22 || r.End.Column != 42 {
+		t.Errorf("expected the error to cover the hint, got %+v", r)
+	}
+}
+
+func TestBuiltinFunctionTable(t *testing.T) {
+	src := `shader_type spatial;
+uniform sampler2D albedo;
+uniform usampler2D ids;
+uniform sampler2DArray layers;
+uniform samplerCubeArray probes;
+void fragment() {
+	vec4 g = textureGather(albedo, UV, 1) + textureProjLod(albedo, vec3(UV, 1.0), 0.0);
+	vec4 c = texture(probes, vec4(NORMAL, 0.0), 1.0) + textureGrad(layers, vec3(UV, 0.0), dFdxFine(UV), dFdyCoarse(UV));
+	uvec4 id = texelFetch(ids, ivec2(FRAGCOORD.xy), 0);
+	int levels = textureQueryLevels(albedo) + textureSize(layers, 0).z;
+	vec2 lod = textureQueryLod(albedo, UV);
+	int bits = bitCount(id.x) + findLSB(5) + findMSB(id.y) + bitfieldExtract(levels, 0, 4);
+	uint packed = packUnorm4x8(g) + bitfieldInsert(id.z, 1u, 2, 3) + bitfieldReverse(id.w);
+	vec3 m = mix(vec3(0.0), c.rgb, bvec3(true, false, true)) + fma(c.rgb, g.rgb, vec3(lod, 1.0));
+	bvec3 broken = any(isnan(m)) || any(isinf(m)) ? lessThanEqual(m, vec3(0.0)) : greaterThanEqual(m, vec3(1.0));
+	ALBEDO = m * unpackSnorm4x8(packed).rgb * float(bits) * fwidthFine(UV.x) * float(any(broken));
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+}
+
+func TestBuiltinFunctionStages(t *testing.T) {
+	src := `shader_type spatial;
+uniform sampler2D tex;
+float edge(float x) {
+	return fwidth(x);
+}
+void vertex() {
+	float d = dFdx(VERTEX.x);
+	vec4 a = texture(tex, UV) + textureLod(tex, UV, 1.0);
+	vec4 b = texture(tex, UV, 1.0);
+}
+void fragment() {
+	ALBEDO = texture(tex, UV, 1.0).rgb * dFdy(UV.y);
+}
+void light() {
+	DIFFUSE_LIGHT = vec3(fwidthFine(ATTENUATION));
+}
+`
+	_, errs := analyze(src)
+	want := []string{
+		"7:12: 'dFdx' is only available in the fragment and light functions",
+		"9:11: 'texture(sampler2D, vec2, float)' is only available in the fragment and light functions",
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code == CodeUnavailable {
+			got = append(got, err.Error())
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	_, errs = analyze("shader_type particles;\nvoid process() {\n\tfloat d = dFdx(TIME);\n}\n")
+	if !hasError(errs, "'dFdx' is not available in particles shaders") {
+		t.Errorf("expected dFdx to be unavailable in particles shaders, got %v", errs)
+	}
+}
+
+func TestOutArguments(t *testing.T) {
+	src := `shader_type spatial;
+void split(float x, out float whole, inout int count) {
+	whole = floor(x);
+	count++;
+}
+void fragment() {
+	float whole;
+	int exp;
+	int count = 0;
+	vec3 parts;
+	float frac = modf(UV.x, whole) + frexp(UV.y, exp);
+	vec3 fracs = modf(VERTEX, parts);
+	split(1.5, whole, count);
+	uvec2 carry;
+	uvec2 sum = uaddCarry(uvec2(1u), uvec2(2u), carry);
+	ALBEDO = vec3(frac) + fracs;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	bad := `shader_type spatial;
+const float WHOLE = 1.0;
+void split(float x, out float whole) {
+	whole = x;
+}
+void fragment() {
+	int i;
+	float a = modf(0.5, 1.0);
+	float b = modf(0.5, TIME);
+	float c = modf(0.5, WHOLE);
+	float d = modf(0.5, i);
+	split(0.5, 2.0);
+	ALBEDO = vec3(a + b + c + d);
+}
+`
+	_, semErrs = analyze(bad)
+	want := []string{
+		"8:22: expression is not assignable",
+		"9:22: built-in 'TIME' is read-only in the fragment function",
+		"10:22: cannot assign to 'WHOLE' (read-only)",
+		"11:12: no matching overload for 'modf(float, int)'",
+		"12:13: expression is not assignable",
+	}
+	var got []string
+	for _, err := range semErrs {
+		got = append(got, err.Error())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestFunctionOverloading(t *testing.T) {
+	src := `shader_type canvas_item;
+
+float blend(float a, float b) {
+	return a * b;
+}
+
+vec3 blend(vec3 a, vec3 b) {
+	return a * b;
+}
+
+void fragment() {
+	float f = blend(0.5, 0.25);
+	vec3 v = blend(COLOR.rgb, vec3(1.0));
+	COLOR.rgb = v * f;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	bad := `shader_type canvas_item;
+float blend(float a, float b) { return a; }
+vec3 blend(float x, float y) { return vec3(x); }
+vec3 blend(vec3 a, vec3 b) { return a; }
+
+void fragment() {
+	blend(vec2(0.0), 1.0);
+}
+`
+	_, errs := analyze(bad)
+	for _, want := range []string{
+		"function 'blend' with the same parameters already defined at line 2",
+		"no matching overload for 'blend(vec2, float)'",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestConstArrays(t *testing.T) {
+	src := `shader_type canvas_item;
+const float WEIGHTS[3] = float[3](0.25, 0.5, 0.25);
+const vec2 OFFSETS[] = { vec2(-1.0, 0.0), vec2(1.0, 0.0) };
+const lowp float[2] PAIR = float[](0.1, 0.9);
+
+void fragment() {
+	highp vec4 sum = vec4(0.0);
+	mediump float local[] = float[](1.0, 2.0);
+	int[2] counts = int[2](1, 2);
+	for (int i = 0; i < 2; i++) {
+		sum += texture(TEXTURE, UV + OFFSETS[i] * TEXTURE_PIXEL_SIZE) * WEIGHTS[i] * PAIR[i];
+	}
+	COLOR = sum * local[1] * float(counts[0]);
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	doc := Parse(src)
+	if got := doc.Constants[1].Type.String(); got != "vec2[]" {
+		t.Errorf("expected type 'vec2[]', got %q", got)
+	}
+
+	bad := `shader_type canvas_item;
+const float WEIGHTS[3] = float[3](0.25, 0.5);
+const int LEVELS[2] = float[2](1.0, 2.0);
+uniform lowp bool flag;
+`
+	_, errs := analyze(bad)
+	for _, want := range []string{
+		"array constructor expects 3 elements, got 2",
+		"cannot initialize 'LEVELS' of type 'int[2]' with 'float[2]'",
+		"precision qualifier 'lowp' cannot be used on boolean types",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestConstantFolding(t *testing.T) {
+	src := `shader_type spatial;
+const int SIZE = 2 * 3 + 1;
+const float SCALE = 0.5;
+const int HALF = int(float(SIZE) * SCALE);
+uniform float weights[SIZE - HALF];
+
+void fragment() {
+	const int LOCAL = HALF + 1;
+	float arr[SIZE];
+	float local[LOCAL];
+	float[SIZE / 2] packed;
+	arr[SIZE - 1] = weights[3] + local[LOCAL - 1] + packed[2];
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+
+	bad := `shader_type spatial;
+const int SIZE = 4;
+const float FRACTION = 1.5;
+uniform float none[SIZE - 4];
+uniform int steps : hint_range(10, 1, 0);
+
+void fragment() {
+	int count = 2;
+	float arr[SIZE];
+	float by_float[FRACTION];
+	float by_variable[count];
+	vec3 v = vec3(arr[SIZE], arr[-1], 0.0);
+	float x = v[SIZE - 1];
+}
+`
+	_, errs := analyze(bad)
+	want := []string{
+		"4:20: array size must be a positive integer constant",
+		"5:21: hint_range min (10) must be less than max (1)",
+		"5:39: hint_range step must be positive",
+		"10:17: array size must be a positive integer constant",
+		"11:20: array size must be a constant expression",
+		"12:20: index 4 is out of bounds for 'float[4]'",
+		"12:31: index -1 is out of bounds for 'float[4]'",
+		"13:14: index 3 is out of bounds for 'vec3'",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, err := range errs {
+		if got := err.Error(); got != want[i] {
+			t.Errorf("error %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestConstantRequired(t *testing.T) {
+	src := `shader_type spatial;
+uniform float base = 1.0;
+uniform float scaled = base * 2.0;
+const float HALF = 0.5;
+const float BAD = HALF + base;
+const vec3 AXIS = vec3(HALF, sin(HALF), 0.0);
+
+void fragment() {
+	int count = 2;
+	const int LOCAL = count + 1;
+	const float FINE = AXIS.y * HALF;
+	float values[2 + count];
+	switch (count) {
+	case 1:
+		break;
+	case count:
+		break;
+	}
+}
+`
+	_, errs := analyze(src)
+	want := []string{
+		"3:24: uniform default value must be a constant expression",
+		"5:26: const initializer must be a constant expression",
+		"10:20: const initializer must be a constant expression",
+		"12:19: array size must be a constant expression",
+		"16:7: case label must be a constant expression",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors, got %v", len(want), errs)
+	}
+	for i, err := range errs {
+		if got := err.Error(); got != want[i] {
+			t.Errorf("error %d: expected %q, got %q", i, want[i], got)
+		}
+		if err.Code != CodeNotConstant {
+			t.Errorf("expected code %s for %q, got %q", CodeNotConstant, err.Message, err.Code)
+		}
+	}
+}
+
+func TestSpatialBuiltins(t *testing.T) {
+	src := `shader_type spatial;
+render_mode skip_vertex_transform;
+varying float weight;
+void vertex() {
+	weight = BONE_WEIGHTS.x + CUSTOM0.w + float(BONE_INDICES.x);
+	MODELVIEW_MATRIX = VIEW_MATRIX * MODEL_MATRIX;
+	VERTEX = (MODELVIEW_MATRIX * vec4(VERTEX + EYE_OFFSET, 1.0)).xyz;
+	ROUGHNESS = VIEW_INDEX == VIEW_RIGHT ? 0.5 : 1.0;
+}
+void fragment() {
+	LIGHT_VERTEX = VERTEX + NODE_POSITION_VIEW * 0.0;
+	TANGENT = normalize(TANGENT);
+	ALBEDO = vec3(weight, POINT_COORD);
+	PREMUL_ALPHA_FACTOR = 1.0;
+	RADIANCE = vec4(0.0);
+}
+void light() {
+	vec2 uv = SCREEN_UV.x > 0.5 ? UV : UV2;
+	DIFFUSE_LIGHT = ALBEDO * uv.x * (INV_VIEW_MATRIX * vec4(LIGHT, 0.0)).xyz;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if len(semErrs) > 0 {
+		t.Fatalf("unexpected semantic errors: %v", semErrs)
+	}
+}
+
+func TestRemovedBuiltins(t *testing.T) {
+	src := `shader_type canvas_item;
+void vertex() {
+	VERTEX = (WORLD_MATRIX * vec4(VERTEX, 0.0, 1.0)).xy;
+}
+void fragment() {
+	COLOR = texture(SCREEN_TEXTURE, SCREEN_UV) * 2.0;
+}
+`
+	_, errs := analyze(src)
+	want := []string{
+		"3:12: built-in 'WORLD_MATRIX' was renamed to 'MODEL_MATRIX' in Godot 4",
+		"6:18: built-in 'SCREEN_TEXTURE' was removed in Godot 4; declare 'uniform sampler2D screen_texture : hint_screen_texture;' and sample it instead",
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code != CodeRemovedBuiltin {
+			t.Errorf("unexpected error %v", err)
+		}
+		got = append(got, err.Error())
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// A shader may still name its own uniform after them
+	if _, errs := analyze("shader_type canvas_item;\nuniform sampler2D SCREEN_TEXTURE : hint_screen_texture;\nvoid fragment() {\n\tCOLOR = texture(SCREEN_TEXTURE, UV);\n}\n"); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestStageBuiltins(t *testing.T) {
+	src := `shader_type spatial;
+void vertex() {
+	vec2 s = SCREEN_UV;
+}
+void fragment() {
+	UV += vec2(1.0);
+	VERTEX.x *= 2.0;
+	++UV2.y;
+	POSITION = vec4(0.0);
+}
+float helper() {
+	return UV.x;
+}
+`
+	_, errs := analyze(src)
+	for _, want := range []string{
+		"built-in 'SCREEN_UV' is not available in the vertex function",
+		"built-in 'UV' is read-only in the fragment function",
+		"built-in 'VERTEX' is read-only in the fragment function",
+		"built-in 'UV2' is read-only in the fragment function",
+		"built-in 'POSITION' is not available in the fragment function",
+		"built-in 'UV' is not available in the helper function",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+	if len(errs) != 6 {
+		t.Errorf("expected 6 errors without cascades, got %v", errs)
+	}
+
+	r := errs[0].Range
+	if r.Start.Line != 2 || r.Start.Column != 10 || r.End.Column != 19 {
+		t.Errorf("expected the error to cover SCREEN_UV, got %+v", r)
+	}
+
+	particles := `shader_type particles;
+void start() {
+	if (RESTART_VELOCITY) {
+		VELOCITY = vec3(0.0, 1.0, 0.0);
+	}
+}
+void process() {
+	VELOCITY.y -= 9.8 * DELTA;
+	VELOCITY += ATTRACTOR_FORCE * DELTA;
+	if (COLLIDED && COLLISION_DEPTH > 0.0) {
+		VELOCITY = reflect(VELOCITY, COLLISION_NORMAL);
+	}
+	ACTIVE = !RESTART || INDEX > 0u;
+}
+`
+	if _, errs := analyze(particles); len(errs) > 0 {
+		t.Errorf("unexpected errors in particles shader: %v", errs)
+	}
+
+	particles = `shader_type particles;
+void start() {
+	bool hit = COLLIDED;
+}
+void process() {
+	bool restarted = RESTART_COLOR;
+}
+`
+	_, errs = analyze(particles)
+	for _, want := range []string{
+		"built-in 'COLLIDED' is not available in the start function",
+		"built-in 'RESTART_COLOR' is not available in the process function",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+}
+
+func TestTargetVersion(t *testing.T) {
+	src := `shader_type particles;
+render_mode keep_data, disable_velocity, no_such_mode;
+void start() {
+	float r = AMOUNT_RATIO;
+}
+`
+	analyzeFor := func(version Version) []*SemanticError {
+		analyzer := NewAnalyzer(Parse(src))
+		analyzer.SetVersion(version)
+		return analyzer.Analyze()
+	}
+
+	errs := analyzeFor(Version{4, 1})
+	if !hasError(errs, "built-in 'AMOUNT_RATIO' requires Godot 4.2 or later") {
+		t.Errorf("expected AMOUNT_RATIO to require 4.2, got %v", errs)
+	}
+	if !hasError(errs, "unknown render mode 'no_such_mode' for particles shaders") {
+		t.Errorf("expected an unknown render mode error, got %v", errs)
+	}
+
+	errs = analyzeFor(LatestVersion)
+	if len(errs) != 1 || !hasError(errs, "unknown render mode 'no_such_mode'") {
+		t.Errorf("expected only the unknown render mode with %s, got %v", LatestVersion, errs)
+	}
+	if r := errs[0].Range; r.Start.Line != 1 || r.Start.Column != 41 {
+		t.Errorf("expected the error to cover no_such_mode, got %+v", r)
+	}
+
+	spatial := `shader_type spatial;
+render_mode specular_occlusion_disabled;
+uniform int mode : hint_enum("A", "B");
+`
+	analyzer := NewAnalyzer(Parse(spatial))
+	analyzer.SetVersion(Version{4, 3})
+	errs = analyzer.Analyze()
+	for _, want := range []string{
+		"render mode 'specular_occlusion_disabled' requires Godot 4.4 or later",
+		"uniform hint 'hint_enum' requires Godot 4.4 or later",
+	} {
+		if !hasError(errs, want) {
+			t.Errorf("expected error containing %q, got %v", want, errs)
+		}
+	}
+
+	if v, ok := ParseVersion("4.2"); !ok || v != (Version{4, 2}) {
+		t.Errorf("expected 4.2 to parse, got %v %v", v, ok)
+	}
+	if _, ok := ParseVersion("four"); ok {
+		t.Error("expected an invalid version not to parse")
+	}
+}
+
+func TestUnreachableCode(t *testing.T) {
+	src := `shader_type canvas_item;
+float pick(int mode) {
+	switch (mode) {
+	case 0:
+		return 1.0;
+		mode = 2;
+	}
+	if (mode > 1) {
+		return 0.5;
+	} else {
+		return 0.25;
+	}
+	return 0.0;
+}
+void fragment() {
+	if (false) {
+		COLOR = vec4(1.0);
+	}
+	if (!false) {
+		COLOR.a = 1.0;
+	} else {
+		COLOR.a = 0.0;
+	}
+	while (false) {
+		COLOR.r = 0.0;
+	}
+	for (int i = 0; false; i++) {
+		COLOR.g = 0.0;
+	}
+	do {
+		COLOR.b = 0.0;
+	} while (false);
+	discard;
+	COLOR = vec4(0.0);
+}
+`
+	_, errs := analyze(src)
+	want := []string{
+		"6:3: unreachable code",
+		"13:2: unreachable code",
+		"16:6: condition is always false; the branch is never taken",
+		"19:6: condition is always true; the else branch is never taken",
+		"24:9: loop condition is always false; the body never runs",
+		"27:18: loop condition is always false; the body never runs",
+		"34:2: unreachable code",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d warnings, got %v", len(want), errs)
+	}
+	for i, err := range errs {
+		if !err.Warning {
+			t.Errorf("expected %q to be a warning", err.Message)
+		}
+		if got := err.Error(); got != want[i] {
+			t.Errorf("warning %d: expected %q, got %q", i, want[i], got)
+		}
+	}
+}
+
+func TestVaryingStages(t *testing.T) {
+	src := `shader_type spatial;
+varying vec3 world_pos;
+varying float fade;
+varying vec2 early;
+varying float twice;
+varying flat bool hit;
+varying float unused;
+varying float lit;
+void set_fade() {
+	fade = 1.0;
+}
+void vertex() {
+	world_pos = VERTEX;
+	twice = 1.0;
+	early.x += 1.0;
+	hit = true;
+	float u = unused;
+	float f = fade;
+}
+void fragment() {
+	ALBEDO = world_pos;
+	twice = 2.0;
+	ALPHA = lit;
+	lit = 0.5;
+	early = vec2(0.0);
+	ALPHA += fade;
+}
+void light() {
+	DIFFUSE_LIGHT = vec3(lit);
+	lit = 1.0;
+}
+`
+	_, errs := analyze(src)
+	want := []string{
+		"10:2: varying 'fade' can only be assigned in the vertex or fragment function",
+		"15:2: varying 'early' is read before it is assigned in the vertex function",
+		"16:2: varying 'hit' of type 'bool' can only be assigned in the fragment function",
+		"17:12: varying 'unused' is read but never assigned",
+		"18:12: varying 'fade' is read but never assigned",
+		"22:2: varying 'twice' is assigned in the vertex function, so it cannot be assigned in fragment",
+		"23:10: varying 'lit' is read before it is assigned in the fragment function",
+		"25:2: varying 'early' is assigned in the vertex function, so it cannot be assigned in fragment",
+		"30:2: varying 'lit' cannot be assigned in the light function, only in vertex or fragment",
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code == CodeVaryingStage {
+			got = append(got, err.Error())
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestFunctionOrderAndRecursion(t *testing.T) {
+	src := `shader_type spatial;
+float fact(float n) {
+	return n <= 1.0 ? 1.0 : n * fact(n - 1.0);
+}
+float ping(float x) {
+	return pong(x);
+}
+float pong(float x) {
+	return ping(x);
+}
+float early(float x) {
+	return helper(x) + fact(x);
+}
+float helper(float x) {
+	return x * 2.0;
+}
+void fragment() {
+	ALPHA = early(0.5);
+}
+`
+	parseErrs, errs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	want := []string{
+		"12:9: function 'helper' is called before it is declared",
+		"3:30: function 'fact' calls itself; recursion is not allowed",
+		"6:9: recursion is not allowed: 'ping' calls 'pong' calls 'ping'",
+	}
+	var got []string
+	for _, err := range errs {
+		if err.Code == CodeRecursion || err.Code == CodeDeclarationOrder {
+			got = append(got, err.Error())
+		}
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	for _, src := range []string{
+		"shader_type spatial;\nvoid fragmnet() {}\n",
+		"shader_type particles;\nvoid vertex() {}\n",
+	} {
+		_, errs := analyze(src)
+		if len(errs) != 1 || errs[0].Code != CodeMissingEntryPoint || !errs[0].Warning {
+			t.Errorf("expected a missing entry point warning for %q, got %v", src, errs)
+		}
+	}
+	for _, src := range []string{
+		"shader_type sky;\nvoid sky() {}\n",
+		"shader_type spatial;\n#include \"res://common.gdshaderinc\"\n",
+	} {
+		if _, errs := analyze(src); len(errs) > 0 {
+			t.Errorf("expected no errors for %q, got %v", src, errs)
+		}
+	}
+}
+
+func TestLints(t *testing.T) {
+	src := `shader_type spatial;
+uniform float speed = 1;
+float half_of(float x) {
+	return x / 2;
+}
+void fragment() {
+	float t = TIME * 2.0;
+	int n = 3;
+	float a = n;
+	float b = pow(t, -2) + half_of(0x10);
+	vec3 c = vec3(abs(-1), 0.0, 1.0);
+	t += 1;
+	float x = float(c);
+	mat3 m = mat3(mat4(1.0));
+	if (t == 0.5 || b != 1.0 || n == 2) {
+		ALBEDO = c * b * a * x * m[0];
+	}
+}
+`
+	parseErrs, errs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	want := []struct{ msg, code string }{
+		{"2:23: integer literal '1' used as 'float'; write '1.0'", CodeImplicitConversion},
+		{"4:13: integer literal '2' used as 'float'; write '2.0'", CodeImplicitConversion},
+		{"9:12: implicit conversion from 'int' to 'float'; convert it explicitly", CodeImplicitConversion},
+		{"10:19: integer literal '-2' used as 'float'; write '-2.0'", CodeImplicitConversion},
+		{"10:33: implicit conversion from 'int' to 'float'; convert it explicitly", CodeImplicitConversion},
+		{"12:7: integer literal '1' used as 'float'; write '1.0'", CodeImplicitConversion},
+		{"13:12: 'float(vec3)' keeps only the first component; use a swizzle such as '.x'", CodeVectorTruncation},
+		{"14:11: 'mat3(mat4)' drops the components outside the upper-left 3x3", CodeVectorTruncation},
+		{"15:6: comparing floats with '==' is unreliable; compare their difference with an epsilon", CodeFloatEquality},
+		{"15:18: comparing floats with '!=' is unreliable; compare their difference with an epsilon", CodeFloatEquality},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d warnings, got %v", len(want), errs)
+	}
+	for i, err := range errs {
+		if got := err.Error(); got != want[i].msg || err.Code != want[i].code || !err.Warning {
+			t.Errorf("warning %d: expected %q (%s), got %q (%s)", i, want[i].msg, want[i].code, got, err.Code)
+		}
+	}
+}
+
+func TestFindNodeAt(t *testing.T) {
+	src := `shader_type spatial;
+
+void fragment() {
+	float d = length(UV - vec2(0.5));
+	ALBEDO = vec3(d);
+}
+`
+	doc := Parse(src)
+	a := NewAnalyzer(doc)
+	a.Analyze()
+
+	tests := []struct {
+		pos  Position
+		want string
+	}{
+		{Position{Line: 3, Column: 19}, "UV vec2"},
+		{Position{Line: 3, Column: 21}, "binary vec2"},
+		{Position{Line: 3, Column: 8}, "d float"},
+		{Position{Line: 4, Column: 15}, "d float"},
+	}
+	for _, tt := range tests {
+		node := doc.FindNodeAt(tt.pos)
+		var got string
+		switch n := node.(type) {
+		case *IdentExpr:
+			got = n.Name + " " + a.TypeOf(n).String()
+		case *BinaryExpr:
+			got = "binary " + a.TypeOf(n).String()
+		case *VarDecl:
+			got = n.Name + " " + a.SymbolOf(n).Type.String()
+		default:
+			got = fmt.Sprintf("%T", node)
+		}
+		if got != tt.want {
+			t.Errorf("FindNodeAt(%d:%d) = %s, want %s", tt.pos.Line, tt.pos.Column, got, tt.want)
+		}
+	}
+	if sym := a.SymbolOf(doc.FindNodeAt(Position{Line: 4, Column: 15})); sym == nil || sym.Range.Start.Line != 3 {
+		t.Errorf("expected 'd' to resolve to its declaration on line 4, got %+v", sym)
+	}
+}
+
+func TestStructConstructor(t *testing.T) {
+	src := `shader_type spatial;
+struct Light {
+	vec3 color;
+	float energy;
+};
+
+void fragment() {
+	Light ok = Light(vec3(1.0), 2.0);
+	Light few = Light(vec3(1.0));
+	Light bad = Light(1.0, 2.0);
+	ALBEDO = ok.color * ok.energy;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if !hasError(semErrs, "struct 'Light' constructor expects 2 arguments, got 1") {
+		t.Errorf("expected an argument count error, got %v", semErrs)
+	}
+	if !hasError(semErrs, "argument 1: cannot convert 'float' to 'vec3'") {
+		t.Errorf("expected an argument type error, got %v", semErrs)
+	}
+	if len(semErrs) != 2 {
+		t.Errorf("expected 2 errors, got %v", semErrs)
+	}
+}
+
+func TestStructArrayMembers(t *testing.T) {
+	src := `shader_type spatial;
+struct Surface {
+	vec3 color;
+};
+struct Light {
+	Surface layers[2];
+	float[3] weights;
+	vec2 offsets[];
+};
+
+void fragment() {
+	Light light;
+	ALBEDO = light.layers[1].color * light.weights[0];
+	float bad = light.layers.color;
+}
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrs)
+	}
+	if !hasError(semErrs, "array member 'offsets' must have a size") {
+		t.Errorf("expected an unsized member error, got %v", semErrs)
+	}
+	if !hasError(semErrs, "cannot access member 'color' on type 'Surface[2]'") {
+		t.Errorf("expected a member access error on the array, got %v", semErrs)
+	}
+	if len(semErrs) != 2 {
+		t.Errorf("expected 2 errors, got %v", semErrs)
+	}
+
+	// A malformed member does not stop the parser
+	doc := Parse("shader_type spatial;\nstruct S { int ]; float f; };\n")
+	if len(doc.Structs) != 1 || len(doc.Structs[0].Members) != 1 {
+		t.Errorf("expected the valid member to be parsed, got %+v", doc.Structs)
+	}
+}
+
+func TestPreprocessorDirectives(t *testing.T) {
+	src := `#pragma disable_preprocessor
+shader_type spatial;
+#include "res://common.gdshaderinc" // Shared helpers
+#define SCALE 2.0
+#define MIX(a, b) \
+	mix(a, b, 0.5)
+
+#ifdef SCALE
+float scaled(float x) { return x * SCALE; }
+#elif defined(OTHER)
+float scaled(float x) { return x; }
+#else
+float scaled(float x) { return 0.0; }
+#endif // SCALE
+
+void fragment() {
+	ALBEDO = vec3(MIX(scaled(1.0), 0.0));
+}
+`
+	doc := Parse(src)
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected parse errors: %v", doc.Errors)
+	}
+	if len(doc.Functions) != 2 {
+		t.Errorf("expected only the first branch to be parsed, got %d functions", len(doc.Functions))
+	}
+	if len(doc.Directives) != 8 {
+		t.Fatalf("expected 8 directives, got %d", len(doc.Directives))
+	}
+	define := doc.Directives[3]
+	if define.Name != "define" || define.Args != "MIX(a, b)  \tmix(a, b, 0.5)" {
+		t.Errorf("unexpected directive %q %q", define.Name, define.Args)
+	}
+	if define.Range.End != (Position{Line: 5, Column: 15}) {
+		t.Errorf("expected the continuation in the range, got %v", define.Range)
+	}
+	if doc.Directives[7].Args != "" {
+		t.Errorf("expected the comment to be dropped, got %q", doc.Directives[7].Args)
+	}
+	if errs := NewAnalyzer(doc).Analyze(); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	src = `shader_type spatial;
+#pragma unknown_option
+#foo
+#define
+#define F(a,
+#undef A B
+#include res://common.gdshaderinc
+#else
+#if
+#ifdef A
+#else
+#else
+#endif
+#ifndef B
+`
+	parseErrs, semErrs := analyze(src)
+	if len(parseErrs) > 0 {
+		t.Errorf("unexpected parse errors: %v", parseErrs)
+	}
+	for _, msg := range []string{
+		"unknown pragma 'unknown_option'",
+		"unknown preprocessor directive '#foo'",
+		"expected a macro name after #define",
+		"invalid parameter list for macro 'F'",
+		"unexpected 'B' after the macro name",
+		"expected a quoted path after #include",
+		"#else without #if",
+		"expected a condition after #if",
+		"#else after #else",
+		"#if without #endif",
+		"#ifndef without #endif",
+	} {
+		if !hasError(semErrs, msg) {
+			t.Errorf("expected %q, got %v", msg, semErrs)
+		}
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	files := map[string]string{
+		"res://noise.gdshaderinc": `#include "res://consts.gdshaderinc"
+uniform sampler2D noise_tex;
+float noise(vec2 uv) { return texture(noise_tex, uv).r * SCALE; }
+`,
+		"res://consts.gdshaderinc": "const float SCALE = 2.0;\n#define OCTAVES 4\n",
+		"res://loop_a.gdshaderinc": "#include \"res://loop_b.gdshaderinc\"\nfloat a() { return 1.0; }\n",
+		"res://loop_b.gdshaderinc": "#include \"res://loop_a.gdshaderinc\"\n",
+	}
+	resolve := func(from, path string) (string, *ShaderDocument) {
+		src, ok := files[path]
+		if !ok {
+			return path, nil
+		}
+		return path, Parse(src)
+	}
+	analyzeWith := func(src string) (*Analyzer, []*SemanticError) {
+		a := NewAnalyzer(Parse(src))
+		a.SetIncludeResolver("res://shader.gdshader", resolve)
+		return a, a.Analyze()
+	}
+
+	a, errs := analyzeWith(`shader_type spatial;
+#include "res://noise.gdshaderinc"
+#include "res://consts.gdshaderinc"
+void fragment() {
+	ALBEDO = vec3(noise(UV) * float(OCTAVES) + SCALE);
+}
+`)
+	if len(errs) > 0 {
+		t.Errorf("expected the included declarations to be known, got %v", errs)
+	}
+	if got := a.IncludedURIs(); !slices.Equal(got, []string{"res://consts.gdshaderinc", "res://noise.gdshaderinc"}) {
+		t.Errorf("expected the included files in insertion order, got %v", got)
+	}
+
+	_, errs = analyzeWith(`shader_type spatial;
+#include "res://noise.gdshaderinc"
+uniform float noise_tex;
+void fragment() {}
+`)
+	if len(errs) != 1 || errs[0].Code != CodeRedefined || errs[0].Message != "symbol 'noise_tex' already defined in 'noise.gdshaderinc'" ||
+		len(errs[0].Related) != 1 || errs[0].Related[0].URI != "res://noise.gdshaderinc" {
+		t.Errorf("expected a redefinition pointing at the included file, got %v", errs)
+	}
+
+	_, errs = analyzeWith(`shader_type spatial;
+#include "res://loop_a.gdshaderinc"
+#include "res://missing.gdshaderinc"
+void fragment() { ALBEDO = vec3(a()); }
+`)
+	if len(errs) != 1 || errs[0].Code != CodeCircularInclude || errs[0].Range.Start.Line != 1 ||
+		errs[0].Message != "circular include: 'loop_a.gdshaderinc' includes 'loop_b.gdshaderinc' includes 'loop_a.gdshaderinc'" {
+		t.Errorf("expected a circular include on the first directive, got %v", errs)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	src := `shader_type spatial;
+#pragma optimize
+uniform float speed;
+uniform float speed;
+struct Light { float energy; };
+struct Light { float range; };
+float twice(float x) { return x * 2.0; }
+float twice(float y) { return y; }
+
+void vertex() {
+	float f = missing;
+	unknown_t g;
+	ALBEDO = vec3(1.0);
+	return;
+	f = 1.0;
+}
+`
+	_, errs := analyze(src)
+	expected := map[string]string{
+		"unknown pragma 'optimize'":      CodePreprocessor,
+		"symbol 'speed' already defined": CodeRedefined,
+		"struct 'Light' already defined": CodeRedefined,
+		"function 'twice' with the same": CodeRedefined,
+		"undefined symbol 'missing'":     CodeUndefined,
+		"unknown type 'unknown_t'":       CodeUndefined,
+		"built-in 'ALBEDO' is not":       CodeUnavailable,
+		"unreachable code":               CodeUnreachable,
+	}
+	for prefix, code := range expected {
+		var found *SemanticError
+		for _, err := range errs {
+			if strings.HasPrefix(err.Message, prefix) {
+				found = err
+			}
+		}
+		if found == nil {
+			t.Errorf("expected an error starting with %q, got %v", prefix, errs)
+		} else if found.Code != code {
+			t.Errorf("expected code %s for %q, got %q", code, found.Message, found.Code)
+		}
+	}
+
+	// Redefinitions point at the first definition
+	firstLines := map[string]int{"speed": 2, "Light": 4, "twice": 6}
+	for _, err := range errs {
+		if err.Code != CodeRedefined {
+			continue
+		}
+		if len(err.Related) != 1 {
+			t.Errorf("expected the first definition for %q, got %v", err.Message, err.Related)
+			continue
+		}
+		for name, line := range firstLines {
+			if strings.Contains(err.Message, "'"+name+"'") && err.Related[0].Range.Start.Line != line {
+				t.Errorf("expected %s to be first defined on line %d, got %v", name, line, err.Related[0].Range)
+			}
+		}
+	}
+}
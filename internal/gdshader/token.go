@@ -1,6 +1,8 @@
 // Package gdshader provides a parser and semantic analyzer for Godot shader files (.gdshader).
 package gdshader
 
+import "sort"
+
 // TokenType represents the type of a token.
 type TokenType int
 
@@ -15,10 +17,14 @@ const (
 	TokenBlockComment // /* */
 	TokenDocComment   // /** */
 
+	// Preprocessor
+	TokenPreprocessor // #define ... up to the end of the line
+
 	// Literals
 	TokenIdent
-	TokenIntLit   // 123, 0x1F
-	TokenFloatLit // 1.5, 1e-3, 1.0f
+	TokenIntLit    // 123, 0x1F
+	TokenFloatLit  // 1.5, 1e-3, 1.0f
+	TokenStringLit // "Option" (only in hint_enum)
 
 	// Delimiters
 	TokenLParen    // (
@@ -172,12 +178,16 @@ func (t TokenType) String() string {
 		return "BLOCK_COMMENT"
 	case TokenDocComment:
 		return "DOC_COMMENT"
+	case TokenPreprocessor:
+		return "PREPROCESSOR"
 	case TokenIdent:
 		return "IDENT"
 	case TokenIntLit:
 		return "INT"
 	case TokenFloatLit:
 		return "FLOAT"
+	case TokenStringLit:
+		return "STRING"
 	case TokenLParen:
 		return "("
 	case TokenRParen:
@@ -486,6 +496,17 @@ func LookupIdent(ident string) TokenType {
 	return TokenIdent
 }
 
+// Keywords returns every keyword of the language, built-in type names
+// included, in alphabetical order.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // IsKeyword returns true if the token type is a keyword.
 func (t TokenType) IsKeyword() bool {
 	return t >= TokenShaderType && t <= TokenSamplerExternalOES
@@ -546,6 +546,9 @@ func CommonType(left, right *Type) *Type {
 	return nil
 }
 
+// SwizzleSets are the component names of each swizzle set, in order.
+var SwizzleSets = []string{"xyzw", "rgba", "stpq"}
+
 // ValidSwizzleChars contains all valid swizzle characters grouped by set.
 var ValidSwizzleChars = map[rune]int{
 	'x': 0, 'y': 1, 'z': 2, 'w': 3, // xyzw set
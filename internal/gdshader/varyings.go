@@ -0,0 +1,159 @@
+package gdshader
+
+import "slices"
+
+// Varyings pass values between the processor functions of a shader, which
+// run in order: vertex, then fragment, then light. Like Godot, a varying is
+// assigned in vertex or in fragment, but not in both, and is read in the
+// same function or in the ones after the one assigning it.
+
+// varyingAccess is a read or write of a varying in a function.
+type varyingAccess struct {
+	stage string // Processor function, "" in other functions
+	rng   Range
+	at    Position // Where it happens: writes at the end of their assignment
+	write bool
+}
+
+// checkVaryings reports varyings assigned where Godot rejects it, and
+// varyings read before the function assigning them runs or never assigned
+// at all. It runs after the function bodies have been analyzed.
+func (a *Analyzer) checkVaryings() {
+	stages := ShaderStages[a.shaderType]
+	if !slices.Contains(stages, "vertex") || !slices.Contains(stages, "fragment") {
+		return
+	}
+
+	accesses := make(map[*Symbol][]varyingAccess)
+	for _, fn := range a.doc.Functions {
+		if fn.Body == nil {
+			continue
+		}
+		stage := ""
+		if slices.Contains(stages, fn.Name) {
+			stage = fn.Name
+		}
+		a.collectVaryingAccesses(fn.Body, stage, accesses)
+	}
+
+	for _, decl := range a.doc.Varyings {
+		sym := a.globalScope.lookup(decl.Name)
+		if sym == nil || sym.Kind != SymbolVarying || sym.URI != "" || sym.NameRange != decl.NameRange {
+			continue
+		}
+		a.checkVaryingFlow(sym, accesses[sym])
+	}
+}
+
+// checkVaryingFlow checks the accesses of one varying, in document order.
+func (a *Analyzer) checkVaryingFlow(sym *Symbol, accesses []varyingAccess) {
+	// The function assigning the varying: vertex when it is assigned there,
+	// as Godot then rejects assignments in fragment
+	assignedIn := ""
+	for _, acc := range accesses {
+		if acc.write && (acc.stage == "vertex" || (acc.stage == "fragment" && assignedIn == "")) {
+			assignedIn = acc.stage
+		}
+	}
+
+	var firstWrite *varyingAccess
+	for i, acc := range accesses {
+		if acc.write && acc.stage == assignedIn && firstWrite == nil {
+			firstWrite = &accesses[i]
+		}
+	}
+
+	for _, acc := range accesses {
+		switch {
+		case acc.write && acc.stage == "":
+			a.addError(acc.rng, "varying '%s' can only be assigned in the vertex or fragment function", sym.Name).Code = CodeVaryingStage
+		case acc.write && acc.stage != "vertex" && acc.stage != "fragment":
+			a.addError(acc.rng, "varying '%s' cannot be assigned in the %s function, only in vertex or fragment", sym.Name, acc.stage).Code = CodeVaryingStage
+		case acc.write && acc.stage != assignedIn:
+			a.addError(acc.rng, "varying '%s' is assigned in the %s function, so it cannot be assigned in %s", sym.Name, assignedIn, acc.stage).Code = CodeVaryingStage
+		case acc.write && acc.stage == "vertex" && isBoolBased(sym.Type):
+			a.addError(acc.rng, "varying '%s' of type '%s' can only be assigned in the fragment function", sym.Name, sym.Type.String()).Code = CodeVaryingStage
+		}
+	}
+
+	for _, acc := range accesses {
+		if acc.write || acc.stage == "" {
+			continue
+		}
+		switch {
+		case assignedIn == "":
+			a.addWarning(acc.rng, "varying '%s' is read but never assigned", sym.Name).Code = CodeVaryingStage
+			return // Once per varying
+		case acc.stage == "vertex" && assignedIn == "fragment":
+			a.addError(acc.rng, "varying '%s' is read in the vertex function but assigned in fragment, which runs after it", sym.Name).Code = CodeVaryingStage
+		case acc.stage == assignedIn && positionBefore(acc.at, firstWrite.at):
+			a.addWarning(acc.rng, "varying '%s' is read before it is assigned in the %s function", sym.Name, acc.stage).Code = CodeVaryingStage
+		}
+	}
+}
+
+// collectVaryingAccesses records the reads and writes of varyings in a
+// function body. Compound assignments and increments both read and write.
+func (a *Analyzer) collectVaryingAccesses(body Node, stage string, accesses map[*Symbol][]varyingAccess) {
+	writes := make(map[*IdentExpr]Position) // Targets of assignments, with their end
+	compound := make(map[*IdentExpr]bool)   // Targets also read
+
+	Inspect(body, func(n Node) bool {
+		switch e := n.(type) {
+		case *BinaryExpr:
+			if isAssignOperator(e.Operator) {
+				if ident := assignedIdent(e.Left); ident != nil {
+					writes[ident] = e.Range.End
+					compound[ident] = e.Operator != "="
+				}
+			}
+		case *UnaryExpr:
+			if e.Operator == "++" || e.Operator == "--" {
+				if ident := assignedIdent(e.Operand); ident != nil {
+					writes[ident] = e.Range.End
+					compound[ident] = true
+				}
+			}
+		case *IdentExpr:
+			sym := a.symbols[e]
+			if sym == nil || sym.Kind != SymbolVarying {
+				return true
+			}
+			end, written := writes[e]
+			if !written || compound[e] {
+				accesses[sym] = append(accesses[sym], varyingAccess{stage: stage, rng: e.Range, at: e.Range.Start})
+			}
+			if written {
+				accesses[sym] = append(accesses[sym], varyingAccess{stage: stage, rng: e.Range, at: end, write: true})
+			}
+		}
+		return true
+	})
+}
+
+// assignedIdent returns the variable an assignment target writes to, as in
+// v, v.xy or v[i], or nil when it is not a variable.
+func assignedIdent(expr Expr) *IdentExpr {
+	for {
+		switch e := expr.(type) {
+		case *IdentExpr:
+			return e
+		case *MemberExpr:
+			expr = e.Expr
+		case *IndexExpr:
+			expr = e.Expr
+		default:
+			return nil
+		}
+	}
+}
+
+// isBoolBased reports whether t is a bool scalar or vector.
+func isBoolBased(t *Type) bool {
+	return t.Kind == TypeKindBool || (t.IsVector() && t.ComponentType().Kind == TypeKindBool)
+}
+
+// positionBefore reports whether p comes before q.
+func positionBefore(p, q Position) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Column < q.Column)
+}
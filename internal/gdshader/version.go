@@ -0,0 +1,54 @@
+package gdshader
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a Godot version such as 4.3. The built-in variables, render
+// modes and uniform hints added after 4.0 record the version they appeared
+// in, so that a shader is checked against the version a project targets.
+type Version struct {
+	Major, Minor int
+}
+
+// LatestVersion is the newest Godot version the built-in tables describe,
+// used unless a project targets an older one.
+var LatestVersion = Version{4, 4}
+
+// ParseVersion parses a version such as "4.3".
+func ParseVersion(s string) (Version, bool) {
+	major, minor, ok := strings.Cut(s, ".")
+	if !ok {
+		return Version{}, false
+	}
+	v := Version{}
+	var err error
+	if v.Major, err = strconv.Atoi(major); err != nil {
+		return Version{}, false
+	}
+	if v.Minor, err = strconv.Atoi(minor); err != nil {
+		return Version{}, false
+	}
+	return v, true
+}
+
+// String returns the version as "major.minor".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Before reports whether v is older than other.
+func (v Version) Before(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// available reports whether something added in since exists in v. The zero
+// version means it has always existed.
+func (v Version) available(since Version) bool {
+	return !v.Before(since)
+}
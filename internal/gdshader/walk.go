@@ -0,0 +1,192 @@
+package gdshader
+
+// Inspect traverses the AST rooted at node in depth-first order, calling f
+// for each node. If f returns false, the children of that node are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *StructDecl:
+		for _, member := range n.Members {
+			Inspect(member, f)
+		}
+	case *StructMember:
+		inspectType(n.Type, f)
+	case *UniformDecl:
+		inspectType(n.Type, f)
+		for _, hint := range n.Hints {
+			Inspect(hint, f)
+		}
+		inspectExpr(n.DefaultValue, f)
+	case *Hint:
+		inspectExprs(n.Args, f)
+	case *VaryingDecl:
+		inspectType(n.Type, f)
+	case *ConstDecl:
+		inspectType(n.Type, f)
+		inspectExpr(n.Value, f)
+	case *FunctionDecl:
+		inspectType(n.ReturnType, f)
+		for _, param := range n.Params {
+			Inspect(param, f)
+		}
+		if n.Body != nil {
+			Inspect(n.Body, f)
+		}
+	case *ParamDecl:
+		inspectType(n.Type, f)
+	case *TypeSpec:
+		inspectExpr(n.ArraySize, f)
+
+	case *BlockStmt:
+		inspectStmts(n.Stmts, f)
+	case *ExprStmt:
+		inspectExpr(n.Expr, f)
+	case *VarDeclStmt:
+		inspectType(n.Type, f)
+		for _, decl := range n.Decls {
+			Inspect(decl, f)
+		}
+	case *VarDecl:
+		inspectExpr(n.ArraySize, f)
+		inspectExpr(n.Init, f)
+	case *IfStmt:
+		inspectExpr(n.Cond, f)
+		inspectStmt(n.Then, f)
+		inspectStmt(n.Else, f)
+	case *ForStmt:
+		inspectStmt(n.Init, f)
+		inspectExpr(n.Cond, f)
+		inspectExpr(n.Post, f)
+		inspectStmt(n.Body, f)
+	case *WhileStmt:
+		inspectExpr(n.Cond, f)
+		inspectStmt(n.Body, f)
+	case *DoWhileStmt:
+		inspectStmt(n.Body, f)
+		inspectExpr(n.Cond, f)
+	case *SwitchStmt:
+		inspectExpr(n.Expr, f)
+		for _, c := range n.Cases {
+			Inspect(c, f)
+		}
+	case *CaseClause:
+		inspectExprs(n.Values, f)
+		inspectStmts(n.Body, f)
+	case *ReturnStmt:
+		inspectExpr(n.Value, f)
+
+	case *BinaryExpr:
+		inspectExpr(n.Left, f)
+		inspectExpr(n.Right, f)
+	case *UnaryExpr:
+		inspectExpr(n.Operand, f)
+	case *TernaryExpr:
+		inspectExpr(n.Cond, f)
+		inspectExpr(n.Then, f)
+		inspectExpr(n.Else, f)
+	case *CallExpr:
+		inspectExpr(n.Func, f)
+		inspectExprs(n.Args, f)
+	case *IndexExpr:
+		inspectExpr(n.Expr, f)
+		inspectExpr(n.Index, f)
+	case *MemberExpr:
+		inspectExpr(n.Expr, f)
+	case *ArrayExpr:
+		inspectType(n.Type, f)
+		inspectExprs(n.Elements, f)
+	}
+}
+
+// InspectDocument calls Inspect on every declaration of a document.
+func InspectDocument(doc *ShaderDocument, f func(Node) bool) {
+	for _, decl := range doc.Structs {
+		Inspect(decl, f)
+	}
+	for _, decl := range doc.Uniforms {
+		Inspect(decl, f)
+	}
+	for _, decl := range doc.Varyings {
+		Inspect(decl, f)
+	}
+	for _, decl := range doc.Constants {
+		Inspect(decl, f)
+	}
+	for _, decl := range doc.Functions {
+		Inspect(decl, f)
+	}
+}
+
+// FindNodeAt returns the innermost expression, variable or parameter
+// declaration, or type name at pos, or nil. Statements are not returned, as
+// most of their ranges only cover their first token.
+func (doc *ShaderDocument) FindNodeAt(pos Position) Node {
+	var found Node
+	InspectDocument(doc, func(node Node) bool {
+		switch node.(type) {
+		case Expr, *VarDecl, *ParamDecl, *TypeSpec:
+			if containsPos(node.GetRange(), pos) {
+				found = node
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// FunctionAt returns the function whose body contains pos, or nil.
+func (doc *ShaderDocument) FunctionAt(pos Position) *FunctionDecl {
+	for _, fn := range doc.Functions {
+		if fn.Body != nil && containsPos(fn.Body.Range, pos) {
+			return fn
+		}
+	}
+	return nil
+}
+
+// containsPos reports whether pos is within r, both ends included.
+func containsPos(r Range, pos Position) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Column < r.Start.Column {
+		return false
+	}
+	if pos.Line == r.End.Line && pos.Column > r.End.Column {
+		return false
+	}
+	return true
+}
+
+func inspectType(t *TypeSpec, f func(Node) bool) {
+	if t != nil {
+		Inspect(t, f)
+	}
+}
+
+func inspectExpr(e Expr, f func(Node) bool) {
+	if e != nil {
+		Inspect(e, f)
+	}
+}
+
+func inspectExprs(exprs []Expr, f func(Node) bool) {
+	for _, e := range exprs {
+		inspectExpr(e, f)
+	}
+}
+
+func inspectStmt(s Stmt, f func(Node) bool) {
+	if s != nil {
+		Inspect(s, f)
+	}
+}
+
+func inspectStmts(stmts []Stmt, f func(Node) bool) {
+	for _, s := range stmts {
+		inspectStmt(s, f)
+	}
+}
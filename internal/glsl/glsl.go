@@ -0,0 +1,597 @@
+// Package glsl parses the GLSL files of Godot's RenderingDevice shaders,
+// such as compute shaders: a #[compute] section tag, a #version directive
+// and GLSL code, which Godot compiles with glslang rather than its own
+// shader compiler. Parsing is syntax-level: it finds the sections of a
+// file, its top-level declarations with their members, unbalanced brackets
+// and the kind of each token for highlighting, but checks neither
+// expressions nor types.
+package glsl
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// Ranges and positions are those of the shader parser, whose lexer
+// tokenizes GLSL as well.
+type (
+	Range    = gdshader.Range
+	Position = gdshader.Position
+)
+
+// Document is a parsed GLSL file.
+type Document struct {
+	Sections     []*Section
+	Declarations []*Declaration // Of every section, in order
+	Highlights   []Highlight    // In order, each on a single line
+	Errors       []ParseError
+}
+
+// Section is a part of a file compiled on its own, opened by a tag such as
+// #[compute] or #[vertex]. Code before the first tag belongs to no section.
+type Section struct {
+	Name     string // e.g., "compute"
+	TagRange Range  // Range of the #[...] tag
+	Range    Range  // From the tag to the next one or the end of the file
+}
+
+// SectionNames are the section tags Godot compiles: the stages, and
+// versions, which lists the defines of each version of the shader.
+var SectionNames = []string{"compute", "vertex", "fragment", "tesselation_control", "tesselation_evaluation", "versions"}
+
+// DeclarationKind is the kind of a declaration.
+type DeclarationKind int
+
+const (
+	DeclarationFunction DeclarationKind = iota // void main() { ... }
+	DeclarationStruct                          // struct Particle { ... };
+	DeclarationBlock                           // layout(std430) buffer Data { ... } data;
+	DeclarationVariable                        // Uniforms, shared variables, inputs, outputs and members
+	DeclarationConstant                        // const uint COUNT = 64;
+)
+
+// Declaration is a top-level declaration, or a member of a struct or block.
+type Declaration struct {
+	Kind      DeclarationKind
+	Name      string // The instance name of blocks that have one
+	NameRange Range
+	Range     Range          // The whole declaration, through its ';' or '}'
+	Detail    string         // e.g., "layout(binding = 0, rgba8) uniform image2D", or "float (vec2 uv)" for functions
+	Section   *Section       // nil before the first section tag
+	Members   []*Declaration // Of structs and blocks
+}
+
+// ParseError is a syntax error.
+type ParseError struct {
+	Range   Range
+	Message string
+}
+
+// HighlightKind classifies a token for highlighting.
+type HighlightKind int
+
+const (
+	HighlightKeyword   HighlightKind = iota // Keywords and qualifiers, layout included
+	HighlightDirective                      // #version, #define and section tags
+	HighlightType                           // Built-in types, structs and blocks
+	HighlightFunction                       // Functions declared or called
+	HighlightVariable                       // Global variables and gl_ built-ins
+	HighlightProperty                       // Members, after a '.'
+	HighlightNumber
+	HighlightString
+	HighlightComment
+)
+
+// Highlight is the kind of a token, or of a line of a token spanning
+// several lines.
+type Highlight struct {
+	Range       Range
+	Kind        HighlightKind
+	Declaration bool // The name of a declaration
+}
+
+// keywords are the GLSL keywords the shader lexer reads as identifiers.
+var keywords = map[string]bool{
+	"layout": true, "buffer": true, "shared": true, "attribute": true,
+	"coherent": true, "volatile": true, "restrict": true, "readonly": true, "writeonly": true,
+	"centroid": true, "sample": true, "patch": true, "noperspective": true,
+	"invariant": true, "precise": true, "precision": true, "subroutine": true,
+}
+
+// shaderKeywords are the keywords of Godot's shading language that are
+// identifiers in GLSL.
+var shaderKeywords = map[string]bool{
+	"shader_type": true, "render_mode": true, "global": true, "group_uniforms": true,
+}
+
+// typePattern matches the GLSL types the shader lexer reads as identifiers.
+var typePattern = regexp.MustCompile(`^(double|atomic_uint|sampler(Shadow)?|d?mat[234](x[234])?|dvec[234]|[iu]?(sampler|image|texture)(1D|2D|3D|Cube|2DRect|Buffer|2DMS)(Array)?(Shadow)?|[iu]?subpassInput(MS)?)$`)
+
+// IsType reports whether a name is a built-in GLSL type.
+func IsType(name string) bool {
+	return gdshader.LookupIdent(name).IsType() || typePattern.MatchString(name)
+}
+
+// Parse parses a GLSL file. A byte order mark is skipped without counting
+// as a column, as the shader lexer does.
+func Parse(content string) *Document {
+	content = strings.TrimPrefix(content, "\uFEFF")
+	p := &parser{
+		content:    content,
+		lineStarts: []int{0},
+		doc:        &Document{},
+		names:      make(map[string]HighlightKind),
+		declared:   make(map[Range]bool),
+	}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			p.lineStarts = append(p.lineStarts, i+1)
+		}
+	}
+
+	tokens := gdshader.NewLexer(content).Tokenize()
+	var section *Section
+	var code []gdshader.Token // Of the current section
+	flush := func(end Position) {
+		if section != nil {
+			section.Range.End = end
+		}
+		if section == nil || section.Name != "versions" {
+			p.checkBrackets(code)
+			p.declarations(section, code)
+		}
+		code = nil
+	}
+	for _, tok := range tokens {
+		switch tok.Type {
+		case gdshader.TokenEOF:
+			flush(p.position(len(content)))
+		case gdshader.TokenNewline, gdshader.TokenLineComment, gdshader.TokenBlockComment, gdshader.TokenDocComment:
+		case gdshader.TokenPreprocessor:
+			if strings.HasPrefix(tok.Literal, "#[") {
+				flush(p.tokenRange(tok).Start)
+				section = p.section(tok)
+				p.doc.Sections = append(p.doc.Sections, section)
+			}
+		case gdshader.TokenError:
+			p.tokenError(tok)
+		default:
+			code = append(code, tok)
+		}
+	}
+	p.highlight(tokens)
+	sort.SliceStable(p.doc.Errors, func(i, j int) bool {
+		return before(p.doc.Errors[i].Range.Start, p.doc.Errors[j].Range.Start)
+	})
+	return p.doc
+}
+
+// parser holds the state of parsing a document.
+type parser struct {
+	content    string
+	lineStarts []int // Offset of the start of each line
+	doc        *Document
+
+	names    map[string]HighlightKind // Of the names declared at the top level
+	declared map[Range]bool           // Ranges of the names of declarations
+}
+
+// declare records a name declared at the top level for highlighting.
+func (p *parser) declare(tok gdshader.Token, kind HighlightKind) {
+	p.names[tok.Literal] = kind
+	p.declared[p.tokenRange(tok)] = true
+}
+
+// offset returns the offset of the start of a token.
+func (p *parser) offset(tok gdshader.Token) int {
+	if tok.Line < 1 || tok.Line > len(p.lineStarts) {
+		return len(p.content)
+	}
+	return min(p.lineStarts[tok.Line-1]+tok.Column-1, len(p.content))
+}
+
+// position returns the position of an offset.
+func (p *parser) position(offset int) Position {
+	line := sort.Search(len(p.lineStarts), func(i int) bool { return p.lineStarts[i] > offset }) - 1
+	return Position{Line: line, Column: offset - p.lineStarts[line]}
+}
+
+// tokenRange returns the range of a token, which may span several lines.
+func (p *parser) tokenRange(tok gdshader.Token) Range {
+	start := p.offset(tok)
+	return Range{Start: p.position(start), End: p.position(min(start+len(tok.Literal), len(p.content)))}
+}
+
+// rangeOf returns the range from the start of a token to the end of another.
+func (p *parser) rangeOf(first, last gdshader.Token) Range {
+	return Range{Start: p.tokenRange(first).Start, End: p.tokenRange(last).End}
+}
+
+// text returns the source from the start of a token to the end of another,
+// with each run of whitespace as a single space.
+func (p *parser) text(first, last gdshader.Token) string {
+	start, end := p.offset(first), p.offset(last)+len(last.Literal)
+	if start >= end {
+		return ""
+	}
+	return strings.Join(strings.Fields(p.content[start:end]), " ")
+}
+
+// tokensText returns the source of tokens, or "" when there are none.
+func (p *parser) tokensText(toks []gdshader.Token) string {
+	if len(toks) == 0 {
+		return ""
+	}
+	return p.text(toks[0], toks[len(toks)-1])
+}
+
+func (p *parser) error(r Range, format string, args ...any) {
+	p.doc.Errors = append(p.doc.Errors, ParseError{Range: r, Message: fmt.Sprintf(format, args...)})
+}
+
+// tokenError reports a token the lexer could not read.
+func (p *parser) tokenError(tok gdshader.Token) {
+	switch {
+	case strings.HasPrefix(tok.Literal, "/*"):
+		p.error(p.tokenRange(tok), "unterminated comment")
+	case strings.HasPrefix(tok.Literal, `"`):
+		p.error(p.tokenRange(tok), "unterminated string")
+	default:
+		p.error(p.tokenRange(tok), "unexpected character %q", tok.Literal)
+	}
+}
+
+// section returns the section a #[...] tag opens, reporting malformed and
+// unknown tags.
+func (p *parser) section(tok gdshader.Token) *Section {
+	tag := strings.TrimSpace(tok.Literal)
+	rng := p.tokenRange(tok)
+	rng.End = p.position(p.offset(tok) + len(tag))
+	s := &Section{TagRange: rng, Range: rng}
+
+	name, ok := strings.CutSuffix(tag[len("#["):], "]")
+	s.Name = strings.TrimSpace(name)
+	switch {
+	case !ok:
+		p.error(rng, "expected ']' to close the section tag")
+	case !slices.Contains(SectionNames, s.Name):
+		p.error(rng, "unknown section %q; expected one of %s", s.Name, strings.Join(SectionNames, ", "))
+	}
+	return s
+}
+
+// closers maps opening brackets to their closing ones.
+var closers = map[gdshader.TokenType]string{
+	gdshader.TokenLParen:   ")",
+	gdshader.TokenLBracket: "]",
+	gdshader.TokenLBrace:   "}",
+}
+
+// checkBrackets reports the brackets of a section that are not closed, or
+// that close nothing. A bracket closing one opened before unclosed ones
+// closes it, so that a single missing bracket is reported once.
+func (p *parser) checkBrackets(toks []gdshader.Token) {
+	var open []gdshader.Token
+	for _, tok := range toks {
+		switch tok.Type {
+		case gdshader.TokenLParen, gdshader.TokenLBracket, gdshader.TokenLBrace:
+			open = append(open, tok)
+		case gdshader.TokenRParen, gdshader.TokenRBracket, gdshader.TokenRBrace:
+			i := len(open) - 1
+			for i >= 0 && closers[open[i].Type] != tok.Literal {
+				i--
+			}
+			if i < 0 {
+				p.error(p.tokenRange(tok), "unmatched '%s'", tok.Literal)
+				continue
+			}
+			for _, unclosed := range open[i+1:] {
+				p.error(p.tokenRange(unclosed), "'%s' is never closed", unclosed.Literal)
+			}
+			open = open[:i]
+		}
+	}
+	for _, unclosed := range open {
+		p.error(p.tokenRange(unclosed), "'%s' is never closed", unclosed.Literal)
+	}
+}
+
+// isName reports whether a token is an identifier naming something, rather
+// than a keyword or type.
+func isName(tok gdshader.Token) bool {
+	if tok.Type != gdshader.TokenIdent {
+		return shaderKeywords[tok.Literal]
+	}
+	return !keywords[tok.Literal] && !IsType(tok.Literal)
+}
+
+// matching returns the index of the bracket closing toks[open], or the last
+// index when it is never closed.
+func matching(toks []gdshader.Token, open int) int {
+	depth := 0
+	for i := open; i < len(toks); i++ {
+		switch toks[i].Type {
+		case gdshader.TokenLParen, gdshader.TokenLBracket, gdshader.TokenLBrace:
+			depth++
+		case gdshader.TokenRParen, gdshader.TokenRBracket, gdshader.TokenRBrace:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(toks) - 1
+}
+
+// split splits tokens at the separators outside of brackets, dropping the
+// separators.
+func split(toks []gdshader.Token, sep gdshader.TokenType) [][]gdshader.Token {
+	var parts [][]gdshader.Token
+	depth, start := 0, 0
+	for i, tok := range toks {
+		switch tok.Type {
+		case gdshader.TokenLParen, gdshader.TokenLBracket, gdshader.TokenLBrace:
+			depth++
+		case gdshader.TokenRParen, gdshader.TokenRBracket, gdshader.TokenRBrace:
+			depth = max(depth-1, 0)
+		case sep:
+			if depth == 0 {
+				parts = append(parts, toks[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(toks) {
+		parts = append(parts, toks[start:])
+	}
+	return parts
+}
+
+// declarationEnd returns the index of the last token of the declaration
+// starting at toks[start]: its ';', the '}' closing a function body, or the
+// last token.
+func declarationEnd(toks []gdshader.Token, start int) int {
+	for i := start; i < len(toks); i++ {
+		switch toks[i].Type {
+		case gdshader.TokenLParen, gdshader.TokenLBracket:
+			i = matching(toks, i)
+		case gdshader.TokenLBrace:
+			end := matching(toks, i)
+			if i > start && toks[i-1].Type == gdshader.TokenRParen {
+				return end // Function body
+			}
+			i = end
+		case gdshader.TokenSemicolon:
+			return i
+		}
+	}
+	return len(toks) - 1
+}
+
+// declarations adds the top-level declarations of the code of a section.
+func (p *parser) declarations(section *Section, toks []gdshader.Token) {
+	for start := 0; start < len(toks); {
+		end := declarationEnd(toks, start)
+		for _, decl := range p.declaration(toks[start : end+1]) {
+			if decl.Kind == DeclarationVariable || decl.Kind == DeclarationConstant {
+				p.names[decl.Name] = HighlightVariable
+				p.declared[decl.NameRange] = true
+			}
+			decl.Section = section
+			p.doc.Declarations = append(p.doc.Declarations, decl)
+		}
+		start = end + 1
+	}
+}
+
+// declaration returns the declarations of the tokens of a top-level
+// declaration: one, or one per declarator of a variable. Function
+// prototypes and declarations naming nothing, such as the
+// layout(local_size_x = 64) in; of compute shaders, have none.
+func (p *parser) declaration(toks []gdshader.Token) []*Declaration {
+	rng := p.rangeOf(toks[0], toks[len(toks)-1])
+
+	for i := 0; i < len(toks); i++ {
+		switch toks[i].Type {
+		case gdshader.TokenAssign:
+			return p.variables(toks, rng)
+		case gdshader.TokenLParen:
+			if i == 0 || !isName(toks[i-1]) {
+				i = matching(toks, i) // layout(...)
+				continue
+			}
+			// A function, or its prototype
+			closing := matching(toks, i)
+			if closing+1 >= len(toks) || toks[closing+1].Type != gdshader.TokenLBrace {
+				return nil
+			}
+			p.declare(toks[i-1], HighlightFunction)
+			return []*Declaration{{
+				Kind:      DeclarationFunction,
+				Name:      toks[i-1].Literal,
+				NameRange: p.tokenRange(toks[i-1]),
+				Range:     rng,
+				Detail:    strings.TrimSpace(p.tokensText(toks[:i-1]) + " " + p.text(toks[i], toks[closing])),
+			}}
+		case gdshader.TokenLBrace:
+			return p.aggregate(toks, i, rng)
+		}
+	}
+	return p.variables(toks, rng)
+}
+
+// aggregate returns the declaration of a struct or block whose members are
+// between the brace at toks[brace] and the one closing it.
+func (p *parser) aggregate(toks []gdshader.Token, brace int, rng Range) []*Declaration {
+	closing := matching(toks, brace)
+	decl := &Declaration{Kind: DeclarationBlock, Range: rng}
+	globalMembers := false
+	if i := slices.IndexFunc(toks[:brace], func(tok gdshader.Token) bool { return tok.Type == gdshader.TokenStruct }); i >= 0 {
+		if i+1 == brace || !isName(toks[i+1]) {
+			return nil
+		}
+		decl.Kind, decl.Detail = DeclarationStruct, "struct"
+		decl.Name, decl.NameRange = toks[i+1].Literal, p.tokenRange(toks[i+1])
+		p.declare(toks[i+1], HighlightType)
+	} else {
+		if brace == 0 || !isName(toks[brace-1]) {
+			return nil
+		}
+		p.declare(toks[brace-1], HighlightType)
+		// Named by its instance when it has one; otherwise its members are
+		// globals
+		if closing+1 < len(toks) && isName(toks[closing+1]) {
+			decl.Detail = p.tokensText(toks[:brace])
+			decl.Name, decl.NameRange = toks[closing+1].Literal, p.tokenRange(toks[closing+1])
+			p.declare(toks[closing+1], HighlightVariable)
+		} else {
+			decl.Detail = p.tokensText(toks[:brace-1])
+			decl.Name, decl.NameRange = toks[brace-1].Literal, p.tokenRange(toks[brace-1])
+			globalMembers = true
+		}
+	}
+
+	for _, member := range split(toks[brace+1:max(closing, brace+1)], gdshader.TokenSemicolon) {
+		if len(member) == 0 {
+			continue
+		}
+		decl.Members = append(decl.Members, p.variables(member, p.rangeOf(member[0], member[len(member)-1]))...)
+	}
+	for _, member := range decl.Members {
+		p.declared[member.NameRange] = true
+		if globalMembers {
+			p.names[member.Name] = HighlightVariable
+		}
+	}
+	// Members end at their ';'
+	for _, member := range decl.Members {
+		if end := member.Range.End; end.Line < len(p.lineStarts) {
+			if offset := p.lineStarts[end.Line] + end.Column; offset < len(p.content) && p.content[offset] == ';' {
+				member.Range.End.Column++
+			}
+		}
+	}
+	return []*Declaration{decl}
+}
+
+// variables returns the variables or constants a declaration declares, one
+// per declarator, e.g. a and b for "float a, b = 1.0;".
+func (p *parser) variables(toks []gdshader.Token, rng Range) []*Declaration {
+	if n := len(toks); n > 0 && toks[n-1].Type == gdshader.TokenSemicolon {
+		toks = toks[:n-1]
+	}
+	kind := DeclarationVariable
+	if slices.ContainsFunc(toks, func(tok gdshader.Token) bool { return tok.Type == gdshader.TokenConst }) {
+		kind = DeclarationConstant
+	}
+
+	var decls []*Declaration
+	var typeText string
+	for i, declarator := range split(toks, gdshader.TokenComma) {
+		if parts := split(declarator, gdshader.TokenAssign); len(parts) > 0 {
+			declarator = parts[0] // Without its initializer
+		}
+		// The name is the last one outside of brackets, before any array size
+		name := -1
+		for j := 0; j < len(declarator); j++ {
+			switch {
+			case declarator[j].Type == gdshader.TokenLParen || declarator[j].Type == gdshader.TokenLBracket:
+				j = matching(declarator, j)
+			case isName(declarator[j]):
+				name = j
+			}
+		}
+		if name < 0 {
+			continue
+		}
+		if i == 0 {
+			typeText = p.tokensText(declarator[:name])
+		}
+		decls = append(decls, &Declaration{
+			Kind:      kind,
+			Name:      declarator[name].Literal,
+			NameRange: p.tokenRange(declarator[name]),
+			Range:     rng,
+			Detail:    typeText + p.tokensText(declarator[name+1:]),
+		})
+	}
+	return decls
+}
+
+// highlight classifies the tokens of the document.
+func (p *parser) highlight(tokens []gdshader.Token) {
+	add := func(tok gdshader.Token, kind HighlightKind) {
+		rng := p.tokenRange(tok)
+		// One highlight per line
+		for line := rng.Start.Line; line <= rng.End.Line; line++ {
+			h := Highlight{Range: rng, Kind: kind, Declaration: p.declared[rng]}
+			if line > rng.Start.Line {
+				h.Range.Start = Position{Line: line}
+			}
+			if line < rng.End.Line {
+				end := len(p.content)
+				if line+1 < len(p.lineStarts) {
+					end = p.lineStarts[line+1] - 1
+				}
+				h.Range.End = p.position(end)
+				if end > 0 && p.content[end-1] == '\r' {
+					h.Range.End.Column--
+				}
+			}
+			if h.Range.End.Column > h.Range.Start.Column || h.Range.End.Line > h.Range.Start.Line {
+				p.doc.Highlights = append(p.doc.Highlights, h)
+			}
+		}
+	}
+
+	var code []gdshader.Token
+	for _, tok := range tokens {
+		switch tok.Type {
+		case gdshader.TokenLineComment, gdshader.TokenBlockComment, gdshader.TokenDocComment:
+			add(tok, HighlightComment)
+		case gdshader.TokenPreprocessor:
+			add(tok, HighlightDirective)
+		case gdshader.TokenNewline, gdshader.TokenError, gdshader.TokenEOF:
+		default:
+			code = append(code, tok)
+		}
+	}
+	for i, tok := range code {
+		switch {
+		case tok.Type == gdshader.TokenStringLit:
+			add(tok, HighlightString)
+		case tok.Type == gdshader.TokenIntLit || tok.Type == gdshader.TokenFloatLit:
+			add(tok, HighlightNumber)
+		case tok.Type.IsType() || (tok.Type == gdshader.TokenIdent && IsType(tok.Literal)):
+			add(tok, HighlightType)
+		case tok.Type.IsKeyword() && !shaderKeywords[tok.Literal], keywords[tok.Literal]:
+			add(tok, HighlightKeyword)
+		case !isName(tok):
+		case i > 0 && code[i-1].Type == gdshader.TokenDot:
+			add(tok, HighlightProperty)
+		case i+1 < len(code) && code[i+1].Type == gdshader.TokenLParen && p.names[tok.Literal] != HighlightType:
+			add(tok, HighlightFunction) // Calls, to built-ins too
+		default:
+			if kind, ok := p.names[tok.Literal]; ok {
+				add(tok, kind)
+			} else if p.declared[p.tokenRange(tok)] {
+				add(tok, HighlightProperty) // The declaration of a member
+			} else if strings.HasPrefix(tok.Literal, "gl_") {
+				add(tok, HighlightVariable)
+			}
+		}
+	}
+	sort.SliceStable(p.doc.Highlights, func(i, j int) bool {
+		return before(p.doc.Highlights[i].Range.Start, p.doc.Highlights[j].Range.Start)
+	})
+}
+
+// before reports whether a position is before another.
+func before(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
@@ -0,0 +1,238 @@
+package glsl
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const computeShader = `#[compute]
+#version 450
+
+// Invocations in the (x, y, z) dimension
+layout(local_size_x = 64, local_size_y = 1, local_size_z = 1) in;
+
+struct Particle {
+	vec3 position;
+	float life;
+};
+
+layout(set = 0, binding = 0, std430) restrict buffer ParticleBuffer {
+	Particle particles[];
+} particle_buffer;
+
+layout(push_constant, std430) uniform Params {
+	float delta;
+	uint count;
+};
+
+layout(set = 0, binding = 1, rgba8) uniform image2D output_image;
+shared float totals[64], maximum;
+const uint STEPS = 4;
+
+float fade(float life) {
+	return clamp(life / 2.0, 0.0, 1.0);
+}
+
+void main() {
+	uint index = gl_GlobalInvocationID.x;
+	if (index >= count) {
+		return;
+	}
+	particle_buffer.particles[index].life -= delta;
+	imageStore(output_image, ivec2(index, 0), vec4(fade(particle_buffer.particles[index].life)));
+}
+`
+
+func TestParseDeclarations(t *testing.T) {
+	doc := Parse(computeShader)
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", doc.Errors)
+	}
+	if len(doc.Sections) != 1 || doc.Sections[0].Name != "compute" {
+		t.Fatalf("expected a compute section, got %+v", doc.Sections)
+	}
+
+	var b strings.Builder
+	var render func(decls []*Declaration, depth int)
+	render = func(decls []*Declaration, depth int) {
+		for _, decl := range decls {
+			fmt.Fprintf(&b, "%s%d %s: %s\n", strings.Repeat("  ", depth), decl.Kind, decl.Name, decl.Detail)
+			render(decl.Members, depth+1)
+		}
+	}
+	render(doc.Declarations, 0)
+	expected := `1 Particle: struct
+  3 position: vec3
+  3 life: float
+2 particle_buffer: layout(set = 0, binding = 0, std430) restrict buffer ParticleBuffer
+  3 particles: Particle[]
+2 Params: layout(push_constant, std430) uniform
+  3 delta: float
+  3 count: uint
+3 output_image: layout(set = 0, binding = 1, rgba8) uniform image2D
+3 totals: shared float[64]
+3 maximum: shared float
+4 STEPS: const uint
+0 fade: float (float life)
+0 main: void ()
+`
+	if b.String() != expected {
+		t.Errorf("expected declarations:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// Names are within the declarations, which end at their ';' or '}'
+	lines := strings.Split(computeShader, "\n")
+	for _, decl := range doc.Declarations {
+		name := decl.NameRange
+		if got := lines[name.Start.Line][name.Start.Column:name.End.Column]; got != decl.Name {
+			t.Errorf("%s: name range selects %q", decl.Name, got)
+		}
+		if end := decl.Range.End; !strings.ContainsAny(lines[end.Line][end.Column-1:end.Column], ";}") {
+			t.Errorf("%s: range %+v does not end the declaration", decl.Name, decl.Range)
+		}
+		if decl.Section != doc.Sections[0] {
+			t.Errorf("%s: expected to be in the compute section", decl.Name)
+		}
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	content := `#[versions]
+
+lit = "#define LIT";
+
+#[vertex]
+#version 450
+void main() {}
+
+#[fragment]
+#version 450
+layout(location = 0) out vec4 frag_color;
+void main() { frag_color = vec4(1.0); }
+`
+	doc := Parse(content)
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", doc.Errors)
+	}
+	var names []string
+	for _, section := range doc.Sections {
+		names = append(names, section.Name)
+	}
+	if got := strings.Join(names, " "); got != "versions vertex fragment" {
+		t.Errorf("expected sections versions, vertex and fragment, got %s", got)
+	}
+	if vertex := doc.Sections[1]; vertex.Range.Start.Line != 4 || vertex.Range.End.Line != 8 {
+		t.Errorf("expected the vertex section to span lines 4 to 8, got %+v", vertex.Range)
+	}
+
+	// The defines of versions are not declarations
+	var decls []string
+	for _, decl := range doc.Declarations {
+		decls = append(decls, decl.Section.Name+"."+decl.Name)
+	}
+	if got := strings.Join(decls, " "); got != "vertex.main fragment.frag_color fragment.main" {
+		t.Errorf("unexpected declarations: %s", got)
+	}
+}
+
+func TestParseByteOrderMarkAndCRLF(t *testing.T) {
+	doc := Parse("\uFEFF#[compute]\r\n#version 450\r\nvoid main() {\r\n}\r\n")
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %+v", doc.Errors)
+	}
+	if len(doc.Sections) != 1 || doc.Sections[0].TagRange.Start.Column != 0 {
+		t.Fatalf("expected the compute section at column 0, got %+v", doc.Sections)
+	}
+	main := doc.Declarations[0]
+	if main.NameRange.Start.Line != 2 || main.NameRange.Start.Column != 5 || main.Range.End.Line != 3 {
+		t.Errorf("unexpected ranges of main: %+v and %+v", main.NameRange, main.Range)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		errors  []string // Message at line:column
+	}{
+		{"#[compute]\nvoid main() {\n\tfloat x = (1.0;\n}\n", []string{"'(' is never closed at 2:11"}},
+		{"#[compute]\nvoid main() {\n}\n}\n", []string{"unmatched '}' at 3:0"}},
+		{"#[compute]\nvoid main() {\n\tfloat x[2 = 1.0;\n", []string{"'{' is never closed at 1:12", "'[' is never closed at 2:8"}},
+		{"#[computer]\n", []string{`unknown section "computer"; expected one of compute, vertex, fragment, tesselation_control, tesselation_evaluation, versions at 0:0`}},
+		{"#[compute\n", []string{"expected ']' to close the section tag at 0:0"}},
+		// Each section is compiled on its own
+		{"#[vertex]\nvoid main() {\n#[fragment]\nvoid main() {}\n", []string{"'{' is never closed at 1:12"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, err := range Parse(tt.content).Errors {
+			got = append(got, fmt.Sprintf("%s at %d:%d", err.Message, err.Range.Start.Line, err.Range.Start.Column))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("%q: expected errors:\n%s\ngot:\n%s", tt.content, strings.Join(tt.errors, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestHighlights(t *testing.T) {
+	doc := Parse(computeShader)
+	lines := strings.Split(computeShader, "\n")
+	kinds := make(map[string]HighlightKind)
+	declarations := make(map[string]bool)
+	for _, h := range doc.Highlights {
+		if h.Range.Start.Line != h.Range.End.Line {
+			t.Fatalf("highlight %+v spans several lines", h.Range)
+		}
+		text := lines[h.Range.Start.Line][h.Range.Start.Column:h.Range.End.Column]
+		if _, ok := kinds[text]; !ok {
+			kinds[text] = h.Kind
+			declarations[text] = h.Declaration
+		}
+	}
+
+	tests := []struct {
+		text        string
+		kind        HighlightKind
+		declaration bool
+	}{
+		{"#[compute]", HighlightDirective, false},
+		{"#version 450", HighlightDirective, false},
+		{"// Invocations in the (x, y, z) dimension", HighlightComment, false},
+		{"layout", HighlightKeyword, false},
+		{"restrict", HighlightKeyword, false},
+		{"uniform", HighlightKeyword, false},
+		{"64", HighlightNumber, false},
+		{"vec3", HighlightType, false},
+		{"image2D", HighlightType, false},
+		{"Particle", HighlightType, true},
+		{"ParticleBuffer", HighlightType, true},
+		{"particle_buffer", HighlightVariable, true},
+		{"position", HighlightProperty, true},
+		{"particles", HighlightProperty, true},
+		{"delta", HighlightVariable, true}, // Member of a block without an instance
+		{"output_image", HighlightVariable, true},
+		{"fade", HighlightFunction, true},
+		{"clamp", HighlightFunction, false},
+		{"gl_GlobalInvocationID", HighlightVariable, false},
+		{"x", HighlightProperty, false},
+	}
+	for _, tt := range tests {
+		kind, ok := kinds[tt.text]
+		if !ok {
+			t.Errorf("%s: expected a highlight", tt.text)
+			continue
+		}
+		if kind != tt.kind || declarations[tt.text] != tt.declaration {
+			t.Errorf("%s: expected kind %d, declaration %t, got %d, %t", tt.text, tt.kind, tt.declaration, kind, declarations[tt.text])
+		}
+	}
+	if _, ok := kinds["index"]; ok {
+		t.Errorf("expected no highlight for local variables")
+	}
+
+	// A block comment is highlighted line by line
+	doc = Parse("/* first\n   second */\n")
+	if len(doc.Highlights) != 2 || doc.Highlights[1].Range != (Range{Start: Position{Line: 1}, End: Position{Line: 1, Column: 12}}) {
+		t.Errorf("unexpected block comment highlights: %+v", doc.Highlights)
+	}
+}
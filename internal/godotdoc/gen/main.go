@@ -0,0 +1,215 @@
+// Command gen builds the class reference database of the godotdoc package
+// from the doc XML of a Godot source tree: doc/classes and the doc_classes
+// directories of modules and platforms.
+//
+//	go run ./gen -godot ~/src/godot -o classes.json.gz
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/andresperezl/gdls/internal/godotdoc"
+)
+
+// docClass is the root element of a class reference XML file.
+type docClass struct {
+	Name     string      `xml:"name,attr"`
+	Inherits string      `xml:"inherits,attr"`
+	Brief    string      `xml:"brief_description"`
+	Members  []docMember `xml:"members>member"`
+	Methods  []docMethod `xml:"methods>method"`
+}
+
+// docMethod is a method of a class.
+type docMethod struct {
+	Name string `xml:"name,attr"`
+}
+
+// docMember is a property of a class.
+type docMember struct {
+	Name        string `xml:"name,attr"`
+	Type        string `xml:"type,attr"`
+	Default     string `xml:"default,attr"`
+	Enum        string `xml:"enum,attr"`
+	Overrides   string `xml:"overrides,attr"`
+	Setter      string `xml:"setter,attr"`
+	Getter      string `xml:"getter,attr"`
+	Description string `xml:",chardata"`
+}
+
+func main() {
+	godotDir := flag.String("godot", "", "path to a Godot source tree")
+	output := flag.String("o", "classes.json.gz", "output file")
+	flag.Parse()
+
+	if *godotDir == "" {
+		fmt.Fprintln(os.Stderr, "gen: -godot is required")
+		os.Exit(2)
+	}
+	if err := run(*godotDir, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(godotDir, output string) error {
+	version, err := readVersion(godotDir)
+	if err != nil {
+		return err
+	}
+
+	db := &godotdoc.Database{Version: version}
+	err = filepath.WalkDir(godotDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".xml" {
+			return nil
+		}
+		dir := filepath.ToSlash(filepath.Dir(path))
+		if !strings.HasSuffix(dir, "/doc/classes") && !strings.HasSuffix(dir, "/doc_classes") {
+			return nil
+		}
+
+		class, err := readClass(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		db.Classes = append(db.Classes, class)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(db.Classes, func(i, j int) bool { return db.Classes[i].Name < db.Classes[j].Name })
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0o644)
+}
+
+// versionPattern matches the assignments of version.py, e.g. major = 4.
+var versionPattern = regexp.MustCompile(`(?m)^(major|minor|patch)\s*=\s*(\d+)`)
+
+// readVersion returns the engine version declared by version.py.
+func readVersion(godotDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(godotDir, "version.py"))
+	if err != nil {
+		return "", err
+	}
+	parts := map[string]string{}
+	for _, m := range versionPattern.FindAllStringSubmatch(string(content), -1) {
+		parts[m[1]] = m[2]
+	}
+	if parts["major"] == "" || parts["minor"] == "" {
+		return "", fmt.Errorf("no version in %s", filepath.Join(godotDir, "version.py"))
+	}
+	version := parts["major"] + "." + parts["minor"]
+	if patch := parts["patch"]; patch != "" && patch != "0" {
+		version += "." + patch
+	}
+	return version, nil
+}
+
+// readClass decodes a class reference XML file.
+func readClass(path string) (*godotdoc.Class, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc docClass
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	class := &godotdoc.Class{
+		Name:     doc.Name,
+		Inherits: doc.Inherits,
+		Brief:    bbcodeToMarkdown(doc.Brief),
+	}
+	for _, member := range doc.Members {
+		if member.Overrides != "" {
+			continue
+		}
+		// Accessors are callable methods the reference only lists here
+		for _, accessor := range []string{member.Setter, member.Getter} {
+			if accessor != "" && !slices.Contains(class.Methods, accessor) {
+				class.Methods = append(class.Methods, accessor)
+			}
+		}
+		class.Properties = append(class.Properties, godotdoc.Property{
+			Name:        member.Name,
+			Type:        member.Type,
+			Default:     member.Default,
+			Enum:        member.Enum,
+			Description: bbcodeToMarkdown(firstParagraph(member.Description)),
+		})
+	}
+	for _, method := range doc.Methods {
+		if !slices.Contains(class.Methods, method.Name) {
+			class.Methods = append(class.Methods, method.Name)
+		}
+	}
+	return class, nil
+}
+
+// firstParagraph returns the text up to the first blank line or line break
+// of an indented XML description.
+func firstParagraph(text string) string {
+	text = strings.TrimSpace(text)
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+var (
+	// codeTag matches inline code, which is kept verbatim.
+	codeTag = regexp.MustCompile(`\[code(?: [^\]]*)?\](.*?)\[/code\]`)
+	// urlTag matches [url=https://...]text[/url].
+	urlTag = regexp.MustCompile(`\[url=([^\]]+)\](.*?)\[/url\]`)
+	// refTag matches references such as [member position], [method add_child]
+	// or [param delta].
+	refTag = regexp.MustCompile(`\[(?:member|method|signal|constant|enum|param|annotation|constructor|operator|theme_item) ([^\]]+)\]`)
+	// classTag matches class references such as [Node2D] or [int].
+	classTag = regexp.MustCompile(`\[([A-Z@][A-Za-z0-9_]*|bool|int|float)\]`)
+	// styleTag matches the formatting tags Markdown has no equivalent for.
+	styleTag = regexp.MustCompile(`\[/?(?:center|color[^\]]*|font[^\]]*|lb|rb|kbd|br|u|s)\]`)
+)
+
+// bbcodeToMarkdown converts the BBCode of the class reference to Markdown.
+func bbcodeToMarkdown(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	text = codeTag.ReplaceAllString(text, "`$1`")
+	text = urlTag.ReplaceAllString(text, "[$2]($1)")
+	text = refTag.ReplaceAllString(text, "`$1`")
+	text = classTag.ReplaceAllString(text, "`$1`")
+	text = strings.NewReplacer("[b]", "**", "[/b]", "**", "[i]", "*", "[/i]", "*").Replace(text)
+	text = styleTag.ReplaceAllString(text, "")
+	return text
+}
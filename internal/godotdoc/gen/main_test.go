@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestBBCodeToMarkdown(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"A [Node2D] with a [Texture2D].", "A `Node2D` with a `Texture2D`."},
+		{"If [code]true[/code], [member texture]\n\t\tis centered.", "If `true`, `texture` is centered."},
+		{"See [method add_child] and [param node].", "See `add_child` and `node`."},
+		{"[b]Note:[/b] read [url=https://docs.godotengine.org]the docs[/url].", "**Note:** read [the docs](https://docs.godotengine.org)."},
+		{"Press [kbd]Ctrl[/kbd].", "Press Ctrl."},
+	}
+	for _, tt := range tests {
+		if got := bbcodeToMarkdown(tt.input); got != tt.want {
+			t.Errorf("bbcodeToMarkdown(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
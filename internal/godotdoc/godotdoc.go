@@ -0,0 +1,134 @@
+// Package godotdoc provides the Godot class reference bundled with gdls: the
+// classes of the engine with their brief description, inheritance and
+// properties. The database is generated from the engine's doc XML by ./gen.
+package godotdoc
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:generate go run ./gen -godot $GODOT_SRC -o classes.json.gz
+
+//go:embed classes.json.gz
+var classesData []byte
+
+// Class is a class of the engine.
+type Class struct {
+	Name       string     `json:"name"`
+	Inherits   string     `json:"inherits,omitempty"`
+	Brief      string     `json:"brief,omitempty"` // Markdown
+	Properties []Property `json:"properties,omitempty"`
+	Methods    []string   `json:"methods,omitempty"` // Names only
+}
+
+// Property is a property declared by a class. Properties a class only
+// overrides the default of are listed on the class declaring them.
+type Property struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`     // e.g. "Vector2(0, 0)"
+	Enum        string `json:"enum,omitempty"`        // e.g. "Node.ProcessMode"
+	Description string `json:"description,omitempty"` // Markdown, first paragraph
+}
+
+// Database is a class reference for one version of the engine.
+type Database struct {
+	Version string   `json:"version"` // e.g. "4.6.3"
+	Classes []*Class `json:"classes"`
+
+	byName map[string]*Class
+}
+
+// Load decodes a gzip-compressed JSON database.
+func Load(data []byte) (*Database, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("class reference: %w", err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("class reference: %w", err)
+	}
+
+	db := &Database{}
+	if err := json.Unmarshal(raw, db); err != nil {
+		return nil, fmt.Errorf("class reference: %w", err)
+	}
+	db.byName = make(map[string]*Class, len(db.Classes))
+	for _, class := range db.Classes {
+		db.byName[class.Name] = class
+	}
+	return db, nil
+}
+
+// Default returns the bundled database, decoding it on first use.
+var Default = sync.OnceValue(func() *Database {
+	db, err := Load(classesData)
+	if err != nil {
+		// The embedded data is generated and checked by the tests
+		panic(err)
+	}
+	return db
+})
+
+// Documents reports whether the database describes the given major.minor
+// version of the engine, e.g. "4.6" for a 4.6.1 database. Classes and
+// properties change between minor versions, so the database only holds for
+// projects targeting the version it was generated from.
+func (db *Database) Documents(version string) bool {
+	return db.Version == version || strings.HasPrefix(db.Version, version+".")
+}
+
+// Class returns the class with the given name, or nil.
+func (db *Database) Class(name string) *Class {
+	return db.byName[name]
+}
+
+// Inheritance returns the chain of classes from name up to the root class,
+// e.g. Sprite2D, Node2D, CanvasItem, Node, Object. It is nil for unknown
+// classes.
+func (db *Database) Inheritance(name string) []string {
+	var chain []string
+	for class := db.byName[name]; class != nil; class = db.byName[class.Inherits] {
+		chain = append(chain, class.Name)
+		if len(chain) > len(db.Classes) {
+			break // Guard against a malformed cycle
+		}
+	}
+	return chain
+}
+
+// HasMethod reports whether a class or one of its ancestors declares a
+// method, including the virtual ones scripts override, e.g. _ready.
+func (db *Database) HasMethod(className, name string) bool {
+	for _, ancestor := range db.Inheritance(className) {
+		if slices.Contains(db.byName[ancestor].Methods, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Property looks up a property of a class or of one of its ancestors and
+// returns it with the class declaring it.
+func (db *Database) Property(className, name string) (*Property, *Class) {
+	for _, ancestor := range db.Inheritance(className) {
+		class := db.byName[ancestor]
+		for i := range class.Properties {
+			if class.Properties[i].Name == name {
+				return &class.Properties[i], class
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+package godotdoc
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/andresperezl/gdls/internal/config"
+)
+
+func TestDefaultDatabase(t *testing.T) {
+	db := Default()
+	if !db.Documents(config.DefaultGodotVersion) {
+		t.Errorf("expected the database to document the default target %s, got %s", config.DefaultGodotVersion, db.Version)
+	}
+	if db.Documents("4.4") || (&Database{Version: "4.60"}).Documents("4.6") {
+		t.Error("expected the database to document only its own minor version")
+	}
+
+	class := db.Class("Sprite2D")
+	if class == nil {
+		t.Fatal("expected Sprite2D in the class reference")
+	}
+	if class.Inherits != "Node2D" || class.Brief == "" {
+		t.Errorf("unexpected Sprite2D entry: %+v", class)
+	}
+
+	want := []string{"Sprite2D", "Node2D", "CanvasItem", "Node", "Object"}
+	if chain := db.Inheritance("Sprite2D"); !slices.Equal(chain, want) {
+		t.Errorf("expected inheritance %v, got %v", want, chain)
+	}
+	if chain := db.Inheritance("NotAClass"); chain != nil {
+		t.Errorf("expected no inheritance for an unknown class, got %v", chain)
+	}
+}
+
+func TestPropertyLookup(t *testing.T) {
+	db := Default()
+
+	prop, owner := db.Property("Sprite2D", "position")
+	if prop == nil {
+		t.Fatal("expected Sprite2D to inherit position")
+	}
+	if owner.Name != "Node2D" || prop.Type != "Vector2" || prop.Default != "Vector2(0, 0)" {
+		t.Errorf("unexpected position property %+v declared in %s", prop, owner.Name)
+	}
+
+	if prop, _ := db.Property("Sprite2D", "not_a_property"); prop != nil {
+		t.Errorf("expected no property, got %+v", prop)
+	}
+}
+
+func TestHasProperty(t *testing.T) {
+	db := Default()
+
+	tests := []struct {
+		class, property string
+		want            bool
+	}{
+		{"Sprite2D", "texture", true},
+		{"Sprite2D", "visible", true},
+		{"Button", "layout_mode", true}, // Undocumented storage of Control
+		{"AnimationPlayer", "libraries", true},
+		{"Sprite2D", "layout_mode", false},
+		{"Sprite2D", "texure", false},
+		{"NotAClass", "texture", false},
+	}
+	for _, tt := range tests {
+		if got := db.HasProperty(tt.class, tt.property); got != tt.want {
+			t.Errorf("HasProperty(%q, %q) = %v, want %v", tt.class, tt.property, got, tt.want)
+		}
+	}
+}
+
+func TestHasMethod(t *testing.T) {
+	db := Default()
+
+	tests := []struct {
+		class, method string
+		want          bool
+	}{
+		{"Node2D", "queue_free", true}, // Declared by Node
+		{"CanvasItem", "hide", true},
+		{"Sprite2D", "set_visible", true}, // Setter of CanvasItem.visible
+		{"Node", "_ready", true},
+		{"Node2D", "jump", false},
+		{"NotAClass", "queue_free", false},
+	}
+	for _, tt := range tests {
+		if got := db.HasMethod(tt.class, tt.method); got != tt.want {
+			t.Errorf("HasMethod(%q, %q) = %v, want %v", tt.class, tt.method, got, tt.want)
+		}
+	}
+}
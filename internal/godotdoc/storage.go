@@ -0,0 +1,42 @@
+package godotdoc
+
+// storageProperties lists properties the engine saves in scene and resource
+// files without documenting them in the class reference, by the class
+// declaring them. Slashed and underscored keys are left out, since they are
+// runtime-generated by nature.
+var storageProperties = map[string][]string{
+	"AnimationMixer":            {"libraries"},
+	"AnimationNodeBlendSpace2D": {"triangles"},
+	"AnimationNodeBlendTree":    {"node_connections"},
+	"AnimationNodeStateMachine": {"transitions"},
+	"BitMap":                    {"data"},
+	"ConcavePolygonShape3D":     {"data"},
+	"Control":                   {"layout_mode", "anchors_preset"},
+	"GridMap":                   {"data"},
+	"ImageTexture":              {"image"},
+	"LightmapGIData":            {"user_data", "probe_data"},
+	"NavigationMesh":            {"vertices", "polygons"},
+	"NavigationPolygon":         {"vertices", "polygons", "outlines"},
+	"OptimizedTranslation":      {"hash_table", "bucket_table", "strings"},
+	"Polygon2D":                 {"bones"},
+	"Skin":                      {"bind_count"},
+	"SpriteFrames":              {"animations"},
+	"TileMap":                   {"format"},
+	"Translation":               {"messages"},
+}
+
+// HasProperty reports whether objects of a class have a property, either
+// documented by the class reference or saved as undocumented storage.
+func (db *Database) HasProperty(className, name string) bool {
+	if prop, _ := db.Property(className, name); prop != nil {
+		return true
+	}
+	for _, ancestor := range db.Inheritance(className) {
+		for _, storage := range storageProperties[ancestor] {
+			if storage == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package lsp
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// trackKeyFields are the fields Godot requires in the keys dictionary of
+// each track type, in the order they are reported. Transform and blend
+// shape tracks store their keys in a packed array instead.
+var trackKeyFields = map[string][]string{
+	"value":       {"times", "values"},
+	"method":      {"times", "values"},
+	"bezier":      {"times", "points"},
+	"audio":       {"times", "clips"},
+	"animation":   {"times", "clips"},
+	"position_3d": nil,
+	"rotation_3d": nil,
+	"scale_3d":    nil,
+	"blend_shape": nil,
+}
+
+// trackTypes are the track types Godot 4 loads, for messages.
+var trackTypes = []string{"value", "position_3d", "rotation_3d", "scale_3d", "blend_shape", "method", "bezier", "audio", "animation"}
+
+// animationTrack holds the properties of one tracks/N/ of an Animation.
+type animationTrack struct {
+	index int
+	typ   *parser.Property
+	path  *parser.Property
+	keys  *parser.Property
+}
+
+// checkAnimationTracks checks the tracks of Animation resources: that their
+// type is one Godot loads, that their keys have the fields of their type,
+// and, for the animations of a scene's AnimationPlayers, that their paths
+// name nodes of the scene. Godot drops broken tracks when loading or skips
+// them when playing, without telling where.
+func (s *Server) checkAnimationTracks(doc *analysis.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	ast := doc.TSCNAST
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, msg string) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(r),
+			Severity: severityPtr(severity),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeAnimationTrack),
+			Message:  msg,
+		})
+	}
+
+	var tree *analysis.SceneTree
+	var players map[string][]*analysis.SceneNode
+	if len(ast.Nodes) > 0 {
+		tree = analysis.NewSceneTree(ast)
+		players = animationPlayers(ast, tree)
+	}
+
+	check := func(id string, props []*parser.Property) {
+		for _, track := range animationTracks(props) {
+			typ := ""
+			if track.typ != nil {
+				sv, ok := track.typ.Value.(*parser.StringValue)
+				if !ok {
+					continue
+				}
+				typ = sv.Value
+				if _, known := trackKeyFields[typ]; !known {
+					add(sv.Range, protocol.DiagnosticSeverityError,
+						fmt.Sprintf("Unknown type %q of track %d; expected one of %s", typ, track.index, strings.Join(trackTypes, ", ")))
+					continue
+				}
+			}
+
+			if dict, ok := track.keysDict(); ok {
+				var missing []string
+				for _, field := range trackKeyFields[typ] {
+					if !dictHasKey(dict, field) {
+						missing = append(missing, strconv.Quote(field))
+					}
+				}
+				if len(missing) > 0 {
+					add(track.keys.KeyRange, protocol.DiagnosticSeverityError,
+						fmt.Sprintf("The keys of %s track %d have no %s", typ, track.index, strings.Join(missing, " or ")))
+				}
+			}
+
+			if bases := players[id]; len(bases) > 0 && track.path != nil {
+				s.checkTrackPath(doc.URI, ast, tree, bases, track, add)
+			}
+		}
+	}
+
+	for _, sub := range ast.SubResources {
+		if sub.Type == "Animation" {
+			check(sub.ID, sub.Properties)
+		}
+	}
+	if ast.Resource != nil && ast.Descriptor != nil && ast.Descriptor.ResourceType == "Animation" {
+		check("", ast.Resource.Properties)
+	}
+
+	return diagnostics
+}
+
+// checkTrackPath reports a track path that names no node from any of the
+// bases it is played from. Absolute paths and paths leaving the scene are
+// resolved at runtime, and paths into instanced scenes that cannot be read
+// are not reported.
+func (s *Server) checkTrackPath(uri string, ast *parser.Document, tree *analysis.SceneTree, bases []*analysis.SceneNode, track *animationTrack, add func(parser.Range, protocol.DiagnosticSeverity, string)) {
+	var sv *parser.StringValue
+	walkNodePaths(track.path.Value, func(v *parser.StringValue) { sv = v })
+	if sv == nil {
+		return
+	}
+	path, _, _ := strings.Cut(sv.Value, ":")
+	if path == "" || strings.HasPrefix(path, "/") {
+		return
+	}
+
+	for _, base := range bases {
+		if _, node := s.resolveNodePath(uri, ast, tree, base, path); node != nil {
+			return
+		}
+		reached, rest := tree.ResolvePrefix(base, path)
+		if reached == nil && rest != "" {
+			return // Leaves the scene through ".."
+		}
+		for n := reached; rest != "" && n != nil; n = n.Parent {
+			if n.Node.Instance != nil {
+				if _, scene := s.instancedScene(uri, ast, n.Node); scene == nil {
+					return
+				}
+				break
+			}
+		}
+	}
+	add(sv.Range, protocol.DiagnosticSeverityWarning,
+		fmt.Sprintf("Track %d animates %s, which is not a node of the scene", track.index, path))
+}
+
+// animationTracks groups the tracks/N/ properties of an Animation by track,
+// in the order of their indices.
+func animationTracks(props []*parser.Property) []*animationTrack {
+	byIndex := make(map[int]*animationTrack)
+	for _, prop := range props {
+		rest, ok := strings.CutPrefix(prop.Key, "tracks/")
+		if !ok {
+			continue
+		}
+		num, field, ok := strings.Cut(rest, "/")
+		index, err := strconv.Atoi(num)
+		if !ok || err != nil {
+			continue
+		}
+		track, ok := byIndex[index]
+		if !ok {
+			track = &animationTrack{index: index}
+			byIndex[index] = track
+		}
+		switch field {
+		case "type":
+			track.typ = prop
+		case "path":
+			track.path = prop
+		case "keys":
+			track.keys = prop
+		}
+	}
+
+	tracks := make([]*animationTrack, 0, len(byIndex))
+	for _, track := range byIndex {
+		tracks = append(tracks, track)
+	}
+	slices.SortFunc(tracks, func(a, b *animationTrack) int { return a.index - b.index })
+	return tracks
+}
+
+// keysDict returns the keys of a track when they are a dictionary.
+func (track *animationTrack) keysDict() (*parser.DictValue, bool) {
+	if track.keys == nil {
+		return nil, false
+	}
+	dict, ok := track.keys.Value.(*parser.DictValue)
+	return dict, ok
+}
+
+// dictHasKey reports whether a dictionary has an entry with a string key.
+func dictHasKey(dict *parser.DictValue, key string) bool {
+	for _, entry := range dict.Entries {
+		if sv, ok := entry.Key.(*parser.StringValue); ok && sv.Value == key {
+			return true
+		}
+	}
+	return false
+}
+
+// animationPlayers maps the IDs of the Animation sub_resources of a scene
+// to the nodes their tracks are resolved from: the root_node of each
+// AnimationPlayer or AnimationTree playing them through one of its
+// libraries, by default the parent of the player.
+func animationPlayers(ast *parser.Document, tree *analysis.SceneTree) map[string][]*analysis.SceneNode {
+	subs := make(map[string]*parser.SubResource, len(ast.SubResources))
+	for _, sub := range ast.SubResources {
+		subs[sub.ID] = sub
+	}
+	// subRefs calls fn with the sub_resource of every SubResource() in v
+	subRefs := func(v parser.Value, fn func(*parser.SubResource)) {
+		walkValue(v, func(v parser.Value) {
+			if ref, ok := v.(*parser.ResourceRef); ok && ref.RefType == "SubResource" && subs[ref.ID] != nil {
+				fn(subs[ref.ID])
+			}
+		})
+	}
+
+	players := make(map[string][]*analysis.SceneNode)
+	for _, sn := range tree.Nodes {
+		rootNode := ".."
+		var animations []string
+		for _, prop := range sn.Node.Properties {
+			switch prop.Key {
+			case "root_node":
+				walkNodePaths(prop.Value, func(sv *parser.StringValue) { rootNode = sv.Value })
+			case "libraries":
+				subRefs(prop.Value, func(library *parser.SubResource) {
+					for _, libProp := range library.Properties {
+						if libProp.Key == "_data" {
+							subRefs(libProp.Value, func(anim *parser.SubResource) {
+								animations = append(animations, anim.ID)
+							})
+						}
+					}
+				})
+			}
+		}
+		if len(animations) == 0 {
+			continue
+		}
+		base := tree.Resolve(sn, rootNode)
+		if base == nil {
+			continue
+		}
+		for _, id := range animations {
+			if !slices.Contains(players[id], base) {
+				players[id] = append(players[id], base)
+			}
+		}
+	}
+	return players
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnimationTrackDiagnostics(t *testing.T) {
+	content := `[gd_scene load_steps=3 format=3]
+
+[sub_resource type="Animation" id="Animation_walk"]
+resource_name = "walk"
+tracks/0/type = "value"
+tracks/0/path = NodePath("Body/Sprite2D:frame")
+tracks/0/keys = {
+"times": PackedFloat32Array(0, 0.5),
+"transitions": PackedFloat32Array(1, 1),
+"update": 1,
+"values": [0, 1]
+}
+tracks/1/type = "value"
+tracks/1/path = NodePath("Body/Sprite:frame")
+tracks/1/keys = {
+"times": PackedFloat32Array(0)
+}
+tracks/2/type = "rotation"
+tracks/2/path = NodePath("Body")
+tracks/3/type = "method"
+tracks/3/path = NodePath(".")
+tracks/3/keys = {
+"values": []
+}
+tracks/4/type = "position_3d"
+tracks/4/path = NodePath("/root/Game")
+tracks/4/keys = PackedFloat32Array(0, 1, 0, 0, 0)
+
+[sub_resource type="AnimationLibrary" id="AnimationLibrary_main"]
+_data = {
+"walk": SubResource("Animation_walk")
+}
+
+[node name="Player" type="CharacterBody2D"]
+
+[node name="Body" type="Node2D" parent="."]
+
+[node name="Sprite2D" type="Sprite2D" parent="Body"]
+
+[node name="AnimationPlayer" type="AnimationPlayer" parent="."]
+libraries = {
+"": SubResource("AnimationLibrary_main")
+}
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/player.tscn", content)
+
+	type result struct {
+		line    uint32
+		message string
+	}
+	var got []result
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value == codeAnimationTrack {
+			got = append(got, result{d.Range.Start.Line, d.Message})
+		}
+	}
+	expected := []result{
+		{13, "Track 1 animates Body/Sprite, which is not a node of the scene"},
+		{14, `The keys of value track 1 have no "values"`},
+		{17, `Unknown type "rotation" of track 2; expected one of value, position_3d, rotation_3d, scale_3d, blend_shape, method, bezier, audio, animation`},
+		{21, `The keys of method track 3 have no "times"`},
+	}
+	slices.SortFunc(got, func(a, b result) int { return int(a.line) - int(b.line) })
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	// The paths of a player with another root_node resolve from it
+	rooted := content + "root_node = NodePath(\"../Body\")\n"
+	doc = s.workspace.OpenDocument("file:///tmp/rooted.tscn", rooted)
+	var paths []string
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value == codeAnimationTrack && (d.Range.Start.Line == 5 || d.Range.Start.Line == 13) {
+			paths = append(paths, d.Message)
+		}
+	}
+	if !slices.Equal(paths, []string{"Track 0 animates Body/Sprite2D, which is not a node of the scene", "Track 1 animates Body/Sprite, which is not a node of the scene"}) {
+		t.Errorf("expected the paths to resolve from root_node, got %q", paths)
+	}
+}
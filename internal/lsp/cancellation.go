@@ -0,0 +1,77 @@
+package lsp
+
+import (
+	"context"
+	"time"
+
+	"github.com/tliron/glsp"
+)
+
+// documentContext returns a context for work on the given document. The
+// context is cancelled when the document changes or is closed, so stale
+// results for an outdated version are abandoned early.
+func (s *Server) documentContext(uri string) context.Context {
+	s.docCtxMu.Lock()
+	defer s.docCtxMu.Unlock()
+
+	if dc, ok := s.docCtx[uri]; ok {
+		return dc.ctx
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.docCtx[uri] = &docContext{ctx: ctx, cancel: cancel}
+	return ctx
+}
+
+// cancelDocumentWork cancels any in-flight work for the given document.
+func (s *Server) cancelDocumentWork(uri string) {
+	s.docCtxMu.Lock()
+	defer s.docCtxMu.Unlock()
+
+	if dc, ok := s.docCtx[uri]; ok {
+		if dc.timer != nil {
+			dc.timer.Stop()
+		}
+		dc.cancel()
+		delete(s.docCtx, uri)
+	}
+}
+
+// scheduleDiagnostics parses the document and publishes its diagnostics in
+// the background once no newer edit has arrived for the diagnostics delay.
+// A newer edit cancels the pending run through the document context.
+func (s *Server) scheduleDiagnostics(ctx *glsp.Context, uri string) {
+	workCtx := s.documentContext(uri)
+
+	s.docCtxMu.Lock()
+	defer s.docCtxMu.Unlock()
+
+	dc, ok := s.docCtx[uri]
+	if !ok {
+		return
+	}
+	if dc.timer != nil {
+		dc.timer.Stop()
+	}
+
+	dc.timer = time.AfterFunc(s.currentSettings().DiagnosticsDelay, func() {
+		defer s.recoverPanic("diagnostics", nil)
+		if workCtx.Err() != nil {
+			return
+		}
+
+		doc := s.workspace.GetDocument(uri)
+		if workCtx.Err() != nil {
+			return
+		}
+
+		s.publishDiagnostics(ctx, uri, doc)
+	})
+}
+
+// docContext holds the cancellable context for a document's current version.
+type docContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	timer  *time.Timer // pending background diagnostics, if any
+}
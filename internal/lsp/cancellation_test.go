@@ -0,0 +1,151 @@
+package lsp
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentContextCancelledOnChange(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/test.tscn"
+
+	ctx := s.documentContext(uri)
+	if ctx != s.documentContext(uri) {
+		t.Error("expected the same context for an unchanged document")
+	}
+
+	s.cancelDocumentWork(uri)
+	if ctx.Err() == nil {
+		t.Error("expected context to be cancelled")
+	}
+	if s.documentContext(uri).Err() != nil {
+		t.Error("expected a fresh context after cancellation")
+	}
+}
+
+// recordingContext returns a glsp context that records published diagnostics.
+func recordingContext() (*glsp.Context, func() []protocol.PublishDiagnosticsParams) {
+	var mu sync.Mutex
+	var published []protocol.PublishDiagnosticsParams
+
+	ctx := &glsp.Context{
+		Notify: func(method string, params any) {
+			if method != protocol.ServerTextDocumentPublishDiagnostics {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			published = append(published, params.(protocol.PublishDiagnosticsParams))
+		},
+	}
+
+	return ctx, func() []protocol.PublishDiagnosticsParams {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]protocol.PublishDiagnosticsParams(nil), published...)
+	}
+}
+
+func TestDiagnosticsDebouncedOnChange(t *testing.T) {
+	s := NewServer("test", "test")
+	s.settings.DiagnosticsDelay = 50 * time.Millisecond
+	uri := "file:///tmp/test.tscn"
+	ctx, published := recordingContext()
+
+	s.workspace.OpenDocument(uri, "[gd_scene format=3]\n")
+
+	edits := []string{
+		"[gd_scene format=3]\n[node name=",
+		"[gd_scene format=3]\n[node name=\"Root\"",
+		"[gd_scene format=3]\n[node name=\"Root\" type=\"Node\"]\n",
+	}
+	for i, text := range edits {
+		err := s.textDocumentDidChange(ctx, &protocol.DidChangeTextDocumentParams{
+			TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: protocol.Integer(i + 2)},
+			ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: text}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if got := published(); len(got) != 0 {
+		t.Fatalf("expected no diagnostics before the delay, got %d", len(got))
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	got := published()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 publish after edits settle, got %d", len(got))
+	}
+	if len(got[0].Diagnostics) != 0 {
+		t.Errorf("expected diagnostics for the final edit only, got %v", got[0].Diagnostics)
+	}
+}
+
+func TestDiagnosticsCancelledOnClose(t *testing.T) {
+	s := NewServer("test", "test")
+	s.settings.DiagnosticsDelay = 50 * time.Millisecond
+	uri := "file:///tmp/test.tscn"
+	ctx, published := recordingContext()
+
+	s.workspace.OpenDocument(uri, "[gd_scene format=3]\n")
+	_ = s.textDocumentDidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: 2},
+		ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: "[gd_scene format=2]\n"}},
+	})
+	_ = s.textDocumentDidClose(ctx, &protocol.DidCloseTextDocumentParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+
+	time.Sleep(200 * time.Millisecond)
+
+	got := published()
+	if len(got) != 1 || len(got[0].Diagnostics) != 0 {
+		t.Errorf("expected only the clearing publish from didClose, got %v", got)
+	}
+}
+
+func TestStaleChangeRejected(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/test.tscn"
+	ctx, _ := recordingContext()
+
+	change := func(version int32, text string) error {
+		return s.textDocumentDidChange(ctx, &protocol.DidChangeTextDocumentParams{
+			TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri}, Version: version},
+			ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: text}},
+		})
+	}
+
+	_ = s.textDocumentDidOpen(ctx, &protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, Version: 3, Text: "[gd_scene format=3]\n"},
+	})
+	doc := s.workspace.GetDocument(uri)
+	if err := change(3, "[gd_scene format=2]\n"); err == nil {
+		t.Error("expected a change at the open version to be rejected")
+	}
+	if err := change(4, "[gd_scene load_steps=1 format=3]\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	current := s.workspace.GetDocument(uri)
+	if current.Version != 4 || current.Content != "[gd_scene load_steps=1 format=3]\n" {
+		t.Errorf("expected version 4 with the new content, got %d: %q", current.Version, current.Content)
+	}
+
+	// Edits computed from the replaced document are not returned
+	err := s.checkCurrent(doc)
+	if rpcErr, ok := err.(*jsonrpc2.Error); !ok || rpcErr.Code != codeContentModified {
+		t.Errorf("expected a content modified error, got %v", err)
+	}
+	if err := s.checkCurrent(current); err != nil {
+		t.Errorf("unexpected error for the current document: %v", err)
+	}
+}
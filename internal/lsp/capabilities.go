@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"reflect"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// capability is a self-contained LSP feature such as hover or semantic
+// tokens. Each feature registers itself from an init function in its own
+// file, so adding a provider does not require touching the server core.
+type capability struct {
+	// name identifies the capability in the "capabilities" initialization
+	// option, e.g. {"capabilities": {"semanticTokens": false}}.
+	name string
+
+	// register installs the capability's request handlers.
+	register func(s *Server, h *protocol.Handler)
+
+	// advertise fills in the server capabilities sent to the client.
+	advertise func(s *Server, caps *serverCapabilities)
+}
+
+// serverCapabilities are the capabilities sent to the client, including
+// the LSP 3.17 ones the protocol package has no fields for.
+type serverCapabilities struct {
+	protocol.ServerCapabilities
+
+	PositionEncoding   string             `json:"positionEncoding,omitempty"`
+	DiagnosticProvider *diagnosticOptions `json:"diagnosticProvider,omitempty"`
+	InlayHintProvider  *bool              `json:"inlayHintProvider,omitempty"`
+}
+
+// initializeResult is the result of the initialize request.
+type initializeResult struct {
+	Capabilities serverCapabilities                   `json:"capabilities"`
+	ServerInfo   *protocol.InitializeResultServerInfo `json:"serverInfo,omitempty"`
+}
+
+// capabilityRegistry holds every capability known to the server, in
+// registration order.
+var capabilityRegistry []*capability
+
+// registerCapability adds a capability to the registry. It is meant to be
+// called from init functions.
+func registerCapability(c *capability) {
+	for _, existing := range capabilityRegistry {
+		if existing.name == c.name {
+			panic("lsp: capability registered twice: " + c.name)
+		}
+	}
+	capabilityRegistry = append(capabilityRegistry, c)
+}
+
+// capabilityEnabled reports whether the named capability is enabled.
+// Capabilities are enabled unless the client turned them off.
+func (s *Server) capabilityEnabled(name string) bool {
+	return s.currentSettings().CapabilityEnabled(name)
+}
+
+// removeDisabledHandlers uninstalls the handlers of the modules the client
+// turned off, so their methods are answered as unknown rather than served
+// without being advertised. Each disabled module registers into a scratch
+// handler, whose installed fields are then cleared on the server's.
+func (s *Server) removeDisabledHandlers() {
+	custom := s.custom
+	defer func() { s.custom = custom }()
+
+	handler := reflect.ValueOf(&s.handler).Elem()
+	for _, c := range capabilityRegistry {
+		if s.capabilityEnabled(c.name) {
+			continue
+		}
+		var scratch protocol.Handler
+		s.custom = make(map[string]customHandler)
+		c.register(s, &scratch)
+
+		for method := range s.custom {
+			delete(custom, method)
+		}
+		installed := reflect.ValueOf(&scratch).Elem()
+		for i := range installed.NumField() {
+			if field := installed.Field(i); field.Kind() == reflect.Func && !field.IsNil() {
+				handler.Field(i).SetZero()
+			}
+		}
+	}
+}
+
+// advertiseCapabilities fills in the capabilities of every enabled module.
+func (s *Server) advertiseCapabilities(caps *serverCapabilities) {
+	for _, c := range capabilityRegistry {
+		if s.capabilityEnabled(c.name) {
+			c.advertise(s, caps)
+		}
+	}
+}
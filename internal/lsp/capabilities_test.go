@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func initializeServer(t *testing.T, s *Server, opts any) serverCapabilities {
+	t.Helper()
+
+	result, err := s.initialize(&glsp.Context{}, &protocol.InitializeParams{InitializationOptions: opts})
+	if err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	return result.(initializeResult).Capabilities
+}
+
+func TestCapabilityRegistry(t *testing.T) {
+	expected := []string{
+		"hover", "definition", "typeDefinition", "implementation", "documentSymbol", "completion",
+		"foldingRange", "documentLink", "references", "documentHighlight", "linkedEditingRange",
+		"semanticTokens", "documentColor", "formatting", "watchedFiles", "codeLens", "codeAction",
+		"sceneTree", "shaderUniforms", "stats", "compileCheck", "groupMembers", "workspaceSymbol",
+		"rename", "willRenameFiles", "pullDiagnostics", "inlayHint", "semanticLegendExtended",
+	}
+
+	registered := make(map[string]bool)
+	for _, c := range capabilityRegistry {
+		registered[c.name] = true
+	}
+	for _, name := range expected {
+		if !registered[name] {
+			t.Errorf("expected capability %q to be registered", name)
+		}
+	}
+
+	s := NewServer("test", "test")
+	if s.handler.TextDocumentHover == nil || s.handler.TextDocumentSemanticTokensFull == nil {
+		t.Error("expected capability handlers to be installed")
+	}
+}
+
+func TestCapabilityFeatureFlags(t *testing.T) {
+	caps := initializeServer(t, NewServer("test", "test"), nil)
+	if caps.HoverProvider == nil || caps.SemanticTokensProvider == nil {
+		t.Fatal("expected capabilities to be enabled by default")
+	}
+
+	caps = initializeServer(t, NewServer("test", "test"), map[string]any{
+		"capabilities": map[string]any{
+			"hover":          false,
+			"semanticTokens": false,
+			"completion":     true,
+		},
+	})
+	if caps.HoverProvider != nil {
+		t.Error("expected hover to be disabled")
+	}
+	if caps.SemanticTokensProvider != nil {
+		t.Error("expected semantic tokens to be disabled")
+	}
+	if caps.CompletionProvider == nil || caps.DefinitionProvider == nil {
+		t.Error("expected other capabilities to stay enabled")
+	}
+}
+
+func TestDisabledCapabilityHandlers(t *testing.T) {
+	s := NewServer("test", "test")
+	client := connectServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	params := map[string]any{
+		"capabilities":          map[string]any{},
+		"initializationOptions": map[string]any{"capabilities": map[string]any{"hover": false, "compileCheck": false}},
+	}
+	if err := client.Call(ctx, "initialize", params, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	for _, method := range []string{string(protocol.MethodTextDocumentHover), CompileCheckMethod} {
+		err := client.Call(ctx, method, map[string]any{}, nil)
+		var rpcErr *jsonrpc2.Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc2.CodeMethodNotFound {
+			t.Errorf("expected %s to be unknown once disabled, got %v", method, err)
+		}
+	}
+	if s.handler.TextDocumentCompletion == nil || s.custom[SceneTreeMethod] == nil {
+		t.Error("expected the handlers of enabled capabilities to stay installed")
+	}
+}
@@ -0,0 +1,246 @@
+package lsp
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "codeAction",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentCodeAction = s.textDocumentCodeAction
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.CodeActionProvider = protocol.CodeActionOptions{
+				CodeActionKinds: []protocol.CodeActionKind{protocol.CodeActionKindQuickFix},
+			}
+		},
+	})
+}
+
+// textDocumentCodeAction handles the textDocument/codeAction request,
+// offering quick fixes for the diagnostics of the requested range.
+func (s *Server) textDocumentCodeAction(ctx *glsp.Context, params *protocol.CodeActionParams) (any, error) {
+	uri := params.TextDocument.URI
+	doc := s.workspace.GetDocument(uri)
+	if doc == nil || (doc.TSCNAST == nil && doc.ShaderAST == nil) {
+		return nil, nil
+	}
+
+	actions := []protocol.CodeAction{}
+	for _, diagnostic := range params.Context.Diagnostics {
+		if diagnostic.Code == nil {
+			continue
+		}
+		// Fixes are recomputed from the current content, as the diagnostic
+		// may be stale
+		var title string
+		var edits []protocol.TextEdit
+		switch {
+		case doc.ShaderAST != nil:
+			switch diagnostic.Code.Value {
+			case gdshader.CodeRemovedBuiltin:
+				title, edits = removedBuiltinFix(doc, diagnostic.Range)
+			case gdshader.CodeUndefined:
+				title, edits = s.undefinedUniformFix(doc, diagnostic.Range)
+			case gdshader.CodeMissingShaderType:
+				actions = append(actions, s.shaderTypeFixes(doc, diagnostic)...)
+			}
+		case diagnostic.Code.Value == codeLoadSteps:
+			title, edits = loadStepsFix(doc.TSCNAST)
+		case diagnostic.Code.Value == codeMissingFile:
+			title, edits = s.missingFileFix(uri, doc.TSCNAST, diagnostic.Range)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+		actions = append(actions, quickFix(uri, diagnostic, title, edits, true))
+	}
+	if err := s.checkCurrent(doc); err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// quickFix returns a code action applying edits to fix a diagnostic.
+func quickFix(uri string, diagnostic protocol.Diagnostic, title string, edits []protocol.TextEdit, preferred bool) protocol.CodeAction {
+	return protocol.CodeAction{
+		Title:       title,
+		Kind:        strPtr(protocol.CodeActionKindQuickFix),
+		Diagnostics: []protocol.Diagnostic{diagnostic},
+		IsPreferred: boolPtr(preferred),
+		Edit: &protocol.WorkspaceEdit{
+			Changes: map[protocol.DocumentUri][]protocol.TextEdit{uri: edits},
+		},
+	}
+}
+
+// loadStepsFix sets load_steps to the count of the resources of the file.
+func loadStepsFix(ast *parser.Document) (string, []protocol.TextEdit) {
+	gd := ast.Descriptor
+	if gd == nil || gd.LoadSteps == nil {
+		return "", nil
+	}
+	expected := loadSteps(ast)
+	if *gd.LoadSteps == expected {
+		return "", nil
+	}
+	return fmt.Sprintf("Set load_steps to %d", expected),
+		[]protocol.TextEdit{{Range: toProtocolRange(gd.LoadStepsRange), NewText: strconv.Itoa(expected)}}
+}
+
+// missingFileFix points the ext_resource path at rng to the file of the
+// same name elsewhere in the project, e.g. after it was moved outside the
+// editor. Nothing is offered when no file or several files have that name.
+func (s *Server) missingFileFix(uri string, ast *parser.Document, rng protocol.Range) (string, []protocol.TextEdit) {
+	root := s.findProjectRoot(uri)
+	if root == "" {
+		return "", nil
+	}
+	for _, ext := range ast.ExtResources {
+		rel, ok := strings.CutPrefix(ext.Path, "res://")
+		if !ok || toProtocolRange(ext.PathRange) != rng || fileExists(filepath.Join(root, filepath.FromSlash(rel))) {
+			continue
+		}
+		candidates := analysis.FindFilesNamed(root, path.Base(rel))
+		if len(candidates) != 1 {
+			return "", nil
+		}
+		newPath, ok := analysis.ResPath(root, candidates[0])
+		if !ok {
+			return "", nil
+		}
+		return fmt.Sprintf("Change path to %s", newPath), []protocol.TextEdit{{
+			Range:   toProtocolRange(stringContentRange(ext.PathRange, 0, len(ext.Path))),
+			NewText: newPath,
+		}}
+	}
+	return "", nil
+}
+
+// removedBuiltinFix replaces the Godot 3 built-in at rng by the one renaming
+// it, or by the uniform replacing it, declared when the shader does not
+// declare it yet. Every use of a
+// removed texture is replaced at once, as they need a single uniform.
+func removedBuiltinFix(doc *analysis.Document, rng protocol.Range) (string, []protocol.TextEdit) {
+	ast := doc.ShaderAST
+	// removedAt returns the removed built-in reported at r
+	removedAt := func(r gdshader.Range) *gdshader.IdentExpr {
+		ident, _ := ast.FindNodeAt(r.Start).(*gdshader.IdentExpr)
+		if ident == nil || ident.Range != r {
+			return nil
+		}
+		return ident
+	}
+
+	var ident *gdshader.IdentExpr
+	var uses []gdshader.Range
+	for _, err := range doc.ShaderErrs {
+		if err.Code != gdshader.CodeRemovedBuiltin {
+			continue
+		}
+		if toProtocolShaderRange(err.Range) == rng {
+			ident = removedAt(err.Range)
+		}
+		uses = append(uses, err.Range)
+	}
+	if ident == nil {
+		return "", nil
+	}
+	removed := gdshader.RemovedBuiltins[ident.Name]
+	if removed.Replacement != "" {
+		return fmt.Sprintf("Replace with %s", removed.Replacement),
+			[]protocol.TextEdit{{Range: rng, NewText: removed.Replacement}}
+	}
+
+	var edits []protocol.TextEdit
+	declared := false
+	for _, uniform := range ast.Uniforms {
+		declared = declared || uniform.Name == removed.Uniform
+	}
+	if !declared {
+		edits = append(edits, uniformDeclarationEdit(ast, removed.Declaration()))
+	}
+	for _, use := range uses {
+		if other := removedAt(use); other != nil && other.Name == ident.Name {
+			edits = append(edits, protocol.TextEdit{Range: toProtocolShaderRange(use), NewText: removed.Uniform})
+		}
+	}
+	return fmt.Sprintf("Declare %s with %s", removed.Uniform, removed.Hint), edits
+}
+
+// undefinedUniformFix declares the undefined variable at rng as a uniform,
+// of the type its use expects, or float when the use does not tell.
+func (s *Server) undefinedUniformFix(doc *analysis.Document, rng protocol.Range) (string, []protocol.TextEdit) {
+	ast := doc.ShaderAST
+	ident, ok := ast.FindNodeAt(gdshader.Position{Line: int(rng.Start.Line), Column: int(rng.Start.Character)}).(*gdshader.IdentExpr)
+	if !ok || toProtocolShaderRange(ident.Range) != rng {
+		return "", nil
+	}
+	// Undefined functions and types are reported on the whole call
+	if !slices.ContainsFunc(doc.ShaderErrs, func(err *gdshader.SemanticError) bool {
+		return err.Code == gdshader.CodeUndefined && err.Range == ident.Range && strings.HasPrefix(err.Message, "undefined symbol")
+	}) {
+		return "", nil
+	}
+
+	analyzer := gdshader.NewAnalyzer(ast)
+	analyzer.SetVersion(s.workspace.ShaderVersion())
+	analyzer.Analyze()
+	typ := "float"
+	if t := analyzer.ExpectedType(ident); t != nil {
+		if t.Kind == gdshader.TypeKindStruct || t.ElementType != nil {
+			return "", nil // Uniforms cannot be structs, and array sizes cannot be told
+		}
+		typ = t.String()
+	}
+	decl := fmt.Sprintf("uniform %s %s;", typ, ident.Name)
+	return fmt.Sprintf("Declare '%s'", decl), []protocol.TextEdit{uniformDeclarationEdit(ast, decl)}
+}
+
+// shaderTypeFixes offers to declare each shader type at the top of a shader
+// lacking shader_type, preferring the one its processor functions and
+// built-ins point to. Includes take the type of the shaders including them.
+func (s *Server) shaderTypeFixes(doc *analysis.Document, diagnostic protocol.Diagnostic) []protocol.CodeAction {
+	if doc.ShaderAST.ShaderType != nil || strings.HasSuffix(strings.ToLower(doc.URI), ".gdshaderinc") {
+		return nil
+	}
+	guess := gdshader.GuessShaderType(doc.ShaderAST, s.workspace.ShaderVersion())
+	types := append([]gdshader.ShaderType{guess}, slices.DeleteFunc(slices.Clone(gdshader.ShaderTypes), func(t gdshader.ShaderType) bool {
+		return t == guess
+	})...)
+
+	actions := make([]protocol.CodeAction, 0, len(types))
+	for _, shaderType := range types {
+		decl := "shader_type " + string(shaderType) + ";"
+		edits := []protocol.TextEdit{{NewText: decl + "\n"}}
+		actions = append(actions, quickFix(doc.URI, diagnostic, "Add '"+decl+"'", edits, shaderType == guess))
+	}
+	return actions
+}
+
+// uniformDeclarationEdit inserts the declaration of a uniform at the top of
+// a shader, after its shader_type and render_mode.
+func uniformDeclarationEdit(ast *gdshader.ShaderDocument, decl string) protocol.TextEdit {
+	line := 0
+	if ast.ShaderType != nil {
+		line = ast.ShaderType.Range.End.Line + 1
+	}
+	if ast.RenderModes != nil {
+		line = max(line, ast.RenderModes.Range.End.Line+1)
+	}
+	at := protocol.Position{Line: uint32(line)}
+	return protocol.TextEdit{Range: protocol.Range{Start: at, End: at}, NewText: decl + "\n"}
+}
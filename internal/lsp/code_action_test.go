@@ -0,0 +1,276 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+func TestLoadStepsQuickFix(t *testing.T) {
+	uri := "file:///tmp/level.tscn"
+	content := `[gd_scene load_steps=5 format=3]
+
+[ext_resource type="Script" path="res://player.gd" id="1_s"]
+
+[sub_resource type="BoxMesh" id="Box_1"]
+
+[node name="Root" type="Node3D"]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	result, err := s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Context:      protocol.CodeActionContext{Diagnostics: s.computeDiagnostics(doc)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := result.([]protocol.CodeAction)
+	if len(actions) != 1 || actions[0].Title != "Set load_steps to 3" {
+		t.Fatalf("expected a load_steps quick fix, got %+v", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	if len(edits) != 1 || edits[0].NewText != "3" ||
+		edits[0].Range != (protocol.Range{Start: protocol.Position{Line: 0, Character: 21}, End: protocol.Position{Line: 0, Character: 22}}) {
+		t.Errorf("expected the load_steps value to be replaced, got %+v", edits)
+	}
+
+	// Nothing to fix once load_steps matches
+	doc = s.workspace.OpenDocument(uri, "[gd_scene load_steps=1 format=3]\n")
+	result, _ = s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Context:      protocol.CodeActionContext{Diagnostics: s.computeDiagnostics(doc)},
+	})
+	if actions := result.([]protocol.CodeAction); len(actions) != 0 {
+		t.Errorf("expected no quick fix, got %+v", actions)
+	}
+}
+
+func TestMissingFileQuickFix(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"project.godot", "art/grass.png", "art/icon.svg", "ui/icon.svg", ".godot/imported/rock.png"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uri := pathToURI(filepath.Join(dir, "level.tscn"))
+	content := `[gd_scene format=3]
+
+[ext_resource type="Texture2D" path="res://textures/grass.png" id="1_g"]
+[ext_resource type="Texture2D" path="res://textures/icon.svg" id="2_i"]
+[ext_resource type="Texture2D" path="res://textures/rock.png" id="3_r"]
+[ext_resource type="Texture2D" path="res://art/grass.png" id="4_g"]
+
+[node name="Root" type="Node2D"]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	var diagnostics []protocol.Diagnostic
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value == codeMissingFile {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	if len(diagnostics) != 3 || diagnostics[0].Message != "File not found: res://textures/grass.png" ||
+		*diagnostics[0].Severity != protocol.DiagnosticSeverityWarning {
+		t.Fatalf("expected 3 missing file warnings, got %+v", diagnostics)
+	}
+
+	result, err := s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// icon.svg is ambiguous and rock.png only exists in a hidden folder
+	actions := result.([]protocol.CodeAction)
+	if len(actions) != 1 || actions[0].Title != "Change path to res://art/grass.png" {
+		t.Fatalf("expected a single path quick fix, got %+v", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	if len(edits) != 1 || edits[0].NewText != "res://art/grass.png" ||
+		edits[0].Range != (protocol.Range{Start: protocol.Position{Line: 2, Character: 37}, End: protocol.Position{Line: 2, Character: 61}}) {
+		t.Errorf("expected the path to be replaced, got %+v", edits)
+	}
+}
+
+func TestRemovedBuiltinQuickFix(t *testing.T) {
+	uri := "file:///tmp/blur.gdshader"
+	content := `shader_type canvas_item;
+render_mode unshaded;
+
+void fragment() {
+	COLOR = texture(SCREEN_TEXTURE, SCREEN_UV) + texture(SCREEN_TEXTURE, UV);
+	COLOR.a = (WORLD_MATRIX * vec4(1.0)).x;
+}
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	codeActions := func(diagnostics []protocol.Diagnostic) []protocol.CodeAction {
+		t.Helper()
+		result, err := s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Context:      protocol.CodeActionContext{Diagnostics: diagnostics},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.([]protocol.CodeAction)
+	}
+
+	var screen, world []protocol.Diagnostic
+	for _, d := range s.computeDiagnostics(doc) {
+		switch {
+		case d.Code == nil || d.Code.Value != "removed-builtin":
+			t.Errorf("unexpected diagnostic %+v", d)
+		case strings.Contains(d.Message, "SCREEN_TEXTURE"):
+			screen = append(screen, d)
+		default:
+			world = append(world, d)
+		}
+	}
+	if len(screen) != 2 || len(world) != 1 {
+		t.Fatalf("expected two SCREEN_TEXTURE and one WORLD_MATRIX diagnostics, got %+v %+v", screen, world)
+	}
+
+	actions := codeActions(screen[1:])
+	if len(actions) != 1 || actions[0].Title != "Declare screen_texture with hint_screen_texture" {
+		t.Fatalf("expected a quick fix declaring the uniform, got %+v", actions)
+	}
+	edits := actions[0].Edit.Changes[uri]
+	at := protocol.Position{Line: 2}
+	if len(edits) != 3 || edits[0].Range != (protocol.Range{Start: at, End: at}) ||
+		edits[0].NewText != "uniform sampler2D screen_texture : hint_screen_texture;\n" ||
+		edits[1].Range != screen[0].Range || edits[2].Range != screen[1].Range || edits[2].NewText != "screen_texture" {
+		t.Errorf("expected the uniform to be declared and both uses replaced, got %+v", edits)
+	}
+
+	actions = codeActions(world)
+	if len(actions) != 1 || actions[0].Title != "Replace with MODEL_MATRIX" {
+		t.Fatalf("expected a rename quick fix, got %+v", actions)
+	}
+	if edits := actions[0].Edit.Changes[uri]; len(edits) != 1 || edits[0].Range != world[0].Range || edits[0].NewText != "MODEL_MATRIX" {
+		t.Errorf("expected WORLD_MATRIX to be replaced, got %+v", edits)
+	}
+}
+
+func TestUndefinedUniformQuickFix(t *testing.T) {
+	uri := "file:///tmp/water.gdshader"
+	content := `shader_type spatial;
+
+float wave(float x) {
+	return sin(x * speed);
+}
+
+void fragment() {
+	vec4 n = texture(noise_tex, UV);
+	vec3 tint = deep_color;
+	ALBEDO = tint * n.rgb * wave(TIME) * strength;
+}
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	var titles []string
+	for _, d := range s.computeDiagnostics(doc) {
+		result, err := s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{d}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, action := range result.([]protocol.CodeAction) {
+			titles = append(titles, action.Title)
+			edits := action.Edit.Changes[uri]
+			at := protocol.Position{Line: 1}
+			if len(edits) != 1 || edits[0].Range != (protocol.Range{Start: at, End: at}) {
+				t.Errorf("expected the uniform to be declared after shader_type, got %+v", edits)
+			}
+		}
+	}
+	want := []string{
+		"Declare 'uniform float speed;'",
+		"Declare 'uniform sampler2D noise_tex;'",
+		"Declare 'uniform vec3 deep_color;'",
+		"Declare 'uniform vec3 strength;'",
+	}
+	if !slices.Equal(titles, want) {
+		t.Errorf("expected quick fixes %q, got %q", want, titles)
+	}
+}
+
+func TestMissingShaderTypeQuickFix(t *testing.T) {
+	s := NewServer("test", "test")
+	fixes := func(uri, content string) []protocol.CodeAction {
+		t.Helper()
+		doc := s.workspace.OpenDocument(uri, content)
+		var actions []protocol.CodeAction
+		for _, d := range s.computeDiagnostics(doc) {
+			if d.Code == nil || d.Code.Value != gdshader.CodeMissingShaderType {
+				continue
+			}
+			result, err := s.textDocumentCodeAction(&glsp.Context{}, &protocol.CodeActionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Context:      protocol.CodeActionContext{Diagnostics: []protocol.Diagnostic{d}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			actions = append(actions, result.([]protocol.CodeAction)...)
+		}
+		return actions
+	}
+
+	actions := fixes("file:///tmp/flame.gdshader", `void fragment() {
+	COLOR = texture(TEXTURE, UV) * MODULATE;
+}
+`)
+	var titles []string
+	for _, action := range actions {
+		titles = append(titles, action.Title)
+		if *action.IsPreferred != (action.Title == "Add 'shader_type canvas_item;'") {
+			t.Errorf("expected only canvas_item to be preferred, got %q preferred=%v", action.Title, *action.IsPreferred)
+		}
+	}
+	want := []string{
+		"Add 'shader_type canvas_item;'",
+		"Add 'shader_type spatial;'",
+		"Add 'shader_type particles;'",
+		"Add 'shader_type sky;'",
+		"Add 'shader_type fog;'",
+	}
+	if !slices.Equal(titles, want) {
+		t.Fatalf("expected quick fixes %q, got %q", want, titles)
+	}
+	edits := actions[0].Edit.Changes["file:///tmp/flame.gdshader"]
+	if len(edits) != 1 || edits[0].Range != (protocol.Range{}) || edits[0].NewText != "shader_type canvas_item;\n" {
+		t.Errorf("expected shader_type to be inserted at the top, got %+v", edits)
+	}
+
+	if actions := fixes("file:///tmp/clouds.gdshader", "void sky() {\n\tCOLOR = vec3(EYEDIR.y);\n}\n"); len(actions) == 0 || actions[0].Title != "Add 'shader_type sky;'" {
+		t.Errorf("expected sky to be suggested first, got %+v", actions)
+	}
+	if actions := fixes("file:///tmp/empty.gdshader", "uniform float x;\n"); len(actions) == 0 || actions[0].Title != "Add 'shader_type spatial;'" {
+		t.Errorf("expected spatial to be the default, got %+v", actions)
+	}
+	if actions := fixes("file:///tmp/common.gdshaderinc", "float half(float x) { return x * 0.5; }\n"); len(actions) != 0 {
+		t.Errorf("expected no fix for shader includes, got %+v", actions)
+	}
+}
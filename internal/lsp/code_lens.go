@@ -0,0 +1,162 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// openFileCommand is the client command an ext_resource lens runs to open
+// the file it loads, with the file URI as its argument.
+const openFileCommand = "gdls.openFile"
+
+func init() {
+	registerCapability(&capability{
+		name: "codeLens",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentCodeLens = s.textDocumentCodeLens
+			h.CodeLensResolve = s.codeLensResolve
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.CodeLensProvider = &protocol.CodeLensOptions{ResolveProvider: boolPtr(true)}
+		},
+	})
+}
+
+// Kinds of code lens resolved by codeLensResolve.
+const (
+	lensNode     = "node"     // Children and connections of a scene node
+	lensFunction = "function" // References to a shader function
+)
+
+// codeLensData identifies what a lens counts, so that codeLensResolve can
+// count it only once the lens is shown.
+type codeLensData struct {
+	URI  string `json:"uri"`
+	Kind string `json:"kind"`
+	Name string `json:"name"` // Node path or function name
+	Line int    `json:"line"` // Line of the function name, to tell overloads apart
+}
+
+// textDocumentCodeLens handles the textDocument/codeLens request. Scene
+// nodes and shader functions get lenses whose counts are computed by
+// codeLens/resolve; ext_resources get an "open file" lens right away.
+func (s *Server) textDocumentCodeLens(ctx *glsp.Context, params *protocol.CodeLensParams) ([]protocol.CodeLens, error) {
+	uri := params.TextDocument.URI
+	doc := s.workspace.GetDocument(uri)
+	if doc == nil {
+		return nil, nil
+	}
+
+	lenses := []protocol.CodeLens{}
+	switch {
+	case doc.TSCNAST != nil:
+		for _, ext := range doc.TSCNAST.ExtResources {
+			location := s.resolveResourcePath(s.extResourcePath(uri, ext), uri)
+			if location == nil || !fileExists(uriToPath(location.URI)) {
+				continue
+			}
+			lenses = append(lenses, protocol.CodeLens{
+				Range: toProtocolRange(ext.Range),
+				Command: &protocol.Command{
+					Title:     "open file",
+					Command:   openFileCommand,
+					Arguments: []any{location.URI},
+				},
+			})
+		}
+		for _, sn := range analysis.NewSceneTree(doc.TSCNAST).Nodes {
+			lenses = append(lenses, protocol.CodeLens{
+				Range: toProtocolRange(sn.Node.Range),
+				Data:  codeLensData{URI: uri, Kind: lensNode, Name: sn.Path},
+			})
+		}
+	case doc.ShaderAST != nil:
+		for _, fn := range doc.ShaderAST.Functions {
+			lenses = append(lenses, protocol.CodeLens{
+				Range: toProtocolShaderRange(fn.NameRange),
+				Data:  codeLensData{URI: uri, Kind: lensFunction, Name: fn.Name, Line: fn.NameRange.Start.Line},
+			})
+		}
+	}
+	return lenses, nil
+}
+
+// codeLensResolve handles the codeLens/resolve request, counting what the
+// lens describes in the current content of its document.
+func (s *Server) codeLensResolve(ctx *glsp.Context, lens *protocol.CodeLens) (*protocol.CodeLens, error) {
+	// Data comes back decoded as a generic JSON object
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return lens, nil
+	}
+	var data codeLensData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return lens, nil
+	}
+
+	doc := s.workspace.GetDocument(data.URI)
+	if doc == nil {
+		return lens, nil
+	}
+
+	var title string
+	switch {
+	case data.Kind == lensNode && doc.TSCNAST != nil:
+		tree := analysis.NewSceneTree(doc.TSCNAST)
+		node := tree.Lookup(data.Name)
+		if node == nil {
+			return lens, nil
+		}
+		connections := 0
+		for _, conn := range doc.TSCNAST.Connections {
+			if tree.Resolve(tree.Root, conn.From) == node || tree.Resolve(tree.Root, conn.To) == node {
+				connections++
+			}
+		}
+		title = plural(len(node.Children), "child", "children") + " • " + plural(connections, "connection", "connections")
+	case data.Kind == lensFunction && doc.ShaderAST != nil:
+		title = plural(s.shaderFunctionReferences(doc, data.Name, data.Line), "reference", "references")
+	default:
+		return lens, nil
+	}
+
+	// A lens that only informs has no command to run
+	lens.Command = &protocol.Command{Title: title}
+	return lens, nil
+}
+
+// shaderFunctionReferences counts the calls to the function declared with
+// its name on line, resolving overloads by their arguments. The analysis of
+// the document is shared with the other shader requests.
+func (s *Server) shaderFunctionReferences(doc *analysis.Document, name string, line int) int {
+	analyzer := s.analyzedShader(doc)
+
+	count := 0
+	gdshader.InspectDocument(doc.ShaderAST, func(node gdshader.Node) bool {
+		call, ok := node.(*gdshader.CallExpr)
+		if !ok {
+			return true
+		}
+		if sym := analyzer.SymbolOf(call.Func); sym != nil && sym.Kind == gdshader.SymbolFunction &&
+			sym.Name == name && sym.NameRange.Start.Line == line {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// plural formats a count with the singular or plural form of a noun, e.g.
+// "1 child" or "3 children".
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, pluralForm)
+}
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// resolveLenses resolves every lens the way a client would, round-tripping
+// their data through JSON, and returns their titles.
+func resolveLenses(t *testing.T, s *Server, uri string) []string {
+	t.Helper()
+	lenses, err := s.textDocumentCodeLens(&glsp.Context{}, &protocol.CodeLensParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, lens := range lenses {
+		raw, err := json.Marshal(lens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded protocol.CodeLens
+		if err := json.Unmarshal(raw, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		resolved, err := s.codeLensResolve(&glsp.Context{}, &decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resolved.Command == nil {
+			t.Fatalf("lens at %+v was not resolved", lens.Range)
+		}
+		titles = append(titles, resolved.Command.Title)
+	}
+	return titles
+}
+
+func TestCodeLensScene(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"project.godot", "player.gd"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="Script" path="res://player.gd" id="1_script"]
+[ext_resource type="Texture2D" path="res://missing.png" id="2_tex"]
+
+[node name="Main" type="Node2D"]
+
+[node name="Player" type="CharacterBody2D" parent="."]
+
+[node name="Sprite" type="Sprite2D" parent="Player"]
+
+[node name="Timer" type="Timer" parent="."]
+
+[connection signal="timeout" from="Timer" to="Player" method="_on_timeout"]
+[connection signal="ready" from="Player" to="." method="_on_ready"]
+`
+	s := NewServer("test", "test")
+	uri := pathToURI(filepath.Join(dir, "main.tscn"))
+	s.workspace.OpenDocument(uri, content)
+
+	titles := resolveLenses(t, s, uri)
+	want := []string{
+		"open file", // Only for files that exist
+		"2 children • 1 connection",
+		"1 child • 2 connections",
+		"0 children • 0 connections",
+		"0 children • 1 connection",
+	}
+	if len(titles) != len(want) {
+		t.Fatalf("expected %v, got %v", want, titles)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Errorf("lens %d: expected %q, got %q", i, want[i], titles[i])
+		}
+	}
+}
+
+func TestCodeLensShader(t *testing.T) {
+	content := `shader_type spatial;
+
+float scale(float x) { return x * 2.0; }
+vec2 scale(vec2 v) { return v * 2.0; }
+float unused() { return 0.0; }
+
+void fragment() {
+	ALBEDO = vec3(scale(1.0), scale(UV));
+	ALPHA = scale(0.5);
+}
+`
+	s := NewServer("test", "test")
+	s.workspace.OpenDocument("file:///tmp/test.gdshader", content)
+
+	titles := resolveLenses(t, s, "file:///tmp/test.gdshader")
+	want := []string{"2 references", "1 reference", "0 references", "0 references"}
+	if len(titles) != len(want) {
+		t.Fatalf("expected %v, got %v", want, titles)
+	}
+	for i := range want {
+		if titles[i] != want[i] {
+			t.Errorf("lens %d: expected %q, got %q", i, want[i], titles[i])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// lspCodec frames messages with the headers of the LSP base protocol.
+// Unlike jsonrpc2.VSCodeObjectCodec it matches header names case-insensitively,
+// does not require a space after the colon, and checks the charset of a
+// Content-Type header, accepting "utf-8" and the legacy "utf8".
+type lspCodec struct{}
+
+// WriteObject implements jsonrpc2.ObjectCodec.
+func (lspCodec) WriteObject(stream io.Writer, obj any) error {
+	return jsonrpc2.VSCodeObjectCodec{}.WriteObject(stream, obj)
+}
+
+// ReadObject implements jsonrpc2.ObjectCodec.
+func (lspCodec) ReadObject(stream *bufio.Reader, v any) error {
+	contentLength := -1
+	var contentTypeErr error
+
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if line == "" {
+			break // End of the header part
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("malformed header %q", line)
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "content-length":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid Content-Length %q", value)
+			}
+			contentLength = n
+		case "content-type":
+			contentTypeErr = checkContentType(value)
+		}
+	}
+
+	if contentLength < 0 {
+		return errors.New("missing Content-Length header")
+	}
+
+	// Consume the content even when it is rejected so the error is not
+	// reported as a framing problem
+	body := make([]byte, contentLength)
+	if _, err := io.ReadFull(stream, body); err != nil {
+		return err
+	}
+	if contentTypeErr != nil {
+		return contentTypeErr
+	}
+	return json.Unmarshal(body, v)
+}
+
+// checkContentType validates a Content-Type header value such as
+// "application/vscode-jsonrpc; charset=utf-8".
+func checkContentType(value string) error {
+	_, params, err := mime.ParseMediaType(value)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q: %w", value, err)
+	}
+	switch charset := strings.ToLower(params["charset"]); charset {
+	case "", "utf-8", "utf8":
+		return nil
+	default:
+		return fmt.Errorf("unsupported charset %q", charset)
+	}
+}
@@ -0,0 +1,343 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// CompileCheckMethod is the request an extension sends to check a file with
+// the Godot engine configured by godotPath, e.g. when it is saved. The
+// errors the engine reports are merged into the diagnostics of the file
+// until its content changes.
+const CompileCheckMethod = "gdls/compileCheck"
+
+const (
+	// compileCheckTimeout bounds a run of the engine.
+	compileCheckTimeout = time.Minute
+
+	// compileCheckInterval is the shortest time between two runs for the
+	// same file; requests in between get the last result.
+	compileCheckInterval = 2 * time.Second
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "compileCheck",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[CompileCheckMethod] = customRequest(s.compileCheck)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["compileCheck"] = true
+		},
+	})
+}
+
+// compileCheckParams are the parameters of the gdls/compileCheck request.
+type compileCheckParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// compileCheckResult is the result of the gdls/compileCheck request.
+type compileCheckResult struct {
+	Diagnostics []protocol.Diagnostic `json:"diagnostics"`
+	Cached      bool                  `json:"cached,omitempty"` // Not checked again, as nothing changed or a check just ran
+}
+
+// compileChecks holds the results of the engine checks, by URI. The engine
+// runs for one file at a time.
+type compileChecks struct {
+	mu       sync.Mutex
+	results  map[string]*engineCheck
+	inflight map[string]*engineCheck
+
+	engine chan struct{} // Held while the engine runs
+}
+
+// engineCheck is a run of the engine on the content of a file.
+type engineCheck struct {
+	hash        uint64 // Of the checked content
+	checked     time.Time
+	diagnostics []protocol.Diagnostic
+	err         error
+	done        chan struct{} // Closed once the run ended
+}
+
+func newCompileChecks() *compileChecks {
+	return &compileChecks{
+		results:  make(map[string]*engineCheck),
+		inflight: make(map[string]*engineCheck),
+		engine:   make(chan struct{}, 1),
+	}
+}
+
+// compileCheck handles the gdls/compileCheck request. The file is checked
+// as saved on disk, so its diagnostics are merged while the editor holds
+// the same content. Requests for a file being checked wait for that run.
+func (s *Server) compileCheck(ctx *glsp.Context, params *compileCheckParams) (any, error) {
+	godot := s.currentSettings().GodotPath
+	if godot == "" {
+		return nil, errors.New("no Godot executable configured; set godotPath")
+	}
+	uri := params.TextDocument.URI
+	root := s.findProjectRoot(uri)
+	resPath, ok := analysis.ResPath(root, uriToPath(uri))
+	if root == "" || !ok {
+		return nil, fmt.Errorf("%s is not in a Godot project", uri)
+	}
+	godot, err := s.godotExecutable(godot, root)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(uriToPath(uri))
+	if err != nil {
+		return nil, err
+	}
+	hash := contentHash(string(content))
+
+	checks := s.compileChecks
+	checks.mu.Lock()
+	check := checks.inflight[uri]
+	cached := check == nil
+	if last := checks.results[uri]; cached && last != nil && (last.hash == hash || time.Since(last.checked) < compileCheckInterval) {
+		check = last
+	}
+	if check == nil {
+		check = &engineCheck{hash: hash, done: make(chan struct{})}
+		checks.inflight[uri] = check
+		cached = false
+		go s.runEngineCheck(ctx, check, godot, root, resPath, uri, string(content))
+	}
+	checks.mu.Unlock()
+
+	select {
+	case <-check.done:
+	case <-s.requestContext(ctx).Done():
+		return nil, s.requestContext(ctx).Err()
+	}
+	if check.err != nil {
+		return nil, check.err
+	}
+	return compileCheckResult{Diagnostics: check.diagnostics, Cached: cached}, nil
+}
+
+// runEngineCheck runs the engine on a file, stores the result and updates
+// the diagnostics of the file. It outlives the request starting it, so that
+// a cancelled request does not waste the run.
+func (s *Server) runEngineCheck(ctx *glsp.Context, check *engineCheck, godot, root, resPath, uri, content string) {
+	defer s.recoverPanic("compileCheck", nil)
+	checks := s.compileChecks
+	checks.engine <- struct{}{}
+	output, err := runEngine(godot, root, resPath)
+	<-checks.engine
+
+	check.checked = time.Now()
+	check.err = err
+	if err == nil {
+		check.diagnostics = engineDiagnostics(output, resPath, content)
+	}
+	checks.mu.Lock()
+	delete(checks.inflight, uri)
+	if err == nil {
+		checks.results[uri] = check
+	}
+	checks.mu.Unlock()
+	close(check.done)
+
+	if err == nil {
+		s.publishDiagnostics(ctx, uri, s.workspace.GetDocument(uri))
+		s.refreshDiagnostics(ctx)
+	}
+}
+
+// engineResults returns the diagnostics the engine reported for the
+// document, when it checked the same content.
+func (s *Server) engineResults(doc *analysis.Document) []protocol.Diagnostic {
+	checks := s.compileChecks
+	checks.mu.Lock()
+	defer checks.mu.Unlock()
+	if check := checks.results[doc.URI]; check != nil && check.hash == contentHash(doc.Content) {
+		return check.diagnostics
+	}
+	return nil
+}
+
+// godotExecutable resolves the configured Godot path to an executable
+// file. godotPath may come from workspace settings, so executables inside
+// the project or a workspace folder are refused: opening a project must
+// not be enough to run a program it ships.
+func (s *Server) godotExecutable(godot, root string) (string, error) {
+	path, err := exec.LookPath(godot)
+	if err != nil {
+		return "", fmt.Errorf("godotPath %q is not an executable: %w", godot, err)
+	}
+	if path, err = filepath.Abs(path); err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("godotPath %q: %w", godot, err)
+	}
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("godotPath %q is not an executable file", godot)
+	}
+
+	dirs := []string{root}
+	for _, folder := range s.workspace.GetFolders() {
+		dirs = append(dirs, uriToPath(folder))
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("godotPath %q is inside the workspace; use a Godot executable installed outside it", godot)
+		}
+	}
+	return path, nil
+}
+
+// runEngine runs Godot headless on the project at root, returning what it
+// printed. Scripts are checked with --check-only; other files are loaded by
+// a script, which makes the engine report what it fails to load.
+func runEngine(godot, root, resPath string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), compileCheckTimeout)
+	defer cancel()
+
+	args := []string{"--headless", "--path", root}
+	if strings.HasSuffix(resPath, ".gd") {
+		args = append(args, "--check-only", "--script", resPath)
+	} else {
+		script, err := os.CreateTemp("", "gdls-check-*.gd")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(script.Name())
+		_, err = fmt.Fprintf(script, loaderScript, strconv.Quote(resPath))
+		if closeErr := script.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "--script", script.Name())
+	}
+
+	output, err := exec.CommandContext(ctx, godot, args...).CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && ctx.Err() == nil {
+		err = nil // Errors in the checked file make the engine fail
+	}
+	if err != nil {
+		return "", fmt.Errorf("running %s: %w", godot, err)
+	}
+	return string(output), nil
+}
+
+// loaderScript loads a resource and quits, for the engine to report the
+// errors of the file, including that it fails to load.
+const loaderScript = `extends SceneTree
+
+func _init() -> void:
+	ResourceLoader.load(%s, "", ResourceLoader.CACHE_MODE_IGNORE)
+	quit()
+`
+
+var (
+	// engineMessagePattern matches the first line of an error or warning
+	// the engine prints, e.g. "SCRIPT ERROR: Parse Error: ...".
+	engineMessagePattern = regexp.MustCompile(`^(SCRIPT |SHADER )?(ERROR|WARNING): (.*)$`)
+
+	// engineLocationPattern matches the line following it, e.g.
+	// "   at: GDScript::reload (res://player.gd:12)".
+	engineLocationPattern = regexp.MustCompile(`^\s+at: .*\(([^()]*):(\d+)\)\s*$`)
+
+	// engineResLinePattern matches a location in a message, e.g.
+	// "res://main.tscn:5 - Parse Error: ...".
+	engineResLinePattern = regexp.MustCompile(`^(res://[^\s:]+):(\d+) - `)
+)
+
+// engineDiagnostics turns the errors and warnings the engine printed about
+// the file at resPath into diagnostics on the lines they name, or on the
+// first line when they name none. Messages about other files are dropped.
+func engineDiagnostics(output, resPath, content string) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	contentLines := strings.Split(content, "\n")
+	outputLines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range outputLines {
+		m := engineMessagePattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		message := m[3]
+		file, lineNum := "", 0
+		if i+1 < len(outputLines) {
+			if loc := engineLocationPattern.FindStringSubmatch(outputLines[i+1]); loc != nil {
+				file, lineNum = loc[1], atoi(loc[2])
+				if file == "" && m[1] == "SHADER " {
+					file = resPath // Shader errors name no file
+				}
+			}
+		}
+		if loc := engineResLinePattern.FindStringSubmatch(message); loc != nil {
+			file, lineNum = loc[1], atoi(loc[2])
+			message = message[len(loc[0]):]
+		}
+		if file != resPath {
+			if !strings.Contains(message, resPath) {
+				continue
+			}
+			lineNum = 0
+		}
+
+		severity := protocol.DiagnosticSeverityError
+		if m[2] == "WARNING" {
+			severity = protocol.DiagnosticSeverityWarning
+		}
+		var rng protocol.Range
+		if lineNum > 0 && lineNum <= len(contentLines) {
+			text := strings.TrimSuffix(contentLines[lineNum-1], "\r")
+			rng = protocol.Range{
+				Start: protocol.Position{Line: uint32(lineNum - 1)},
+				End:   protocol.Position{Line: uint32(lineNum - 1), Character: uint32(len(text))},
+			}
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    rng,
+			Severity: severityPtr(severity),
+			Source:   strPtr("godot"),
+			Code:     diagnosticCode(codeEngine),
+			Message:  message,
+		})
+	}
+	return diagnostics
+}
+
+// atoi converts the digits matched by a pattern.
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// contentHash identifies the content of a file.
+func contentHash(content string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	return h.Sum64()
+}
@@ -0,0 +1,125 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestEngineDiagnostics(t *testing.T) {
+	output := `Godot Engine v4.3.stable.official - https://godotengine.org
+SCRIPT ERROR: Parse Error: Identifier "speed" not declared in the current scope.
+          at: GDScript::reload (res://player.gd:3)
+ERROR: Failed to load script "res://player.gd" with error "Parse error".
+   at: load (modules/gdscript/gdscript.cpp:2907)
+WARNING: res://enemy.gd:1 - Unused variable.
+   at: push_warning (core/variant/variant_utility.cpp:1112)
+`
+	content := "extends Node\n\nfunc _ready():\n\tprint(speed)\n"
+	diagnostics := engineDiagnostics(output, "res://player.gd", content)
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected the two messages about player.gd, got %+v", diagnostics)
+	}
+	if d := diagnostics[0]; d.Range.Start.Line != 2 || d.Range.End.Character != 14 || *d.Severity != protocol.DiagnosticSeverityError ||
+		d.Code.Value != codeEngine || !strings.HasPrefix(d.Message, "Parse Error: Identifier") {
+		t.Errorf("expected the parse error on line 3, got %+v", d)
+	}
+	if d := diagnostics[1]; d.Range != (protocol.Range{}) || !strings.Contains(d.Message, "Failed to load script") {
+		t.Errorf("expected the load failure on the first line, got %+v", d)
+	}
+
+	shader := "SHADER ERROR: Unknown identifier in expression: 'foo'.\n          at: (null) (:2)\n"
+	if diagnostics := engineDiagnostics(shader, "res://water.gdshader", "shader_type spatial;\nvoid fragment() { foo; }\n"); len(diagnostics) != 1 || diagnostics[0].Range.Start.Line != 1 {
+		t.Errorf("expected the shader error on line 2, got %+v", diagnostics)
+	}
+}
+
+func TestCompileCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake engine is a shell script")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "project.godot"), []byte("config_version=5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scene := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node\"]\n"
+	scenePath := filepath.Join(dir, "main.tscn")
+	if err := os.WriteFile(scenePath, []byte(scene), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// The fake engine counts its runs and reports an error on line 3
+	bin := t.TempDir()
+	runs := filepath.Join(bin, "runs")
+	godot := filepath.Join(bin, "godot")
+	if err := os.WriteFile(godot, []byte("#!/bin/sh\necho run >> "+runs+"\necho 'ERROR: res://main.tscn:3 - Parse Error: Unexpected node.'\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer("test", "test")
+	uri := pathToURI(scenePath)
+	ctx := &glsp.Context{Notify: func(string, any) {}}
+	check := func() compileCheckResult {
+		t.Helper()
+		result, err := s.compileCheck(ctx, &compileCheckParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.(compileCheckResult)
+	}
+
+	if _, err := s.compileCheck(ctx, &compileCheckParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}); err == nil {
+		t.Error("expected an error without godotPath")
+	}
+
+	// An engine shipped inside the project is never run
+	bundled := filepath.Join(dir, "godot")
+	if err := os.WriteFile(bundled, []byte("#!/bin/sh\necho run >> "+runs+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{bundled, filepath.Join(bin, "missing"), bin} {
+		s.applySettings(map[string]any{"godotPath": path})
+		if _, err := s.compileCheck(ctx, &compileCheckParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}); err == nil {
+			t.Errorf("expected godotPath %s to be refused", path)
+		}
+	}
+	if _, err := os.Stat(runs); err == nil {
+		t.Error("expected no engine run with a refused godotPath")
+	}
+	s.applySettings(map[string]any{"godotPath": godot})
+
+	result := check()
+	if result.Cached || len(result.Diagnostics) != 1 || result.Diagnostics[0].Range.Start.Line != 2 || result.Diagnostics[0].Message != "Parse Error: Unexpected node." {
+		t.Errorf("expected the engine error on line 3, got %+v", result)
+	}
+	if result := check(); !result.Cached || len(result.Diagnostics) != 1 {
+		t.Errorf("expected the unchanged file not to be checked again, got %+v", result)
+	}
+	if data, _ := os.ReadFile(runs); strings.Count(string(data), "run") != 1 {
+		t.Errorf("expected one engine run, got %q", data)
+	}
+
+	// The engine's errors are merged while the editor holds the checked content
+	doc := s.workspace.OpenDocument(uri, scene)
+	if !hasDiagnostic(s.computeDiagnostics(doc), "Parse Error: Unexpected node.") {
+		t.Error("expected the engine error among the diagnostics of the open scene")
+	}
+	doc = s.workspace.OpenDocument(uri, scene+"\n")
+	if hasDiagnostic(s.computeDiagnostics(doc), "Parse Error: Unexpected node.") {
+		t.Error("expected no engine error once the content changed")
+	}
+}
+
+// hasDiagnostic reports whether a diagnostic has the given message.
+func hasDiagnostic(diagnostics []protocol.Diagnostic, message string) bool {
+	for _, d := range diagnostics {
+		if d.Message == message {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,31 @@
 package lsp
 
 import (
+	"regexp"
 	"strings"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 
 	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "completion",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentCompletion = s.textDocumentCompletion
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.CompletionProvider = &protocol.CompletionOptions{
+				TriggerCharacters: []string{"\"", "/", "=", "."},
+				ResolveProvider:   boolPtr(false),
+			}
+		},
+	})
+}
+
 // textDocumentCompletion handles the textDocument/completion request.
 func (s *Server) textDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams) (any, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
@@ -31,7 +48,8 @@ func (s *Server) textDocumentCompletion(ctx *glsp.Context, params *protocol.Comp
 	prefix := lineText[:col]
 
 	// Determine completion context
-	items := s.getCompletions(doc, prefix, lineText)
+	offset := doc.PositionToOffset(uint32(line), uint32(col))
+	items := s.getCompletions(doc, prefix, lineText, offset)
 
 	return &protocol.CompletionList{
 		IsIncomplete: false,
@@ -40,12 +58,21 @@ func (s *Server) textDocumentCompletion(ctx *glsp.Context, params *protocol.Comp
 }
 
 // getCompletions returns completion items based on context.
-func (s *Server) getCompletions(doc *analysis.Document, prefix, lineText string) []protocol.CompletionItem {
+func (s *Server) getCompletions(doc *analysis.Document, prefix, lineText string, offset int) []protocol.CompletionItem {
 	items := []protocol.CompletionItem{}
 
+	if doc.Type == analysis.DocumentTypeGDShader {
+		return s.getShaderCompletions(doc, prefix, offset)
+	}
+
+	// Inside an array or dictionary value
+	if cc := findCollectionContext(doc.Content, offset); cc != nil {
+		return s.getCollectionCompletions(doc, cc)
+	}
+
 	// Inside a type="" attribute
 	if strings.Contains(prefix, "type=\"") && !strings.HasSuffix(prefix, "\"") {
-		return s.getNodeTypeCompletions()
+		return s.getNodeTypeCompletions(doc)
 	}
 
 	// Inside ExtResource("")
@@ -63,6 +90,11 @@ func (s *Server) getCompletions(doc *analysis.Document, prefix, lineText string)
 		return s.getNodePathCompletions(doc)
 	}
 
+	// The flags= of a connection
+	if connectFlagsPattern.MatchString(prefix) {
+		return s.getConnectFlagsCompletions()
+	}
+
 	// After = sign, suggest value types
 	if strings.HasSuffix(strings.TrimSpace(prefix), "=") {
 		return s.getValueCompletions(doc)
@@ -77,8 +109,9 @@ func (s *Server) getCompletions(doc *analysis.Document, prefix, lineText string)
 	return items
 }
 
-// getNodeTypeCompletions returns completions for node types.
-func (s *Server) getNodeTypeCompletions() []protocol.CompletionItem {
+// getNodeTypeCompletions returns completions for node types, followed by
+// the script classes of the project.
+func (s *Server) getNodeTypeCompletions(doc *analysis.Document) []protocol.CompletionItem {
 	nodeTypes := []string{
 		// Base
 		"Node", "Node2D", "Node3D",
@@ -137,6 +170,22 @@ func (s *Server) getNodeTypeCompletions() []protocol.CompletionItem {
 			Detail: strPtr("Godot Node Type"),
 		})
 	}
+	for _, class := range s.workspace.ScriptClasses(doc.URI) {
+		kind := protocol.CompletionItemKindClass
+		detail := "Script class"
+		if base := s.workspace.ScriptClassBase(doc.URI, class.Name); base != "" {
+			detail += " extending " + base
+		}
+		item := protocol.CompletionItem{
+			Label:  class.Name,
+			Kind:   &kind,
+			Detail: strPtr(detail),
+		}
+		if class.Path != "" {
+			item.Documentation = class.Path
+		}
+		items = append(items, item)
+	}
 	return items
 }
 
@@ -210,6 +259,15 @@ func (s *Server) getNodePathCompletions(doc *analysis.Document) []protocol.Compl
 		})
 	}
 
+	// Autoloads live under /root for every scene in the project
+	for _, autoload := range s.workspace.Autoloads(doc.URI) {
+		items = append(items, protocol.CompletionItem{
+			Label:  "/root/" + autoload.Name,
+			Kind:   &kind,
+			Detail: strPtr("Autoload - " + autoload.Path),
+		})
+	}
+
 	return items
 }
 
@@ -303,3 +361,322 @@ func (s *Server) getPropertyCompletions() []protocol.CompletionItem {
 
 	return items
 }
+
+// collectionContext describes the innermost array or dictionary enclosing
+// the cursor.
+type collectionContext struct {
+	property  string // key of the property or heading attribute holding the collection
+	ownerType string // type="" of the enclosing section
+	section   string // section keyword, e.g. "node" or "sub_resource"
+	inHeading bool   // collection is a heading attribute such as groups=[...]
+	isDict    bool
+	dictKey   bool   // cursor is at a dictionary key position
+	elemType  string // element type from Array[T]( or the first sibling element
+	inString  bool   // cursor is inside a string literal
+}
+
+var (
+	sectionHeadingRe = regexp.MustCompile(`(?m)^\[(gd_scene|gd_resource|ext_resource|sub_resource|node|connection|editable|resource)\b`)
+	headingTypeRe    = regexp.MustCompile(`\btype="([^"]*)"`)
+	typedArrayRe     = regexp.MustCompile(`Array\[(\w+)\]\s*$`)
+	elemTypeRe       = regexp.MustCompile(`^\s*&?(\w+)\s*\(`)
+)
+
+// collectionFrame is an open bracket found while scanning towards the cursor.
+type collectionFrame struct {
+	open      byte
+	pos       int
+	elemStart int  // offset where the current element starts
+	sawColon  bool // current dictionary entry already has its key
+}
+
+// findCollectionContext scans the section enclosing offset and reports the
+// innermost array or dictionary the cursor sits in. It returns nil when the
+// cursor is not directly inside a collection, for example inside a
+// constructor's arguments.
+func findCollectionContext(content string, offset int) *collectionContext {
+	if offset > len(content) {
+		offset = len(content)
+	}
+
+	// Locate the heading of the section containing the cursor
+	loc := sectionHeadingRe.FindAllStringSubmatchIndex(content[:offset], -1)
+	if len(loc) == 0 {
+		return nil
+	}
+	last := loc[len(loc)-1]
+	start := last[0]
+	cc := &collectionContext{section: content[last[2]:last[3]]}
+
+	headingEnd := strings.IndexByte(content[start:], '\n')
+	if headingEnd < 0 {
+		headingEnd = len(content) - start
+	}
+	if m := headingTypeRe.FindStringSubmatch(content[start : start+headingEnd]); m != nil {
+		cc.ownerType = m[1]
+	}
+
+	// Scan forward, tracking strings and open brackets
+	var stack []collectionFrame
+	inString := false
+	for i := start; i < offset; i++ {
+		c := content[i]
+		if inString {
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case ';':
+			// Comments run to the end of the line
+			if nl := strings.IndexByte(content[i:offset], '\n'); nl >= 0 {
+				i += nl
+			} else {
+				i = offset
+			}
+		case '[', '{', '(':
+			stack = append(stack, collectionFrame{open: c, pos: i, elemStart: i + 1})
+		case ']', '}', ')':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if len(stack) > 0 {
+				top := &stack[len(stack)-1]
+				top.elemStart = i + 1
+				top.sawColon = false
+			}
+		case ':':
+			if len(stack) > 0 {
+				top := &stack[len(stack)-1]
+				top.sawColon = true
+				top.elemStart = i + 1
+			}
+		}
+	}
+
+	// The first frame is the heading bracket itself; anything at depth one
+	// belongs to the heading, otherwise the heading is closed.
+	if len(stack) == 0 {
+		return nil
+	}
+	headingOpen := stack[0].pos == start
+	if headingOpen {
+		cc.inHeading = true
+		stack = stack[1:]
+	}
+	if len(stack) == 0 {
+		return nil
+	}
+
+	top := stack[len(stack)-1]
+	if top.open == '(' {
+		return nil
+	}
+	cc.isDict = top.open == '{'
+	cc.dictKey = cc.isDict && !top.sawColon
+	cc.inString = inString
+
+	// The property owning the outermost collection
+	outer := stack[0]
+	before := strings.TrimRight(content[start:outer.pos], " \t")
+	if m := typedArrayRe.FindStringSubmatch(before); m != nil {
+		before = strings.TrimRight(before[:len(before)-len(m[0])], " \t")
+	}
+	if strings.HasSuffix(before, "=") {
+		before = strings.TrimRight(before[:len(before)-1], " \t")
+		keyStart := strings.LastIndexAny(before, " \t\n[") + 1
+		cc.property = before[keyStart:]
+	}
+
+	// Element type from typed array syntax, e.g. Array[Color]([...])
+	if top.open == '[' && len(stack) >= 2 && stack[len(stack)-2].open == '(' {
+		paren := stack[len(stack)-2]
+		if m := typedArrayRe.FindStringSubmatch(content[start:paren.pos]); m != nil {
+			cc.elemType = m[1]
+		}
+	}
+
+	// Otherwise, infer it from the first element already in the collection
+	if cc.elemType == "" && top.open == '[' {
+		if m := elemTypeRe.FindStringSubmatch(content[top.pos+1 : offset]); m != nil {
+			cc.elemType = m[1]
+		}
+	}
+
+	return cc
+}
+
+// getCollectionCompletions returns completions for a position inside an
+// array or dictionary, based on the enclosing property and owner type.
+func (s *Server) getCollectionCompletions(doc *analysis.Document, cc *collectionContext) []protocol.CompletionItem {
+	switch {
+	case cc.inHeading && cc.section == "node" && cc.property == "groups":
+		return s.getGroupCompletions(doc, cc.inString)
+	case cc.isDict && cc.ownerType == "AnimationLibrary" && cc.property == "_data":
+		if cc.dictKey {
+			return s.getAnimationNameCompletions(doc, cc.inString)
+		}
+		return s.getResourceRefCompletions(doc, "Animation")
+	case cc.elemType != "":
+		return s.getTypedValueCompletions(doc, cc.elemType)
+	}
+
+	if cc.inString {
+		return nil
+	}
+	return s.getValueCompletions(doc)
+}
+
+// getGroupCompletions returns the group names already used in the scene
+// and in the other scenes of the project, with their number of members.
+func (s *Server) getGroupCompletions(doc *analysis.Document, inString bool) []protocol.CompletionItem {
+	if doc.TSCNAST == nil {
+		return nil
+	}
+
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindEnumMember
+	counts := make(map[string]int)
+	var groups []string
+
+	// The groups of this scene first, then the others in order
+	members := analysis.SceneGroups(doc.URI, doc.TSCNAST)
+	for _, m := range s.workspace.Groups(doc.URI) {
+		if m.URI != doc.URI {
+			members = append(members, m)
+		}
+	}
+	for _, m := range members {
+		if counts[m.Group] == 0 {
+			groups = append(groups, m.Group)
+		}
+		counts[m.Group]++
+	}
+
+	for _, group := range groups {
+		item := protocol.CompletionItem{
+			Label:  group,
+			Kind:   &kind,
+			Detail: strPtr("Group, " + plural(counts[group], "node", "nodes")),
+		}
+		if !inString {
+			item.InsertText = strPtr("\"" + group + "\"")
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// getAnimationNameCompletions returns &"name" keys for the Animation
+// sub-resources of the scene, as used by AnimationLibrary._data.
+func (s *Server) getAnimationNameCompletions(doc *analysis.Document, inString bool) []protocol.CompletionItem {
+	if doc.TSCNAST == nil {
+		return nil
+	}
+
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindValue
+
+	for _, sub := range doc.TSCNAST.SubResources {
+		if sub.Type != "Animation" {
+			continue
+		}
+
+		name := ""
+		for _, prop := range sub.Properties {
+			if sv, ok := prop.Value.(*parser.StringValue); ok && prop.Key == "resource_name" {
+				name = sv.Value
+			}
+		}
+		if name == "" {
+			continue
+		}
+
+		item := protocol.CompletionItem{
+			Label:  "&\"" + name + "\"",
+			Kind:   &kind,
+			Detail: strPtr("Animation " + sub.ID),
+		}
+		if inString {
+			item.Label = name
+		}
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// getResourceRefCompletions returns ExtResource/SubResource references to
+// resources of the given type.
+func (s *Server) getResourceRefCompletions(doc *analysis.Document, resType string) []protocol.CompletionItem {
+	if doc.TSCNAST == nil {
+		return nil
+	}
+
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindReference
+
+	for _, ext := range doc.TSCNAST.ExtResources {
+		if ext.Type != resType {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:  "ExtResource(\"" + ext.ID + "\")",
+			Kind:   &kind,
+			Detail: strPtr(ext.Type + " - " + ext.Path),
+		})
+	}
+
+	for _, sub := range doc.TSCNAST.SubResources {
+		if sub.Type != resType {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:  "SubResource(\"" + sub.ID + "\")",
+			Kind:   &kind,
+			Detail: strPtr(sub.Type),
+		})
+	}
+
+	return items
+}
+
+// getTypedValueCompletions returns completions for a value of a known type:
+// its constructor for built-in types, or matching resource references.
+func (s *Server) getTypedValueCompletions(doc *analysis.Document, typeName string) []protocol.CompletionItem {
+	switch typeName {
+	case "StringName":
+		kind := protocol.CompletionItemKindValue
+		snippetFormat := protocol.InsertTextFormatSnippet
+		return []protocol.CompletionItem{{
+			Label:            "&\"\"",
+			Kind:             &kind,
+			Detail:           strPtr("StringName"),
+			InsertText:       strPtr("&\"$1\""),
+			InsertTextFormat: &snippetFormat,
+		}}
+	case "NodePath":
+		items := []protocol.CompletionItem{}
+		for _, item := range s.getValueCompletions(doc) {
+			if item.Label == "NodePath" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	for _, item := range s.getValueCompletions(doc) {
+		if item.Label == typeName {
+			return []protocol.CompletionItem{item}
+		}
+	}
+
+	return s.getResourceRefCompletions(doc, typeName)
+}
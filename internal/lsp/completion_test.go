@@ -0,0 +1,413 @@
+package lsp
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// completeAt returns the completion labels at the position marked by | in
+// the given scene content.
+func completeAt(t *testing.T, content string) []string {
+	t.Helper()
+	return completeIn(t, "file:///tmp/test.tscn", content)
+}
+
+// completeIn returns the completion labels at the position marked by | in
+// the content of the document at uri.
+func completeIn(t *testing.T, uri, content string) []string {
+	t.Helper()
+
+	offset := strings.Index(content, "|")
+	if offset < 0 {
+		t.Fatal("missing cursor marker")
+	}
+	content = content[:offset] + content[offset+1:]
+
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	lineStart := strings.LastIndexByte(content[:offset], '\n') + 1
+	lineEnd := strings.IndexByte(content[offset:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(content) - offset
+	}
+	lineText := content[lineStart : offset+lineEnd]
+
+	var labels []string
+	for _, item := range s.getCompletions(doc, content[lineStart:offset], lineText, offset) {
+		labels = append(labels, item.Label)
+	}
+	return labels
+}
+
+func containsLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCompletionGroupsArray(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+
+[node name="Enemy" type="Node2D" parent="." groups=["enemies", "damageable"]]
+
+[node name="Player" type="Node2D" parent="." groups=["|"]]
+`
+	labels := completeAt(t, content)
+	if !containsLabel(labels, "enemies") || !containsLabel(labels, "damageable") {
+		t.Errorf("expected existing group names, got %v", labels)
+	}
+	if containsLabel(labels, "Node2D") {
+		t.Errorf("expected no node type completions inside groups, got %v", labels)
+	}
+}
+
+func TestCompletionAnimationLibraryData(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[sub_resource type="Animation" id="Animation_idle"]
+resource_name = "idle"
+
+[sub_resource type="AnimationLibrary" id="AnimationLibrary_1"]
+_data = {
+|
+}
+`
+	labels := completeAt(t, content)
+	if !containsLabel(labels, `&"idle"`) {
+		t.Errorf("expected animation name key, got %v", labels)
+	}
+
+	content = strings.Replace(content, "\n|\n", "\n&\"idle\": |\n", 1)
+	labels = completeAt(t, content)
+	if !containsLabel(labels, `SubResource("Animation_idle")`) {
+		t.Errorf("expected animation sub-resource value, got %v", labels)
+	}
+}
+
+func TestCompletionTypedArray(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+colors = Array[Color]([|])
+`
+	labels := completeAt(t, content)
+	if len(labels) != 1 || labels[0] != "Color" {
+		t.Errorf("expected only the Color constructor, got %v", labels)
+	}
+
+	content = `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+points = [Vector2(0, 0), |]
+`
+	labels = completeAt(t, content)
+	if len(labels) != 1 || labels[0] != "Vector2" {
+		t.Errorf("expected element type from siblings, got %v", labels)
+	}
+}
+
+func TestFindCollectionContextOutsideCollection(t *testing.T) {
+	content := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node2D\"]\nposition = Vector2(1, 2)\n"
+	offset := strings.Index(content, "1, 2")
+	if cc := findCollectionContext(content, offset); cc != nil {
+		t.Errorf("expected no collection context inside constructor args, got %+v", cc)
+	}
+
+	offset = strings.Index(content, "type=") + len(`type="`)
+	if cc := findCollectionContext(content, offset); cc != nil {
+		t.Errorf("expected no collection context in heading attribute, got %+v", cc)
+	}
+}
+
+func TestCompletionShader(t *testing.T) {
+	content := `shader_type spatial;
+
+struct Light {
+	vec3 color;
+	float energy;
+};
+
+uniform vec4 tint : source_color;
+varying vec3 world_pos;
+const float SCALE = 2.0;
+
+float luminance(vec3 c) {
+	return dot(c, vec3(0.299, 0.587, 0.114));
+}
+
+void vertex() {
+	world_pos = VERTEX;
+}
+
+void fragment() {
+	Light lights[2];
+	float local = 1.0;
+	|
+}
+`
+	labels := completeIn(t, "file:///tmp/test.gdshader", content)
+	for _, want := range []string{
+		"if", "return", "vec3", // keywords and types
+		"texture", "mix", // built-in functions
+		"tint", "world_pos", "SCALE", "luminance", "Light", "lights", "local", // symbols in scope
+		"ALBEDO", "UV", "PI", // built-ins of the fragment function
+	} {
+		if !containsLabel(labels, want) {
+			t.Errorf("expected %q in completions, got %v", want, labels)
+		}
+	}
+	for _, unwanted := range []string{"POSITION", "LIGHT"} {
+		if containsLabel(labels, unwanted) {
+			t.Errorf("expected no %q outside its stage", unwanted)
+		}
+	}
+
+	vertex := strings.Replace(content, "\t|\n", "", 1)
+	vertex = strings.Replace(vertex, "world_pos = VERTEX;", "world_pos = VERTEX;\n\t|", 1)
+	labels = completeIn(t, "file:///tmp/test.gdshader", vertex)
+	if containsLabel(labels, "ALBEDO") || containsLabel(labels, "local") {
+		t.Errorf("expected no fragment built-ins or locals in vertex, got %v", labels)
+	}
+	if !containsLabel(labels, "POSITION") {
+		t.Errorf("expected vertex built-ins, got %v", labels)
+	}
+
+	member := strings.Replace(content, "\t|\n", "\tlights[0].|\n", 1)
+	labels = completeIn(t, "file:///tmp/test.gdshader", member)
+	if len(labels) != 2 || !containsLabel(labels, "color") || !containsLabel(labels, "energy") {
+		t.Errorf("expected struct fields, got %v", labels)
+	}
+}
+
+func TestCompletionShaderSwizzle(t *testing.T) {
+	content := `shader_type spatial;
+
+struct Light {
+	vec3 color;
+};
+
+uniform vec4 tint : source_color;
+
+void fragment() {
+	Light light;
+	|
+}
+`
+	complete := func(expr string) []string {
+		return completeIn(t, "file:///tmp/test.gdshader", strings.Replace(content, "|", expr+"|", 1))
+	}
+
+	labels := complete("UV.")
+	want := []string{"x", "y", "xy", "r", "g", "rg", "s", "t", "st"}
+	if strings.Join(labels, " ") != strings.Join(want, " ") {
+		t.Errorf("expected vec2 swizzles %v, got %v", want, labels)
+	}
+
+	labels = complete("light.color.r")
+	if !containsLabel(labels, "rgb") || containsLabel(labels, "rgba") || containsLabel(labels, "w") {
+		t.Errorf("expected vec3 swizzles, got %v", labels)
+	}
+
+	labels = complete("(tint * 2.0).")
+	if !containsLabel(labels, "xyzw") || !containsLabel(labels, "a") {
+		t.Errorf("expected vec4 swizzles, got %v", labels)
+	}
+
+	if labels := complete("float f = 1."); len(labels) != 0 {
+		t.Errorf("expected no completions after a float literal, got %v", labels)
+	}
+}
+
+func TestCompletionShaderNestedMembers(t *testing.T) {
+	content := `shader_type spatial;
+
+struct Surface {
+	vec3 color;
+	float roughness;
+};
+
+struct Light {
+	Surface surface;
+	Surface layers[2];
+	float energy;
+};
+
+void fragment() {
+	Light lights[4];
+	|
+}
+`
+	complete := func(expr string) []string {
+		return completeIn(t, "file:///tmp/test.gdshader", strings.Replace(content, "|", expr+"|", 1))
+	}
+
+	surface := []string{"color", "roughness"}
+	for _, expr := range []string{"lights[0].surface.", "lights[1].layers[0].", "lights[2].surface.rou"} {
+		if labels := complete(expr); strings.Join(labels, " ") != strings.Join(surface, " ") {
+			t.Errorf("%s: expected the fields of Surface, got %v", expr, labels)
+		}
+	}
+
+	labels := complete("lights[0].layers[1].color.")
+	if !containsLabel(labels, "rgb") || containsLabel(labels, "rgba") || containsLabel(labels, "roughness") {
+		t.Errorf("expected vec3 swizzles, got %v", labels)
+	}
+}
+
+func TestCompletionShaderVersion(t *testing.T) {
+	content := `shader_type particles;
+render_mode keep_data, 
+void start() {
+	float r = 
+}
+`
+	complete := func(godotVersion, marker string) []string {
+		t.Helper()
+		s := NewServer("test", "test")
+		s.applySettings(map[string]any{"godotVersion": godotVersion})
+		doc := s.workspace.OpenDocument("file:///tmp/test.gdshader", content)
+		offset := strings.Index(content, marker) + len(marker)
+		prefix := content[strings.LastIndexByte(content[:offset], '\n')+1 : offset]
+
+		var labels []string
+		for _, item := range s.getCompletions(doc, prefix, prefix, offset) {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	if labels := complete("4.1", "float r = "); containsLabel(labels, "AMOUNT_RATIO") {
+		t.Errorf("expected no AMOUNT_RATIO for Godot 4.1, got %v", labels)
+	}
+	if labels := complete("4.4", "float r = "); !containsLabel(labels, "AMOUNT_RATIO") {
+		t.Errorf("expected AMOUNT_RATIO for Godot 4.4, got %v", labels)
+	}
+
+	labels := complete("4.4", "keep_data, ")
+	if !containsLabel(labels, "disable_force") || containsLabel(labels, "keep_data") || containsLabel(labels, "if") {
+		t.Errorf("expected the undeclared render modes, got %v", labels)
+	}
+}
+
+func TestCompletionShaderDeclarations(t *testing.T) {
+	s := NewServer("test", "test")
+	complete := func(content, marker string) []protocol.CompletionItem {
+		t.Helper()
+		doc := s.workspace.OpenDocument("file:///tmp/decl.gdshader", content)
+		offset := strings.Index(content, marker) + len(marker)
+		prefix := content[strings.LastIndexByte(content[:offset], '\n')+1 : offset]
+		return s.getCompletions(doc, prefix, prefix, offset)
+	}
+
+	items := complete("shader_type \n", "shader_type ")
+	var labels []string
+	for _, item := range items {
+		labels = append(labels, item.Label)
+	}
+	if !slices.Equal(labels, []string{"spatial", "canvas_item", "particles", "sky", "fog"}) {
+		t.Errorf("expected the shader types, got %v", labels)
+	}
+	if items[0].Detail == nil || !strings.HasPrefix(*items[0].Detail, "3D shader") {
+		t.Errorf("expected the shader type description as detail, got %+v", items[0])
+	}
+
+	// Render modes are those of the declared shader type
+	items = complete("shader_type canvas_item;\nrender_mode bl\n", "render_mode bl")
+	detail := ""
+	labels = nil
+	for _, item := range items {
+		labels = append(labels, item.Label)
+		if item.Label == "light_only" && item.Detail != nil {
+			detail = *item.Detail
+		}
+	}
+	if !containsLabel(labels, "blend_disabled") || containsLabel(labels, "cull_disabled") {
+		t.Errorf("expected the canvas_item render modes, got %v", labels)
+	}
+	if detail != "Only draw on light pass" {
+		t.Errorf("expected the render mode description as detail, got %q", detail)
+	}
+}
+
+func TestCompletionShaderSnippets(t *testing.T) {
+	s := NewServer("test", "test")
+	complete := func(content string) map[string]protocol.CompletionItem {
+		t.Helper()
+		content, after, _ := strings.Cut(content, "|")
+		offset := len(content)
+		content += after
+		doc := s.workspace.OpenDocument("file:///tmp/snippets.gdshader", content)
+		prefix := content[strings.LastIndexByte(content[:offset], '\n')+1 : offset]
+		snippets := make(map[string]protocol.CompletionItem)
+		for _, item := range s.getCompletions(doc, prefix, prefix, offset) {
+			if *item.Kind == protocol.CompletionItemKindSnippet {
+				snippets[item.Label] = item
+			}
+		}
+		return snippets
+	}
+
+	content := "shader_type spatial;\n\n|\n\nvoid vertex() {\n}\n\nvoid fragment() {\n\t\n}\n"
+	if snippets := complete(content); len(snippets) != 0 {
+		t.Errorf("expected no snippets without client support, got %v", slices.Collect(maps.Keys(snippets)))
+	}
+
+	var caps protocol.ClientCapabilities
+	if err := json.Unmarshal([]byte(`{"textDocument": {"completion": {"completionItem": {"snippetSupport": true}}}}`), &caps); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.initialize(&glsp.Context{}, &protocol.InitializeParams{Capabilities: caps}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Between declarations: the processor functions not defined yet, and
+	// the uniform and function templates
+	snippets := complete(content)
+	for _, want := range []string{"light()", "uniform hint_range", "screen texture", "triplanar"} {
+		if _, ok := snippets[want]; !ok {
+			t.Errorf("expected snippet %q, got %v", want, slices.Collect(maps.Keys(snippets)))
+		}
+	}
+	for _, unwanted := range []string{"vertex()", "fragment()", "for loop"} {
+		if _, ok := snippets[unwanted]; ok {
+			t.Errorf("expected no snippet %q between declarations", unwanted)
+		}
+	}
+	if item := snippets["light()"]; *item.InsertText != "void light() {\n\t$0\n}" || *item.InsertTextFormat != protocol.InsertTextFormatSnippet {
+		t.Errorf("unexpected light() snippet: %+v", item)
+	}
+
+	// In function bodies: loops, and patterns using the built-ins in scope
+	body := strings.Replace(strings.Replace(content, "|", "", 1), "fragment() {\n\t", "fragment() {\n\t|", 1)
+	snippets = complete(body)
+	if _, ok := snippets["for loop"]; !ok {
+		t.Errorf("expected the for loop snippet in a function, got %v", slices.Collect(maps.Keys(snippets)))
+	}
+	if _, ok := snippets["screen uv"]; !ok {
+		t.Error("expected the screen uv snippet in fragment")
+	}
+	if _, ok := snippets["uniform hint_range"]; ok {
+		t.Error("expected no uniform snippet in a function")
+	}
+	vertex := strings.Replace(strings.Replace(content, "|", "", 1), "vertex() {\n", "vertex() {\n\t|\n", 1)
+	if _, ok := complete(vertex)["screen uv"]; ok {
+		t.Error("expected no screen uv snippet in vertex, where SCREEN_UV is not available")
+	}
+
+	s.applySettings(map[string]any{"shaderSnippets": false})
+	if snippets := complete(content); len(snippets) != 0 {
+		t.Errorf("expected no snippets when turned off, got %v", slices.Collect(maps.Keys(snippets)))
+	}
+}
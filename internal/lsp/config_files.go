@@ -0,0 +1,214 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// .import files are written by the editor next to every imported asset, and
+// .gdextension files tell the engine which native library to load. Both are
+// ConfigFile documents like project.godot.
+
+// importSectionDescriptions describes the sections of .import files.
+var importSectionDescriptions = map[string]string{
+	"remap":  "How the imported resource is loaded: its importer, type, uid and imported data.",
+	"deps":   "The source file and the files the import produced.",
+	"params": "Import options, as set in the Import dock.",
+}
+
+// importSettingDescriptions describes the keys of .import files by their
+// full section/key name.
+var importSettingDescriptions = map[string]string{
+	"remap/importer":         "Importer that processed the source file, e.g. `texture` or `scene`. `keep` leaves the file as is and `skip` does not import it.",
+	"remap/importer_version": "Version of the importer, which reimports the file when it changes.",
+	"remap/type":             "Class of the imported resource.",
+	"remap/uid":              "The uid:// identifier of the imported resource, which stays the same when the file is moved.",
+	"remap/path":             "Imported data loaded in place of the source file.",
+	"remap/valid":            "`false` when the import failed.",
+	"remap/metadata":         "Importer metadata, such as the VRAM compression formats of a texture.",
+	"deps/source_file":       "The imported file.",
+	"deps/source_md5":        "Checksum of the source file, used to detect changes.",
+	"deps/dest_files":        "Files written by the import, under res://.godot/imported/.",
+}
+
+// gdextensionSectionDescriptions describes the sections of .gdextension files.
+var gdextensionSectionDescriptions = map[string]string{
+	"configuration": "How the engine loads the extension.",
+	"libraries":     "Native library to load on each platform, keyed by feature tags such as `linux.debug.x86_64`.",
+	"icons":         "Editor icons of the classes the extension registers, by class name.",
+	"dependencies":  "Further libraries exported along with the extension, keyed by feature tags.",
+}
+
+// gdextensionSettingDescriptions describes the keys of .gdextension files
+// by their full section/key name.
+var gdextensionSettingDescriptions = map[string]string{
+	"configuration/entry_symbol":          "Name of the C function the engine calls to initialize the extension.",
+	"configuration/compatibility_minimum": "Oldest Godot version the extension can be loaded in, e.g. `\"4.1\"`.",
+	"configuration/compatibility_maximum": "Newest Godot version the extension can be loaded in.",
+	"configuration/reloadable":            "Whether the editor reloads the extension when its library is rebuilt.",
+}
+
+// configDescriptions returns the section and setting descriptions for the
+// kind of ConfigFile at uri.
+func configDescriptions(uri string) (sections, settings map[string]string) {
+	switch {
+	case strings.HasSuffix(uri, analysis.ImportFileExt):
+		return importSectionDescriptions, importSettingDescriptions
+	case strings.HasSuffix(uri, analysis.GDExtensionFileExt):
+		return gdextensionSectionDescriptions, gdextensionSettingDescriptions
+	}
+	return projectSectionDescriptions, projectSettingDescriptions
+}
+
+// checkImportFile reports the malformed sections and values of an .import
+// file.
+func checkImportFile(cfg *parser.ConfigDocument, add addDiagnostic) {
+	checkConfigSections(cfg, importSectionDescriptions, "an import file", add)
+
+	remap := cfg.Section("remap")
+	if remap == nil {
+		add(parser.Range{}, protocol.DiagnosticSeverityError, codeMissingKey, "Import file has no [remap] section")
+		return
+	}
+	if remap.Get("importer") == nil {
+		add(remap.NameRange, protocol.DiagnosticSeverityError, codeMissingKey, "[remap] has no importer")
+	}
+	if v := configValue(remap.Get("uid")); v != nil {
+		sv, ok := v.(*parser.StringValue)
+		if ok {
+			_, ok = analysis.TextToUID(sv.Value)
+		}
+		if !ok {
+			add(v.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, "uid must be a uid:// string")
+		}
+	}
+
+	deps := cfg.Section("deps")
+	if deps == nil {
+		return
+	}
+	if v := configValue(deps.Get("source_file")); v != nil {
+		if sv, ok := v.(*parser.StringValue); !ok || !strings.HasPrefix(sv.Value, "res://") {
+			add(v.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, "source_file must be a res:// path string")
+		}
+	}
+	if v := configValue(deps.Get("dest_files")); v != nil && !isStringArray(v) {
+		add(v.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, "dest_files must be an array of path strings")
+	}
+}
+
+// checkGDExtension reports the malformed sections and values of a
+// .gdextension file.
+func checkGDExtension(cfg *parser.ConfigDocument, add addDiagnostic) {
+	checkConfigSections(cfg, gdextensionSectionDescriptions, "a GDExtension file", add)
+
+	configuration := cfg.Section("configuration")
+	if configuration == nil {
+		add(parser.Range{}, protocol.DiagnosticSeverityError, codeMissingKey, "GDExtension file has no [configuration] section")
+	} else {
+		for _, key := range []string{"entry_symbol", "compatibility_minimum"} {
+			v := configValue(configuration.Get(key))
+			if v == nil {
+				add(configuration.NameRange, protocol.DiagnosticSeverityError, codeMissingKey, fmt.Sprintf("[configuration] has no %s", key))
+			} else if _, ok := v.(*parser.StringValue); !ok {
+				add(v.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, fmt.Sprintf("%s must be a string", key))
+			}
+		}
+		if v := configValue(configuration.Get("reloadable")); v != nil {
+			if _, ok := v.(*parser.BoolValue); !ok {
+				add(v.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, "reloadable must be true or false")
+			}
+		}
+	}
+
+	libraries := cfg.Section("libraries")
+	if libraries == nil || len(libraries.Properties) == 0 {
+		add(parser.Range{}, protocol.DiagnosticSeverityError, codeMissingKey, "GDExtension file has no [libraries] to load")
+		return
+	}
+	for _, prop := range libraries.Properties {
+		if _, ok := prop.Value.(*parser.StringValue); !ok {
+			add(prop.Value.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting,
+				fmt.Sprintf("Library for '%s' must be a path string", prop.Key))
+		}
+	}
+}
+
+// checkConfigSections warns about sections the engine does not read.
+func checkConfigSections(cfg *parser.ConfigDocument, known map[string]string, file string, add addDiagnostic) {
+	for _, section := range cfg.Sections {
+		if _, ok := known[section.Name]; !ok {
+			add(section.NameRange, protocol.DiagnosticSeverityWarning, codeUnknownSection,
+				fmt.Sprintf("Unknown section [%s] in %s", section.Name, file))
+		}
+	}
+	for _, prop := range cfg.Properties {
+		add(prop.KeyRange, protocol.DiagnosticSeverityWarning, codeUnknownSection,
+			fmt.Sprintf("Key '%s' is outside of any section", prop.Key))
+	}
+}
+
+// configValue returns the value of a property, or nil when it is not set.
+func configValue(prop *parser.Property) parser.Value {
+	if prop == nil {
+		return nil
+	}
+	return prop.Value
+}
+
+// isStringArray reports whether v is an array of strings.
+func isStringArray(v parser.Value) bool {
+	arr, ok := v.(*parser.ArrayValue)
+	if !ok {
+		return false
+	}
+	for _, elem := range arr.Values {
+		if _, ok := elem.(*parser.StringValue); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// configLinks returns the links of the paths in a ConfigFile document. The
+// library paths of a .gdextension file may be relative to the file.
+func (s *Server) configLinks(doc *analysis.Document) []protocol.DocumentLink {
+	links := []protocol.DocumentLink{}
+	gdextension := strings.HasSuffix(doc.URI, analysis.GDExtensionFileExt)
+	visit := func(section string, prop *parser.Property) {
+		walkValue(prop.Value, func(v parser.Value) {
+			sv, ok := v.(*parser.StringValue)
+			if !ok || sv.Value == "" {
+				return
+			}
+			if link := s.stringLink(sv, doc.URI); link != nil {
+				links = append(links, *link)
+				return
+			}
+			if !gdextension || (section != "libraries" && section != "dependencies") || strings.Contains(sv.Value, "://") {
+				return
+			}
+			if location := s.resolveResourcePath(sv.Value, doc.URI); location != nil {
+				links = append(links, protocol.DocumentLink{
+					Range:   toProtocolRange(sv.Range),
+					Target:  strPtr(location.URI),
+					Tooltip: strPtr("Open " + sv.Value),
+				})
+			}
+		})
+	}
+	for _, prop := range doc.ConfigAST.Properties {
+		visit("", prop)
+	}
+	for _, section := range doc.ConfigAST.Sections {
+		for _, prop := range section.Properties {
+			visit(section.Name, prop)
+		}
+	}
+	return links
+}
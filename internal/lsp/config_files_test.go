@@ -0,0 +1,175 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const importFile = `[remap]
+
+importer="texture"
+type="CompressedTexture2D"
+uid="uid://cvx3w7ihlvwq8"
+path="res://.godot/imported/icon.svg-218a8f2b3041327d8a5756f3a245f83b.ctex"
+
+[deps]
+
+source_file="res://icon.svg"
+dest_files=["res://.godot/imported/icon.svg-218a8f2b3041327d8a5756f3a245f83b.ctex"]
+
+[params]
+
+compress/mode=0
+`
+
+const gdextensionFile = `[configuration]
+
+entry_symbol="example_library_init"
+compatibility_minimum="4.1"
+reloadable=true
+
+[libraries]
+
+linux.debug.x86_64="bin/libexample.linux.debug.x86_64.so"
+windows.debug.x86_64="res://bin/libexample.windows.debug.x86_64.dll"
+`
+
+func TestImportAndGDExtensionDiagnostics(t *testing.T) {
+	s := NewServer("test", "test")
+	if diagnostics := s.Check("file:///game/icon.svg.import", importFile); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics for a valid import file: %v", diagnostics)
+	}
+	if diagnostics := s.Check("file:///game/example.gdextension", gdextensionFile); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics for a valid GDExtension file: %v", diagnostics)
+	}
+
+	tests := []struct {
+		uri, content string
+		expected     map[string]string // Message to code
+	}{
+		{
+			uri: "file:///game/icon.svg.import",
+			content: `version=1
+
+[remap]
+uid="not a uid"
+
+[deps]
+source_file=42
+dest_files="res://icon.ctex"
+
+[extra]
+`,
+			expected: map[string]string{
+				"Key 'version' is outside of any section":     codeUnknownSection,
+				"[remap] has no importer":                     codeMissingKey,
+				"uid must be a uid:// string":                 codeInvalidSetting,
+				"source_file must be a res:// path string":    codeInvalidSetting,
+				"dest_files must be an array of path strings": codeInvalidSetting,
+				"Unknown section [extra] in an import file":   codeUnknownSection,
+			},
+		},
+		{
+			uri: "file:///game/example.gdextension",
+			content: `[configuration]
+compatibility_minimum=4.1
+reloadable="yes"
+`,
+			expected: map[string]string{
+				"[configuration] has no entry_symbol":         codeMissingKey,
+				"compatibility_minimum must be a string":      codeInvalidSetting,
+				"reloadable must be true or false":            codeInvalidSetting,
+				"GDExtension file has no [libraries] to load": codeMissingKey,
+			},
+		},
+	}
+	for _, tt := range tests {
+		diagnostics := s.Check(tt.uri, tt.content)
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%s: expected %d diagnostics, got %v", tt.uri, len(tt.expected), diagnostics)
+		}
+		for _, d := range diagnostics {
+			code, ok := tt.expected[d.Message]
+			if !ok {
+				t.Errorf("%s: unexpected diagnostic %q", tt.uri, d.Message)
+			} else if d.Code == nil || d.Code.Value != code {
+				t.Errorf("%s: expected code %s for %q, got %v", tt.uri, code, d.Message, d.Code)
+			}
+		}
+	}
+}
+
+func TestImportAndGDExtensionHover(t *testing.T) {
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///game/icon.svg.import", importFile)
+
+	hover := s.findConfigHoverInfo(doc, 2, 3)
+	if !strings.Contains(hover, "`remap/importer`") || !strings.Contains(hover, "Importer that processed") {
+		t.Errorf("unexpected importer hover:\n%s", hover)
+	}
+	hover = s.findConfigHoverInfo(doc, 7, 2)
+	if !strings.Contains(hover, "### Section: `[deps]`") || !strings.Contains(hover, "source file") {
+		t.Errorf("unexpected section hover:\n%s", hover)
+	}
+
+	doc = s.workspace.OpenDocument("file:///game/example.gdextension", gdextensionFile)
+	hover = s.findConfigHoverInfo(doc, 2, 3)
+	if !strings.Contains(hover, "`configuration/entry_symbol`") || !strings.Contains(hover, "C function") {
+		t.Errorf("unexpected entry_symbol hover:\n%s", hover)
+	}
+
+	result, err := s.configDocumentSymbols(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := result.([]protocol.DocumentSymbol)
+	if len(symbols) != 2 || symbols[1].Name != "libraries" || len(symbols[1].Children) != 2 {
+		t.Errorf("unexpected symbols: %+v", symbols)
+	}
+}
+
+func TestImportAndGDExtensionLinks(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"project.godot", "icon.svg", "bin/libexample.linux.debug.x86_64.so"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := NewServer("test", "test")
+
+	targets := func(name, content string) []string {
+		uri := pathToURI(filepath.Join(dir, name))
+		s.workspace.OpenDocument(uri, content)
+		links, err := s.textDocumentDocumentLink(&glsp.Context{}, &protocol.DocumentLinkParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var targets []string
+		for _, link := range links {
+			targets = append(targets, uriToPath(*link.Target))
+		}
+		return targets
+	}
+
+	found := targets("icon.svg.import", importFile)
+	if !slices.Contains(found, filepath.Join(dir, "icon.svg")) {
+		t.Errorf("expected a link to the source file, got %v", found)
+	}
+
+	found = targets("example.gdextension", gdextensionFile)
+	if !slices.Contains(found, filepath.Join(dir, "bin", "libexample.linux.debug.x86_64.so")) {
+		t.Errorf("expected a link to the relative library path, got %v", found)
+	}
+}
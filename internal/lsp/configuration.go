@@ -0,0 +1,69 @@
+package lsp
+
+import (
+	"context"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/config"
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// currentSettings returns a snapshot of the user settings.
+func (s *Server) currentSettings() config.Settings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings
+}
+
+// applySettings updates the user settings from a settings object, logging
+// the values it rejects, and returns the settings it replaced. Shaders are
+// checked against the Godot version the settings target.
+func (s *Server) applySettings(opts map[string]any) config.Settings {
+	s.settingsMu.Lock()
+	previous := s.settings
+	errs := s.settings.Apply(opts)
+	s.settingsMu.Unlock()
+
+	for _, err := range errs {
+		s.log.Warningf("settings: %s", err.Error())
+	}
+	if version, ok := gdshader.ParseVersion(s.currentSettings().GodotVersion); ok {
+		s.workspace.SetShaderVersion(version)
+	}
+	return previous
+}
+
+// workspaceDidChangeConfiguration handles the
+// workspace/didChangeConfiguration notification. Clients usually nest the
+// settings of each server under its name, so a "gdls" object is used when
+// there is one. Capabilities are advertised once, so turning them on or off
+// only takes effect on the next start.
+func (s *Server) workspaceDidChangeConfiguration(ctx *glsp.Context, params *protocol.DidChangeConfigurationParams) error {
+	opts, ok := params.Settings.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if section, ok := opts["gdls"].(map[string]any); ok {
+		opts = section
+	}
+
+	previous := s.applySettings(opts)
+
+	// A new size limit adds or drops files from the index
+	if s.currentSettings().MaxFileSize != previous.MaxFileSize {
+		for _, folder := range s.workspace.GetFolders() {
+			if status, ok := s.indexFolder(context.Background(), folder, nil); ok {
+				ctx.Notify(StatusNotification, status)
+			}
+		}
+	}
+
+	// Severities and the target version change what is reported
+	s.refreshDiagnostics(ctx)
+	for _, doc := range s.workspace.GetAllDocuments() {
+		s.scheduleDiagnostics(ctx, doc.URI)
+	}
+	return nil
+}
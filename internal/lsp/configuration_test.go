@@ -0,0 +1,54 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDidChangeConfiguration(t *testing.T) {
+	s := NewServer("test", "test")
+	initializeServer(t, s, map[string]any{
+		"diagnosticSeverity": map[string]any{"unknown-property": "hint"},
+	})
+
+	content := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node\" parent=\"Missing\"]\npositon = 1\n"
+	doc := s.workspace.OpenDocument("file:///tmp/main.tscn", content)
+
+	severities := func() map[string]protocol.DiagnosticSeverity {
+		result := make(map[string]protocol.DiagnosticSeverity)
+		for _, d := range s.computeDiagnostics(doc) {
+			result[d.Code.Value.(string)] = *d.Severity
+		}
+		return result
+	}
+
+	got := severities()
+	if got[codeUnknownProperty] != protocol.DiagnosticSeverityHint || got[codeMissingParent] != protocol.DiagnosticSeverityWarning {
+		t.Errorf("expected the initialization severity override, got %v", got)
+	}
+
+	// Settings nested under the server name are applied live
+	ctx := &glsp.Context{Notify: func(string, any) {}}
+	err := s.workspaceDidChangeConfiguration(ctx, &protocol.DidChangeConfigurationParams{
+		Settings: map[string]any{"gdls": map[string]any{
+			"diagnosticSeverity": map[string]any{"missing-parent": "off"},
+			"alignProperties":    true,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got = severities()
+	if _, ok := got[codeMissingParent]; ok {
+		t.Errorf("expected missing-parent to be turned off, got %v", got)
+	}
+	if got[codeUnknownProperty] != protocol.DiagnosticSeverityWarning {
+		t.Errorf("expected the new overrides to replace the earlier ones, got %v", got)
+	}
+	if !s.currentSettings().AlignProperties {
+		t.Error("expected alignProperties to be applied")
+	}
+}
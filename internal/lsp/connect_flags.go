@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// connectFlag is a bit of Object.ConnectFlags, which the flags= of a
+// [connection] holds.
+type connectFlag struct {
+	name        string
+	value       int
+	description string
+}
+
+var connectFlags = []connectFlag{
+	{"CONNECT_DEFERRED", 1, "The method is called at the end of the frame, not when the signal is emitted"},
+	{"CONNECT_PERSIST", 2, "The connection is saved with the scene"},
+	{"CONNECT_ONE_SHOT", 4, "The connection is removed after the first emission"},
+	{"CONNECT_REFERENCE_COUNTED", 8, "Connecting the same signal and method again counts up, and it takes as many disconnects to remove the connection"},
+	{"CONNECT_APPEND_SOURCE_OBJECT", 16, "The emitting object is passed to the method after the signal arguments"},
+}
+
+// connectFlagsPattern matches a connection header up to the value of its
+// flags, e.g. `[connection signal="pressed" from="." to="." flags=`.
+var connectFlagsPattern = regexp.MustCompile(`^\s*\[connection\b.*\bflags=\d*$`)
+
+// decodeConnectFlags returns the names of the bits set in flags, in order,
+// followed by the value of the unknown bits.
+func decodeConnectFlags(flags int) []string {
+	var names []string
+	for _, f := range connectFlags {
+		if flags&f.value != 0 {
+			names = append(names, f.name)
+			flags &^= f.value
+		}
+	}
+	if flags != 0 {
+		names = append(names, strconv.Itoa(flags))
+	}
+	return names
+}
+
+// formatConnectFlags describes the flags of a connection, one bit per line.
+func formatConnectFlags(flags int) string {
+	var sb strings.Builder
+	if flags == 0 {
+		sb.WriteString("**Flags:** `0` (none)\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("**Flags:** `%d` = `%s`\n\n", flags, strings.Join(decodeConnectFlags(flags), " | ")))
+	for _, f := range connectFlags {
+		if flags&f.value != 0 {
+			sb.WriteString(fmt.Sprintf("- `%s`: %s\n", f.name, f.description))
+		}
+	}
+	return sb.String()
+}
+
+// getConnectFlagsCompletions returns the values of the flags of a
+// connection, labeled with the names of their bits. Every combination is
+// offered, as Godot writes the sum.
+func (s *Server) getConnectFlagsCompletions() []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindConstant
+	for value := 0; value < 1<<len(connectFlags); value++ {
+		label := "none"
+		if value != 0 {
+			label = strings.Join(decodeConnectFlags(value), " | ")
+		}
+		number := strconv.Itoa(value)
+		items = append(items, protocol.CompletionItem{
+			Label:      label,
+			Kind:       &kind,
+			Detail:     strPtr(number),
+			InsertText: strPtr(number),
+			FilterText: strPtr(number),
+			SortText:   strPtr(fmt.Sprintf("%02d", value)),
+		})
+	}
+	return items
+}
@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDecodeConnectFlags(t *testing.T) {
+	tests := []struct {
+		flags int
+		want  []string
+	}{
+		{0, nil},
+		{3, []string{"CONNECT_DEFERRED", "CONNECT_PERSIST"}},
+		{12, []string{"CONNECT_ONE_SHOT", "CONNECT_REFERENCE_COUNTED"}},
+		{16 | 64, []string{"CONNECT_APPEND_SOURCE_OBJECT", "64"}},
+	}
+	for _, tt := range tests {
+		if got := decodeConnectFlags(tt.flags); !slices.Equal(got, tt.want) {
+			t.Errorf("decodeConnectFlags(%d) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestConnectFlags(t *testing.T) {
+	uri := "file:///tmp/menu.tscn"
+	content := `[gd_scene format=3]
+
+[node name="Menu" type="Control"]
+
+[node name="Play" type="Button" parent="."]
+
+[connection signal="pressed" from="Play" to="." method="_on_play" flags=3]
+[connection signal="pressed" from="Play" to="." method="_on_click"]
+[connection signal="pressed" from="Play" to="." method="_on_first" flags=0]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(uri, content)
+
+	hover := s.findTSCNHoverInfo(doc, 6, 10)
+	for _, want := range []string{
+		"**Flags:** `3` = `CONNECT_DEFERRED | CONNECT_PERSIST`",
+		"- `CONNECT_DEFERRED`: ",
+		"- `CONNECT_PERSIST`: ",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("expected hover to contain %q, got:\n%s", want, hover)
+		}
+	}
+	if hover := s.findTSCNHoverInfo(doc, 7, 10); strings.Contains(hover, "Flags") {
+		t.Errorf("expected no flags in the hover of a connection without them, got:\n%s", hover)
+	}
+
+	// Only the connection setting flags gets a hint, after the number
+	result, err := s.textDocumentInlayHint(&glsp.Context{}, &inlayHintParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{End: protocol.Position{Line: 100}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hints := result.([]inlayHint)
+	if len(hints) != 1 || hints[0].Label != "→ CONNECT_DEFERRED | CONNECT_PERSIST" {
+		t.Fatalf("expected one flags hint, got %+v", hints)
+	}
+	line := `[connection signal="pressed" from="Play" to="." method="_on_play" flags=3`
+	if hints[0].Position != (protocol.Position{Line: 6, Character: uint32(len(line))}) {
+		t.Errorf("expected the hint after the flags, got %+v", hints[0].Position)
+	}
+}
+
+func TestCompletionConnectFlags(t *testing.T) {
+	labels := completeAt(t, `[gd_scene format=3]
+
+[node name="Menu" type="Control"]
+
+[connection signal="pressed" from="." to="." method="_on_play" flags=|]
+`)
+	for _, want := range []string{"none", "CONNECT_DEFERRED", "CONNECT_DEFERRED | CONNECT_PERSIST", "CONNECT_ONE_SHOT | CONNECT_REFERENCE_COUNTED"} {
+		if !containsLabel(labels, want) {
+			t.Errorf("expected %q in completions, got %v", want, labels)
+		}
+	}
+	if len(labels) != 32 {
+		t.Errorf("expected every combination of flags, got %d", len(labels))
+	}
+
+	// Other values of a connection header are not flags
+	labels = completeAt(t, `[gd_scene format=3]
+
+[connection signal="pressed" from="." to="." method=|]
+`)
+	if containsLabel(labels, "CONNECT_DEFERRED") {
+		t.Errorf("expected no flags outside flags=, got %v", labels)
+	}
+}
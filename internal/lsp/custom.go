@@ -0,0 +1,45 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"github.com/tliron/glsp"
+)
+
+// customHandler handles a request the protocol handler does not know about:
+// the gdls/ requests and those added after LSP 3.16. validParams is false when the params cannot be decoded.
+type customHandler func(ctx *glsp.Context) (result any, validParams bool, err error)
+
+// customRequest adapts a handler of decoded params to a customHandler.
+func customRequest[P any](handle func(ctx *glsp.Context, params *P) (any, error)) customHandler {
+	return func(ctx *glsp.Context) (any, bool, error) {
+		var params P
+		if err := json.Unmarshal(ctx.Params, &params); err != nil {
+			return nil, false, err
+		}
+		result, err := handle(ctx, &params)
+		return result, true, err
+	}
+}
+
+// handle dispatches a message to its custom handler, or to the protocol
+// handler for every other method.
+func (s *Server) handle(ctx *glsp.Context) (result any, validMethod, validParams bool, err error) {
+	if handle, ok := s.custom[ctx.Method]; ok {
+		result, validParams, err = handle(ctx)
+		return result, true, validParams, err
+	}
+	return s.handler.Handle(ctx)
+}
+
+// experimentalCapabilities returns the experimental server capabilities,
+// where the gdls/ requests the server answers are advertised to the
+// extensions that use them.
+func experimentalCapabilities(caps *serverCapabilities) map[string]any {
+	experimental, ok := caps.Experimental.(map[string]any)
+	if !ok {
+		experimental = make(map[string]any)
+		caps.Experimental = experimental
+	}
+	return experimental
+}
@@ -10,13 +10,26 @@ import (
 	protocol "github.com/tliron/glsp/protocol_3_16"
 
 	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
 	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "definition",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentDefinition = s.textDocumentDefinition
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DefinitionProvider = &protocol.DefinitionOptions{}
+		},
+	})
+}
+
 // textDocumentDefinition handles the textDocument/definition request.
 func (s *Server) textDocumentDefinition(ctx *glsp.Context, params *protocol.DefinitionParams) (any, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
-	if doc == nil || doc.TSCNAST == nil {
+	if doc == nil {
 		return nil, nil
 	}
 
@@ -24,7 +37,13 @@ func (s *Server) textDocumentDefinition(ctx *glsp.Context, params *protocol.Defi
 	col := int(params.Position.Character)
 
 	// Find what's at this position and where it's defined
-	location := s.findDefinition(doc, params.TextDocument.URI, line, col)
+	var location *protocol.Location
+	switch {
+	case doc.TSCNAST != nil:
+		location = s.findDefinition(doc, params.TextDocument.URI, line, col)
+	case doc.ShaderAST != nil:
+		location = findGDShaderDefinition(doc.ShaderAST, s.analyzedShader(doc), params.TextDocument.URI, line, col)
+	}
 	if location == nil {
 		return nil, nil
 	}
@@ -36,160 +55,268 @@ func (s *Server) textDocumentDefinition(ctx *glsp.Context, params *protocol.Defi
 func (s *Server) findDefinition(doc *analysis.Document, uri string, line, col int) *protocol.Location {
 	ast := doc.TSCNAST
 
-	// Check if we're on a resource reference in a property value
-	for _, sub := range ast.SubResources {
-		for _, prop := range sub.Properties {
-			if loc := s.findDefinitionInValue(prop.Value, ast, uri, line, col); loc != nil {
-				return loc
+	if ref := resourceRefAt(doc, line, col); ref != nil {
+		// Instances open the instanced scene at its root
+		if node, ok := analysis.ElementAt[*parser.Node](doc.Positions, line, col); ok && node.Instance == parser.Value(ref) {
+			if sceneURI, scene := s.instancedScene(uri, ast, node); scene != nil {
+				if root := analysis.NewSceneTree(scene).Root; root != nil {
+					return nodeLocation(sceneURI, root)
+				}
 			}
 		}
+		// Other ExtResource("...") and SubResource("...") go to the header
+		// declaring their ID
+		return resourceDeclarationLocation(uri, ast, ref)
 	}
 
-	for _, node := range ast.Nodes {
-		// Check instance references
-		if node.Instance != nil {
-			if loc := s.findDefinitionInValue(node.Instance, ast, uri, line, col); loc != nil {
-				return loc
-			}
-		}
-		// Check property values
-		for _, prop := range node.Properties {
-			if loc := s.findDefinitionInValue(prop.Value, ast, uri, line, col); loc != nil {
-				return loc
-			}
-		}
+	// Check if we're on an ext_resource path
+	if ext, ok := analysis.ElementAt[*parser.ExtResource](doc.Positions, line, col); ok {
+		// Return location to the file itself, at the class of scripts
+		return s.resourceFileLocation(uri, ext)
 	}
 
-	// Check if we're on an ext_resource path
-	for _, ext := range ast.ExtResources {
-		if isInRange(ext.Range, line, col) {
-			// Return location to the file itself
-			return s.resolveResourcePath(ext.Path, uri)
+	// Check if we're on the method of a connection, declared by the script
+	// of the node it connects to
+	if conn, ok := analysis.ElementAt[*parser.Connection](doc.Positions, line, col); ok && conn.Method != "" && isInRange(conn.MethodRange, line, col) {
+		return s.connectionMethodLocation(uri, ast, conn)
+	}
+
+	// Check if we're on a node path: parent=, a NodePath() value or a
+	// connection's from/to
+	tree := analysis.NewSceneTree(ast)
+	if np := nodePathAt(doc, tree, line, col); np != nil {
+		// Resolve up to the name under the cursor, e.g. "Parent" in "Parent/Child"
+		targetURI, target := s.resolveNodePath(uri, ast, tree, np.base, nodePathPrefixAt(np, line, col))
+		if target != nil {
+			return nodeLocation(targetURI, target)
 		}
+		return nil
 	}
 
 	// Check if we're on a node with a parent reference
-	for _, node := range ast.Nodes {
-		if isInRange(node.Range, line, col) && node.Parent != "" && node.Parent != "." {
-			// Find the parent node
-			return s.findNodeByPath(ast, node.Parent, uri)
+	if node, ok := analysis.ElementAt[*parser.Node](doc.Positions, line, col); ok && node.Parent != "" && node.Parent != "." {
+		if parentURI, parent := s.resolveNodePath(uri, ast, tree, tree.Root, node.Parent); parent != nil {
+			return nodeLocation(parentURI, parent)
 		}
 	}
 
 	return nil
 }
 
-// findDefinitionInValue finds definition for a resource reference in a value.
-func (s *Server) findDefinitionInValue(v parser.Value, ast *parser.Document, uri string, line, col int) *protocol.Location {
-	if v == nil {
+// connectionMethodLocation returns where the method of a connection is
+// declared, in the script of the node it connects to or one that script
+// extends.
+func (s *Server) connectionMethodLocation(uri string, ast *parser.Document, conn *parser.Connection) *protocol.Location {
+	tree := analysis.NewSceneTree(ast)
+	targetURI, target := s.resolveNodePath(uri, ast, tree, tree.Root, conn.To)
+	if target == nil {
 		return nil
 	}
+	targetAST := ast
+	if targetURI != uri {
+		targetAST = s.loadScene(targetURI)
+	}
+	scriptURI := s.nodeScript(targetURI, targetAST, target.Node)
+	if scriptURI == "" {
+		return nil
+	}
+	loc, _, _ := s.scriptMethod(scriptURI, conn.Method)
+	return loc
+}
 
-	switch val := v.(type) {
-	case *parser.ResourceRef:
-		if isInRange(val.Range, line, col) {
-			switch val.RefType {
-			case "ExtResource":
-				// Find the ext_resource definition
-				for _, ext := range ast.ExtResources {
-					if ext.ID == val.ID {
-						return &protocol.Location{
-							URI: uri,
-							Range: protocol.Range{
-								Start: protocol.Position{
-									Line:      uint32(ext.Range.Start.Line),
-									Character: uint32(ext.Range.Start.Column),
-								},
-								End: protocol.Position{
-									Line:      uint32(ext.Range.End.Line),
-									Character: uint32(ext.Range.End.Column),
-								},
-							},
-						}
-					}
-				}
-			case "SubResource":
-				// Find the sub_resource definition
-				for _, sub := range ast.SubResources {
-					if sub.ID == val.ID {
-						return &protocol.Location{
-							URI: uri,
-							Range: protocol.Range{
-								Start: protocol.Position{
-									Line:      uint32(sub.Range.Start.Line),
-									Character: uint32(sub.Range.Start.Column),
-								},
-								End: protocol.Position{
-									Line:      uint32(sub.Range.End.Line),
-									Character: uint32(sub.Range.End.Column),
-								},
-							},
-						}
-					}
-				}
+// findGDShaderDefinition finds the declaration of the shader identifier,
+// struct type name or struct field at the given position. Built-in
+// variables, functions and constants have no declaration to jump to.
+func findGDShaderDefinition(ast *gdshader.ShaderDocument, analyzer *gdshader.Analyzer, uri string, line, col int) *protocol.Location {
+	node := ast.FindNodeAt(gdshader.Position{Line: line, Column: col})
+	if spec, ok := node.(*gdshader.TypeSpec); ok {
+		for _, st := range ast.Structs {
+			if st.Name == spec.Name {
+				return &protocol.Location{URI: uri, Range: toProtocolShaderRange(st.NameRange)}
 			}
 		}
+		return nil
+	}
+	if node == nil {
+		return nil
+	}
 
-	case *parser.ArrayValue:
-		for _, elem := range val.Values {
-			if loc := s.findDefinitionInValue(elem, ast, uri, line, col); loc != nil {
-				return loc
-			}
+	if member, ok := node.(*gdshader.MemberExpr); ok {
+		if _, field := shaderStructField(ast, analyzer, member); field != nil {
+			return &protocol.Location{URI: uri, Range: toProtocolShaderRange(field.NameRange)}
 		}
+		return nil
+	}
+	sym := analyzer.SymbolOf(node)
+	if sym == nil || sym.Kind == gdshader.SymbolBuiltinVariable {
+		return nil
+	}
+	return &protocol.Location{URI: uri, Range: toProtocolShaderRange(sym.NameRange)}
+}
 
-	case *parser.DictValue:
-		for _, entry := range val.Entries {
-			if loc := s.findDefinitionInValue(entry.Value, ast, uri, line, col); loc != nil {
-				return loc
+// toProtocolShaderRange converts a GDShader range to an LSP range.
+func toProtocolShaderRange(r gdshader.Range) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(r.Start.Line), Character: uint32(r.Start.Column)},
+		End:   protocol.Position{Line: uint32(r.End.Line), Character: uint32(r.End.Column)},
+	}
+}
+
+// resourceRefAt returns the ExtResource("...") or SubResource("...") at
+// the given position, in any section, or nil when there is none.
+func resourceRefAt(doc *analysis.Document, line, col int) *parser.ResourceRef {
+	ref, _ := analysis.ElementAt[*parser.ResourceRef](doc.Positions, line, col)
+	return ref
+}
+
+// resourceDeclarationLocation returns the location of the [ext_resource]
+// or [sub_resource] header declaring the ID of a reference, or nil when the
+// file declares none.
+func resourceDeclarationLocation(uri string, ast *parser.Document, ref *parser.ResourceRef) *protocol.Location {
+	switch ref.RefType {
+	case "ExtResource":
+		for _, ext := range ast.ExtResources {
+			if ext.ID == ref.ID {
+				return &protocol.Location{URI: uri, Range: toProtocolRange(ext.Range)}
 			}
 		}
-
-	case *parser.TypedValue:
-		for _, arg := range val.Arguments {
-			if loc := s.findDefinitionInValue(arg, ast, uri, line, col); loc != nil {
-				return loc
+	case "SubResource":
+		for _, sub := range ast.SubResources {
+			if sub.ID == ref.ID {
+				return &protocol.Location{URI: uri, Range: toProtocolRange(sub.HeaderRange)}
 			}
 		}
 	}
-
 	return nil
 }
 
-// findNodeByPath finds a node by its path and returns its location.
-func (s *Server) findNodeByPath(ast *parser.Document, path, uri string) *protocol.Location {
-	// Build node path map
-	pathToNode := make(map[string]*parser.Node)
-	for _, node := range ast.Nodes {
-		var nodePath string
-		switch node.Parent {
-		case "":
-			nodePath = ""
-		case ".":
-			nodePath = node.Name
-		default:
-			nodePath = node.Parent + "/" + node.Name
+// maxInstanceDepth bounds how many nested instanced scenes a node path is
+// followed through, which also stops scenes that instance each other.
+const maxInstanceDepth = 16
+
+// resolveNodePath resolves a node path from base. Names past an instanced
+// node are looked up in the instanced scene, so paths into its children,
+// including "%Unique" names and the parents of editable-children overrides,
+// reach the node in the scene that declares it. It returns the URI of that
+// scene and the node, or a nil node when the path does not resolve.
+func (s *Server) resolveNodePath(uri string, ast *parser.Document, tree *analysis.SceneTree, base *analysis.SceneNode, path string) (string, *analysis.SceneNode) {
+	for range maxInstanceDepth {
+		node, rest := tree.ResolvePrefix(base, path)
+		if node == nil || rest == "" {
+			return uri, node
+		}
+
+		// Overrides of editable children sit under the instance in this
+		// scene, so continue from the instance they belong to
+		for node != nil && node.Node.Instance == nil {
+			rest = node.Node.Name + "/" + rest
+			node = node.Parent
+		}
+		if node == nil {
+			return uri, nil
 		}
-		pathToNode[nodePath] = node
+
+		sceneURI, scene := s.instancedScene(uri, ast, node.Node)
+		if scene == nil {
+			return uri, nil
+		}
+		uri, ast = sceneURI, scene
+		tree = analysis.NewSceneTree(scene)
+		base, path = tree.Root, rest
 	}
+	return uri, nil
+}
 
-	// Find the target node
-	if node, ok := pathToNode[path]; ok {
-		return &protocol.Location{
-			URI: uri,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(node.Range.Start.Line),
-					Character: uint32(node.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(node.Range.End.Line),
-					Character: uint32(node.Range.End.Column),
-				},
-			},
+// instancedScene returns the URI and parsed document of the scene a node
+// instances, or a nil document when the node is not an instance or the
+// scene cannot be read.
+func (s *Server) instancedScene(uri string, ast *parser.Document, node *parser.Node) (string, *parser.Document) {
+	ref, ok := node.Instance.(*parser.ResourceRef)
+	if !ok || ref.RefType != "ExtResource" {
+		return "", nil
+	}
+
+	for _, ext := range ast.ExtResources {
+		if ext.ID != ref.ID {
+			continue
+		}
+		loc := s.resolveResourcePath(s.extResourcePath(uri, ext), uri)
+		if loc == nil {
+			return "", nil
 		}
+		return loc.URI, s.loadScene(loc.URI)
 	}
+	return "", nil
+}
 
-	return nil
+// loadScene returns the parsed scene at uri, preferring the open document
+// and otherwise reading it from disk with the same guards as the workspace
+// scanner.
+func (s *Server) loadScene(uri string) *parser.Document {
+	if analysis.GetDocumentType(uri) != analysis.DocumentTypeTSCN {
+		return nil
+	}
+	if doc := s.workspace.GetDocument(uri); doc != nil {
+		return doc.TSCNAST
+	}
+
+	path := uriToPath(uri)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > s.currentSettings().MaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || analysis.IsBinaryContent(content) {
+		return nil
+	}
+	return s.workspace.ParseDocument(uri, string(content)).TSCNAST
+}
+
+// nodePathPrefixAt returns the part of a node path up to the end of the
+// name under the cursor, or the whole path when the cursor is on "." or
+// "..", a separator, or the property subpath.
+func nodePathPrefixAt(np *nodePathString, line, col int) string {
+	if line != np.rng.Start.Line {
+		return np.path
+	}
+	offset := col - np.rng.Start.Column - 1 // Skip the opening quote
+	if offset < 0 || offset >= len(np.path) || np.path[offset] == '/' {
+		return np.path
+	}
+
+	if strings.Contains(np.path[:offset], ":") {
+		return np.path // On the property subpath
+	}
+
+	end := len(np.path)
+	if i := strings.IndexAny(np.path[offset:], "/:"); i >= 0 {
+		end = offset + i
+	}
+	start := strings.LastIndexByte(np.path[:offset], '/') + 1
+	if name := np.path[start:end]; name == "." || name == ".." {
+		return np.path
+	}
+	return np.path[:end]
+}
+
+// nodeLocation returns the location of a node's [node] section.
+func nodeLocation(uri string, sn *analysis.SceneNode) *protocol.Location {
+	return &protocol.Location{
+		URI:   uri,
+		Range: toProtocolRange(sn.Node.Range),
+	}
+}
+
+// extResourcePath returns the path an ext_resource of the document at uri
+// loads. Like Godot, it prefers the resource its uid maps to, falling back
+// to the path when the uid is unknown.
+func (s *Server) extResourcePath(uri string, ext *parser.ExtResource) string {
+	if ext.UID != "" {
+		if path, ok := s.workspace.ResolveUID(uri, ext.UID); ok {
+			return path
+		}
+	}
+	return ext.Path
 }
 
 // resolveResourcePath resolves a resource path to an actual file URI.
@@ -234,17 +361,13 @@ func (s *Server) resolveResourcePath(resPath, currentURI string) *protocol.Locat
 	}
 }
 
-// findProjectRoot finds the Godot project root by looking for project.godot file.
+// findProjectRoot finds the root of the Godot project a file belongs to,
+// which res:// paths are resolved against.
 func (s *Server) findProjectRoot(currentURI string) string {
-	// First, try to use workspace folders
-	for _, folder := range s.workspace.GetFolders() {
+	// First, try the workspace folder containing the file
+	if folder := s.workspace.FolderOf(currentURI); folder != "" {
 		folderPath := uriToPath(folder)
-		if folderPath == "" {
-			continue
-		}
-
-		// Check if project.godot exists in this folder
-		if fileExists(filepath.Join(folderPath, "project.godot")) {
+		if folderPath != "" && fileExists(filepath.Join(folderPath, "project.godot")) {
 			return folderPath
 		}
 	}
@@ -0,0 +1,436 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// positionOf returns the line and column of the first occurrence of marker.
+func positionOf(t *testing.T, content, marker string) (int, int) {
+	t.Helper()
+	offset := strings.Index(content, marker)
+	if offset < 0 {
+		t.Fatalf("marker %q not found", marker)
+	}
+	line := strings.Count(content[:offset], "\n")
+	col := offset - (strings.LastIndexByte(content[:offset], '\n') + 1)
+	return line, col
+}
+
+func TestDefinitionResolvesNodePaths(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/definition.tscn"
+	doc := s.workspace.OpenDocument(uri, highlightScene)
+	lines := strings.Split(highlightScene, "\n")
+
+	tests := []struct {
+		marker string
+		node   string
+	}{
+		{`Sprite")`, "Sprite"},          // Last segment of NodePath("../../Sprite")
+		{`../../Sprite`, "Sprite"},      // ".." resolves the whole path
+		{`..:position`, "Sprite"},       // Subpath is ignored
+		{`%Label`, "Label"},             // Unique name
+		{`Body/Sprite")`, "Body"},       // Segment under the cursor
+		{`Timer" to=`, "Timer"},         // Connection from
+		{`Body/Sprite" method`, "Body"}, // Connection to
+		{`Sprite" method`, "Sprite"},    // Connection to, last segment
+		{`Body/Sprite"]`, "Body"},       // parent= attribute
+		{`type="Camera2D"`, "Sprite"},   // Anywhere else in a child node
+	}
+
+	for _, tt := range tests {
+		line, col := positionOf(t, highlightScene, tt.marker)
+		loc := s.findDefinition(doc, uri, line, col)
+		if loc == nil {
+			t.Errorf("%s: expected a definition", tt.marker)
+			continue
+		}
+		header := lines[loc.Range.Start.Line]
+		if !strings.Contains(header, `name="`+tt.node+`"`) {
+			t.Errorf("%s: expected [node name=%q], got %q", tt.marker, tt.node, header)
+		}
+	}
+
+	line, col := positionOf(t, highlightScene, "../../Sprite")
+	hover := s.findTSCNHoverInfo(doc, line, col)
+	if !strings.Contains(hover, "**Resolves to:** `Body/Sprite` (`Sprite2D`)") {
+		t.Errorf("unexpected NodePath hover:\n%s", hover)
+	}
+}
+
+func TestDefinitionUnresolvedNodePath(t *testing.T) {
+	content := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node\"]\ntarget = NodePath(\"Missing/Child\")\n"
+	s := NewServer("test", "test")
+	uri := "file:///tmp/unresolved.tscn"
+	doc := s.workspace.OpenDocument(uri, content)
+
+	line, col := positionOf(t, content, "Child")
+	if loc := s.findDefinition(doc, uri, line, col); loc != nil {
+		t.Errorf("expected no definition for an unresolved path, got %+v", loc)
+	}
+	if hover := s.findTSCNHoverInfo(doc, line, col); !strings.Contains(hover, "Does not resolve") {
+		t.Errorf("unexpected hover:\n%s", hover)
+	}
+}
+
+func TestDefinitionOfResourceReferences(t *testing.T) {
+	scene := `[gd_scene load_steps=3 format=3]
+
+[ext_resource type="Texture2D" path="res://icon.png" id="1_tex"]
+
+[sub_resource type="CircleShape2D" id="CircleShape2D_a"]
+radius = 8.0
+
+[node name="Root" type="Node2D"]
+texture = ExtResource("1_tex")
+shapes = [SubResource("CircleShape2D_a")]
+meta = {"shape": SubResource("CircleShape2D_a")}
+`
+	material := `[gd_resource type="ShaderMaterial" load_steps=2 format=3]
+
+[ext_resource type="Shader" path="res://water.gdshader" id="1_sh"]
+
+[resource]
+shader = ExtResource("1_sh")
+`
+	s := NewServer("test", "test")
+
+	tests := []struct {
+		content string
+		marker  string
+		line    uint32 // Of the declaring header
+	}{
+		{scene, `ExtResource("1_tex")`, 2},
+		{scene, `SubResource("CircleShape2D_a")]`, 4}, // In an array
+		{scene, `SubResource("CircleShape2D_a")}`, 4}, // In a dictionary
+		{material, `ExtResource("1_sh")`, 2},          // In the [resource] section
+	}
+	for i, tt := range tests {
+		uri := fmt.Sprintf("file:///tmp/refs%d.tscn", i)
+		doc := s.workspace.OpenDocument(uri, tt.content)
+		line, col := positionOf(t, tt.content, tt.marker)
+		loc := s.findDefinition(doc, uri, line, col+len("SubResource(\""))
+		if loc == nil {
+			t.Errorf("%s: expected a definition", tt.marker)
+			continue
+		}
+		// Only the header, not the properties of a sub_resource
+		if loc.URI != uri || loc.Range.Start.Line != tt.line || loc.Range.End.Line != tt.line {
+			t.Errorf("%s: expected line %d, got %+v", tt.marker, tt.line, loc.Range)
+		}
+	}
+
+	undeclared := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node\"]\nshape = SubResource(\"Missing\")\n"
+	uri := "file:///tmp/undeclared.tscn"
+	doc := s.workspace.OpenDocument(uri, undeclared)
+	line, col := positionOf(t, undeclared, "Missing")
+	if loc := s.findDefinition(doc, uri, line, col); loc != nil {
+		t.Errorf("expected no definition for an undeclared ID, got %+v", loc)
+	}
+}
+
+func TestDefinitionAcrossInstancedScenes(t *testing.T) {
+	dir := t.TempDir()
+	player := `[gd_scene format=3]
+
+[node name="Player" type="CharacterBody2D"]
+
+[node name="Body" type="Node2D" parent="."]
+
+[node name="Shape" type="CollisionShape2D" parent="Body"]
+
+[node name="Health" type="Node" parent="."]
+unique_name_in_owner = true
+`
+	main := `[gd_scene load_steps=2 format=3]
+
+[ext_resource type="PackedScene" path="res://player.tscn" id="1_p"]
+
+[node name="Main" type="Node"]
+target = NodePath("Player/%Health")
+
+[node name="Player" parent="." instance=ExtResource("1_p")]
+
+[node name="Body" parent="Player" index="0"]
+visible = false
+
+[node name="Extra" type="Node" parent="Player/Body/Shape"]
+
+[connection signal="ready" from="Player/Body" to="." method="_on_ready"]
+`
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"player.tscn":   player,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	uri := pathToURI(filepath.Join(dir, "main.tscn"))
+	playerURI := pathToURI(filepath.Join(dir, "player.tscn"))
+	doc := s.workspace.OpenDocument(uri, main)
+	playerLines := strings.Split(player, "\n")
+	mainLines := strings.Split(main, "\n")
+
+	tests := []struct {
+		marker string
+		uri    string
+		node   string
+	}{
+		{`ExtResource("1_p")]`, playerURI, "Player"}, // Instance opens the scene root
+		{`%Health`, playerURI, "Health"},             // Unique name inside the instance
+		{`Shape"]`, playerURI, "Shape"},              // Child of an editable-children override
+		{`Body" to=`, uri, "Body"},                   // The override itself
+	}
+
+	for _, tt := range tests {
+		line, col := positionOf(t, main, tt.marker)
+		loc := s.findDefinition(doc, uri, line, col)
+		if loc == nil {
+			t.Errorf("%s: expected a definition", tt.marker)
+			continue
+		}
+		if loc.URI != tt.uri {
+			t.Errorf("%s: expected %s, got %s", tt.marker, tt.uri, loc.URI)
+			continue
+		}
+		lines := mainLines
+		if loc.URI == playerURI {
+			lines = playerLines
+		}
+		if header := lines[loc.Range.Start.Line]; !strings.Contains(header, `name="`+tt.node+`"`) {
+			t.Errorf("%s: expected [node name=%q], got %q", tt.marker, tt.node, header)
+		}
+	}
+
+	line, col := positionOf(t, main, "%Health")
+	hover := s.findTSCNHoverInfo(doc, line, col)
+	if !strings.Contains(hover, "`Health` (`Node`) in instanced scene `player.tscn`") {
+		t.Errorf("unexpected hover:\n%s", hover)
+	}
+}
+
+func TestDefinitionInShader(t *testing.T) {
+	content := `shader_type spatial;
+
+struct Ripple {
+	float height;
+};
+
+uniform float speed;
+varying vec3 world_pos;
+const float SCALE = 2.0;
+
+float wave(float phase) {
+	return sin(phase * SCALE);
+}
+
+void fragment() {
+	Ripple r = Ripple(wave(TIME * speed));
+	float h = r.height;
+	ALBEDO = world_pos * h;
+}
+`
+	s := NewServer("test", "test")
+	uri := "file:///tmp/ripple.gdshader"
+	doc := s.workspace.OpenDocument(uri, content)
+	lines := strings.Split(content, "\n")
+
+	tests := []struct {
+		marker string
+		line   int
+		name   string
+	}{
+		{"phase * SCALE", 10, "phase"},     // Parameter
+		{"SCALE);", 8, "SCALE"},            // Constant
+		{"Ripple r", 2, "Ripple"},          // Struct type
+		{"Ripple(wave", 2, "Ripple"},       // Struct constructor
+		{"wave(TIME", 10, "wave"},          // Function
+		{"speed))", 6, "speed"},            // Uniform
+		{"r.height", 15, "r"},              // Local variable
+		{"world_pos * h", 7, "world_pos"},  // Varying
+		{"h;\n}", 16, "h"},                 // Local used after its declaration
+		{"height;\n\tALBEDO", 3, "height"}, // Struct field
+	}
+	for _, tt := range tests {
+		line, col := positionOf(t, content, tt.marker)
+		loc := findGDShaderDefinition(doc.ShaderAST, s.analyzedShader(doc), uri, line, col)
+		if loc == nil {
+			t.Errorf("%q: expected a definition", tt.marker)
+			continue
+		}
+		start, end := loc.Range.Start, loc.Range.End
+		if int(start.Line) != tt.line || lines[start.Line][start.Character:end.Character] != tt.name {
+			t.Errorf("%q: expected %s on line %d, got %+v", tt.marker, tt.name, tt.line, loc.Range)
+		}
+	}
+
+	for _, marker := range []string{"sin(", "TIME", "ALBEDO"} {
+		line, col := positionOf(t, content, marker)
+		if loc := findGDShaderDefinition(doc.ShaderAST, s.analyzedShader(doc), uri, line, col); loc != nil {
+			t.Errorf("%q: expected no definition for a built-in, got %+v", marker, loc)
+		}
+	}
+}
+
+func TestTypeDefinition(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"icon.png":      "",
+		"item.gd":       "extends Resource\nclass_name ItemData\n",
+		"player.gd":     "extends CharacterBody2D\n",
+		"door.tscn":     "[gd_scene format=3]\n\n[node name=\"Door\" type=\"Area2D\"]\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="Texture2D" path="res://icon.png" id="1_icon"]
+[ext_resource type="Script" path="res://item.gd" id="2_item"]
+[ext_resource type="Script" path="res://player.gd" id="3_player"]
+[ext_resource type="PackedScene" path="res://door.tscn" id="4_door"]
+
+[sub_resource type="Resource" id="Item_1"]
+script = ExtResource("2_item")
+
+[node name="Level" type="Node2D"]
+
+[node name="Player" type="CharacterBody2D" parent="."]
+script = ExtResource("3_player")
+texture = ExtResource("1_icon")
+item = SubResource("Item_1")
+
+[node name="Door" parent="." instance=ExtResource("4_door")]
+
+[node name="Timer" type="Timer" parent="."]
+
+[connection signal="timeout" from="Timer" to="Player" method="_on_timeout"]
+`
+	s := NewServer("test", "test")
+	uri := pathToURI(filepath.Join(dir, "level.tscn"))
+	doc := s.workspace.OpenDocument(uri, content)
+
+	tests := []struct {
+		marker string
+		offset int
+		want   string
+	}{
+		{`ExtResource("1_icon")`, 2, "icon.png:0"},            // The texture file, not its ext_resource
+		{`SubResource("Item_1")`, 2, "item.gd:1"},             // The script of the sub-resource, at its class
+		{`[node name="Player"`, 2, "player.gd:0"},             // The script of the node
+		{`to="Player"`, 5, "player.gd:0"},                     // Through a node path
+		{`[node name="Door"`, 2, "door.tscn:2"},               // The root of the instanced scene
+		{`path="res://door.tscn"`, 8, "door.tscn:0"},          // An ext_resource path
+		{`instance=ExtResource("4_door")`, 12, "door.tscn:0"}, // The instanced file
+	}
+	for _, tt := range tests {
+		line, col := positionOf(t, content, tt.marker)
+		loc := s.findTypeDefinition(doc, uri, line, col+tt.offset)
+		if loc == nil {
+			t.Errorf("%q: expected a type definition", tt.marker)
+			continue
+		}
+		if got := fmt.Sprintf("%s:%d", filepath.Base(uriToPath(loc.URI)), loc.Range.Start.Line); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.marker, tt.want, got)
+		}
+	}
+
+	// Definition still goes to the declaration of the resource
+	line, col := positionOf(t, content, `ExtResource("1_icon")`)
+	if loc := s.findDefinition(doc, uri, line, col+2); loc == nil || loc.URI != uri || loc.Range.Start.Line != 2 {
+		t.Errorf("expected definition to go to the ext_resource, got %+v", loc)
+	}
+
+	line, col = positionOf(t, content, `[node name="Timer"`)
+	if loc := s.findTypeDefinition(doc, uri, line, col+2); loc != nil {
+		t.Errorf("expected no type definition for a node without a script, got %+v", loc)
+	}
+}
+
+func TestImplementation(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"lock.tscn": `[gd_scene format=3]
+
+[node name="Lock" type="Node2D"]
+
+[node name="Key" type="Sprite2D" parent="."]
+visible = false
+`,
+		"door.tscn": `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://lock.tscn" id="1_lock"]
+
+[node name="Door" type="Area2D"]
+monitoring = false
+
+[node name="Sprite" type="Sprite2D" parent="."]
+
+[node name="Lock" parent="." instance=ExtResource("1_lock")]
+`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://door.tscn" id="1_door"]
+
+[node name="Level" type="Node2D"]
+
+[node name="Door" parent="." instance=ExtResource("1_door")]
+monitoring = true
+
+[node name="Sprite" parent="Door"]
+modulate = Color(1, 0, 0, 1)
+
+[node name="Key" parent="Door/Lock"]
+visible = true
+
+[node name="Hud" type="CanvasLayer" parent="."]
+`
+	s := NewServer("test", "test")
+	uri := pathToURI(filepath.Join(dir, "level.tscn"))
+	doc := s.workspace.OpenDocument(uri, content)
+
+	tests := []struct {
+		marker string
+		want   string
+	}{
+		{`monitoring = true`, "door.tscn:5"},   // The property of the instanced root
+		{`[node name="Door"`, "door.tscn:4"},   // The instanced root
+		{`[node name="Sprite"`, "door.tscn:7"}, // An editable child
+		{`modulate = `, "door.tscn:7"},         // Not set there: the node
+		{`visible = true`, "lock.tscn:5"},      // Through a nested instance
+		{`[node name="Key"`, "lock.tscn:4"},
+	}
+	for _, tt := range tests {
+		line, col := positionOf(t, content, tt.marker)
+		loc := s.findImplementation(doc, uri, line, col+2)
+		if loc == nil {
+			t.Errorf("%q: expected an implementation", tt.marker)
+			continue
+		}
+		if got := fmt.Sprintf("%s:%d", filepath.Base(uriToPath(loc.URI)), loc.Range.Start.Line); got != tt.want {
+			t.Errorf("%q: expected %s, got %s", tt.marker, tt.want, got)
+		}
+	}
+
+	line, col := positionOf(t, content, `[node name="Hud"`)
+	if loc := s.findImplementation(doc, uri, line, col+2); loc != nil {
+		t.Errorf("expected nothing overridden by a typed node, got %+v", loc)
+	}
+}
@@ -1,31 +1,147 @@
 package lsp
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/config"
+	"github.com/andresperezl/gdls/internal/godotdoc"
 	"github.com/andresperezl/gdls/internal/parser"
+	"github.com/andresperezl/gdls/internal/telemetry"
 )
 
-// publishDiagnostics publishes diagnostics for a document.
+// publishDiagnostics publishes diagnostics for a document, unless the
+// client pulls them.
 func (s *Server) publishDiagnostics(ctx *glsp.Context, uri string, doc *analysis.Document) {
-	if doc == nil {
+	if doc == nil || s.pullDiagnostics {
 		return
 	}
 
+	_, span := telemetry.Start(s.requestContext(ctx), "diagnostics", attribute.String("document.uri", uri))
+	diagnostics := s.computeDiagnostics(doc)
+	span.SetAttributes(attribute.Int("diagnostics.count", len(diagnostics)))
+	span.End()
+	if diagnostics == nil {
+		return
+	}
+
+	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+// Check parses the given content as the document at uri and returns its
+// diagnostics without publishing them. It is used by the headless CLI.
+func (s *Server) Check(uri, content string) []protocol.Diagnostic {
+	doc := s.workspace.OpenDocument(uri, content)
+	defer s.workspace.CloseDocument(uri)
+
+	diagnostics := s.computeDiagnostics(doc)
+	if diagnostics == nil {
+		return []protocol.Diagnostic{}
+	}
+	return diagnostics
+}
+
+// Diagnostic codes identify each kind of diagnostic, e.g. to override its
+// severity with the diagnosticSeverity setting.
+const (
+	codeParseError        = "parse-error"
+	codeUnsupportedFormat = "unsupported-format"
+	codeLoadSteps         = "load-steps"
+	codeMissingType       = "missing-type"
+	codeUndefinedResource = "undefined-resource"
+	codeUnknownUID        = "unknown-uid"
+	codeUIDMismatch       = "uid-mismatch"
+	codeResourceType      = "resource-type"
+	codeUnknownType       = "unknown-type"
+	codeMissingFile       = "missing-file"
+	codeMissingParent     = "missing-parent"
+	codeDuplicateID       = "duplicate-id"
+	codeRegeneratedID     = "regenerated-id"
+	codeDuplicateUID      = "duplicate-uid"
+	codeUnknownProperty   = "unknown-property"
+	codeMissingMethod     = "missing-method"
+	codeValueType         = "value-type"
+	codeShaderError       = "shader-error"   // Shader errors without a code of their own
+	codeShaderWarning     = "shader-warning" // Shader warnings without a code of their own
+	codeDuplicateKey      = "duplicate-key"
+	codeDuplicateSection  = "duplicate-section"
+	codeInvalidSetting    = "invalid-setting"
+	codeMissingKey        = "missing-key"
+	codeUnknownSection    = "unknown-section"
+	codeExportedScene     = "exported-scene"
+	codeAnimationTrack    = "animation-track"
+	codeEngine            = "engine" // Reported by the Godot engine, see compile_check.go
+)
+
+// computeDiagnostics returns the diagnostics for a document, or nil if the
+// document type has none. Severity overrides and gdls-ignore comments are
+// applied.
+func (s *Server) computeDiagnostics(doc *analysis.Document) []protocol.Diagnostic {
+	var diagnostics []protocol.Diagnostic
 	switch doc.Type {
 	case analysis.DocumentTypeTSCN:
-		s.publishTSCNDiagnostics(ctx, uri, doc)
+		diagnostics = s.tscnDiagnostics(doc)
 	case analysis.DocumentTypeGDShader:
-		s.publishGDShaderDiagnostics(ctx, uri, doc)
+		diagnostics = s.gdshaderDiagnostics(doc)
+	case analysis.DocumentTypeConfig:
+		diagnostics = s.configDiagnostics(doc)
+	case analysis.DocumentTypeGLSL:
+		diagnostics = s.glslDiagnostics(doc)
+	}
+	if diagnostics == nil {
+		return nil
 	}
+	diagnostics = append(diagnostics, s.engineResults(doc)...)
+	diagnostics = applySeverities(diagnostics, s.currentSettings().Severities)
+	return suppressDiagnostics(doc, diagnostics)
 }
 
-// publishTSCNDiagnostics publishes diagnostics for a TSCN document.
-func (s *Server) publishTSCNDiagnostics(ctx *glsp.Context, uri string, doc *analysis.Document) {
+// applySeverities overrides the severity of diagnostics by their code,
+// dropping the ones turned off.
+func applySeverities(diagnostics []protocol.Diagnostic, overrides map[string]config.Severity) []protocol.Diagnostic {
+	if len(overrides) == 0 {
+		return diagnostics
+	}
+
+	kept := diagnostics[:0]
+	for _, d := range diagnostics {
+		var code string
+		if d.Code != nil {
+			code, _ = d.Code.Value.(string)
+		}
+		switch overrides[code] {
+		case config.SeverityOff:
+			continue
+		case config.SeverityError:
+			d.Severity = severityPtr(protocol.DiagnosticSeverityError)
+		case config.SeverityWarning:
+			d.Severity = severityPtr(protocol.DiagnosticSeverityWarning)
+		case config.SeverityInformation:
+			d.Severity = severityPtr(protocol.DiagnosticSeverityInformation)
+		case config.SeverityHint:
+			d.Severity = severityPtr(protocol.DiagnosticSeverityHint)
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// tscnDiagnostics computes diagnostics for a TSCN document.
+func (s *Server) tscnDiagnostics(doc *analysis.Document) []protocol.Diagnostic {
 	if doc.TSCNAST == nil {
-		return
+		return nil
 	}
 
 	diagnostics := []protocol.Diagnostic{}
@@ -45,42 +161,123 @@ func (s *Server) publishTSCNDiagnostics(ctx *glsp.Context, uri string, doc *anal
 			},
 			Severity: severityPtr(protocol.DiagnosticSeverityError),
 			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeParseError),
 			Message:  err.Message,
 		})
 	}
 
-	// Check format version
-	if doc.TSCNAST.Descriptor != nil && doc.TSCNAST.Descriptor.Format != 3 {
-		diagnostics = append(diagnostics, protocol.Diagnostic{
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(doc.TSCNAST.Descriptor.Range.Start.Line),
-					Character: uint32(doc.TSCNAST.Descriptor.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(doc.TSCNAST.Descriptor.Range.End.Line),
-					Character: uint32(doc.TSCNAST.Descriptor.Range.End.Column),
-				},
-			},
-			Severity: severityPtr(protocol.DiagnosticSeverityError),
-			Source:   strPtr("gdls"),
-			Message:  "Only format=3 (Godot 4.x) is supported",
-		})
-	}
+	// Scenes exported from other tools are relaxed: Godot imports them
+	// without checking their header, and those written for Godot 3 use
+	// its class names
+	exported := exportedScene(doc.URI)
+	legacy := exported && doc.TSCNAST.Descriptor != nil && doc.TSCNAST.Descriptor.Format < 3
+
+	// Check the [gd_scene] or [gd_resource] header
+	diagnostics = append(diagnostics, checkHeader(doc.TSCNAST, exported)...)
 
 	// Check for missing resource references
 	diagnostics = append(diagnostics, s.checkResourceReferences(doc)...)
 
+	// Check for uids that do not match any resource
+	diagnostics = append(diagnostics, s.checkUIDReferences(doc)...)
+
+	// Check for res:// paths of files that do not exist
+	diagnostics = append(diagnostics, s.checkMissingFiles(doc)...)
+
+	// Check that external resources declare the type of the file they load
+	if !legacy {
+		diagnostics = append(diagnostics, s.checkTypes(doc)...)
+		diagnostics = append(diagnostics, s.checkExtResourceTypes(doc)...)
+	}
+
 	// Check for missing parent nodes
 	diagnostics = append(diagnostics, s.checkParentReferences(doc)...)
 
+	// Check that connections call methods their target's script declares
+	diagnostics = append(diagnostics, s.checkConnectionMethods(doc)...)
+
 	// Check for duplicate resource IDs
 	diagnostics = append(diagnostics, s.checkDuplicateIDs(doc)...)
 
-	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
-		URI:         uri,
-		Diagnostics: diagnostics,
-	})
+	if !legacy {
+		// Check for properties the declared types do not have
+		diagnostics = append(diagnostics, s.checkUnknownProperties(doc)...)
+
+		// Check that values match the types of their properties
+		diagnostics = append(diagnostics, s.checkValueTypes(doc)...)
+
+		// Check the tracks of animations
+		diagnostics = append(diagnostics, s.checkAnimationTracks(doc)...)
+	}
+
+	return diagnostics
+}
+
+// exportedScene reports whether uri is a scene exported from another tool,
+// such as the .escn files of the Blender exporter, which Godot imports like
+// a model rather than opening it for editing.
+func exportedScene(uri string) bool {
+	return strings.EqualFold(filepath.Ext(uriToPath(uri)), ".escn")
+}
+
+// supportedFormats are the format= values of Godot 4 files: 3, and 4 since
+// Godot 4.3 for files using newer value types such as PackedVector4Array.
+var supportedFormats = map[int]bool{3: true, 4: true}
+
+// checkHeader checks the file descriptor: its format, that load_steps
+// counts the resources of the file, and that resources declare their type.
+// The header of an exported scene only gets a hint that it is imported, as
+// exporters write the format and load_steps of the Godot they target.
+func checkHeader(ast *parser.Document, exported bool) []protocol.Diagnostic {
+	gd := ast.Descriptor
+	if gd == nil {
+		return nil
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, code, msg string) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(r),
+			Severity: severityPtr(severity),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(code),
+			Message:  msg,
+		})
+	}
+
+	if exported {
+		add(gd.Range, protocol.DiagnosticSeverityHint, codeExportedScene,
+			"Exported scene: Godot imports it as a new scene, so edits are lost when it is exported again; inherit from it or change the import settings instead")
+		return diagnostics
+	}
+
+	if !supportedFormats[gd.Format] {
+		add(gd.FormatRange, protocol.DiagnosticSeverityError, codeUnsupportedFormat,
+			fmt.Sprintf("Unsupported format=%d, only format=3 and format=4 (Godot 4.x) are supported", gd.Format))
+	}
+
+	// Godot omits load_steps when there is nothing to load, so only a
+	// declared value is checked
+	if gd.LoadSteps != nil {
+		if expected := loadSteps(ast); *gd.LoadSteps != expected {
+			add(gd.LoadStepsRange, protocol.DiagnosticSeverityWarning, codeLoadSteps,
+				fmt.Sprintf("load_steps=%d does not match the %s of the file, expected load_steps=%d",
+					*gd.LoadSteps, plural(expected-1, "resource", "resources"), expected))
+		}
+	}
+
+	if gd.Type == "gd_resource" && gd.ResourceType == "" {
+		add(gd.Range, protocol.DiagnosticSeverityError, codeMissingType,
+			"[gd_resource] must declare the type of the resource, e.g. type=\"Resource\"")
+	}
+
+	return diagnostics
+}
+
+// loadSteps returns the load_steps Godot expects: one step per
+// ext_resource and sub_resource, plus one for the scene or resource itself.
+func loadSteps(ast *parser.Document) int {
+	return len(ast.ExtResources) + len(ast.SubResources) + 1
 }
 
 // checkResourceReferences checks for references to non-existent resources.
@@ -122,6 +319,7 @@ func (s *Server) checkResourceReferences(doc *analysis.Document) []protocol.Diag
 					},
 					Severity: severityPtr(protocol.DiagnosticSeverityError),
 					Source:   strPtr("gdls"),
+					Code:     diagnosticCode(codeUndefinedResource),
 					Message:  "Reference to undefined resource: " + ref.ID,
 				})
 			}
@@ -148,6 +346,274 @@ func (s *Server) checkResourceReferences(doc *analysis.Document) []protocol.Diag
 	return diagnostics
 }
 
+// checkUIDReferences checks the uids of external resources against the
+// workspace uid index. Godot loads a resource by uid when it can and falls
+// back to the path otherwise, so an unknown uid is an error only when there
+// is no path. Without an index nothing can be told apart, so nothing is
+// reported.
+func (s *Server) checkUIDReferences(doc *analysis.Document) []protocol.Diagnostic {
+	if !s.workspace.HasUIDIndex(doc.URI) {
+		return nil
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, code, msg string, related ...protocol.DiagnosticRelatedInformation) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:              toProtocolRange(r),
+			Severity:           severityPtr(severity),
+			Source:             strPtr("gdls"),
+			Code:               diagnosticCode(code),
+			Message:            msg,
+			RelatedInformation: related,
+		})
+	}
+
+	for _, ext := range doc.TSCNAST.ExtResources {
+		if ext.UID == "" {
+			continue
+		}
+		path, ok := s.workspace.ResolveUID(doc.URI, ext.UID)
+		switch {
+		case !ok && ext.Path == "":
+			add(ext.UIDRange, protocol.DiagnosticSeverityError, codeUnknownUID,
+				fmt.Sprintf("Unknown uid %s and no path to fall back to", ext.UID))
+		case !ok:
+			add(ext.UIDRange, protocol.DiagnosticSeverityWarning, codeUnknownUID,
+				fmt.Sprintf("Unknown uid %s; Godot falls back to %s", ext.UID, ext.Path))
+		case ext.Path != "" && path != ext.Path:
+			var related []protocol.DiagnosticRelatedInformation
+			if location := s.resolveResourcePath(path, doc.URI); location != nil {
+				related = append(related, protocol.DiagnosticRelatedInformation{Location: *location, Message: "File loaded by the uid"})
+			}
+			add(ext.PathRange, protocol.DiagnosticSeverityWarning, codeUIDMismatch,
+				fmt.Sprintf("uid %s belongs to %s; Godot loads that instead of this path", ext.UID, path), related...)
+		}
+	}
+
+	return diagnostics
+}
+
+// checkMissingFiles checks that the res:// paths of external resources exist
+// under the project root. A path is not checked when its uid resolves, as
+// Godot then loads the file the uid belongs to.
+func (s *Server) checkMissingFiles(doc *analysis.Document) []protocol.Diagnostic {
+	root := s.findProjectRoot(doc.URI)
+	if root == "" {
+		return nil
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+	for _, ext := range doc.TSCNAST.ExtResources {
+		if ext.UID != "" {
+			if _, ok := s.workspace.ResolveUID(doc.URI, ext.UID); ok {
+				continue
+			}
+		}
+		rel, ok := strings.CutPrefix(ext.Path, "res://")
+		if !ok || fileExists(filepath.Join(root, filepath.FromSlash(rel))) {
+			continue
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(ext.PathRange),
+			Severity: severityPtr(protocol.DiagnosticSeverityWarning),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeMissingFile),
+			Message:  "File not found: " + ext.Path,
+		})
+	}
+	return diagnostics
+}
+
+// classReference returns the bundled class reference when it documents the
+// targeted Godot version, or nil. Classes and properties are added, renamed
+// and removed between versions, so checks against a reference of another
+// version would report files that are valid for the target.
+func (s *Server) classReference() *godotdoc.Database {
+	if db := godotdoc.Default(); db.Documents(s.currentSettings().GodotVersion) {
+		return db
+	}
+	return nil
+}
+
+// checkExtResourceTypes checks the declared type of external resources
+// against the file they load. Godot fails to load a resource that is not of
+// the declared type or one of its subclasses. Script classes are checked
+// against the engine class they derive from. Files whose type cannot be
+// told, and types the class reference does not know, are not checked.
+func (s *Server) checkExtResourceTypes(doc *analysis.Document) []protocol.Diagnostic {
+	db := s.classReference()
+	if db == nil {
+		return nil
+	}
+	diagnostics := []protocol.Diagnostic{}
+	for _, ext := range doc.TSCNAST.ExtResources {
+		expected := ext.Type
+		if expected != "" && db.Class(expected) == nil {
+			expected = s.workspace.ScriptClassBase(doc.URI, expected)
+		}
+		if expected == "" || db.Class(expected) == nil {
+			continue
+		}
+		path := s.extResourcePath(doc.URI, ext)
+		location := s.resolveResourcePath(path, doc.URI)
+		if path == "" || location == nil {
+			continue
+		}
+		actual := s.resourceFileType(location.URI)
+		if actual == "" || db.Class(actual) == nil || slices.Contains(db.Inheritance(actual), expected) {
+			continue
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(ext.TypeRange),
+			Severity: severityPtr(protocol.DiagnosticSeverityError),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeResourceType),
+			Message:  fmt.Sprintf("%s is of type %s, not %s; Godot fails to load it", path, actual, ext.Type),
+			RelatedInformation: []protocol.DiagnosticRelatedInformation{
+				{Location: *location, Message: "Loaded file, of type " + actual},
+			},
+		})
+	}
+	return diagnostics
+}
+
+// checkConnectionMethods checks that the method of each connection is
+// declared by the GDScript or C# script of the node it connects to, by the
+// scripts that one extends, or by the engine class they derive from.
+// Targets without a script, scripts that cannot be read and engine classes
+// the class reference does not document are not checked.
+func (s *Server) checkConnectionMethods(doc *analysis.Document) []protocol.Diagnostic {
+	ast := doc.TSCNAST
+	if len(ast.Connections) == 0 {
+		return nil
+	}
+	tree := analysis.NewSceneTree(ast)
+	diagnostics := []protocol.Diagnostic{}
+	for _, conn := range ast.Connections {
+		if conn.Method == "" || tree.Root == nil {
+			continue
+		}
+		targetURI, target := s.resolveNodePath(doc.URI, ast, tree, tree.Root, conn.To)
+		if target == nil {
+			continue
+		}
+		targetAST := ast
+		if targetURI != doc.URI {
+			targetAST = s.loadScene(targetURI)
+		}
+		scriptURI := s.nodeScript(targetURI, targetAST, target.Node)
+		if scriptURI == "" {
+			continue
+		}
+		loc, native, complete := s.scriptMethod(scriptURI, conn.Method)
+		if loc != nil || !complete {
+			continue
+		}
+		if db := s.classReference(); db == nil || db.Class(native) == nil || db.HasMethod(native, conn.Method) {
+			continue
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(conn.MethodRange),
+			Severity: severityPtr(protocol.DiagnosticSeverityWarning),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeMissingMethod),
+			Message: fmt.Sprintf("%s declares no method %s, nor do the scripts it extends or %s; the signal fails to connect",
+				scriptName(scriptURI), conn.Method, native),
+		})
+	}
+	return diagnostics
+}
+
+// checkTypes checks the type= of nodes, sub_resources and ext_resources
+// against the classes of the class reference and the script classes of the
+// project. Only documents of a workspace folder are checked, as the script
+// classes of other files are not indexed, and not in projects with a
+// GDExtension, whose classes only the engine knows.
+func (s *Server) checkTypes(doc *analysis.Document) []protocol.Diagnostic {
+	folder := s.workspace.FolderOf(doc.URI)
+	if folder == "" {
+		return nil
+	}
+	for _, uri := range s.workspace.Files() {
+		if strings.HasSuffix(uri, analysis.GDExtensionFileExt) && s.workspace.FolderOf(uri) == folder {
+			return nil
+		}
+	}
+
+	db := s.classReference()
+	if db == nil {
+		return nil
+	}
+	scriptClasses := make(map[string]bool)
+	for _, class := range s.workspace.ScriptClasses(doc.URI) {
+		scriptClasses[class.Name] = true
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+	check := func(typeName string, r parser.Range) {
+		if typeName == "" || db.Class(typeName) != nil || scriptClasses[typeName] {
+			return
+		}
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(r),
+			Severity: severityPtr(protocol.DiagnosticSeverityWarning),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeUnknownType),
+			Message:  fmt.Sprintf("Unknown type %s; it is neither a Godot class nor a class_name of the project", typeName),
+		})
+	}
+
+	ast := doc.TSCNAST
+	for _, ext := range ast.ExtResources {
+		check(ext.Type, ext.TypeRange)
+	}
+	for _, sub := range ast.SubResources {
+		check(sub.Type, sub.TypeRange)
+	}
+	for _, node := range ast.Nodes {
+		check(node.Type, node.TypeRange)
+	}
+	return diagnostics
+}
+
+// resourceFileType returns the class of the resource Godot loads from the
+// file at uri, or "" when it cannot be told. Scenes, shaders and scripts are
+// known by their extension, resources by their header, and imported assets
+// by the type in their .import file.
+func (s *Server) resourceFileType(uri string) string {
+	switch strings.ToLower(filepath.Ext(uriToPath(uri))) {
+	case ".tscn", ".escn":
+		return "PackedScene"
+	case ".tres":
+		if ast := s.loadScene(uri); ast != nil && ast.Descriptor != nil {
+			return ast.Descriptor.ResourceType
+		}
+		return ""
+	case ".gdshader":
+		return "Shader"
+	case ".gdshaderinc":
+		return "ShaderInclude"
+	case ".gd":
+		return "GDScript"
+	case ".cs":
+		return "CSharpScript"
+	}
+
+	var cfg *parser.ConfigDocument
+	if doc := s.workspace.GetDocument(uri + analysis.ImportFileExt); doc != nil {
+		cfg = doc.ConfigAST
+	} else if content, err := os.ReadFile(uriToPath(uri) + analysis.ImportFileExt); err == nil {
+		cfg = parser.ParseConfig(string(content))
+	}
+	if cfg == nil || cfg.Section("remap") == nil {
+		return ""
+	}
+	if sv, ok := configValue(cfg.Section("remap").Get("type")).(*parser.StringValue); ok {
+		return sv.Value
+	}
+	return ""
+}
+
 // checkParentReferences checks for references to non-existent parent nodes.
 func (s *Server) checkParentReferences(doc *analysis.Document) []protocol.Diagnostic {
 	diagnostics := []protocol.Diagnostic{}
@@ -165,10 +631,10 @@ func (s *Server) checkParentReferences(doc *analysis.Document) []protocol.Diagno
 		} else {
 			// Build the full path
 			var fullPath string
-			if node.Parent == "." {
+			if parent := analysis.ParentPath(node.Parent); parent == "." {
 				fullPath = node.Name
 			} else {
-				fullPath = node.Parent + "/" + node.Name
+				fullPath = parent + "/" + node.Name
 			}
 			nodePaths[fullPath] = true
 		}
@@ -176,12 +642,12 @@ func (s *Server) checkParentReferences(doc *analysis.Document) []protocol.Diagno
 
 	// Check parent references
 	for _, node := range doc.TSCNAST.Nodes {
-		if node.Parent == "" || node.Parent == "." {
+		if node.Parent == "" {
 			continue
 		}
 
 		// The parent path should exist (without including the root name)
-		if !nodePaths[node.Parent] && node.Parent != rootName {
+		if parent := analysis.ParentPath(node.Parent); !nodePaths[parent] && parent != rootName {
 			diagnostics = append(diagnostics, protocol.Diagnostic{
 				Range: protocol.Range{
 					Start: protocol.Position{
@@ -195,6 +661,7 @@ func (s *Server) checkParentReferences(doc *analysis.Document) []protocol.Diagno
 				},
 				Severity: severityPtr(protocol.DiagnosticSeverityWarning),
 				Source:   strPtr("gdls"),
+				Code:     diagnosticCode(codeMissingParent),
 				Message:  "Parent node not found: " + node.Parent,
 			})
 		}
@@ -203,32 +670,40 @@ func (s *Server) checkParentReferences(doc *analysis.Document) []protocol.Diagno
 	return diagnostics
 }
 
-// checkDuplicateIDs checks for duplicate resource IDs.
+// checkDuplicateIDs checks for duplicate resource IDs, ext_resource IDs the
+// Godot editor would replace, and a file uid another file already declares.
 func (s *Server) checkDuplicateIDs(doc *analysis.Document) []protocol.Diagnostic {
 	diagnostics := []protocol.Diagnostic{}
+	exported := exportedScene(doc.URI) // Never saved by the editor
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, code, msg string, related ...protocol.DiagnosticRelatedInformation) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:              toProtocolRange(r),
+			Severity:           severityPtr(severity),
+			Source:             strPtr("gdls"),
+			Code:               diagnosticCode(code),
+			Message:            msg,
+			RelatedInformation: related,
+		})
+	}
+	firstDeclared := func(r parser.Range) protocol.DiagnosticRelatedInformation {
+		return protocol.DiagnosticRelatedInformation{
+			Location: protocol.Location{URI: doc.URI, Range: toProtocolRange(r)},
+			Message:  "First declared here",
+		}
+	}
 
 	// Check external resources
 	extIDs := make(map[string]*parser.ExtResource)
 	for _, ext := range doc.TSCNAST.ExtResources {
 		if existing, ok := extIDs[ext.ID]; ok {
-			diagnostics = append(diagnostics, protocol.Diagnostic{
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(ext.Range.Start.Line),
-						Character: uint32(ext.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(ext.Range.End.Line),
-						Character: uint32(ext.Range.End.Column),
-					},
-				},
-				Severity: severityPtr(protocol.DiagnosticSeverityError),
-				Source:   strPtr("gdls"),
-				Message:  "Duplicate external resource ID: " + ext.ID,
-			})
-			_ = existing
-		} else {
-			extIDs[ext.ID] = ext
+			add(ext.Range, protocol.DiagnosticSeverityError, codeDuplicateID,
+				"Duplicate external resource ID: "+ext.ID, firstDeclared(existing.Range))
+			continue
+		}
+		extIDs[ext.ID] = ext
+		if ext.ID != "" && !extResourceIDPattern.MatchString(ext.ID) && !exported {
+			add(ext.Range, protocol.DiagnosticSeverityWarning, codeRegeneratedID,
+				fmt.Sprintf("External resource ID %s is not of the form <number>_<suffix>; the Godot editor replaces it on the next save", ext.ID))
 		}
 	}
 
@@ -236,30 +711,153 @@ func (s *Server) checkDuplicateIDs(doc *analysis.Document) []protocol.Diagnostic
 	subIDs := make(map[string]*parser.SubResource)
 	for _, sub := range doc.TSCNAST.SubResources {
 		if existing, ok := subIDs[sub.ID]; ok {
+			add(sub.Range, protocol.DiagnosticSeverityError, codeDuplicateID,
+				"Duplicate sub-resource ID: "+sub.ID, firstDeclared(existing.Range))
+			continue
+		}
+		subIDs[sub.ID] = sub
+	}
+
+	// Check the uid of the file against the other files of the project
+	if gd := doc.TSCNAST.Descriptor; gd != nil && gd.UID != "" {
+		if other := s.uidOwner(doc.URI, gd.UID); other != nil {
+			add(gd.UIDRange, protocol.DiagnosticSeverityError, codeDuplicateUID,
+				fmt.Sprintf("uid %s is also declared by %s; Godot loads only one of them", gd.UID, s.displayPath(other.URI)),
+				protocol.DiagnosticRelatedInformation{Location: *other, Message: "Also declared here"})
+		}
+	}
+
+	return diagnostics
+}
+
+// extResourceIDPattern matches the ext_resource IDs the Godot editor
+// generates and keeps, e.g. "1_7bt6s". Other IDs, such as the bare numbers
+// of converted Godot 3 scenes, are replaced when the scene is saved.
+var extResourceIDPattern = regexp.MustCompile(`^[0-9]+_`)
+
+// uidOwner returns the location of another file of the project that the
+// uid index maps uid to, when that file still exists. The file at uri is
+// then a copy declaring the uid of the original, and only one of them can
+// be loaded by uid.
+func (s *Server) uidOwner(uri, uid string) *protocol.Location {
+	path, ok := s.workspace.ResolveUID(uri, uid)
+	if !ok {
+		return nil
+	}
+	root := s.findProjectRoot(uri)
+	if root == "" {
+		return nil
+	}
+	if own, ok := analysis.ResPath(root, uriToPath(uri)); !ok || own == path {
+		return nil
+	}
+	location := s.resolveResourcePath(path, uri)
+	if location == nil || !fileExists(uriToPath(location.URI)) {
+		return nil
+	}
+	return location
+}
+
+// checkUnknownProperties checks the properties of nodes and resources
+// against the class reference, suggesting the closest property name.
+// Sections with a script are skipped, as are types the class reference
+// does not know, since both can declare properties of their own.
+func (s *Server) checkUnknownProperties(doc *analysis.Document) []protocol.Diagnostic {
+	db := s.classReference()
+	if db == nil {
+		return nil
+	}
+	diagnostics := []protocol.Diagnostic{}
+	ast := doc.TSCNAST
+
+	check := func(typeName string, props []*parser.Property) {
+		if db.Class(typeName) == nil {
+			return
+		}
+		for _, prop := range props {
+			if prop.Key == "script" {
+				return
+			}
+		}
+		for _, prop := range props {
+			if isDynamicProperty(prop.Key) {
+				continue
+			}
+			if db.HasProperty(typeName, prop.Key) {
+				continue
+			}
+			msg := fmt.Sprintf("Unknown property %s on %s", prop.Key, typeName)
+			if suggestion := closestProperty(db, typeName, prop.Key); suggestion != "" {
+				msg += fmt.Sprintf("; did you mean %s?", suggestion)
+			}
 			diagnostics = append(diagnostics, protocol.Diagnostic{
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(sub.Range.Start.Line),
-						Character: uint32(sub.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(sub.Range.End.Line),
-						Character: uint32(sub.Range.End.Column),
-					},
-				},
-				Severity: severityPtr(protocol.DiagnosticSeverityError),
+				Range:    toProtocolRange(prop.KeyRange),
+				Severity: severityPtr(protocol.DiagnosticSeverityWarning),
 				Source:   strPtr("gdls"),
-				Message:  "Duplicate sub-resource ID: " + sub.ID,
+				Code:     diagnosticCode(codeUnknownProperty),
+				Message:  msg,
 			})
-			_ = existing
-		} else {
-			subIDs[sub.ID] = sub
 		}
 	}
 
+	for _, sub := range ast.SubResources {
+		check(sub.Type, sub.Properties)
+	}
+	for _, node := range ast.Nodes {
+		check(node.Type, node.Properties)
+	}
+	if ast.Resource != nil && ast.Descriptor != nil {
+		check(ast.Descriptor.ResourceType, ast.Resource.Properties)
+	}
+
 	return diagnostics
 }
 
+// isDynamicProperty reports whether a key names a property objects create
+// at runtime rather than one the class reference lists: slashed keys such as
+// metadata/foo, bones/0/name or theme_override_colors/font_color, and
+// underscored storage properties such as _data.
+func isDynamicProperty(key string) bool {
+	return strings.Contains(key, "/") || strings.HasPrefix(key, "_")
+}
+
+// closestProperty returns the property of a class, or of its ancestors,
+// whose name is the fewest edits away from key, or "" when none is close
+// enough to be a likely typo.
+func closestProperty(db *godotdoc.Database, typeName, key string) string {
+	// Allow about one edit for every three characters of the key
+	best, bestDistance := "", len(key)/3+1
+	for _, ancestor := range db.Inheritance(typeName) {
+		for _, prop := range db.Class(ancestor).Properties {
+			if d := editDistance(key, prop.Name); d < bestDistance {
+				best, bestDistance = prop.Name, d
+			}
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
 // walkValue recursively walks a value and calls the callback for each value.
 func walkValue(v parser.Value, cb func(parser.Value)) {
 	if v == nil {
@@ -278,14 +876,17 @@ func walkValue(v parser.Value, cb func(parser.Value)) {
 			walkValue(entry.Value, cb)
 		}
 	case *parser.TypedValue:
+		for _, param := range val.TypeParams {
+			walkValue(param, cb)
+		}
 		for _, arg := range val.Arguments {
 			walkValue(arg, cb)
 		}
 	}
 }
 
-// publishGDShaderDiagnostics publishes diagnostics for a GDShader document.
-func (s *Server) publishGDShaderDiagnostics(ctx *glsp.Context, uri string, doc *analysis.Document) {
+// gdshaderDiagnostics computes diagnostics for a GDShader document.
+func (s *Server) gdshaderDiagnostics(doc *analysis.Document) []protocol.Diagnostic {
 	diagnostics := []protocol.Diagnostic{}
 
 	// Add parse errors from the shader AST
@@ -304,34 +905,43 @@ func (s *Server) publishGDShaderDiagnostics(ctx *glsp.Context, uri string, doc *
 				},
 				Severity: severityPtr(protocol.DiagnosticSeverityError),
 				Source:   strPtr("gdls"),
+				Code:     diagnosticCode(codeParseError),
 				Message:  err.Message,
 			})
 		}
 	}
 
-	// Add semantic errors
+	// Add semantic errors and warnings
 	for _, err := range doc.ShaderErrs {
+		severity, code := protocol.DiagnosticSeverityError, codeShaderError
+		if err.Warning {
+			severity, code = protocol.DiagnosticSeverityWarning, codeShaderWarning
+		}
+		if err.Code != "" {
+			code = err.Code
+		}
+		var related []protocol.DiagnosticRelatedInformation
+		for _, info := range err.Related {
+			uri := doc.URI
+			if info.URI != "" {
+				uri = info.URI // Declared in an included file
+			}
+			related = append(related, protocol.DiagnosticRelatedInformation{
+				Location: protocol.Location{URI: uri, Range: toProtocolShaderRange(info.Range)},
+				Message:  info.Message,
+			})
+		}
 		diagnostics = append(diagnostics, protocol.Diagnostic{
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(err.Range.Start.Line),
-					Character: uint32(err.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(err.Range.End.Line),
-					Character: uint32(err.Range.End.Column),
-				},
-			},
-			Severity: severityPtr(protocol.DiagnosticSeverityError),
-			Source:   strPtr("gdls"),
-			Message:  err.Message,
+			Range:              toProtocolShaderRange(err.Range),
+			Severity:           severityPtr(severity),
+			Source:             strPtr("gdls"),
+			Code:               diagnosticCode(code),
+			Message:            err.Message,
+			RelatedInformation: related,
 		})
 	}
 
-	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, protocol.PublishDiagnosticsParams{
-		URI:         uri,
-		Diagnostics: diagnostics,
-	})
+	return diagnostics
 }
 
 func severityPtr(s protocol.DiagnosticSeverity) *protocol.DiagnosticSeverity {
@@ -341,3 +951,11 @@ func severityPtr(s protocol.DiagnosticSeverity) *protocol.DiagnosticSeverity {
 func strPtr(s string) *string {
 	return &s
 }
+
+// addDiagnostic adds a diagnostic to the ones of a document, with related
+// information such as where a duplicate was first declared.
+type addDiagnostic func(r parser.Range, severity protocol.DiagnosticSeverity, code, msg string, related ...protocol.DiagnosticRelatedInformation)
+
+func diagnosticCode(code string) *protocol.IntegerOrString {
+	return &protocol.IntegerOrString{Value: code}
+}
@@ -0,0 +1,319 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestUnknownPropertyDiagnostics(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[ext_resource type="Script" path="res://player.gd" id="1_s"]
+
+[sub_resource type="SphereShape3D" id="Sphere_1"]
+radius = 1.5
+raduis = 2.0
+
+[node name="Root" type="Node3D"]
+positon = Vector3(1, 0, 0)
+metadata/_custom = 1
+_import_path = NodePath("")
+zzzzzz = 1
+
+[node name="Player" type="CharacterBody3D" parent="."]
+script = ExtResource("1_s")
+speed = 10.0
+
+[node name="Custom" type="MyCustomNode" parent="."]
+anything = true
+
+[node name="HUD" type="Control" parent="."]
+layout_mode = 3
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/level.tscn", content)
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		messages = append(messages, d.Message)
+	}
+
+	expected := []string{
+		"Unknown property raduis on SphereShape3D; did you mean radius?",
+		"Unknown property positon on Node3D; did you mean position?",
+		"Unknown property zzzzzz on Node3D",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	// The class reference does not document older targets
+	s.applySettings(map[string]any{"godotVersion": "4.4"})
+	if diagnostics := s.computeDiagnostics(doc); len(diagnostics) != 0 {
+		t.Errorf("expected no unknown properties for Godot 4.4, got %+v", diagnostics)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "abc", 3},
+		{"position", "position", 0},
+		{"positon", "position", 1},
+		{"rotaton_degres", "rotation_degrees", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderDiagnostics(t *testing.T) {
+	tests := []struct {
+		name, content string
+		expected      []string
+	}{
+		{"matching load_steps", "[gd_scene load_steps=2 format=3]\n\n[sub_resource type=\"BoxMesh\" id=\"Box_1\"]\n", nil},
+		{"omitted load_steps", "[gd_scene format=3]\n\n[sub_resource type=\"BoxMesh\" id=\"Box_1\"]\n", nil},
+		{"format 4", "[gd_scene format=4]\n", nil},
+		{"load_steps mismatch", "[gd_scene load_steps=4 format=3]\n\n[sub_resource type=\"BoxMesh\" id=\"Box_1\"]\n",
+			[]string{"load-steps: load_steps=4 does not match the 1 resource of the file, expected load_steps=2"}},
+		{"unsupported format", "[gd_scene format=2]\n",
+			[]string{"unsupported-format: Unsupported format=2, only format=3 and format=4 (Godot 4.x) are supported"}},
+		{"resource without type", "[gd_resource format=3]\n\n[resource]\n",
+			[]string{"missing-type: [gd_resource] must declare the type of the resource, e.g. type=\"Resource\""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer("test", "test")
+			doc := s.workspace.OpenDocument("file:///tmp/header.tscn", tt.content)
+
+			var messages []string
+			for _, d := range s.computeDiagnostics(doc) {
+				messages = append(messages, d.Code.Value.(string)+": "+d.Message)
+			}
+			if !slices.Equal(messages, tt.expected) {
+				t.Errorf("expected %q, got %q", tt.expected, messages)
+			}
+		})
+	}
+}
+
+func TestExportedSceneDiagnostics(t *testing.T) {
+	// A Godot 3 export: format=2, numeric ids, a stale load_steps and
+	// classes Godot 4 renamed
+	content := `[gd_scene load_steps=5 format=2]
+
+[ext_resource id=1 path="res://grid.png" type="Texture"]
+
+[sub_resource id=1 type="SpatialMaterial"]
+albedo_texture = ExtResource(1)
+
+[node type="Spatial" name="Root"]
+
+[node name="Mesh" type="MeshInstance" parent="."]
+material_override = SubResource(1)
+`
+	s := NewServer("test", "test")
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(s.workspace.OpenDocument("file:///tmp/model.escn", content)) {
+		messages = append(messages, d.Code.Value.(string))
+	}
+	if !slices.Equal(messages, []string{codeExportedScene}) {
+		t.Errorf("expected only the exported scene hint, got %q", messages)
+	}
+
+	// The same file as a scene of the project is not relaxed
+	messages = nil
+	for _, d := range s.computeDiagnostics(s.workspace.OpenDocument("file:///tmp/model.tscn", content)) {
+		messages = append(messages, d.Code.Value.(string))
+	}
+	if !slices.Contains(messages, codeUnsupportedFormat) || !slices.Contains(messages, codeRegeneratedID) {
+		t.Errorf("expected the header and ids of a .tscn to be checked, got %q", messages)
+	}
+}
+
+func TestDuplicateIDDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	original := "[gd_scene format=3 uid=\"uid://b1dupl1cate\"]\n\n[node name=\"Level\" type=\"Node\"]\n"
+	for name, content := range map[string]string{
+		"project.godot":  "config_version=5\n",
+		"level.tscn":     original,
+		"level_old.tscn": original, // A copy made outside the editor
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	content := `[gd_scene format=3 uid="uid://b1dupl1cate"]
+
+[ext_resource type="Script" path="res://player.gd" id="1_s"]
+[ext_resource type="Texture2D" path="res://icon.png" id="1_s"]
+[ext_resource type="Texture2D" path="res://other.png" id="3"]
+
+[sub_resource type="BoxMesh" id="Box_1"]
+
+[sub_resource type="BoxMesh" id="Box_1"]
+
+[node name="Level" type="Node"]
+`
+	// The index maps the uid to the file read last
+	doc := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "level.tscn")), content)
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		if code := d.Code.Value.(string); code == codeDuplicateID || code == codeRegeneratedID || code == codeDuplicateUID {
+			messages = append(messages, code+": "+d.Message)
+		}
+	}
+	expected := []string{
+		"duplicate-id: Duplicate external resource ID: 1_s",
+		"regenerated-id: External resource ID 3 is not of the form <number>_<suffix>; the Godot editor replaces it on the next save",
+		"duplicate-id: Duplicate sub-resource ID: Box_1",
+		"duplicate-uid: uid uid://b1dupl1cate is also declared by level_old.tscn; Godot loads only one of them",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+}
+
+func TestExtResourceTypeDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot":   "config_version=5\n",
+		"enemy.tscn":      "[gd_scene format=3]\n\n[node name=\"Enemy\" type=\"Node2D\"]\n",
+		"material.tres":   "[gd_resource type=\"StandardMaterial3D\" format=3]\n\n[resource]\n",
+		"icon.png":        "",
+		"icon.png.import": "[remap]\n\nimporter=\"texture\"\ntype=\"CompressedTexture2D\"\n",
+		"player.gd":       "extends Node2D\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://enemy.tscn" id="1_ok"]
+[ext_resource type="Material" path="res://material.tres" id="2_ok"]
+[ext_resource type="Texture2D" path="res://icon.png" id="3_ok"]
+[ext_resource type="Script" path="res://player.gd" id="4_ok"]
+[ext_resource type="Texture2D" path="res://enemy.tscn" id="5_bad"]
+[ext_resource type="ShaderMaterial" path="res://material.tres" id="6_bad"]
+[ext_resource type="AudioStream" path="res://icon.png" id="7_bad"]
+[ext_resource type="PackedScene" path="res://player.gd" id="8_bad"]
+[ext_resource type="MyCustomType" path="res://player.gd" id="9_unknown"]
+
+[node name="Level" type="Node"]
+`
+	doc := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "level.tscn")), content)
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value.(string) == codeResourceType {
+			messages = append(messages, fmt.Sprintf("%d: %s", d.Range.Start.Line, d.Message))
+		}
+	}
+	expected := []string{
+		"6: res://enemy.tscn is of type PackedScene, not Texture2D; Godot fails to load it",
+		"7: res://material.tres is of type StandardMaterial3D, not ShaderMaterial; Godot fails to load it",
+		"8: res://icon.png is of type CompressedTexture2D, not AudioStream; Godot fails to load it",
+		"9: res://player.gd is of type GDScript, not PackedScene; Godot fails to load it",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+}
+
+func TestUnknownTypeDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"enemy.gd":      "class_name Enemy\nextends CharacterBody2D\n",
+		"Item.cs":       "[GlobalClass]\npublic partial class ItemData : Resource {}\n",
+		"palette.gd":    "class_name Palette extends Gradient\n",
+		"sunset.tres":   "[gd_resource type=\"Gradient\" format=3]\n\n[resource]\n",
+		"boss.tscn":     "[gd_scene format=3]\n\n[node name=\"Boss\" type=\"Node2D\"]\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="Palette" path="res://sunset.tres" id="1_ok"]
+[ext_resource type="Palette" path="res://boss.tscn" id="2_bad"]
+[ext_resource type="Texture2d" path="res://icon.png" id="3_unknown"]
+
+[sub_resource type="ItemData" id="Item_1"]
+
+[sub_resource type="Itemdata" id="Item_2"]
+
+[node name="Level" type="Node2D"]
+
+[node name="Enemy" type="Enemy" parent="."]
+
+[node name="Boss" type="Bos" parent="."]
+`
+	doc := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "level.tscn")), content)
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		if code := d.Code.Value.(string); code == codeUnknownType || code == codeResourceType {
+			messages = append(messages, fmt.Sprintf("%d: %s", d.Range.Start.Line, d.Message))
+		}
+	}
+	expected := []string{
+		"14: Unknown type Bos; it is neither a Godot class nor a class_name of the project",
+		"3: res://boss.tscn is of type PackedScene, not Palette; Godot fails to load it",
+		"4: Unknown type Texture2d; it is neither a Godot class nor a class_name of the project",
+		"8: Unknown type Itemdata; it is neither a Godot class nor a class_name of the project",
+	}
+	slices.Sort(messages)
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	// Script classes are completed after the engine classes
+	items := s.getNodeTypeCompletions(doc)
+	var details []string
+	for _, item := range items[len(items)-3:] {
+		details = append(details, item.Label+": "+*item.Detail)
+	}
+	if want := []string{"Enemy: Script class extending CharacterBody2D", "ItemData: Script class extending Resource", "Palette: Script class extending Gradient"}; !slices.Equal(details, want) {
+		t.Errorf("expected %q, got %q", want, details)
+	}
+
+	// Classes of a GDExtension are unknown, so types are not checked
+	if err := os.WriteFile(filepath.Join(dir, "spine.gdextension"), []byte("[configuration]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value.(string) == codeUnknownType {
+			t.Errorf("expected no unknown types in a project with a GDExtension, got %q", d.Message)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "documentColor",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentColor = s.textDocumentColor
+			h.TextDocumentColorPresentation = s.textDocumentColorPresentation
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.ColorProvider = &protocol.DocumentColorOptions{}
+		},
+	})
+}
+
+// textDocumentColor handles the textDocument/documentColor request.
+func (s *Server) textDocumentColor(ctx *glsp.Context, params *protocol.DocumentColorParams) ([]protocol.ColorInformation, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+
+	switch {
+	case doc.TSCNAST != nil:
+		return tscnColors(doc.TSCNAST), nil
+	case doc.ShaderAST != nil:
+		return gdshaderColors(doc.ShaderAST), nil
+	}
+	return nil, nil
+}
+
+// tscnColors returns the Color() values of every property in a scene or
+// resource.
+func tscnColors(ast *parser.Document) []protocol.ColorInformation {
+	var props []*parser.Property
+	for _, sub := range ast.SubResources {
+		props = append(props, sub.Properties...)
+	}
+	for _, node := range ast.Nodes {
+		props = append(props, node.Properties...)
+	}
+	if ast.Resource != nil {
+		props = append(props, ast.Resource.Properties...)
+	}
+
+	colors := []protocol.ColorInformation{}
+	for _, prop := range props {
+		walkValue(prop.Value, func(v parser.Value) {
+			tv, ok := v.(*parser.TypedValue)
+			if !ok || tv.TypeName != "Color" {
+				return
+			}
+			components := make([]float64, len(tv.Arguments))
+			for i, arg := range tv.Arguments {
+				num, ok := arg.(*parser.NumberValue)
+				if !ok {
+					return
+				}
+				components[i] = num.Value
+			}
+			if color, ok := colorFromComponents(components); ok {
+				colors = append(colors, protocol.ColorInformation{
+					Range: toProtocolRange(tv.Range),
+					Color: color,
+				})
+			}
+		})
+	}
+	return colors
+}
+
+// gdshaderColors returns the vec3 and vec4 default values of the uniforms
+// hinted as source_color, when every component is a number literal.
+func gdshaderColors(ast *gdshader.ShaderDocument) []protocol.ColorInformation {
+	colors := []protocol.ColorInformation{}
+	for _, uniform := range ast.Uniforms {
+		if !hasHint(uniform, "source_color") {
+			continue
+		}
+		call, ok := uniform.DefaultValue.(*gdshader.CallExpr)
+		if !ok {
+			continue
+		}
+		ident, ok := call.Func.(*gdshader.IdentExpr)
+		if !ok || (ident.Name != "vec3" && ident.Name != "vec4") {
+			continue
+		}
+
+		components := make([]float64, len(call.Args))
+		for i, arg := range call.Args {
+			value, ok := shaderNumber(arg)
+			if !ok {
+				components = nil
+				break
+			}
+			components[i] = value
+		}
+		// vec4(1.0) fills every component with the same value
+		if len(components) == 1 {
+			size := 3
+			if ident.Name == "vec4" {
+				size = 4
+			}
+			for len(components) < size {
+				components = append(components, components[0])
+			}
+		}
+		if color, ok := colorFromComponents(components); ok {
+			colors = append(colors, protocol.ColorInformation{
+				Range: toProtocolShaderRange(call.Range),
+				Color: color,
+			})
+		}
+	}
+	return colors
+}
+
+// hasHint reports whether a uniform has the named hint.
+func hasHint(uniform *gdshader.UniformDecl, name string) bool {
+	for _, hint := range uniform.Hints {
+		if hint.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// shaderNumber returns the value of a number literal, possibly negated.
+func shaderNumber(expr gdshader.Expr) (float64, bool) {
+	switch e := expr.(type) {
+	case *gdshader.LiteralExpr:
+		if e.Kind != "float" && e.Kind != "int" {
+			return 0, false
+		}
+		value, err := strconv.ParseFloat(strings.TrimRight(e.Value, "fFuU"), 64)
+		return value, err == nil
+	case *gdshader.UnaryExpr:
+		if e.Operator == "-" {
+			value, ok := shaderNumber(e.Operand)
+			return -value, ok
+		}
+	}
+	return 0, false
+}
+
+// colorFromComponents builds a color from three or four components, the
+// alpha defaulting to 1.
+func colorFromComponents(components []float64) (protocol.Color, bool) {
+	if len(components) != 3 && len(components) != 4 {
+		return protocol.Color{}, false
+	}
+	color := protocol.Color{
+		Red:   protocol.Decimal(components[0]),
+		Green: protocol.Decimal(components[1]),
+		Blue:  protocol.Decimal(components[2]),
+		Alpha: 1,
+	}
+	if len(components) == 4 {
+		color.Alpha = protocol.Decimal(components[3])
+	}
+	return color, true
+}
+
+// textDocumentColorPresentation handles the textDocument/colorPresentation
+// request, writing a picked color back as a Color() value in scenes and
+// resources, or as the vec3 or vec4 constructor it replaces in shaders.
+func (s *Server) textDocumentColorPresentation(ctx *glsp.Context, params *protocol.ColorPresentationParams) ([]protocol.ColorPresentation, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+
+	label := colorPresentation(doc, params.Color, params.Range)
+	if label == "" {
+		return nil, nil
+	}
+	return []protocol.ColorPresentation{{
+		Label:    label,
+		TextEdit: &protocol.TextEdit{Range: params.Range, NewText: label},
+	}}, nil
+}
+
+// colorPresentation formats a color for the document the given range is in.
+func colorPresentation(doc *analysis.Document, c protocol.Color, rng protocol.Range) string {
+	switch doc.Type {
+	case analysis.DocumentTypeTSCN:
+		return fmt.Sprintf("Color(%s, %s, %s, %s)",
+			formatColorComponent(c.Red), formatColorComponent(c.Green),
+			formatColorComponent(c.Blue), formatColorComponent(c.Alpha))
+	case analysis.DocumentTypeGDShader:
+		start := doc.PositionToOffset(rng.Start.Line, rng.Start.Character)
+		if strings.HasPrefix(doc.Content[start:], "vec3") {
+			return fmt.Sprintf("vec3(%s, %s, %s)",
+				formatShaderFloat(c.Red), formatShaderFloat(c.Green), formatShaderFloat(c.Blue))
+		}
+		return fmt.Sprintf("vec4(%s, %s, %s, %s)",
+			formatShaderFloat(c.Red), formatShaderFloat(c.Green),
+			formatShaderFloat(c.Blue), formatShaderFloat(c.Alpha))
+	}
+	return ""
+}
+
+// formatColorComponent formats a component the way Godot writes floats in
+// scene files, e.g. 1, 0.5 or 0.2509804.
+func formatColorComponent(v protocol.Decimal) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// formatShaderFloat formats a component as a shader float literal, which
+// needs a decimal point, e.g. 1.0 or 0.5.
+func formatShaderFloat(v protocol.Decimal) string {
+	s := formatColorComponent(v)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentColorsInScene(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[sub_resource type="Gradient" id="Gradient_1"]
+colors = PackedColorArray(0, 0, 0, 1, 1, 1, 1, 1)
+
+[node name="Root" type="ColorRect"]
+color = Color(1, 0.5, 0, 0.25)
+modulate = Color(x, 0, 0, 1)
+`
+	s := NewServer("test", "test")
+	uri := "file:///tmp/colors.tscn"
+	s.workspace.OpenDocument(uri, content)
+
+	colors, err := s.textDocumentColor(nil, &protocol.DocumentColorParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(colors) != 1 {
+		t.Fatalf("expected 1 color, got %+v", colors)
+	}
+	want := protocol.Color{Red: 1, Green: 0.5, Blue: 0, Alpha: 0.25}
+	if colors[0].Color != want {
+		t.Errorf("expected %+v, got %+v", want, colors[0].Color)
+	}
+	if r := colors[0].Range; r.Start.Line != 6 || r.Start.Character != 8 || r.End.Character != 30 {
+		t.Errorf("unexpected range %+v", r)
+	}
+
+	presentations, _ := s.textDocumentColorPresentation(nil, &protocol.ColorPresentationParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Color:        protocol.Color{Red: 0.2509804, Green: 1, Blue: 0, Alpha: 1},
+		Range:        colors[0].Range,
+	})
+	if len(presentations) != 1 || presentations[0].Label != "Color(0.2509804, 1, 0, 1)" {
+		t.Errorf("unexpected presentations %+v", presentations)
+	}
+}
+
+func TestDocumentColorsInShader(t *testing.T) {
+	content := `shader_type spatial;
+
+uniform vec4 albedo : source_color = vec4(1.0, 0.5, 0.0, 1.0);
+uniform vec3 emission : source_color = vec3(0.2);
+uniform vec3 offset = vec3(1.0, 0.0, 0.0);
+uniform vec4 tint : source_color = vec4(albedo.rgb, 1.0);
+`
+	s := NewServer("test", "test")
+	uri := "file:///tmp/colors.gdshader"
+	s.workspace.OpenDocument(uri, content)
+
+	colors, err := s.textDocumentColor(nil, &protocol.DocumentColorParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(colors) != 2 {
+		t.Fatalf("expected 2 colors, got %+v", colors)
+	}
+	if want := (protocol.Color{Red: 1, Green: 0.5, Blue: 0, Alpha: 1}); colors[0].Color != want {
+		t.Errorf("expected %+v, got %+v", want, colors[0].Color)
+	}
+	if want := (protocol.Color{Red: 0.2, Green: 0.2, Blue: 0.2, Alpha: 1}); colors[1].Color != want {
+		t.Errorf("expected %+v, got %+v", want, colors[1].Color)
+	}
+	if r := colors[0].Range; r.Start.Line != 2 || r.Start.Character != 37 || r.End.Character != 61 {
+		t.Errorf("unexpected range %+v", r)
+	}
+
+	for i, want := range []string{"vec4(0.5, 1.0, 0.0, 0.5)", "vec3(0.5, 1.0, 0.0)"} {
+		presentations, _ := s.textDocumentColorPresentation(nil, &protocol.ColorPresentationParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Color:        protocol.Color{Red: 0.5, Green: 1, Blue: 0, Alpha: 0.5},
+			Range:        colors[i].Range,
+		})
+		if len(presentations) != 1 || presentations[0].Label != want {
+			t.Errorf("expected %s, got %+v", want, presentations)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "documentHighlight",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentDocumentHighlight = s.textDocumentDocumentHighlight
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DocumentHighlightProvider = true
+		},
+	})
+}
+
+// nodeOccurrence is a place in a scene where a node is named: its own
+// [node name=...] declaration or a segment of a path that resolves to it.
+type nodeOccurrence struct {
+	node        *analysis.SceneNode
+	rng         parser.Range
+	declaration bool
+}
+
+// textDocumentDocumentHighlight handles the textDocument/documentHighlight request.
+func (s *Server) textDocumentDocumentHighlight(ctx *glsp.Context, params *protocol.DocumentHighlightParams) ([]protocol.DocumentHighlight, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil || doc.TSCNAST == nil {
+		return nil, nil
+	}
+
+	// Nodes are only named inside [node] and [connection] sections
+	line, col := int(params.Position.Line), int(params.Position.Character)
+	if _, ok := analysis.ElementAt[*parser.Node](doc.Positions, line, col); !ok {
+		if _, ok := analysis.ElementAt[*parser.Connection](doc.Positions, line, col); !ok {
+			return nil, nil
+		}
+	}
+
+	occurrences := collectNodeOccurrences(doc.TSCNAST)
+	target := nodeOccurrenceAt(occurrences, line, col)
+	if target == nil {
+		return nil, nil
+	}
+
+	highlights := []protocol.DocumentHighlight{}
+	for _, occ := range occurrences {
+		if occ.node != target {
+			continue
+		}
+		kind := protocol.DocumentHighlightKindRead
+		if occ.declaration {
+			kind = protocol.DocumentHighlightKindWrite
+		}
+		highlights = append(highlights, protocol.DocumentHighlight{
+			Range: toProtocolRange(occ.rng),
+			Kind:  &kind,
+		})
+	}
+
+	return highlights, nil
+}
+
+// nodePathString is a quoted node path in a scene together with the node
+// it is relative to.
+type nodePathString struct {
+	base *analysis.SceneNode
+	path string
+	rng  parser.Range // Range of the quoted string
+}
+
+// collectNodePaths returns every node path written in a scene: parent=
+// attributes, NodePath() values in node properties, and connection from/to
+// paths.
+func collectNodePaths(ast *parser.Document, tree *analysis.SceneTree) []nodePathString {
+	paths := []nodePathString{}
+
+	for _, sn := range tree.Nodes {
+		node := sn.Node
+		if node.Parent != "" {
+			paths = append(paths, nodePathString{tree.Root, node.Parent, node.ParentRange})
+		}
+		for _, prop := range node.Properties {
+			walkNodePaths(prop.Value, func(sv *parser.StringValue) {
+				paths = append(paths, nodePathString{sn, sv.Value, sv.Range})
+			})
+		}
+	}
+
+	for _, conn := range ast.Connections {
+		paths = append(paths,
+			nodePathString{tree.Root, conn.From, conn.FromRange},
+			nodePathString{tree.Root, conn.To, conn.ToRange})
+	}
+
+	return paths
+}
+
+// nodePathAt returns the node path whose string contains the position, as
+// collectNodePaths would find it, looking up the elements at the position
+// in the position index of the scene.
+func nodePathAt(doc *analysis.Document, tree *analysis.SceneTree, line, col int) *nodePathString {
+	elems := doc.Positions.At(line, col)
+	if len(elems) == 0 {
+		return nil
+	}
+
+	switch elem := elems[0].(type) {
+	case *parser.Node:
+		if elem.Parent != "" && isInRange(elem.ParentRange, line, col) {
+			return &nodePathString{tree.Root, elem.Parent, elem.ParentRange}
+		}
+	case *parser.Connection:
+		if isInRange(elem.FromRange, line, col) {
+			return &nodePathString{tree.Root, elem.From, elem.FromRange}
+		}
+		if isInRange(elem.ToRange, line, col) {
+			return &nodePathString{tree.Root, elem.To, elem.ToRange}
+		}
+	case *parser.StringValue:
+		// The argument of a NodePath() in the value of a node property,
+		// outside of dictionary keys
+		if len(elems) < 2 {
+			return nil
+		}
+		if np, ok := elems[1].(*parser.TypedValue); !ok || np.TypeName != "NodePath" || len(np.Arguments) != 1 {
+			return nil
+		}
+		for i, outer := range elems[2:] {
+			switch outer := outer.(type) {
+			case *parser.DictEntry:
+				if outer.Key == elems[i+1] {
+					return nil
+				}
+			case *parser.Property:
+				if i+3 >= len(elems) {
+					return nil
+				}
+				if node, ok := elems[i+3].(*parser.Node); ok {
+					if sn := tree.NodeFor(node); sn != nil {
+						return &nodePathString{sn, elem.Value, elem.Range}
+					}
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// collectNodeOccurrences returns every declaration of and path reference to
+// the nodes of a scene.
+func collectNodeOccurrences(ast *parser.Document) []nodeOccurrence {
+	tree := analysis.NewSceneTree(ast)
+	occurrences := []nodeOccurrence{}
+
+	for _, sn := range tree.Nodes {
+		if sn.Node.Name != "" {
+			occurrences = append(occurrences, nodeOccurrence{
+				node:        sn,
+				rng:         stringContentRange(sn.Node.NameRange, 0, len(sn.Node.Name)),
+				declaration: true,
+			})
+		}
+	}
+
+	for _, np := range collectNodePaths(ast, tree) {
+		occurrences = append(occurrences, pathOccurrences(tree, np.base, np.path, np.rng)...)
+	}
+
+	return occurrences
+}
+
+// pathOccurrences resolves a node path written in the quoted string at rng.
+func pathOccurrences(tree *analysis.SceneTree, base *analysis.SceneNode, path string, rng parser.Range) []nodeOccurrence {
+	if base == nil {
+		return nil
+	}
+
+	// "." names the base node itself
+	if path == "." {
+		return []nodeOccurrence{{node: base, rng: stringContentRange(rng, 0, 1)}}
+	}
+
+	var occurrences []nodeOccurrence
+	for _, seg := range tree.ResolvePath(base, path) {
+		if seg.Node == nil {
+			continue
+		}
+		occurrences = append(occurrences, nodeOccurrence{
+			node: seg.Node,
+			rng:  stringContentRange(rng, seg.Offset, len(seg.Name)),
+		})
+	}
+	return occurrences
+}
+
+// walkNodePaths calls fn for the string argument of every NodePath() in v.
+func walkNodePaths(v parser.Value, fn func(*parser.StringValue)) {
+	switch val := v.(type) {
+	case *parser.TypedValue:
+		if val.TypeName == "NodePath" && len(val.Arguments) == 1 {
+			if sv, ok := val.Arguments[0].(*parser.StringValue); ok {
+				fn(sv)
+			}
+			return
+		}
+		for _, arg := range val.Arguments {
+			walkNodePaths(arg, fn)
+		}
+	case *parser.ArrayValue:
+		for _, elem := range val.Values {
+			walkNodePaths(elem, fn)
+		}
+	case *parser.DictValue:
+		for _, entry := range val.Entries {
+			walkNodePaths(entry.Value, fn)
+		}
+	}
+}
+
+// nodeOccurrenceAt returns the node named at the given position, if any.
+func nodeOccurrenceAt(occurrences []nodeOccurrence, line, col int) *analysis.SceneNode {
+	for _, occ := range occurrences {
+		if isInRange(occ.rng, line, col) {
+			return occ.node
+		}
+	}
+	return nil
+}
+
+// stringContentRange returns the range of length bytes starting at offset
+// inside the quoted string token at rng.
+func stringContentRange(rng parser.Range, offset, length int) parser.Range {
+	start := parser.Position{
+		Line:   rng.Start.Line,
+		Column: rng.Start.Column + 1 + offset,
+		Offset: rng.Start.Offset + 1 + offset,
+	}
+	end := parser.Position{
+		Line:   start.Line,
+		Column: start.Column + length,
+		Offset: start.Offset + length,
+	}
+	return parser.Range{Start: start, End: end}
+}
+
+// toProtocolRange converts a TSCN range to an LSP range.
+func toProtocolRange(r parser.Range) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(r.Start.Line), Character: uint32(r.Start.Column)},
+		End:   protocol.Position{Line: uint32(r.End.Line), Character: uint32(r.End.Column)},
+	}
+}
@@ -0,0 +1,124 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const highlightScene = `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+
+[node name="Body" type="CharacterBody2D" parent="."]
+
+[node name="Sprite" type="Sprite2D" parent="Body"]
+
+[node name="Camera" type="Camera2D" parent="Body/Sprite"]
+target = NodePath("../../Sprite")
+follow = NodePath("..:position")
+
+[node name="Label" type="Label" parent="."]
+unique_name_in_owner = true
+
+[node name="Timer" type="Timer" parent="."]
+labels = [NodePath("%Label"), NodePath("../Body/Sprite")]
+
+[connection signal="timeout" from="Timer" to="Body/Sprite" method="_on_timeout"]
+`
+
+// highlightedText returns the text under each highlight at the first
+// occurrence of marker in highlightScene.
+func highlightedText(t *testing.T, marker string) ([]string, []protocol.DocumentHighlight) {
+	t.Helper()
+
+	s := NewServer("test", "test")
+	uri := "file:///tmp/highlight.tscn"
+	s.workspace.OpenDocument(uri, highlightScene)
+
+	offset := strings.Index(highlightScene, marker)
+	if offset < 0 {
+		t.Fatalf("marker %q not found", marker)
+	}
+	line := strings.Count(highlightScene[:offset], "\n")
+	col := offset - (strings.LastIndexByte(highlightScene[:offset], '\n') + 1)
+
+	highlights, err := s.textDocumentDocumentHighlight(&glsp.Context{}, &protocol.DocumentHighlightParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: uint32(line), Character: uint32(col)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("highlight failed: %v", err)
+	}
+
+	lines := strings.Split(highlightScene, "\n")
+	var texts []string
+	for _, h := range highlights {
+		l := lines[h.Range.Start.Line]
+		texts = append(texts, l[h.Range.Start.Character:h.Range.End.Character])
+	}
+	return texts, highlights
+}
+
+func TestDocumentHighlightNodeName(t *testing.T) {
+	// Cursor on the "Sprite" node declaration
+	texts, highlights := highlightedText(t, `Sprite" type="Sprite2D"`)
+
+	// Declaration, Camera's parent, Camera's "../../Sprite", Timer's
+	// "../Body/Sprite" and the connection target. Relative steps such as
+	// ".." do not name the node and are not highlighted.
+	if len(highlights) != 5 {
+		t.Fatalf("expected 5 highlights, got %d: %v", len(highlights), texts)
+	}
+	for i, text := range texts {
+		if text != "Sprite" {
+			t.Errorf("unexpected highlighted text %q", text)
+		}
+		if i == 0 && *highlights[i].Kind != protocol.DocumentHighlightKindWrite {
+			t.Error("expected the declaration to be a write highlight")
+		}
+	}
+}
+
+func TestDocumentHighlightFromPathSegment(t *testing.T) {
+	// Cursor on "Body" inside the connection's to= path
+	texts, _ := highlightedText(t, `Body/Sprite" method`)
+
+	// Declaration, parent= of Sprite and Camera, Timer's NodePath and the connection
+	if len(texts) != 5 {
+		t.Fatalf("expected 5 highlights, got %d: %v", len(texts), texts)
+	}
+	for _, text := range texts {
+		if text != "Body" {
+			t.Errorf("unexpected highlighted text %q", text)
+		}
+	}
+}
+
+func TestDocumentHighlightUniqueName(t *testing.T) {
+	texts, _ := highlightedText(t, `Label" type="Label"`)
+	if len(texts) != 2 || texts[1] != "%Label" {
+		t.Errorf("expected declaration and %%Label reference, got %v", texts)
+	}
+}
+
+func TestReferencesNodeName(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/highlight.tscn"
+	doc := s.workspace.OpenDocument(uri, highlightScene)
+
+	// Cursor on the "Timer" node declaration
+	offset := strings.Index(highlightScene, `Timer" type`)
+	line := strings.Count(highlightScene[:offset], "\n")
+	col := offset - (strings.LastIndexByte(highlightScene[:offset], '\n') + 1)
+
+	withDecl := s.findReferences(doc, uri, line, col, true)
+	withoutDecl := s.findReferences(doc, uri, line, col, false)
+	if len(withDecl) != 2 || len(withoutDecl) != 1 {
+		t.Errorf("expected 2 and 1 locations, got %d and %d", len(withDecl), len(withoutDecl))
+	}
+}
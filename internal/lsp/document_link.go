@@ -1,21 +1,57 @@
 package lsp
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "documentLink",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentDocumentLink = s.textDocumentDocumentLink
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DocumentLinkProvider = &protocol.DocumentLinkOptions{
+				ResolveProvider: boolPtr(false),
+			}
+		},
+	})
+}
+
 // textDocumentDocumentLink handles the textDocument/documentLink request.
 func (s *Server) textDocumentDocumentLink(ctx *glsp.Context, params *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc != nil && doc.ConfigAST != nil {
+		return s.configLinks(doc), nil
+	}
 	if doc == nil || doc.TSCNAST == nil {
 		return nil, nil
 	}
 
 	links := []protocol.DocumentLink{}
 
-	// Add links for external resource paths
+	// Add links for external resource paths and uids
 	for _, ext := range doc.TSCNAST.ExtResources {
+		if ext.UID != "" {
+			if path, ok := s.workspace.ResolveUID(params.TextDocument.URI, ext.UID); ok {
+				if location := s.resolveResourcePath(path, params.TextDocument.URI); location != nil {
+					links = append(links, protocol.DocumentLink{
+						Range:   toProtocolRange(ext.UIDRange),
+						Target:  strPtr(location.URI),
+						Tooltip: strPtr("Open " + path),
+					})
+				}
+			}
+		}
+
 		if ext.Path == "" {
 			continue
 		}
@@ -49,5 +85,86 @@ func (s *Server) textDocumentDocumentLink(ctx *glsp.Context, params *protocol.Do
 		})
 	}
 
+	// Add links for resource URIs in property values
+	var props []*parser.Property
+	for _, sub := range doc.TSCNAST.SubResources {
+		props = append(props, sub.Properties...)
+	}
+	for _, node := range doc.TSCNAST.Nodes {
+		props = append(props, node.Properties...)
+	}
+	if doc.TSCNAST.Resource != nil {
+		props = append(props, doc.TSCNAST.Resource.Properties...)
+	}
+	for _, prop := range props {
+		walkValue(prop.Value, func(v parser.Value) {
+			sv, ok := v.(*parser.StringValue)
+			if !ok {
+				return
+			}
+			if link := s.stringLink(sv, params.TextDocument.URI); link != nil {
+				links = append(links, *link)
+			}
+		})
+	}
+
 	return links, nil
 }
+
+// stringLink returns a link for a string value holding a res://, uid:// or
+// user:// URI, or nil when the string is not one or cannot be resolved.
+func (s *Server) stringLink(sv *parser.StringValue, currentURI string) *protocol.DocumentLink {
+	// Sub-resources of a file are addressed as "res://file.tres::id"
+	value, _, _ := strings.Cut(sv.Value, "::")
+
+	var target string
+	switch {
+	case strings.HasPrefix(value, "res://"):
+		if location := s.resolveResourcePath(value, currentURI); location != nil {
+			target = location.URI
+		}
+	case strings.HasPrefix(value, "uid://"):
+		if path, ok := s.workspace.ResolveUID(currentURI, value); ok {
+			if location := s.resolveResourcePath(path, currentURI); location != nil {
+				target = location.URI
+			}
+		}
+	case strings.HasPrefix(value, "user://"):
+		if dir := s.userDataDir(currentURI); dir != "" {
+			target = pathToURI(filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(value, "user://"))))
+		}
+	}
+	if target == "" {
+		return nil
+	}
+
+	// Link the URI itself when the string has no escapes shifting columns
+	rng := sv.Range
+	if rng.Start.Line == rng.End.Line && rng.End.Column-rng.Start.Column == len(sv.Value)+2 {
+		rng = stringContentRange(rng, 0, len(value))
+	}
+	return &protocol.DocumentLink{
+		Range:   toProtocolRange(rng),
+		Target:  strPtr(target),
+		Tooltip: strPtr("Open " + value),
+	}
+}
+
+// userDataDir returns the directory user:// maps to for the project that
+// contains currentURI, or "" when there is no project.
+func (s *Server) userDataDir(currentURI string) string {
+	root := s.findProjectRoot(currentURI)
+	if root == "" {
+		return ""
+	}
+
+	projectFile := filepath.Join(root, analysis.ProjectFileName)
+	if doc := s.workspace.GetDocument(pathToURI(projectFile)); doc != nil && doc.ConfigAST != nil {
+		return analysis.UserDataDir(doc.ConfigAST)
+	}
+	content, err := os.ReadFile(projectFile)
+	if err != nil {
+		return ""
+	}
+	return analysis.UserDataDir(parser.ParseConfig(string(content)))
+}
@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestDocumentLinksInStrings(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"project.godot":  "[application]\nconfig/name=\"Space: Game\"\n",
+		"enemy.tscn":     "[gd_scene format=3 uid=\"uid://enemy\"]\n",
+		"icon.svg":       "<svg/>",
+		"theme.tres":     "[gd_resource type=\"Theme\" format=3]\n",
+		"stars.gdshader": "shader_type canvas_item;\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+
+	s := NewServer("test", "test")
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	resource := `[gd_resource type="Resource" format=3]
+
+[sub_resource type="ShaderMaterial" id="mat"]
+shader_path = "res://stars.gdshader"
+
+[resource]
+spawns = ["uid://enemy", "uid://unknown", "res://theme.tres::StyleBox_1"]
+icons = PackedStringArray("res://icon.svg")
+save_path = "user://saves/slot1.save"
+label = "not a path"
+`
+	uri := pathToURI(filepath.Join(dir, "level.tres"))
+	s.workspace.OpenDocument(uri, resource)
+
+	links, err := s.textDocumentDocumentLink(&glsp.Context{}, &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	targets := map[string]protocol.Range{}
+	for _, link := range links {
+		targets[*link.Target] = link.Range
+	}
+
+	expected := []string{"stars.gdshader", "enemy.tscn", "theme.tres", "icon.svg"}
+	for _, name := range expected {
+		if _, ok := targets[pathToURI(filepath.Join(dir, name))]; !ok {
+			t.Errorf("expected a link to %s, got %v", name, targets)
+		}
+	}
+	if runtime.GOOS == "linux" {
+		save := pathToURI(filepath.Join(dir, "data", "godot", "app_userdata", "Space_ Game", "saves", "slot1.save"))
+		if _, ok := targets[save]; !ok {
+			t.Errorf("expected a link to %s, got %v", save, targets)
+		}
+	}
+	if len(links) != len(expected)+1 {
+		t.Errorf("expected %d links, got %d", len(expected)+1, len(links))
+	}
+
+	// The link covers the path without the quotes or the sub-resource id
+	line, col := positionOf(t, resource, `res://theme.tres::`)
+	want := protocol.Range{
+		Start: protocol.Position{Line: uint32(line), Character: uint32(col)},
+		End:   protocol.Position{Line: uint32(line), Character: uint32(col + len("res://theme.tres"))},
+	}
+	if got := targets[pathToURI(filepath.Join(dir, "theme.tres"))]; got != want {
+		t.Errorf("expected range %v, got %v", want, got)
+	}
+}
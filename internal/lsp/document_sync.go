@@ -1,8 +1,11 @@
 package lsp
 
 import (
+	"github.com/sourcegraph/jsonrpc2"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
 )
 
 // textDocumentDidOpen handles the textDocument/didOpen notification.
@@ -11,10 +14,11 @@ func (s *Server) textDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpen
 	content := params.TextDocument.Text
 
 	// Store the document and parse it
-	doc := s.workspace.OpenDocument(uri, content)
+	doc := s.workspace.OpenDocumentVersion(uri, content, int(params.TextDocument.Version))
 
 	// Publish diagnostics
 	s.publishDiagnostics(ctx, uri, doc)
+	s.refreshShaderDependents(ctx, uri)
 
 	return nil
 }
@@ -24,28 +28,52 @@ func (s *Server) textDocumentDidChange(ctx *glsp.Context, params *protocol.DidCh
 	uri := params.TextDocument.URI
 
 	// With full sync, we get the complete new content
-	if len(params.ContentChanges) > 0 {
-		// The last change contains the full content in full sync mode
-		// ContentChanges is []any in glsp, need to type assert
-		lastChange := params.ContentChanges[len(params.ContentChanges)-1]
-		if change, ok := lastChange.(protocol.TextDocumentContentChangeEventWhole); ok {
-			doc := s.workspace.UpdateDocument(uri, change.Text)
-			s.publishDiagnostics(ctx, uri, doc)
-		} else if changeMap, ok := lastChange.(map[string]any); ok {
-			// Fallback for when it comes as a map
-			if text, ok := changeMap["text"].(string); ok {
-				doc := s.workspace.UpdateDocument(uri, text)
-				s.publishDiagnostics(ctx, uri, doc)
-			}
+	if len(params.ContentChanges) == 0 {
+		return nil
+	}
+	// The last change contains the full content in full sync mode
+	// ContentChanges is []any in glsp, need to type assert
+	var text string
+	switch change := params.ContentChanges[len(params.ContentChanges)-1].(type) {
+	case protocol.TextDocumentContentChangeEventWhole:
+		text = change.Text
+	case map[string]any:
+		// Fallback for when it comes as a map
+		var ok bool
+		if text, ok = change["text"].(string); !ok {
+			return nil
 		}
+	default:
+		return nil
+	}
+
+	// A change older than the stored document is dropped rather than
+	// undoing a newer one
+	if _, err := s.workspace.SetDocumentContent(uri, text, int(params.TextDocument.Version)); err != nil {
+		return err
 	}
 
+	// Parsing and diagnostics are deferred until edits settle
+	s.cancelDocumentWork(uri)
+	s.scheduleDiagnostics(ctx, uri)
+	s.refreshShaderDependents(ctx, uri)
 	return nil
 }
 
+// checkCurrent returns a content modified error when doc was replaced by a
+// newer version while a request worked on it. Requests returning edits
+// check it, so that the client never applies edits to the wrong content.
+func (s *Server) checkCurrent(doc *analysis.Document) error {
+	if s.workspace.IsCurrent(doc) {
+		return nil
+	}
+	return &jsonrpc2.Error{Code: codeContentModified, Message: "content modified"}
+}
+
 // textDocumentDidClose handles the textDocument/didClose notification.
 func (s *Server) textDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
 	uri := params.TextDocument.URI
+	s.cancelDocumentWork(uri)
 	s.workspace.CloseDocument(uri)
 
 	// Clear diagnostics for the closed document
@@ -54,6 +82,8 @@ func (s *Server) textDocumentDidClose(ctx *glsp.Context, params *protocol.DidClo
 		Diagnostics: []protocol.Diagnostic{},
 	})
 
+	// Shaders including it see the file on disk again
+	s.refreshShaderDependents(ctx, uri)
 	return nil
 }
 
@@ -62,8 +92,10 @@ func (s *Server) textDocumentDidSave(ctx *glsp.Context, params *protocol.DidSave
 	// Re-parse if text is included
 	if params.Text != nil {
 		uri := params.TextDocument.URI
+		s.cancelDocumentWork(uri)
 		doc := s.workspace.UpdateDocument(uri, *params.Text)
 		s.publishDiagnostics(ctx, uri, doc)
+		s.refreshShaderDependents(ctx, uri)
 	}
 	return nil
 }
@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "willRenameFiles",
+		register: func(s *Server, h *protocol.Handler) {
+			h.WorkspaceWillRenameFiles = s.workspaceWillRenameFiles
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			if caps.Workspace == nil {
+				caps.Workspace = &protocol.ServerCapabilitiesWorkspace{}
+			}
+			if caps.Workspace.FileOperations == nil {
+				caps.Workspace.FileOperations = &protocol.ServerCapabilitiesWorkspaceFileOperations{}
+			}
+			// Any file or folder can be loaded by a scene
+			caps.Workspace.FileOperations.WillRename = &protocol.FileOperationRegistrationOptions{
+				Filters: []protocol.FileOperationFilter{{
+					Scheme:  strPtr("file"),
+					Pattern: protocol.FileOperationPattern{Glob: "**/*"},
+				}},
+			}
+		},
+	})
+}
+
+// resRename is a file or folder moved from one res:// path to another.
+type resRename struct {
+	root     string // Project root both paths are relative to
+	from, to string
+}
+
+// workspaceWillRenameFiles handles the workspace/willRenameFiles request,
+// sent before the client renames or moves files. The ext_resource paths of
+// the scenes and resources of the project that load a renamed file, or a
+// file inside a renamed folder, are rewritten to the new path.
+func (s *Server) workspaceWillRenameFiles(ctx *glsp.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	var renames []resRename
+	for _, file := range params.Files {
+		root := s.findProjectRoot(file.OldURI)
+		if root == "" {
+			continue
+		}
+		from, okFrom := analysis.ResPath(root, uriToPath(file.OldURI))
+		to, okTo := analysis.ResPath(root, uriToPath(file.NewURI))
+		if okFrom && okTo && from != to {
+			renames = append(renames, resRename{root: root, from: from, to: to})
+		}
+	}
+	if len(renames) == 0 {
+		return nil, nil
+	}
+
+	changes := make(map[protocol.DocumentUri][]protocol.TextEdit)
+	for _, uri := range s.workspace.Files() {
+		if analysis.GetDocumentType(uri) != analysis.DocumentTypeTSCN {
+			continue
+		}
+		ast := s.loadScene(uri)
+		if ast == nil {
+			continue
+		}
+
+		path := uriToPath(uri)
+		for _, ext := range ast.ExtResources {
+			for _, rename := range renames {
+				if !strings.HasPrefix(path, rename.root+string(filepath.Separator)) {
+					continue // Another project's res:// paths
+				}
+				if newPath, ok := rename.apply(ext.Path); ok {
+					changes[uri] = append(changes[uri], protocol.TextEdit{
+						Range:   toProtocolRange(stringContentRange(ext.PathRange, 0, len(ext.Path))),
+						NewText: newPath,
+					})
+					break
+				}
+			}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return &protocol.WorkspaceEdit{Changes: changes}, nil
+}
+
+// apply returns the path a res:// path has after the rename: the new path
+// for the renamed file, or the path under the new folder for a file inside
+// a renamed folder.
+func (r resRename) apply(path string) (string, bool) {
+	if path == r.from {
+		return r.to, true
+	}
+	if rest, ok := strings.CutPrefix(path, r.from+"/"); ok {
+		return r.to + "/" + rest, true
+	}
+	return "", false
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestWillRenameFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+
+	write("project.godot", "config_version=5\n")
+	write("player/player.gd", "extends Node\n")
+	write("player/icon.png", "")
+	mainURI := write("main.tscn", `[gd_scene format=3]
+
+[ext_resource type="Script" path="res://player/player.gd" id="1_script"]
+[ext_resource type="Texture2D" path="res://player/icon.png" id="2_icon"]
+[ext_resource type="Texture2D" path="res://player_icon.png" id="3_other"]
+
+[node name="Main" type="Node"]
+`)
+	materialURI := write("materials/hero.tres", `[gd_resource type="Material" format=3]
+
+[ext_resource type="Script" path="res://player/player.gd" id="1_script"]
+
+[resource]
+`)
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	rename := func(from, to string) *protocol.WorkspaceEdit {
+		t.Helper()
+		edit, err := s.workspaceWillRenameFiles(&glsp.Context{}, &protocol.RenameFilesParams{
+			Files: []protocol.FileRename{{
+				OldURI: pathToURI(filepath.Join(dir, from)),
+				NewURI: pathToURI(filepath.Join(dir, to)),
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return edit
+	}
+
+	// Renaming a file rewrites every ext_resource loading it
+	edit := rename("player/player.gd", "player/hero.gd")
+	if edit == nil || len(edit.Changes) != 2 {
+		t.Fatalf("expected edits to both files, got %+v", edit)
+	}
+	for _, uri := range []string{mainURI, materialURI} {
+		edits := edit.Changes[uri]
+		if len(edits) != 1 || edits[0].NewText != "res://player/hero.gd" || edits[0].Range.Start.Line != 2 {
+			t.Errorf("expected the script path of %s to be rewritten, got %+v", uri, edits)
+		}
+	}
+	if r := edit.Changes[mainURI][0].Range; r.Start.Character != 34 || r.End.Character != 56 {
+		t.Errorf("expected the edit to cover the path inside the quotes, got %+v", r)
+	}
+
+	// Moving a folder rewrites the paths of the files inside it only
+	edit = rename("player", "characters/player")
+	if edits := edit.Changes[mainURI]; len(edits) != 2 ||
+		edits[0].NewText != "res://characters/player/player.gd" || edits[1].NewText != "res://characters/player/icon.png" {
+		t.Errorf("expected the paths under player/ to move, got %+v", edits)
+	}
+
+	// Files nothing loads need no edits
+	if edit := rename("unused.gd", "other.gd"); edit != nil {
+		t.Errorf("expected no edit, got %+v", edit)
+	}
+}
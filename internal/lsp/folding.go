@@ -5,6 +5,18 @@ import (
 	protocol "github.com/tliron/glsp/protocol_3_16"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "foldingRange",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentFoldingRange = s.textDocumentFoldingRange
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.FoldingRangeProvider = &protocol.FoldingRangeOptions{}
+		},
+	})
+}
+
 // textDocumentFoldingRange handles the textDocument/foldingRange request.
 func (s *Server) textDocumentFoldingRange(ctx *glsp.Context, params *protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
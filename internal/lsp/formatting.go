@@ -0,0 +1,58 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "formatting",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentFormatting = s.textDocumentFormatting
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DocumentFormattingProvider = &protocol.DocumentFormattingOptions{}
+		},
+	})
+}
+
+// textDocumentFormatting handles the textDocument/formatting request with
+// the formatters of `gdls fmt`, replacing the whole document when it
+// changes.
+func (s *Server) textDocumentFormatting(ctx *glsp.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+
+	var formatted string
+	switch doc.Type {
+	case analysis.DocumentTypeTSCN:
+		formatted = parser.Format(doc.Content, parser.FormatOptions{AlignProperties: s.currentSettings().AlignProperties})
+	case analysis.DocumentTypeGDShader:
+		formatted = gdshader.Format(doc.Content)
+	default:
+		return nil, nil
+	}
+	if formatted == doc.Content {
+		return []protocol.TextEdit{}, nil
+	}
+
+	if err := s.checkCurrent(doc); err != nil {
+		return nil, err
+	}
+
+	line, character := doc.OffsetToPosition(len(doc.Content))
+	return []protocol.TextEdit{{
+		Range: protocol.Range{
+			Start: protocol.Position{Line: 0, Character: 0},
+			End:   protocol.Position{Line: line, Character: character},
+		},
+		NewText: formatted,
+	}}, nil
+}
@@ -0,0 +1,40 @@
+package lsp
+
+import (
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestFormattingScene(t *testing.T) {
+	content := "[gd_scene format=3]\n[node name=\"Root\"  type=\"Label\"]\ntext=\"Hi\"\nvisible = false"
+	s := NewServer("test", "test")
+	s.settings.AlignProperties = true
+	uri := "file:///tmp/format.tscn"
+	s.workspace.OpenDocument(uri, content)
+
+	edits, err := s.textDocumentFormatting(nil, &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) != 1 {
+		t.Fatalf("expected one edit, got %+v", edits)
+	}
+	want := "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Label\"]\ntext    = \"Hi\"\nvisible = false\n"
+	if edits[0].NewText != want {
+		t.Errorf("unexpected formatting:\n%q\nexpected:\n%q", edits[0].NewText, want)
+	}
+	if end := edits[0].Range.End; end.Line != 3 || end.Character != 15 {
+		t.Errorf("expected the edit to end at 3:15, got %+v", end)
+	}
+
+	s.workspace.UpdateDocument(uri, want)
+	edits, _ = s.textDocumentFormatting(nil, &protocol.DocumentFormattingParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if len(edits) != 0 {
+		t.Errorf("expected no edits for a formatted document, got %+v", edits)
+	}
+}
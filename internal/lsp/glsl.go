@@ -0,0 +1,127 @@
+package lsp
+
+import (
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/glsl"
+)
+
+// GLSL files hold the RenderingDevice shaders of a project, compute shaders
+// above all. Godot compiles them with glslang, so only their syntax is
+// looked at: their outline, bracket errors and highlighting.
+
+// glslDocumentSymbols returns the document symbols of a GLSL document: its
+// sections, holding their declarations, and the members of structs and
+// blocks.
+func (s *Server) glslDocumentSymbols(doc *analysis.Document) (any, error) {
+	if doc.GLSLAST == nil {
+		return nil, nil
+	}
+
+	symbols := []protocol.DocumentSymbol{}
+	sections := make(map[*glsl.Section]int) // Index of the symbol of each section
+	for _, section := range doc.GLSLAST.Sections {
+		sections[section] = len(symbols)
+		symbols = append(symbols, protocol.DocumentSymbol{
+			Name:           "#[" + section.Name + "]",
+			Detail:         strPtr("section"),
+			Kind:           protocol.SymbolKindNamespace,
+			Range:          toProtocolShaderRange(section.Range),
+			SelectionRange: toProtocolShaderRange(section.TagRange),
+		})
+	}
+	for _, decl := range doc.GLSLAST.Declarations {
+		sym := glslDeclarationSymbol(decl)
+		if i, ok := sections[decl.Section]; ok {
+			symbols[i].Children = append(symbols[i].Children, sym)
+		} else {
+			symbols = append(symbols, sym)
+		}
+	}
+	return symbols, nil
+}
+
+// glslDeclarationSymbol returns the symbol of a GLSL declaration and its
+// members.
+func glslDeclarationSymbol(decl *glsl.Declaration) protocol.DocumentSymbol {
+	kind := protocol.SymbolKindVariable
+	switch decl.Kind {
+	case glsl.DeclarationFunction:
+		kind = protocol.SymbolKindFunction
+	case glsl.DeclarationStruct:
+		kind = protocol.SymbolKindStruct
+	case glsl.DeclarationBlock:
+		kind = protocol.SymbolKindObject
+	case glsl.DeclarationConstant:
+		kind = protocol.SymbolKindConstant
+	}
+	sym := protocol.DocumentSymbol{
+		Name:           decl.Name,
+		Kind:           kind,
+		Range:          toProtocolShaderRange(decl.Range),
+		SelectionRange: toProtocolShaderRange(decl.NameRange),
+	}
+	if decl.Detail != "" {
+		sym.Detail = strPtr(decl.Detail)
+	}
+	for _, member := range decl.Members {
+		child := glslDeclarationSymbol(member)
+		child.Kind = protocol.SymbolKindField
+		sym.Children = append(sym.Children, child)
+	}
+	return sym
+}
+
+// glslDiagnostics returns the syntax errors of a GLSL document.
+func (s *Server) glslDiagnostics(doc *analysis.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	if doc.GLSLAST == nil {
+		return diagnostics
+	}
+	for _, err := range doc.GLSLAST.Errors {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolShaderRange(err.Range),
+			Severity: severityPtr(protocol.DiagnosticSeverityError),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeParseError),
+			Message:  err.Message,
+		})
+	}
+	return diagnostics
+}
+
+// glslTokenTypes maps the highlights of GLSL documents to semantic token
+// types.
+var glslTokenTypes = map[glsl.HighlightKind]uint32{
+	glsl.HighlightKeyword:   tokenTypeKeyword,
+	glsl.HighlightDirective: tokenTypeKeyword,
+	glsl.HighlightType:      tokenTypeType,
+	glsl.HighlightFunction:  tokenTypeFunction,
+	glsl.HighlightVariable:  tokenTypeVariable,
+	glsl.HighlightProperty:  tokenTypeProperty,
+	glsl.HighlightNumber:    tokenTypeNumber,
+	glsl.HighlightString:    tokenTypeString,
+	glsl.HighlightComment:   tokenTypeComment,
+}
+
+// glslSemanticTokens returns the semantic tokens of a GLSL document.
+func glslSemanticTokens(doc *analysis.Document) []semanticToken {
+	if doc.GLSLAST == nil {
+		return nil
+	}
+	tokens := make([]semanticToken, 0, len(doc.GLSLAST.Highlights))
+	for _, h := range doc.GLSLAST.Highlights {
+		tok := semanticToken{
+			line:      uint32(h.Range.Start.Line),
+			startChar: uint32(h.Range.Start.Column),
+			length:    uint32(h.Range.End.Column - h.Range.Start.Column),
+			tokenType: glslTokenTypes[h.Kind],
+		}
+		if h.Declaration {
+			tok.modifiers = 1 << 0 // declaration
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
@@ -0,0 +1,95 @@
+package lsp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const computeShader = `#[compute]
+#version 450
+
+layout(local_size_x = 64) in;
+
+layout(set = 0, binding = 0, std430) restrict buffer DataBuffer {
+	float values[];
+} data;
+
+void main() {
+	data.values[gl_GlobalInvocationID.x] *= 2.0;
+}
+`
+
+func TestGLSLDocumentSymbols(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/compute.glsl"
+	s.workspace.OpenDocument(uri, computeShader)
+
+	result, err := s.textDocumentDocumentSymbol(&glsp.Context{}, &protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, _ := result.([]protocol.DocumentSymbol)
+
+	var render func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder)
+	render = func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder) {
+		for _, sym := range syms {
+			b.WriteString(strings.Repeat("  ", depth) + sym.Name)
+			if sym.Detail != nil {
+				b.WriteString(" " + *sym.Detail)
+			}
+			b.WriteString("\n")
+			render(sym.Children, depth+1, b)
+		}
+	}
+	var b strings.Builder
+	render(symbols, 0, &b)
+	expected := `#[compute] section
+  data layout(set = 0, binding = 0, std430) restrict buffer DataBuffer
+    values float[]
+  main void ()
+`
+	if b.String() != expected {
+		t.Errorf("expected symbols:\n%s\ngot:\n%s", expected, b.String())
+	}
+	if main := symbols[0].Children[1]; main.Kind != protocol.SymbolKindFunction || main.SelectionRange.Start != (protocol.Position{Line: 9, Character: 5}) {
+		t.Errorf("unexpected main symbol: %+v", main)
+	}
+}
+
+func TestGLSLDiagnosticsAndSemanticTokens(t *testing.T) {
+	s := NewServer("test", "test")
+	uri := "file:///tmp/compute.glsl"
+	doc := s.workspace.OpenDocument(uri, strings.Replace(computeShader, "2.0;\n}", "2.0;\n", 1))
+
+	diagnostics := s.computeDiagnostics(doc)
+	if len(diagnostics) != 1 || diagnostics[0].Message != "'{' is never closed" || diagnostics[0].Range.Start.Line != 9 {
+		t.Errorf("expected the body of main to be reported unclosed, got %+v", diagnostics)
+	}
+
+	tokens, err := s.textDocumentSemanticTokensFull(&glsp.Context{}, &protocol.SemanticTokensParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil || tokens == nil {
+		t.Fatalf("expected semantic tokens, got %v, %v", tokens, err)
+	}
+	// The first tokens are the section tag, the #version directive and the
+	// layout keyword, each on its own line
+	want := []uint32{
+		0, 0, 10, tokenTypeKeyword, 0,
+		1, 0, 12, tokenTypeKeyword, 0,
+		2, 0, 6, tokenTypeKeyword, 0,
+	}
+	if len(tokens.Data) < len(want) {
+		t.Fatalf("expected at least %d values, got %v", len(want), tokens.Data)
+	}
+	for i, v := range want {
+		if tokens.Data[i] != v {
+			t.Fatalf("expected data to start with %v, got %v", want, tokens.Data[:len(want)])
+		}
+	}
+}
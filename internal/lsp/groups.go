@@ -0,0 +1,134 @@
+package lsp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// GroupMembersMethod is the request an extension sends to list the nodes of
+// the project in a group, e.g. to find every node get_tree().call_group()
+// reaches.
+const GroupMembersMethod = "gdls/groupMembers"
+
+// maxGroupHoverMembers bounds the members listed by the hover of a group.
+const maxGroupHoverMembers = 20
+
+func init() {
+	registerCapability(&capability{
+		name: "groupMembers",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[GroupMembersMethod] = customRequest(s.groupMembers)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["groupMembers"] = true
+		},
+	})
+	registerCapability(&capability{
+		name: "workspaceSymbol",
+		register: func(s *Server, h *protocol.Handler) {
+			h.WorkspaceSymbol = s.workspaceSymbol
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.WorkspaceSymbolProvider = true
+		},
+	})
+}
+
+// groupMembersParams are the parameters of the gdls/groupMembers request.
+type groupMembersParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"` // Any document of the project
+	Group        string                          `json:"group,omitempty"`
+}
+
+// groupMember is a node of the gdls/groupMembers result.
+type groupMember struct {
+	Group string         `json:"group"`
+	URI   string         `json:"uri"`
+	Range protocol.Range `json:"range"` // The group string in the node header
+	Path  string         `json:"path"`  // Node path from the root, "." for the root
+	Name  string         `json:"name"`
+	Type  string         `json:"type,omitempty"`
+}
+
+// groupMembers handles the gdls/groupMembers request, returning the nodes of
+// the project that belong to the requested group, or to any group when none
+// is given, sorted by group and scene.
+func (s *Server) groupMembers(ctx *glsp.Context, params *groupMembersParams) (any, error) {
+	result := []groupMember{}
+	for _, m := range s.workspace.Groups(params.TextDocument.URI) {
+		if params.Group != "" && m.Group != params.Group {
+			continue
+		}
+		result = append(result, groupMember{
+			Group: m.Group,
+			URI:   m.URI,
+			Range: toProtocolRange(m.Range),
+			Path:  m.Path,
+			Name:  m.Name,
+			Type:  m.Type,
+		})
+	}
+	return result, nil
+}
+
+// workspaceSymbol handles the workspace/symbol request, returning the group
+// memberships of the nodes of every project whose group name contains the
+// query, ignoring case.
+func (s *Server) workspaceSymbol(ctx *glsp.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	query := strings.ToLower(params.Query)
+	symbols := []protocol.SymbolInformation{}
+	for _, m := range s.workspace.Groups("") {
+		if !strings.Contains(strings.ToLower(m.Group), query) {
+			continue
+		}
+		container := fmt.Sprintf("%s in %s", groupMemberName(m), filepath.Base(uriToPath(m.URI)))
+		symbols = append(symbols, protocol.SymbolInformation{
+			Name:          m.Group,
+			Kind:          protocol.SymbolKindEnumMember,
+			Location:      protocol.Location{URI: m.URI, Range: toProtocolRange(m.Range)},
+			ContainerName: &container,
+		})
+	}
+	return symbols, nil
+}
+
+// formatGroupHover lists the nodes of the project of the document at uri
+// that belong to a group.
+func (s *Server) formatGroupHover(uri, group string) string {
+	members := s.workspace.GroupMembers(uri, group)
+	scenes := make(map[string]bool)
+	for _, m := range members {
+		scenes[m.URI] = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Group: `%s`\n\n", group))
+	sb.WriteString(fmt.Sprintf("**Members:** %s in %s\n\n",
+		plural(len(members), "node", "nodes"), plural(len(scenes), "scene", "scenes")))
+	for i, m := range members {
+		if i == maxGroupHoverMembers {
+			sb.WriteString(fmt.Sprintf("- _and %d more_\n", len(members)-i))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("- `%s`", groupMemberName(m)))
+		if m.Type != "" {
+			sb.WriteString(fmt.Sprintf(" (`%s`)", m.Type))
+		}
+		sb.WriteString(fmt.Sprintf(" in `%s`\n", filepath.Base(uriToPath(m.URI))))
+	}
+	return sb.String()
+}
+
+// groupMemberName is the node path of a member, or its name for the root.
+func groupMemberName(m analysis.GroupMember) string {
+	if m.Path == "." {
+		return m.Name
+	}
+	return m.Path
+}
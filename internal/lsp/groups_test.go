@@ -0,0 +1,106 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestGroups(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+
+	content := `[gd_scene format=3]
+
+[node name="Level" type="Node2D"]
+
+[node name="Goblin" type="CharacterBody2D" parent="." groups=["enemies"]]
+`
+	write("project.godot", "config_version=5\n")
+	uri := write("level.tscn", `[gd_scene format=3]
+
+[node name="Level" type="Node2D"]
+`)
+	write("boss.tscn", `[gd_scene format=3]
+
+[node name="Boss" type="CharacterBody2D" groups=["enemies", "bosses"]]
+
+[node name="Minion" type="Node2D" parent="Wave" groups=["enemies"]]
+`)
+	write("theme.tres", `[gd_resource type="Theme" format=3]
+`)
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	// The open document replaces what was read from disk
+	doc := s.workspace.OpenDocument(uri, content)
+
+	result, err := s.groupMembers(&glsp.Context{}, &groupMembersParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Group:        "enemies",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, m := range result.([]groupMember) {
+		paths = append(paths, filepath.Base(uriToPath(m.URI))+":"+m.Path)
+	}
+	if got := strings.Join(paths, " "); got != "boss.tscn:. boss.tscn:Wave/Minion level.tscn:Goblin" {
+		t.Errorf("unexpected members: %s", got)
+	}
+	if m := result.([]groupMember)[2]; m.Range.Start != (protocol.Position{Line: 4, Character: 62}) {
+		t.Errorf("expected the range of the group string, got %+v", m.Range)
+	}
+
+	hover := s.findTSCNHoverInfo(doc, 4, 65)
+	for _, want := range []string{
+		"### Group: `enemies`",
+		"**Members:** 3 nodes in 2 scenes",
+		"- `Boss` (`CharacterBody2D`) in `boss.tscn`",
+		"- `Wave/Minion` (`Node2D`) in `boss.tscn`",
+		"- `Goblin` (`CharacterBody2D`) in `level.tscn`",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("expected hover to contain %q, got:\n%s", want, hover)
+		}
+	}
+
+	symbols, err := s.workspaceSymbol(&glsp.Context{}, &protocol.WorkspaceSymbolParams{Query: "BOSS"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != 1 || symbols[0].Name != "bosses" || *symbols[0].ContainerName != "Boss in boss.tscn" {
+		t.Errorf("expected the bosses group, got %+v", symbols)
+	}
+
+	labels := completeIn(t, uri, `[gd_scene format=3]
+
+[node name="Level" type="Node2D" groups=["|"]]
+`)
+	if len(labels) != 0 {
+		t.Errorf("expected no groups without an index, got %v", labels)
+	}
+	items := s.getGroupCompletions(doc, true)
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label+" ("+*item.Detail+")")
+	}
+	if got := strings.Join(names, ", "); got != "enemies (Group, 3 nodes), bosses (Group, 1 node)" {
+		t.Errorf("unexpected group completions: %s", got)
+	}
+}
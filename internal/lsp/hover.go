@@ -2,6 +2,9 @@ package lsp
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/tliron/glsp"
@@ -9,9 +12,22 @@ import (
 
 	"github.com/andresperezl/gdls/internal/analysis"
 	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/godotdoc"
 	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "hover",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentHover = s.textDocumentHover
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.HoverProvider = &protocol.HoverOptions{}
+		},
+	})
+}
+
 // textDocumentHover handles the textDocument/hover request.
 func (s *Server) textDocumentHover(ctx *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
@@ -35,6 +51,11 @@ func (s *Server) textDocumentHover(ctx *glsp.Context, params *protocol.HoverPara
 			return nil, nil
 		}
 		hoverInfo = s.findGDShaderHoverInfo(doc, line, col)
+	case analysis.DocumentTypeConfig:
+		if doc.ConfigAST == nil {
+			return nil, nil
+		}
+		hoverInfo = s.findConfigHoverInfo(doc, line, col)
 	default:
 		return nil, nil
 	}
@@ -55,80 +76,194 @@ func (s *Server) textDocumentHover(ctx *glsp.Context, params *protocol.HoverPara
 func (s *Server) findTSCNHoverInfo(doc *analysis.Document, line, col int) string {
 	ast := doc.TSCNAST
 
-	// Check external resources
-	for _, ext := range ast.ExtResources {
-		if isInRange(ext.Range, line, col) {
-			return formatExtResourceHover(ext)
-		}
+	// Check SubResource references before the properties holding them
+	if sub := subResourceRefAt(doc, line, col); sub != nil {
+		return formatSubResourceHover(sub, doc.Content)
 	}
 
-	// Check sub resources
-	for _, sub := range ast.SubResources {
-		if isInRange(sub.Range, line, col) {
-			// Check if we're on a specific property
-			for _, prop := range sub.Properties {
-				if isInRange(prop.Range, line, col) {
-					return formatPropertyHover(prop, sub.Type)
-				}
-			}
-			return formatSubResourceHover(sub)
+	// Check node paths before the properties and connections holding them
+	tree := analysis.NewSceneTree(ast)
+	if np := nodePathAt(doc, tree, line, col); np != nil {
+		targetURI, target := s.resolveNodePath(doc.URI, ast, tree, np.base, np.path)
+		scene := ""
+		if target != nil && targetURI != doc.URI {
+			scene = filepath.Base(uriToPath(targetURI))
 		}
+		return formatNodePathHover(np.path, target, scene)
 	}
 
-	// Check nodes
-	for _, node := range ast.Nodes {
-		if isInRange(node.Range, line, col) {
-			// Check if we're on a specific property
-			for _, prop := range node.Properties {
-				if isInRange(prop.Range, line, col) {
-					return formatPropertyHover(prop, node.Type)
+	// Describe the section or descriptor at the position, or the property
+	// of the section the position is on
+	var prop *parser.Property
+	for _, elem := range doc.Positions.At(line, col) {
+		switch elem := elem.(type) {
+		case *parser.Property:
+			if prop == nil {
+				prop = elem
+			}
+		case *parser.ExtResource:
+			loadPath := s.extResourcePath(doc.URI, elem)
+			return formatExtResourceHover(elem, loadPath, s.extResourcePreview(doc.URI, elem, loadPath))
+		case *parser.SubResource:
+			if prop != nil {
+				return formatPropertyHover(prop, elem.Type)
+			}
+			return formatSubResourceHover(elem, doc.Content)
+		case *parser.Node:
+			if prop != nil {
+				return formatPropertyHover(prop, elem.Type)
+			}
+			for i, r := range elem.GroupRanges {
+				if isInRange(r, line, col) {
+					return s.formatGroupHover(doc.URI, elem.Groups[i])
 				}
 			}
-			return formatNodeHover(node, doc)
-		}
-	}
-
-	// Check connections
-	for _, conn := range ast.Connections {
-		if isInRange(conn.Range, line, col) {
-			return formatConnectionHover(conn)
+			return formatNodeHover(elem, doc)
+		case *parser.Connection:
+			return formatConnectionHover(elem)
+		case *parser.GdScene:
+			return formatDescriptorHover(elem, ast)
 		}
 	}
 
-	// Check descriptor
-	if ast.Descriptor != nil && isInRange(ast.Descriptor.Range, line, col) {
-		return formatDescriptorHover(ast.Descriptor)
-	}
-
 	return ""
 }
 
-func formatExtResourceHover(ext *parser.ExtResource) string {
+// formatExtResourceHover describes an ext_resource; loadPath is the path
+// Godot loads, which differs from ext.Path when the uid maps elsewhere, and
+// preview summarizes the file it points to, if any.
+func formatExtResourceHover(ext *parser.ExtResource, loadPath, preview string) string {
 	var sb strings.Builder
 	sb.WriteString("### External Resource\n\n")
 	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", ext.Type))
+	writeClassDoc(&sb, ext.Type)
 	sb.WriteString(fmt.Sprintf("**Path:** `%s`\n\n", ext.Path))
+	if loadPath != ext.Path {
+		sb.WriteString(fmt.Sprintf("**Loads:** `%s` (from the uid)\n\n", loadPath))
+	}
 	sb.WriteString(fmt.Sprintf("**ID:** `%s`\n\n", ext.ID))
 	if ext.UID != "" {
-		sb.WriteString(fmt.Sprintf("**UID:** `%s`\n", ext.UID))
+		sb.WriteString(fmt.Sprintf("**UID:** `%s`\n\n", ext.UID))
 	}
+	sb.WriteString(preview)
 	return sb.String()
 }
 
-func formatSubResourceHover(sub *parser.SubResource) string {
+// maxScriptPreviewSize is the size of the scripts read for the hover of an
+// ext_resource; class_name and extends come first.
+const maxScriptPreviewSize = 1 << 20
+
+// extResourcePreview summarizes the file an ext_resource loads from
+// loadPath: the root node and children of a scene, or the class_name and
+// extends of a script. It returns "" for other files or when the file
+// cannot be read.
+func (s *Server) extResourcePreview(uri string, ext *parser.ExtResource, loadPath string) string {
+	loc := s.resolveResourcePath(loadPath, uri)
+	if loc == nil {
+		return ""
+	}
+
+	switch {
+	case ext.Type == "PackedScene" || analysis.GetDocumentType(loc.URI) == analysis.DocumentTypeTSCN:
+		root := analysis.NewSceneTree(s.loadScene(loc.URI)).Root
+		if root == nil {
+			return ""
+		}
+		var sb strings.Builder
+		sb.WriteString("---\n\n")
+		if root.Node.Type != "" {
+			sb.WriteString(fmt.Sprintf("**Root:** `%s` (`%s`)\n\n", root.Node.Name, root.Node.Type))
+		} else {
+			sb.WriteString(fmt.Sprintf("**Root:** `%s`\n\n", root.Node.Name))
+		}
+		sb.WriteString(fmt.Sprintf("**Children:** %d\n", len(root.Children)))
+		return sb.String()
+
+	case ext.Type == "Script" || strings.HasSuffix(loc.URI, ".gd"):
+		path := uriToPath(loc.URI)
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > maxScriptPreviewSize {
+			return ""
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		className, extends := analysis.GDScriptHeader(string(content))
+		if className == "" && extends == "" {
+			return ""
+		}
+		var sb strings.Builder
+		sb.WriteString("---\n\n")
+		if className != "" {
+			sb.WriteString(fmt.Sprintf("**Class:** `%s`\n\n", className))
+		}
+		if extends != "" {
+			sb.WriteString(fmt.Sprintf("**Extends:** `%s`\n", extends))
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// subResourceRefAt returns the sub_resource referenced by the
+// SubResource("...") at the position, in the properties of any section.
+func subResourceRefAt(doc *analysis.Document, line, col int) *parser.SubResource {
+	ref, ok := analysis.ElementAt[*parser.ResourceRef](doc.Positions, line, col)
+	if !ok || ref.RefType != "SubResource" {
+		return nil
+	}
+	if _, ok := analysis.ElementAt[*parser.Property](doc.Positions, line, col); !ok {
+		return nil
+	}
+	for _, sub := range doc.TSCNAST.SubResources {
+		if sub.ID == ref.ID {
+			return sub
+		}
+	}
+	return nil
+}
+
+// maxPreviewProperties is the number of properties listed in the hover of
+// a sub_resource.
+const maxPreviewProperties = 8
+
+// formatSubResourceHover describes a sub_resource with its properties as
+// written in content, so that hovering a reference shows what it holds.
+func formatSubResourceHover(sub *parser.SubResource, content string) string {
 	var sb strings.Builder
 	sb.WriteString("### Internal Resource\n\n")
 	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", sub.Type))
 	sb.WriteString(fmt.Sprintf("**ID:** `%s`\n\n", sub.ID))
-
-	// Add type description if available
-	if desc := getGodotTypeDescription(sub.Type); desc != "" {
-		sb.WriteString(fmt.Sprintf("_%s_\n", desc))
+	if len(sub.Properties) > 0 {
+		sb.WriteString("**Properties:**\n")
+		for i, prop := range sub.Properties {
+			if i == maxPreviewProperties {
+				sb.WriteString(fmt.Sprintf("- ... and %d more\n", len(sub.Properties)-i))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("- `%s = %s`\n", prop.Key, valueText(prop.Value, content)))
+		}
+		sb.WriteString("\n")
 	}
-
+	writeClassDoc(&sb, sub.Type)
 	return sb.String()
 }
 
+// valueText returns a value as written in content, on one line and cut to
+// fit a hover.
+func valueText(v parser.Value, content string) string {
+	r := v.GetRange()
+	if r.Start.Offset < 0 || r.End.Offset > len(content) || r.Start.Offset >= r.End.Offset {
+		return formatValuePreview(v)
+	}
+	text := strings.Join(strings.Fields(content[r.Start.Offset:r.End.Offset]), " ")
+	if len(text) > 60 {
+		return text[:57] + "..."
+	}
+	return text
+}
+
 func formatNodeHover(node *parser.Node, doc *analysis.Document) string {
 	var sb strings.Builder
 	sb.WriteString("### Scene Node\n\n")
@@ -136,9 +271,7 @@ func formatNodeHover(node *parser.Node, doc *analysis.Document) string {
 
 	if node.Type != "" {
 		sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", node.Type))
-		if desc := getGodotTypeDescription(node.Type); desc != "" {
-			sb.WriteString(fmt.Sprintf("_%s_\n\n", desc))
-		}
+		writeClassDoc(&sb, node.Type)
 	} else if node.Instance != nil {
 		if ref, ok := node.Instance.(*parser.ResourceRef); ok {
 			// Find the external resource to get the path
@@ -168,19 +301,51 @@ func formatPropertyHover(prop *parser.Property, ownerType string) string {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("### Property: `%s`\n\n", prop.Key))
 
-	// Describe the value type
-	valueType := describeValueType(prop.Value)
-	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", valueType))
+	// Prefer the declared type of the property to the type of its value
+	info, class := godotdoc.Default().Property(ownerType, prop.Key)
+	if info != nil {
+		sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", info.Type))
+	} else {
+		sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", describeValueType(prop.Value)))
+	}
 
 	// Show a preview of the value
 	valuePreview := formatValuePreview(prop.Value)
 	if valuePreview != "" {
-		sb.WriteString(fmt.Sprintf("**Value:** `%s`\n", valuePreview))
+		sb.WriteString(fmt.Sprintf("**Value:** `%s`\n\n", valuePreview))
+	}
+
+	if info != nil {
+		if info.Default != "" {
+			sb.WriteString(fmt.Sprintf("**Default:** `%s`\n\n", info.Default))
+		}
+		if class.Name != ownerType {
+			sb.WriteString(fmt.Sprintf("**Declared in:** `%s`\n\n", class.Name))
+		}
+		if info.Description != "" {
+			sb.WriteString(info.Description + "\n")
+		}
 	}
 
 	return sb.String()
 }
 
+// writeClassDoc appends the class reference of a Godot type: its brief
+// description and inheritance chain.
+func writeClassDoc(sb *strings.Builder, typeName string) {
+	db := godotdoc.Default()
+	class := db.Class(typeName)
+	if class == nil {
+		return
+	}
+	if class.Brief != "" {
+		sb.WriteString(class.Brief + "\n\n")
+	}
+	if chain := db.Inheritance(typeName); len(chain) > 1 {
+		sb.WriteString(fmt.Sprintf("**Inherits:** `%s`\n\n", strings.Join(chain[1:], "` < `")))
+	}
+}
+
 func formatConnectionHover(conn *parser.Connection) string {
 	var sb strings.Builder
 	sb.WriteString("### Signal Connection\n\n")
@@ -188,10 +353,32 @@ func formatConnectionHover(conn *parser.Connection) string {
 	sb.WriteString(fmt.Sprintf("**From:** `%s`\n\n", conn.From))
 	sb.WriteString(fmt.Sprintf("**To:** `%s`\n\n", conn.To))
 	sb.WriteString(fmt.Sprintf("**Method:** `%s`\n", conn.Method))
+	if conn.Flags != nil {
+		sb.WriteString("\n")
+		sb.WriteString(formatConnectFlags(*conn.Flags))
+	}
 	return sb.String()
 }
 
-func formatDescriptorHover(desc *parser.GdScene) string {
+func formatNodePathHover(path string, target *analysis.SceneNode, scene string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### NodePath: `%s`\n\n", path))
+	if target == nil {
+		sb.WriteString("Does not resolve to a node in this scene\n")
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("**Resolves to:** `%s`", target.Path))
+	if target.Node.Type != "" {
+		sb.WriteString(fmt.Sprintf(" (`%s`)", target.Node.Type))
+	}
+	if scene != "" {
+		sb.WriteString(fmt.Sprintf(" in instanced scene `%s`", scene))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func formatDescriptorHover(desc *parser.GdScene, ast *parser.Document) string {
 	var sb strings.Builder
 	if desc.Type == "gd_scene" {
 		sb.WriteString("### Scene File\n\n")
@@ -199,6 +386,7 @@ func formatDescriptorHover(desc *parser.GdScene) string {
 		sb.WriteString("### Resource File\n\n")
 		if desc.ResourceType != "" {
 			sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", desc.ResourceType))
+			writeClassDoc(&sb, desc.ResourceType)
 		}
 	}
 	sb.WriteString(fmt.Sprintf("**Format:** `%d` (Godot 4.x)\n\n", desc.Format))
@@ -206,11 +394,112 @@ func formatDescriptorHover(desc *parser.GdScene) string {
 		sb.WriteString(fmt.Sprintf("**Load Steps:** `%d`\n\n", *desc.LoadSteps))
 	}
 	if desc.UID != "" {
-		sb.WriteString(fmt.Sprintf("**UID:** `%s`\n", desc.UID))
+		sb.WriteString(fmt.Sprintf("**UID:** `%s`\n\n", desc.UID))
 	}
+
+	writeSceneSummary(&sb, ast)
 	return sb.String()
 }
 
+// maxHoverDependencies caps the dependency list shown in the descriptor hover.
+const maxHoverDependencies = 20
+
+// writeSceneSummary appends at-a-glance stats about the whole file: the root
+// node, section counts and the external files it depends on.
+func writeSceneSummary(sb *strings.Builder, ast *parser.Document) {
+	sb.WriteString("---\n\n")
+
+	if len(ast.Nodes) > 0 {
+		root := ast.Nodes[0]
+		switch {
+		case root.Type != "":
+			sb.WriteString(fmt.Sprintf("**Root:** `%s` (`%s`)\n\n", root.Name, root.Type))
+		case root.Instance != nil:
+			sb.WriteString(fmt.Sprintf("**Root:** `%s` _(instance)_\n\n", root.Name))
+		default:
+			sb.WriteString(fmt.Sprintf("**Root:** `%s`\n\n", root.Name))
+		}
+
+		instances := 0
+		for _, node := range ast.Nodes {
+			if node.Instance != nil {
+				instances++
+			}
+		}
+		if instances > 0 {
+			sb.WriteString(fmt.Sprintf("**Nodes:** %d (%d instanced)\n\n", len(ast.Nodes), instances))
+		} else {
+			sb.WriteString(fmt.Sprintf("**Nodes:** %d\n\n", len(ast.Nodes)))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("**External Resources:** %d\n\n", len(ast.ExtResources)))
+
+	subTypes := make([]string, 0, len(ast.SubResources))
+	for _, sub := range ast.SubResources {
+		subTypes = append(subTypes, sub.Type)
+	}
+	sb.WriteString(fmt.Sprintf("**Sub-Resources:** %d%s\n\n", len(ast.SubResources), countByName(subTypes)))
+
+	if len(ast.Connections) > 0 {
+		sb.WriteString(fmt.Sprintf("**Connections:** %d\n\n", len(ast.Connections)))
+	}
+
+	// External dependencies, deduplicated by path
+	seen := make(map[string]bool)
+	var deps []*parser.ExtResource
+	for _, ext := range ast.ExtResources {
+		if ext.Path == "" || seen[ext.Path] {
+			continue
+		}
+		seen[ext.Path] = true
+		deps = append(deps, ext)
+	}
+	if len(deps) > 0 {
+		sb.WriteString("**Dependencies:**\n")
+		for i, ext := range deps {
+			if i == maxHoverDependencies {
+				sb.WriteString(fmt.Sprintf("- _... and %d more_\n", len(deps)-i))
+				break
+			}
+			if ext.Type != "" {
+				sb.WriteString(fmt.Sprintf("- `%s` (%s)\n", ext.Path, ext.Type))
+			} else {
+				sb.WriteString(fmt.Sprintf("- `%s`\n", ext.Path))
+			}
+		}
+	}
+}
+
+// countByName formats a breakdown like " (2 `BoxMesh`, 1 `StandardMaterial3D`)",
+// most frequent first, or "" for an empty list.
+func countByName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for _, name := range names {
+		if counts[name] == 0 {
+			order = append(order, name)
+		}
+		counts[name]++
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if counts[order[i]] != counts[order[j]] {
+			return counts[order[i]] > counts[order[j]]
+		}
+		return order[i] < order[j]
+	})
+
+	parts := make([]string, 0, len(order))
+	for _, name := range order {
+		parts = append(parts, fmt.Sprintf("%d `%s`", counts[name], name))
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
 func describeValueType(v parser.Value) string {
 	switch val := v.(type) {
 	case *parser.StringValue:
@@ -278,80 +567,6 @@ func isInRange(r parser.Range, line, col int) bool {
 	return true
 }
 
-// getGodotTypeDescription returns a brief description for common Godot types.
-func getGodotTypeDescription(typeName string) string {
-	descriptions := map[string]string{
-		// Nodes
-		"Node":                "Base class for all scene objects",
-		"Node2D":              "A 2D game object",
-		"Node3D":              "A 3D game object (formerly Spatial)",
-		"Control":             "Base class for all UI-related nodes",
-		"Camera2D":            "Camera node for 2D scenes",
-		"Camera3D":            "Camera node for 3D scenes",
-		"CharacterBody2D":     "2D physics body for character movement",
-		"CharacterBody3D":     "3D physics body for character movement",
-		"RigidBody2D":         "2D physics body with rigid body dynamics",
-		"RigidBody3D":         "3D physics body with rigid body dynamics",
-		"StaticBody2D":        "2D physics body that doesn't move",
-		"StaticBody3D":        "3D physics body that doesn't move",
-		"Area2D":              "2D area for detecting overlaps",
-		"Area3D":              "3D area for detecting overlaps",
-		"CollisionShape2D":    "2D collision shape for physics",
-		"CollisionShape3D":    "3D collision shape for physics",
-		"Sprite2D":            "2D sprite node",
-		"Sprite3D":            "3D sprite node",
-		"MeshInstance3D":      "Instance of a 3D mesh",
-		"AnimationPlayer":     "Node for playing animations",
-		"AnimationTree":       "Node for blending animations",
-		"AudioStreamPlayer":   "Plays audio non-positionally",
-		"AudioStreamPlayer2D": "Plays audio with 2D positioning",
-		"AudioStreamPlayer3D": "Plays audio with 3D positioning",
-		"Label":               "Displays text",
-		"Button":              "Clickable button",
-		"TextEdit":            "Multi-line text editor",
-		"LineEdit":            "Single-line text input",
-		"Timer":               "Counts down and emits timeout signal",
-		"Path2D":              "Contains a Curve2D path",
-		"Path3D":              "Contains a Curve3D path",
-		"PathFollow2D":        "Follows a Path2D",
-		"PathFollow3D":        "Follows a Path3D",
-		"Skeleton3D":          "3D skeleton for mesh deformation",
-		"BoneAttachment3D":    "Attaches nodes to skeleton bones",
-		"GPUParticles2D":      "2D GPU-accelerated particles",
-		"GPUParticles3D":      "3D GPU-accelerated particles",
-		"DirectionalLight3D":  "Directional light source",
-		"OmniLight3D":         "Omnidirectional point light",
-		"SpotLight3D":         "Spotlight",
-
-		// Resources
-		"BoxShape3D":         "3D box collision shape",
-		"SphereShape3D":      "3D sphere collision shape",
-		"CapsuleShape3D":     "3D capsule collision shape",
-		"CylinderShape3D":    "3D cylinder collision shape",
-		"BoxMesh":            "Box primitive mesh",
-		"SphereMesh":         "Sphere primitive mesh",
-		"CapsuleMesh":        "Capsule primitive mesh",
-		"CylinderMesh":       "Cylinder primitive mesh",
-		"PlaneMesh":          "Plane primitive mesh",
-		"ArrayMesh":          "Mesh from vertex arrays",
-		"StandardMaterial3D": "PBR material for 3D",
-		"ShaderMaterial":     "Custom shader material",
-		"Texture2D":          "2D texture resource",
-		"Animation":          "Animation resource",
-		"AnimationLibrary":   "Collection of animations",
-		"PackedScene":        "Serialized scene",
-		"Script":             "GDScript or other script",
-		"AudioStream":        "Audio data resource",
-		"Font":               "Font resource",
-		"Theme":              "UI theme resource",
-	}
-
-	if desc, ok := descriptions[typeName]; ok {
-		return desc
-	}
-	return ""
-}
-
 // findGDShaderHoverInfo finds hover information for a GDShader document position.
 func (s *Server) findGDShaderHoverInfo(doc *analysis.Document, line, col int) string {
 	ast := doc.ShaderAST
@@ -365,8 +580,17 @@ func (s *Server) findGDShaderHoverInfo(doc *analysis.Document, line, col int) st
 	}
 
 	// Check render_mode declaration
-	if ast.RenderModes != nil && isInGDShaderRange(ast.RenderModes.Range, line, col) {
-		return formatRenderModeHover(ast.RenderModes)
+	version := s.workspace.ShaderVersion()
+	if rm := ast.RenderModes; rm != nil && ast.ShaderType != nil {
+		shaderType := gdshader.ShaderType(ast.ShaderType.Type)
+		for i, r := range rm.ModeRanges {
+			if i < len(rm.Modes) && isInGDShaderRange(r, line, col) {
+				return formatRenderModeValueHover(rm.Modes[i], shaderType, version)
+			}
+		}
+		if isInGDShaderRange(rm.Range, line, col) {
+			return formatRenderModeHover(rm, gdshader.GetRenderModes(version, shaderType))
+		}
 	}
 
 	// Check uniforms
@@ -404,6 +628,11 @@ func (s *Server) findGDShaderHoverInfo(doc *analysis.Document, line, col int) st
 		}
 	}
 
+	// Check identifiers and expressions inside function bodies
+	if hoverInfo := findGDShaderExprHover(ast, s.analyzedShader(doc), version, line, col); hoverInfo != "" {
+		return hoverInfo
+	}
+
 	// Check for built-in function or constant at position
 	// This requires finding the identifier at the position
 	hoverInfo := s.findGDShaderBuiltinHover(doc, line, col)
@@ -414,6 +643,152 @@ func (s *Server) findGDShaderHoverInfo(doc *analysis.Document, line, col int) st
 	return ""
 }
 
+// analyzedShader returns the analysis of a shader for the Godot version
+// shaders are analyzed for, made once per version of the document. It is
+// shared by the requests on that version, which may only read it, e.g.
+// with SymbolOf and TypeOf.
+func (s *Server) analyzedShader(doc *analysis.Document) *gdshader.Analyzer {
+	version := s.workspace.ShaderVersion()
+	analyzer, _ := doc.Derived(fmt.Sprintf("shaderAnalysis/%s", version), func() (any, error) {
+		analyzer := gdshader.NewAnalyzer(doc.ShaderAST)
+		analyzer.SetVersion(version)
+		analyzer.Analyze()
+		return analyzer, nil
+	})
+	return analyzer.(*gdshader.Analyzer)
+}
+
+// findGDShaderExprHover describes the expression, variable or parameter
+// at a position: the symbol an identifier refers to and where it was
+// declared, or the inferred type of any other expression. Built-in
+// functions and constants have no symbol and are left to
+// findGDShaderBuiltinHover.
+func findGDShaderExprHover(ast *gdshader.ShaderDocument, analyzer *gdshader.Analyzer, version gdshader.Version, line, col int) string {
+	node := ast.FindNodeAt(gdshader.Position{Line: line, Column: col})
+	if node == nil {
+		return ""
+	}
+	if spec, ok := node.(*gdshader.TypeSpec); ok {
+		for _, st := range ast.Structs {
+			if st.Name == spec.Name {
+				return formatStructHover(st)
+			}
+		}
+		return ""
+	}
+
+	if sym := analyzer.SymbolOf(node); sym != nil {
+		return formatGDShaderSymbolHover(ast, version, sym)
+	}
+	if _, ok := node.(*gdshader.IdentExpr); ok {
+		return ""
+	}
+	if member, ok := node.(*gdshader.MemberExpr); ok {
+		if st, field := shaderStructField(ast, analyzer, member); field != nil {
+			var sb strings.Builder
+			sb.WriteString("### Struct Field\n\n")
+			sb.WriteString(fmt.Sprintf("**Name:** `%s.%s`\n\n", st.Name, field.Name))
+			if t := analyzer.TypeOf(member); t != nil && t.Kind != gdshader.TypeKindError {
+				sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", t.String()))
+			}
+			sb.WriteString(fmt.Sprintf("_Declared at line %d._\n", field.Range.Start.Line+1))
+			return sb.String()
+		}
+	}
+
+	expr, ok := node.(gdshader.Expr)
+	if !ok {
+		return ""
+	}
+	t := analyzer.TypeOf(expr)
+	if t == nil || t.Kind == gdshader.TypeKindError {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("### Expression\n\n")
+	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", t.String()))
+	return sb.String()
+}
+
+// shaderStructField returns the struct and the field declaration a member
+// access refers to, or nil when its base is not a struct of the shader,
+// e.g. for swizzles. Chains such as lights[0].surface.color resolve through
+// the types the analyzer inferred for each step.
+func shaderStructField(ast *gdshader.ShaderDocument, analyzer *gdshader.Analyzer, member *gdshader.MemberExpr) (*gdshader.StructDecl, *gdshader.StructMember) {
+	base := analyzer.TypeOf(member.Expr)
+	if base == nil || base.Kind != gdshader.TypeKindStruct {
+		return nil, nil
+	}
+	for _, st := range ast.Structs {
+		if st.Name != base.Name {
+			continue
+		}
+		for _, field := range st.Members {
+			if field.Name == member.Member {
+				return st, field
+			}
+		}
+	}
+	return nil, nil
+}
+
+// formatGDShaderSymbolHover describes a symbol resolved by the analyzer.
+func formatGDShaderSymbolHover(ast *gdshader.ShaderDocument, version gdshader.Version, sym *gdshader.Symbol) string {
+	var sb strings.Builder
+
+	switch sym.Kind {
+	case gdshader.SymbolFunction:
+		sb.WriteString("### Function\n\n")
+		params := make([]string, len(sym.Function.Params))
+		for i, param := range sym.Function.Params {
+			params[i] = param.Type.String() + " " + param.Name
+			if len(param.Qualifiers) > 0 && param.Qualifiers[0] != "" {
+				params[i] = param.Qualifiers[0] + " " + params[i]
+			}
+		}
+		sb.WriteString(fmt.Sprintf("```gdshader\n%s %s(%s)\n```\n\n",
+			sym.Function.ReturnType.String(), sym.Name, strings.Join(params, ", ")))
+	case gdshader.SymbolBuiltinVariable:
+		sb.WriteString("### Built-in Variable\n\n")
+		sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", sym.Name))
+		sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", sym.Type.String()))
+		if sym.ReadOnly {
+			sb.WriteString("**Access:** read-only\n\n")
+		}
+		if ast.ShaderType != nil {
+			if builtin, ok := gdshader.GetBuiltinsForShaderType(version, ast.ShaderType.Type)[sym.Name]; ok && builtin.Description != "" {
+				sb.WriteString(fmt.Sprintf("_%s_\n", builtin.Description))
+			}
+		}
+		return sb.String()
+	default:
+		titles := map[gdshader.SymbolKind]string{
+			gdshader.SymbolVariable:  "Local Variable",
+			gdshader.SymbolParameter: "Parameter",
+			gdshader.SymbolUniform:   "Uniform Variable",
+			gdshader.SymbolVarying:   "Varying Variable",
+			gdshader.SymbolConstant:  "Constant",
+			gdshader.SymbolStruct:    "Struct",
+			gdshader.SymbolMacro:     "Macro",
+		}
+		title := titles[sym.Kind]
+		if sym.Kind == gdshader.SymbolVariable && sym.Constant {
+			title = "Local Constant"
+		}
+		sb.WriteString(fmt.Sprintf("### %s\n\n", title))
+		sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", sym.Name))
+		if sym.Kind != gdshader.SymbolStruct && sym.Kind != gdshader.SymbolMacro {
+			sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", sym.Type.String()))
+		}
+		if sym.Kind == gdshader.SymbolParameter && len(sym.Qualifiers) > 0 && sym.Qualifiers[0] != "" {
+			sb.WriteString(fmt.Sprintf("**Qualifier:** `%s`\n\n", sym.Qualifiers[0]))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("_Declared at line %d._\n", sym.Range.Start.Line+1))
+	return sb.String()
+}
+
 // isInGDShaderRange checks if a position is within a GDShader range.
 func isInGDShaderRange(r gdshader.Range, line, col int) bool {
 	if line < r.Start.Line || line > r.End.Line {
@@ -433,26 +808,60 @@ func formatShaderTypeHover(shaderType string) string {
 	sb.WriteString("### Shader Type\n\n")
 	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", shaderType))
 
-	descriptions := map[string]string{
-		"spatial":     "3D shader for MeshInstance3D and other 3D nodes. Supports vertex, fragment, and light functions.",
-		"canvas_item": "2D shader for CanvasItem nodes like Sprite2D, Control, etc. Supports vertex, fragment, and light functions.",
-		"particles":   "Shader for GPUParticles2D/3D. Supports start and process functions for particle behavior.",
-		"sky":         "Shader for Sky resource. Used for rendering sky backgrounds.",
-		"fog":         "Shader for FogVolume. Used for volumetric fog effects.",
-	}
-
-	if desc, ok := descriptions[shaderType]; ok {
+	if desc, ok := gdshader.ShaderTypeDescriptions[gdshader.ShaderType(shaderType)]; ok {
 		sb.WriteString(fmt.Sprintf("_%s_\n", desc))
 	}
 
 	return sb.String()
 }
 
-func formatRenderModeHover(rm *gdshader.RenderModeDecl) string {
+func formatRenderModeHover(rm *gdshader.RenderModeDecl, modes map[string]*gdshader.RenderMode) string {
 	var sb strings.Builder
 	sb.WriteString("### Render Modes\n\n")
-	for _, mode := range rm.Modes {
-		sb.WriteString(fmt.Sprintf("- `%s`\n", mode))
+	for _, name := range rm.Modes {
+		if mode, ok := modes[name]; ok {
+			sb.WriteString(fmt.Sprintf("- `%s`: %s\n", name, mode.Description))
+		} else {
+			sb.WriteString(fmt.Sprintf("- `%s`\n", name))
+		}
+	}
+	return sb.String()
+}
+
+// formatRenderModeValueHover describes a single render mode: its effect
+// for the shader type, the shader types that accept it, and the Godot
+// version it needs when the project targets an older one.
+func formatRenderModeValueHover(name string, shaderType gdshader.ShaderType, version gdshader.Version) string {
+	var sb strings.Builder
+	sb.WriteString("### Render Mode\n\n")
+	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", name))
+
+	// Modes added after the targeted version are described as of the latest
+	described := version
+	types := gdshader.RenderModeShaderTypes(version, name)
+	if len(types) == 0 {
+		described = gdshader.LatestVersion
+		types = gdshader.RenderModeShaderTypes(described, name)
+	}
+	if len(types) == 0 {
+		sb.WriteString("_Unknown render mode._\n")
+		return sb.String()
+	}
+
+	// The same mode can mean something slightly different for each type
+	mode, ok := gdshader.GetRenderModes(described, shaderType)[name]
+	if !ok {
+		mode = gdshader.GetRenderModes(described, types[0])[name]
+	}
+	sb.WriteString(fmt.Sprintf("_%s_\n\n", mode.Description))
+
+	quoted := make([]string, len(types))
+	for i, t := range types {
+		quoted[i] = "`" + string(t) + "`"
+	}
+	sb.WriteString(fmt.Sprintf("**Shader types:** %s\n", strings.Join(quoted, ", ")))
+	if described != version {
+		sb.WriteString(fmt.Sprintf("\n**Requires Godot %s or later.**\n", mode.Since))
 	}
 	return sb.String()
 }
@@ -461,7 +870,7 @@ func formatUniformHover(uniform *gdshader.UniformDecl) string {
 	var sb strings.Builder
 	sb.WriteString("### Uniform Variable\n\n")
 	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", uniform.Name))
-	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", uniform.Type.Name))
+	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", uniform.Type.String()))
 
 	if uniform.IsGlobal {
 		sb.WriteString("**Scope:** `global`\n\n")
@@ -485,7 +894,7 @@ func formatVaryingHover(varying *gdshader.VaryingDecl) string {
 	var sb strings.Builder
 	sb.WriteString("### Varying Variable\n\n")
 	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", varying.Name))
-	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", varying.Type.Name))
+	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", varying.Type.String()))
 
 	if varying.Interpolation != "" {
 		sb.WriteString(fmt.Sprintf("**Interpolation:** `%s`\n\n", varying.Interpolation))
@@ -499,7 +908,7 @@ func formatConstantHover(constant *gdshader.ConstDecl) string {
 	var sb strings.Builder
 	sb.WriteString("### Constant\n\n")
 	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", constant.Name))
-	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", constant.Type.Name))
+	sb.WriteString(fmt.Sprintf("**Type:** `%s`\n\n", constant.Type.String()))
 	return sb.String()
 }
 
@@ -603,11 +1012,18 @@ func (s *Server) findGDShaderBuiltinHover(doc *analysis.Document, line, col int)
 		// Show all overloads
 		sb.WriteString("```gdshader\n")
 		for _, sig := range fn.Signatures {
-			sb.WriteString(fmt.Sprintf("%s %s(%s)\n", sig.Return, fn.Name, strings.Join(sig.Params, ", ")))
+			sb.WriteString(fmt.Sprintf("%s %s(%s)", sig.Return, fn.Name, strings.Join(sig.ParamList(), ", ")))
+			if sig.Stages != nil {
+				sb.WriteString(" // " + gdshader.JoinStages(sig.Stages) + " only")
+			}
+			sb.WriteString("\n")
 		}
 		sb.WriteString("```\n\n")
 
 		sb.WriteString(fmt.Sprintf("_%s_\n", fn.Description))
+		if fn.Stages != nil {
+			sb.WriteString(fmt.Sprintf("\nOnly available in the %s functions.\n", gdshader.JoinStages(fn.Stages)))
+		}
 		return sb.String()
 	}
 
@@ -0,0 +1,333 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+func TestDescriptorHoverSceneSummary(t *testing.T) {
+	content := `[gd_scene load_steps=5 format=3 uid="uid://abc"]
+
+[ext_resource type="Script" path="res://player.gd" id="1_a"]
+[ext_resource type="PackedScene" path="res://gun.tscn" id="2_b"]
+[ext_resource type="Texture2D" path="res://player.gd" id="3_c"]
+
+[sub_resource type="BoxShape3D" id="Box_1"]
+[sub_resource type="BoxShape3D" id="Box_2"]
+[sub_resource type="StandardMaterial3D" id="Mat_1"]
+
+[node name="Player" type="CharacterBody3D"]
+script = ExtResource("1_a")
+
+[node name="Gun" parent="." instance=ExtResource("2_b")]
+
+[node name="Shape" type="CollisionShape3D" parent="."]
+
+[connection signal="ready" from="." to="." method="_on_ready"]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/player.tscn", content)
+
+	hover := s.findTSCNHoverInfo(doc, 0, 3)
+	for _, want := range []string{
+		"### Scene File",
+		"**Root:** `Player` (`CharacterBody3D`)",
+		"**Nodes:** 3 (1 instanced)",
+		"**External Resources:** 3",
+		"**Sub-Resources:** 3 (2 `BoxShape3D`, 1 `StandardMaterial3D`)",
+		"**Connections:** 1",
+		"- `res://player.gd` (Script)",
+		"- `res://gun.tscn` (PackedScene)",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("expected hover to contain %q, got:\n%s", want, hover)
+		}
+	}
+	if strings.Count(hover, "res://player.gd") != 1 {
+		t.Errorf("expected dependencies to be deduplicated, got:\n%s", hover)
+	}
+}
+
+func TestDescriptorHoverResourceSummary(t *testing.T) {
+	content := `[gd_resource type="Theme" format=3]
+
+[resource]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/theme.tres", content)
+
+	hover := s.findTSCNHoverInfo(doc, 0, 3)
+	if strings.Contains(hover, "**Root:**") || strings.Contains(hover, "**Dependencies:**") {
+		t.Errorf("expected no node or dependency stats for an empty resource, got:\n%s", hover)
+	}
+	if !strings.Contains(hover, "**Sub-Resources:** 0\n") {
+		t.Errorf("expected sub-resource count, got:\n%s", hover)
+	}
+}
+
+func TestHoverSubResourceReference(t *testing.T) {
+	content := `[gd_scene load_steps=3 format=3]
+
+[sub_resource type="SphereShape3D" id="SphereShape3D_abc"]
+radius = 0.75
+
+[sub_resource type="BoxMesh" id="BoxMesh_1"]
+size = Vector3(1, 2,   3)
+
+[node name="Root" type="Node3D"]
+
+[node name="Shape" type="CollisionShape3D" parent="."]
+shape = SubResource("SphereShape3D_abc")
+
+[node name="Mesh" type="MeshInstance3D" parent="."]
+mesh = SubResource("BoxMesh_1")
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/shapes.tscn", content)
+
+	tests := []struct {
+		line, col int
+		want      []string
+	}{
+		{11, 12, []string{"### Internal Resource", "**Type:** `SphereShape3D`", "**ID:** `SphereShape3D_abc`", "- `radius = 0.75`"}},
+		{14, 12, []string{"**Type:** `BoxMesh`", "- `size = Vector3(1, 2, 3)`"}},
+		{5, 2, []string{"**Type:** `BoxMesh`", "**Properties:**"}}, // On the section header
+	}
+	for _, tt := range tests {
+		hover := s.findTSCNHoverInfo(doc, tt.line, tt.col)
+		for _, want := range tt.want {
+			if !strings.Contains(hover, want) {
+				t.Errorf("%d:%d: expected hover to contain %q, got:\n%s", tt.line, tt.col, want, hover)
+			}
+		}
+	}
+
+	// The rest of the property keeps the property hover
+	if hover := s.findTSCNHoverInfo(doc, 11, 2); !strings.HasPrefix(hover, "### Property: `shape`") {
+		t.Errorf("expected the property hover on the key, got:\n%s", hover)
+	}
+}
+
+func TestHoverExtResourcePreview(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"player.tscn": `[gd_scene format=3]
+
+[node name="Player" type="CharacterBody2D"]
+
+[node name="Sprite" type="Sprite2D" parent="."]
+
+[node name="Shape" type="CollisionShape2D" parent="."]
+
+[node name="Hitbox" type="Area2D" parent="Sprite"]
+`,
+		"enemy.gd": "@tool\nclass_name Enemy extends CharacterBody2D # A comment\n\nfunc _ready():\n\tpass\n",
+		"plain.gd": "extends Node\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := `[gd_scene load_steps=4 format=3]
+
+[ext_resource type="PackedScene" path="res://player.tscn" id="1_p"]
+[ext_resource type="Script" path="res://enemy.gd" id="2_e"]
+[ext_resource type="Script" path="res://plain.gd" id="3_s"]
+
+[node name="Main" type="Node"]
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "main.tscn")), content)
+
+	tests := []struct {
+		line    int
+		want    []string
+		notWant string
+	}{
+		{2, []string{"**Root:** `Player` (`CharacterBody2D`)", "**Children:** 2"}, ""},
+		{3, []string{"**Class:** `Enemy`", "**Extends:** `CharacterBody2D`"}, ""},
+		{4, []string{"**Extends:** `Node`"}, "**Class:**"},
+	}
+	for _, tt := range tests {
+		hover := s.findTSCNHoverInfo(doc, tt.line, 3)
+		for _, want := range tt.want {
+			if !strings.Contains(hover, want) {
+				t.Errorf("line %d: expected hover to contain %q, got:\n%s", tt.line, want, hover)
+			}
+		}
+		if tt.notWant != "" && strings.Contains(hover, tt.notWant) {
+			t.Errorf("line %d: expected hover not to contain %q, got:\n%s", tt.line, tt.notWant, hover)
+		}
+	}
+}
+
+func TestHoverClassReference(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[sub_resource type="RectangleShape2D" id="Rect_1"]
+
+[node name="Player" type="Sprite2D"]
+position = Vector2(4, 2)
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/player.tscn", content)
+
+	line, col := positionOf(t, content, `type="Sprite2D"`)
+	hover := s.findTSCNHoverInfo(doc, line, col)
+	for _, want := range []string{
+		"General-purpose sprite node.",
+		"**Inherits:** `Node2D` < `CanvasItem` < `Node` < `Object`",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("expected node hover to contain %q, got:\n%s", want, hover)
+		}
+	}
+
+	line, col = positionOf(t, content, `type="RectangleShape2D"`)
+	hover = s.findTSCNHoverInfo(doc, line, col)
+	if !strings.Contains(hover, "**Inherits:** `Shape2D` < `Resource`") {
+		t.Errorf("expected sub-resource hover to show inheritance, got:\n%s", hover)
+	}
+
+	line, col = positionOf(t, content, "position =")
+	hover = s.findTSCNHoverInfo(doc, line, col)
+	for _, want := range []string{
+		"**Type:** `Vector2`",
+		"**Default:** `Vector2(0, 0)`",
+		"**Declared in:** `Node2D`",
+	} {
+		if !strings.Contains(hover, want) {
+			t.Errorf("expected property hover to contain %q, got:\n%s", want, hover)
+		}
+	}
+}
+
+func TestHoverShaderExpressions(t *testing.T) {
+	content := `shader_type canvas_item;
+
+float brighten(float amount) {
+	return abs(amount) * 2.0;
+}
+
+void fragment() {
+	vec3 tint = vec3(1.0, 0.5, 0.0);
+	COLOR.rgb = tint.rg.xyy * brighten(0.5);
+}
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/tint.gdshader", content)
+
+	tests := []struct {
+		name string
+		line int
+		col  int
+		want []string
+	}{
+		{"local", 8, 14, []string{"### Local Variable", "**Type:** `vec3`", "_Declared at line 8._"}},
+		{"declaration", 7, 7, []string{"### Local Variable", "**Name:** `tint`"}},
+		{"parameter", 3, 13, []string{"### Parameter", "**Type:** `float`", "_Declared at line 3._"}},
+		{"swizzle", 8, 19, []string{"### Expression", "**Type:** `vec2`"}},
+		{"call", 8, 29, []string{"### Function", "float brighten(float amount)"}},
+		{"builtin", 8, 2, []string{"### Built-in Variable", "**Type:** `vec4`"}},
+		{"builtin function", 3, 9, []string{"### Built-in Function"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hover := s.findGDShaderHoverInfo(doc, tt.line, tt.col)
+			for _, want := range tt.want {
+				if !strings.Contains(hover, want) {
+					t.Errorf("expected hover to contain %q, got:\n%s", want, hover)
+				}
+			}
+		})
+	}
+}
+
+func TestHoverShaderBuiltinStages(t *testing.T) {
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/stages.gdshader", "shader_type spatial;\nuniform sampler2D tex;\nvoid fragment() {\n\tALBEDO = vec3(dFdx(UV.x)) + texture(tex, UV).rgb;\n}\n")
+
+	if hover := s.findGDShaderHoverInfo(doc, 3, 16); !strings.Contains(hover, "Only available in the fragment, light and sky functions.") {
+		t.Errorf("expected dFdx to be restricted to per-pixel functions, got:\n%s", hover)
+	}
+	if hover := s.findGDShaderHoverInfo(doc, 3, 31); !strings.Contains(hover, "vec4 texture(sampler2D, vec2, float) // fragment, light and sky only") ||
+		strings.Contains(hover, "vec4 texture(sampler2D, vec2) //") {
+		t.Errorf("expected only the bias overloads of texture to be restricted, got:\n%s", hover)
+	}
+}
+
+func TestHoverShaderStructFields(t *testing.T) {
+	content := `shader_type spatial;
+
+struct Surface {
+	vec3 color;
+};
+
+struct Light {
+	Surface layers[2];
+};
+
+void fragment() {
+	Light lights[4];
+	ALBEDO = lights[1].layers[0].color.rgb;
+}
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/light.gdshader", content)
+
+	tests := []struct {
+		marker string
+		want   []string
+	}{
+		{"layers[0]", []string{"### Struct Field", "**Name:** `Light.layers`", "**Type:** `Surface[2]`", "_Declared at line 8._"}},
+		{"color.rgb", []string{"### Struct Field", "**Name:** `Surface.color`", "**Type:** `vec3`", "_Declared at line 4._"}},
+		{"rgb;", []string{"### Expression", "**Type:** `vec3`"}},
+	}
+	for _, tt := range tests {
+		line, col := positionOf(t, content, tt.marker)
+		hover := s.findGDShaderHoverInfo(doc, line, col)
+		for _, want := range tt.want {
+			if !strings.Contains(hover, want) {
+				t.Errorf("%q: expected hover to contain %q, got:\n%s", tt.marker, want, hover)
+			}
+		}
+	}
+}
+
+func TestHoverRenderModes(t *testing.T) {
+	content := `shader_type spatial;
+render_mode unshaded, blend_add, light_only, specular_occlusion_disabled;
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/glow.gdshader", content)
+
+	tests := []struct {
+		name    string
+		version gdshader.Version
+		col     int
+		want    []string
+	}{
+		{"declaration", gdshader.LatestVersion, 3, []string{"### Render Modes", "- `unshaded`: Result is just albedo", "- `light_only`\n"}},
+		{"mode", gdshader.LatestVersion, 14, []string{"### Render Mode", "**Name:** `unshaded`", "_Result is just albedo, no lighting or shading_", "**Shader types:** `spatial`, `canvas_item`"}},
+		{"mode of the other type too", gdshader.LatestVersion, 25, []string{"**Name:** `blend_add`", "_Additive blend mode_", "**Shader types:** `spatial`, `canvas_item`"}},
+		{"mode of another type", gdshader.LatestVersion, 36, []string{"**Name:** `light_only`", "_Only draw on light pass_", "**Shader types:** `canvas_item`"}},
+		{"newer mode", gdshader.Version{Major: 4, Minor: 3}, 50, []string{"_Disable specular occlusion_", "**Shader types:** `spatial`", "**Requires Godot 4.4 or later.**"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.workspace.SetShaderVersion(tt.version)
+			hover := s.findGDShaderHoverInfo(doc, 1, tt.col)
+			for _, want := range tt.want {
+				if !strings.Contains(hover, want) {
+					t.Errorf("expected hover to contain %q, got:\n%s", want, hover)
+				}
+			}
+		})
+	}
+}
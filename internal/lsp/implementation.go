@@ -0,0 +1,147 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "implementation",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentImplementation = s.textDocumentImplementation
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.ImplementationProvider = &protocol.ImplementationOptions{}
+		},
+	})
+}
+
+// textDocumentImplementation handles the textDocument/implementation
+// request. Nodes of a scene can override what an instanced scene declares:
+// the instance node overrides the root of that scene, and nodes without a
+// type under it override its children (editable children). Implementation
+// goes from such a node, or one of its properties, to the node or property
+// it overrides in the scene declaring it.
+func (s *Server) textDocumentImplementation(ctx *glsp.Context, params *protocol.ImplementationParams) (any, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil || doc.TSCNAST == nil {
+		return nil, nil
+	}
+
+	location := s.findImplementation(doc, params.TextDocument.URI, int(params.Position.Line), int(params.Position.Character))
+	if location == nil {
+		return nil, nil
+	}
+	return location, nil
+}
+
+// findImplementation finds what the node or property at the given position
+// overrides.
+func (s *Server) findImplementation(doc *analysis.Document, uri string, line, col int) *protocol.Location {
+	ast := doc.TSCNAST
+	for _, node := range ast.Nodes {
+		for _, prop := range node.Properties {
+			if isInRange(prop.Range, line, col) {
+				return s.overriddenProperty(uri, ast, node, prop.Key)
+			}
+		}
+		if isInRange(node.Range, line, col) {
+			if originURI, _, origin := s.overriddenNode(uri, ast, node); origin != nil {
+				return &protocol.Location{URI: originURI, Range: toProtocolRange(origin.Range)}
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// overriddenProperty returns the nearest declaration of a property a node
+// overrides, following the scenes instancing each other. When none of them
+// sets the property, the engine default is overridden, and it returns the
+// node the property belongs to.
+func (s *Server) overriddenProperty(uri string, ast *parser.Document, node *parser.Node, key string) *protocol.Location {
+	var first *protocol.Location
+	for range maxInstanceDepth {
+		originURI, originAST, origin := s.overriddenNode(uri, ast, node)
+		if origin == nil {
+			break
+		}
+		if first == nil {
+			first = &protocol.Location{URI: originURI, Range: toProtocolRange(origin.Range)}
+		}
+		for _, prop := range origin.Properties {
+			if prop.Key == key {
+				return &protocol.Location{URI: originURI, Range: toProtocolRange(prop.Range)}
+			}
+		}
+		uri, ast, node = originURI, originAST, origin
+	}
+	return first
+}
+
+// overriddenNode returns the node of an instanced scene a node overrides,
+// with the URI and parsed document of the scene declaring it: for an
+// instance, the root of the scene it instances; for a node without a type,
+// the child of that name of the node its parent overrides or reaches in an
+// instanced scene. It returns a nil node for nodes overriding nothing.
+func (s *Server) overriddenNode(uri string, ast *parser.Document, node *parser.Node) (string, *parser.Document, *parser.Node) {
+	if node.Instance != nil {
+		sceneURI, scene := s.instancedScene(uri, ast, node)
+		if root := analysis.NewSceneTree(scene).Root; root != nil {
+			return sceneURI, scene, root.Node
+		}
+		return "", nil, nil
+	}
+	if node.Parent == "" || node.Type != "" {
+		return "", nil, nil
+	}
+
+	tree := analysis.NewSceneTree(ast)
+	parentURI, parent := s.resolveNodePath(uri, ast, tree, tree.Root, analysis.ParentPath(node.Parent))
+	if parent == nil {
+		return "", nil, nil
+	}
+
+	// The parent is declared in this scene when it is an instance or an
+	// override itself, and in an instanced scene when the parent= path
+	// reaches into one
+	var sceneURI string
+	var scene *parser.Document
+	var sceneTree *analysis.SceneTree
+	var base *analysis.SceneNode
+	if parentURI == uri {
+		var origin *parser.Node
+		sceneURI, scene, origin = s.overriddenNode(uri, ast, parent.Node)
+		if origin == nil {
+			return "", nil, nil
+		}
+		sceneTree = analysis.NewSceneTree(scene)
+		base = sceneTree.NodeFor(origin)
+	} else {
+		sceneURI, scene = parentURI, s.loadScene(parentURI)
+		sceneTree = analysis.NewSceneTree(scene)
+		base = sceneTree.Lookup(parent.Path)
+	}
+	if base == nil {
+		return "", nil, nil
+	}
+
+	targetURI, target := s.resolveNodePath(sceneURI, scene, sceneTree, base, node.Name)
+	if target == nil {
+		return "", nil, nil
+	}
+	if targetURI == sceneURI {
+		return targetURI, scene, target.Node
+	}
+	// Look the node up again in the document returned, so that its callers
+	// can follow the overrides of that scene
+	scene = s.loadScene(targetURI)
+	if target = analysis.NewSceneTree(scene).Lookup(target.Path); target == nil {
+		return "", nil, nil
+	}
+	return targetURI, scene, target.Node
+}
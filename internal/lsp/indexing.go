@@ -0,0 +1,144 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tliron/glsp"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// StatusNotification is sent to the client once a workspace folder has
+// been indexed, listing the files that were skipped.
+const StatusNotification = "gdls/status"
+
+// statusParams are the parameters of the gdls/status notification.
+type statusParams struct {
+	Message string          `json:"message"`
+	Skipped []skippedParams `json:"skipped,omitempty"`
+}
+
+// skippedParams describes a file left out of the workspace index.
+type skippedParams struct {
+	URI    string `json:"uri"`
+	Reason string `json:"reason"`
+}
+
+// indexWorkspace indexes the workspace folders in the background once the
+// client is initialized, reporting the progress of each scan as
+// "Indexing Godot project: 12/40 files" when the client supports it. The
+// user can cancel the progress to stop scanning; what was scanned until
+// then stays indexed.
+func (s *Server) indexWorkspace(ctx *glsp.Context) {
+	defer s.recoverPanic("workspace indexing", nil)
+	folders := s.workspace.GetFolders()
+	if len(folders) == 0 {
+		return
+	}
+
+	progress := s.beginProgress(ctx, "Indexing Godot project")
+	indexed := 0
+	for _, folder := range folders {
+		status, ok := s.indexFolder(progress.context(), folder, progress.reportFiles)
+		if ok {
+			ctx.Notify(StatusNotification, status)
+		}
+		if progress.context().Err() != nil {
+			break
+		}
+		indexed++
+	}
+	if indexed < len(folders) {
+		progress.end("Indexing canceled")
+	} else {
+		progress.end(fmt.Sprintf("Indexed %d files", len(s.workspace.Files())))
+	}
+
+	// Diagnostics of documents opened meanwhile can use the index now
+	for _, doc := range s.workspace.GetAllDocuments() {
+		s.scheduleDiagnostics(ctx, doc.URI)
+	}
+}
+
+// indexFolder scans a workspace folder for the documents gdls understands.
+// The groups of the nodes of its scenes and the classes its scripts declare
+// are indexed, and when the folder is a project, the autoloads of its
+// project.godot and the uid:// identifiers of its resources too. Canceling
+// ctx stops the scan, keeping the files checked so far, and progress, if
+// set, is called as files are checked.
+// It returns the status to report through a gdls/status notification,
+// which lists the files skipped by the scanner.
+func (s *Server) indexFolder(ctx context.Context, folderURI string, progress func(done, total int)) (statusParams, bool) {
+	root := uriToPath(folderURI)
+	if root == "" {
+		return statusParams{}, false
+	}
+
+	result := analysis.ScanFolder(ctx, root, analysis.ScanOptions{
+		MaxFileSize: s.currentSettings().MaxFileSize,
+		Progress:    progress,
+	})
+
+	projectFile := filepath.Join(root, analysis.ProjectFileName)
+	uris := make([]string, len(result.Files))
+	for i, path := range result.Files {
+		uris[i] = pathToURI(path)
+		if path == projectFile {
+			if content, err := os.ReadFile(path); err == nil {
+				s.workspace.IndexProjectFile(uris[i], string(content))
+			}
+		}
+	}
+	s.workspace.SetFolderFiles(folderURI, uris)
+	s.workspace.SetFolderGroups(folderURI, analysis.ReadSceneGroups(result.Files, uris))
+	scriptURIs := make([]string, len(result.Scripts))
+	for i, path := range result.Scripts {
+		scriptURIs[i] = pathToURI(path)
+	}
+	s.workspace.SetFolderScriptClasses(folderURI, analysis.ReadScriptClasses(root, result.Scripts, scriptURIs))
+	if fileExists(projectFile) {
+		s.workspace.SetFolderUIDs(folderURI, analysis.BuildUIDIndex(root, result))
+	}
+
+	status := scanStatus(folderURI, result)
+	if len(status.Skipped) > 0 {
+		s.log.Warning(status.Message)
+	}
+	return status, true
+}
+
+// scanStatus summarizes a folder scan, e.g.
+// "Indexed 12 files in file:///game; skipped 3 (2 binary, 1 too large)".
+func scanStatus(folderURI string, result analysis.ScanResult) statusParams {
+	status := statusParams{
+		Message: fmt.Sprintf("Indexed %d files in %s", len(result.Files), folderURI),
+	}
+	if result.Canceled {
+		status.Message = fmt.Sprintf("Indexing canceled after %d files in %s", len(result.Files), folderURI)
+	}
+	if len(result.Skipped) == 0 {
+		return status
+	}
+
+	counts := make(map[analysis.SkipReason]int)
+	for _, skipped := range result.Skipped {
+		counts[skipped.Reason]++
+		status.Skipped = append(status.Skipped, skippedParams{
+			URI:    pathToURI(skipped.Path),
+			Reason: string(skipped.Reason),
+		})
+	}
+
+	var reasons []string
+	for reason, n := range counts {
+		reasons = append(reasons, fmt.Sprintf("%d %s", n, reason))
+	}
+	sort.Strings(reasons)
+	status.Message += fmt.Sprintf("; skipped %d (%s)", len(result.Skipped), strings.Join(reasons, ", "))
+	return status
+}
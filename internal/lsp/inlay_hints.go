@@ -0,0 +1,154 @@
+package lsp
+
+import (
+	"path"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// LSP 3.17 inlay hints, which the protocol package predates. In scenes and
+// resources, a hint after each ExtResource("...") names the file it loads,
+// and one after each SubResource("...") the type of the sub_resource, so
+// that the opaque ids can be read without scrolling to the headers. The
+// flags= of connections get the names of the bits they set.
+const methodTextDocumentInlayHint = "textDocument/inlayHint"
+
+func init() {
+	registerCapability(&capability{
+		name: "inlayHint",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[methodTextDocumentInlayHint] = customRequest(s.textDocumentInlayHint)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.InlayHintProvider = boolPtr(true)
+		},
+	})
+}
+
+// inlayHintParams are the parameters of the textDocument/inlayHint request.
+type inlayHintParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+	Range        protocol.Range                  `json:"range"`
+}
+
+// inlayHintKindType is the kind of hints that show a type.
+const inlayHintKindType = 1
+
+// inlayHint is a hint shown inline at a position.
+type inlayHint struct {
+	Position    protocol.Position `json:"position"`
+	Label       string            `json:"label"`
+	Kind        int               `json:"kind,omitempty"`
+	Tooltip     string            `json:"tooltip,omitempty"`
+	PaddingLeft bool              `json:"paddingLeft,omitempty"`
+}
+
+// textDocumentInlayHint handles the textDocument/inlayHint request,
+// returning the hints of the resource references in the requested range.
+func (s *Server) textDocumentInlayHint(ctx *glsp.Context, params *inlayHintParams) (any, error) {
+	uri := params.TextDocument.URI
+	doc := s.workspace.GetDocument(uri)
+	if doc == nil || doc.TSCNAST == nil {
+		return nil, nil
+	}
+	ast := doc.TSCNAST
+
+	extPaths := make(map[string]string, len(ast.ExtResources))
+	for _, ext := range ast.ExtResources {
+		extPaths[ext.ID] = s.extResourcePath(uri, ext)
+	}
+	subTypes := make(map[string]string, len(ast.SubResources))
+	for _, sub := range ast.SubResources {
+		subTypes[sub.ID] = sub.Type
+	}
+
+	hints := []inlayHint{}
+	forEachResourceRef(ast, func(ref *parser.ResourceRef) {
+		end := protocol.Position{Line: uint32(ref.Range.End.Line), Character: uint32(ref.Range.End.Column)}
+		if !positionInRange(end, params.Range) {
+			return
+		}
+		var label, tooltip string
+		switch ref.RefType {
+		case "ExtResource":
+			resPath, ok := extPaths[ref.ID]
+			if !ok || resPath == "" {
+				return
+			}
+			label, tooltip = path.Base(resPath), resPath
+		case "SubResource":
+			// Godot names sub_resources after their type, as in
+			// "SphereShape3D_abc", which needs no hint
+			subType, ok := subTypes[ref.ID]
+			if !ok || subType == "" || strings.HasPrefix(ref.ID, subType+"_") {
+				return
+			}
+			label = subType
+		default:
+			return
+		}
+		hints = append(hints, inlayHint{
+			Position:    end,
+			Label:       "→ " + label,
+			Kind:        inlayHintKindType,
+			Tooltip:     tooltip,
+			PaddingLeft: true,
+		})
+	})
+	for _, conn := range ast.Connections {
+		end := protocol.Position{Line: uint32(conn.FlagsRange.End.Line), Character: uint32(conn.FlagsRange.End.Column)}
+		if conn.Flags == nil || *conn.Flags == 0 || !positionInRange(end, params.Range) {
+			continue
+		}
+		hints = append(hints, inlayHint{
+			Position:    end,
+			Label:       "→ " + strings.Join(decodeConnectFlags(*conn.Flags), " | "),
+			PaddingLeft: true,
+		})
+	}
+	return hints, nil
+}
+
+// forEachResourceRef calls fn for every resource reference of a scene or
+// resource, in document order: in the properties of every section and in
+// the instance= of nodes.
+func forEachResourceRef(ast *parser.Document, fn func(*parser.ResourceRef)) {
+	visit := func(v parser.Value) {
+		walkValue(v, func(v parser.Value) {
+			if ref, ok := v.(*parser.ResourceRef); ok {
+				fn(ref)
+			}
+		})
+	}
+	visitProperties := func(props []*parser.Property) {
+		for _, prop := range props {
+			visit(prop.Value)
+		}
+	}
+
+	for _, sub := range ast.SubResources {
+		visitProperties(sub.Properties)
+	}
+	if ast.Resource != nil {
+		visitProperties(ast.Resource.Properties)
+	}
+	for _, node := range ast.Nodes {
+		visit(node.Instance)
+		visitProperties(node.Properties)
+	}
+}
+
+// positionInRange reports whether pos is within r, ends included.
+func positionInRange(pos protocol.Position, r protocol.Range) bool {
+	if pos.Line < r.Start.Line || pos.Line > r.End.Line {
+		return false
+	}
+	if pos.Line == r.Start.Line && pos.Character < r.Start.Character {
+		return false
+	}
+	return pos.Line != r.End.Line || pos.Character <= r.End.Character
+}
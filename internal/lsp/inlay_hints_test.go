@@ -0,0 +1,81 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestInlayHints(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "project.godot"), []byte("config_version=5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := pathToURI(filepath.Join(dir, "level.tscn"))
+	content := `[gd_scene load_steps=4 format=3]
+
+[ext_resource type="Script" path="res://ui/MainMenu.cs" id="1_mainmenu"]
+[ext_resource type="PackedScene" path="res://player.tscn" id="2_player"]
+
+[sub_resource type="RectangleShape2D" id="RectangleShape2D_a1"]
+
+[sub_resource type="StyleBoxFlat" id="3"]
+
+[node name="Level" type="Control"]
+script = ExtResource("1_mainmenu")
+theme_override_styles/panel = SubResource("3")
+
+[node name="Area" type="CollisionShape2D" parent="."]
+shape = SubResource("RectangleShape2D_a1")
+
+[node name="Player" parent="." instance=ExtResource("2_player")]
+`
+	s := NewServer("test", "test")
+	s.workspace.OpenDocument(uri, content)
+
+	all := protocol.Range{End: protocol.Position{Line: 100}}
+	result, err := s.textDocumentInlayHint(&glsp.Context{}, &inlayHintParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        all,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hints := result.([]inlayHint)
+
+	// The sub_resource named after its type gets no hint
+	expected := []struct {
+		line  uint32
+		label string
+	}{
+		{10, "→ MainMenu.cs"},
+		{11, "→ StyleBoxFlat"},
+		{16, "→ player.tscn"},
+	}
+	if len(hints) != len(expected) {
+		t.Fatalf("expected %d hints, got %+v", len(expected), hints)
+	}
+	for i, want := range expected {
+		if hints[i].Position.Line != want.line || hints[i].Label != want.label {
+			t.Errorf("hint %d: expected %q on line %d, got %+v", i, want.label, want.line, hints[i])
+		}
+	}
+	if hints[0].Position.Character != uint32(len(`script = ExtResource("1_mainmenu")`)) {
+		t.Errorf("expected the hint after the reference, got %+v", hints[0].Position)
+	}
+	if hints[0].Tooltip != "res://ui/MainMenu.cs" {
+		t.Errorf("expected the full path as tooltip, got %q", hints[0].Tooltip)
+	}
+
+	// Only the references in the requested range
+	result, _ = s.textDocumentInlayHint(&glsp.Context{}, &inlayHintParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+		Range:        protocol.Range{Start: protocol.Position{Line: 11}, End: protocol.Position{Line: 12}},
+	})
+	if hints := result.([]inlayHint); len(hints) != 1 || hints[0].Label != "→ StyleBoxFlat" {
+		t.Errorf("expected one hint in range, got %+v", hints)
+	}
+}
@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// codeServerNotInitialized is the LSP error code for a request sent before
+// the initialize request.
+const codeServerNotInitialized = -32002
+
+// ErrExitWithoutShutdown is returned by RunStdio when the client sent the
+// exit notification without a shutdown request first. The specification
+// asks the server to exit with code 1 in that case.
+var ErrExitWithoutShutdown = errors.New("exit notification received before shutdown")
+
+// ErrParentExited is returned by RunStdio when the process that started the
+// server, usually the editor, is gone without closing the connection.
+var ErrParentExited = errors.New("parent process exited")
+
+// parentCheckInterval is how often the parent process is checked for.
+const parentCheckInterval = 3 * time.Second
+
+// lifecycleState is where a session is in the initialize/shutdown/exit
+// sequence of the specification.
+type lifecycleState int32
+
+const (
+	stateUninitialized lifecycleState = iota
+	stateInitialized
+	stateShutdown
+)
+
+// lifecycle tracks the state of a session and how it ended.
+type lifecycle struct {
+	state  atomic.Int32
+	exited atomic.Pointer[error]
+}
+
+func (l *lifecycle) current() lifecycleState  { return lifecycleState(l.state.Load()) }
+func (l *lifecycle) set(state lifecycleState) { l.state.Store(int32(state)) }
+
+// check returns the error for a message the current state does not allow:
+// only initialize and exit before initialize, and only exit after shutdown.
+// Requests are answered with the error; notifications are dropped.
+func (l *lifecycle) check(method string) *jsonrpc2.Error {
+	switch l.current() {
+	case stateUninitialized:
+		if method == "initialize" || method == "exit" {
+			return nil
+		}
+		return &jsonrpc2.Error{Code: codeServerNotInitialized, Message: "server not initialized"}
+	case stateInitialized:
+		if method == "initialize" {
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server already initialized"}
+		}
+	case stateShutdown:
+		if method != "exit" {
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is shutting down"}
+		}
+	}
+	return nil
+}
+
+// handled advances the state after the handler for method succeeded.
+func (l *lifecycle) handled(method string) {
+	switch method {
+	case "initialize":
+		l.set(stateInitialized)
+	case "shutdown":
+		l.set(stateShutdown)
+	case "exit":
+		var err error
+		if l.current() != stateShutdown {
+			err = ErrExitWithoutShutdown
+		}
+		l.exited.Store(&err)
+	}
+}
+
+// exitError returns ErrExitWithoutShutdown if the session ended with an
+// exit notification that was not preceded by shutdown.
+func (l *lifecycle) exitError() error {
+	if err := l.exited.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// watchParent calls stop once the process pid is gone, checking every
+// interval until ctx is done.
+func watchParent(ctx context.Context, pid int, interval time.Duration, stop func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !processAlive(pid) {
+				stop()
+				return
+			}
+		}
+	}
+}
+
+// processAlive reports whether the process pid is running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		return true // FindProcess fails for processes that are gone
+	}
+	// Signal 0 only checks that the process exists; EPERM means it does
+	// but belongs to another user
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,91 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestLifecycle(t *testing.T) {
+	s := NewServer("test", "test")
+	client := connectServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	expectCode := func(method string, code int64) {
+		t.Helper()
+		err := client.Call(ctx, method, map[string]any{}, nil)
+		var rpcErr *jsonrpc2.Error
+		if !errors.As(err, &rpcErr) || rpcErr.Code != code {
+			t.Errorf("%s: expected error code %d, got %v", method, code, err)
+		}
+	}
+
+	expectCode("textDocument/hover", codeServerNotInitialized)
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	expectCode("initialize", jsonrpc2.CodeInvalidRequest)
+	if err := client.Call(ctx, "shutdown", nil, nil); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	expectCode(StatsMethod, jsonrpc2.CodeInvalidRequest)
+
+	if err := client.Notify(ctx, "exit", nil); err != nil {
+		t.Fatalf("exit failed: %v", err)
+	}
+	<-client.DisconnectNotify()
+	if err := s.lifecycle.exitError(); err != nil {
+		t.Errorf("expected a clean exit after shutdown, got %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	s := NewServer("test", "test")
+	client := connectServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := client.Notify(ctx, "exit", nil); err != nil {
+		t.Fatalf("exit failed: %v", err)
+	}
+	<-client.DisconnectNotify()
+	if err := s.lifecycle.exitError(); !errors.Is(err, ErrExitWithoutShutdown) {
+		t.Errorf("expected ErrExitWithoutShutdown, got %v", err)
+	}
+}
+
+func TestWatchParent(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A process that already exited and was reaped
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	if err := child.Run(); err != nil {
+		t.Fatalf("failed to run child process: %v", err)
+	}
+	stopped := make(chan struct{})
+	go watchParent(ctx, child.Process.Pid, 10*time.Millisecond, func() { close(stopped) })
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the watchdog to stop once the process is gone")
+	}
+
+	alive := make(chan struct{})
+	go watchParent(ctx, os.Getpid(), 10*time.Millisecond, func() { close(alive) })
+	select {
+	case <-alive:
+		t.Error("expected the watchdog to keep running while the process lives")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// Files saved on Windows have CRLF line endings and sometimes a byte order
+// mark. Every feature gives the same results for them as for the same file
+// with LF line endings.
+func TestCRLFMatchesLF(t *testing.T) {
+	for _, name := range []string{"crlf.tscn", "crlf.gdshader"} {
+		t.Run(name, func(t *testing.T) {
+			raw, err := os.ReadFile("../../testdata/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			crlf := string(raw)
+			lf := strings.TrimPrefix(strings.ReplaceAll(crlf, "\r\n", "\n"), "\uFEFF")
+
+			want, got := lineEndingResults(t, name, lf), lineEndingResults(t, name, crlf)
+			for feature, result := range want {
+				if got[feature] != result {
+					t.Errorf("%s differ:\nLF:   %s\nCRLF: %s", feature, result, got[feature])
+				}
+			}
+		})
+	}
+}
+
+// lineEndingResults returns the results of the features working on a whole
+// document, as JSON.
+func lineEndingResults(t *testing.T, name, content string) map[string]string {
+	t.Helper()
+
+	s := NewServer("test", "test")
+	uri := "file:///project/" + name
+	doc := s.workspace.OpenDocument(uri, content)
+	ctx := &glsp.Context{}
+	td := protocol.TextDocumentIdentifier{URI: uri}
+
+	results := make(map[string]string)
+	record := func(feature string, result any, err error) {
+		if err != nil {
+			t.Fatalf("%s: %v", feature, err)
+		}
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatalf("%s: %v", feature, err)
+		}
+		results[feature] = string(data)
+	}
+	links, err := s.textDocumentDocumentLink(ctx, &protocol.DocumentLinkParams{TextDocument: td})
+	record("document links", links, err)
+	folding, err := s.textDocumentFoldingRange(ctx, &protocol.FoldingRangeParams{TextDocument: td})
+	record("folding ranges", folding, err)
+	symbols, err := s.textDocumentDocumentSymbol(ctx, &protocol.DocumentSymbolParams{TextDocument: td})
+	record("document symbols", symbols, err)
+	colors, err := s.textDocumentColor(ctx, &protocol.DocumentColorParams{TextDocument: td})
+	record("colors", colors, err)
+	tokens, err := s.textDocumentSemanticTokensFull(ctx, &protocol.SemanticTokensParams{TextDocument: td})
+	record("semantic tokens", tokens, err)
+	lenses, err := s.textDocumentCodeLens(ctx, &protocol.CodeLensParams{TextDocument: td})
+	record("code lenses", lenses, err)
+	record("diagnostics", s.computeDiagnostics(doc), nil)
+
+	// Formatting keeps the line endings of the file
+	edits, err := s.textDocumentFormatting(ctx, &protocol.DocumentFormattingParams{TextDocument: td})
+	if err != nil {
+		t.Fatalf("formatting: %v", err)
+	}
+	if len(edits) == 0 {
+		t.Fatal("expected the fixture to need formatting")
+	}
+	for i := range edits {
+		if strings.Contains(content, "\r\n") && strings.Count(edits[i].NewText, "\r\n") != strings.Count(edits[i].NewText, "\n") {
+			t.Errorf("expected formatting to keep CRLF line endings, got %q", edits[i].NewText)
+		}
+		edits[i].NewText = strings.ReplaceAll(edits[i].NewText, "\r\n", "\n")
+	}
+	record("formatting", edits, nil)
+	return results
+}
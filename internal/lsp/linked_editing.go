@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "linkedEditingRange",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentLinkedEditingRange = s.textDocumentLinkedEditingRange
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.LinkedEditingRangeProvider = &protocol.LinkedEditingRangeOptions{}
+		},
+	})
+}
+
+// Valid contents of linked ranges: shader identifiers and resource IDs.
+var (
+	identifierWordPattern = `[A-Za-z_][A-Za-z0-9_]*`
+	resourceIDWordPattern = `[^"\s]+`
+)
+
+// textDocumentLinkedEditingRange handles the textDocument/linkedEditingRange
+// request. Typing over a name the file declares edits its other
+// occurrences along: the ID of an [ext_resource] or [sub_resource] and the
+// ExtResource("...") or SubResource("...") using it, or the name of a
+// shader variable, parameter or function and the identifiers referring to
+// it. Unlike rename, nothing outside the file is looked at.
+func (s *Server) textDocumentLinkedEditingRange(ctx *glsp.Context, params *protocol.LinkedEditingRangeParams) (*protocol.LinkedEditingRanges, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	line, col := int(params.Position.Line), int(params.Position.Character)
+
+	var ranges []protocol.Range
+	var pattern string
+	switch {
+	case doc.TSCNAST != nil:
+		ranges, pattern = resourceIDRanges(doc, line, col), resourceIDWordPattern
+	case doc.ShaderAST != nil:
+		ranges, pattern = shaderSymbolRanges(doc.ShaderAST, s.analyzedShader(doc), line, col), identifierWordPattern
+	}
+	// A single range has nothing to be linked to
+	if len(ranges) < 2 {
+		return nil, nil
+	}
+	return &protocol.LinkedEditingRanges{Ranges: ranges, WordPattern: &pattern}, nil
+}
+
+// resourceIDRanges returns the ranges of the ID of the resource declared or
+// referenced at the given position, inside their quotes: in its declaration
+// and every reference to it.
+func resourceIDRanges(doc *analysis.Document, line, col int) []protocol.Range {
+	ast := doc.TSCNAST
+	var refType, id string
+	var declaration parser.Range
+	for _, ext := range ast.ExtResources {
+		if isInRange(ext.IDRange, line, col) {
+			refType, id, declaration = "ExtResource", ext.ID, ext.IDRange
+		}
+	}
+	for _, sub := range ast.SubResources {
+		if isInRange(sub.IDRange, line, col) {
+			refType, id, declaration = "SubResource", sub.ID, sub.IDRange
+		}
+	}
+	forEachResourceRef(ast, func(ref *parser.ResourceRef) {
+		if isInRange(ref.IDRange, line, col) {
+			refType, id = ref.RefType, ref.ID
+		}
+	})
+	if id == "" {
+		return nil
+	}
+
+	// Find the declaration of a reference
+	if declaration == (parser.Range{}) {
+		if refType == "ExtResource" {
+			for _, ext := range ast.ExtResources {
+				if ext.ID == id {
+					declaration = ext.IDRange
+				}
+			}
+		} else {
+			for _, sub := range ast.SubResources {
+				if sub.ID == id {
+					declaration = sub.IDRange
+				}
+			}
+		}
+	}
+
+	var ranges []protocol.Range
+	if declaration != (parser.Range{}) {
+		ranges = append(ranges, idContentRange(doc.Content, declaration, id))
+	}
+	forEachResourceRef(ast, func(ref *parser.ResourceRef) {
+		if ref.RefType == refType && ref.ID == id {
+			ranges = append(ranges, idContentRange(doc.Content, ref.IDRange, id))
+		}
+	})
+	return ranges
+}
+
+// idContentRange returns the range of an ID without the quotes of its
+// string; the numeric IDs of format=2 files have none.
+func idContentRange(content string, rng parser.Range, id string) protocol.Range {
+	if rng.Start.Offset < len(content) && content[rng.Start.Offset] == '"' {
+		rng = stringContentRange(rng, 0, len(id))
+	}
+	return toProtocolRange(rng)
+}
+
+// shaderSymbolRanges returns the ranges of the name of the shader symbol
+// declared or referenced at the given position: its declaration and every
+// identifier resolving to it. Every overload of a function is linked, as
+// they share their name. Built-ins, structs, macros and symbols declared by
+// an included file have no linked ranges.
+func shaderSymbolRanges(ast *gdshader.ShaderDocument, analyzer *gdshader.Analyzer, line, col int) []protocol.Range {
+	// symbolKey identifies the symbol a name refers to; functions are
+	// identified by name, so that overloads are linked.
+	type symbolKey struct {
+		sym  *gdshader.Symbol
+		name string
+	}
+	keyOf := func(sym *gdshader.Symbol) (symbolKey, bool) {
+		switch {
+		case sym == nil || sym.URI != "":
+			return symbolKey{}, false
+		case sym.Kind == gdshader.SymbolFunction:
+			return symbolKey{name: sym.Name}, true
+		case sym.Kind == gdshader.SymbolVariable, sym.Kind == gdshader.SymbolParameter,
+			sym.Kind == gdshader.SymbolUniform, sym.Kind == gdshader.SymbolVarying,
+			sym.Kind == gdshader.SymbolConstant:
+			return symbolKey{sym: sym}, true
+		}
+		return symbolKey{}, false
+	}
+
+	type occurrence struct {
+		key symbolKey
+		rng gdshader.Range
+	}
+	var occurrences []occurrence
+	seen := make(map[gdshader.Range]bool)
+	add := func(sym *gdshader.Symbol, rng gdshader.Range) {
+		if key, ok := keyOf(sym); ok && !seen[rng] {
+			seen[rng] = true
+			occurrences = append(occurrences, occurrence{key, rng})
+		}
+	}
+
+	for _, sym := range analyzer.GetSymbols() {
+		add(sym, sym.NameRange)
+		for _, overload := range sym.Overloads {
+			add(overload, overload.NameRange)
+		}
+	}
+	gdshader.InspectDocument(ast, func(node gdshader.Node) bool {
+		switch n := node.(type) {
+		case *gdshader.IdentExpr:
+			add(analyzer.SymbolOf(n), n.Range)
+		case *gdshader.VarDecl, *gdshader.ParamDecl:
+			if sym := analyzer.SymbolOf(n); sym != nil {
+				add(sym, sym.NameRange)
+			}
+		}
+		return true
+	})
+
+	var target *symbolKey
+	for _, occ := range occurrences {
+		if isInGDShaderRange(occ.rng, line, col) {
+			target = &occ.key
+			break
+		}
+	}
+	if target == nil {
+		return nil
+	}
+
+	var ranges []protocol.Range
+	for _, occ := range occurrences {
+		if occ.key == *target {
+			ranges = append(ranges, toProtocolShaderRange(occ.rng))
+		}
+	}
+	return ranges
+}
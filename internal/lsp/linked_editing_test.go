@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// linkedText returns the line and text of each linked editing range at the
+// first occurrence of marker, offset by a column.
+func linkedText(t *testing.T, uri, content, marker string, offset int) []string {
+	t.Helper()
+
+	s := NewServer("test", "test")
+	s.workspace.OpenDocument(uri, content)
+	line, col := positionOf(t, content, marker)
+	result, err := s.textDocumentLinkedEditingRange(&glsp.Context{}, &protocol.LinkedEditingRangeParams{
+		TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+			Position:     protocol.Position{Line: uint32(line), Character: uint32(col + offset)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		return nil
+	}
+
+	lines := strings.Split(content, "\n")
+	var texts []string
+	for _, r := range result.Ranges {
+		texts = append(texts, fmt.Sprintf("%d:%s", r.Start.Line, lines[r.Start.Line][r.Start.Character:r.End.Character]))
+	}
+	return texts
+}
+
+func TestLinkedEditingResourceIDs(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[ext_resource type="Texture2D" path="res://icon.png" id="1_icon"]
+
+[sub_resource type="CircleShape2D" id="Circle_1"]
+
+[sub_resource type="CircleShape2D" id="Circle_2"]
+
+[node name="Root" type="Area2D"]
+
+[node name="Shape" type="CollisionShape2D" parent="."]
+shape = SubResource("Circle_1")
+shapes = [SubResource("Circle_1"), SubResource("Circle_2")]
+
+[node name="Sprite" type="Sprite2D" parent="."]
+texture = ExtResource("1_icon")
+`
+	uri := "file:///tmp/linked.tscn"
+	want := []string{"4:Circle_1", "11:Circle_1", "12:Circle_1"}
+	for _, marker := range []string{`id="Circle_1"`, `shape = SubResource("Circle_1")`} {
+		offset := strings.Index(marker, "Circle_1") + 2
+		if got := linkedText(t, uri, content, marker, offset); !slices.Equal(got, want) {
+			t.Errorf("%s: expected %v, got %v", marker, want, got)
+		}
+	}
+	if got := linkedText(t, uri, content, `id="1_icon"`, 5); !slices.Equal(got, []string{"2:1_icon", "15:1_icon"}) {
+		t.Errorf("unexpected ext_resource ranges: %v", got)
+	}
+	if got := linkedText(t, uri, content, `type="Area2D"`, 7); got != nil {
+		t.Errorf("expected no linked ranges on a type, got %v", got)
+	}
+}
+
+func TestLinkedEditingShaderSymbols(t *testing.T) {
+	content := `shader_type spatial;
+
+uniform float speed = 1.0;
+
+float wave(float x) {
+	return sin(x * speed);
+}
+
+vec2 wave(vec2 x) {
+	return x;
+}
+
+void fragment() {
+	float h = wave(TIME);
+	ALBEDO = vec3(h, wave(vec2(h)).x, speed);
+}
+`
+	uri := "file:///tmp/linked.gdshader"
+	for _, tt := range []struct {
+		marker string
+		want   []string
+	}{
+		{"speed = 1.0", []string{"2:speed", "5:speed", "14:speed"}},
+		{"wave(float", []string{"4:wave", "8:wave", "13:wave", "14:wave"}}, // Overloads are linked
+		{"h = wave", []string{"13:h", "14:h", "14:h"}},
+		{"x * speed", []string{"4:x", "5:x"}}, // The parameter, not the one of the overload
+	} {
+		if got := linkedText(t, uri, content, tt.marker, 0); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.marker, tt.want, got)
+		}
+	}
+	for _, marker := range []string{"TIME", "sin(", "ALBEDO"} {
+		if got := linkedText(t, uri, content, marker, 0); got != nil {
+			t.Errorf("%q: expected no linked ranges for a built-in, got %v", marker, got)
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package lsp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"slices"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// Positions of the protocol count the characters of a line in an encoding
+// the client and server agree on: UTF-16 code units, unless the client
+// offers another one. The parsers count bytes, which is what the utf-8
+// encoding counts too, so under utf-16 the positions of messages are
+// converted as they cross the JSON-RPC layer: those of requests before
+// they are handled, and those of results and of the messages the server
+// sends. Positions are found by their shape, an object with only a line
+// and a character, and belong to the document named by the nearest uri,
+// targetUri or textDocument around them, or by the keys of the changes of
+// workspace edits, and otherwise to the document of the request.
+
+// Position encodings negotiated with the client.
+const (
+	positionEncodingUTF8  = "utf-8"
+	positionEncodingUTF16 = "utf-16"
+)
+
+// clientPositionEncoding returns the position encoding to use with a
+// client, from its initialize params: utf-8 when it offers it, as positions
+// then need no conversion, and otherwise utf-16, which every client
+// supports.
+func clientPositionEncoding(params json.RawMessage) string {
+	var p struct {
+		Capabilities struct {
+			General struct {
+				PositionEncodings []string `json:"positionEncodings"`
+			} `json:"general"`
+		} `json:"capabilities"`
+	}
+	if len(params) > 0 && json.Unmarshal(params, &p) == nil &&
+		slices.Contains(p.Capabilities.General.PositionEncodings, positionEncodingUTF8) {
+		return positionEncodingUTF8
+	}
+	return positionEncodingUTF16
+}
+
+// convertsPositions reports whether positions exchanged with the client
+// need conversion.
+func (s *Server) convertsPositions() bool {
+	return s.positionEncoding != positionEncodingUTF8
+}
+
+// lineIndex locates the lines of a content with characters other than
+// ASCII, whose byte and UTF-16 columns differ.
+type lineIndex struct {
+	content string
+	starts  []int // Offset of the start of each line
+}
+
+// newLineIndex returns the line index of a content, or nil when it is ASCII
+// and columns need no conversion.
+func newLineIndex(content string) *lineIndex {
+	ascii := true
+	for i := 0; i < len(content); i++ {
+		if content[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return nil
+	}
+
+	starts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{content: content, starts: starts}
+}
+
+// line returns the text of a line without its line break, and whether the
+// content has that line.
+func (idx *lineIndex) line(n int) (string, bool) {
+	if n < 0 || n >= len(idx.starts) {
+		return "", false
+	}
+	end := len(idx.content)
+	if n+1 < len(idx.starts) {
+		end = idx.starts[n+1] - 1
+	}
+	return idx.content[idx.starts[n]:end], true
+}
+
+// utf16Column converts a byte column of a line to UTF-16 code units. A
+// column inside a character counts the whole character, and columns past
+// the end of the line keep their distance to it.
+func (idx *lineIndex) utf16Column(line, col int) int {
+	text, ok := idx.line(line)
+	if !ok || col <= 0 {
+		return col
+	}
+	units := 0
+	for i, r := range text {
+		if i >= col {
+			return units
+		}
+		units += utf16.RuneLen(r)
+	}
+	return units + col - len(text)
+}
+
+// byteColumn converts a column of a line in UTF-16 code units to bytes. A
+// column inside a surrogate pair ends after the pair, and columns past the
+// end of the line keep their distance to it.
+func (idx *lineIndex) byteColumn(line, units int) int {
+	text, ok := idx.line(line)
+	if !ok || units <= 0 {
+		return units
+	}
+	n := 0
+	for i, r := range text {
+		if n >= units {
+			return i
+		}
+		n += utf16.RuneLen(r)
+	}
+	if units > n {
+		return len(text) + units - n
+	}
+	return len(text)
+}
+
+// documentLineIndex returns the line index of a document, computed once
+// per version, or nil when it is ASCII.
+func documentLineIndex(doc *analysis.Document) *lineIndex {
+	value, _ := doc.Derived("lineIndex", func() (any, error) {
+		return newLineIndex(doc.Content), nil
+	})
+	idx, _ := value.(*lineIndex)
+	return idx
+}
+
+// lineIndexOf returns the line index of the document at uri: the open or
+// scanned document, or else the file on disk. It returns nil for ASCII
+// documents and files that cannot be read.
+func (s *Server) lineIndexOf(uri string) *lineIndex {
+	if doc := s.workspace.GetDocument(uri); doc != nil {
+		return documentLineIndex(doc)
+	}
+
+	path := uriToPath(uri)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > s.currentSettings().MaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || analysis.IsBinaryContent(content) {
+		return nil
+	}
+	return newLineIndex(string(content))
+}
+
+// semanticTokensToClient converts the columns and lengths of the semantic
+// tokens of a document, which the protocol sends as numbers rather than
+// positions, to the client's encoding.
+func (s *Server) semanticTokensToClient(doc *analysis.Document, tokens []semanticToken) {
+	if !s.convertsPositions() {
+		return
+	}
+	idx := documentLineIndex(doc)
+	if idx == nil {
+		return
+	}
+	for i := range tokens {
+		tok := &tokens[i]
+		line, start := int(tok.line), int(tok.startChar)
+		startUnits := idx.utf16Column(line, start)
+		endUnits := idx.utf16Column(line, start+int(tok.length))
+		tok.startChar, tok.length = uint32(startUnits), uint32(endUnits-startUnits)
+	}
+}
+
+// characterKey is how every position of a message starts being looked for,
+// to leave the messages without one alone.
+var characterKey = []byte(`"character"`)
+
+// positionsFromClient converts the positions of the params of a request
+// from the client's encoding to byte columns.
+func (s *Server) positionsFromClient(params json.RawMessage) json.RawMessage {
+	if !s.convertsPositions() || !bytes.Contains(params, characterKey) {
+		return params
+	}
+	c := &positionConverter{s: s}
+	converted, ok := c.convertJSON(params, "")
+	if !ok {
+		return params
+	}
+	return converted
+}
+
+// positionsToClient converts the positions of a result or of the params of
+// a message to the client from byte columns to the client's encoding.
+// Positions outside of a document named in the value belong to the
+// document at uri.
+func (s *Server) positionsToClient(value any, uri string) any {
+	if value == nil || !s.convertsPositions() {
+		return value
+	}
+	if _, ok := value.(*protocol.SemanticTokens); ok {
+		return value // Converted as they are encoded
+	}
+	data, err := json.Marshal(value)
+	if err != nil || !bytes.Contains(data, characterKey) {
+		return value
+	}
+	c := &positionConverter{s: s, toClient: true}
+	converted, ok := c.convertJSON(data, uri)
+	if !ok {
+		return value
+	}
+	return converted
+}
+
+// requestDocumentURI returns the URI of the text document of the params of
+// a request, or "" when it has none.
+func requestDocumentURI(params json.RawMessage) string {
+	var p struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+	}
+	if len(params) == 0 || json.Unmarshal(params, &p) != nil {
+		return ""
+	}
+	return p.TextDocument.URI
+}
+
+// positionConverter converts the positions of a message in one direction.
+type positionConverter struct {
+	s        *Server
+	toClient bool
+	indexes  map[string]*lineIndex // By URI
+}
+
+// convertJSON converts the positions of a JSON value, returning false when
+// it cannot be decoded.
+func (c *positionConverter) convertJSON(data []byte, uri string) (json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if dec.Decode(&value) != nil {
+		return nil, false
+	}
+	converted, err := json.Marshal(c.walk(value, uri))
+	if err != nil {
+		return nil, false
+	}
+	return converted, true
+}
+
+// walk converts the positions in a decoded JSON value, which belong to the
+// document at uri unless the value names another one.
+func (c *positionConverter) walk(value any, uri string) any {
+	switch v := value.(type) {
+	case []any:
+		for i := range v {
+			v[i] = c.walk(v[i], uri)
+		}
+	case map[string]any:
+		if c.convertPosition(v, uri) {
+			return v
+		}
+		outer := uri
+		if u, ok := v["uri"].(string); ok {
+			uri = u
+		}
+		if u, ok := v["targetUri"].(string); ok {
+			uri = u
+		}
+		if doc, ok := v["textDocument"].(map[string]any); ok {
+			if u, ok := doc["uri"].(string); ok {
+				uri = u
+			}
+		}
+		for key, item := range v {
+			switch key {
+			case "originSelectionRange":
+				// The range of a location link in the document it starts from
+				v[key] = c.walk(item, outer)
+			case "changes", "relatedDocuments":
+				// Workspace edits and related diagnostics, by document URI
+				if byURI, ok := item.(map[string]any); ok {
+					for u, edits := range byURI {
+						byURI[u] = c.walk(edits, u)
+					}
+					continue
+				}
+				v[key] = c.walk(item, uri)
+			default:
+				v[key] = c.walk(item, uri)
+			}
+		}
+	}
+	return value
+}
+
+// convertPosition converts a position of the document at uri, returning
+// false when the object is not a position.
+func (c *positionConverter) convertPosition(obj map[string]any, uri string) bool {
+	if len(obj) != 2 {
+		return false
+	}
+	lineNum, ok := obj["line"].(json.Number)
+	if !ok {
+		return false
+	}
+	charNum, ok := obj["character"].(json.Number)
+	if !ok {
+		return false
+	}
+
+	idx := c.index(uri)
+	if idx == nil {
+		return true
+	}
+	line, err := lineNum.Int64()
+	if err != nil {
+		return true
+	}
+	char, err := charNum.Int64()
+	if err != nil {
+		return true
+	}
+	if c.toClient {
+		char = int64(idx.utf16Column(int(line), int(char)))
+	} else {
+		char = int64(idx.byteColumn(int(line), int(char)))
+	}
+	obj["character"] = json.Number(strconv.FormatInt(char, 10))
+	return true
+}
+
+// index returns the line index of the document at uri, looked up once per
+// message.
+func (c *positionConverter) index(uri string) *lineIndex {
+	if uri == "" {
+		return nil
+	}
+	if idx, ok := c.indexes[uri]; ok {
+		return idx
+	}
+	if c.indexes == nil {
+		c.indexes = make(map[string]*lineIndex)
+	}
+	idx := c.s.lineIndexOf(uri)
+	c.indexes[uri] = idx
+	return idx
+}
@@ -0,0 +1,155 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestLineIndexColumns(t *testing.T) {
+	if idx := newLineIndex("[node name=\"Root\"]\n"); idx != nil {
+		t.Fatal("expected no line index for ASCII content")
+	}
+
+	// é is 2 bytes and 1 unit, 日 3 bytes and 1 unit, 🌟 4 bytes and 2 units
+	idx := newLineIndex("ascii\né日🌟x\n")
+	tests := []struct {
+		line, bytes, units int
+	}{
+		{0, 3, 3},
+		{1, 0, 0},
+		{1, 2, 1},
+		{1, 5, 2},
+		{1, 9, 4},
+		{1, 10, 5},
+		{1, 12, 7}, // Past the end of the line
+		{5, 4, 4},  // Past the end of the content
+	}
+	for _, tt := range tests {
+		if got := idx.utf16Column(tt.line, tt.bytes); got != tt.units {
+			t.Errorf("utf16Column(%d, %d) = %d, want %d", tt.line, tt.bytes, got, tt.units)
+		}
+		if got := idx.byteColumn(tt.line, tt.units); got != tt.bytes {
+			t.Errorf("byteColumn(%d, %d) = %d, want %d", tt.line, tt.units, got, tt.bytes)
+		}
+	}
+
+	// Inside a character
+	if got := idx.utf16Column(1, 7); got != 4 {
+		t.Errorf("utf16Column inside 🌟 = %d, want 4", got)
+	}
+	if got := idx.byteColumn(1, 3); got != 9 {
+		t.Errorf("byteColumn inside 🌟 = %d, want 9", got)
+	}
+}
+
+func TestSemanticTokensToClient(t *testing.T) {
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///test/tokens.tscn", "x\né日🌟x\n")
+
+	tokens := []semanticToken{{line: 1, startChar: 2, length: 7}, {line: 0, startChar: 0, length: 1}}
+	s.semanticTokensToClient(doc, tokens)
+	if tokens[0].startChar != 1 || tokens[0].length != 3 {
+		t.Errorf("expected 日🌟 at 1 for 3 units, got %d for %d", tokens[0].startChar, tokens[0].length)
+	}
+	if tokens[1].startChar != 0 || tokens[1].length != 1 {
+		t.Errorf("expected an ASCII line to be left alone, got %d for %d", tokens[1].startChar, tokens[1].length)
+	}
+}
+
+func TestClientPositionEncoding(t *testing.T) {
+	tests := []struct {
+		params string
+		want   string
+	}{
+		{`{}`, positionEncodingUTF16},
+		{`{"capabilities":{"general":{"positionEncodings":["utf-16"]}}}`, positionEncodingUTF16},
+		{`{"capabilities":{"general":{"positionEncodings":["utf-32","utf-8","utf-16"]}}}`, positionEncodingUTF8},
+	}
+	for _, tt := range tests {
+		if got := clientPositionEncoding(json.RawMessage(tt.params)); got != tt.want {
+			t.Errorf("clientPositionEncoding(%s) = %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestPositionEncodingOverRPC(t *testing.T) {
+	content := `[gd_scene load_steps=2 format=3]
+
+[sub_resource type="Gradient" id="Grad_1"]
+
+[node name="Étoile" type="Node2D"]
+metadata/note = ["日本🌟", SubResource("Grad_1")]
+`
+	uri := "file:///test/encoding.tscn"
+	line := 5
+	prefix := `metadata/note = ["日本🌟", SubResource("`
+	byteCol := len(prefix)
+	utf16Col := byteCol - 6 // 日 and 本 take 3 bytes and 1 unit each, 🌟 4 bytes and 2 units
+
+	tests := []struct {
+		name       string
+		encodings  []string
+		want       string
+		wantColumn int
+	}{
+		{"utf-16", nil, positionEncodingUTF16, utf16Col},
+		{"utf-8", []string{"utf-16", "utf-8"}, positionEncodingUTF8, byteCol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := connectServer(t, NewServer("test", "test"))
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var result struct {
+				Capabilities struct {
+					PositionEncoding string `json:"positionEncoding"`
+				} `json:"capabilities"`
+			}
+			params := map[string]any{"capabilities": map[string]any{}}
+			if tt.encodings != nil {
+				params["capabilities"] = map[string]any{"general": map[string]any{"positionEncodings": tt.encodings}}
+			}
+			if err := client.Call(ctx, "initialize", params, &result); err != nil {
+				t.Fatalf("initialize failed: %v", err)
+			}
+			if result.Capabilities.PositionEncoding != tt.want {
+				t.Errorf("expected position encoding %q, got %q", tt.want, result.Capabilities.PositionEncoding)
+			}
+
+			if err := client.Notify(ctx, "textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+				TextDocument: protocol.TextDocumentItem{URI: uri, LanguageID: "tscn", Version: 1, Text: content},
+			}); err != nil {
+				t.Fatalf("didOpen failed: %v", err)
+			}
+
+			var ranges protocol.LinkedEditingRanges
+			if err := client.Call(ctx, "textDocument/linkedEditingRange", protocol.LinkedEditingRangeParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+					Position:     protocol.Position{Line: uint32(line), Character: uint32(tt.wantColumn + 1)},
+				},
+			}, &ranges); err != nil {
+				t.Fatalf("linkedEditingRange failed: %v", err)
+			}
+			if len(ranges.Ranges) != 2 {
+				t.Fatalf("expected 2 ranges, got %v", ranges.Ranges)
+			}
+			got := ranges.Ranges[1]
+			want := protocol.Range{
+				Start: protocol.Position{Line: uint32(line), Character: uint32(tt.wantColumn)},
+				End:   protocol.Position{Line: uint32(line), Character: uint32(tt.wantColumn + len("Grad_1"))},
+			}
+			if got != want {
+				t.Errorf("expected the reference at %v, got %v", want, got)
+			}
+			if decl := ranges.Ranges[0]; decl.Start.Line != 2 || decl.Start.Character != uint32(len(`[sub_resource type="Gradient" id="`)) {
+				t.Errorf("expected the declaration on line 2, got %v", decl)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workDoneProgress reports the progress of an operation the server starts
+// on its own through window/workDoneProgress. The user can cancel it from
+// the client, which cancels its context. A nil progress reports nothing
+// and is never canceled, for clients without progress support.
+type workDoneProgress struct {
+	s      *Server
+	glsp   *glsp.Context
+	token  string
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	percentage protocol.UInteger // Last percentage reported
+}
+
+// beginProgress starts reporting the progress of an operation under title.
+// It calls the client, so it must not run on the read loop.
+func (s *Server) beginProgress(ctx *glsp.Context, title string) *workDoneProgress {
+	if !s.workDoneProgress {
+		return nil
+	}
+
+	s.progressMu.Lock()
+	s.progressID++
+	p := &workDoneProgress{
+		s:     s,
+		glsp:  ctx,
+		token: fmt.Sprintf("gdls-progress-%d", s.progressID),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	s.progress[p.token] = p.cancel
+	s.progressMu.Unlock()
+
+	token := protocol.ProgressToken{Value: p.token}
+	ctx.Call(string(protocol.ServerWindowWorkDoneProgressCreate), protocol.WorkDoneProgressCreateParams{Token: token}, nil)
+	ctx.Notify(string(protocol.MethodProgress), protocol.ProgressParams{
+		Token: token,
+		Value: protocol.WorkDoneProgressBegin{
+			Kind:        "begin",
+			Title:       title,
+			Cancellable: boolPtr(true),
+			Percentage:  &p.percentage,
+		},
+	})
+	return p
+}
+
+// context returns the context canceled when the user cancels the operation.
+func (p *workDoneProgress) context() context.Context {
+	if p == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// reportFiles reports that done of total files were processed, e.g.
+// "12/40 files". Reports are only sent when the percentage changes, so a
+// large project does not flood the client.
+func (p *workDoneProgress) reportFiles(done, total int) {
+	if p == nil || total == 0 {
+		return
+	}
+	percentage := protocol.UInteger(done * 100 / total)
+	if percentage == p.percentage && done != total {
+		return
+	}
+	p.percentage = percentage
+
+	p.glsp.Notify(string(protocol.MethodProgress), protocol.ProgressParams{
+		Token: protocol.ProgressToken{Value: p.token},
+		Value: protocol.WorkDoneProgressReport{
+			Kind:       "report",
+			Message:    strPtr(fmt.Sprintf("%d/%d files", done, total)),
+			Percentage: &percentage,
+		},
+	})
+}
+
+// end finishes the operation with a final message.
+func (p *workDoneProgress) end(message string) {
+	if p == nil {
+		return
+	}
+	p.s.progressMu.Lock()
+	delete(p.s.progress, p.token)
+	p.s.progressMu.Unlock()
+	p.cancel()
+
+	p.glsp.Notify(string(protocol.MethodProgress), protocol.ProgressParams{
+		Token: protocol.ProgressToken{Value: p.token},
+		Value: protocol.WorkDoneProgressEnd{Kind: "end", Message: strPtr(message)},
+	})
+}
+
+// windowWorkDoneProgressCancel handles the window/workDoneProgress/cancel
+// notification sent when the user cancels an operation.
+func (s *Server) windowWorkDoneProgressCancel(ctx *glsp.Context, params *protocol.WorkDoneProgressCancelParams) error {
+	token, ok := params.Token.Value.(string)
+	if !ok {
+		return nil
+	}
+
+	s.progressMu.Lock()
+	cancel := s.progress[token]
+	s.progressMu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	return nil
+}
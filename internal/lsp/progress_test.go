@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestIndexingProgress(t *testing.T) {
+	// Enough files that the scan outlasts the workers checking them
+	dir := t.TempDir()
+	for i := range 200 {
+		name := filepath.Join(dir, fmt.Sprintf("scene%d.tscn", i))
+		if err := os.WriteFile(name, []byte("[gd_scene format=3]\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// index runs the initial scan, canceling it at the first report when
+	// cancel is set, and returns the progress messages and folder statuses
+	index := func(cancel bool) (progress, statuses []string) {
+		s := NewServer("test", "test")
+		s.workDoneProgress = true
+		s.workspace.AddFolder(pathToURI(dir))
+
+		var created []string
+		ctx := &glsp.Context{
+			Call: func(method string, params any, result any) {
+				if method == string(protocol.ServerWindowWorkDoneProgressCreate) {
+					created = append(created, params.(protocol.WorkDoneProgressCreateParams).Token.Value.(string))
+				}
+			},
+		}
+		ctx.Notify = func(method string, params any) {
+			switch method {
+			case StatusNotification:
+				statuses = append(statuses, params.(statusParams).Message)
+			case string(protocol.MethodProgress):
+				p := params.(protocol.ProgressParams)
+				if len(created) != 1 || p.Token.Value != created[0] {
+					t.Errorf("expected progress on the created token %v, got %v", created, p.Token.Value)
+				}
+				switch value := p.Value.(type) {
+				case protocol.WorkDoneProgressBegin:
+					progress = append(progress, "begin "+value.Title)
+				case protocol.WorkDoneProgressReport:
+					progress = append(progress, *value.Message)
+					if cancel {
+						s.windowWorkDoneProgressCancel(ctx, &protocol.WorkDoneProgressCancelParams{Token: p.Token})
+					}
+				case protocol.WorkDoneProgressEnd:
+					progress = append(progress, "end "+*value.Message)
+				}
+			}
+		}
+		s.indexWorkspace(ctx)
+		return progress, statuses
+	}
+
+	progress, statuses := index(false)
+	if len(progress) < 3 || progress[0] != "begin Indexing Godot project" ||
+		progress[len(progress)-2] != "200/200 files" || progress[len(progress)-1] != "end Indexed 200 files" {
+		t.Errorf("expected progress up to 200/200 files, got %v", progress)
+	}
+	if len(progress) > 103 {
+		t.Errorf("expected at most one report per percent, got %d", len(progress)-2)
+	}
+	if len(statuses) != 1 || !strings.HasPrefix(statuses[0], "Indexed 200 files") {
+		t.Errorf("expected the folder status, got %v", statuses)
+	}
+
+	progress, statuses = index(true)
+	if progress[len(progress)-1] != "end Indexing canceled" {
+		t.Errorf("expected the progress to end canceled, got %v", progress)
+	}
+	if len(statuses) != 1 || !strings.HasPrefix(statuses[0], "Indexing canceled after") {
+		t.Errorf("expected a canceled status, got %v", statuses)
+	}
+}
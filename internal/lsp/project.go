@@ -0,0 +1,356 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// projectSectionDescriptions describes the well-known sections of project.godot.
+var projectSectionDescriptions = map[string]string{
+	"application":          "General application settings: name, main scene, icon and boot splash.",
+	"autoload":             "Scripts and scenes added under /root when the game starts. A `*` prefix also registers them as global singletons.",
+	"input":                "Input actions and the events (keys, buttons, axes) bound to them.",
+	"display":              "Window size, stretch mode and other display settings.",
+	"rendering":            "Renderer selection and rendering quality settings.",
+	"physics":              "2D and 3D physics engine settings.",
+	"audio":                "Audio driver and bus layout settings.",
+	"layer_names":          "Names for the render, physics, navigation and avoidance layers.",
+	"editor_plugins":       "Editor plugins enabled for this project.",
+	"global_group":         "Groups available to every scene in the project.",
+	"internationalization": "Translations and locale settings.",
+	"gui":                  "Theme and GUI behavior settings.",
+	"debug":                "Debugging and GDScript warning settings.",
+	"filesystem":           "Import and file system settings.",
+	"dotnet":               "C# project settings.",
+}
+
+// projectSettingDescriptions describes well-known settings by their full
+// section/key name.
+var projectSettingDescriptions = map[string]string{
+	"config_version":                                            "Version of the project file format. Godot 4 writes `5`.",
+	"application/config/name":                                   "The project name, shown in the project manager and as the window title.",
+	"application/config/description":                            "The project description, shown in the project manager.",
+	"application/config/version":                                "The project version, used by exporters.",
+	"application/config/icon":                                   "Icon used for the project and its window.",
+	"application/config/features":                               "Engine version and renderer the project was created with.",
+	"application/run/main_scene":                                "The scene loaded when the project runs.",
+	"application/boot_splash/image":                             "Image shown while the project boots.",
+	"application/boot_splash/bg_color":                          "Background color of the boot splash.",
+	"display/window/size/viewport_width":                        "Width of the game viewport in pixels.",
+	"display/window/size/viewport_height":                       "Height of the game viewport in pixels.",
+	"display/window/size/mode":                                  "Window mode at startup: windowed, minimized, maximized or fullscreen.",
+	"display/window/stretch/mode":                               "How the viewport is stretched to the window: `disabled`, `canvas_items` or `viewport`.",
+	"display/window/stretch/aspect":                             "How the aspect ratio is kept when stretching.",
+	"rendering/renderer/rendering_method":                       "Renderer used on desktop: `forward_plus`, `mobile` or `gl_compatibility`.",
+	"rendering/renderer/rendering_method.mobile":                "Renderer used on mobile platforms.",
+	"rendering/textures/canvas_textures/default_texture_filter": "Default texture filter for 2D, e.g. `0` for nearest.",
+	"physics/common/physics_ticks_per_second":                   "Number of physics steps per second.",
+	"physics/2d/default_gravity":                                "Default gravity strength in 2D.",
+	"physics/3d/default_gravity":                                "Default gravity strength in 3D.",
+	"gui/theme/custom":                                          "Theme applied to every Control in the project.",
+	"internationalization/locale/translations":                  "Translation files loaded at startup.",
+}
+
+// configDiagnostics computes diagnostics for project.godot, .import,
+// .gdextension and other ConfigFile documents.
+func (s *Server) configDiagnostics(doc *analysis.Document) []protocol.Diagnostic {
+	cfg := doc.ConfigAST
+	if cfg == nil {
+		return nil
+	}
+
+	diagnostics := []protocol.Diagnostic{}
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, code, msg string, related ...protocol.DiagnosticRelatedInformation) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:              toProtocolRange(r),
+			Severity:           severityPtr(severity),
+			Source:             strPtr("gdls"),
+			Code:               diagnosticCode(code),
+			Message:            msg,
+			RelatedInformation: related,
+		})
+	}
+	firstDeclared := func(r parser.Range) protocol.DiagnosticRelatedInformation {
+		return protocol.DiagnosticRelatedInformation{
+			Location: protocol.Location{URI: doc.URI, Range: toProtocolRange(r)},
+			Message:  "First declared here",
+		}
+	}
+
+	for _, err := range cfg.Errors {
+		add(err.Range, protocol.DiagnosticSeverityError, codeParseError, err.Message)
+	}
+
+	// Godot merges sections that appear twice and keeps the last value of
+	// a repeated key, which usually hides a merge mistake
+	sectionSeen := make(map[string]parser.Range)
+	keySeen := make(map[string]parser.Range)
+	for _, prop := range cfg.Properties {
+		if first, ok := keySeen[prop.Key]; ok {
+			add(prop.KeyRange, protocol.DiagnosticSeverityWarning, codeDuplicateKey,
+				fmt.Sprintf("Duplicate key '%s'; the last value is used", prop.Key), firstDeclared(first))
+			continue
+		}
+		keySeen[prop.Key] = prop.KeyRange
+	}
+	for _, section := range cfg.Sections {
+		if first, ok := sectionSeen[section.Name]; ok {
+			add(section.NameRange, protocol.DiagnosticSeverityWarning, codeDuplicateSection,
+				fmt.Sprintf("Section [%s] is declared more than once", section.Name), firstDeclared(first))
+		} else {
+			sectionSeen[section.Name] = section.NameRange
+		}
+
+		for _, prop := range section.Properties {
+			fullKey := section.Name + "/" + prop.Key
+			if first, ok := keySeen[fullKey]; ok {
+				add(prop.KeyRange, protocol.DiagnosticSeverityWarning, codeDuplicateKey,
+					fmt.Sprintf("Duplicate key '%s' in [%s]; the last value is used", prop.Key, section.Name), firstDeclared(first))
+				continue
+			}
+			keySeen[fullKey] = prop.KeyRange
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(doc.URI, analysis.ImportFileExt):
+		checkImportFile(cfg, add)
+		return diagnostics
+	case strings.HasSuffix(doc.URI, analysis.GDExtensionFileExt):
+		checkGDExtension(cfg, add)
+		return diagnostics
+	case !strings.HasSuffix(doc.URI, "/"+analysis.ProjectFileName):
+		return diagnostics
+	}
+
+	// Value checks for well-known project settings
+	for _, prop := range cfg.Properties {
+		if prop.Key == "config_version" {
+			if n, ok := prop.Value.(*parser.NumberValue); !ok || !n.IsInt {
+				add(prop.Value.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, "config_version must be an integer")
+			}
+		}
+	}
+	for _, section := range cfg.Sections {
+		for _, prop := range section.Properties {
+			if msg := checkProjectSetting(section.Name, prop); msg != "" {
+				add(prop.Value.GetRange(), protocol.DiagnosticSeverityError, codeInvalidSetting, msg)
+			}
+		}
+	}
+
+	return diagnostics
+}
+
+// checkProjectSetting validates the value of a well-known setting, returning
+// an error message or "".
+func checkProjectSetting(section string, prop *parser.Property) string {
+	switch {
+	case section == "autoload":
+		sv, ok := prop.Value.(*parser.StringValue)
+		if !ok {
+			return fmt.Sprintf("Autoload '%s' must be a path string such as \"*res://%s.gd\"", prop.Key, strings.ToLower(prop.Key))
+		}
+		if path, _ := analysis.ParseAutoload(sv.Value); !isResourcePath(path) {
+			return fmt.Sprintf("Autoload '%s' must point to a res:// or uid:// path", prop.Key)
+		}
+	case section == "input":
+		dict, ok := prop.Value.(*parser.DictValue)
+		if !ok {
+			return fmt.Sprintf("Input action '%s' must be a dictionary with \"deadzone\" and \"events\"", prop.Key)
+		}
+		if events := dictEntry(dict, "events"); events != nil {
+			if _, ok := events.(*parser.ArrayValue); !ok {
+				return fmt.Sprintf("\"events\" of input action '%s' must be an array", prop.Key)
+			}
+		}
+	case section == "application" && prop.Key == "run/main_scene":
+		sv, ok := prop.Value.(*parser.StringValue)
+		if !ok || !isResourcePath(sv.Value) {
+			return "Main scene must be a res:// or uid:// path string"
+		}
+	}
+	return ""
+}
+
+// isResourcePath reports whether path is a res:// or uid:// path.
+func isResourcePath(path string) bool {
+	return strings.HasPrefix(path, "res://") || strings.HasPrefix(path, "uid://")
+}
+
+// dictEntry returns the value for a string or identifier key in a dictionary.
+func dictEntry(dict *parser.DictValue, key string) parser.Value {
+	for _, entry := range dict.Entries {
+		switch k := entry.Key.(type) {
+		case *parser.StringValue:
+			if k.Value == key {
+				return entry.Value
+			}
+		case *parser.IdentValue:
+			if k.Name == key {
+				return entry.Value
+			}
+		}
+	}
+	return nil
+}
+
+// configDocumentSymbols returns one symbol per section with its keys as children.
+func (s *Server) configDocumentSymbols(doc *analysis.Document) (any, error) {
+	cfg := doc.ConfigAST
+	if cfg == nil {
+		return nil, nil
+	}
+
+	symbols := []protocol.DocumentSymbol{}
+	for _, prop := range cfg.Properties {
+		symbols = append(symbols, configPropertySymbol("", prop))
+	}
+
+	for _, section := range cfg.Sections {
+		sym := protocol.DocumentSymbol{
+			Name:           section.Name,
+			Kind:           protocol.SymbolKindNamespace,
+			Range:          toProtocolRange(section.Range),
+			SelectionRange: toProtocolRange(section.NameRange),
+		}
+		for _, prop := range section.Properties {
+			sym.Children = append(sym.Children, configPropertySymbol(section.Name, prop))
+		}
+		symbols = append(symbols, sym)
+	}
+
+	return symbols, nil
+}
+
+// configPropertySymbol returns the symbol for a key in the given section.
+func configPropertySymbol(section string, prop *parser.Property) protocol.DocumentSymbol {
+	kind := protocol.SymbolKindProperty
+	detail := describeValueType(prop.Value)
+	switch section {
+	case "autoload":
+		kind = protocol.SymbolKindClass
+		if sv, ok := prop.Value.(*parser.StringValue); ok {
+			detail, _ = analysis.ParseAutoload(sv.Value)
+		}
+	case "input":
+		kind = protocol.SymbolKindEvent
+		detail = "input action"
+	default:
+		if sv, ok := prop.Value.(*parser.StringValue); ok {
+			detail = sv.Value
+		}
+	}
+
+	return protocol.DocumentSymbol{
+		Name:           prop.Key,
+		Detail:         strPtr(detail),
+		Kind:           kind,
+		Range:          toProtocolRange(prop.Range),
+		SelectionRange: toProtocolRange(prop.KeyRange),
+	}
+}
+
+// findConfigHoverInfo finds hover information for a ConfigFile position.
+func (s *Server) findConfigHoverInfo(doc *analysis.Document, line, col int) string {
+	cfg := doc.ConfigAST
+	sections, settings := configDescriptions(doc.URI)
+
+	for _, prop := range cfg.Properties {
+		if isInRange(prop.Range, line, col) {
+			return formatConfigSettingHover(settings, "", prop)
+		}
+	}
+
+	for _, section := range cfg.Sections {
+		if isInRange(section.NameRange, line, col) {
+			return formatConfigSectionHover(sections, section)
+		}
+		for _, prop := range section.Properties {
+			if isInRange(prop.Range, line, col) {
+				switch section.Name {
+				case "autoload":
+					return formatAutoloadHover(prop)
+				case "input":
+					return formatInputActionHover(prop)
+				}
+				return formatConfigSettingHover(settings, section.Name, prop)
+			}
+		}
+	}
+
+	return ""
+}
+
+func formatConfigSectionHover(descriptions map[string]string, section *parser.ConfigSection) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Section: `[%s]`\n\n", section.Name))
+	if desc, ok := descriptions[section.Name]; ok {
+		sb.WriteString(fmt.Sprintf("_%s_\n\n", desc))
+	}
+	sb.WriteString(fmt.Sprintf("**Keys:** %d\n", len(section.Properties)))
+	return sb.String()
+}
+
+func formatConfigSettingHover(descriptions map[string]string, section string, prop *parser.Property) string {
+	name := prop.Key
+	if section != "" {
+		name = section + "/" + prop.Key
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("### Setting: `%s`\n\n", name))
+	sb.WriteString(fmt.Sprintf("**Value Type:** `%s`\n\n", describeValueType(prop.Value)))
+	if desc, ok := descriptions[name]; ok {
+		sb.WriteString(fmt.Sprintf("_%s_\n", desc))
+	}
+	return sb.String()
+}
+
+func formatAutoloadHover(prop *parser.Property) string {
+	var sb strings.Builder
+	sb.WriteString("### Autoload\n\n")
+	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", prop.Key))
+	if sv, ok := prop.Value.(*parser.StringValue); ok {
+		path, singleton := analysis.ParseAutoload(sv.Value)
+		sb.WriteString(fmt.Sprintf("**Path:** `%s`\n\n", path))
+		sb.WriteString(fmt.Sprintf("**Node Path:** `/root/%s`\n\n", prop.Key))
+		if singleton {
+			sb.WriteString(fmt.Sprintf("_Global singleton: accessible from any script as `%s`._\n", prop.Key))
+		} else {
+			sb.WriteString("_Not a global singleton: access it through its node path._\n")
+		}
+	}
+	return sb.String()
+}
+
+func formatInputActionHover(prop *parser.Property) string {
+	var sb strings.Builder
+	sb.WriteString("### Input Action\n\n")
+	sb.WriteString(fmt.Sprintf("**Name:** `%s`\n\n", prop.Key))
+
+	dict, ok := prop.Value.(*parser.DictValue)
+	if !ok {
+		return sb.String()
+	}
+	if n, ok := dictEntry(dict, "deadzone").(*parser.NumberValue); ok {
+		sb.WriteString(fmt.Sprintf("**Deadzone:** `%s`\n\n", n.RawValue))
+	}
+	if events, ok := dictEntry(dict, "events").(*parser.ArrayValue); ok {
+		sb.WriteString(fmt.Sprintf("**Events:** %d\n", len(events.Values)))
+		for _, ev := range events.Values {
+			// Events are written as Object(InputEventKey, "key": value, ...)
+			if obj, ok := ev.(*parser.TypedValue); ok && len(obj.Arguments) > 0 {
+				if class, ok := obj.Arguments[0].(*parser.IdentValue); ok {
+					sb.WriteString(fmt.Sprintf("- `%s`\n", class.Name))
+				}
+			}
+		}
+	}
+	return sb.String()
+}
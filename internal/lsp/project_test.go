@@ -0,0 +1,142 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+const projectFile = `config_version=5
+
+[application]
+
+config/name="My Game"
+run/main_scene=42
+config/name="Renamed"
+
+[autoload]
+
+Global="*res://global.gd"
+Events="events.gd"
+
+[input]
+
+jump={
+"deadzone": 0.5,
+"events": [Object(InputEventKey,"resource_local_to_scene":false,"physical_keycode":32,"script":null)]
+}
+`
+
+func TestProjectDiagnostics(t *testing.T) {
+	s := NewServer("test", "test")
+	diagnostics := s.Check("file:///game/project.godot", projectFile)
+
+	expected := map[string]protocol.DiagnosticSeverity{
+		"Main scene must be a res:// or uid:// path string":                    protocol.DiagnosticSeverityError,
+		"Duplicate key 'config/name' in [application]; the last value is used": protocol.DiagnosticSeverityWarning,
+		"Autoload 'Events' must point to a res:// or uid:// path":              protocol.DiagnosticSeverityError,
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("expected %d diagnostics, got %v", len(expected), diagnostics)
+	}
+	for _, d := range diagnostics {
+		severity, ok := expected[d.Message]
+		if !ok {
+			t.Errorf("unexpected diagnostic %q", d.Message)
+			continue
+		}
+		if *d.Severity != severity {
+			t.Errorf("expected severity %d for %q, got %d", severity, d.Message, *d.Severity)
+		}
+		if strings.HasPrefix(d.Message, "Duplicate key") {
+			if len(d.RelatedInformation) != 1 || d.RelatedInformation[0].Location.Range.Start.Line != 4 {
+				t.Errorf("expected the duplicate key to point at line 4, got %+v", d.RelatedInformation)
+			}
+		}
+	}
+}
+
+func TestProjectHoverAndSymbols(t *testing.T) {
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///game/project.godot", projectFile)
+
+	lines := strings.Split(projectFile, "\n")
+	lineOf := func(prefix string) int {
+		for i, l := range lines {
+			if strings.HasPrefix(l, prefix) {
+				return i
+			}
+		}
+		t.Fatalf("line %q not found", prefix)
+		return -1
+	}
+
+	hover := s.findConfigHoverInfo(doc, lineOf("config/name"), 2)
+	if !strings.Contains(hover, "`application/config/name`") || !strings.Contains(hover, "project name") {
+		t.Errorf("unexpected setting hover:\n%s", hover)
+	}
+
+	hover = s.findConfigHoverInfo(doc, lineOf("Global"), 2)
+	if !strings.Contains(hover, "### Autoload") || !strings.Contains(hover, "Global singleton") {
+		t.Errorf("unexpected autoload hover:\n%s", hover)
+	}
+
+	hover = s.findConfigHoverInfo(doc, lineOf("jump"), 1)
+	if !strings.Contains(hover, "**Deadzone:** `0.5`") || !strings.Contains(hover, "- `InputEventKey`") {
+		t.Errorf("unexpected input action hover:\n%s", hover)
+	}
+
+	hover = s.findConfigHoverInfo(doc, lineOf("[input]"), 2)
+	if !strings.Contains(hover, "### Section: `[input]`") {
+		t.Errorf("unexpected section hover:\n%s", hover)
+	}
+
+	result, err := s.configDocumentSymbols(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := result.([]protocol.DocumentSymbol)
+	if len(symbols) != 4 || symbols[1].Name != "application" || len(symbols[2].Children) != 2 {
+		t.Errorf("unexpected symbols: %+v", symbols)
+	}
+}
+
+func TestProjectAutoloadIndex(t *testing.T) {
+	dir := t.TempDir()
+	content := "[autoload]\n\nGlobal=\"*res://global.gd\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "project.godot"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer("test", "test")
+	status, _ := s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	autoloads := s.workspace.Autoloads("")
+	if len(autoloads) != 1 || autoloads[0].Name != "Global" || !autoloads[0].Singleton {
+		t.Fatalf("expected Global autoload from disk, got %+v", autoloads)
+	}
+	if !strings.HasPrefix(status.Message, "Indexed 1 files") {
+		t.Errorf("unexpected scan status: %+v", status)
+	}
+
+	// Opening the project file replaces what was read from disk
+	s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "project.godot")), content+"Music=\"res://music.tscn\"\n")
+	if got := len(s.workspace.Autoloads("")); got != 2 {
+		t.Errorf("expected 2 autoloads after opening the project file, got %d", got)
+	}
+
+	doc := s.workspace.OpenDocument("file:///tmp/scene.tscn", "[gd_scene format=3]\n")
+	found := false
+	for _, item := range s.getNodePathCompletions(doc) {
+		if item.Label == "/root/Music" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected /root/Music in node path completions")
+	}
+}
@@ -0,0 +1,264 @@
+package lsp
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// LSP 3.17 pull diagnostics, which the protocol package predates. Clients
+// that pull ask for the diagnostics of a document when they need them, and
+// for the whole project with workspace/diagnostic. Reports carry a result
+// id, so that unchanged diagnostics are not sent again.
+const (
+	methodTextDocumentDiagnostic     = "textDocument/diagnostic"
+	methodWorkspaceDiagnostic        = "workspace/diagnostic"
+	methodWorkspaceDiagnosticRefresh = "workspace/diagnostic/refresh"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "pullDiagnostics",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[methodTextDocumentDiagnostic] = customRequest(s.textDocumentDiagnostic)
+			s.custom[methodWorkspaceDiagnostic] = customRequest(s.workspaceDiagnostic)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DiagnosticProvider = &diagnosticOptions{
+				Identifier:            "gdls",
+				InterFileDependencies: true, // Paths, uids and resource types
+				WorkspaceDiagnostics:  true,
+			}
+		},
+	})
+}
+
+// diagnosticOptions are the diagnosticProvider server capabilities.
+type diagnosticOptions struct {
+	Identifier            string `json:"identifier,omitempty"`
+	InterFileDependencies bool   `json:"interFileDependencies"`
+	WorkspaceDiagnostics  bool   `json:"workspaceDiagnostics"`
+}
+
+// documentDiagnosticParams are the parameters of the textDocument/diagnostic
+// request.
+type documentDiagnosticParams struct {
+	TextDocument     protocol.TextDocumentIdentifier `json:"textDocument"`
+	Identifier       string                          `json:"identifier,omitempty"`
+	PreviousResultID string                          `json:"previousResultId,omitempty"`
+}
+
+// workspaceDiagnosticParams are the parameters of the workspace/diagnostic
+// request.
+type workspaceDiagnosticParams struct {
+	Identifier        string             `json:"identifier,omitempty"`
+	PreviousResultIDs []previousResultID `json:"previousResultIds"`
+}
+
+// previousResultID is the result id the client holds for a document.
+type previousResultID struct {
+	URI   string `json:"uri"`
+	Value string `json:"value"`
+}
+
+// Kinds of diagnostic reports.
+const (
+	reportFull      = "full"
+	reportUnchanged = "unchanged"
+)
+
+// documentDiagnosticReport is a full or unchanged diagnostic report. Items
+// is only set on full reports.
+type documentDiagnosticReport struct {
+	Kind     string                `json:"kind"`
+	ResultID string                `json:"resultId"`
+	Items    []protocol.Diagnostic `json:"items,omitzero"`
+}
+
+// workspaceDocumentDiagnosticReport is the report of one document in a
+// workspace/diagnostic result. Version is always null: reports are not tied
+// to the versions the client sent.
+type workspaceDocumentDiagnosticReport struct {
+	Kind     string                `json:"kind"`
+	ResultID string                `json:"resultId"`
+	Items    []protocol.Diagnostic `json:"items,omitzero"`
+	URI      string                `json:"uri"`
+	Version  *int32                `json:"version"`
+}
+
+// workspaceDiagnosticReport is the result of the workspace/diagnostic
+// request.
+type workspaceDiagnosticReport struct {
+	Items []workspaceDocumentDiagnosticReport `json:"items"`
+}
+
+// fileReport caches the diagnostics of a file that is not open, until the
+// file changes on disk or the workspace does.
+type fileReport struct {
+	modTime     time.Time
+	size        int64
+	resultID    string
+	diagnostics []protocol.Diagnostic
+}
+
+// textDocumentDiagnostic handles the textDocument/diagnostic request.
+func (s *Server) textDocumentDiagnostic(ctx *glsp.Context, params *documentDiagnosticParams) (any, error) {
+	diagnostics, resultID := s.documentDiagnostics(params.TextDocument.URI)
+	if diagnostics == nil {
+		diagnostics = []protocol.Diagnostic{}
+		resultID = diagnosticsResultID(diagnostics)
+	}
+	return newDiagnosticReport(diagnostics, resultID, params.PreviousResultID), nil
+}
+
+// workspaceDiagnostic handles the workspace/diagnostic request, reporting
+// every document of the workspace folders and every open document.
+func (s *Server) workspaceDiagnostic(ctx *glsp.Context, params *workspaceDiagnosticParams) (any, error) {
+	reqCtx := s.requestContext(ctx)
+
+	previous := make(map[string]string, len(params.PreviousResultIDs))
+	for _, p := range params.PreviousResultIDs {
+		previous[p.URI] = p.Value
+	}
+
+	uris := s.workspace.Files()
+	for _, doc := range s.workspace.GetAllDocuments() {
+		if !slices.Contains(uris, doc.URI) {
+			uris = append(uris, doc.URI)
+		}
+	}
+
+	result := workspaceDiagnosticReport{Items: []workspaceDocumentDiagnosticReport{}}
+	for _, uri := range uris {
+		if err := reqCtx.Err(); err != nil {
+			return nil, err
+		}
+		if analysis.GetDocumentType(uri) == analysis.DocumentTypeUnknown {
+			continue
+		}
+		diagnostics, resultID := s.documentDiagnostics(uri)
+		if diagnostics == nil {
+			continue // Unreadable
+		}
+		report := newDiagnosticReport(diagnostics, resultID, previous[uri])
+		result.Items = append(result.Items, workspaceDocumentDiagnosticReport{
+			Kind:     report.Kind,
+			ResultID: report.ResultID,
+			Items:    report.Items,
+			URI:      uri,
+		})
+	}
+	return result, nil
+}
+
+// newDiagnosticReport returns an unchanged report when the client already
+// holds the diagnostics, and a full report otherwise.
+func newDiagnosticReport(diagnostics []protocol.Diagnostic, resultID, previousResultID string) documentDiagnosticReport {
+	if resultID == previousResultID {
+		return documentDiagnosticReport{Kind: reportUnchanged, ResultID: resultID}
+	}
+	return documentDiagnosticReport{Kind: reportFull, ResultID: resultID, Items: diagnostics}
+}
+
+// documentDiagnostics computes the diagnostics of the open document at uri,
+// or of the file on disk when it is not open. It returns nil when the file
+// cannot be read, and an empty slice when there is nothing to report.
+func (s *Server) documentDiagnostics(uri string) ([]protocol.Diagnostic, string) {
+	if doc := s.workspace.GetDocument(uri); doc != nil {
+		diagnostics := s.computeDiagnostics(doc)
+		if diagnostics == nil {
+			diagnostics = []protocol.Diagnostic{}
+		}
+		return diagnostics, diagnosticsResultID(diagnostics)
+	}
+
+	path := uriToPath(uri)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > s.currentSettings().MaxFileSize {
+		return nil, ""
+	}
+
+	s.fileReportsMu.Lock()
+	cached, ok := s.fileReports[uri]
+	s.fileReportsMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		s.counters.reportHits.Add(1)
+		return cached.diagnostics, cached.resultID
+	}
+	s.counters.reportMisses.Add(1)
+
+	content, err := os.ReadFile(path)
+	if err != nil || analysis.IsBinaryContent(content) {
+		return nil, ""
+	}
+	diagnostics := s.computeDiagnostics(s.workspace.ParseDocument(uri, string(content)))
+	if diagnostics == nil {
+		diagnostics = []protocol.Diagnostic{}
+	}
+	report := fileReport{
+		modTime:     info.ModTime(),
+		size:        info.Size(),
+		resultID:    diagnosticsResultID(diagnostics),
+		diagnostics: diagnostics,
+	}
+
+	s.fileReportsMu.Lock()
+	s.fileReports[uri] = report
+	s.fileReportsMu.Unlock()
+	return report.diagnostics, report.resultID
+}
+
+// diagnosticsResultID identifies a set of diagnostics by its content, so
+// that the same diagnostics get the same result id across requests.
+func diagnosticsResultID(diagnostics []protocol.Diagnostic) string {
+	data, _ := json.Marshal(diagnostics)
+	h := fnv.New64a()
+	h.Write(data)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// refreshDiagnostics drops the cached diagnostics of closed files after the
+// workspace or the settings changed, and asks a client that pulls
+// diagnostics to pull them again.
+func (s *Server) refreshDiagnostics(ctx *glsp.Context) {
+	s.fileReportsMu.Lock()
+	clear(s.fileReports)
+	s.fileReportsMu.Unlock()
+
+	if s.pullDiagnostics && s.diagnosticRefresh {
+		// Notifications are handled on the read loop, which must keep
+		// running to receive the reply
+		go ctx.Call(methodWorkspaceDiagnosticRefresh, nil, nil)
+	}
+}
+
+// clientPullsDiagnostics reports whether the client declared support for
+// pulling diagnostics in its initialize params, and whether it can be asked
+// to refresh them. The protocol package has no fields for either.
+func clientPullsDiagnostics(params json.RawMessage) (pull, refresh bool) {
+	var p struct {
+		Capabilities struct {
+			TextDocument struct {
+				Diagnostic *struct{} `json:"diagnostic"`
+			} `json:"textDocument"`
+			Workspace struct {
+				Diagnostics struct {
+					RefreshSupport bool `json:"refreshSupport"`
+				} `json:"diagnostics"`
+			} `json:"workspace"`
+		} `json:"capabilities"`
+	}
+	if len(params) == 0 || json.Unmarshal(params, &p) != nil {
+		return false, false
+	}
+	pull = p.Capabilities.TextDocument.Diagnostic != nil
+	return pull, pull && p.Capabilities.Workspace.Diagnostics.RefreshSupport
+}
@@ -0,0 +1,106 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestPullDiagnostics(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"project.godot": "config_version=5\n",
+		"level.tscn":    "[gd_scene format=3]\n\n[ext_resource type=\"Texture2D\" path=\"res://missing.png\" id=\"1_t\"]\n\n[node name=\"Level\" type=\"Node2D\"]\n",
+		"clean.tscn":    "[gd_scene format=3]\n\n[node name=\"Clean\" type=\"Node2D\"]\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	folder := pathToURI(dir)
+	level := pathToURI(filepath.Join(dir, "level.tscn"))
+	clean := pathToURI(filepath.Join(dir, "clean.tscn"))
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(folder)
+	s.workspace.SetFolderFiles(folder, []string{clean, level})
+	client := connectServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Decoded by hand, as the protocol types predate pull diagnostics
+	var init struct {
+		Capabilities struct {
+			DiagnosticProvider *diagnosticOptions `json:"diagnosticProvider"`
+		} `json:"capabilities"`
+	}
+	params := map[string]any{"capabilities": map[string]any{
+		"textDocument": map[string]any{"diagnostic": map[string]any{}},
+	}}
+	if err := client.Call(ctx, "initialize", params, &init); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if p := init.Capabilities.DiagnosticProvider; p == nil || !p.WorkspaceDiagnostics || !p.InterFileDependencies {
+		t.Fatalf("expected workspace diagnostics to be advertised, got %+v", p)
+	}
+
+	// Pulled diagnostics are not pushed as well
+	pushed := false
+	doc := s.workspace.OpenDocument(level, files["level.tscn"])
+	s.publishDiagnostics(&glsp.Context{Notify: func(string, any) { pushed = true }}, level, doc)
+	if pushed {
+		t.Error("expected no diagnostics to be published to a client that pulls them")
+	}
+
+	var report struct {
+		Kind     string                `json:"kind"`
+		ResultID string                `json:"resultId"`
+		Items    []protocol.Diagnostic `json:"items"`
+	}
+	textDocument := protocol.TextDocumentIdentifier{URI: level}
+	if err := client.Call(ctx, methodTextDocumentDiagnostic, documentDiagnosticParams{TextDocument: textDocument}, &report); err != nil {
+		t.Fatalf("%s failed: %v", methodTextDocumentDiagnostic, err)
+	}
+	if report.Kind != reportFull || report.ResultID == "" || len(report.Items) != 1 || report.Items[0].Message != "File not found: res://missing.png" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	resultID := report.ResultID
+
+	report.Items = nil
+	if err := client.Call(ctx, methodTextDocumentDiagnostic, documentDiagnosticParams{TextDocument: textDocument, PreviousResultID: resultID}, &report); err != nil {
+		t.Fatalf("%s failed: %v", methodTextDocumentDiagnostic, err)
+	}
+	if report.Kind != reportUnchanged || report.ResultID != resultID || report.Items != nil {
+		t.Errorf("expected an unchanged report, got %+v", report)
+	}
+
+	// Closed files are read from disk, open ones from the editor
+	s.workspace.CloseDocument(level)
+	var workspace struct {
+		Items []json.RawMessage `json:"items"`
+	}
+	previous := workspaceDiagnosticParams{PreviousResultIDs: []previousResultID{{URI: level, Value: resultID}}}
+	if err := client.Call(ctx, methodWorkspaceDiagnostic, previous, &workspace); err != nil {
+		t.Fatalf("%s failed: %v", methodWorkspaceDiagnostic, err)
+	}
+	expected := []string{
+		`{"kind":"full","resultId":"` + diagnosticsResultID([]protocol.Diagnostic{}) + `","items":[],"uri":"` + clean + `","version":null}`,
+		`{"kind":"unchanged","resultId":"` + resultID + `","uri":"` + level + `","version":null}`,
+	}
+	if len(workspace.Items) != len(expected) {
+		t.Fatalf("expected %d reports, got %s", len(expected), workspace.Items)
+	}
+	for i, item := range workspace.Items {
+		if string(item) != expected[i] {
+			t.Errorf("expected\n%s\ngot\n%s", expected[i], item)
+		}
+	}
+}
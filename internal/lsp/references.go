@@ -8,6 +8,18 @@ import (
 	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "references",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentReferences = s.textDocumentReferences
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.ReferencesProvider = &protocol.ReferenceOptions{}
+		},
+	})
+}
+
 // textDocumentReferences handles the textDocument/references request.
 func (s *Server) textDocumentReferences(ctx *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
@@ -30,84 +42,66 @@ func (s *Server) findReferences(doc *analysis.Document, uri string, line, col in
 	locations := []protocol.Location{}
 
 	// Check if we're on an ext_resource
-	for _, ext := range ast.ExtResources {
-		if isInRange(ext.Range, line, col) {
-			// Find all references to this ext_resource ID
-			if includeDeclaration {
-				locations = append(locations, protocol.Location{
-					URI: uri,
-					Range: protocol.Range{
-						Start: protocol.Position{
-							Line:      uint32(ext.Range.Start.Line),
-							Character: uint32(ext.Range.Start.Column),
-						},
-						End: protocol.Position{
-							Line:      uint32(ext.Range.End.Line),
-							Character: uint32(ext.Range.End.Column),
-						},
+	if ext, ok := analysis.ElementAt[*parser.ExtResource](doc.Positions, line, col); ok {
+		// Find all references to this ext_resource ID
+		if includeDeclaration {
+			locations = append(locations, protocol.Location{
+				URI: uri,
+				Range: protocol.Range{
+					Start: protocol.Position{
+						Line:      uint32(ext.Range.Start.Line),
+						Character: uint32(ext.Range.Start.Column),
 					},
-				})
-			}
-			locations = append(locations, s.findResourceReferences(ast, ext.ID, "ExtResource", uri)...)
-			return locations
+					End: protocol.Position{
+						Line:      uint32(ext.Range.End.Line),
+						Character: uint32(ext.Range.End.Column),
+					},
+				},
+			})
 		}
+		locations = append(locations, s.findResourceReferences(ast, ext.ID, "ExtResource", uri)...)
+		return locations
 	}
 
 	// Check if we're on a sub_resource
-	for _, sub := range ast.SubResources {
-		if isInRange(sub.Range, line, col) {
-			if includeDeclaration {
-				locations = append(locations, protocol.Location{
-					URI: uri,
-					Range: protocol.Range{
-						Start: protocol.Position{
-							Line:      uint32(sub.Range.Start.Line),
-							Character: uint32(sub.Range.Start.Column),
-						},
-						End: protocol.Position{
-							Line:      uint32(sub.Range.End.Line),
-							Character: uint32(sub.Range.End.Column),
-						},
+	if sub, ok := analysis.ElementAt[*parser.SubResource](doc.Positions, line, col); ok {
+		if includeDeclaration {
+			locations = append(locations, protocol.Location{
+				URI: uri,
+				Range: protocol.Range{
+					Start: protocol.Position{
+						Line:      uint32(sub.Range.Start.Line),
+						Character: uint32(sub.Range.Start.Column),
 					},
-				})
-			}
-			locations = append(locations, s.findResourceReferences(ast, sub.ID, "SubResource", uri)...)
-			return locations
+					End: protocol.Position{
+						Line:      uint32(sub.Range.End.Line),
+						Character: uint32(sub.Range.End.Column),
+					},
+				},
+			})
 		}
+		locations = append(locations, s.findResourceReferences(ast, sub.ID, "SubResource", uri)...)
+		return locations
 	}
 
-	// Check if we're on a node
-	for _, node := range ast.Nodes {
-		if isInRange(node.Range, line, col) {
-			// Find the node's path
-			var nodePath string
-			switch node.Parent {
-			case "":
-				nodePath = ""
-			case ".":
-				nodePath = node.Name
-			default:
-				nodePath = node.Parent + "/" + node.Name
-			}
+	// Check if we're on a node name or a path naming one
+	occurrences := collectNodeOccurrences(ast)
+	target := nodeOccurrenceAt(occurrences, line, col)
+	if target == nil {
+		// Anywhere else inside a [node] section refers to that node
+		if node, ok := analysis.ElementAt[*parser.Node](doc.Positions, line, col); ok {
+			target = analysis.NewSceneTree(ast).NodeFor(node)
+		}
+	}
+	if target == nil {
+		return locations
+	}
 
-			if includeDeclaration {
-				locations = append(locations, protocol.Location{
-					URI: uri,
-					Range: protocol.Range{
-						Start: protocol.Position{
-							Line:      uint32(node.Range.Start.Line),
-							Character: uint32(node.Range.Start.Column),
-						},
-						End: protocol.Position{
-							Line:      uint32(node.Range.End.Line),
-							Character: uint32(node.Range.End.Column),
-						},
-					},
-				})
-			}
-			locations = append(locations, s.findNodeReferences(ast, nodePath, uri)...)
-			return locations
+	for _, occ := range occurrences {
+		if occ.node != target || (occ.declaration && !includeDeclaration) {
+			continue
 		}
+		locations = append(locations, protocol.Location{URI: uri, Range: toProtocolRange(occ.rng)})
 	}
 
 	return locations
@@ -149,6 +143,9 @@ func (s *Server) findResourceReferences(ast *parser.Document, id, refType, uri s
 				findInValue(entry.Value)
 			}
 		case *parser.TypedValue:
+			for _, param := range val.TypeParams {
+				findInValue(param)
+			}
 			for _, arg := range val.Arguments {
 				findInValue(arg)
 			}
@@ -174,49 +171,3 @@ func (s *Server) findResourceReferences(ast *parser.Document, id, refType, uri s
 
 	return locations
 }
-
-// findNodeReferences finds all references to a node path (in parent= and connections).
-func (s *Server) findNodeReferences(ast *parser.Document, nodePath, uri string) []protocol.Location {
-	locations := []protocol.Location{}
-
-	// Find nodes that have this path as parent
-	for _, node := range ast.Nodes {
-		if node.Parent == nodePath || (nodePath == "" && node.Parent == ".") {
-			// This node references our target as its parent
-			locations = append(locations, protocol.Location{
-				URI: uri,
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(node.Range.Start.Line),
-						Character: uint32(node.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(node.Range.End.Line),
-						Character: uint32(node.Range.End.Column),
-					},
-				},
-			})
-		}
-	}
-
-	// Find connections that reference this node
-	for _, conn := range ast.Connections {
-		if conn.From == nodePath || conn.To == nodePath {
-			locations = append(locations, protocol.Location{
-				URI: uri,
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(conn.Range.Start.Line),
-						Character: uint32(conn.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(conn.Range.End.Line),
-						Character: uint32(conn.Range.End.Column),
-					},
-				},
-			})
-		}
-	}
-
-	return locations
-}
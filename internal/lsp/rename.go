@@ -0,0 +1,274 @@
+package lsp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/godotdoc"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "rename",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentRename = s.textDocumentRename
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.RenameProvider = true
+		},
+	})
+}
+
+// invalidNodeNameChars are the characters Godot does not allow in node
+// names, since they have a meaning in node paths.
+const invalidNodeNameChars = ".:@/\"%"
+
+// textDocumentRename handles the textDocument/rename request. Renaming a
+// node rewrites its [node name=...] declaration and every parent=,
+// NodePath() and connection path of the scene that names it. Scenes that
+// instance this one are not edited, but the user is warned when their
+// paths reach the renamed node.
+func (s *Server) textDocumentRename(ctx *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	uri := params.TextDocument.URI
+	doc := s.workspace.GetDocument(uri)
+	if doc == nil || doc.TSCNAST == nil {
+		return nil, nil
+	}
+
+	occurrences := collectNodeOccurrences(doc.TSCNAST)
+	target := nodeOccurrenceAt(occurrences, int(params.Position.Line), int(params.Position.Character))
+	if target == nil {
+		return nil, nil
+	}
+
+	newName := params.NewName
+	if err := validateNodeName(target, newName); err != nil {
+		return nil, err
+	}
+
+	oldName := target.Node.Name
+	edits := []protocol.TextEdit{}
+	for _, occ := range occurrences {
+		if occ.node != target {
+			continue
+		}
+		// "." and ".." name the node without its name, and "%Name" keeps
+		// its unique name marker
+		switch doc.Content[occ.rng.Start.Offset:occ.rng.End.Offset] {
+		case oldName:
+			edits = append(edits, protocol.TextEdit{Range: toProtocolRange(occ.rng), NewText: newName})
+		case "%" + oldName:
+			edits = append(edits, protocol.TextEdit{Range: toProtocolRange(occ.rng), NewText: "%" + newName})
+		}
+	}
+
+	// Instances are named by the scene instancing them, so only paths to
+	// the children of the root can break
+	if target.Parent != nil {
+		if scenes := s.scenesReachingNode(uri, target.Path); len(scenes) > 0 {
+			ctx.Notify(string(protocol.ServerWindowShowMessage), protocol.ShowMessageParams{
+				Type: protocol.MessageTypeWarning,
+				Message: fmt.Sprintf("Renaming %s does not update the scenes that instance this one and refer to it: %s",
+					oldName, strings.Join(scenes, ", ")),
+			})
+		}
+	}
+
+	if err := s.checkCurrent(doc); err != nil {
+		return nil, err
+	}
+	return &protocol.WorkspaceEdit{
+		Changes: map[protocol.DocumentUri][]protocol.TextEdit{uri: edits},
+	}, nil
+}
+
+// textDocumentPrepareRename handles the textDocument/prepareRename
+// request. On a node name it returns the range of the name, without the %
+// of unique names, and the name as placeholder. Anything else is rejected
+// with an error telling the user why it cannot be renamed.
+func (s *Server) textDocumentPrepareRename(ctx *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	line, col := int(params.Position.Line), int(params.Position.Character)
+
+	switch {
+	case doc.TSCNAST != nil:
+		return prepareNodeRename(doc, line, col)
+	case doc.ShaderAST != nil:
+		return nil, s.shaderRenameError(doc, line, col)
+	}
+	if word := identifierAt(doc.Content, line, col); word != "" {
+		return nil, fmt.Errorf("only scene nodes can be renamed")
+	}
+	return nil, nil
+}
+
+// prepareNodeRename returns the range and placeholder of the node named at
+// a position of a scene, or the reason nothing there can be renamed.
+func prepareNodeRename(doc *analysis.Document, line, col int) (any, error) {
+	ast := doc.TSCNAST
+	for _, occ := range collectNodeOccurrences(ast) {
+		if !isInRange(occ.rng, line, col) {
+			continue
+		}
+		rng := occ.rng
+		if strings.HasPrefix(doc.Content[rng.Start.Offset:rng.End.Offset], "%") {
+			rng.Start.Column++
+			rng.Start.Offset++
+		}
+		return protocol.RangeWithPlaceholder{Range: toProtocolRange(rng), Placeholder: occ.node.Node.Name}, nil
+	}
+
+	// Paths can name nodes this scene only instances
+	tree := analysis.NewSceneTree(ast)
+	if np := nodePathAt(doc, tree, line, col); np != nil {
+		if node, rest := tree.ResolvePrefix(np.base, np.path); node != nil && rest != "" && node.Node.Instance != nil {
+			return nil, fmt.Errorf("%s is declared in the scene instanced as %s; rename it in that scene", rest, node.Node.Name)
+		}
+		return nil, fmt.Errorf("%q does not name a node of this scene", np.path)
+	}
+
+	word := identifierAt(doc.Content, line, col)
+	switch {
+	case word == "":
+		return nil, nil
+	case godotdoc.Default().Class(word) != nil:
+		return nil, fmt.Errorf("%s is a Godot class and cannot be renamed", word)
+	}
+	return nil, fmt.Errorf("only node names can be renamed")
+}
+
+// shaderRenameError explains why the identifier at a position of a shader
+// cannot be renamed: built-ins and keywords belong to Godot, and renaming
+// the symbols a shader declares is not supported.
+func (s *Server) shaderRenameError(doc *analysis.Document, line, col int) error {
+	word := identifierAt(doc.Content, line, col)
+	if word == "" {
+		return nil
+	}
+	ast := doc.ShaderAST
+	version := s.workspace.ShaderVersion()
+
+	if gdshader.LookupIdent(word) != gdshader.TokenIdent {
+		return fmt.Errorf("%s is a keyword of the shading language and cannot be renamed", word)
+	}
+	if ast.RenderModes != nil {
+		for _, rng := range ast.RenderModes.ModeRanges {
+			if isInGDShaderRange(rng, line, col) {
+				return fmt.Errorf("%s is a render mode of Godot and cannot be renamed", word)
+			}
+		}
+	}
+	if _, ok := gdshader.BuiltinFunctions[word]; ok {
+		return fmt.Errorf("%s is a built-in function and cannot be renamed", word)
+	}
+	if _, ok := gdshader.BuiltinConstants[word]; ok {
+		return fmt.Errorf("%s is a built-in constant and cannot be renamed", word)
+	}
+	if ast.ShaderType != nil {
+		if _, ok := gdshader.GetBuiltinsForShaderType(version, ast.ShaderType.Type)[word]; ok {
+			return fmt.Errorf("%s is a built-in variable and cannot be renamed", word)
+		}
+	}
+	return fmt.Errorf("renaming shader symbols is not supported, only scene nodes can be renamed")
+}
+
+// identifierAt returns the identifier under a position, or "" when there
+// is none.
+func identifierAt(content string, line, col int) string {
+	lines := strings.Split(content, "\n")
+	if line >= len(lines) || col > len(lines[line]) {
+		return ""
+	}
+	text := lines[line]
+	start, end := col, col
+	for start > 0 && isIdentChar(text[start-1]) {
+		start--
+	}
+	for end < len(text) && isIdentChar(text[end]) {
+		end++
+	}
+	return text[start:end]
+}
+
+// validateNodeName checks that a node can be renamed to name: Godot must
+// accept the name, and no sibling may have it already.
+func validateNodeName(node *analysis.SceneNode, name string) error {
+	if name == "" {
+		return fmt.Errorf("node name cannot be empty")
+	}
+	if i := strings.IndexAny(name, invalidNodeNameChars); i >= 0 {
+		return fmt.Errorf("node name %q cannot contain %q", name, name[i])
+	}
+	if node.Parent != nil && name != node.Node.Name {
+		for _, sibling := range node.Parent.Children {
+			if sibling.Node.Name == name {
+				return fmt.Errorf("%s already has a child named %q", node.Parent.Node.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// scenesReachingNode returns the workspace scenes, other than the one at
+// uri, with a node path that reaches the node at nodePath of that scene or
+// one of its children through an instance of it. Their names are relative
+// to the workspace folder when they are inside one.
+func (s *Server) scenesReachingNode(uri, nodePath string) []string {
+	var scenes []string
+	for _, sceneURI := range s.workspace.Files() {
+		if sceneURI == uri || analysis.GetDocumentType(sceneURI) != analysis.DocumentTypeTSCN {
+			continue
+		}
+		ast := s.loadScene(sceneURI)
+		if ast == nil || !s.loadsScene(sceneURI, ast, uri) {
+			continue
+		}
+
+		tree := analysis.NewSceneTree(ast)
+		for _, np := range collectNodePaths(ast, tree) {
+			reachedURI, node := s.resolveNodePath(sceneURI, ast, tree, np.base, np.path)
+			if reachedURI == uri && node != nil &&
+				(node.Path == nodePath || strings.HasPrefix(node.Path, nodePath+"/")) {
+				scenes = append(scenes, s.displayPath(sceneURI))
+				break
+			}
+		}
+	}
+	return scenes
+}
+
+// loadsScene reports whether the scene at sceneURI has an ext_resource
+// loading the scene at uri, which is cheaper to check than resolving its
+// node paths.
+func (s *Server) loadsScene(sceneURI string, ast *parser.Document, uri string) bool {
+	for _, ext := range ast.ExtResources {
+		if loc := s.resolveResourcePath(s.extResourcePath(sceneURI, ext), sceneURI); loc != nil && loc.URI == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// displayPath returns a file path relative to its workspace folder, or the
+// URI for files outside every folder.
+func (s *Server) displayPath(uri string) string {
+	folder := s.workspace.FolderOf(uri)
+	if folder == "" {
+		return uri
+	}
+	rel, err := filepath.Rel(uriToPath(folder), uriToPath(uri))
+	if err != nil {
+		return uri
+	}
+	return filepath.ToSlash(rel)
+}
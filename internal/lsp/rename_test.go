@@ -0,0 +1,180 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestRenameNode(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+
+	content := `[gd_scene format=3]
+
+[node name="Main" type="Node2D"]
+target = NodePath("Player/Sprite")
+
+[node name="Player" type="CharacterBody2D" parent="."]
+unique_name_in_owner = true
+
+[node name="Sprite" type="Sprite2D" parent="Player"]
+owner_path = NodePath("..")
+
+[node name="Label" type="Label" parent="."]
+follow = NodePath("%Player")
+
+[connection signal="ready" from="Player" to="." method="_on_ready"]
+`
+	write("project.godot", "config_version=5\n")
+	uri := write("main.tscn", content)
+	write("level.tscn", `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://main.tscn" id="1_main"]
+
+[node name="Level" type="Node"]
+
+[node name="Hero" parent="." instance=ExtResource("1_main")]
+
+[connection signal="ready" from="Hero/Player" to="." method="_on_player_ready"]
+`)
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+	s.workspace.OpenDocument(uri, content)
+
+	var messages []string
+	ctx := &glsp.Context{Notify: func(method string, params any) {
+		if method == string(protocol.ServerWindowShowMessage) {
+			messages = append(messages, params.(protocol.ShowMessageParams).Message)
+		}
+	}}
+	rename := func(line, character uint32, newName string) (*protocol.WorkspaceEdit, error) {
+		return s.textDocumentRename(ctx, &protocol.RenameParams{
+			TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+				TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+				Position:     protocol.Position{Line: line, Character: character},
+			},
+			NewName: newName,
+		})
+	}
+
+	// Rename from the declaration of Player
+	edit, err := rename(5, 14, "Hero")
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits := edit.Changes[uri]
+	sort.Slice(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+	})
+	var got []string
+	for _, e := range edits {
+		got = append(got, fmt.Sprintf("%s@%d", e.NewText, e.Range.Start.Line))
+	}
+	want := []string{"Hero@3", "Hero@5", "Hero@8", "%Hero@12", "Hero@14"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected edits %v, got %v", want, got)
+	}
+
+	if len(messages) != 1 || !strings.Contains(messages[0], "level.tscn") {
+		t.Errorf("expected a warning about level.tscn, got %v", messages)
+	}
+
+	// Names Godot rejects and names of siblings are refused
+	for _, name := range []string{"", "A/B", "Label"} {
+		if _, err := rename(5, 14, name); err == nil {
+			t.Errorf("expected renaming to %q to fail", name)
+		}
+	}
+}
+
+func TestPrepareRename(t *testing.T) {
+	s := NewServer("test", "test")
+	sceneURI := "file:///tmp/main.tscn"
+	s.workspace.OpenDocument(sceneURI, `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://enemy.tscn" id="1_e"]
+
+[node name="Main" type="Node2D"]
+target = NodePath("%Player")
+
+[node name="Player" type="CharacterBody2D" parent="."]
+unique_name_in_owner = true
+
+[node name="Enemy" parent="." instance=ExtResource("1_e")]
+
+[connection signal="died" from="Enemy/Body" to="." method="_on_died"]
+`)
+	shaderURI := "file:///tmp/water.gdshader"
+	s.workspace.OpenDocument(shaderURI, `shader_type spatial;
+render_mode unshaded;
+
+uniform float speed = 1.0;
+
+void fragment() {
+	ALBEDO = vec3(sin(TIME * speed) * PI);
+}
+`)
+
+	tests := []struct {
+		name      string
+		uri       string
+		line, col uint32
+		expected  string // Range and placeholder, or the error
+	}{
+		{"node declaration", sceneURI, 7, 14, "7:12-7:18 Player"},
+		{"unique name keeps its marker", sceneURI, 5, 21, "5:20-5:26 Player"},
+		{"dot names the root", sceneURI, 12, 48, "12:48-12:49 Main"},
+		{"instanced scene", sceneURI, 12, 40, "error: Body is declared in the scene instanced as Enemy; rename it in that scene"},
+		{"godot class", sceneURI, 4, 26, "error: Node2D is a Godot class and cannot be renamed"},
+		{"section keyword", sceneURI, 4, 2, "error: only node names can be renamed"},
+		{"blank line", sceneURI, 1, 0, "null"},
+		{"shader keyword", shaderURI, 6, 11, "error: vec3 is a keyword of the shading language and cannot be renamed"},
+		{"render mode", shaderURI, 1, 14, "error: unshaded is a render mode of Godot and cannot be renamed"},
+		{"built-in function", shaderURI, 6, 15, "error: sin is a built-in function and cannot be renamed"},
+		{"built-in constant", shaderURI, 6, 35, "error: PI is a built-in constant and cannot be renamed"},
+		{"built-in variable", shaderURI, 6, 2, "error: ALBEDO is a built-in variable and cannot be renamed"},
+		{"shader uniform", shaderURI, 6, 28, "error: renaming shader symbols is not supported, only scene nodes can be renamed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.textDocumentPrepareRename(&glsp.Context{}, &protocol.PrepareRenameParams{
+				TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+					TextDocument: protocol.TextDocumentIdentifier{URI: tt.uri},
+					Position:     protocol.Position{Line: tt.line, Character: tt.col},
+				},
+			})
+			var got string
+			switch r := result.(type) {
+			case protocol.RangeWithPlaceholder:
+				got = fmt.Sprintf("%d:%d-%d:%d %s", r.Range.Start.Line, r.Range.Start.Character,
+					r.Range.End.Line, r.Range.End.Character, r.Placeholder)
+			case nil:
+				got = "null"
+			}
+			if err != nil {
+				got = "error: " + err.Error()
+			}
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/andresperezl/gdls/internal/telemetry"
+)
+
+// codeRequestCancelled is the LSP error code for a request cancelled by the client.
+const codeRequestCancelled = -32800
+
+// codeContentModified is the LSP error code for a request whose document
+// changed while the server worked on it.
+const codeContentModified = -32801
+
+// rpcHandler adapts the protocol handler to JSON-RPC connections. Unlike
+// glsp's built-in server it gives every request its own context, which is
+// cancelled by $/cancelRequest.
+//
+// Notifications are handled in order on the connection's read loop, so
+// document updates are always applied before any later request. Requests
+// run in their own goroutine so a cancellation can arrive while they work.
+type rpcHandler struct {
+	s *Server
+
+	mu       sync.Mutex
+	inflight map[jsonrpc2.ID]context.CancelFunc
+}
+
+// newRPCHandler creates a JSON-RPC handler for the server.
+func newRPCHandler(s *Server) *rpcHandler {
+	return &rpcHandler{
+		s:        s,
+		inflight: make(map[jsonrpc2.ID]context.CancelFunc),
+	}
+}
+
+// Handle implements jsonrpc2.Handler.
+func (h *rpcHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	start := time.Now()
+	if req.Notif {
+		_, err := h.handle(ctx, conn, req)
+		h.logMessage(ctx, conn, req, time.Since(start), err)
+		if err != nil {
+			h.s.log.Errorf("notification %s: %s", req.Method, err.Error())
+		}
+		return
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	h.mu.Lock()
+	h.inflight[req.ID] = cancel
+	h.mu.Unlock()
+
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.inflight, req.ID)
+			h.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := h.handle(reqCtx, conn, req)
+		if reqCtx.Err() != nil && ctx.Err() == nil {
+			err = &jsonrpc2.Error{Code: codeRequestCancelled, Message: "request cancelled"}
+		}
+		h.logMessage(ctx, conn, req, time.Since(start), err)
+
+		if err != nil {
+			rpcErr, ok := err.(*jsonrpc2.Error)
+			if !ok {
+				rpcErr = &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: err.Error()}
+			}
+			if err := conn.ReplyWithError(ctx, req.ID, rpcErr); err != nil {
+				h.s.log.Errorf("reply to %s: %s", req.Method, err.Error())
+			}
+			return
+		}
+
+		if err := conn.Reply(ctx, req.ID, result); err != nil {
+			h.s.log.Errorf("reply to %s: %s", req.Method, err.Error())
+		}
+	}()
+}
+
+// handle dispatches a single message to the protocol handler inside a
+// span, which is a no-op unless tracing is enabled.
+func (h *rpcHandler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (result any, err error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("rpc.system", "jsonrpc"),
+		attribute.String("rpc.method", req.Method),
+	}
+	if !req.Notif {
+		attrs = append(attrs, attribute.String("rpc.jsonrpc.request_id", req.ID.String()))
+	}
+	ctx, span := telemetry.Start(ctx, req.Method, attrs...)
+	defer func() {
+		if ctx.Err() != nil {
+			span.AddEvent("cancelled")
+		}
+		telemetry.End(span, err)
+	}()
+	defer h.s.recoverPanic(req.Method, &err)
+
+	return h.dispatch(ctx, conn, req)
+}
+
+// maxPanicStack is the number of bytes of the stack of a panic that are
+// logged.
+const maxPanicStack = 8 << 10
+
+// recoverPanic recovers from a panic in the handling of method, so that a
+// bug in one handler does not end the session. The panic is logged with
+// its stack, which is never sent anywhere else, and becomes an internal
+// error in *err when err is not nil. It must be deferred.
+func (s *Server) recoverPanic(method string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	if len(stack) > maxPanicStack {
+		stack = append(stack[:maxPanicStack], "\n..."...)
+	}
+	s.log.Errorf("panic in %s: %v\n%s", method, r, stack)
+	if err != nil {
+		*err = &jsonrpc2.Error{Code: jsonrpc2.CodeInternalError, Message: fmt.Sprintf("internal error in %s: %v", method, r)}
+	}
+}
+
+// dispatch passes a single message to the protocol handler.
+func (h *rpcHandler) dispatch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+	glspCtx := &glsp.Context{
+		Method: req.Method,
+		Notify: func(method string, params any) {
+			params = h.s.positionsToClient(params, "")
+			if err := conn.Notify(ctx, method, params); err != nil {
+				h.s.log.Errorf("%s", err.Error())
+			}
+		},
+		Call: func(method string, params any, result any) {
+			params = h.s.positionsToClient(params, "")
+			if err := conn.Call(ctx, method, params, result); err != nil {
+				h.s.log.Errorf("%s", err.Error())
+			}
+		},
+	}
+	if req.Params != nil {
+		glspCtx.Params = *req.Params
+	}
+
+	if err := h.s.lifecycle.check(req.Method); err != nil {
+		if req.Notif {
+			return nil, nil // Notifications cannot be answered, so they are dropped
+		}
+		return nil, err
+	}
+
+	switch req.Method {
+	case string(protocol.MethodCancelRequest):
+		var params struct {
+			ID jsonrpc2.ID `json:"id"`
+		}
+		if err := json.Unmarshal(glspCtx.Params, &params); err != nil {
+			return nil, &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams, Message: err.Error()}
+		}
+		h.cancel(params.ID)
+		return nil, nil
+
+	case "exit":
+		// Give the handler a chance to run, but ignore any result
+		h.s.handler.Handle(glspCtx)
+		h.s.lifecycle.handled(req.Method)
+		return nil, conn.Close()
+	}
+
+	h.s.bindRequestContext(glspCtx, ctx)
+	defer h.s.unbindRequestContext(glspCtx)
+
+	// Handlers count columns in bytes, whatever the client's encoding
+	if !req.Notif {
+		glspCtx.Params = h.s.positionsFromClient(glspCtx.Params)
+	}
+
+	r, validMethod, validParams, err := h.s.handle(glspCtx)
+	switch {
+	case !validMethod:
+		if req.Notif && strings.HasPrefix(req.Method, "$/") {
+			return nil, nil // Protocol-dependent notifications may be ignored
+		}
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeMethodNotFound,
+			Message: fmt.Sprintf("method not supported: %s", req.Method),
+		}
+	case !validParams:
+		rpcErr := &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidParams}
+		if err != nil {
+			rpcErr.Message = err.Error()
+		}
+		return nil, rpcErr
+	case err != nil:
+		return nil, err
+	}
+
+	h.s.lifecycle.handled(req.Method)
+	return h.s.positionsToClient(r, requestDocumentURI(glspCtx.Params)), nil
+}
+
+// logMessage records a handled message in the session counters and logs
+// it with its duration at debug level, which --verbose enables. When the
+// client asked for traces with $/setTrace, it also gets a $/logTrace
+// notification, with the params when the trace is verbose.
+func (h *rpcHandler) logMessage(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request, elapsed time.Duration, err error) {
+	h.s.counters.record(req.Method, elapsed, err != nil)
+
+	kind, id := "notification", ""
+	if !req.Notif {
+		kind, id = "request", req.ID.String()
+	}
+	size := 0
+	if req.Params != nil {
+		size = len(*req.Params)
+	}
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+	h.s.log.Debugf("handled %s method=%s id=%s duration=%s params=%dB status=%q", kind, req.Method, id, elapsed, size, status)
+
+	trace := h.s.traceValue()
+	if trace == protocol.TraceValueOff || req.Method == "exit" {
+		return
+	}
+	message := fmt.Sprintf("Handled %s '%s' in %dms.", kind, req.Method, elapsed.Milliseconds())
+	if id != "" {
+		message = fmt.Sprintf("Handled %s '%s - (%s)' in %dms.", kind, req.Method, id, elapsed.Milliseconds())
+	}
+	if err != nil {
+		message += " Failed: " + err.Error()
+	}
+	params := protocol.LogTraceParams{Message: message}
+	if trace == protocol.TraceValueVerbose && req.Params != nil {
+		verbose := "Params: " + string(*req.Params)
+		params.Verbose = &verbose
+	}
+	if err := conn.Notify(ctx, string(protocol.MethodLogTrace), params); err != nil {
+		h.s.log.Errorf("%s", err.Error())
+	}
+}
+
+// cancel cancels the in-flight request with the given ID, if any.
+func (h *rpcHandler) cancel(id jsonrpc2.ID) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cancel, ok := h.inflight[id]; ok {
+		cancel()
+	}
+}
+
+// bindRequestContext associates a request context with a glsp context so
+// handlers can look it up with requestContext.
+func (s *Server) bindRequestContext(ctx *glsp.Context, reqCtx context.Context) {
+	s.reqCtxMu.Lock()
+	defer s.reqCtxMu.Unlock()
+	s.reqCtx[ctx] = reqCtx
+}
+
+// unbindRequestContext removes the association made by bindRequestContext.
+func (s *Server) unbindRequestContext(ctx *glsp.Context) {
+	s.reqCtxMu.Lock()
+	defer s.reqCtxMu.Unlock()
+	delete(s.reqCtx, ctx)
+}
+
+// requestContext returns the context of the request being handled, which is
+// cancelled when the client sends $/cancelRequest for it. Handlers invoked
+// outside a JSON-RPC connection get a background context.
+func (s *Server) requestContext(ctx *glsp.Context) context.Context {
+	s.reqCtxMu.Lock()
+	defer s.reqCtxMu.Unlock()
+
+	if reqCtx, ok := s.reqCtx[ctx]; ok {
+		return reqCtx
+	}
+	return context.Background()
+}
+
+// stdio is an io.ReadWriteCloser over the process's standard streams.
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error)  { return os.Stdin.Read(p) }
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+func (stdio) Close() error {
+	if err := os.Stdin.Close(); err != nil {
+		return err
+	}
+	return os.Stdout.Close()
+}
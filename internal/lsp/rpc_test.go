@@ -0,0 +1,168 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// connectServer connects a JSON-RPC client to the server over an in-memory pipe.
+func connectServer(t *testing.T, s *Server) *jsonrpc2.Conn {
+	t.Helper()
+
+	clientSide, serverSide := net.Pipe()
+	jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}), newRPCHandler(s))
+	client := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(
+		func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil },
+	))
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCancelRequest(t *testing.T) {
+	s := NewServer("test", "test")
+
+	started := make(chan struct{})
+	s.handler.TextDocumentHover = func(ctx *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+		close(started)
+		reqCtx := s.requestContext(ctx)
+		<-reqCtx.Done()
+		return nil, reqCtx.Err()
+	}
+
+	client := connectServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	id := jsonrpc2.ID{Num: 42}
+
+	waiter, err := client.DispatchCall(ctx, "textDocument/hover", protocol.HoverParams{}, jsonrpc2.PickID(id))
+	if err != nil {
+		t.Fatalf("dispatch failed: %v", err)
+	}
+
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatal("hover handler was not called")
+	}
+	if err := client.Notify(ctx, "$/cancelRequest", map[string]any{"id": 42}); err != nil {
+		t.Fatalf("cancel failed: %v", err)
+	}
+
+	err = waiter.Wait(ctx, nil)
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected a JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != codeRequestCancelled {
+		t.Errorf("expected code %d, got %d", codeRequestCancelled, rpcErr.Code)
+	}
+}
+
+func TestRequestContextOutsideConnection(t *testing.T) {
+	s := NewServer("test", "test")
+	if err := s.requestContext(&glsp.Context{}).Err(); err != nil {
+		t.Errorf("expected a live background context, got %v", err)
+	}
+}
+
+func TestRequestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	s := NewServer("test", "test")
+	client := connectServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := client.Call(ctx, "gdls/unknown", nil, nil); err == nil {
+		t.Fatal("expected an error for an unknown method")
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	if _, ok := spans["initialize"]; !ok {
+		t.Errorf("expected an initialize span, got %v", spans)
+	}
+	unknown, ok := spans["gdls/unknown"]
+	if !ok {
+		t.Fatalf("expected a span for the failed request, got %v", spans)
+	}
+	if unknown.Status().Code != codes.Error {
+		t.Errorf("expected an error status, got %v", unknown.Status())
+	}
+}
+
+func TestPanicInHandler(t *testing.T) {
+	s := NewServer("test", "test")
+	s.handler.TextDocumentHover = func(ctx *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+		var hover *protocol.Hover
+		return &protocol.Hover{Range: hover.Range}, nil // nil pointer dereference
+	}
+	opened := make(chan struct{}, 1)
+	s.handler.TextDocumentDidOpen = func(ctx *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
+		opened <- struct{}{}
+		panic("broken didOpen")
+	}
+
+	client := connectServer(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	err := client.Call(ctx, "textDocument/hover", protocol.HoverParams{}, nil)
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) {
+		t.Fatalf("expected a JSON-RPC error, got %v", err)
+	}
+	if rpcErr.Code != jsonrpc2.CodeInternalError || !strings.Contains(rpcErr.Message, "textDocument/hover") {
+		t.Errorf("expected an internal error naming the method, got %d: %s", rpcErr.Code, rpcErr.Message)
+	}
+
+	// A panicking notification is dropped without ending the session
+	if err := client.Notify(ctx, "textDocument/didOpen", protocol.DidOpenTextDocumentParams{}); err != nil {
+		t.Fatalf("didOpen failed: %v", err)
+	}
+	select {
+	case <-opened:
+	case <-ctx.Done():
+		t.Fatal("didOpen handler was not called")
+	}
+
+	var stats statsResult
+	if err := client.Call(ctx, StatsMethod, struct{}{}, &stats); err != nil {
+		t.Fatalf("expected the session to survive the panics, got %v", err)
+	}
+	if got := stats.Requests["textDocument/hover"]; got.Errors != 1 {
+		t.Errorf("expected the panic to count as an error, got %+v", got)
+	}
+}
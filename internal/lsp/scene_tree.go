@@ -0,0 +1,96 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// SceneTreeMethod is the request an extension sends to get the node tree of
+// a scene, e.g. to show it the way the Scene dock of the Godot editor does.
+const SceneTreeMethod = "gdls/sceneTree"
+
+func init() {
+	registerCapability(&capability{
+		name: "sceneTree",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[SceneTreeMethod] = customRequest(s.sceneTree)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["sceneTree"] = true
+		},
+	})
+}
+
+// sceneTreeParams are the parameters of the gdls/sceneTree request.
+type sceneTreeParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// sceneTreeNode is a node of the gdls/sceneTree result.
+type sceneTreeNode struct {
+	Name     string           `json:"name"`
+	Type     string           `json:"type,omitempty"` // Declared type, or root type of the instanced scene
+	Path     string           `json:"path"`           // Node path from the root, "." for the root
+	Range    protocol.Range   `json:"range"`
+	Script   string           `json:"script,omitempty"`   // Path of the attached script
+	Instance string           `json:"instance,omitempty"` // Path of the instanced scene
+	Groups   []string         `json:"groups,omitempty"`
+	Children []*sceneTreeNode `json:"children"`
+}
+
+// sceneTree handles the gdls/sceneTree request, returning the root node of
+// the scene at the requested URI, or null when it is not a scene or has no
+// nodes. The open document is used when there is one, so the tree follows
+// unsaved edits.
+func (s *Server) sceneTree(ctx *glsp.Context, params *sceneTreeParams) (any, error) {
+	uri := params.TextDocument.URI
+	ast := s.loadScene(uri)
+	if ast == nil {
+		return nil, nil
+	}
+	tree := analysis.NewSceneTree(ast)
+	if tree.Root == nil {
+		return nil, nil
+	}
+
+	// ext_resource paths by ID, for scripts and instances
+	paths := make(map[string]string, len(ast.ExtResources))
+	for _, ext := range ast.ExtResources {
+		paths[ext.ID] = s.extResourcePath(uri, ext)
+	}
+
+	var build func(sn *analysis.SceneNode) *sceneTreeNode
+	build = func(sn *analysis.SceneNode) *sceneTreeNode {
+		node := &sceneTreeNode{
+			Name:     sn.Node.Name,
+			Type:     sn.Node.Type,
+			Path:     sn.Path,
+			Range:    toProtocolRange(sn.Node.Range),
+			Groups:   sn.Node.Groups,
+			Children: []*sceneTreeNode{},
+		}
+		if ref, ok := sn.Node.Instance.(*parser.ResourceRef); ok && ref.RefType == "ExtResource" {
+			node.Instance = paths[ref.ID]
+			if node.Type == "" {
+				if _, scene := s.instancedScene(uri, ast, sn.Node); scene != nil {
+					if root := analysis.NewSceneTree(scene).Root; root != nil {
+						node.Type = root.Node.Type
+					}
+				}
+			}
+		}
+		for _, prop := range sn.Node.Properties {
+			if ref, ok := prop.Value.(*parser.ResourceRef); ok && prop.Key == "script" && ref.RefType == "ExtResource" {
+				node.Script = paths[ref.ID]
+			}
+		}
+		for _, child := range sn.Children {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+	return build(tree.Root), nil
+}
@@ -0,0 +1,85 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestSceneTree(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"enemy.tscn":    "[gd_scene format=3]\n\n[node name=\"Enemy\" type=\"CharacterBody2D\"]\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uri := pathToURI(filepath.Join(dir, "level.tscn"))
+	content := `[gd_scene load_steps=3 format=3]
+
+[ext_resource type="Script" path="res://level.gd" id="1_s"]
+[ext_resource type="PackedScene" path="res://enemy.tscn" id="2_e"]
+
+[node name="Level" type="Node2D"]
+script = ExtResource("1_s")
+
+[node name="Enemies" type="Node2D" parent="." groups=["spawners"]]
+
+[node name="Enemy" parent="Enemies" instance=ExtResource("2_e")]
+`
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.workspace.OpenDocument(uri, content)
+	client := connectServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Decoded by hand, as the protocol types drop experimental capabilities
+	var init struct {
+		Capabilities struct {
+			Experimental map[string]any `json:"experimental"`
+		} `json:"capabilities"`
+	}
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, &init); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if init.Capabilities.Experimental["sceneTree"] != true {
+		t.Errorf("expected sceneTree to be advertised, got %v", init.Capabilities.Experimental)
+	}
+
+	var root *sceneTreeNode
+	if err := client.Call(ctx, SceneTreeMethod, sceneTreeParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}}, &root); err != nil {
+		t.Fatalf("%s failed: %v", SceneTreeMethod, err)
+	}
+
+	got, _ := json.Marshal(root)
+	expected := `{"name":"Level","type":"Node2D","path":".","range":{"start":{"line":5,"character":0},"end":{"line":6,"character":27}},"script":"res://level.gd","children":[` +
+		`{"name":"Enemies","type":"Node2D","path":"Enemies","range":{"start":{"line":8,"character":0},"end":{"line":8,"character":66}},"groups":["spawners"],"children":[` +
+		`{"name":"Enemy","type":"CharacterBody2D","path":"Enemies/Enemy","range":{"start":{"line":10,"character":0},"end":{"line":10,"character":64}},"instance":"res://enemy.tscn","children":[]}]}]}`
+	if string(got) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// Files that are not scenes have no tree
+	root = &sceneTreeNode{}
+	if err := client.Call(ctx, SceneTreeMethod, sceneTreeParams{TextDocument: protocol.TextDocumentIdentifier{URI: pathToURI(filepath.Join(dir, "project.godot"))}}, &root); err != nil || root != nil {
+		t.Errorf("expected a null result, got %+v (%v)", root, err)
+	}
+
+	err := client.Call(ctx, SceneTreeMethod, []int{1}, nil)
+	var rpcErr *jsonrpc2.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != jsonrpc2.CodeInvalidParams {
+		t.Errorf("expected an invalid params error, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// maxScriptDepth bounds the chain of scripts extending each other that is
+// followed to find a method, which also stops scripts extending themselves.
+const maxScriptDepth = 16
+
+// loadScript reads the GDScript or C# script at uri from disk, with the
+// same guards as the workspace scanner. It returns nil for other files and
+// scripts that cannot be read.
+func (s *Server) loadScript(uri string) *analysis.Script {
+	path := uriToPath(uri)
+	if !analysis.IsScriptFile(path) {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > s.currentSettings().MaxFileSize {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || analysis.IsBinaryContent(content) {
+		return nil
+	}
+	return analysis.ParseScript(path, string(content))
+}
+
+// nodeScript returns the URI of the script attached to a node of the scene
+// at uri: its own script property, or the script of the root of the scene
+// it instances. It returns "" for nodes without a script.
+func (s *Server) nodeScript(uri string, ast *parser.Document, node *parser.Node) string {
+	for range maxInstanceDepth {
+		for _, prop := range node.Properties {
+			if prop.Key != "script" {
+				continue
+			}
+			ref, ok := prop.Value.(*parser.ResourceRef)
+			if !ok || ref.RefType != "ExtResource" {
+				return ""
+			}
+			for _, ext := range ast.ExtResources {
+				if ext.ID == ref.ID {
+					if loc := s.resolveResourcePath(s.extResourcePath(uri, ext), uri); loc != nil {
+						return loc.URI
+					}
+				}
+			}
+			return ""
+		}
+
+		sceneURI, scene := s.instancedScene(uri, ast, node)
+		root := analysis.NewSceneTree(scene).Root
+		if root == nil {
+			return ""
+		}
+		uri, ast, node = sceneURI, scene, root.Node
+	}
+	return ""
+}
+
+// scriptMethod looks up a method in the script at uri and the scripts it
+// extends. It returns where the method is declared, or, when no script
+// declares it, the engine class the scripts extend. complete is false when
+// a script of the chain cannot be read, so the method may be declared
+// there.
+func (s *Server) scriptMethod(uri, name string) (loc *protocol.Location, native string, complete bool) {
+	for range maxScriptDepth {
+		script := s.loadScript(uri)
+		if script == nil {
+			return nil, "", false
+		}
+		if m, ok := script.Method(name); ok {
+			pos := protocol.Position{Line: uint32(m.Line), Character: uint32(m.Column)}
+			end := protocol.Position{Line: pos.Line, Character: pos.Character + uint32(len(m.Name))}
+			return &protocol.Location{URI: uri, Range: protocol.Range{Start: pos, End: end}}, "", true
+		}
+
+		base := s.scriptBase(uri, script)
+		if base == nil {
+			if strings.Contains(script.Extends, "/") {
+				return nil, "", false
+			}
+			return nil, script.Extends, true
+		}
+		uri = base.URI
+	}
+	return nil, "", false
+}
+
+// scriptBase returns the location of the script a script extends, by path
+// or by class name, or nil when it extends an engine class.
+func (s *Server) scriptBase(uri string, script *analysis.Script) *protocol.Location {
+	switch {
+	case script.Extends == "":
+		return nil
+	case strings.Contains(script.Extends, "/"):
+		return s.resolveResourcePath(script.Extends, uri)
+	}
+	if class, ok := s.workspace.ScriptClass(uri, script.Extends); ok && class.Path != "" {
+		return s.resolveResourcePath(class.Path, uri)
+	}
+	return nil
+}
+
+// scriptClassLocation returns the location of the class a script declares,
+// or the start of the script when it declares none.
+func (s *Server) scriptClassLocation(loc *protocol.Location) *protocol.Location {
+	script := s.loadScript(loc.URI)
+	if script == nil || script.ClassLine < 0 {
+		return loc
+	}
+	pos := protocol.Position{Line: uint32(script.ClassLine)}
+	return &protocol.Location{URI: loc.URI, Range: protocol.Range{Start: pos, End: pos}}
+}
+
+// scriptName is the file name of a script, for messages.
+func scriptName(uri string) string {
+	return filepath.Base(uriToPath(uri))
+}
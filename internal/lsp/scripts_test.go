@@ -0,0 +1,99 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestConnectionMethods(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"actor.gd":      "class_name Actor extends CharacterBody2D\n\nfunc hurt(amount: int) -> void:\n\tpass\n",
+		"player.gd":     "extends Actor\n\nfunc _on_hit() -> void:\n\tpass\n",
+		"Door.cs":       "using Godot;\n\npublic partial class Door : Area2D\n{\n\tpublic void Open()\n\t{\n\t}\n}\n",
+		"door.tscn": `[gd_scene format=3]
+
+[ext_resource type="Script" path="res://Door.cs" id="1_door"]
+
+[node name="Door" type="Area2D"]
+script = ExtResource("1_door")
+`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	s.workspace.AddFolder(pathToURI(dir))
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	content := `[gd_scene format=3]
+
+[ext_resource type="Script" path="res://player.gd" id="1_player"]
+[ext_resource type="PackedScene" path="res://door.tscn" id="2_door"]
+
+[node name="Level" type="Node2D"]
+
+[node name="Player" type="CharacterBody2D" parent="."]
+script = ExtResource("1_player")
+
+[node name="Door" parent="." instance=ExtResource("2_door")]
+
+[node name="Timer" type="Timer" parent="."]
+
+[connection signal="timeout" from="Timer" to="Player" method="_on_hit"]
+[connection signal="timeout" from="Timer" to="Player" method="hurt"]
+[connection signal="timeout" from="Timer" to="Player" method="jump"]
+[connection signal="timeout" from="Timer" to="Door" method="Open"]
+[connection signal="timeout" from="Timer" to="Door" method="Close"]
+[connection signal="timeout" from="Timer" to="." method="anything"]
+[connection signal="timeout" from="Timer" to="Player" method="queue_free"]
+[connection signal="timeout" from="Timer" to="Door" method="set_monitoring"]
+`
+	doc := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "level.tscn")), content)
+
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		if d.Code.Value.(string) == codeMissingMethod {
+			messages = append(messages, fmt.Sprintf("%d:%d: %s", d.Range.Start.Line, d.Range.Start.Character, d.Message))
+		}
+	}
+	expected := []string{
+		"16:61: player.gd declares no method jump, nor do the scripts it extends or CharacterBody2D; the signal fails to connect",
+		"18:59: Door.cs declares no method Close, nor do the scripts it extends or Area2D; the signal fails to connect",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	for _, tc := range []struct {
+		line, col int
+		want      string
+	}{
+		{15, 65, "actor.gd:2:5"}, // Declared by the script player.gd extends
+		{17, 63, "Door.cs:4:13"}, // Through the instanced scene
+		{2, 40, "player.gd:0:0"}, // A script without a class declaration
+	} {
+		loc := s.findDefinition(doc, doc.URI, tc.line, tc.col)
+		if loc == nil {
+			t.Errorf("%d:%d: expected a definition", tc.line, tc.col)
+			continue
+		}
+		got := fmt.Sprintf("%s:%d:%d", filepath.Base(uriToPath(loc.URI)), loc.Range.Start.Line, loc.Range.Start.Character)
+		if got != tc.want {
+			t.Errorf("%d:%d: expected %s, got %s", tc.line, tc.col, tc.want, got)
+		}
+	}
+
+	// A C# script path goes to its class
+	door := s.workspace.OpenDocument(pathToURI(filepath.Join(dir, "door.tscn")), "[gd_scene format=3]\n\n[ext_resource type=\"Script\" path=\"res://Door.cs\" id=\"1_door\"]\n")
+	if loc := s.findDefinition(door, door.URI, 2, 36); loc == nil || loc.Range.Start.Line != 2 {
+		t.Errorf("expected the Door class, got %+v", loc)
+	}
+}
@@ -1,7 +1,11 @@
 package lsp
 
 import (
+	"context"
+	"runtime"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
@@ -10,7 +14,7 @@ import (
 	"github.com/andresperezl/gdls/internal/parser"
 )
 
-// Semantic token types (must match the order in server.go SemanticTokensLegend)
+// Semantic token types (must match the order of the legend advertised below)
 const (
 	tokenTypeKeyword   = 0 // gd_scene, ext_resource, sub_resource, node, connection
 	tokenTypeType      = 1 // Vector3, Transform3D, Color, node types
@@ -23,6 +27,62 @@ const (
 	tokenTypeParameter = 8 // parameters in headings
 )
 
+// semanticTokenTypes is the token type legend advertised to clients.
+var semanticTokenTypes = []string{
+	"keyword",   // gd_scene, ext_resource, sub_resource, node, connection
+	"type",      // Vector3, Transform3D, Color, node types
+	"string",    // string literals
+	"number",    // numeric literals
+	"property",  // property keys
+	"function",  // type constructors
+	"comment",   // ; comments
+	"variable",  // resource IDs
+	"parameter", // parameters in headings
+}
+
+// Semantic token modifiers, as bits in the order of semanticTokenModifiers.
+// The last three are gdls' own, for editors without a grammar for scenes to
+// color references distinctly.
+const (
+	tokenModifierDeclaration = 1 << iota
+	tokenModifierDefinition
+	tokenModifierReference
+	tokenModifierResourceID // IDs of ExtResource() and SubResource()
+	tokenModifierNodePath   // parent= and connection paths, NodePath() strings
+	tokenModifierUID        // uid:// strings
+)
+
+// semanticTokenModifiers is the modifier legend advertised to clients.
+var semanticTokenModifiers = []string{
+	"declaration",
+	"definition",
+	"reference",
+	"resourceId",
+	"nodePath",
+	"uid",
+}
+
+// SemanticLegendMethod is the request an extension sends to learn what the
+// custom semantic token modifiers mean, e.g. to map them to theme colors.
+const SemanticLegendMethod = "gdls/semanticLegendExtended"
+
+// customTokenModifiers describes the modifiers beyond the standard ones.
+var customTokenModifiers = []customTokenModifier{
+	{Name: "resourceId", Description: "The id of an ExtResource() or SubResource() reference"},
+	{Name: "nodePath", Description: "A node path: parent=, the from= and to= of connections, and NodePath() strings"},
+	{Name: "uid", Description: "A uid:// identifier"},
+}
+
+const (
+	// semanticTokensChunkThreshold is the document size in bytes above which
+	// semantic tokens are computed in chunks with cancellation checks.
+	semanticTokensChunkThreshold = 256 * 1024
+
+	// semanticTokensChunkSize is the number of sections or properties processed
+	// between cancellation checks.
+	semanticTokensChunkSize = 1024
+)
+
 // semanticToken represents a single semantic token.
 type semanticToken struct {
 	line      uint32
@@ -32,29 +92,123 @@ type semanticToken struct {
 	modifiers uint32
 }
 
-// textDocumentSemanticTokensFull handles the textDocument/semanticTokens/full request.
+func init() {
+	registerCapability(&capability{
+		name: "semanticTokens",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentSemanticTokensFull = s.textDocumentSemanticTokensFull
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.SemanticTokensProvider = &protocol.SemanticTokensOptions{
+				Legend: protocol.SemanticTokensLegend{
+					TokenTypes:     semanticTokenTypes,
+					TokenModifiers: semanticTokenModifiers,
+				},
+				Full: boolPtr(true),
+			}
+		},
+	})
+	registerCapability(&capability{
+		name: "semanticLegendExtended",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[SemanticLegendMethod] = customRequest(s.semanticLegendExtended)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["semanticLegendExtended"] = true
+		},
+	})
+}
+
+// customTokenModifier is a custom modifier of the gdls/semanticLegendExtended
+// result.
+type customTokenModifier struct {
+	Name        string `json:"name"`
+	Bit         int    `json:"bit"` // Bit of the modifier in semantic tokens
+	Description string `json:"description"`
+}
+
+// semanticLegendResult is the result of the gdls/semanticLegendExtended
+// request.
+type semanticLegendResult struct {
+	TokenTypes      []string              `json:"tokenTypes"`
+	TokenModifiers  []string              `json:"tokenModifiers"`
+	CustomModifiers []customTokenModifier `json:"customModifiers"`
+}
+
+// semanticLegendExtended handles the gdls/semanticLegendExtended request,
+// returning the semantic tokens legend with a description of each custom
+// modifier.
+func (s *Server) semanticLegendExtended(ctx *glsp.Context, params *struct{}) (any, error) {
+	result := semanticLegendResult{
+		TokenTypes:     semanticTokenTypes,
+		TokenModifiers: semanticTokenModifiers,
+	}
+	for _, m := range customTokenModifiers {
+		m.Bit = slices.Index(semanticTokenModifiers, m.Name)
+		result.CustomModifiers = append(result.CustomModifiers, m)
+	}
+	return result, nil
+}
+
+// textDocumentSemanticTokensFull handles the textDocument/semanticTokens/full
+// request. Tokens only depend on the document, so they are computed once
+// per version.
 func (s *Server) textDocumentSemanticTokensFull(ctx *glsp.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
 	if doc == nil {
 		return nil, nil
 	}
 
-	var tokens []semanticToken
-
 	switch doc.Type {
 	case analysis.DocumentTypeTSCN:
 		if doc.TSCNAST == nil {
 			return nil, nil
 		}
-		tokens = s.collectTSCNSemanticTokens(doc.TSCNAST)
 	case analysis.DocumentTypeGDShader:
 		// For now, GDShader semantic tokens are handled by VS Code's TextMate grammar
 		// We can add more advanced semantic highlighting later
 		return nil, nil
+	case analysis.DocumentTypeGLSL:
+		if doc.GLSLAST == nil {
+			return nil, nil
+		}
 	default:
 		return nil, nil
 	}
 
+	result, err := doc.Derived("semanticTokens", func() (any, error) {
+		if doc.Type == analysis.DocumentTypeGLSL {
+			tokens := glslSemanticTokens(doc)
+			s.semanticTokensToClient(doc, tokens)
+			return &protocol.SemanticTokens{Data: encodeSemanticTokens(tokens)}, nil
+		}
+
+		// Stop early if the request is cancelled or the document changes
+		workCtx, cancel := context.WithCancel(s.requestContext(ctx))
+		defer cancel()
+		stop := context.AfterFunc(s.documentContext(doc.URI), cancel)
+		defer stop()
+
+		y := &tokenYielder{ctx: workCtx}
+		if len(doc.Content) > semanticTokensChunkThreshold {
+			y.every = semanticTokensChunkSize
+		}
+		tokens, err := s.collectTSCNSemanticTokens(y, doc.TSCNAST)
+		if err != nil {
+			return nil, err
+		}
+		s.semanticTokensToClient(doc, tokens)
+		return &protocol.SemanticTokens{Data: encodeSemanticTokens(tokens)}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*protocol.SemanticTokens), nil
+}
+
+// encodeSemanticTokens sorts tokens by position and encodes them relative
+// to each other, as the protocol sends them.
+func encodeSemanticTokens(tokens []semanticToken) []uint32 {
 	// Sort tokens by position
 	sort.Slice(tokens, func(i, j int) bool {
 		if tokens[i].line != tokens[j].line {
@@ -80,14 +234,34 @@ func (s *Server) textDocumentSemanticTokensFull(ctx *glsp.Context, params *proto
 		prevLine = tok.line
 		prevChar = tok.startChar
 	}
+	return data
+}
 
-	return &protocol.SemanticTokens{
-		Data: data,
-	}, nil
+// tokenYielder periodically checks for cancellation while tokens are collected.
+// A zero every disables the checks, which is what small documents use.
+type tokenYielder struct {
+	ctx   context.Context
+	every int
+	steps int
+}
+
+// step records one unit of work. Every y.every steps it yields the processor
+// and reports whether the context has been cancelled.
+func (y *tokenYielder) step() error {
+	if y.every <= 0 {
+		return nil
+	}
+	y.steps++
+	if y.steps%y.every != 0 {
+		return nil
+	}
+	runtime.Gosched()
+	return y.ctx.Err()
 }
 
 // collectTSCNSemanticTokens collects all semantic tokens from a TSCN AST.
-func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken {
+// It returns the context error if the yielder's context is cancelled midway.
+func (s *Server) collectTSCNSemanticTokens(y *tokenYielder, ast *parser.Document) ([]semanticToken, error) {
 	tokens := []semanticToken{}
 
 	// Descriptor tokens
@@ -99,10 +273,16 @@ func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken
 			length:    uint32(len(ast.Descriptor.Type)),
 			tokenType: tokenTypeKeyword,
 		})
+		if ast.Descriptor.UID != "" {
+			tokens = append(tokens, rangeToken(ast.Descriptor.UIDRange, tokenTypeString, tokenModifierDeclaration|tokenModifierUID))
+		}
 	}
 
 	// External resources
 	for _, ext := range ast.ExtResources {
+		if err := y.step(); err != nil {
+			return nil, err
+		}
 		// Section keyword
 		tokens = append(tokens, semanticToken{
 			line:      uint32(ext.Range.Start.Line),
@@ -110,10 +290,16 @@ func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken
 			length:    12, // "ext_resource"
 			tokenType: tokenTypeKeyword,
 		})
+		if ext.UID != "" {
+			tokens = append(tokens, rangeToken(ext.UIDRange, tokenTypeString, tokenModifierReference|tokenModifierUID))
+		}
 	}
 
 	// Sub resources
 	for _, sub := range ast.SubResources {
+		if err := y.step(); err != nil {
+			return nil, err
+		}
 		// Section keyword
 		tokens = append(tokens, semanticToken{
 			line:      uint32(sub.Range.Start.Line),
@@ -124,12 +310,18 @@ func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken
 
 		// Properties
 		for _, prop := range sub.Properties {
+			if err := y.step(); err != nil {
+				return nil, err
+			}
 			tokens = append(tokens, s.tokenizeProperty(prop)...)
 		}
 	}
 
 	// Nodes
 	for _, node := range ast.Nodes {
+		if err := y.step(); err != nil {
+			return nil, err
+		}
 		// Section keyword
 		tokens = append(tokens, semanticToken{
 			line:      uint32(node.Range.Start.Line),
@@ -137,25 +329,44 @@ func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken
 			length:    4, // "node"
 			tokenType: tokenTypeKeyword,
 		})
+		if node.Parent != "" {
+			tokens = append(tokens, rangeToken(node.ParentRange, tokenTypeString, tokenModifierNodePath))
+		}
+		tokens = append(tokens, s.tokenizeValue(node.Instance)...)
 
 		// Properties
 		for _, prop := range node.Properties {
+			if err := y.step(); err != nil {
+				return nil, err
+			}
 			tokens = append(tokens, s.tokenizeProperty(prop)...)
 		}
 	}
 
 	// Connections
 	for _, conn := range ast.Connections {
+		if err := y.step(); err != nil {
+			return nil, err
+		}
 		tokens = append(tokens, semanticToken{
 			line:      uint32(conn.Range.Start.Line),
 			startChar: uint32(conn.Range.Start.Column) + 1,
 			length:    10, // "connection"
 			tokenType: tokenTypeKeyword,
 		})
+		if conn.From != "" {
+			tokens = append(tokens, rangeToken(conn.FromRange, tokenTypeString, tokenModifierNodePath))
+		}
+		if conn.To != "" {
+			tokens = append(tokens, rangeToken(conn.ToRange, tokenTypeString, tokenModifierNodePath))
+		}
 	}
 
 	// Comments
 	for _, comment := range ast.Comments {
+		if err := y.step(); err != nil {
+			return nil, err
+		}
 		tokens = append(tokens, semanticToken{
 			line:      uint32(comment.Range.Start.Line),
 			startChar: uint32(comment.Range.Start.Column),
@@ -164,7 +375,7 @@ func (s *Server) collectTSCNSemanticTokens(ast *parser.Document) []semanticToken
 		})
 	}
 
-	return tokens
+	return tokens, nil
 }
 
 // tokenizeProperty tokenizes a property and its value.
@@ -195,12 +406,11 @@ func (s *Server) tokenizeValue(v parser.Value) []semanticToken {
 
 	switch val := v.(type) {
 	case *parser.StringValue:
-		tokens = append(tokens, semanticToken{
-			line:      uint32(val.Range.Start.Line),
-			startChar: uint32(val.Range.Start.Column),
-			length:    uint32(val.Range.End.Column - val.Range.Start.Column),
-			tokenType: tokenTypeString,
-		})
+		var modifiers uint32
+		if strings.HasPrefix(val.Value, "uid://") {
+			modifiers = tokenModifierUID
+		}
+		tokens = append(tokens, rangeToken(val.Range, tokenTypeString, modifiers))
 
 	case *parser.NumberValue:
 		tokens = append(tokens, semanticToken{
@@ -218,8 +428,15 @@ func (s *Server) tokenizeValue(v parser.Value) []semanticToken {
 			length:    uint32(val.TypeRange.End.Column - val.TypeRange.Start.Column),
 			tokenType: tokenTypeFunction,
 		})
-		// Arguments
+		// Element types of typed collections, then arguments
+		for _, param := range val.TypeParams {
+			tokens = append(tokens, s.tokenizeValue(param)...)
+		}
 		for _, arg := range val.Arguments {
+			if str, ok := arg.(*parser.StringValue); ok && val.TypeName == "NodePath" {
+				tokens = append(tokens, rangeToken(str.Range, tokenTypeString, tokenModifierNodePath))
+				continue
+			}
 			tokens = append(tokens, s.tokenizeValue(arg)...)
 		}
 
@@ -232,12 +449,7 @@ func (s *Server) tokenizeValue(v parser.Value) []semanticToken {
 			tokenType: tokenTypeFunction,
 		})
 		// ID
-		tokens = append(tokens, semanticToken{
-			line:      uint32(val.IDRange.Start.Line),
-			startChar: uint32(val.IDRange.Start.Column),
-			length:    uint32(val.IDRange.End.Column - val.IDRange.Start.Column),
-			tokenType: tokenTypeVariable,
-		})
+		tokens = append(tokens, rangeToken(val.IDRange, tokenTypeVariable, tokenModifierReference|tokenModifierResourceID))
 
 	case *parser.ArrayValue:
 		for _, elem := range val.Values {
@@ -253,3 +465,14 @@ func (s *Server) tokenizeValue(v parser.Value) []semanticToken {
 
 	return tokens
 }
+
+// rangeToken returns the token covering a single-line range.
+func rangeToken(r parser.Range, tokenType, modifiers uint32) semanticToken {
+	return semanticToken{
+		line:      uint32(r.Start.Line),
+		startChar: uint32(r.Start.Column),
+		length:    uint32(r.End.Column - r.Start.Column),
+		tokenType: tokenType,
+		modifiers: modifiers,
+	}
+}
@@ -0,0 +1,150 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// generateLargeScene builds a scene of roughly the requested number of lines.
+func generateLargeScene(lines int) string {
+	var b strings.Builder
+	b.WriteString("[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node3D\"]\n\n")
+	for i := 0; i < lines/5; i++ {
+		fmt.Fprintf(&b, "[node name=\"Node%d\" type=\"Node3D\" parent=\".\"]\n", i)
+		fmt.Fprintf(&b, "position = Vector3(%d, 0, 0)\n", i)
+		b.WriteString("visible = true\n")
+		fmt.Fprintf(&b, "editor_description = \"node %d\"\n\n", i)
+	}
+	return b.String()
+}
+
+func TestSemanticTokensCancelledContextStopsWork(t *testing.T) {
+	ast := parser.Parse(generateLargeScene(100000))
+	s := NewServer("test", "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	y := &tokenYielder{ctx: ctx, every: semanticTokensChunkSize}
+	tokens, err := s.collectTSCNSemanticTokens(y, ast)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if tokens != nil {
+		t.Errorf("expected no tokens after cancellation, got %d", len(tokens))
+	}
+	if y.steps != semanticTokensChunkSize {
+		t.Errorf("expected work to stop after %d steps, got %d", semanticTokensChunkSize, y.steps)
+	}
+}
+
+// cancelAfterContext is a context that reports cancellation after Err has
+// been called a fixed number of times.
+type cancelAfterContext struct {
+	context.Context
+	remaining int
+}
+
+func (c *cancelAfterContext) Err() error {
+	if c.remaining <= 0 {
+		return context.Canceled
+	}
+	c.remaining--
+	return nil
+}
+
+func TestSemanticTokensCancelledMidway(t *testing.T) {
+	ast := parser.Parse(generateLargeScene(100000))
+	s := NewServer("test", "test")
+
+	full, err := s.collectTSCNSemanticTokens(&tokenYielder{ctx: context.Background()}, ast)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := &cancelAfterContext{Context: context.Background(), remaining: 3}
+	y := &tokenYielder{ctx: ctx, every: semanticTokensChunkSize}
+	_, err = s.collectTSCNSemanticTokens(y, ast)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if y.steps != 4*semanticTokensChunkSize {
+		t.Errorf("expected work to stop after %d steps, got %d", 4*semanticTokensChunkSize, y.steps)
+	}
+	if y.steps >= len(full) {
+		t.Errorf("expected cancellation before finishing, processed %d steps for %d tokens", y.steps, len(full))
+	}
+}
+
+func TestSemanticTokensSmallDocumentNotChunked(t *testing.T) {
+	ast := parser.Parse(generateLargeScene(100))
+	s := NewServer("test", "test")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tokens, err := s.collectTSCNSemanticTokens(&tokenYielder{ctx: ctx}, ast)
+	if err != nil {
+		t.Fatalf("expected no error for unchunked collection, got %v", err)
+	}
+	if len(tokens) == 0 {
+		t.Error("expected tokens")
+	}
+}
+
+func TestSemanticTokenModifiers(t *testing.T) {
+	content := `[gd_scene format=3 uid="uid://main"]
+
+[ext_resource type="Script" uid="uid://script" path="res://main.gd" id="1_s"]
+
+[node name="Main" type="Node"]
+script = ExtResource("1_s")
+target = NodePath("Child")
+
+[node name="Child" type="Node" parent="."]
+
+[connection signal="ready" from="Child" to="." method="_on_ready"]
+`
+	s := NewServer("test", "test")
+	tokens, err := s.collectTSCNSemanticTokens(&tokenYielder{ctx: context.Background()}, parser.Parse(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(content, "\n")
+	got := map[string]uint32{}
+	for _, tok := range tokens {
+		if tok.modifiers != 0 {
+			got[lines[tok.line][tok.startChar:tok.startChar+tok.length]] |= tok.modifiers
+		}
+	}
+	expected := map[string]uint32{
+		`"uid://main"`:   tokenModifierDeclaration | tokenModifierUID,
+		`"uid://script"`: tokenModifierReference | tokenModifierUID,
+		`"1_s"`:          tokenModifierReference | tokenModifierResourceID,
+		`"Child"`:        tokenModifierNodePath,
+		`"."`:            tokenModifierNodePath,
+	}
+	for text, modifiers := range expected {
+		if got[text] != modifiers {
+			t.Errorf("expected %s to have modifiers %b, got %b", text, modifiers, got[text])
+		}
+	}
+
+	result, _ := s.semanticLegendExtended(nil, &struct{}{})
+	legend := result.(semanticLegendResult)
+	for _, m := range legend.CustomModifiers {
+		if m.Bit < 0 || legend.TokenModifiers[m.Bit] != m.Name {
+			t.Errorf("custom modifier %q has the wrong bit %d", m.Name, m.Bit)
+		}
+	}
+	if len(legend.CustomModifiers) != 3 || 1<<legend.CustomModifiers[1].Bit != tokenModifierNodePath {
+		t.Errorf("unexpected custom modifiers: %+v", legend.CustomModifiers)
+	}
+}
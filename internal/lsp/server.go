@@ -2,11 +2,17 @@
 package lsp
 
 import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+	"github.com/tliron/commonlog"
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
-	"github.com/tliron/glsp/server"
 
 	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/config"
 )
 
 // Server represents the TSCN language server.
@@ -14,50 +20,167 @@ type Server struct {
 	name      string
 	version   string
 	handler   protocol.Handler
-	server    *server.Server
+	custom    map[string]customHandler // gdls/ requests by method
+	log       commonlog.Logger
 	workspace *analysis.Workspace
+
+	reqCtxMu sync.Mutex
+	reqCtx   map[*glsp.Context]context.Context
+
+	docCtxMu sync.Mutex
+	docCtx   map[string]*docContext
+
+	settingsMu sync.RWMutex
+	settings   config.Settings
+
+	// watchFiles is set when the client can watch files on behalf of the
+	// server.
+	watchFiles bool
+
+	// workDoneProgress is set when the client shows progress the server
+	// starts on its own.
+	workDoneProgress bool
+
+	// snippetSupport is set when the client expands snippets in the text
+	// inserted by completions.
+	snippetSupport bool
+
+	// pullDiagnostics is set when the client pulls diagnostics, which are
+	// then no longer published. diagnosticRefresh is set when it can be
+	// asked to pull them again.
+	pullDiagnostics   bool
+	diagnosticRefresh bool
+
+	// positionEncoding is the encoding of the columns of the positions
+	// exchanged with the client, utf-16 unless it offers utf-8.
+	positionEncoding string
+
+	compileChecks *compileChecks // Results of gdls/compileCheck
+
+	fileReportsMu sync.Mutex
+	fileReports   map[string]fileReport // Diagnostics of closed files, by URI
+
+	progressMu sync.Mutex
+	progressID int
+	progress   map[string]context.CancelFunc // By progress token
+
+	lifecycle lifecycle
+
+	// trace is the $/setTrace value of the client, which asks for a
+	// $/logTrace notification for every message handled.
+	traceMu sync.Mutex
+	trace   protocol.TraceValue
+
+	counters *serverStats // For the gdls/stats request
+
+	// parentPID is the process watched by RunStdio, from WatchParent or
+	// else the processId of the initialize request. stopStdio ends
+	// RunStdio when it is gone.
+	parentPID int
+	parentCtx context.Context
+	stopStdio context.CancelCauseFunc
 }
 
 // NewServer creates a new TSCN language server.
 func NewServer(name, version string) *Server {
 	s := &Server{
-		name:      name,
-		version:   version,
-		workspace: analysis.NewWorkspace(),
+		name:          name,
+		version:       version,
+		log:           commonlog.GetLoggerf("%s.server", name),
+		workspace:     analysis.NewWorkspace(),
+		reqCtx:        make(map[*glsp.Context]context.Context),
+		docCtx:        make(map[string]*docContext),
+		custom:        make(map[string]customHandler),
+		settings:      config.Default(),
+		progress:      make(map[string]context.CancelFunc),
+		fileReports:   make(map[string]fileReport),
+		compileChecks: newCompileChecks(),
+		trace:         protocol.TraceValueOff,
+		counters:      newServerStats(),
 	}
 
 	s.handler = protocol.Handler{
-		Initialize:                     s.initialize,
-		Initialized:                    s.initialized,
-		Shutdown:                       s.shutdown,
-		SetTrace:                       s.setTrace,
-		TextDocumentDidOpen:            s.textDocumentDidOpen,
-		TextDocumentDidChange:          s.textDocumentDidChange,
-		TextDocumentDidClose:           s.textDocumentDidClose,
-		TextDocumentDidSave:            s.textDocumentDidSave,
-		TextDocumentHover:              s.textDocumentHover,
-		TextDocumentDefinition:         s.textDocumentDefinition,
-		TextDocumentDocumentSymbol:     s.textDocumentDocumentSymbol,
-		TextDocumentCompletion:         s.textDocumentCompletion,
-		TextDocumentFoldingRange:       s.textDocumentFoldingRange,
-		TextDocumentDocumentLink:       s.textDocumentDocumentLink,
-		TextDocumentReferences:         s.textDocumentReferences,
-		TextDocumentSemanticTokensFull: s.textDocumentSemanticTokensFull,
-	}
-
-	s.server = server.NewServer(&s.handler, name, false)
+		Initialize:            s.initialize,
+		Initialized:           s.initialized,
+		Shutdown:              s.shutdown,
+		SetTrace:              s.setTrace,
+		TextDocumentDidOpen:   s.textDocumentDidOpen,
+		TextDocumentDidChange: s.textDocumentDidChange,
+		TextDocumentDidClose:  s.textDocumentDidClose,
+		TextDocumentDidSave:   s.textDocumentDidSave,
+
+		WindowWorkDoneProgressCancel:       s.windowWorkDoneProgressCancel,
+		WorkspaceDidChangeConfiguration:    s.workspaceDidChangeConfiguration,
+		WorkspaceDidChangeWorkspaceFolders: s.workspaceDidChangeWorkspaceFolders,
+	}
+
+	// Shaders see the declarations of the files they include
+	s.workspace.SetIncludeLoader(s.loadShaderInclude)
+
+	// Install the handlers of every capability module
+	for _, c := range capabilityRegistry {
+		c.register(s, &s.handler)
+	}
 
 	return s
 }
 
-// RunStdio runs the server using stdio transport.
+// WatchParent makes RunStdio return once the process pid is gone, for
+// editors that can die without closing the connection. Without it, the
+// processId of the initialize request is watched.
+func (s *Server) WatchParent(pid int) {
+	s.parentPID = pid
+}
+
+// RunStdio runs the server using stdio transport. It returns
+// ErrExitWithoutShutdown if the client exited without shutting it down,
+// and ErrParentExited if the parent process died.
 func (s *Server) RunStdio() error {
-	return s.server.RunStdio()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	s.parentCtx, s.stopStdio = ctx, cancel
+	if s.parentPID > 0 {
+		go s.watchParent(s.parentPID)
+	}
+
+	s.log.Info("reading from stdin, writing to stdout")
+	s.serve(ctx, jsonrpc2.NewBufferedStream(stdio{}, lspCodec{}))
+	s.log.Info("stdin/stdout connection closed")
+	if err := context.Cause(ctx); errors.Is(err, ErrParentExited) {
+		return err
+	}
+	return s.lifecycle.exitError()
+}
+
+// watchParent stops RunStdio once the process pid is gone.
+func (s *Server) watchParent(pid int) {
+	watchParent(s.parentCtx, pid, parentCheckInterval, func() {
+		s.log.Errorf("parent process %d is gone, exiting", pid)
+		s.stopStdio(ErrParentExited)
+	})
 }
 
 // initialize handles the initialize request from the client.
 func (s *Server) initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, error) {
-	capabilities := s.handler.CreateServerCapabilities()
+	if params.Trace != nil {
+		s.setTraceValue(*params.Trace)
+	}
+
+	// Over stdio the client runs on the same machine, so its process can be
+	// watched
+	if s.stopStdio != nil && s.parentPID == 0 && params.ProcessID != nil && *params.ProcessID > 0 {
+		go s.watchParent(int(*params.ProcessID))
+	}
+
+	// Apply initialization options
+	if opts, ok := params.InitializationOptions.(map[string]any); ok {
+		s.applySettings(opts)
+	}
+
+	var capabilities serverCapabilities
+
+	s.positionEncoding = clientPositionEncoding(ctx.Params)
+	capabilities.PositionEncoding = s.positionEncoding
 
 	// Configure text document sync - use full sync for simplicity
 	sync := protocol.TextDocumentSyncKindFull
@@ -69,56 +192,22 @@ func (s *Server) initialize(ctx *glsp.Context, params *protocol.InitializeParams
 		},
 	}
 
-	// Enable hover
-	capabilities.HoverProvider = &protocol.HoverOptions{}
-
-	// Enable go to definition
-	capabilities.DefinitionProvider = &protocol.DefinitionOptions{}
-
-	// Enable document symbols (outline)
-	capabilities.DocumentSymbolProvider = &protocol.DocumentSymbolOptions{}
-
-	// Enable completion
-	capabilities.CompletionProvider = &protocol.CompletionOptions{
-		TriggerCharacters: []string{"\"", "/", "="},
-		ResolveProvider:   boolPtr(false),
-	}
-
-	// Enable folding ranges
-	capabilities.FoldingRangeProvider = &protocol.FoldingRangeOptions{}
-
-	// Enable document links (clickable paths)
-	capabilities.DocumentLinkProvider = &protocol.DocumentLinkOptions{
-		ResolveProvider: boolPtr(false),
-	}
-
-	// Enable find references
-	capabilities.ReferencesProvider = &protocol.ReferenceOptions{}
-
-	// Enable semantic tokens
-	capabilities.SemanticTokensProvider = &protocol.SemanticTokensOptions{
-		Legend: protocol.SemanticTokensLegend{
-			TokenTypes: []string{
-				"keyword",   // gd_scene, ext_resource, sub_resource, node, connection
-				"type",      // Vector3, Transform3D, Color, node types
-				"string",    // string literals
-				"number",    // numeric literals
-				"property",  // property keys
-				"function",  // type constructors
-				"comment",   // ; comments
-				"variable",  // resource IDs
-				"parameter", // parameters in headings
-			},
-			TokenModifiers: []string{
-				"declaration",
-				"definition",
-				"reference",
-			},
+	// Each workspace folder is a project of its own, and folders can be
+	// added or removed while the server runs
+	capabilities.Workspace = &protocol.ServerCapabilitiesWorkspace{
+		WorkspaceFolders: &protocol.WorkspaceFoldersServerCapabilities{
+			Supported:           boolPtr(true),
+			ChangeNotifications: &protocol.BoolOrString{Value: true},
 		},
-		Full: boolPtr(true),
 	}
 
-	// Store workspace folders if provided
+	// Enable every capability module the client has not turned off, and
+	// stop serving the others
+	s.advertiseCapabilities(&capabilities)
+	s.removeDisabledHandlers()
+
+	// Store workspace folders if provided; they are indexed once the
+	// client is initialized
 	if params.WorkspaceFolders != nil {
 		for _, folder := range params.WorkspaceFolders {
 			s.workspace.AddFolder(folder.URI)
@@ -127,7 +216,26 @@ func (s *Server) initialize(ctx *glsp.Context, params *protocol.InitializeParams
 		s.workspace.AddFolder(*params.RootURI)
 	}
 
-	return protocol.InitializeResult{
+	if window := params.Capabilities.Window; window != nil {
+		s.workDoneProgress = window.WorkDoneProgress != nil && *window.WorkDoneProgress
+	}
+
+	if textDocument := params.Capabilities.TextDocument; textDocument != nil && textDocument.Completion != nil {
+		item := textDocument.Completion.CompletionItem
+		s.snippetSupport = item != nil && item.SnippetSupport != nil && *item.SnippetSupport
+	}
+
+	// Watched files can only be registered dynamically
+	if workspace := params.Capabilities.Workspace; workspace != nil && workspace.DidChangeWatchedFiles != nil {
+		s.watchFiles = workspace.DidChangeWatchedFiles.DynamicRegistration != nil && *workspace.DidChangeWatchedFiles.DynamicRegistration
+	}
+
+	// Clients that pull diagnostics would show pushed ones twice
+	if s.capabilityEnabled("pullDiagnostics") {
+		s.pullDiagnostics, s.diagnosticRefresh = clientPullsDiagnostics(ctx.Params)
+	}
+
+	return initializeResult{
 		Capabilities: capabilities,
 		ServerInfo: &protocol.InitializeResultServerInfo{
 			Name:    s.name,
@@ -138,21 +246,45 @@ func (s *Server) initialize(ctx *glsp.Context, params *protocol.InitializeParams
 
 // initialized handles the initialized notification from the client.
 func (s *Server) initialized(ctx *glsp.Context, params *protocol.InitializedParams) error {
+	// Notifications are handled on the read loop, which must keep running
+	// while the folders are scanned and to receive the client's replies
+	go s.indexWorkspace(ctx)
+	if s.watchFiles && s.capabilityEnabled("watchedFiles") {
+		go s.registerFileWatchers(ctx)
+	}
 	return nil
 }
 
 // shutdown handles the shutdown request from the client.
 func (s *Server) shutdown(ctx *glsp.Context) error {
-	protocol.SetTraceValue(protocol.TraceValueOff)
+	s.setTraceValue(protocol.TraceValueOff)
 	return nil
 }
 
 // setTrace handles the setTrace notification from the client.
 func (s *Server) setTrace(ctx *glsp.Context, params *protocol.SetTraceParams) error {
-	protocol.SetTraceValue(params.Value)
+	s.setTraceValue(params.Value)
 	return nil
 }
 
+// setTraceValue sets the trace value of the session. Some clients send
+// "messages" for "message".
+func (s *Server) setTraceValue(value protocol.TraceValue) {
+	if value == "messages" {
+		value = protocol.TraceValueMessage
+	}
+	s.traceMu.Lock()
+	defer s.traceMu.Unlock()
+	s.trace = value
+}
+
+// traceValue returns the trace value of the session.
+func (s *Server) traceValue() protocol.TraceValue {
+	s.traceMu.Lock()
+	defer s.traceMu.Unlock()
+	return s.trace
+}
+
 // boolPtr is a helper to create a pointer to a bool.
 func boolPtr(b bool) *bool {
 	return &b
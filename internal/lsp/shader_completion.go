@@ -0,0 +1,367 @@
+package lsp
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// shaderDotPattern matches a word being typed after a dot: a member access
+// such as "light.col" or "texture(tex, UV).", or a float literal like "1.".
+var shaderDotPattern = regexp.MustCompile(`\.\w*$`)
+
+// getShaderCompletions returns completions for a GDShader document: shader
+// types in the shader_type declaration, render modes in a render_mode
+// declaration, struct fields or swizzles after a dot, otherwise keywords,
+// built-in functions and the symbols visible at the cursor, including the
+// built-in variables of the processor function it is in, and snippets of
+// whole constructs when the client supports them. Render modes and built-in
+// variables are those of the Godot version the project targets.
+func (s *Server) getShaderCompletions(doc *analysis.Document, prefix string, offset int) []protocol.CompletionItem {
+	if doc.ShaderAST == nil || strings.Contains(prefix, "//") {
+		return nil
+	}
+
+	pos := gdshader.Position{
+		Line:   strings.Count(doc.Content[:offset], "\n"),
+		Column: len(prefix),
+	}
+
+	version := s.workspace.ShaderVersion()
+	analyzer := gdshader.NewAnalyzer(doc.ShaderAST)
+	analyzer.SetVersion(version)
+
+	if shaderTypePattern.MatchString(prefix) {
+		return shaderTypeCompletions()
+	}
+	if renderModePattern.MatchString(prefix) {
+		return shaderRenderModeCompletions(doc.ShaderAST, version)
+	}
+
+	if shaderDotPattern.MatchString(prefix) {
+		// A float literal has no member access, so nothing is offered
+		return shaderMemberCompletions(analyzer.MemberBaseTypeAt(pos))
+	}
+
+	symbols := analyzer.SymbolsAt(pos)
+	items := []protocol.CompletionItem{}
+	items = append(items, shaderKeywordCompletions()...)
+	items = append(items, shaderBuiltinFunctionCompletions()...)
+	items = append(items, shaderSymbolCompletions(doc, version, symbols)...)
+	if s.snippetSupport && s.currentSettings().ShaderSnippets {
+		items = append(items, shaderSnippetCompletions(doc.ShaderAST, pos, symbols)...)
+	}
+	return items
+}
+
+// shaderTypePattern matches a line prefix where the type of a shader_type
+// declaration is typed.
+var shaderTypePattern = regexp.MustCompile(`^\s*shader_type\s+\w*$`)
+
+// shaderTypeCompletions offers the shader types.
+func shaderTypeCompletions() []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindEnumMember
+	for _, shaderType := range gdshader.ShaderTypes {
+		items = append(items, protocol.CompletionItem{
+			Label:  string(shaderType),
+			Kind:   &kind,
+			Detail: strPtr(gdshader.ShaderTypeDescriptions[shaderType]),
+		})
+	}
+	return items
+}
+
+// renderModePattern matches a line prefix inside a render_mode declaration,
+// after the keyword or a comma.
+var renderModePattern = regexp.MustCompile(`^\s*render_mode\s+(\w+\s*,\s*)*\w*$`)
+
+// shaderRenderModeCompletions offers the render modes of the shader type
+// that are not already declared.
+func shaderRenderModeCompletions(ast *gdshader.ShaderDocument, version gdshader.Version) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	if ast.ShaderType == nil {
+		return items
+	}
+
+	var declared []string
+	if ast.RenderModes != nil {
+		declared = ast.RenderModes.Modes
+	}
+	kind := protocol.CompletionItemKindEnumMember
+	modes := gdshader.GetRenderModes(version, gdshader.ShaderType(ast.ShaderType.Type))
+	for _, name := range slices.Sorted(maps.Keys(modes)) {
+		if slices.Contains(declared, name) {
+			continue
+		}
+		items = append(items, protocol.CompletionItem{
+			Label:  name,
+			Kind:   &kind,
+			Detail: strPtr(modes[name].Description),
+		})
+	}
+	return items
+}
+
+// shaderMemberCompletions offers the fields of a struct type, or the
+// swizzles of a vector type: each component and each run of components
+// from the first, in all three swizzle sets.
+func shaderMemberCompletions(t *gdshader.Type) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	if t == nil {
+		return items
+	}
+
+	kind := protocol.CompletionItemKindField
+	switch {
+	case t.Kind == gdshader.TypeKindStruct:
+		for _, field := range t.Fields {
+			items = append(items, protocol.CompletionItem{
+				Label:  field.Name,
+				Kind:   &kind,
+				Detail: strPtr(field.Type.String()),
+			})
+		}
+	case t.IsVector():
+		size := t.VectorSize()
+		for _, set := range gdshader.SwizzleSets {
+			var swizzles []string
+			for i := range size {
+				swizzles = append(swizzles, set[i:i+1])
+			}
+			for n := 2; n <= size; n++ {
+				swizzles = append(swizzles, set[:n])
+			}
+			for _, swizzle := range swizzles {
+				result, err := gdshader.ValidateSwizzle(t, swizzle)
+				if err != nil {
+					continue
+				}
+				items = append(items, protocol.CompletionItem{
+					Label:  swizzle,
+					Kind:   &kind,
+					Detail: strPtr(result.String()),
+				})
+			}
+		}
+	}
+	return items
+}
+
+// shaderKeywordCompletions offers the keywords and built-in types.
+func shaderKeywordCompletions() []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	for _, keyword := range gdshader.Keywords() {
+		kind := protocol.CompletionItemKindKeyword
+		if gdshader.LookupIdent(keyword).IsType() {
+			kind = protocol.CompletionItemKindClass
+		}
+		items = append(items, protocol.CompletionItem{
+			Label: keyword,
+			Kind:  &kind,
+		})
+	}
+	return items
+}
+
+// shaderBuiltinFunctionCompletions offers the built-in functions, inserting
+// a placeholder for each parameter of their first signature.
+func shaderBuiltinFunctionCompletions() []protocol.CompletionItem {
+	var items []protocol.CompletionItem
+	kind := protocol.CompletionItemKindFunction
+	snippetFormat := protocol.InsertTextFormatSnippet
+	for _, name := range slices.Sorted(maps.Keys(gdshader.BuiltinFunctions)) {
+		fn := gdshader.BuiltinFunctions[name]
+		item := protocol.CompletionItem{
+			Label:            name,
+			Kind:             &kind,
+			InsertText:       strPtr(name + "($1)"),
+			InsertTextFormat: &snippetFormat,
+		}
+		if len(fn.Signatures) > 0 {
+			sig := fn.Signatures[0]
+			detail := fmt.Sprintf("%s %s(%s)", sig.Return, name, strings.Join(sig.ParamList(), ", "))
+			if fn.Stages != nil {
+				detail += " (" + gdshader.JoinStages(fn.Stages) + " only)"
+			}
+			item.Detail = strPtr(detail)
+			item.InsertText = strPtr(name + "(" + snippetParams(sig.Params) + ")")
+		}
+		if fn.Description != "" {
+			item.Documentation = fn.Description
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// snippetParams returns the ${1:type}, ${2:type} placeholders of a call.
+func snippetParams(params []string) string {
+	placeholders := make([]string, len(params))
+	for i, param := range params {
+		placeholders[i] = fmt.Sprintf("${%d:%s}", i+1, param)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// shaderSymbolCompletions offers the symbols visible at the cursor.
+func shaderSymbolCompletions(doc *analysis.Document, version gdshader.Version, symbols []*gdshader.Symbol) []protocol.CompletionItem {
+	var builtins map[string]*gdshader.BuiltinVariable
+	if doc.ShaderAST.ShaderType != nil {
+		builtins = gdshader.GetBuiltinsForShaderType(version, doc.ShaderAST.ShaderType.Type)
+	}
+
+	var items []protocol.CompletionItem
+	snippetFormat := protocol.InsertTextFormatSnippet
+	for _, sym := range symbols {
+		item := protocol.CompletionItem{Label: sym.Name}
+		var kind protocol.CompletionItemKind
+
+		switch sym.Kind {
+		case gdshader.SymbolFunction:
+			kind = protocol.CompletionItemKindFunction
+			params := make([]string, len(sym.Function.Params))
+			names := make([]string, len(sym.Function.Params))
+			for i, param := range sym.Function.Params {
+				params[i] = param.Type.String() + " " + param.Name
+				names[i] = param.Name
+			}
+			item.Detail = strPtr(fmt.Sprintf("%s %s(%s)", sym.Function.ReturnType, sym.Name, strings.Join(params, ", ")))
+			item.InsertText = strPtr(sym.Name + "(" + snippetParams(names) + ")")
+			item.InsertTextFormat = &snippetFormat
+		case gdshader.SymbolStruct:
+			kind = protocol.CompletionItemKindStruct
+			item.Detail = strPtr("struct")
+		case gdshader.SymbolConstant:
+			kind = protocol.CompletionItemKindConstant
+			item.Detail = strPtr("const " + sym.Type.String())
+			if constant, ok := gdshader.BuiltinConstants[sym.Name]; ok {
+				item.Documentation = constant.Description
+			}
+		case gdshader.SymbolMacro:
+			kind = protocol.CompletionItemKindConstant
+			item.Detail = strPtr("#define")
+		case gdshader.SymbolBuiltinVariable:
+			kind = protocol.CompletionItemKindVariable
+			detail := sym.Type.String()
+			if sym.ReadOnly {
+				detail += " (read-only)"
+			}
+			item.Detail = strPtr(detail)
+			if builtin, ok := builtins[sym.Name]; ok {
+				item.Documentation = builtin.Description
+			}
+		default:
+			kind = protocol.CompletionItemKindVariable
+			detail := sym.Type.String()
+			if len(sym.Qualifiers) > 0 && sym.Qualifiers[0] != "" {
+				detail = sym.Qualifiers[0] + " " + detail
+			}
+			item.Detail = strPtr(detail)
+		}
+
+		item.Kind = &kind
+		items = append(items, item)
+	}
+	return items
+}
+
+// shaderSnippet is a snippet of a whole construct, offered either between
+// declarations or in function bodies.
+type shaderSnippet struct {
+	label      string
+	detail     string
+	body       string
+	inFunction bool
+	builtin    string // Built-in variable the snippet uses, if any
+}
+
+var shaderSnippets = []shaderSnippet{
+	{
+		label:  "uniform hint_range",
+		detail: "Uniform float set in a range",
+		body:   "uniform float ${1:amount} : hint_range(${2:0.0}, ${3:1.0}) = ${4:0.5};",
+	},
+	{
+		label:  "screen texture",
+		detail: "Uniform sampling the screen",
+		body:   "uniform sampler2D ${1:screen_texture} : hint_screen_texture, filter_linear_mipmap;",
+	},
+	{
+		label:  "triplanar",
+		detail: "Function sampling a texture along the three axes",
+		body: "vec4 ${1:triplanar}(sampler2D tex, vec3 position, vec3 normal, float sharpness) {\n" +
+			"\tvec3 weights = pow(abs(normal), vec3(sharpness));\n" +
+			"\tweights /= weights.x + weights.y + weights.z;\n" +
+			"\treturn texture(tex, position.zy) * weights.x\n" +
+			"\t\t+ texture(tex, position.xz) * weights.y\n" +
+			"\t\t+ texture(tex, position.xy) * weights.z;\n" +
+			"}",
+	},
+	{
+		label:      "for loop",
+		detail:     "Loop over a range of integers",
+		body:       "for (int ${1:i} = 0; ${1:i} < ${2:count}; ${1:i}++) {\n\t$0\n}",
+		inFunction: true,
+	},
+	{
+		label:      "screen uv",
+		detail:     "Color of the screen behind the fragment",
+		body:       "vec3 ${1:screen_color} = texture(${2:screen_texture}, SCREEN_UV).rgb;",
+		inFunction: true,
+		builtin:    "SCREEN_UV",
+	},
+}
+
+// shaderSnippetCompletions offers snippets of the constructs that fit at
+// pos: the processor functions the shader does not define yet and uniform
+// and function templates between declarations, loops and sampling patterns
+// in function bodies. Patterns using a built-in are only offered where it
+// is available.
+func shaderSnippetCompletions(ast *gdshader.ShaderDocument, pos gdshader.Position, symbols []*gdshader.Symbol) []protocol.CompletionItem {
+	items := []protocol.CompletionItem{}
+	kind := protocol.CompletionItemKindSnippet
+	snippetFormat := protocol.InsertTextFormatSnippet
+	add := func(label, detail, body string) {
+		items = append(items, protocol.CompletionItem{
+			Label:            label,
+			Kind:             &kind,
+			Detail:           strPtr(detail),
+			InsertText:       strPtr(body),
+			InsertTextFormat: &snippetFormat,
+		})
+	}
+
+	inFunction := ast.FunctionAt(pos) != nil
+	if !inFunction {
+		stages := []string{"vertex", "fragment"}
+		if ast.ShaderType != nil {
+			stages = gdshader.ShaderStages[gdshader.ShaderType(ast.ShaderType.Type)]
+		}
+		for _, stage := range stages {
+			if !slices.ContainsFunc(ast.Functions, func(fn *gdshader.FunctionDecl) bool { return fn.Name == stage }) {
+				add(stage+"()", "Processor function", "void "+stage+"() {\n\t$0\n}")
+			}
+		}
+	}
+
+	for _, snippet := range shaderSnippets {
+		if snippet.inFunction != inFunction {
+			continue
+		}
+		if snippet.builtin != "" && !slices.ContainsFunc(symbols, func(sym *gdshader.Symbol) bool {
+			return sym.Name == snippet.builtin && sym.Kind == gdshader.SymbolBuiltinVariable
+		}) {
+			continue
+		}
+		add(snippet.label, snippet.detail, snippet.body)
+	}
+	return items
+}
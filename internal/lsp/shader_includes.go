@@ -0,0 +1,43 @@
+package lsp
+
+import (
+	"os"
+
+	"github.com/tliron/glsp"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// loadShaderInclude reads the file an #include of the shader at from names:
+// the content of the editor when it is open, otherwise the file on disk.
+func (s *Server) loadShaderInclude(from, path string) (string, string, bool) {
+	location := s.resolveResourcePath(path, from)
+	if location == nil {
+		return "", "", false
+	}
+	if content, ok := s.workspace.DocumentContent(location.URI); ok {
+		return location.URI, content, true
+	}
+	content, err := os.ReadFile(uriToPath(location.URI))
+	if err != nil {
+		return location.URI, "", false
+	}
+	return location.URI, string(content), true
+}
+
+// refreshShaderDependents analyzes the open shaders including the file at
+// uri again once its content changed, and updates their diagnostics.
+func (s *Server) refreshShaderDependents(ctx *glsp.Context, uri string) {
+	if analysis.GetDocumentType(uri) != analysis.DocumentTypeGDShader {
+		return
+	}
+	dependents := s.workspace.ShaderDependents(uri)
+	if len(dependents) == 0 {
+		return
+	}
+	s.workspace.InvalidateShaders(dependents)
+	for _, dependent := range dependents {
+		s.scheduleDiagnostics(ctx, dependent)
+	}
+	s.refreshDiagnostics(ctx)
+}
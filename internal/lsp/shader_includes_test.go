@@ -0,0 +1,108 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestShaderIncludes(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+	write("project.godot", "config_version=5\n")
+	commonURI := write("common.gdshaderinc", "float half_of(float x) { return x * 0.5; }\n")
+	waterURI := pathToURI(filepath.Join(dir, "water.gdshader"))
+
+	s := NewServer("test", "test")
+	s.settings.DiagnosticsDelay = 0
+	var mu sync.Mutex
+	published := make(map[string][]protocol.Diagnostic)
+	ctx := &glsp.Context{Notify: func(method string, params any) {
+		if p, ok := params.(protocol.PublishDiagnosticsParams); ok {
+			mu.Lock()
+			published[p.URI] = p.Diagnostics
+			mu.Unlock()
+		}
+	}}
+	messages := func(uri string) []string {
+		t.Helper()
+		var msgs []string
+		for _, d := range s.computeDiagnostics(s.workspace.GetDocument(uri)) {
+			msgs = append(msgs, d.Message)
+		}
+		return msgs
+	}
+
+	// The included file is read from disk while it is not open
+	if err := s.textDocumentDidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{
+		URI:     waterURI,
+		Version: 1,
+		Text: `shader_type spatial;
+#include "res://common.gdshaderinc"
+void fragment() {
+	ALBEDO = vec3(half_of(scale));
+}
+`,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := messages(waterURI); len(msgs) != 1 || msgs[0] != "undefined symbol 'scale'" {
+		t.Errorf("expected only scale to be undefined, got %q", msgs)
+	}
+
+	// Editing the included file analyzes the shader again
+	mu.Lock()
+	delete(published, waterURI)
+	mu.Unlock()
+	if err := s.textDocumentDidOpen(ctx, &protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{
+		URI:     commonURI,
+		Version: 1,
+		Text:    "uniform float scale;\nfloat half_of(float x) { return x * 0.5; }\n",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := messages(waterURI); len(msgs) != 0 {
+		t.Errorf("expected the new uniform to be seen, got %q", msgs)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		mu.Lock()
+		diagnostics, ok := published[waterURI]
+		mu.Unlock()
+		if ok {
+			if len(diagnostics) != 0 {
+				t.Errorf("expected the shader's diagnostics to be republished empty, got %+v", diagnostics)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the diagnostics of the including shader to be republished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Circular includes are errors
+	if err := s.textDocumentDidChange(ctx, &protocol.DidChangeTextDocumentParams{
+		TextDocument:   protocol.VersionedTextDocumentIdentifier{TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: commonURI}, Version: 2},
+		ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: "#include \"water.gdshader\"\nuniform float scale;\nfloat half_of(float x) { return x; }\n"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	msgs := messages(waterURI)
+	if len(msgs) != 1 || !strings.HasPrefix(msgs[0], "circular include: 'water.gdshader' includes 'common.gdshaderinc' includes 'water.gdshader'") {
+		t.Errorf("expected a circular include, got %q", msgs)
+	}
+}
@@ -0,0 +1,109 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+)
+
+// ShaderUniformsMethod is the request a tool sends to get the uniforms of a
+// shader, e.g. to generate a material inspector or documentation.
+const ShaderUniformsMethod = "gdls/shaderUniforms"
+
+func init() {
+	registerCapability(&capability{
+		name: "shaderUniforms",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[ShaderUniformsMethod] = customRequest(s.shaderUniforms)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["shaderUniforms"] = true
+		},
+	})
+}
+
+// shaderUniformsParams are the parameters of the gdls/shaderUniforms request.
+type shaderUniformsParams struct {
+	TextDocument protocol.TextDocumentIdentifier `json:"textDocument"`
+}
+
+// shaderUniform is a uniform of the gdls/shaderUniforms result. Hint
+// arguments and the default value are given as written in the shader.
+type shaderUniform struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Global      bool           `json:"global,omitempty"`
+	Hints       []shaderHint   `json:"hints,omitempty"`
+	Default     string         `json:"default,omitempty"`
+	Group       string         `json:"group,omitempty"`
+	Subgroup    string         `json:"subgroup,omitempty"`
+	Description string         `json:"description,omitempty"` // From the /** */ comment
+	Range       protocol.Range `json:"range"`                 // Range of the name
+}
+
+// shaderHint is a uniform hint such as hint_range(0.0, 1.0).
+type shaderHint struct {
+	Name string   `json:"name"`
+	Args []string `json:"args,omitempty"`
+}
+
+// shaderUniforms handles the gdls/shaderUniforms request, returning the
+// uniforms of the shader at the requested URI in declaration order, or
+// null when it is not an open shader.
+func (s *Server) shaderUniforms(ctx *glsp.Context, params *shaderUniformsParams) (any, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil || doc.ShaderAST == nil {
+		return nil, nil
+	}
+
+	uniforms := []shaderUniform{}
+	for _, decl := range doc.ShaderAST.Uniforms {
+		if decl.Type == nil || decl.Name == "" {
+			continue // Incomplete declaration being typed
+		}
+		uniform := shaderUniform{
+			Name:        decl.Name,
+			Type:        decl.Type.String(),
+			Global:      decl.IsGlobal,
+			Group:       decl.GroupName,
+			Subgroup:    decl.SubgroupName,
+			Description: decl.DocComment,
+			Range:       toProtocolShaderRange(decl.NameRange),
+		}
+		for _, hint := range decl.Hints {
+			h := shaderHint{Name: hint.Name}
+			for _, arg := range hint.Args {
+				h.Args = append(h.Args, shaderSource(doc.Content, arg.GetRange()))
+			}
+			uniform.Hints = append(uniform.Hints, h)
+		}
+		if decl.DefaultValue != nil {
+			uniform.Default = shaderSource(doc.Content, decl.DefaultValue.GetRange())
+		}
+		uniforms = append(uniforms, uniform)
+	}
+	return uniforms, nil
+}
+
+// shaderSource returns the text of a range of shader source.
+func shaderSource(content string, r gdshader.Range) string {
+	lines := strings.SplitAfter(content, "\n")
+	offset := func(p gdshader.Position) int {
+		if p.Line >= len(lines) {
+			return len(content)
+		}
+		n := 0
+		for _, line := range lines[:p.Line] {
+			n += len(line)
+		}
+		return min(n+p.Column, len(content))
+	}
+	start, end := offset(r.Start), offset(r.End)
+	if start >= end {
+		return ""
+	}
+	return content[start:end]
+}
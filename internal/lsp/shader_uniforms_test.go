@@ -0,0 +1,49 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestShaderUniforms(t *testing.T) {
+	uri := "file:///tmp/water.gdshader"
+	content := `shader_type spatial;
+
+/** Color of shallow water. */
+uniform vec4 albedo : source_color = vec4(0.1, 0.4, 0.8, 1.0);
+
+group_uniforms waves;
+uniform float speed : hint_range(0.0, 2.0, 0.1) = 0.5;
+group_uniforms waves.foam;
+uniform sampler2D foam_texture : source_color, filter_linear;
+group_uniforms;
+
+global uniform float wind;
+`
+	s := NewServer("test", "test")
+	s.workspace.OpenDocument(uri, content)
+
+	result, err := s.shaderUniforms(&glsp.Context{}, &shaderUniformsParams{TextDocument: protocol.TextDocumentIdentifier{URI: uri}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := json.Marshal(result)
+	expected := `[` +
+		`{"name":"albedo","type":"vec4","hints":[{"name":"source_color"}],"default":"vec4(0.1, 0.4, 0.8, 1.0)","description":"Color of shallow water.","range":{"start":{"line":3,"character":13},"end":{"line":3,"character":19}}},` +
+		`{"name":"speed","type":"float","hints":[{"name":"hint_range","args":["0.0","2.0","0.1"]}],"default":"0.5","group":"waves","range":{"start":{"line":6,"character":14},"end":{"line":6,"character":19}}},` +
+		`{"name":"foam_texture","type":"sampler2D","hints":[{"name":"source_color"},{"name":"filter_linear"}],"group":"waves","subgroup":"foam","range":{"start":{"line":8,"character":18},"end":{"line":8,"character":30}}},` +
+		`{"name":"wind","type":"float","global":true,"range":{"start":{"line":11,"character":21},"end":{"line":11,"character":25}}}]`
+	if string(got) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// Scenes have no uniforms
+	s.workspace.OpenDocument("file:///tmp/level.tscn", "[gd_scene format=3]\n")
+	if result, _ := s.shaderUniforms(&glsp.Context{}, &shaderUniformsParams{TextDocument: protocol.TextDocumentIdentifier{URI: "file:///tmp/level.tscn"}}); result != nil {
+		t.Errorf("expected no result, got %v", result)
+	}
+}
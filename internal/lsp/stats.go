@@ -0,0 +1,166 @@
+package lsp
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// StatsMethod is the request an extension sends to get the counters of the
+// server, e.g. to attach them to a report of slowness.
+const StatsMethod = "gdls/stats"
+
+func init() {
+	registerCapability(&capability{
+		name: "stats",
+		register: func(s *Server, h *protocol.Handler) {
+			s.custom[StatsMethod] = customRequest(s.stats)
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			experimentalCapabilities(caps)["stats"] = true
+		},
+	})
+}
+
+// serverStats counts the messages handled by a session and the use of its
+// caches.
+type serverStats struct {
+	started time.Time
+
+	mu      sync.Mutex
+	methods map[string]*methodStats
+
+	reportHits   atomic.Int64 // Diagnostics of closed files served from the cache
+	reportMisses atomic.Int64
+}
+
+// methodStats are the counters of one method.
+type methodStats struct {
+	Count     int64   `json:"count"`
+	Errors    int64   `json:"errors"`
+	TotalMs   float64 `json:"totalMs"`
+	MaxMs     float64 `json:"maxMs"`
+	AverageMs float64 `json:"averageMs"`
+}
+
+// newServerStats creates the counters of a session starting now.
+func newServerStats() *serverStats {
+	return &serverStats{
+		started: time.Now(),
+		methods: make(map[string]*methodStats),
+	}
+}
+
+// record counts a handled message.
+func (st *serverStats) record(method string, elapsed time.Duration, failed bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	m, ok := st.methods[method]
+	if !ok {
+		m = &methodStats{}
+		st.methods[method] = m
+	}
+	ms := float64(elapsed) / float64(time.Millisecond)
+	m.Count++
+	m.TotalMs += ms
+	m.MaxMs = max(m.MaxMs, ms)
+	if failed {
+		m.Errors++
+	}
+}
+
+// snapshot returns a copy of the method counters with their averages.
+func (st *serverStats) snapshot() map[string]methodStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	methods := make(map[string]methodStats, len(st.methods))
+	for method, m := range st.methods {
+		copied := *m
+		copied.AverageMs = copied.TotalMs / float64(copied.Count)
+		methods[method] = copied
+	}
+	return methods
+}
+
+// statsResult is the result of the gdls/stats request.
+type statsResult struct {
+	UptimeSeconds float64                `json:"uptimeSeconds"`
+	Documents     documentStats          `json:"documents"`
+	Parses        parseStats             `json:"parses"`
+	Caches        map[string]cacheStats  `json:"caches"`
+	Memory        memoryStats            `json:"memory"`
+	Requests      map[string]methodStats `json:"requests"` // Requests and notifications by method
+}
+
+// documentStats counts the documents the server knows about.
+type documentStats struct {
+	Open    int `json:"open"`
+	Indexed int `json:"indexed"` // Files found by scanning the workspace folders
+}
+
+// parseStats counts the parses of documents.
+type parseStats struct {
+	Count   int64   `json:"count"`
+	TotalMs float64 `json:"totalMs"`
+}
+
+// cacheStats counts the lookups of a cache.
+type cacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"` // From 0 to 1, 0 before any lookup
+}
+
+// memoryStats is the memory use of the process.
+type memoryStats struct {
+	HeapAllocBytes uint64 `json:"heapAllocBytes"`
+	SysBytes       uint64 `json:"sysBytes"` // Obtained from the OS
+	NumGC          uint32 `json:"numGC"`
+	Goroutines     int    `json:"goroutines"`
+}
+
+// newCacheStats returns the counters of a cache with its hit rate.
+func newCacheStats(hits, misses int64) cacheStats {
+	stats := cacheStats{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		stats.HitRate = float64(hits) / float64(total)
+	}
+	return stats
+}
+
+// stats handles the gdls/stats request, returning the counters of the
+// session: documents, parses, cache hit rates, memory use and the number
+// and duration of the messages handled by method.
+func (s *Server) stats(ctx *glsp.Context, params *struct{}) (any, error) {
+	ws := s.workspace.Stats()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	return statsResult{
+		UptimeSeconds: time.Since(s.counters.started).Seconds(),
+		Documents:     documentStats{Open: ws.OpenDocuments, Indexed: ws.IndexedFiles},
+		Parses: parseStats{
+			Count:   ws.Parses,
+			TotalMs: float64(ws.ParseTime) / float64(time.Millisecond),
+		},
+		Caches: map[string]cacheStats{
+			"documents":       newCacheStats(ws.LazyHits, ws.LazyMisses),
+			"parses":          newCacheStats(ws.ParseHits, ws.ParseMisses),
+			"fileDiagnostics": newCacheStats(s.counters.reportHits.Load(), s.counters.reportMisses.Load()),
+		},
+		Memory: memoryStats{
+			HeapAllocBytes: mem.HeapAlloc,
+			SysBytes:       mem.Sys,
+			NumGC:          mem.NumGC,
+			Goroutines:     runtime.NumGoroutine(),
+		},
+		Requests: s.counters.snapshot(),
+	}, nil
+}
@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestStatsAndTrace(t *testing.T) {
+	s := NewServer("test", "test")
+
+	// Record the $/logTrace notifications the client gets
+	var mu sync.Mutex
+	var traces []protocol.LogTraceParams
+	clientSide, serverSide := net.Pipe()
+	jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(serverSide, jsonrpc2.VSCodeObjectCodec{}), newRPCHandler(s))
+	client := jsonrpc2.NewConn(context.Background(), jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(
+		func(_ context.Context, _ *jsonrpc2.Conn, req *jsonrpc2.Request) (any, error) {
+			if req.Method == string(protocol.MethodLogTrace) {
+				var params protocol.LogTraceParams
+				_ = json.Unmarshal(*req.Params, &params)
+				mu.Lock()
+				traces = append(traces, params)
+				mu.Unlock()
+			}
+			return nil, nil
+		},
+	))
+	t.Cleanup(func() { client.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Call(ctx, "initialize", protocol.InitializeParams{}, nil); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := client.Notify(ctx, "$/setTrace", protocol.SetTraceParams{Value: protocol.TraceValueVerbose}); err != nil {
+		t.Fatalf("setTrace failed: %v", err)
+	}
+	uri := "file:///tmp/stats.tscn"
+	if err := client.Notify(ctx, "textDocument/didOpen", protocol.DidOpenTextDocumentParams{
+		TextDocument: protocol.TextDocumentItem{URI: uri, Version: 1, Text: "[gd_scene format=3]\n"},
+	}); err != nil {
+		t.Fatalf("didOpen failed: %v", err)
+	}
+	var symbols any
+	if err := client.Call(ctx, "textDocument/documentSymbol", protocol.DocumentSymbolParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	}, &symbols); err != nil {
+		t.Fatalf("documentSymbol failed: %v", err)
+	}
+
+	var stats statsResult
+	if err := client.Call(ctx, StatsMethod, struct{}{}, &stats); err != nil {
+		t.Fatalf("%s failed: %v", StatsMethod, err)
+	}
+	if stats.Documents.Open != 1 || stats.Parses.Count != 1 {
+		t.Errorf("expected 1 open document parsed once, got %+v and %+v", stats.Documents, stats.Parses)
+	}
+	if got := stats.Requests["textDocument/documentSymbol"]; got.Count != 1 || got.Errors != 0 {
+		t.Errorf("expected 1 documentSymbol request, got %+v", got)
+	}
+	if got := stats.Caches["documents"]; got.Hits != 1 || got.HitRate != 1 {
+		t.Errorf("expected the parsed document to be reused, got %+v", got)
+	}
+	if stats.Memory.HeapAllocBytes == 0 || stats.Memory.Goroutines == 0 {
+		t.Errorf("expected memory statistics, got %+v", stats.Memory)
+	}
+
+	// The trace notification of a request is sent before its reply
+	mu.Lock()
+	defer mu.Unlock()
+	var found bool
+	for _, trace := range traces {
+		if strings.HasPrefix(trace.Message, "Handled request 'textDocument/documentSymbol - (") {
+			found = trace.Verbose != nil && strings.Contains(*trace.Verbose, uri)
+		}
+	}
+	if !found {
+		t.Errorf("expected a verbose trace of the documentSymbol request, got %+v", traces)
+	}
+}
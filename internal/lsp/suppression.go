@@ -0,0 +1,113 @@
+package lsp
+
+import (
+	"regexp"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// Diagnostics are suppressed with a gdls-ignore comment, optionally
+// followed by the codes to ignore, e.g. "; gdls-ignore: unknown-property"
+// in scenes and resources or "// gdls-ignore" in shaders. A comment at the
+// end of a line applies to that line, and a comment on a line of its own to
+// the next one.
+
+// suppressionPattern matches a gdls-ignore comment, capturing its codes.
+var suppressionPattern = regexp.MustCompile(`^\s*gdls-ignore\b(?:\s*:\s*(.*))?`)
+
+// suppression is what a gdls-ignore comment ignores on a line: every
+// diagnostic when codes is empty.
+type suppression struct {
+	codes map[string]bool
+}
+
+// ignores reports whether the suppression applies to a diagnostic.
+func (s suppression) ignores(d protocol.Diagnostic) bool {
+	if len(s.codes) == 0 {
+		return true
+	}
+	if d.Code == nil {
+		return false
+	}
+	code, _ := d.Code.Value.(string)
+	return s.codes[code]
+}
+
+// suppressDiagnostics drops the diagnostics a gdls-ignore comment applies to.
+func suppressDiagnostics(doc *analysis.Document, diagnostics []protocol.Diagnostic) []protocol.Diagnostic {
+	suppressions := documentSuppressions(doc)
+	if len(suppressions) == 0 {
+		return diagnostics
+	}
+
+	kept := diagnostics[:0]
+	for _, d := range diagnostics {
+		if s, ok := suppressions[int(d.Range.Start.Line)]; ok && s.ignores(d) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// documentSuppressions returns the suppressions of a document by the line
+// they apply to.
+func documentSuppressions(doc *analysis.Document) map[int]suppression {
+	marker := ";"
+	if doc.Type == analysis.DocumentTypeGDShader {
+		marker = "//"
+	}
+	if !strings.Contains(doc.Content, "gdls-ignore") {
+		return nil
+	}
+
+	suppressions := make(map[int]suppression)
+	for i, line := range strings.Split(doc.Content, "\n") {
+		start := commentStart(line, marker)
+		if start < 0 {
+			continue
+		}
+		m := suppressionPattern.FindStringSubmatch(line[start+len(marker):])
+		if m == nil {
+			continue
+		}
+		target := i
+		if strings.TrimSpace(line[:start]) == "" {
+			target = i + 1
+		}
+		s, ok := suppressions[target]
+		if !ok {
+			s = suppression{codes: make(map[string]bool)}
+		}
+		codes := strings.FieldsFunc(m[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' })
+		if len(codes) == 0 || (ok && len(s.codes) == 0) {
+			suppressions[target] = suppression{} // Ignores everything
+			continue
+		}
+		for _, code := range codes {
+			s.codes[code] = true
+		}
+		suppressions[target] = s
+	}
+	return suppressions
+}
+
+// commentStart returns the offset of the comment marker in a line, outside
+// of strings, or -1 when the line has no comment.
+func commentStart(line, marker string) int {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inString && line[i] == '\\':
+			i++ // Skip the escaped character
+		case line[i] == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(line[i:], marker):
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,59 @@
+package lsp
+
+import "testing"
+
+func TestSuppressionComments(t *testing.T) {
+	s := NewServer("test", "test")
+
+	tests := []struct {
+		name, uri, content string
+		expected           []string // Messages kept
+	}{
+		{
+			name: "scene",
+			uri:  "file:///tmp/suppressed.tscn",
+			content: `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+; gdls-ignore: unknown-property
+positon = Vector2(1, 2)
+rotaton = 1.0 ; gdls-ignore: missing-parent, unknown-property
+scael = 2.0 ; gdls-ignore: missing-parent
+text = "; gdls-ignore"
+
+; gdls-ignore
+[node name="Child" type="Node2D" parent="Missing"]
+`,
+			expected: []string{
+				"Unknown property scael on Node2D",
+				"Unknown property text on Node2D",
+			},
+		},
+		{
+			name: "shader",
+			uri:  "file:///tmp/suppressed.gdshader",
+			content: `shader_type spatial;
+
+void fragment() {
+	// gdls-ignore: undefined-symbol
+	float a = missing;
+	float b = other; // gdls-ignore
+	float c = unknown; // gdls-ignore: unreachable-code
+}
+`,
+			expected: []string{"undefined symbol 'unknown'"},
+		},
+	}
+	for _, tt := range tests {
+		diagnostics := s.Check(tt.uri, tt.content)
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, diagnostics)
+			continue
+		}
+		for i, d := range diagnostics {
+			if d.Message != tt.expected[i] {
+				t.Errorf("%s: expected %q, got %q", tt.name, tt.expected[i], d.Message)
+			}
+		}
+	}
+}
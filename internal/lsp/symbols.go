@@ -1,13 +1,28 @@
 package lsp
 
 import (
+	"strings"
+
 	"github.com/tliron/glsp"
 	protocol "github.com/tliron/glsp/protocol_3_16"
 
 	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/gdshader"
 	"github.com/andresperezl/gdls/internal/parser"
 )
 
+func init() {
+	registerCapability(&capability{
+		name: "documentSymbol",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentDocumentSymbol = s.textDocumentDocumentSymbol
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.DocumentSymbolProvider = &protocol.DocumentSymbolOptions{}
+		},
+	})
+}
+
 // textDocumentDocumentSymbol handles the textDocument/documentSymbol request.
 func (s *Server) textDocumentDocumentSymbol(ctx *glsp.Context, params *protocol.DocumentSymbolParams) (any, error) {
 	doc := s.workspace.GetDocument(params.TextDocument.URI)
@@ -15,14 +30,21 @@ func (s *Server) textDocumentDocumentSymbol(ctx *glsp.Context, params *protocol.
 		return nil, nil
 	}
 
-	switch doc.Type {
-	case analysis.DocumentTypeTSCN:
-		return s.tscnDocumentSymbols(doc)
-	case analysis.DocumentTypeGDShader:
-		return s.gdshaderDocumentSymbols(doc)
-	default:
-		return nil, nil
-	}
+	// Symbols only depend on the document, so they are computed once per version
+	return doc.Derived("documentSymbol", func() (any, error) {
+		switch doc.Type {
+		case analysis.DocumentTypeTSCN:
+			return s.tscnDocumentSymbols(doc)
+		case analysis.DocumentTypeGDShader:
+			return s.gdshaderDocumentSymbols(doc)
+		case analysis.DocumentTypeConfig:
+			return s.configDocumentSymbols(doc)
+		case analysis.DocumentTypeGLSL:
+			return s.glslDocumentSymbols(doc)
+		default:
+			return nil, nil
+		}
+	})
 }
 
 // tscnDocumentSymbols returns document symbols for a TSCN document.
@@ -157,105 +179,51 @@ func (s *Server) tscnDocumentSymbols(doc *analysis.Document) (any, error) {
 	return symbols, nil
 }
 
-// buildNodeTree builds a hierarchical tree of node symbols.
+// buildNodeTree builds a hierarchical tree of node symbols, nested the way
+// the Scene dock of the Godot editor shows them. Nodes whose parent is not
+// in the scene follow the root, so that they still appear in the outline.
 func (s *Server) buildNodeTree(nodes []*parser.Node) []protocol.DocumentSymbol {
 	if len(nodes) == 0 {
 		return nil
 	}
-
-	// Build a map of node paths to their children
-	type nodeInfo struct {
-		node     *parser.Node
-		children []*parser.Node
-	}
-
-	pathToNode := make(map[string]*nodeInfo)
-	var rootNode *parser.Node
-
-	// First pass: create nodeInfo for each node
-	for _, node := range nodes {
-		var path string
-		switch node.Parent {
-		case "":
-			path = ""
-			rootNode = node
-		case ".":
-			path = node.Name
-		default:
-			path = node.Parent + "/" + node.Name
-		}
-		pathToNode[path] = &nodeInfo{node: node, children: []*parser.Node{}}
-	}
-
-	// Second pass: link children to parents
-	for _, node := range nodes {
-		if node.Parent == "" {
-			continue // Root has no parent
-		}
-		parentPath := node.Parent
-		if parentPath == "." {
-			parentPath = ""
-		}
-		if parent, ok := pathToNode[parentPath]; ok {
-			parent.children = append(parent.children, node)
-		}
+	tree := analysis.NewSceneTree(&parser.Document{Nodes: nodes})
+	if tree.Root == nil {
+		return nil
 	}
 
-	// Build symbols recursively
-	var buildSymbol func(node *parser.Node, path string) protocol.DocumentSymbol
-	buildSymbol = func(node *parser.Node, path string) protocol.DocumentSymbol {
+	var buildSymbol func(sn *analysis.SceneNode) protocol.DocumentSymbol
+	buildSymbol = func(sn *analysis.SceneNode) protocol.DocumentSymbol {
+		node := sn.Node
 		detail := node.Type
-		if detail == "" {
+		switch {
+		case detail != "":
+		case node.InstancePlaceholder != "":
+			detail = "(instance placeholder)"
+		default:
 			detail = "(instance)"
 		}
 
+		nodeRange := toProtocolRange(node.Range)
 		sym := protocol.DocumentSymbol{
-			Name:   node.Name,
-			Detail: strPtr(detail),
-			Kind:   getNodeSymbolKind(node.Type),
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(node.Range.Start.Line),
-					Character: uint32(node.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(node.Range.End.Line),
-					Character: uint32(node.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(node.Range.Start.Line),
-					Character: uint32(node.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(node.Range.End.Line),
-					Character: uint32(node.Range.End.Column),
-				},
-			},
+			Name:           node.Name,
+			Detail:         strPtr(detail),
+			Kind:           getNodeSymbolKind(node.Type),
+			Range:          nodeRange,
+			SelectionRange: nodeRange,
 		}
-
-		// Add children
-		if info, ok := pathToNode[path]; ok {
-			for _, child := range info.children {
-				var childPath string
-				if path == "" {
-					childPath = child.Name
-				} else {
-					childPath = path + "/" + child.Name
-				}
-				sym.Children = append(sym.Children, buildSymbol(child, childPath))
-			}
+		for _, child := range sn.Children {
+			sym.Children = append(sym.Children, buildSymbol(child))
 		}
-
 		return sym
 	}
 
-	if rootNode == nil {
-		return nil
+	symbols := []protocol.DocumentSymbol{buildSymbol(tree.Root)}
+	for _, sn := range tree.Nodes {
+		if sn.Parent == nil && sn != tree.Root {
+			symbols = append(symbols, buildSymbol(sn))
+		}
 	}
-
-	return []protocol.DocumentSymbol{buildSymbol(rootNode, "")}
+	return symbols
 }
 
 // getNodeSymbolKind returns the appropriate symbol kind for a node type.
@@ -282,7 +250,11 @@ func getNodeSymbolKind(nodeType string) protocol.SymbolKind {
 	}
 }
 
-// gdshaderDocumentSymbols returns document symbols for a GDShader document.
+// gdshaderDocumentSymbols returns document symbols for a GDShader document:
+// its uniforms, varyings, constants, structs with their members, and
+// functions. Each symbol selects the name of its declaration, and its detail
+// describes it the way it is declared. Declarations still being typed, with
+// no name yet, are left out.
 func (s *Server) gdshaderDocumentSymbols(doc *analysis.Document) (any, error) {
 	if doc.ShaderAST == nil {
 		return nil, nil
@@ -291,183 +263,107 @@ func (s *Server) gdshaderDocumentSymbols(doc *analysis.Document) (any, error) {
 	symbols := []protocol.DocumentSymbol{}
 	ast := doc.ShaderAST
 
-	// Add uniforms
+	// Add uniforms, with their hints
 	for _, uniform := range ast.Uniforms {
-		symbols = append(symbols, protocol.DocumentSymbol{
-			Name:   uniform.Name,
-			Detail: strPtr("uniform " + uniform.Type.Name),
-			Kind:   protocol.SymbolKindVariable,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(uniform.Range.Start.Line),
-					Character: uint32(uniform.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(uniform.Range.End.Line),
-					Character: uint32(uniform.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(uniform.Range.Start.Line),
-					Character: uint32(uniform.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(uniform.Range.End.Line),
-					Character: uint32(uniform.Range.End.Column),
-				},
-			},
-		})
+		if uniform.Name == "" {
+			continue
+		}
+		detail := "uniform " + shaderTypeString(uniform.Type)
+		if uniform.IsGlobal {
+			detail = "global " + detail
+		}
+		if len(uniform.Hints) > 0 {
+			hints := make([]string, len(uniform.Hints))
+			for i, hint := range uniform.Hints {
+				hints[i] = shaderSource(doc.Content, hint.Range)
+			}
+			detail += " : " + strings.Join(hints, ", ")
+		}
+		symbols = append(symbols, shaderDocumentSymbol(uniform.Name, detail, protocol.SymbolKindProperty, uniform.DeclRange, uniform.NameRange))
 	}
 
 	// Add varyings
 	for _, varying := range ast.Varyings {
-		symbols = append(symbols, protocol.DocumentSymbol{
-			Name:   varying.Name,
-			Detail: strPtr("varying " + varying.Type.Name),
-			Kind:   protocol.SymbolKindVariable,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(varying.Range.Start.Line),
-					Character: uint32(varying.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(varying.Range.End.Line),
-					Character: uint32(varying.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(varying.Range.Start.Line),
-					Character: uint32(varying.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(varying.Range.End.Line),
-					Character: uint32(varying.Range.End.Column),
-				},
-			},
-		})
+		if varying.Name == "" {
+			continue
+		}
+		detail := "varying " + shaderTypeString(varying.Type)
+		if varying.Interpolation != "" {
+			detail = "varying " + varying.Interpolation + " " + shaderTypeString(varying.Type)
+		}
+		symbols = append(symbols, shaderDocumentSymbol(varying.Name, detail, protocol.SymbolKindVariable, varying.DeclRange, varying.NameRange))
 	}
 
 	// Add constants
 	for _, constant := range ast.Constants {
-		symbols = append(symbols, protocol.DocumentSymbol{
-			Name:   constant.Name,
-			Detail: strPtr("const " + constant.Type.Name),
-			Kind:   protocol.SymbolKindConstant,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(constant.Range.Start.Line),
-					Character: uint32(constant.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(constant.Range.End.Line),
-					Character: uint32(constant.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(constant.Range.Start.Line),
-					Character: uint32(constant.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(constant.Range.End.Line),
-					Character: uint32(constant.Range.End.Column),
-				},
-			},
-		})
+		if constant.Name == "" {
+			continue
+		}
+		symbols = append(symbols, shaderDocumentSymbol(constant.Name, "const "+shaderTypeString(constant.Type), protocol.SymbolKindConstant, constant.DeclRange, constant.NameRange))
 	}
 
-	// Add structs
+	// Add structs, with their members as children
 	for _, st := range ast.Structs {
-		structSym := protocol.DocumentSymbol{
-			Name:   st.Name,
-			Detail: strPtr("struct"),
-			Kind:   protocol.SymbolKindStruct,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(st.Range.Start.Line),
-					Character: uint32(st.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(st.Range.End.Line),
-					Character: uint32(st.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(st.Range.Start.Line),
-					Character: uint32(st.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(st.Range.End.Line),
-					Character: uint32(st.Range.End.Column),
-				},
-			},
+		if st.Name == "" {
+			continue
 		}
-
-		// Add struct members as children
+		structSym := shaderDocumentSymbol(st.Name, "struct", protocol.SymbolKindStruct, st.DeclRange, st.NameRange)
 		for _, member := range st.Members {
-			structSym.Children = append(structSym.Children, protocol.DocumentSymbol{
-				Name:   member.Name,
-				Detail: strPtr(member.Type.Name),
-				Kind:   protocol.SymbolKindField,
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(member.Range.Start.Line),
-						Character: uint32(member.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(member.Range.End.Line),
-						Character: uint32(member.Range.End.Column),
-					},
-				},
-				SelectionRange: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(member.Range.Start.Line),
-						Character: uint32(member.Range.Start.Column),
-					},
-					End: protocol.Position{
-						Line:      uint32(member.Range.End.Line),
-						Character: uint32(member.Range.End.Column),
-					},
-				},
-			})
+			if member.Name == "" {
+				continue
+			}
+			structSym.Children = append(structSym.Children,
+				shaderDocumentSymbol(member.Name, shaderTypeString(member.Type), protocol.SymbolKindField, member.DeclRange, member.NameRange))
 		}
-
 		symbols = append(symbols, structSym)
 	}
 
-	// Add functions
+	// Add functions, with their signatures
 	for _, fn := range ast.Functions {
-		funcSym := protocol.DocumentSymbol{
-			Name:   fn.Name,
-			Detail: strPtr(fn.ReturnType.Name),
-			Kind:   protocol.SymbolKindFunction,
-			Range: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(fn.Range.Start.Line),
-					Character: uint32(fn.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(fn.Range.End.Line),
-					Character: uint32(fn.Range.End.Column),
-				},
-			},
-			SelectionRange: protocol.Range{
-				Start: protocol.Position{
-					Line:      uint32(fn.Range.Start.Line),
-					Character: uint32(fn.Range.Start.Column),
-				},
-				End: protocol.Position{
-					Line:      uint32(fn.Range.End.Line),
-					Character: uint32(fn.Range.End.Column),
-				},
-			},
+		if fn.Name == "" {
+			continue
 		}
-
-		symbols = append(symbols, funcSym)
+		params := make([]string, len(fn.Params))
+		for i, param := range fn.Params {
+			params[i] = strings.TrimSpace(shaderTypeString(param.Type) + " " + param.Name)
+			if param.Qualifier != "" {
+				params[i] = param.Qualifier + " " + params[i]
+			}
+		}
+		detail := shaderTypeString(fn.ReturnType) + " (" + strings.Join(params, ", ") + ")"
+		symbols = append(symbols, shaderDocumentSymbol(fn.Name, detail, protocol.SymbolKindFunction, fn.DeclRange, fn.NameRange))
 	}
 
 	return symbols, nil
 }
+
+// shaderDocumentSymbol returns the symbol of a shader declaration spanning
+// rng, selecting its name. A declaration whose name has no range, or a
+// range outside the declaration, selects the whole declaration.
+func shaderDocumentSymbol(name, detail string, kind protocol.SymbolKind, rng, nameRange gdshader.Range) protocol.DocumentSymbol {
+	if nameRange == (gdshader.Range{}) || !containsShaderRange(rng, nameRange) {
+		nameRange = rng
+	}
+	return protocol.DocumentSymbol{
+		Name:           name,
+		Detail:         strPtr(detail),
+		Kind:           kind,
+		Range:          toProtocolShaderRange(rng),
+		SelectionRange: toProtocolShaderRange(nameRange),
+	}
+}
+
+// containsShaderRange reports whether inner is within outer.
+func containsShaderRange(outer, inner gdshader.Range) bool {
+	return isInGDShaderRange(outer, inner.Start.Line, inner.Start.Column) &&
+		isInGDShaderRange(outer, inner.End.Line, inner.End.Column)
+}
+
+// shaderTypeString returns a type as written, or "" for a declaration whose
+// type is still being typed.
+func shaderTypeString(t *gdshader.TypeSpec) string {
+	if t == nil {
+		return ""
+	}
+	return t.String()
+}
@@ -0,0 +1,143 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func TestBuildNodeTree(t *testing.T) {
+	// Children declared before their parents, deep and "./" parent paths, an
+	// instance placeholder and a node whose parent does not exist
+	content := `[gd_scene format=3]
+
+[ext_resource type="PackedScene" path="res://gem.tscn" id="1_gem"]
+
+[node name="Robot" type="Node3D"]
+
+[node name="Finger" type="Node3D" parent="Arm/Hand"]
+
+[node name="Hand" type="Node3D" parent="./Arm"]
+
+[node name="Arm" type="Node3D" parent="."]
+
+[node name="Tip" type="Marker3D" parent="Arm/Hand/Finger"]
+
+[node name="Gem" parent="Arm/Hand" instance_placeholder="res://gem.tscn"]
+
+[node name="Lost" type="Node" parent="Leg"]
+`
+	doc := parser.Parse(content)
+	s := NewServer("test", "test")
+	symbols := s.buildNodeTree(doc.Nodes)
+
+	var render func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder)
+	render = func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder) {
+		for _, sym := range syms {
+			b.WriteString(strings.Repeat("  ", depth) + sym.Name + " " + *sym.Detail + "\n")
+			render(sym.Children, depth+1, b)
+		}
+	}
+	var b strings.Builder
+	render(symbols, 0, &b)
+
+	expected := `Robot Node3D
+  Arm Node3D
+    Hand Node3D
+      Finger Node3D
+        Tip Marker3D
+      Gem (instance placeholder)
+Lost Node
+`
+	if b.String() != expected {
+		t.Errorf("expected tree:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestGDShaderDocumentSymbols(t *testing.T) {
+	content := `shader_type spatial;
+
+uniform vec4 tint : source_color = vec4(1.0);
+uniform float strength : hint_range(0.0, 1.0, 0.1) = 0.5;
+global uniform sampler2D noise;
+varying flat vec3 world_normal;
+const int STEPS[2] = {4, 8};
+
+struct Ripple {
+	float height;
+	vec2 direction;
+};
+
+float wave(in vec2 uv, float t) {
+	return sin(uv.x + t);
+}
+
+void fragment() {
+	ALBEDO = tint.rgb;
+}
+`
+	s := NewServer("test", "test")
+	uri := "file:///tmp/symbols.gdshader"
+	doc := s.workspace.OpenDocument(uri, content)
+	result, err := s.gdshaderDocumentSymbols(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := result.([]protocol.DocumentSymbol)
+
+	var render func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder)
+	render = func(syms []protocol.DocumentSymbol, depth int, b *strings.Builder) {
+		for _, sym := range syms {
+			fmt.Fprintf(b, "%s%s (%d) %s\n", strings.Repeat("  ", depth), sym.Name, sym.Kind, *sym.Detail)
+			render(sym.Children, depth+1, b)
+		}
+	}
+	var b strings.Builder
+	render(symbols, 0, &b)
+
+	expected := fmt.Sprintf(`tint (%[1]d) uniform vec4 : source_color
+strength (%[1]d) uniform float : hint_range(0.0, 1.0, 0.1)
+noise (%[1]d) global uniform sampler2D
+world_normal (%[2]d) varying flat vec3
+STEPS (%[3]d) const int[2]
+Ripple (%[4]d) struct
+  height (%[5]d) float
+  direction (%[5]d) vec2
+wave (%[6]d) float (in vec2 uv, float t)
+fragment (%[6]d) void ()
+`, protocol.SymbolKindProperty, protocol.SymbolKindVariable, protocol.SymbolKindConstant,
+		protocol.SymbolKindStruct, protocol.SymbolKindField, protocol.SymbolKindFunction)
+	if b.String() != expected {
+		t.Errorf("expected symbols:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	// Names are selected, within the whole declarations
+	lines := strings.Split(content, "\n")
+	var check func(syms []protocol.DocumentSymbol)
+	check = func(syms []protocol.DocumentSymbol) {
+		for _, sym := range syms {
+			sel := sym.SelectionRange
+			line := lines[sel.Start.Line]
+			if sel.Start.Line != sel.End.Line || line[sel.Start.Character:sel.End.Character] != sym.Name {
+				t.Errorf("%s: selection range %+v does not select the name", sym.Name, sel)
+			}
+			// Every declaration starts its line, global uniforms at "global"
+			if indent := len(line) - len(strings.TrimLeft(line, "\t")); sym.Range.Start != (protocol.Position{Line: sel.Start.Line, Character: uint32(indent)}) {
+				t.Errorf("%s: range %+v does not start the declaration", sym.Name, sym.Range)
+			}
+			if !positionInRange(sel.Start, sym.Range) || !positionInRange(sel.End, sym.Range) {
+				t.Errorf("%s: range %+v does not contain the selection range %+v", sym.Name, sym.Range, sel)
+			}
+			end := sym.Range.End
+			if last := lines[end.Line]; end.Character == 0 || !strings.ContainsAny(last[end.Character-1:end.Character], ";}") {
+				t.Errorf("%s: range %+v does not end the declaration", sym.Name, sym.Range)
+			}
+			check(sym.Children)
+		}
+	}
+	check(symbols)
+}
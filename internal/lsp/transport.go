@@ -0,0 +1,199 @@
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	wsjsonrpc2 "github.com/sourcegraph/jsonrpc2/websocket"
+	"github.com/tliron/commonlog"
+)
+
+// serve runs a JSON-RPC connection over the given stream until the client
+// disconnects or ctx is cancelled.
+func (s *Server) serve(ctx context.Context, stream jsonrpc2.ObjectStream) {
+	conn := jsonrpc2.NewConn(ctx, stream, newRPCHandler(s))
+	select {
+	case <-conn.DisconnectNotify():
+	case <-ctx.Done():
+		conn.Close()
+		<-conn.DisconnectNotify()
+	}
+}
+
+// ServeConn serves a single client over conn, e.g. one end of a net.Pipe
+// in benchmarks, until it disconnects or ctx is cancelled.
+func (s *Server) ServeConn(ctx context.Context, conn io.ReadWriteCloser) {
+	s.serve(ctx, jsonrpc2.NewBufferedStream(conn, lspCodec{}))
+}
+
+// ListenAndServe listens on a tcp:// or ws:// address and serves every client
+// with its own Server created by newServer, so concurrent editors do not
+// share open documents. It returns after ctx is cancelled and all
+// connections have been closed.
+//
+// Clients are not authenticated. WebSocket connections are only accepted
+// from pages of loopback origins, and of the origins in allowOrigins, so
+// that the sites a browser visits cannot drive the server.
+func ListenAndServe(ctx context.Context, address string, allowOrigins []string, newServer func() *Server) error {
+	u, err := url.Parse(address)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid listen address %q: missing host:port", address)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		return listenTCP(ctx, u.Host, newServer)
+	case "ws":
+		return listenWebSocket(ctx, u.Host, u.Path, allowOrigins, newServer)
+	default:
+		return fmt.Errorf("unsupported listen scheme %q (expected tcp or ws)", u.Scheme)
+	}
+}
+
+// listenTCP serves LSP clients connecting over raw TCP.
+func listenTCP(ctx context.Context, host string, newServer func() *Server) error {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+	return serveTCP(ctx, listener, newServer)
+}
+
+// serveTCP accepts connections from listener until ctx is cancelled.
+func serveTCP(ctx context.Context, listener net.Listener, newServer func() *Server) error {
+	log := commonlog.GetLogger("gdls.transport")
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	log.Infof("listening for TCP connections on %s", listener.Addr())
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for id := 1; ; id++ {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		log.Infof("accepted TCP connection #%d from %s", id, conn.RemoteAddr())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			newServer().serve(ctx, jsonrpc2.NewBufferedStream(conn, lspCodec{}))
+			log.Infof("TCP connection #%d closed", id)
+		}()
+	}
+}
+
+// allowedOrigin reports whether a WebSocket connection may be accepted
+// from the page whose Origin header r carries: none, as other clients than
+// browsers send, a page served from a loopback address, or one of the
+// origins in allow.
+func allowedOrigin(r *http.Request, allow []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(allow, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenWebSocket serves LSP clients connecting over WebSocket from the
+// origins allowedOrigin accepts.
+func listenWebSocket(ctx context.Context, host, path string, allowOrigins []string, newServer func() *Server) error {
+	log := commonlog.GetLogger("gdls.transport")
+	if path == "" {
+		path = "/"
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		id     int
+		closed bool // Set once the server stopped, after which wg is waited for
+	)
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool {
+		if !allowedOrigin(r, allowOrigins) {
+			log.Infof("rejected WebSocket connection from origin %s", r.Header.Get("Origin"))
+			return false
+		}
+		return true
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		// Connections are counted before the server stops waiting for them
+		mu.Lock()
+		if closed {
+			mu.Unlock()
+			http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+			return
+		}
+		wg.Add(1)
+		id++
+		connID := id
+		mu.Unlock()
+		defer wg.Done()
+
+		socket, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Infof("error upgrading HTTP to WebSocket: %s", err.Error())
+			return
+		}
+
+		log.Infof("accepted WebSocket connection #%d from %s", connID, r.RemoteAddr)
+		newServer().serve(ctx, wsjsonrpc2.NewObjectStream(socket))
+		log.Infof("WebSocket connection #%d closed", connID)
+	})
+
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", host)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: mux}
+	go func() {
+		<-ctx.Done()
+		// Hijacked WebSocket connections are closed through ctx by serve
+		srv.Close()
+	}()
+
+	log.Infof("listening for WebSocket connections on %s%s", listener.Addr(), path)
+	err = srv.Serve(listener)
+	mu.Lock()
+	closed = true
+	mu.Unlock()
+	wg.Wait()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestServeTCPConcurrentClients(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveTCP(ctx, listener, func() *Server { return NewServer("test", "test") })
+	}()
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer callCancel()
+
+	var clients []*jsonrpc2.Conn
+	for i := 0; i < 2; i++ {
+		netConn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+		client := jsonrpc2.NewConn(callCtx, jsonrpc2.NewBufferedStream(netConn, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(
+			func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil },
+		))
+		clients = append(clients, client)
+	}
+
+	// Each client gets its own session, so both can initialize
+	for i, client := range clients {
+		var result protocol.InitializeResult
+		if err := client.Call(callCtx, "initialize", protocol.InitializeParams{}, &result); err != nil {
+			t.Fatalf("client %d initialize failed: %v", i, err)
+		}
+		if result.ServerInfo == nil || result.ServerInfo.Name != "test" {
+			t.Errorf("client %d: unexpected server info %+v", i, result.ServerInfo)
+		}
+	}
+
+	// Shutting down closes the listener and every open connection
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down")
+	}
+
+	for i, client := range clients {
+		select {
+		case <-client.DisconnectNotify():
+		case <-time.After(5 * time.Second):
+			t.Errorf("client %d was not disconnected", i)
+		}
+	}
+}
+
+func TestListenAndServeInvalidAddress(t *testing.T) {
+	newServer := func() *Server { return NewServer("test", "test") }
+	for _, address := range []string{"127.0.0.1:9000", "http://127.0.0.1:9000", "tcp://"} {
+		if err := ListenAndServe(context.Background(), address, nil, newServer); err == nil {
+			t.Errorf("expected error for %q", address)
+		}
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	allow := []string{"https://editor.example.com"}
+	tests := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true}, // Not a browser
+		{"http://localhost:5173", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:8080", true},
+		{"https://editor.example.com", true},
+		{"https://evil.example.com", false},
+		{"http://localhost.evil.example.com", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := allowedOrigin(r, allow); got != tt.ok {
+			t.Errorf("allowedOrigin(%q) = %v, want %v", tt.origin, got, tt.ok)
+		}
+	}
+}
+
+func TestCodecHeaders(t *testing.T) {
+	body := `{"jsonrpc":"2.0","method":"initialized","params":{}}`
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"Content-Length: %d\r\n\r\n", true},
+		{"content-length:%d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n", true},
+		{"Content-Type: application/vscode-jsonrpc; charset=UTF8\r\nCONTENT-LENGTH: %d\r\n\r\n", true},
+		{"Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=latin1\r\n\r\n", false},
+		{"Content-Type: application/vscode-jsonrpc\r\n\r\n", false},
+	}
+
+	for _, tt := range tests {
+		header := tt.header
+		if strings.Contains(header, "%d") {
+			header = fmt.Sprintf(header, len(body))
+		}
+		// A second message checks the first was consumed entirely
+		input := header + body + fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+		reader := bufio.NewReader(strings.NewReader(input))
+
+		var msg map[string]any
+		err := lspCodec{}.ReadObject(reader, &msg)
+		if tt.ok != (err == nil) {
+			t.Errorf("%q: expected ok=%v, got %v", tt.header, tt.ok, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if msg["method"] != "initialized" {
+			t.Errorf("%q: unexpected message %v", tt.header, msg)
+		}
+		if err := (lspCodec{}).ReadObject(reader, &msg); err != nil {
+			t.Errorf("%q: reading the next message failed: %v", tt.header, err)
+		}
+	}
+}
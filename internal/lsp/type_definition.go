@@ -0,0 +1,129 @@
+package lsp
+
+import (
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+func init() {
+	registerCapability(&capability{
+		name: "typeDefinition",
+		register: func(s *Server, h *protocol.Handler) {
+			h.TextDocumentTypeDefinition = s.textDocumentTypeDefinition
+		},
+		advertise: func(s *Server, caps *serverCapabilities) {
+			caps.TypeDefinitionProvider = &protocol.TypeDefinitionOptions{}
+		},
+	})
+}
+
+// textDocumentTypeDefinition handles the textDocument/typeDefinition
+// request. Where definition goes to the declaration a value refers to, e.g.
+// the [ext_resource] of an ExtResource("..."), type definition goes to what
+// gives the value its type: the file the resource loads, or the script of a
+// sub-resource or node.
+func (s *Server) textDocumentTypeDefinition(ctx *glsp.Context, params *protocol.TypeDefinitionParams) (any, error) {
+	doc := s.workspace.GetDocument(params.TextDocument.URI)
+	if doc == nil || doc.TSCNAST == nil {
+		return nil, nil
+	}
+
+	location := s.findTypeDefinition(doc, params.TextDocument.URI, int(params.Position.Line), int(params.Position.Character))
+	if location == nil {
+		return nil, nil
+	}
+	return location, nil
+}
+
+// findTypeDefinition finds the type definition of the item at the given
+// position: for ExtResource("...") and [ext_resource] paths the file
+// loaded, at the class of scripts; for SubResource("...") the script of
+// the sub-resource; for nodes, and the node paths naming them, their script
+// or else the scene they instance.
+func (s *Server) findTypeDefinition(doc *analysis.Document, uri string, line, col int) *protocol.Location {
+	ast := doc.TSCNAST
+
+	if ref := resourceRefAt(doc, line, col); ref != nil {
+		switch ref.RefType {
+		case "ExtResource":
+			for _, ext := range ast.ExtResources {
+				if ext.ID == ref.ID {
+					return s.resourceFileLocation(uri, ext)
+				}
+			}
+		case "SubResource":
+			for _, sub := range ast.SubResources {
+				if sub.ID == ref.ID {
+					return s.propertiesScriptLocation(uri, ast, sub.Properties)
+				}
+			}
+		}
+		return nil
+	}
+
+	if ext, ok := analysis.ElementAt[*parser.ExtResource](doc.Positions, line, col); ok {
+		return s.resourceFileLocation(uri, ext)
+	}
+
+	tree := analysis.NewSceneTree(ast)
+	if np := nodePathAt(doc, tree, line, col); np != nil {
+		targetURI, target := s.resolveNodePath(uri, ast, tree, np.base, nodePathPrefixAt(np, line, col))
+		if target == nil {
+			return nil
+		}
+		targetAST := ast
+		if targetURI != uri {
+			targetAST = s.loadScene(targetURI)
+		}
+		return s.nodeTypeLocation(targetURI, targetAST, target.Node)
+	}
+
+	if node, ok := analysis.ElementAt[*parser.Node](doc.Positions, line, col); ok {
+		return s.nodeTypeLocation(uri, ast, node)
+	}
+	return nil
+}
+
+// resourceFileLocation returns the location of the file an ext_resource
+// loads, at the class of scripts.
+func (s *Server) resourceFileLocation(uri string, ext *parser.ExtResource) *protocol.Location {
+	loc := s.resolveResourcePath(s.extResourcePath(uri, ext), uri)
+	if loc != nil && analysis.IsScriptFile(uriToPath(loc.URI)) {
+		return s.scriptClassLocation(loc)
+	}
+	return loc
+}
+
+// propertiesScriptLocation returns the location of the class of the script
+// set by the script property of a section, or nil when it sets none.
+func (s *Server) propertiesScriptLocation(uri string, ast *parser.Document, props []*parser.Property) *protocol.Location {
+	for _, prop := range props {
+		ref, ok := prop.Value.(*parser.ResourceRef)
+		if prop.Key != "script" || !ok || ref.RefType != "ExtResource" {
+			continue
+		}
+		for _, ext := range ast.ExtResources {
+			if ext.ID == ref.ID {
+				return s.resourceFileLocation(uri, ext)
+			}
+		}
+	}
+	return nil
+}
+
+// nodeTypeLocation returns the location of the class of the script of a
+// node, or of the root of the scene it instances when it has none.
+func (s *Server) nodeTypeLocation(uri string, ast *parser.Document, node *parser.Node) *protocol.Location {
+	if scriptURI := s.nodeScript(uri, ast, node); scriptURI != "" {
+		return s.scriptClassLocation(&protocol.Location{URI: scriptURI})
+	}
+	if sceneURI, scene := s.instancedScene(uri, ast, node); scene != nil {
+		if root := analysis.NewSceneTree(scene).Root; root != nil {
+			return nodeLocation(sceneURI, root)
+		}
+	}
+	return nil
+}
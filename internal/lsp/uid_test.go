@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestUIDResolution(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"project.godot": "config_version=5\n",
+		"player.tscn":   "[gd_scene format=3 uid=\"uid://player\"]\n\n[node name=\"Player\" type=\"Node2D\"]\n",
+		"player.gd":     "extends Node2D\n",
+		"player.gd.uid": "uid://script\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer("test", "test")
+	s.indexFolder(context.Background(), pathToURI(dir), nil)
+
+	scene := `[gd_scene load_steps=4 format=3]
+
+[ext_resource type="PackedScene" uid="uid://player" id="1_p"]
+[ext_resource type="Script" uid="uid://script" path="res://old_name.gd" id="2_s"]
+[ext_resource type="Texture2D" uid="uid://missing" id="3_t"]
+[ext_resource type="Texture2D" uid="uid://gone" path="res://icon.svg" id="4_i"]
+
+[node name="Main" type="Node"]
+script = ExtResource("2_s")
+
+[node name="Player" parent="." instance=ExtResource("1_p")]
+`
+	uri := pathToURI(filepath.Join(dir, "main.tscn"))
+	doc := s.workspace.OpenDocument(uri, scene)
+
+	// Definition of a uid-only instance opens the scene the uid maps to
+	line, col := positionOf(t, scene, `ExtResource("1_p")]`)
+	loc := s.findDefinition(doc, uri, line, col)
+	if loc == nil || loc.URI != pathToURI(filepath.Join(dir, "player.tscn")) {
+		t.Errorf("expected definition in player.tscn, got %+v", loc)
+	}
+
+	links, err := s.textDocumentDocumentLink(&glsp.Context{}, &protocol.DocumentLinkParams{
+		TextDocument: protocol.TextDocumentIdentifier{URI: uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := map[string]bool{}
+	for _, link := range links {
+		targets[filepath.Base(*link.Target)] = true
+	}
+	if !targets["player.tscn"] || !targets["player.gd"] {
+		t.Errorf("expected uid links to player.tscn and player.gd, got %v", targets)
+	}
+
+	expected := []string{
+		"uid uid://script belongs to res://player.gd",
+		"Unknown uid uid://missing and no path to fall back to",
+		"Unknown uid uid://gone; Godot falls back to res://icon.svg",
+	}
+	var messages []string
+	for _, d := range s.computeDiagnostics(doc) {
+		messages = append(messages, d.Message)
+	}
+	for _, msg := range expected {
+		found := false
+		for _, m := range messages {
+			found = found || strings.HasPrefix(m, msg)
+		}
+		if !found {
+			t.Errorf("expected diagnostic %q, got %v", msg, messages)
+		}
+	}
+}
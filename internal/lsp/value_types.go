@@ -0,0 +1,211 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+	"github.com/andresperezl/gdls/internal/godotdoc"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// constructorArity is the number of arguments Godot's scene file parser
+// requires for each fixed-size constructor.
+var constructorArity = map[string]int{
+	"Vector2":     2,
+	"Vector2i":    2,
+	"Rect2":       4,
+	"Rect2i":      4,
+	"Vector3":     3,
+	"Vector3i":    3,
+	"Vector4":     4,
+	"Vector4i":    4,
+	"Transform2D": 6,
+	"Plane":       4,
+	"Quaternion":  4,
+	"AABB":        6,
+	"Basis":       9,
+	"Transform3D": 12,
+	"Projection":  16,
+	"Color":       4,
+	"NodePath":    1,
+}
+
+// packedStride is the number of numbers making up one element of the
+// packed arrays of vectors and colors.
+var packedStride = map[string]int{
+	"PackedVector2Array": 2,
+	"PackedVector3Array": 3,
+	"PackedVector4Array": 4,
+	"PackedColorArray":   4,
+}
+
+// checkValueTypes checks that property values match the types the class
+// reference declares for them, and that constructors such as Color(...)
+// have the number of arguments Godot expects. Property types are only
+// checked when the class reference documents the targeted version.
+func (s *Server) checkValueTypes(doc *analysis.Document) []protocol.Diagnostic {
+	diagnostics := []protocol.Diagnostic{}
+	ast := doc.TSCNAST
+	db := s.classReference()
+
+	add := func(r parser.Range, severity protocol.DiagnosticSeverity, msg string) {
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    toProtocolRange(r),
+			Severity: severityPtr(severity),
+			Source:   strPtr("gdls"),
+			Code:     diagnosticCode(codeValueType),
+			Message:  msg,
+		})
+	}
+
+	// Types of the resources references can point to
+	resourceTypes := make(map[string]string)
+	for _, ext := range ast.ExtResources {
+		resourceTypes["ExtResource:"+ext.ID] = ext.Type
+	}
+	for _, sub := range ast.SubResources {
+		resourceTypes["SubResource:"+sub.ID] = sub.Type
+	}
+
+	check := func(typeName string, props []*parser.Property) {
+		for _, prop := range props {
+			walkValue(prop.Value, func(v parser.Value) {
+				if msg := checkConstructor(v); msg != "" {
+					add(v.GetRange(), protocol.DiagnosticSeverityError, msg)
+				}
+			})
+
+			if db == nil {
+				continue
+			}
+			info, _ := db.Property(typeName, prop.Key)
+			if info == nil || prop.Value == nil {
+				continue
+			}
+			actual := valueType(prop.Value, resourceTypes)
+			if actual != "" && !typeAccepts(db, info.Type, actual) {
+				add(prop.Value.GetRange(), protocol.DiagnosticSeverityWarning,
+					fmt.Sprintf("%s expects %s, got %s", prop.Key, info.Type, actual))
+			}
+		}
+	}
+
+	for _, sub := range ast.SubResources {
+		check(sub.Type, sub.Properties)
+	}
+	for _, node := range ast.Nodes {
+		check(node.Type, node.Properties)
+	}
+	if ast.Resource != nil && ast.Descriptor != nil {
+		check(ast.Descriptor.ResourceType, ast.Resource.Properties)
+	}
+
+	return diagnostics
+}
+
+// checkConstructor returns an error message when a constructor has the
+// wrong number of arguments for its type.
+func checkConstructor(v parser.Value) string {
+	tv, ok := v.(*parser.TypedValue)
+	if !ok {
+		return ""
+	}
+	if want, ok := constructorArity[tv.TypeName]; ok && len(tv.Arguments) != want {
+		return fmt.Sprintf("%s expects %d arguments, got %d", tv.TypeName, want, len(tv.Arguments))
+	}
+	if stride, ok := packedStride[tv.TypeName]; ok && tv.ArgumentCount()%stride != 0 {
+		return fmt.Sprintf("%s expects a multiple of %d numbers, got %d", tv.TypeName, stride, tv.ArgumentCount())
+	}
+	return ""
+}
+
+// valueType returns the Variant type of a value, the class of the resource
+// a reference points to, "null", or "" when it cannot be told.
+func valueType(v parser.Value, resourceTypes map[string]string) string {
+	switch val := v.(type) {
+	case *parser.NumberValue:
+		if val.IsInt {
+			return "int"
+		}
+		return "float"
+	case *parser.StringValue:
+		return "String"
+	case *parser.BoolValue:
+		return "bool"
+	case *parser.NullValue:
+		return "null"
+	case *parser.ArrayValue:
+		return "Array"
+	case *parser.DictValue:
+		return "Dictionary"
+	case *parser.TypedValue:
+		// Objects that are not resources are saved as Object(Class, ...)
+		if val.TypeName == "Object" {
+			if len(val.Arguments) > 0 {
+				if class, ok := val.Arguments[0].(*parser.IdentValue); ok {
+					return class.Name
+				}
+			}
+			return ""
+		}
+		return val.TypeName
+	case *parser.ResourceRef:
+		return resourceTypes[val.RefType+":"+val.ID]
+	}
+	return ""
+}
+
+// typeAccepts reports whether a property declared with the expected type
+// can hold a value of the actual type, allowing the conversions Godot
+// makes when it loads a scene.
+func typeAccepts(db *godotdoc.Database, expected, actual string) bool {
+	if expected == actual || expected == "Variant" {
+		return true
+	}
+
+	// Typed arrays such as Material[] are saved as arrays
+	if strings.HasSuffix(expected, "[]") {
+		return actual == "Array" || actual == "null"
+	}
+
+	if isObjectClass(db, expected) {
+		if actual == "null" {
+			return true
+		}
+		if db.Class(actual) == nil {
+			return true // A type the class reference does not know
+		}
+		for _, ancestor := range db.Inheritance(actual) {
+			if ancestor == expected {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch expected {
+	case "float":
+		return actual == "int"
+	case "String", "StringName":
+		return actual == "String" || actual == "StringName"
+	case "NodePath":
+		return actual == "String"
+	case "Array":
+		return strings.HasPrefix(actual, "Packed") && strings.HasSuffix(actual, "Array")
+	}
+	if strings.HasPrefix(expected, "Packed") && strings.HasSuffix(expected, "Array") {
+		return actual == "Array"
+	}
+	// Variant types the class reference does not document, such as Callable
+	return db.Class(expected) == nil
+}
+
+// isObjectClass reports whether a type is an Object class rather than a
+// built-in Variant type.
+func isObjectClass(db *godotdoc.Database, typeName string) bool {
+	chain := db.Inheritance(typeName)
+	return len(chain) > 0 && chain[len(chain)-1] == "Object"
+}
@@ -0,0 +1,98 @@
+package lsp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestValueTypeDiagnostics(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[ext_resource type="Texture2D" path="res://icon.svg" id="1_tex"]
+[ext_resource type="AudioStream" path="res://jump.ogg" id="2_snd"]
+
+[sub_resource type="BoxShape3D" id="Box_1"]
+size = Vector3(1, 2, 3)
+
+[sub_resource type="StandardMaterial3D" id="Mat_1"]
+albedo_color = Color(1, 0, 0)
+albedo_texture = ExtResource("1_tex")
+
+[node name="Root" type="Node3D"]
+position = 1.5
+rotation = Vector3(0, 0, 0)
+visible = true
+
+[node name="Shape" type="CollisionShape3D" parent="."]
+shape = SubResource("Mat_1")
+
+[node name="Sprite" type="Sprite3D" parent="."]
+texture = ExtResource("2_snd")
+pixel_size = 1
+hframes = 2.5
+modulate = Color(1, 1, 1, 1)
+region_rect = Rect2(0, 0, 16)
+
+[node name="Mesh" type="MeshInstance3D" parent="."]
+mesh = null
+skeleton = "../Skeleton"
+
+[node name="Path" type="Path3D" parent="."]
+metadata/points = PackedVector3Array(0, 0, 0, 1, 1)
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/level.tscn", content)
+
+	var messages []string
+	for _, d := range s.checkValueTypes(doc) {
+		messages = append(messages, d.Message)
+	}
+
+	expected := []string{
+		"Color expects 4 arguments, got 3",
+		"position expects Vector3, got float",
+		"shape expects Shape3D, got StandardMaterial3D",
+		"texture expects Texture2D, got AudioStream",
+		"hframes expects int, got float",
+		"Rect2 expects 4 arguments, got 3",
+		"PackedVector3Array expects a multiple of 3 numbers, got 5",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q, got %q", expected, messages)
+	}
+
+	// Only constructors are checked against older targets
+	s.applySettings(map[string]any{"godotVersion": "4.4"})
+	messages = nil
+	for _, d := range s.checkValueTypes(doc) {
+		messages = append(messages, d.Message)
+	}
+	expected = []string{
+		"Color expects 4 arguments, got 3",
+		"Rect2 expects 4 arguments, got 3",
+		"PackedVector3Array expects a multiple of 3 numbers, got 5",
+	}
+	if !slices.Equal(messages, expected) {
+		t.Errorf("expected %q for Godot 4.4, got %q", expected, messages)
+	}
+}
+
+func TestValueTypeDiagnosticRange(t *testing.T) {
+	content := `[gd_scene format=3]
+
+[node name="Root" type="Node2D"]
+position = Vector3(1, 2, 3)
+`
+	s := NewServer("test", "test")
+	doc := s.workspace.OpenDocument("file:///tmp/level.tscn", content)
+
+	diagnostics := s.checkValueTypes(doc)
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics))
+	}
+	line, col := positionOf(t, content, "Vector3(")
+	r := diagnostics[0].Range
+	if int(r.Start.Line) != line || int(r.Start.Character) != col || int(r.End.Character) != col+len("Vector3(1, 2, 3)") {
+		t.Errorf("expected the diagnostic to cover the value, got %+v", r)
+	}
+}
@@ -0,0 +1,123 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/analysis"
+)
+
+// watchedFilesGlob matches the files the workspace index is built from,
+// plus the scripts whose uid sidecars and paths resources refer to, and
+// which declare the script classes of the project.
+const watchedFilesGlob = "**/*.{tscn,escn,tres,gdshader,gdshaderinc,glsl,godot,cfg,import,gdextension,gd,cs,uid}"
+
+func init() {
+	registerCapability(&capability{
+		name: "watchedFiles",
+		register: func(s *Server, h *protocol.Handler) {
+			h.WorkspaceDidChangeWatchedFiles = s.workspaceDidChangeWatchedFiles
+		},
+		// Watchers are registered dynamically once the client is initialized
+		advertise: func(s *Server, caps *serverCapabilities) {},
+	})
+}
+
+// registerFileWatchers asks the client to report changes to the files the
+// workspace index is built from.
+func (s *Server) registerFileWatchers(ctx *glsp.Context) {
+	defer s.recoverPanic("file watcher registration", nil)
+	ctx.Call(string(protocol.ServerClientRegisterCapability), protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     "gdls-watched-files",
+			Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{{GlobPattern: watchedFilesGlob}},
+			},
+		}},
+	}, nil)
+}
+
+// workspaceDidChangeWatchedFiles handles the workspace/didChangeWatchedFiles
+// notification, updating the index for files created, changed or deleted
+// outside the editor. A rename arrives as a deletion and a creation.
+func (s *Server) workspaceDidChangeWatchedFiles(ctx *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	changed := false
+	for _, change := range params.Changes {
+		if folder := s.workspace.FolderOf(change.URI); folder != "" {
+			s.updateWatchedFile(ctx, folder, change)
+			s.refreshShaderDependents(ctx, change.URI)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	// Links and uid references of open documents may point elsewhere now
+	s.refreshDiagnostics(ctx)
+	for _, doc := range s.workspace.GetAllDocuments() {
+		s.scheduleDiagnostics(ctx, doc.URI)
+	}
+	return nil
+}
+
+// updateWatchedFile updates the index of a workspace folder for one file.
+func (s *Server) updateWatchedFile(ctx *glsp.Context, folderURI string, change protocol.FileEvent) {
+	root := uriToPath(folderURI)
+	path := uriToPath(change.URI)
+	if root == "" || path == "" {
+		return
+	}
+
+	// An open project file is indexed from the editor's content instead
+	if path == filepath.Join(root, analysis.ProjectFileName) && s.workspace.GetDocument(change.URI) == nil {
+		switch change.Type {
+		case protocol.FileChangeTypeCreated:
+			// The folder became a project, whose uids are indexed too
+			if status, ok := s.indexFolder(context.Background(), folderURI, nil); ok {
+				ctx.Notify(StatusNotification, status)
+			}
+			return
+		case protocol.FileChangeTypeChanged:
+			if content, err := os.ReadFile(path); err == nil {
+				s.workspace.IndexProjectFile(change.URI, string(content))
+			}
+		case protocol.FileChangeTypeDeleted:
+			s.workspace.RemoveProjectFile(change.URI)
+		}
+	}
+
+	if analysis.IsScriptFile(path) {
+		classes := analysis.ReadScriptClasses(root, []string{path}, []string{change.URI})
+		s.workspace.SetScriptClasses(change.URI, classes[change.URI])
+	}
+
+	if change.Type == protocol.FileChangeTypeDeleted {
+		s.workspace.RemoveFolderFile(folderURI, change.URI)
+		s.workspace.SetSceneGroups(change.URI, nil)
+		for _, p := range []string{path, strings.TrimSuffix(path, analysis.UIDFileExt)} {
+			if res, ok := analysis.ResPath(root, p); ok {
+				s.workspace.RemoveFolderUIDs(folderURI, res)
+			}
+		}
+		return
+	}
+
+	if analysis.ScanFile(root, path, analysis.ScanOptions{MaxFileSize: s.currentSettings().MaxFileSize}) {
+		s.workspace.AddFolderFile(folderURI, change.URI)
+		groups := analysis.ReadSceneGroups([]string{path}, []string{change.URI})
+		s.workspace.SetSceneGroups(change.URI, groups[change.URI])
+	} else {
+		s.workspace.RemoveFolderFile(folderURI, change.URI)
+		s.workspace.SetSceneGroups(change.URI, nil)
+	}
+	if uid, res, ok := analysis.FileUID(root, path); ok && fileExists(filepath.Join(root, analysis.ProjectFileName)) {
+		s.workspace.SetFolderUID(folderURI, uid, res)
+	}
+}
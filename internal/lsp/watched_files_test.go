@@ -0,0 +1,88 @@
+package lsp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestWatchedFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+
+	projectURI := write("project.godot", "config_version=5\n")
+	playerURI := write("player.tscn", "[gd_scene format=3 uid=\"uid://player\"]\n")
+
+	s := NewServer("test", "test")
+	folder := pathToURI(dir)
+	s.workspace.AddFolder(folder)
+	s.indexFolder(context.Background(), folder, nil)
+
+	var notified []string
+	ctx := &glsp.Context{Notify: func(method string, params any) { notified = append(notified, method) }}
+	notify := func(changes ...protocol.FileEvent) {
+		t.Helper()
+		if err := s.workspaceDidChangeWatchedFiles(ctx, &protocol.DidChangeWatchedFilesParams{Changes: changes}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Renaming a scene outside the editor moves its uid and file entry
+	if err := os.Remove(filepath.Join(dir, "player.tscn")); err != nil {
+		t.Fatal(err)
+	}
+	heroURI := write("hero.tscn", "[gd_scene format=3 uid=\"uid://player\"]\n")
+	notify(
+		protocol.FileEvent{URI: playerURI, Type: protocol.FileChangeTypeDeleted},
+		protocol.FileEvent{URI: heroURI, Type: protocol.FileChangeTypeCreated},
+	)
+	if res, ok := s.workspace.ResolveUID(heroURI, "uid://player"); !ok || res != "res://hero.tscn" {
+		t.Errorf("expected uid://player to resolve to res://hero.tscn, got %q", res)
+	}
+	files := s.workspace.Files()
+	if slices.Contains(files, playerURI) || !slices.Contains(files, heroURI) {
+		t.Errorf("expected hero.tscn in place of player.tscn, got %v", files)
+	}
+
+	// A new uid sidecar maps its script
+	scriptUID := write("hero.gd.uid", "uid://script\n")
+	notify(protocol.FileEvent{URI: scriptUID, Type: protocol.FileChangeTypeCreated})
+	if res, ok := s.workspace.ResolveUID(heroURI, "uid://script"); !ok || res != "res://hero.gd" {
+		t.Errorf("expected uid://script to resolve to res://hero.gd, got %q", res)
+	}
+
+	// Editing project.godot outside the editor updates the autoloads
+	write("project.godot", "[autoload]\n\nGlobal=\"*res://global.gd\"\n")
+	notify(protocol.FileEvent{URI: projectURI, Type: protocol.FileChangeTypeChanged})
+	if autoloads := s.workspace.Autoloads(heroURI); len(autoloads) != 1 || autoloads[0].Name != "Global" {
+		t.Errorf("expected the Global autoload, got %+v", autoloads)
+	}
+	notify(protocol.FileEvent{URI: projectURI, Type: protocol.FileChangeTypeDeleted})
+	if autoloads := s.workspace.Autoloads(heroURI); len(autoloads) != 0 {
+		t.Errorf("expected no autoloads after deleting project.godot, got %+v", autoloads)
+	}
+
+	// Creating a project rescans the folder and reports its status
+	notify(protocol.FileEvent{URI: projectURI, Type: protocol.FileChangeTypeCreated})
+	if !slices.Contains(notified, StatusNotification) {
+		t.Errorf("expected a %s notification, got %v", StatusNotification, notified)
+	}
+
+	// Files outside every workspace folder are ignored
+	notify(protocol.FileEvent{URI: "file:///elsewhere/other.tscn", Type: protocol.FileChangeTypeCreated})
+	if slices.Contains(s.workspace.Files(), "file:///elsewhere/other.tscn") {
+		t.Error("expected files outside the workspace to be ignored")
+	}
+}
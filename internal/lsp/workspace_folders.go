@@ -0,0 +1,30 @@
+package lsp
+
+import (
+	"context"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+// workspaceDidChangeWorkspaceFolders handles the
+// workspace/didChangeWorkspaceFolders notification. Every folder is indexed
+// as its own project, so adding one scans it and removing one drops its
+// files, uids and autoloads.
+func (s *Server) workspaceDidChangeWorkspaceFolders(ctx *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	for _, folder := range params.Event.Removed {
+		s.workspace.RemoveFolder(folder.URI)
+	}
+	for _, folder := range params.Event.Added {
+		s.workspace.AddFolder(folder.URI)
+		if status, ok := s.indexFolder(context.Background(), folder.URI, nil); ok {
+			ctx.Notify(StatusNotification, status)
+		}
+	}
+
+	// Open documents may belong to another project now
+	for _, doc := range s.workspace.GetAllDocuments() {
+		s.scheduleDiagnostics(ctx, doc.URI)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/tliron/glsp"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+)
+
+func TestWorkspaceFolders(t *testing.T) {
+	// Two projects give the same uid to different resources
+	write := func(dir, name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return pathToURI(path)
+	}
+	game, editor := t.TempDir(), t.TempDir()
+	write(game, "project.godot", "[autoload]\n\nGlobal=\"*res://global.gd\"\n")
+	gameScene := write(game, "main.tscn", "[gd_scene format=3 uid=\"uid://shared\"]\n")
+	write(editor, "project.godot", "config_version=5\n")
+	editorScene := write(editor, "tool.tscn", "[gd_scene format=3 uid=\"uid://shared\"]\n")
+
+	s := NewServer("test", "test")
+	ctx := &glsp.Context{Notify: func(string, any) {}}
+	result, err := s.initialize(ctx, &protocol.InitializeParams{
+		WorkspaceFolders: []protocol.WorkspaceFolder{{URI: pathToURI(game), Name: "game"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws := result.(initializeResult).Capabilities.Workspace; ws == nil || ws.WorkspaceFolders == nil {
+		t.Fatal("expected workspace folder support to be advertised")
+	}
+	s.indexWorkspace(ctx)
+
+	if err := s.workspaceDidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Added: []protocol.WorkspaceFolder{{URI: pathToURI(editor), Name: "editor"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok := s.workspace.ResolveUID(gameScene, "uid://shared"); !ok || res != "res://main.tscn" {
+		t.Errorf("expected uid://shared to resolve to main.tscn in the game, got %q", res)
+	}
+	if res, ok := s.workspace.ResolveUID(editorScene, "uid://shared"); !ok || res != "res://tool.tscn" {
+		t.Errorf("expected uid://shared to resolve to tool.tscn in the editor, got %q", res)
+	}
+	if loc := s.resolveResourcePath("res://tool.tscn", editorScene); loc == nil || loc.URI != editorScene {
+		t.Errorf("expected res:// to resolve against the editor project, got %+v", loc)
+	}
+	if autoloads := s.workspace.Autoloads(editorScene); len(autoloads) != 0 {
+		t.Errorf("expected no autoloads in the editor project, got %+v", autoloads)
+	}
+	if autoloads := s.workspace.Autoloads(gameScene); len(autoloads) != 1 {
+		t.Errorf("expected the game's autoload, got %+v", autoloads)
+	}
+
+	// Removing a folder drops its index
+	if err := s.workspaceDidChangeWorkspaceFolders(ctx, &protocol.DidChangeWorkspaceFoldersParams{
+		Event: protocol.WorkspaceFoldersChangeEvent{
+			Removed: []protocol.WorkspaceFolder{{URI: pathToURI(game), Name: "game"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if slices.Contains(s.workspace.Files(), gameScene) {
+		t.Error("expected the game's files to be dropped")
+	}
+	if autoloads := s.workspace.Autoloads(""); len(autoloads) != 0 {
+		t.Errorf("expected the game's autoloads to be dropped, got %+v", autoloads)
+	}
+	if folders := s.workspace.GetFolders(); len(folders) != 1 || folders[0] != pathToURI(editor) {
+		t.Errorf("expected only the editor folder, got %v", folders)
+	}
+}
@@ -7,17 +7,21 @@ type Document struct {
 	SubResources []*SubResource // [sub_resource ...]
 	Nodes        []*Node        // [node ...]
 	Connections  []*Connection  // [connection ...]
+	Resource     *Resource      // [resource] of a .tres file
 	Comments     []*Comment     // ; comments
 	Errors       []ParseError   // Syntax errors
 }
 
 // GdScene represents the file descriptor [gd_scene ...] or [gd_resource ...].
 type GdScene struct {
-	Range     Range
-	Type      string // "gd_scene" or "gd_resource"
-	LoadSteps *int
-	Format    int
-	UID       string
+	Range          Range
+	Type           string // "gd_scene" or "gd_resource"
+	LoadSteps      *int
+	LoadStepsRange Range // Range of the load_steps value
+	Format         int
+	FormatRange    Range // Range of the format value, zero when omitted
+	UID            string
+	UIDRange       Range // Range of the uid string
 	// For gd_resource
 	ResourceType string
 }
@@ -26,17 +30,30 @@ type GdScene struct {
 type ExtResource struct {
 	Range     Range
 	Type      string // e.g., "Texture2D", "Material"
+	TypeRange Range  // Range of the type string
 	UID       string // uid://...
+	UIDRange  Range  // Range of the uid string
 	Path      string // res://... or relative path
 	PathRange Range  // Range of the path string (for go-to-definition)
 	ID        string // e.g., "1_7bt6s"
+	IDRange   Range  // Range of the ID string
 }
 
 // SubResource represents an internal resource [sub_resource ...].
 type SubResource struct {
+	Range       Range
+	HeaderRange Range  // Range of the [sub_resource ...] header, without the properties
+	Type        string // e.g., "SphereShape3D"
+	TypeRange   Range  // Range of the type string
+	ID          string // e.g., "SphereShape3D_tj6p1"
+	IDRange     Range  // Range of the ID string
+	Properties  []*Property
+}
+
+// Resource represents the [resource] section holding the properties of the
+// main resource of a .tres file.
+type Resource struct {
 	Range      Range
-	Type       string // e.g., "SphereShape3D"
-	ID         string // e.g., "SphereShape3D_tj6p1"
 	Properties []*Property
 }
 
@@ -44,25 +61,33 @@ type SubResource struct {
 type Node struct {
 	Range               Range
 	Name                string
+	NameRange           Range  // Range of the name string
 	Type                string // optional (missing for instance nodes)
+	TypeRange           Range  // Range of the type string
 	Parent              string // "." or "Path/To/Parent", empty for root
+	ParentRange         Range  // Range of the parent string
 	Instance            Value  // ExtResource("id") for instanced scenes
 	InstancePlaceholder string
 	Owner               string
 	Index               *int
 	Groups              []string
+	GroupRanges         []Range // Range of each group string, in the order of Groups
 	Properties          []*Property
 }
 
 // Connection represents a signal connection [connection ...].
 type Connection struct {
-	Range  Range
-	Signal string
-	From   string // NodePath
-	To     string // NodePath
-	Method string
-	Flags  *int
-	Binds  []Value
+	Range       Range
+	Signal      string
+	From        string // NodePath
+	FromRange   Range  // Range of the from string
+	To          string // NodePath
+	ToRange     Range  // Range of the to string
+	Method      string
+	MethodRange Range // Range of the method string
+	Flags       *int  // Object.ConnectFlags bitmask
+	FlagsRange  Range // Range of the flags number
+	Binds       []Value
 }
 
 // Property represents a key = value pair.
@@ -164,10 +189,17 @@ func (v *IdentValue) GetRange() Range { return v.Range }
 
 // TypedValue represents a typed value like Vector3(1, 2, 3).
 type TypedValue struct {
-	Range     Range
-	TypeName  string // e.g., "Vector3", "Color", "Transform3D"
-	TypeRange Range  // Range of just the type name
-	Arguments []Value
+	Range      Range
+	TypeName   string  // e.g., "Vector3", "Color", "Transform3D"
+	TypeRange  Range   // Range of just the type name
+	TypeParams []Value // Element types of Array[T] and Dictionary[K, V]
+	Arguments  []Value
+	Elided     int // Number of arguments skipped in a large file, which leaves Arguments empty
+}
+
+// ArgumentCount returns the number of arguments, including elided ones.
+func (v *TypedValue) ArgumentCount() int {
+	return len(v.Arguments) + v.Elided
 }
 
 func (v *TypedValue) valueNode()      {}
@@ -0,0 +1,187 @@
+package parser
+
+import "strings"
+
+// ConfigDocument represents a parsed ConfigFile such as project.godot or the
+// editor settings under .godot/. Values use the same syntax as TSCN files.
+type ConfigDocument struct {
+	Properties []*Property // Keys before the first section (e.g. config_version)
+	Sections   []*ConfigSection
+	Comments   []*Comment
+	Errors     []ParseError
+}
+
+// ConfigSection represents a [section] and the keys that follow it.
+type ConfigSection struct {
+	Range      Range
+	Name       string
+	NameRange  Range // Range of the name between the brackets
+	Properties []*Property
+}
+
+// Section returns the first section with the given name, or nil.
+func (d *ConfigDocument) Section(name string) *ConfigSection {
+	for _, section := range d.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+// Get returns the last value of key in the section, matching how Godot
+// resolves duplicate keys, or nil when it is not set.
+func (s *ConfigSection) Get(key string) *Property {
+	var found *Property
+	for _, prop := range s.Properties {
+		if prop.Key == key {
+			found = prop
+		}
+	}
+	return found
+}
+
+// ParseConfig parses ConfigFile source code and returns a ConfigDocument.
+func ParseConfig(input string) *ConfigDocument {
+	p := newParser(input)
+	defer p.release()
+	cfg := &ConfigDocument{
+		Properties: []*Property{},
+		Sections:   []*ConfigSection{},
+	}
+
+	var section *ConfigSection
+	for !p.isAtEnd() {
+		p.skipNewlines()
+		if p.isAtEnd() {
+			break
+		}
+
+		switch p.current.Type {
+		case TokenLBracket:
+			section = p.parseConfigSection(input)
+			cfg.Sections = append(cfg.Sections, section)
+		case TokenIdent, TokenNumber:
+			prop := p.parseConfigProperty(input)
+			if prop == nil {
+				break
+			}
+			if section != nil {
+				section.Properties = append(section.Properties, prop)
+				section.Range.End = prop.Range.End
+			} else {
+				cfg.Properties = append(cfg.Properties, prop)
+			}
+		default:
+			p.addError("unexpected token: " + p.current.Value)
+			p.skipLine()
+		}
+	}
+
+	cfg.Comments = p.doc.Comments
+	cfg.Errors = p.doc.Errors
+	return cfg
+}
+
+// parseConfigSection parses a [section] header. Section names are taken
+// verbatim from the source since they may contain any character, as in the
+// [res://player.gd] sections of the editor caches.
+func (p *Parser) parseConfigSection(input string) *ConfigSection {
+	startToken := p.current
+	p.advance() // consume '['
+
+	nameStart := startToken.Offset + 1
+	for p.current.Type != TokenRBracket && p.current.Type != TokenNewline && !p.isAtEnd() {
+		p.advance()
+	}
+	nameEnd := p.current.Offset
+
+	endToken := p.current
+	if p.current.Type == TokenRBracket {
+		p.advance()
+	} else {
+		p.addError("expected ']' after section name")
+		endToken = p.prevToken()
+	}
+
+	name := strings.TrimSpace(input[nameStart:nameEnd])
+	if name == "" {
+		p.errorAt(startToken, "expected section name")
+	}
+
+	// The name is on the header line, so columns follow from offsets
+	leading := len(input[nameStart:nameEnd]) - len(strings.TrimLeft(input[nameStart:nameEnd], " \t"))
+	nameCol := startToken.Column + 1 + leading
+	nameOffset := nameStart + leading
+
+	start := Position{Line: startToken.Line, Column: startToken.Column, Offset: startToken.Offset}
+	end := Position{Line: endToken.Line, Column: endToken.Column + endToken.Length, Offset: endToken.Offset + endToken.Length}
+	return &ConfigSection{
+		Range: Range{Start: start, End: end},
+		Name:  name,
+		NameRange: Range{
+			Start: Position{Line: startToken.Line, Column: nameCol, Offset: nameOffset},
+			End:   Position{Line: startToken.Line, Column: nameCol + len(name), Offset: nameOffset + len(name)},
+		},
+		Properties: []*Property{},
+	}
+}
+
+// parseConfigProperty parses a key=value line, reporting a missing value or
+// anything left on the line after the value. Keys run verbatim up to the
+// '=' since they may hold characters TSCN keys never do, as in the feature
+// overrides of "rendering_method.mobile".
+func (p *Parser) parseConfigProperty(input string) *Property {
+	keyToken := p.current
+	for p.current.Type != TokenEquals && p.current.Type != TokenNewline && !p.isAtEnd() {
+		p.advance()
+	}
+	if p.current.Type != TokenEquals {
+		p.errorAt(keyToken, "expected '=' after key")
+		p.skipLine()
+		return nil
+	}
+
+	key := strings.TrimSpace(input[keyToken.Offset:p.current.Offset])
+	keyStart := Position{Line: keyToken.Line, Column: keyToken.Column, Offset: keyToken.Offset}
+	keyEnd := Position{Line: keyToken.Line, Column: keyToken.Column + len(key), Offset: keyToken.Offset + len(key)}
+	p.advance() // consume '='
+
+	value := p.parseValue()
+	if value == nil {
+		p.errorAt(keyToken, "expected a value for '"+key+"'")
+		p.skipLine()
+		return nil
+	}
+
+	prop := &Property{
+		Range:    Range{Start: keyStart, End: value.GetRange().End},
+		Key:      key,
+		KeyRange: Range{Start: keyStart, End: keyEnd},
+		Value:    value,
+	}
+
+	if p.current.Type != TokenNewline && p.current.Type != TokenComment && !p.isAtEnd() {
+		p.addError("unexpected '" + p.current.Value + "' after value of '" + prop.Key + "'")
+		p.skipLine()
+	}
+	return prop
+}
+
+// skipLine advances past the rest of the current line.
+func (p *Parser) skipLine() {
+	for !p.isAtEnd() && p.current.Type != TokenNewline {
+		p.advance()
+	}
+}
+
+// errorAt records a parse error at the given token.
+func (p *Parser) errorAt(tok Token, msg string) {
+	if len(p.doc.Errors) >= maxErrors {
+		return
+	}
+	p.doc.Errors = append(p.doc.Errors, ParseError{
+		Range:   p.makeRange(tok),
+		Message: msg,
+	})
+}
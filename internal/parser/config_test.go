@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	input := `; Engine configuration file.
+
+config_version=5
+
+[application]
+
+config/name="My Game"
+config/features=PackedStringArray("4.2", "Forward Plus")
+
+[autoload]
+
+Global="*res://global.gd"
+
+[input]
+
+jump={
+"deadzone": 0.5,
+"events": [Object(InputEventKey,"resource_local_to_scene":false,"keycode":0,"physical_keycode":32,"script":null)
+]
+}
+
+[rendering]
+
+renderer/rendering_method.mobile="gl_compatibility"
+`
+	cfg := ParseConfig(input)
+
+	if len(cfg.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", cfg.Errors)
+	}
+	if len(cfg.Properties) != 1 || cfg.Properties[0].Key != "config_version" {
+		t.Errorf("expected top-level config_version, got %v", cfg.Properties)
+	}
+	if len(cfg.Sections) != 4 {
+		t.Fatalf("expected 4 sections, got %d", len(cfg.Sections))
+	}
+
+	app := cfg.Section("application")
+	if app == nil || len(app.Properties) != 2 {
+		t.Fatal("expected application section with 2 keys")
+	}
+	if name, ok := app.Get("config/name").Value.(*StringValue); !ok || name.Value != "My Game" {
+		t.Errorf("unexpected config/name: %v", app.Get("config/name").Value)
+	}
+	if app.NameRange.Start.Column != 1 || app.NameRange.End.Column != 12 {
+		t.Errorf("unexpected section name range: %v", app.NameRange)
+	}
+
+	if _, ok := cfg.Section("input").Get("jump").Value.(*DictValue); !ok {
+		t.Error("expected input action to be a dictionary")
+	}
+	if cfg.Section("rendering").Get("renderer/rendering_method.mobile") == nil {
+		t.Error("expected feature override key to be kept verbatim")
+	}
+}
+
+func TestParseConfigMalformed(t *testing.T) {
+	input := `[application]
+config/name=
+config/icon "res://icon.svg"
+run/main_scene="res://main.tscn" extra
+[unterminated
+`
+	cfg := ParseConfig(input)
+
+	expected := []string{
+		"expected a value for 'config/name'",
+		"expected '=' after key",
+		"unexpected 'extra' after value of 'run/main_scene'",
+		"expected ']' after section name",
+	}
+	if len(cfg.Errors) != len(expected) {
+		t.Fatalf("expected %d errors, got %v", len(expected), cfg.Errors)
+	}
+	for i, msg := range expected {
+		if cfg.Errors[i].Message != msg {
+			t.Errorf("error %d: expected %q, got %q", i, msg, cfg.Errors[i].Message)
+		}
+	}
+}
+
+func TestLexerLoneDotMakesProgress(t *testing.T) {
+	tokens := NewLexer("a.b").Tokenize()
+	if len(tokens) != 4 || tokens[1].Type != TokenError {
+		t.Errorf("expected ident, error, ident, EOF; got %v", tokens)
+	}
+}
@@ -0,0 +1,393 @@
+package parser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatOptions configures Format.
+type FormatOptions struct {
+	// AlignProperties pads the keys of each section so that the '=' of its
+	// properties line up.
+	AlignProperties bool
+}
+
+// Format returns TSCN or TRES source with a normalized section layout. It
+// only changes layout, never values:
+//   - section headers have single spaces between attributes and no spaces
+//     around their '='
+//   - properties are written as "key = value", optionally aligned
+//   - ext_resource headers are sorted by id
+//   - sections are separated by one blank line, except consecutive
+//     ext_resource headers, and blank lines inside sections are removed
+//   - the file ends with exactly one newline
+//
+// Comments are kept with the section or property they precede. Values
+// spanning several lines, such as multi-line strings, arrays and
+// dictionaries, are kept as is. So are a byte order mark and the line
+// breaks of the file, the most common of "\n" and "\r\n" when it mixes
+// them.
+func Format(src string, opts FormatOptions) string {
+	src, bom := strings.CutPrefix(src, byteOrderMark)
+	eol := lineEnding(src)
+	out := format(strings.ReplaceAll(src, "\r\n", "\n"), opts)
+	if eol != "\n" {
+		out = strings.ReplaceAll(out, "\n", eol)
+	}
+	if bom && out != "" {
+		out = byteOrderMark + out
+	}
+	return out
+}
+
+// lineEnding returns the line break most lines of src end with: "\r\n",
+// or "\n" when at least as many end with it alone.
+func lineEnding(src string) string {
+	crlf := strings.Count(src, "\r\n")
+	if crlf > strings.Count(src, "\n")-crlf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// format formats src with "\n" line breaks.
+func format(src string, opts FormatOptions) string {
+	entries := splitEntries(src)
+	var blocks []*formatBlock
+	current := &formatBlock{}
+	var comments []string
+	for i, entry := range entries {
+		text := strings.TrimSpace(entry)
+		switch {
+		case text == "":
+			// Comments set apart from the next section by a blank line stand
+			// on their own
+			if len(comments) > 0 && nextIsHeader(entries[i:]) {
+				if current.header != "" || len(current.body) > 0 {
+					blocks = append(blocks, current)
+				}
+				current = &formatBlock{}
+				blocks = append(blocks, &formatBlock{comments: comments})
+				comments = nil
+			}
+		case strings.HasPrefix(text, ";"):
+			comments = append(comments, text)
+		case strings.HasPrefix(text, "["):
+			if current.header != "" || len(current.body) > 0 {
+				blocks = append(blocks, current)
+			}
+			current = &formatBlock{comments: comments, header: formatHeader(text)}
+			comments = nil
+		default:
+			current.body = append(current.body, comments...)
+			current.body = append(current.body, text)
+			comments = nil
+		}
+	}
+	// Comments right after the last property close its section
+	current.body = append(current.body, comments...)
+	if current.header != "" || len(current.body) > 0 {
+		blocks = append(blocks, current)
+	}
+
+	sortExtResources(blocks)
+
+	var out []string
+	for i, block := range blocks {
+		if i > 0 && !(block.isExtResource() && blocks[i-1].isExtResource() && len(blocks[i-1].body) == 0) {
+			out = append(out, "")
+		}
+		out = append(out, block.comments...)
+		if block.header != "" {
+			out = append(out, block.header)
+		}
+		out = append(out, formatProperties(block.body, opts.AlignProperties)...)
+	}
+	if len(out) == 0 {
+		return ""
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// formatBlock is a section of a file being formatted: the comments before
+// its header, the header, and its properties and comments.
+type formatBlock struct {
+	comments []string
+	header   string
+	body     []string
+}
+
+// isExtResource reports whether the block is an ext_resource header.
+func (b *formatBlock) isExtResource() bool {
+	return strings.HasPrefix(b.header, "[ext_resource ")
+}
+
+// nextIsHeader reports whether the first entry that is not blank is a
+// section header, or there is none.
+func nextIsHeader(entries []string) bool {
+	for _, entry := range entries {
+		text := strings.TrimSpace(entry)
+		if text != "" {
+			return strings.HasPrefix(text, "[")
+		}
+	}
+	return true
+}
+
+// splitEntries splits src into logical lines: section headers, properties,
+// comments and blank lines. Headers and values spanning lines are kept
+// whole, as are lines of a string literal.
+func splitEntries(src string) []string {
+	var entries []string
+	start, depth := 0, 0
+	inString := false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case ';':
+			// Skip to the end of the comment
+			if end := strings.IndexByte(src[i:], '\n'); end >= 0 {
+				i += end - 1
+			} else {
+				i = len(src)
+			}
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			if depth > 0 {
+				depth--
+			}
+		case '\n':
+			if depth == 0 {
+				entries = append(entries, src[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(src) {
+		entries = append(entries, src[start:])
+	}
+	return entries
+}
+
+// formatHeader normalizes the spacing of a section header, e.g.
+// `[ node  name = "A" type="Node" ]` becomes `[node name="A" type="Node"]`.
+// Headers with unbalanced brackets are returned unchanged.
+func formatHeader(text string) string {
+	end := closingBracket(text)
+	if end < 0 {
+		return text
+	}
+
+	tokens := headerTokens(text[1:end])
+	if len(tokens) == 0 {
+		return text
+	}
+
+	parts := []string{tokens[0]}
+	for i := 1; i < len(tokens); {
+		if i+2 < len(tokens) && tokens[i+1] == "=" {
+			parts = append(parts, tokens[i]+"="+tokens[i+2])
+			i += 3
+			continue
+		}
+		parts = append(parts, tokens[i])
+		i++
+	}
+
+	header := "[" + strings.Join(parts, " ") + "]"
+	// Keep a trailing comment
+	if rest := strings.TrimSpace(text[end+1:]); rest != "" {
+		header += " " + rest
+	}
+	return header
+}
+
+// closingBracket returns the index of the ']' closing the header that text
+// starts with, or -1.
+func closingBracket(text string) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '(', '{':
+			depth++
+		case ']', ')', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// headerTokens splits the inside of a section header into words, values and
+// '=' signs. Whitespace inside strings and brackets does not split a value.
+func headerTokens(inner string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	depth := 0
+	inString := false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if inString {
+			cur.WriteByte(c)
+			switch c {
+			case '\\':
+				if i+1 < len(inner) {
+					i++
+					cur.WriteByte(inner[i])
+				}
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case depth == 0 && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			flush()
+		case depth == 0 && c == '=':
+			flush()
+			tokens = append(tokens, "=")
+		default:
+			switch c {
+			case '"':
+				inString = true
+			case '[', '(', '{':
+				depth++
+			case ']', ')', '}':
+				depth--
+			}
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// headerAttr returns the unquoted value of an attribute of a formatted
+// header, or "".
+func headerAttr(header, key string) string {
+	end := closingBracket(header)
+	if end < 0 {
+		return ""
+	}
+	tokens := headerTokens(header[1:end])
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i] == key && tokens[i+1] == "=" {
+			return strings.Trim(tokens[i+2], `"`)
+		}
+	}
+	return ""
+}
+
+// sortExtResources sorts each run of consecutive ext_resource headers by id.
+func sortExtResources(blocks []*formatBlock) {
+	for start := 0; start < len(blocks); {
+		if !blocks[start].isExtResource() {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(blocks) && blocks[end].isExtResource() {
+			end++
+		}
+		run := blocks[start:end]
+		sort.SliceStable(run, func(i, j int) bool {
+			return idLess(headerAttr(run[i].header, "id"), headerAttr(run[j].header, "id"))
+		})
+		start = end
+	}
+}
+
+// idLess orders resource ids by their numeric prefix, so that "2_abc" comes
+// before "10_def", then as strings.
+func idLess(a, b string) bool {
+	na, okA := leadingNumber(a)
+	nb, okB := leadingNumber(b)
+	if okA && okB && na != nb {
+		return na < nb
+	}
+	return a < b
+}
+
+// leadingNumber parses the digits an id starts with.
+func leadingNumber(id string) (int, bool) {
+	end := 0
+	for end < len(id) && id[end] >= '0' && id[end] <= '9' {
+		end++
+	}
+	n, err := strconv.Atoi(id[:end])
+	return n, err == nil
+}
+
+// formatProperties writes properties as "key = value", padding the keys to
+// the same width when align is set. Comments are kept as is.
+func formatProperties(lines []string, align bool) []string {
+	width := 0
+	if align {
+		for _, line := range lines {
+			if key, _, ok := splitProperty(line); ok {
+				width = max(width, len(key))
+			}
+		}
+	}
+
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		key, value, ok := splitProperty(line)
+		if !ok {
+			out[i] = line
+			continue
+		}
+		out[i] = key + strings.Repeat(" ", max(width-len(key), 0)) + " ="
+		if value != "" {
+			out[i] += " " + value
+		}
+	}
+	return out
+}
+
+// splitProperty splits a "key = value" line.
+func splitProperty(line string) (key, value string, ok bool) {
+	if strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(line, "=")
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(key), strings.TrimSpace(value), true
+}
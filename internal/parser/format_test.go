@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSectionLayout(t *testing.T) {
+	input := "[gd_scene  load_steps = 3 format=3]\r\n" +
+		"[ext_resource type=\"Script\" path=\"res://b.gd\" id=\"10_b\"]\n\n" +
+		"[ext_resource   type=\"Texture2D\" path=\"res://a.png\"  id=\"2_a\" ]\n" +
+		"[sub_resource type=\"Gradient\" id=\"Gradient_1\"]\n" +
+		"colors=PackedColorArray(0, 0, 0, 1)\n\n\n" +
+		"; The root node\n" +
+		"[node name=\"Root\" type=\"Node2D\" groups=[\"a\", \"b\"]]\n" +
+		"  script = ExtResource(\"10_b\")   \n\n" +
+		"; Hidden until ready\n" +
+		"visible=false\n" +
+		"[node name=\"Child\" type=\"Sprite2D\" parent=\".\"]\n"
+	expected := "[gd_scene load_steps=3 format=3]\n\n" +
+		"[ext_resource type=\"Texture2D\" path=\"res://a.png\" id=\"2_a\"]\n" +
+		"[ext_resource type=\"Script\" path=\"res://b.gd\" id=\"10_b\"]\n\n" +
+		"[sub_resource type=\"Gradient\" id=\"Gradient_1\"]\n" +
+		"colors = PackedColorArray(0, 0, 0, 1)\n\n" +
+		"; The root node\n" +
+		"[node name=\"Root\" type=\"Node2D\" groups=[\"a\", \"b\"]]\n" +
+		"script = ExtResource(\"10_b\")\n" +
+		"; Hidden until ready\n" +
+		"visible = false\n\n" +
+		"[node name=\"Child\" type=\"Sprite2D\" parent=\".\"]\n"
+
+	if got := Format(input, FormatOptions{}); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+	if got := Format(expected, FormatOptions{}); got != expected {
+		t.Errorf("expected formatted input to be unchanged, got:\n%s", got)
+	}
+}
+
+func TestFormatKeepsMultilineValues(t *testing.T) {
+	input := "[resource]\n" +
+		"text = \"line one  \n\n[not a header]\nkey=value\"\n" +
+		"data={\n\"a\": 1,\n  \"b\": [2, 3]\n}\n"
+	expected := "[resource]\n" +
+		"text = \"line one  \n\n[not a header]\nkey=value\"\n" +
+		"data = {\n\"a\": 1,\n  \"b\": [2, 3]\n}\n"
+
+	if got := Format(input, FormatOptions{}); got != expected {
+		t.Errorf("unexpected output:\n%q\nexpected:\n%q", got, expected)
+	}
+}
+
+func TestFormatAlignProperties(t *testing.T) {
+	input := "[node name=\"Root\" type=\"Label\"]\ntext = \"Hi\"\nhorizontal_alignment = 1\n; note\nvisible = false\n"
+	expected := "[node name=\"Root\" type=\"Label\"]\n" +
+		"text                 = \"Hi\"\n" +
+		"horizontal_alignment = 1\n" +
+		"; note\n" +
+		"visible              = false\n"
+
+	if got := Format(input, FormatOptions{AlignProperties: true}); got != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", got, expected)
+	}
+	if got := Format("", FormatOptions{}); got != "" {
+		t.Errorf("expected empty output, got %q", got)
+	}
+}
+
+func TestFormatKeepsLineEndings(t *testing.T) {
+	input := "\uFEFF[gd_scene format=3]\r\n[node name=\"Root\" type=\"Node2D\"]\r\nvisible=false\r\n"
+	expected := "\uFEFF[gd_scene format=3]\r\n\r\n[node name=\"Root\" type=\"Node2D\"]\r\nvisible = false\r\n"
+	if got := Format(input, FormatOptions{}); got != expected {
+		t.Errorf("expected CRLF line endings and the byte order mark to be kept, got %q", got)
+	}
+
+	// Mixed line endings use the most common one
+	mixed := "[gd_scene format=3]\r\n\r\n[node name=\"Root\" type=\"Node2D\"]\nvisible = false\r\n"
+	if got := Format(mixed, FormatOptions{}); strings.Count(got, "\r\n") != strings.Count(got, "\n") {
+		t.Errorf("expected only CRLF line endings, got %q", got)
+	}
+	mixed = "[gd_scene format=3]\n\n[node name=\"Root\" type=\"Node2D\"]\r\nvisible = false\n"
+	if got := Format(mixed, FormatOptions{}); strings.Contains(got, "\r") {
+		t.Errorf("expected only LF line endings, got %q", got)
+	}
+}
@@ -15,13 +15,25 @@ type Lexer struct {
 	start       int // start position of current token
 	startLine   int
 	startColumn int
+
+	elide      bool // skip the arguments of large numeric packed arrays
+	packedOpen bool // a numeric packed array name was just scanned
+	packedArgs bool // the '(' of a numeric packed array was just scanned
 }
 
+// byteOrderMark starts files saved by some Windows editors. It is skipped
+// without counting as a column, as editors do not show it.
+const byteOrderMark = "\uFEFF"
+
 // NewLexer creates a new lexer for the given input.
 func NewLexer(input string) *Lexer {
+	pos := 0
+	if strings.HasPrefix(input, byteOrderMark) {
+		pos = len(byteOrderMark)
+	}
 	return &Lexer{
 		input:  input,
-		pos:    0,
+		pos:    pos,
 		line:   0,
 		column: 0,
 	}
@@ -29,6 +41,12 @@ func NewLexer(input string) *Lexer {
 
 // Next returns the next token.
 func (l *Lexer) Next() Token {
+	if l.packedArgs {
+		l.packedArgs = false
+		if tok, ok := l.scanElided(); ok {
+			return tok
+		}
+	}
 	l.skipWhitespace()
 
 	l.start = l.pos
@@ -51,6 +69,7 @@ func (l *Lexer) Next() Token {
 		return l.makeToken(TokenRBracket, "]")
 	case '(':
 		l.advance()
+		l.packedArgs, l.packedOpen = l.packedOpen, false
 		return l.makeToken(TokenLParen, "(")
 	case ')':
 		l.advance()
@@ -76,6 +95,11 @@ func (l *Lexer) Next() Token {
 	case '\n':
 		l.advance()
 		return l.makeToken(TokenNewline, "\n")
+	case '\r':
+		// Only the "\r" of a "\r\n" is left by skipWhitespace
+		l.advance()
+		l.advance()
+		return l.makeToken(TokenNewline, "\n")
 	case ';':
 		return l.scanComment()
 	case '"':
@@ -117,7 +141,12 @@ func (l *Lexer) Next() Token {
 
 // Tokenize returns all tokens from the input.
 func (l *Lexer) Tokenize() []Token {
-	var tokens []Token
+	return l.appendTokens(make([]Token, 0, estimateTokens(l.input)))
+}
+
+// appendTokens appends all tokens from the input to tokens, so that the
+// parser can reuse the slice of a previous file.
+func (l *Lexer) appendTokens(tokens []Token) []Token {
 	for {
 		tok := l.Next()
 		tokens = append(tokens, tok)
@@ -129,6 +158,13 @@ func (l *Lexer) Tokenize() []Token {
 	return tokens
 }
 
+// estimateTokens guesses the number of tokens of input, to size the token
+// slice once: scenes average a token every four to five bytes, and more in
+// animation tracks.
+func estimateTokens(input string) int {
+	return len(input)/4 + 16
+}
+
 func (l *Lexer) peek() byte {
 	if l.pos >= len(l.input) {
 		return 0
@@ -143,6 +179,17 @@ func (l *Lexer) peekN(n int) byte {
 	return l.input[l.pos+n]
 }
 
+// atLineEnd reports whether the lexer is at a line break, "\n" or "\r\n".
+func (l *Lexer) atLineEnd() bool {
+	switch l.peek() {
+	case '\n':
+		return true
+	case '\r':
+		return l.peekN(1) == '\n'
+	}
+	return false
+}
+
 func (l *Lexer) advance() byte {
 	if l.pos >= len(l.input) {
 		return 0
@@ -161,7 +208,7 @@ func (l *Lexer) advance() byte {
 func (l *Lexer) skipWhitespace() {
 	for l.pos < len(l.input) {
 		ch := l.peek()
-		if ch == ' ' || ch == '\t' || ch == '\r' {
+		if ch == ' ' || ch == '\t' || (ch == '\r' && !l.atLineEnd()) {
 			l.advance()
 		} else {
 			break
@@ -183,7 +230,7 @@ func (l *Lexer) makeToken(typ TokenType, value string) Token {
 func (l *Lexer) scanComment() Token {
 	l.advance() // consume ';'
 	start := l.pos
-	for l.pos < len(l.input) && l.peek() != '\n' {
+	for l.pos < len(l.input) && !l.atLineEnd() {
 		l.advance()
 	}
 	return l.makeToken(TokenComment, l.input[start:l.pos])
@@ -191,8 +238,16 @@ func (l *Lexer) scanComment() Token {
 
 func (l *Lexer) scanString() Token {
 	l.advance() // consume opening '"'
-	var builder strings.Builder
 
+	// Most strings have no escapes, so their value is a slice of the input
+	start := l.pos
+	if end := strings.IndexAny(l.input[start:], "\"\\\n"); end >= 0 && l.input[start+end] == '"' {
+		l.pos += end + 1
+		l.column += end + 1
+		return l.makeToken(TokenString, l.input[start:start+end])
+	}
+
+	var builder strings.Builder
 	for l.pos < len(l.input) {
 		ch := l.peek()
 		if ch == '"' {
@@ -220,7 +275,11 @@ func (l *Lexer) scanString() Token {
 					builder.WriteByte(escaped)
 				}
 			}
-		case '\n':
+		case '\n', '\r':
+			if !l.atLineEnd() {
+				builder.WriteByte(l.advance())
+				continue
+			}
 			// Unterminated string
 			return l.makeToken(TokenError, "unterminated string")
 		default:
@@ -256,7 +315,8 @@ func (l *Lexer) scanNumber() Token {
 			l.advance()
 		}
 	} else if l.peek() == '.' && !hasDigits {
-		// Just a dot, not a number
+		// Just a dot, not a number; consume it so the lexer makes progress
+		l.advance()
 		return l.makeToken(TokenError, "invalid number")
 	}
 
@@ -315,9 +375,79 @@ func (l *Lexer) scanIdentifier() Token {
 		return l.makeToken(TokenNumber, value)
 	}
 
+	l.packedOpen = l.elide && numericPackedArrays[value] && l.peek() == '('
 	return l.makeToken(TokenIdent, value)
 }
 
+// numericPackedArrays are the packed array types whose arguments are all
+// numbers, which baked meshes and animations fill with millions of values.
+// The Godot 3 names are those of the meshes in exported .escn scenes.
+var numericPackedArrays = map[string]bool{
+	"PackedByteArray":    true,
+	"PackedInt32Array":   true,
+	"PackedInt64Array":   true,
+	"PackedFloat32Array": true,
+	"PackedFloat64Array": true,
+	"PackedVector2Array": true,
+	"PackedVector3Array": true,
+	"PackedVector4Array": true,
+	"PackedColorArray":   true,
+
+	"PoolByteArray":    true,
+	"PoolIntArray":     true,
+	"PoolRealArray":    true,
+	"PoolVector2Array": true,
+	"PoolVector3Array": true,
+	"PoolColorArray":   true,
+	"ByteArray":        true,
+	"IntArray":         true,
+	"FloatArray":       true,
+	"Vector2Array":     true,
+	"Vector3Array":     true,
+	"ColorArray":       true,
+}
+
+// elidedSize is the size of the arguments above which a numeric packed
+// array is elided, so that small arrays in large files are still parsed.
+const elidedSize = 4 << 10
+
+// scanElided skips the arguments of a numeric packed array up to its ')',
+// returning a single token that covers them. It only applies to arguments
+// larger than elidedSize made of numbers, commas and whitespace.
+func (l *Lexer) scanElided() (Token, bool) {
+	end := strings.IndexByte(l.input[l.pos:], ')')
+	if end < elidedSize {
+		return Token{}, false
+	}
+	args := l.input[l.pos : l.pos+end]
+	for i := 0; i < len(args); i++ {
+		if !isElidedByte(args[i]) {
+			return Token{}, false
+		}
+	}
+
+	l.start = l.pos
+	l.startLine = l.line
+	l.startColumn = l.column
+	if lines := strings.Count(args, "\n"); lines > 0 {
+		l.line += lines
+		l.column = len(args) - strings.LastIndexByte(args, '\n') - 1
+	} else {
+		l.column += len(args)
+	}
+	l.pos += end
+	return l.makeToken(TokenElided, ""), true
+}
+
+// isElidedByte reports whether ch may appear in elided arguments.
+func isElidedByte(ch byte) bool {
+	switch ch {
+	case ',', ' ', '\t', '\r', '\n', '-', '+', '.', 'e', 'E', 'i', 'n', 'f', 'a':
+		return true
+	}
+	return ch >= '0' && ch <= '9'
+}
+
 func isIdentStart(ch byte) bool {
 	return ch >= 'a' && ch <= 'z' ||
 		ch >= 'A' && ch <= 'Z' ||
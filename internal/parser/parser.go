@@ -1,23 +1,67 @@
 package parser
 
 import (
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 // Parser parses TSCN tokens into an AST.
 type Parser struct {
+	input   string
 	tokens  []Token
 	pos     int
 	doc     *Document
 	current Token
+
+	// Animation tracks hold many thousands of values, so numbers and typed
+	// values are allocated in blocks, and the elements of arrays and
+	// arguments are gathered on a shared stack before being copied into a
+	// slice of the right size.
+	numbers []NumberValue
+	typed   []TypedValue
+	stack   []Value
 }
 
+// tokenPool holds the token slices of finished parses. The AST keeps no
+// tokens, so a slice is reused by the next parse instead of growing a new
+// one for every keystroke on a large scene.
+var tokenPool sync.Pool
+
+// LargeFileSize is the size above which the arguments of large numeric
+// packed arrays, such as the vertices of baked meshes, are counted rather
+// than parsed: their TypedValue has only a range and an Elided count.
+const LargeFileSize = 8 << 20
+
 // Parse parses TSCN source code and returns a Document.
 func Parse(input string) *Document {
+	p := newParser(input)
+	defer p.release()
+	p.parse()
+	return p.doc
+}
+
+// newParser tokenizes input and returns a parser positioned at its first
+// token. The parser must be released once parsing is done.
+func newParser(input string) *Parser {
+	size := estimateTokens(input)
+	large := len(input) > LargeFileSize
+	if large {
+		size = min(size, 1<<16) // Most of a large file is elided
+	}
+	var tokens []Token
+	if buf, ok := tokenPool.Get().(*[]Token); ok && cap(*buf) >= size {
+		tokens = *buf
+	} else {
+		tokens = make([]Token, 0, size)
+	}
 	lexer := NewLexer(input)
-	tokens := lexer.Tokenize()
+	lexer.elide = large
+	tokens = lexer.appendTokens(tokens)
 
 	p := &Parser{
+		input:  input,
 		tokens: tokens,
 		pos:    0,
 		doc: &Document{
@@ -33,9 +77,7 @@ func Parse(input string) *Document {
 	if len(tokens) > 0 {
 		p.current = tokens[0]
 	}
-
-	p.parse()
-	return p.doc
+	return p
 }
 
 func (p *Parser) parse() {
@@ -124,23 +166,19 @@ func (p *Parser) parseGdScene(startToken Token, sectionType string) {
 				if p.current.Type == TokenNumber {
 					val, _ := strconv.Atoi(p.current.Value)
 					gd.LoadSteps = &val
+					gd.LoadStepsRange = p.makeRange(p.current)
 					p.advance()
 				}
 			case "format":
 				if p.current.Type == TokenNumber {
 					gd.Format, _ = strconv.Atoi(p.current.Value)
+					gd.FormatRange = p.makeRange(p.current)
 					p.advance()
 				}
 			case "uid":
-				if p.current.Type == TokenString {
-					gd.UID = p.current.Value
-					p.advance()
-				}
+				gd.UID, gd.UIDRange = p.attributeString(false)
 			case "type":
-				if p.current.Type == TokenString {
-					gd.ResourceType = p.current.Value
-					p.advance()
-				}
+				gd.ResourceType, _ = p.attributeString(false)
 			default:
 				// Skip unknown attributes
 				p.parseValue()
@@ -179,26 +217,13 @@ func (p *Parser) parseExtResource(startToken Token) {
 
 			switch key {
 			case "type":
-				if p.current.Type == TokenString {
-					ext.Type = p.current.Value
-					p.advance()
-				}
+				ext.Type, ext.TypeRange = p.attributeString(false)
 			case "uid":
-				if p.current.Type == TokenString {
-					ext.UID = p.current.Value
-					p.advance()
-				}
+				ext.UID, ext.UIDRange = p.attributeString(false)
 			case "path":
-				if p.current.Type == TokenString {
-					ext.Path = p.current.Value
-					ext.PathRange = p.makeRange(p.current)
-					p.advance()
-				}
+				ext.Path, ext.PathRange = p.attributeString(false)
 			case "id":
-				if p.current.Type == TokenString {
-					ext.ID = p.current.Value
-					p.advance()
-				}
+				ext.ID, ext.IDRange = p.attributeString(true)
 			default:
 				p.parseValue()
 			}
@@ -238,15 +263,9 @@ func (p *Parser) parseSubResource(startToken Token) {
 
 			switch key {
 			case "type":
-				if p.current.Type == TokenString {
-					sub.Type = p.current.Value
-					p.advance()
-				}
+				sub.Type, sub.TypeRange = p.attributeString(false)
 			case "id":
-				if p.current.Type == TokenString {
-					sub.ID = p.current.Value
-					p.advance()
-				}
+				sub.ID, sub.IDRange = p.attributeString(true)
 			default:
 				p.parseValue()
 			}
@@ -256,6 +275,10 @@ func (p *Parser) parseSubResource(startToken Token) {
 	}
 
 	endToken := p.current
+	sub.HeaderRange = Range{
+		Start: Position{Line: startToken.Line, Column: startToken.Column, Offset: startToken.Offset},
+		End:   Position{Line: endToken.Line, Column: endToken.Column + endToken.Length, Offset: endToken.Offset + endToken.Length},
+	}
 	if p.current.Type == TokenRBracket {
 		p.advance()
 	}
@@ -316,32 +339,17 @@ func (p *Parser) parseNode(startToken Token) {
 
 			switch key {
 			case "name":
-				if p.current.Type == TokenString {
-					node.Name = p.current.Value
-					p.advance()
-				}
+				node.Name, node.NameRange = p.attributeString(false)
 			case "type":
-				if p.current.Type == TokenString {
-					node.Type = p.current.Value
-					p.advance()
-				}
+				node.Type, node.TypeRange = p.attributeString(false)
 			case "parent":
-				if p.current.Type == TokenString {
-					node.Parent = p.current.Value
-					p.advance()
-				}
+				node.Parent, node.ParentRange = p.attributeString(false)
 			case "instance":
 				node.Instance = p.parseValue()
 			case "instance_placeholder":
-				if p.current.Type == TokenString {
-					node.InstancePlaceholder = p.current.Value
-					p.advance()
-				}
+				node.InstancePlaceholder, _ = p.attributeString(false)
 			case "owner":
-				if p.current.Type == TokenString {
-					node.Owner = p.current.Value
-					p.advance()
-				}
+				node.Owner, _ = p.attributeString(false)
 			case "index":
 				if p.current.Type == TokenNumber {
 					val, _ := strconv.Atoi(p.current.Value)
@@ -354,6 +362,7 @@ func (p *Parser) parseNode(startToken Token) {
 					for _, v := range arr.Values {
 						if sv, ok := v.(*StringValue); ok {
 							node.Groups = append(node.Groups, sv.Value)
+							node.GroupRanges = append(node.GroupRanges, sv.Range)
 						}
 					}
 				}
@@ -424,29 +433,17 @@ func (p *Parser) parseConnection(startToken Token) {
 
 			switch key {
 			case "signal":
-				if p.current.Type == TokenString {
-					conn.Signal = p.current.Value
-					p.advance()
-				}
+				conn.Signal, _ = p.attributeString(false)
 			case "from":
-				if p.current.Type == TokenString {
-					conn.From = p.current.Value
-					p.advance()
-				}
+				conn.From, conn.FromRange = p.attributeString(false)
 			case "to":
-				if p.current.Type == TokenString {
-					conn.To = p.current.Value
-					p.advance()
-				}
+				conn.To, conn.ToRange = p.attributeString(false)
 			case "method":
-				if p.current.Type == TokenString {
-					conn.Method = p.current.Value
-					p.advance()
-				}
+				conn.Method, conn.MethodRange = p.attributeString(false)
 			case "flags":
 				if p.current.Type == TokenNumber {
 					val, _ := strconv.Atoi(p.current.Value)
-					conn.Flags = &val
+					conn.Flags, conn.FlagsRange = &val, p.makeRange(p.current)
 					p.advance()
 				}
 			case "binds":
@@ -475,15 +472,20 @@ func (p *Parser) parseConnection(startToken Token) {
 }
 
 func (p *Parser) parseResourceSection(startToken Token) {
+	res := &Resource{
+		Properties: []*Property{},
+	}
+
 	// Skip to ]
 	for p.current.Type != TokenRBracket && !p.isAtEnd() {
 		p.advance()
 	}
+	endToken := p.current
 	if p.current.Type == TokenRBracket {
 		p.advance()
 	}
 
-	// Parse properties - they become part of the document's resources
+	// Parse properties until next section
 	p.skipNewlines()
 resPropLoop:
 	for !p.isAtEnd() && p.current.Type != TokenLBracket {
@@ -491,13 +493,33 @@ resPropLoop:
 		case TokenComment:
 			p.parseComment()
 		case TokenIdent:
-			p.parseProperty()
+			prop := p.parseProperty()
+			if prop != nil {
+				res.Properties = append(res.Properties, prop)
+			}
 		case TokenNewline:
 			p.advance()
 		default:
 			break resPropLoop
 		}
 	}
+
+	// Update end range to include properties
+	if len(res.Properties) > 0 {
+		lastProp := res.Properties[len(res.Properties)-1]
+		endToken = Token{
+			Line:   lastProp.Range.End.Line,
+			Column: lastProp.Range.End.Column,
+			Offset: lastProp.Range.End.Offset,
+		}
+	}
+
+	res.Range = Range{
+		Start: Position{Line: startToken.Line, Column: startToken.Column, Offset: startToken.Offset},
+		End:   Position{Line: endToken.Line, Column: endToken.Column + endToken.Length, Offset: endToken.Offset + endToken.Length},
+	}
+
+	p.doc.Resource = res
 }
 
 func (p *Parser) parseProperty() *Property {
@@ -507,22 +529,33 @@ func (p *Parser) parseProperty() *Property {
 
 	keyStart := Position{Line: p.current.Line, Column: p.current.Column, Offset: p.current.Offset}
 
-	// Parse key (can include slashes for paths like "bones/1/position")
-	var key string
+	// Parse key (can include slashes for paths like "bones/1/position").
+	// Keys are written without spaces, so they are sliced from the input,
+	// and only joined from their tokens otherwise.
+	contiguous := true
+	end := p.current.Offset
+	first := p.pos
 keyLoop:
 	for {
 		switch p.current.Type {
-		case TokenIdent, TokenNumber:
-			key += p.current.Value
-			p.advance()
-		case TokenSlash:
-			key += "/"
+		case TokenIdent, TokenNumber, TokenSlash:
+			contiguous = contiguous && p.current.Offset == end
+			end = p.current.Offset + p.current.Length
 			p.advance()
 		default:
 			break keyLoop
 		}
 	}
 
+	key := p.input[keyStart.Offset:end]
+	if !contiguous {
+		var b strings.Builder
+		for _, tok := range p.tokens[first:p.pos] {
+			b.WriteString(tok.Value)
+		}
+		key = b.String()
+	}
+
 	keyEnd := Position{Line: p.prevToken().Line, Column: p.prevToken().Column + p.prevToken().Length, Offset: p.prevToken().Offset + p.prevToken().Length}
 
 	if p.current.Type != TokenEquals {
@@ -560,8 +593,9 @@ func (p *Parser) parseValue() Value {
 	case TokenNumber:
 		rawVal := p.current.Value
 		floatVal, _ := strconv.ParseFloat(rawVal, 64)
-		isInt := !containsAny(rawVal, ".eE") && rawVal != "inf" && rawVal != "nan" && rawVal != "-inf"
-		val := &NumberValue{
+		isInt := !strings.ContainsAny(rawVal, ".eE") && rawVal != "inf" && rawVal != "nan" && rawVal != "-inf"
+		val := alloc(&p.numbers)
+		*val = NumberValue{
 			Range:    p.makeRange(p.current),
 			Value:    floatVal,
 			IsInt:    isInt,
@@ -603,7 +637,7 @@ func (p *Parser) parseArray() Value {
 	startToken := p.current
 	p.advance() // consume '['
 
-	values := []Value{}
+	mark := len(p.stack)
 	for p.current.Type != TokenRBracket && !p.isAtEnd() {
 		p.skipNewlines()
 		if p.current.Type == TokenRBracket {
@@ -612,7 +646,7 @@ func (p *Parser) parseArray() Value {
 
 		val := p.parseValue()
 		if val != nil {
-			values = append(values, val)
+			p.stack = append(p.stack, val)
 		} else {
 			// parseValue returned nil - skip this token to avoid infinite loop
 			p.advance()
@@ -624,6 +658,7 @@ func (p *Parser) parseArray() Value {
 		}
 	}
 
+	values := p.popValues(mark)
 	endToken := p.current
 	if p.current.Type == TokenRBracket {
 		p.advance()
@@ -723,13 +758,38 @@ func (p *Parser) parseTypedValueOrIdent() Value {
 	typeRange := p.makeRange(p.current)
 	p.advance()
 
+	// Typed collections name their element types: Array[int]([1, 2]),
+	// Array[ExtResource("1_a")]([...]) or Dictionary[String, int]({...})
+	var typeParams []Value
+	if (name == "Array" || name == "Dictionary") && p.current.Type == TokenLBracket {
+		p.advance() // consume '['
+		for p.current.Type != TokenRBracket && !p.isAtEnd() {
+			val := p.parseValue()
+			if val != nil {
+				typeParams = append(typeParams, val)
+			} else if p.current.Type != TokenRBracket && p.current.Type != TokenComma {
+				p.advance()
+			}
+			if p.current.Type == TokenComma {
+				p.advance()
+			}
+		}
+		if p.current.Type == TokenRBracket {
+			p.advance()
+		}
+		if p.current.Type != TokenLParen {
+			p.addError("expected '(' after typed " + name)
+		}
+	}
+
 	// Check if it's a function call (typed value)
 	if p.current.Type == TokenLParen {
 		p.advance() // consume '('
 
 		// Special case for ExtResource and SubResource
 		if name == "ExtResource" || name == "SubResource" {
-			if p.current.Type == TokenString {
+			// Numeric ids, as in SubResource(1), are from format=2 scenes
+			if p.current.Type == TokenString || p.current.Type == TokenNumber {
 				idRange := p.makeRange(p.current)
 				id := p.current.Value
 				p.advance()
@@ -752,12 +812,20 @@ func (p *Parser) parseTypedValueOrIdent() Value {
 			}
 		}
 
+		// Arguments skipped in a large file are counted from their text
+		elided := 0
+		if p.current.Type == TokenElided {
+			args := p.input[p.current.Offset : p.current.Offset+p.current.Length]
+			elided = strings.Count(args, ",") + 1
+			p.advance()
+		}
+
 		// Regular typed value
-		args := []Value{}
+		mark := len(p.stack)
 		for p.current.Type != TokenRParen && !p.isAtEnd() {
 			val := p.parseValue()
 			if val != nil {
-				args = append(args, val)
+				p.stack = append(p.stack, val)
 			} else {
 				// parseValue returned nil - skip this token to avoid infinite loop
 				if p.current.Type != TokenRParen && p.current.Type != TokenComma {
@@ -770,21 +838,26 @@ func (p *Parser) parseTypedValueOrIdent() Value {
 			}
 		}
 
+		args := p.popValues(mark)
 		endToken := p.current
 		if p.current.Type == TokenRParen {
 			p.advance()
 			endToken = p.prevToken()
 		}
 
-		return &TypedValue{
+		val := alloc(&p.typed)
+		*val = TypedValue{
 			Range: Range{
 				Start: Position{Line: startToken.Line, Column: startToken.Column, Offset: startToken.Offset},
 				End:   Position{Line: endToken.Line, Column: endToken.Column + endToken.Length, Offset: endToken.Offset + endToken.Length},
 			},
-			TypeName:  name,
-			TypeRange: typeRange,
-			Arguments: args,
+			TypeName:   name,
+			TypeRange:  typeRange,
+			TypeParams: typeParams,
+			Arguments:  args,
+			Elided:     elided,
 		}
+		return val
 	}
 
 	// Plain identifier
@@ -794,6 +867,20 @@ func (p *Parser) parseTypedValueOrIdent() Value {
 	}
 }
 
+// attributeString consumes the value of a section attribute and returns it
+// when it is a string, or when number is set, a number: Godot 3 scenes
+// (format=2), like the .escn files exported from Blender, write their ids as
+// id=1. Other values are skipped, so that the rest of the header is parsed.
+func (p *Parser) attributeString(number bool) (string, Range) {
+	if p.current.Type == TokenString || (number && p.current.Type == TokenNumber) {
+		val, r := p.current.Value, p.makeRange(p.current)
+		p.advance()
+		return val, r
+	}
+	p.parseValue()
+	return "", Range{}
+}
+
 func (p *Parser) skipNewlines() {
 	for p.current.Type == TokenNewline || p.current.Type == TokenComment {
 		if p.current.Type == TokenComment {
@@ -813,6 +900,34 @@ func (p *Parser) skipToNextSection() {
 	}
 }
 
+// popValues removes the values gathered on the stack since mark and returns
+// them in a slice of their own.
+func (p *Parser) popValues(mark int) []Value {
+	values := slices.Clone(p.stack[mark:])
+	clear(p.stack[mark:])
+	p.stack = p.stack[:mark]
+	return values
+}
+
+// alloc returns a new value from a block, starting a larger block when the
+// current one is full.
+func alloc[T any](block *[]T) *T {
+	if len(*block) == cap(*block) {
+		*block = make([]T, 0, min(max(2*cap(*block), 8), 256))
+	}
+	*block = (*block)[:len(*block)+1]
+	return &(*block)[len(*block)-1]
+}
+
+// release returns the tokens of the parser to the pool. Their values are
+// cleared first, so that the pool does not keep the input alive.
+func (p *Parser) release() {
+	clear(p.tokens)
+	tokens := p.tokens[:0]
+	tokenPool.Put(&tokens)
+	p.tokens = nil
+}
+
 func (p *Parser) advance() {
 	if p.pos < len(p.tokens)-1 {
 		p.pos++
@@ -849,14 +964,3 @@ func (p *Parser) makeRange(tok Token) Range {
 		End:   Position{Line: tok.Line, Column: tok.Column + tok.Length, Offset: tok.Offset + tok.Length},
 	}
 }
-
-func containsAny(s string, chars string) bool {
-	for _, c := range chars {
-		for _, sc := range s {
-			if c == sc {
-				return true
-			}
-		}
-	}
-	return false
-}
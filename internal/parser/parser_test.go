@@ -1,6 +1,10 @@
 package parser
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -32,6 +36,15 @@ func TestParseWithUID(t *testing.T) {
 	if doc.Descriptor.LoadSteps == nil || *doc.Descriptor.LoadSteps != 4 {
 		t.Error("expected load_steps 4")
 	}
+	if r := doc.Descriptor.LoadStepsRange; r.Start.Column != 21 || r.End.Column != 22 {
+		t.Errorf("expected the load_steps value range, got %+v", r)
+	}
+	if r := doc.Descriptor.UIDRange; r.Start.Column != 36 || r.End.Column != 57 {
+		t.Errorf("expected the uid string range, got %+v", r)
+	}
+	if r := doc.Descriptor.FormatRange; r.Start.Column != 30 || r.End.Column != 31 {
+		t.Errorf("expected the format value range, got %+v", r)
+	}
 }
 
 func TestParseExtResource(t *testing.T) {
@@ -70,6 +83,9 @@ func TestParseExtResource(t *testing.T) {
 	if actualLen != pathLen {
 		t.Errorf("expected PathRange length to be %d (full quoted string), got %d", pathLen, actualLen)
 	}
+	if ext.TypeRange.Start.Column != 19 || ext.TypeRange.End.Column != 30 {
+		t.Errorf("expected TypeRange to span \"Texture2D\", got %v", ext.TypeRange)
+	}
 }
 
 func TestParseSubResource(t *testing.T) {
@@ -98,6 +114,32 @@ radius = 1.5`
 	}
 }
 
+func TestParseResourceSection(t *testing.T) {
+	input := `[gd_resource type="Theme" format=3]
+
+[resource]
+default_font_size = 14
+resource_name = "ui"`
+
+	doc := Parse(input)
+
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", doc.Errors)
+	}
+	if doc.Resource == nil {
+		t.Fatal("expected a [resource] section")
+	}
+	if len(doc.Resource.Properties) != 2 {
+		t.Fatalf("expected 2 properties, got %d", len(doc.Resource.Properties))
+	}
+	if doc.Resource.Properties[1].Key != "resource_name" {
+		t.Errorf("expected key resource_name, got %s", doc.Resource.Properties[1].Key)
+	}
+	if doc.Resource.Range.Start.Line != 2 || doc.Resource.Range.End.Line != 4 {
+		t.Errorf("expected the section to span lines 2-4, got %d-%d", doc.Resource.Range.Start.Line, doc.Resource.Range.End.Line)
+	}
+}
+
 func TestParseNode(t *testing.T) {
 	input := `[gd_scene format=3]
 [node name="Player" type="CharacterBody3D"]
@@ -213,6 +255,45 @@ path = NodePath("Parent/Child")`
 	}
 }
 
+func TestParseTypedCollections(t *testing.T) {
+	input := `[gd_scene format=3]
+[node name="A" type="Node"]
+names = Array[StringName]([&"a", &"b"])
+items = Array[ExtResource("1_item")]([])
+scores = Dictionary[String, int]({"a": 1})`
+
+	doc := Parse(input)
+
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", doc.Errors)
+	}
+	props := doc.Nodes[0].Properties
+	if len(props) != 3 {
+		t.Fatalf("expected 3 properties, got %d", len(props))
+	}
+
+	names, ok := props[0].Value.(*TypedValue)
+	if !ok || names.TypeName != "Array" || len(names.TypeParams) != 1 || len(names.Arguments) != 1 {
+		t.Fatalf("unexpected typed array: %+v", props[0].Value)
+	}
+	if ident, ok := names.TypeParams[0].(*IdentValue); !ok || ident.Name != "StringName" {
+		t.Errorf("expected StringName element type, got %+v", names.TypeParams[0])
+	}
+
+	items := props[1].Value.(*TypedValue)
+	if ref, ok := items.TypeParams[0].(*ResourceRef); !ok || ref.ID != "1_item" {
+		t.Errorf("expected a script element type, got %+v", items.TypeParams[0])
+	}
+
+	scores := props[2].Value.(*TypedValue)
+	if scores.TypeName != "Dictionary" || len(scores.TypeParams) != 2 {
+		t.Errorf("unexpected typed dictionary: %+v", scores)
+	}
+	if _, ok := scores.Arguments[0].(*DictValue); !ok {
+		t.Errorf("expected a dictionary argument, got %+v", scores.Arguments[0])
+	}
+}
+
 func TestParseResourceReferences(t *testing.T) {
 	input := `[gd_scene format=3]
 [ext_resource type="Script" path="res://script.gd" id="1_abc"]
@@ -259,6 +340,39 @@ shape = SubResource("BoxShape_123")`
 	}
 }
 
+func TestParseFormat2Scene(t *testing.T) {
+	// Godot 3 scenes, like the .escn files exported from Blender, use
+	// numeric ids; unknown attributes are skipped whatever their value
+	input := `[gd_scene load_steps=2 format=2]
+[ext_resource id=1 path="res://grid.png" type="Texture"]
+[sub_resource id=2 type="SpatialMaterial" extra=[1, 2]]
+albedo_texture = ExtResource(1)
+[node type="Spatial" name="Root" custom={"a": 1}]
+[node name="Mesh" type="MeshInstance" parent="."]
+material_override = SubResource(2)`
+
+	doc := Parse(input)
+
+	if len(doc.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", doc.Errors)
+	}
+	if len(doc.ExtResources) != 1 || doc.ExtResources[0].ID != "1" || doc.ExtResources[0].Type != "Texture" {
+		t.Errorf("unexpected ext_resources: %+v", doc.ExtResources)
+	}
+	if len(doc.SubResources) != 1 || doc.SubResources[0].ID != "2" || doc.SubResources[0].Type != "SpatialMaterial" {
+		t.Fatalf("unexpected sub_resources: %+v", doc.SubResources)
+	}
+	if ref, ok := doc.SubResources[0].Properties[0].Value.(*ResourceRef); !ok || ref.ID != "1" {
+		t.Errorf("expected a reference to ext_resource 1, got %#v", doc.SubResources[0].Properties[0].Value)
+	}
+	if len(doc.Nodes) != 2 || doc.Nodes[0].Name != "Root" || doc.Nodes[0].Type != "Spatial" {
+		t.Fatalf("unexpected nodes: %+v", doc.Nodes)
+	}
+	if ref, ok := doc.Nodes[1].Properties[0].Value.(*ResourceRef); !ok || ref.ID != "2" {
+		t.Errorf("expected a reference to sub_resource 2, got %#v", doc.Nodes[1].Properties[0].Value)
+	}
+}
+
 func TestParseArray(t *testing.T) {
 	input := `[gd_scene format=3]
 [node name="Root" type="Node"]
@@ -336,13 +450,14 @@ func TestParseSlashedProperty(t *testing.T) {
 	input := `[gd_scene format=3]
 [node name="Skeleton" type="Skeleton3D"]
 bones/0/position = Vector3(0, 1, 0)
-bones/0/rotation = Quaternion(0, 0, 0, 1)`
+bones/0/rotation = Quaternion(0, 0, 0, 1)
+bones / 1/scale = Vector3(1, 1, 1)`
 
 	doc := Parse(input)
 
 	node := doc.Nodes[0]
-	if len(node.Properties) != 2 {
-		t.Fatalf("expected 2 properties, got %d", len(node.Properties))
+	if len(node.Properties) != 3 {
+		t.Fatalf("expected 3 properties, got %d", len(node.Properties))
 	}
 
 	if node.Properties[0].Key != "bones/0/position" {
@@ -351,6 +466,10 @@ bones/0/rotation = Quaternion(0, 0, 0, 1)`
 	if node.Properties[1].Key != "bones/0/rotation" {
 		t.Errorf("expected bones/0/rotation, got %s", node.Properties[1].Key)
 	}
+	// Spaces are dropped from keys
+	if node.Properties[2].Key != "bones/1/scale" {
+		t.Errorf("expected bones/1/scale, got %s", node.Properties[2].Key)
+	}
 }
 
 func TestParseBoolAndNull(t *testing.T) {
@@ -590,3 +709,154 @@ tracks/0/keys = {
 		t.Logf("Parse had %d errors: %v", len(doc.Errors), doc.Errors)
 	}
 }
+
+// animationScene generates a scene with an animation of many keyed tracks,
+// the bulk of the largest scenes of real projects.
+func animationScene(tracks, keys int) string {
+	var b strings.Builder
+	b.WriteString("[gd_scene load_steps=2 format=3]\n\n[sub_resource type=\"Animation\" id=\"Animation_walk\"]\nlength = 2.0\n")
+	for t := range tracks {
+		fmt.Fprintf(&b, "tracks/%d/type = \"value\"\ntracks/%d/path = NodePath(\"Skeleton:bones/%d\")\n", t, t, t)
+		fmt.Fprintf(&b, "tracks/%d/keys = {\n\"times\": PackedFloat32Array(", t)
+		for k := range keys {
+			if k > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%g", float64(k)*0.0333)
+		}
+		b.WriteString("),\n\"update\": 0,\n\"values\": [")
+		for k := range keys {
+			if k > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "Vector3(%d.5, -%d.25, 1e-3)", k, t)
+		}
+		b.WriteString("]\n}\n")
+	}
+	b.WriteString("\n[node name=\"Root\" type=\"Node3D\"]\n\n[node name=\"AnimationPlayer\" type=\"AnimationPlayer\" parent=\".\"]\n")
+	return b.String()
+}
+
+func TestParseGeneratedAnimation(t *testing.T) {
+	doc := Parse(animationScene(3, 4))
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", doc.Errors)
+	}
+	props := doc.SubResources[0].Properties
+	if len(props) != 10 || props[9].Key != "tracks/2/keys" {
+		t.Fatalf("unexpected properties: %d", len(props))
+	}
+	values := props[9].Value.(*DictValue).Entries[2].Value.(*ArrayValue).Values
+	if len(values) != 4 || values[3].(*TypedValue).Arguments[1].(*NumberValue).RawValue != "-2.25" {
+		t.Errorf("unexpected values: %+v", values)
+	}
+}
+
+func TestParseLargeFileElidesPackedArrays(t *testing.T) {
+	vertices := strings.Repeat("0.5, -1, 2e-3, ", LargeFileSize/15) + "0, 0, 0"
+	input := "[gd_resource type=\"ArrayMesh\" format=3]\n\n[resource]\n" +
+		"vertices = PackedVector3Array(" + vertices + ")\n" +
+		"indices = PackedInt32Array(0, 1, 2)\n" +
+		"name = \"Baked\"\n"
+	doc := Parse(input)
+	if len(doc.Errors) > 0 {
+		t.Fatalf("unexpected errors: %v", doc.Errors[0])
+	}
+
+	props := doc.Resource.Properties
+	if len(props) != 3 {
+		t.Fatalf("expected 3 properties, got %d", len(props))
+	}
+	packed := props[0].Value.(*TypedValue)
+	if packed.Arguments != nil || packed.ArgumentCount() != LargeFileSize/15*3+3 {
+		t.Errorf("expected %d elided arguments, got %d parsed and %d elided",
+			LargeFileSize/15*3+3, len(packed.Arguments), packed.Elided)
+	}
+	if end := packed.Range.End; end.Line != 3 || end.Offset != strings.Index(input, ")\n")+1 {
+		t.Errorf("unexpected range end: %+v", end)
+	}
+	if small := props[1].Value.(*TypedValue); len(small.Arguments) != 3 || small.Elided != 0 {
+		t.Errorf("expected a small packed array to be parsed, got %+v", small)
+	}
+	if props[2].Key != "name" || props[2].Range.Start.Line != 5 {
+		t.Errorf("unexpected property after the elided array: %+v", props[2])
+	}
+
+	// Small files keep every argument
+	doc = Parse("[resource]\nvertices = PackedVector3Array(" + vertices[:elidedSize*2] + "0)\n")
+	if small := doc.Resource.Properties[0].Value.(*TypedValue); small.Elided != 0 || len(small.Arguments) == 0 {
+		t.Errorf("expected arguments to be parsed below LargeFileSize, got %d elided", small.Elided)
+	}
+}
+
+func BenchmarkParseAnimation(b *testing.B) {
+	input := animationScene(200, 120)
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	for b.Loop() {
+		Parse(input)
+	}
+}
+
+// BenchmarkParseHextracer parses the scenes of the hextracer project, when
+// it is checked out under test/hextracer.
+func BenchmarkParseHextracer(b *testing.B) {
+	paths, _ := filepath.Glob(filepath.Join("..", "..", "test", "hextracer", "scenes", "*.tscn"))
+	if len(paths) == 0 {
+		b.Skip("hextracer test project not available")
+	}
+	var inputs []string
+	var size int64
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		inputs = append(inputs, string(content))
+		size += int64(len(content))
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, input := range inputs {
+			Parse(input)
+		}
+	}
+}
+
+func TestParseCRLFAndByteOrderMark(t *testing.T) {
+	raw, err := os.ReadFile("../../testdata/crlf.tscn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	crlf := string(raw)
+	if !strings.HasPrefix(crlf, byteOrderMark) || !strings.Contains(crlf, "\r\n") {
+		t.Fatal("expected the fixture to start with a byte order mark and use CRLF line endings")
+	}
+	lf := strings.ReplaceAll(strings.TrimPrefix(crlf, byteOrderMark), "\r\n", "\n")
+
+	// Tokens are at the same lines and columns, with the same values; only
+	// line breaks are longer
+	lfTokens, crlfTokens := NewLexer(lf).Tokenize(), NewLexer(crlf).Tokenize()
+	if len(lfTokens) != len(crlfTokens) {
+		t.Fatalf("expected %d tokens, got %d", len(lfTokens), len(crlfTokens))
+	}
+	for i, want := range lfTokens {
+		got := crlfTokens[i]
+		if want.Type == TokenNewline {
+			want.Length = 2
+		}
+		if got.Type != want.Type || got.Value != want.Value || got.Line != want.Line || got.Column != want.Column || got.Length != want.Length {
+			t.Errorf("token %d: expected %+v, got %+v", i, want, got)
+		}
+	}
+	if doc := Parse(crlf); len(doc.Errors) > 0 {
+		t.Errorf("unexpected errors: %v", doc.Errors)
+	}
+
+	// An unterminated string ends before the line break
+	tokens := NewLexer("key = \"open\r\nnext = 1\r\n").Tokenize()
+	if tokens[2].Type != TokenError || tokens[2].Length != len(`"open`) {
+		t.Errorf("expected an unterminated string of %d bytes, got %+v", len(`"open`), tokens[2])
+	}
+}
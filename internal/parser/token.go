@@ -30,6 +30,10 @@ const (
 	TokenNumber // 123, 1.5, -2.5e10, inf, nan
 	TokenBool   // true, false
 	TokenNull   // null
+
+	// TokenElided covers the arguments of a numeric packed array that the
+	// lexer skipped in a large file; see LargeFileSize.
+	TokenElided
 )
 
 // Token represents a lexical token.
@@ -83,6 +87,8 @@ func (t TokenType) String() string {
 		return "Bool"
 	case TokenNull:
 		return "Null"
+	case TokenElided:
+		return "Elided"
 	default:
 		return "Unknown"
 	}
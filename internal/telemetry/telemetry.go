@@ -0,0 +1,90 @@
+// Package telemetry provides optional OpenTelemetry tracing. Tracing is off
+// unless an OTLP endpoint is configured; until then every span is a no-op
+// and no exporter is started.
+package telemetry
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by gdls.
+const instrumentationName = "github.com/andresperezl/gdls"
+
+// endpointEnvVars are the standard variables that enable the OTLP exporter.
+var endpointEnvVars = []string{
+	"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+}
+
+// Config configures trace export.
+type Config struct {
+	Endpoint       string // OTLP/HTTP endpoint URL, e.g. http://localhost:4318
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Enabled reports whether an endpoint is set, either in the config or
+// through the standard OTEL_EXPORTER_OTLP_* environment variables.
+func (c Config) Enabled() bool {
+	if c.Endpoint != "" {
+		return true
+	}
+	for _, name := range endpointEnvVars {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Setup installs a tracer provider that batches spans to the configured
+// OTLP endpoint. The returned function flushes pending spans and stops the
+// exporter. When tracing is not enabled nothing is installed and the
+// returned function does nothing.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", cfg.ServiceName),
+			attribute.String("service.version", cfg.ServiceVersion),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of the span in ctx, if any.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends a span, marking it as failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
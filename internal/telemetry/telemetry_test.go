@@ -0,0 +1,48 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupDisabledByDefault(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	previous := otel.GetTracerProvider()
+	shutdown, err := Setup(context.Background(), Config{ServiceName: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != previous {
+		t.Error("expected no tracer provider to be installed without an endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown of disabled tracing failed: %v", err)
+	}
+
+	_, span := Start(context.Background(), "noop")
+	if span.SpanContext().IsValid() {
+		t.Error("expected spans to be no-ops while tracing is disabled")
+	}
+	span.End()
+}
+
+func TestConfigEnabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	if (Config{}).Enabled() {
+		t.Error("expected tracing to be off without an endpoint")
+	}
+	if !(Config{Endpoint: "http://localhost:4318"}).Enabled() {
+		t.Error("expected an endpoint in the config to enable tracing")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if !(Config{}).Enabled() {
+		t.Error("expected OTEL_EXPORTER_OTLP_ENDPOINT to enable tracing")
+	}
+}
@@ -0,0 +1,255 @@
+// Package bench benchmarks the language server on large inputs: the scenes
+// of the hextracer project when it is checked out under test/hextracer, a
+// 10MB scene of baked animations and a 2,000-line shader. It measures
+// parsing, analysis, and the latency of semantic tokens and hover requests
+// served over JSON-RPC. Run "task bench:compare" to compare a change with
+// the main branch.
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+	protocol "github.com/tliron/glsp/protocol_3_16"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/lsp"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// requestTimeout bounds each request, so that a hung server fails the
+// benchmark instead of blocking it.
+const requestTimeout = time.Minute
+
+func BenchmarkParse(b *testing.B) {
+	b.Run("hextracer", func(b *testing.B) {
+		scenes := hextracer(b)
+		b.SetBytes(totalSize(scenes))
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, scene := range scenes {
+				parser.Parse(scene.content)
+			}
+		}
+	})
+	b.Run("animation", func(b *testing.B) {
+		scene := animation()
+		b.SetBytes(int64(len(scene.content)))
+		b.ReportAllocs()
+		for b.Loop() {
+			parser.Parse(scene.content)
+		}
+	})
+	b.Run("shader", func(b *testing.B) {
+		sh := shader()
+		b.SetBytes(int64(len(sh.content)))
+		b.ReportAllocs()
+		for b.Loop() {
+			gdshader.Parse(sh.content)
+		}
+	})
+}
+
+// BenchmarkAnalyze measures the diagnostics of scenes, parsing included, as
+// the headless check computes them, and the semantic analysis of the
+// shader alone.
+func BenchmarkAnalyze(b *testing.B) {
+	b.Run("hextracer", func(b *testing.B) {
+		scenes := hextracer(b)
+		s := lsp.NewServer("bench", "bench")
+		b.SetBytes(totalSize(scenes))
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, scene := range scenes {
+				s.Check(scene.uri, scene.content)
+			}
+		}
+	})
+	b.Run("animation", func(b *testing.B) {
+		scene := animation()
+		s := lsp.NewServer("bench", "bench")
+		b.SetBytes(int64(len(scene.content)))
+		b.ReportAllocs()
+		for b.Loop() {
+			s.Check(scene.uri, scene.content)
+		}
+	})
+	b.Run("shader", func(b *testing.B) {
+		sh := shader()
+		doc := gdshader.Parse(sh.content)
+		b.SetBytes(int64(len(sh.content)))
+		b.ReportAllocs()
+		for b.Loop() {
+			gdshader.NewAnalyzer(doc).Analyze()
+		}
+	})
+}
+
+// BenchmarkSemanticTokens measures textDocument/semanticTokens/full
+// requests, for a document left unchanged, whose tokens the server can
+// reuse, and for a document edited before each request.
+func BenchmarkSemanticTokens(b *testing.B) {
+	b.Run("hextracer", func(b *testing.B) {
+		benchmarkRequests(b, hextracer(b), "textDocument/semanticTokens/full", semanticTokensParams)
+	})
+	b.Run("animation", func(b *testing.B) {
+		benchmarkRequests(b, []input{animation()}, "textDocument/semanticTokens/full", semanticTokensParams)
+	})
+	b.Run("shader", func(b *testing.B) {
+		benchmarkRequests(b, []input{shader()}, "textDocument/semanticTokens/full", semanticTokensParams)
+	})
+}
+
+// BenchmarkHover measures textDocument/hover requests, over the type of the
+// last node of scenes, where every section before it is looked through, and
+// over the call to the last function of the shader.
+func BenchmarkHover(b *testing.B) {
+	b.Run("hextracer", func(b *testing.B) {
+		benchmarkRequests(b, hextracer(b), "textDocument/hover", hoverParams(`type="`, len(`type="`)))
+	})
+	b.Run("animation", func(b *testing.B) {
+		benchmarkRequests(b, []input{animation()}, "textDocument/hover", hoverParams(`type="`, len(`type="`)))
+	})
+	b.Run("shader", func(b *testing.B) {
+		benchmarkRequests(b, []input{shader()}, "textDocument/hover", hoverParams("= wave_", len("= ")))
+	})
+}
+
+// paramsFunc returns the parameters of the request benchmarked on a
+// document.
+type paramsFunc func(in input) any
+
+func semanticTokensParams(in input) any {
+	return protocol.SemanticTokensParams{TextDocument: protocol.TextDocumentIdentifier{URI: in.uri}}
+}
+
+// hoverParams returns the parameters of hovers at the last occurrence of
+// marker in each document, offset bytes into it.
+func hoverParams(marker string, offset int) paramsFunc {
+	return func(in input) any {
+		i := strings.LastIndex(in.content, marker) + offset
+		line := strings.Count(in.content[:i], "\n")
+		col := i - (strings.LastIndex(in.content[:i], "\n") + 1)
+		return protocol.HoverParams{TextDocumentPositionParams: protocol.TextDocumentPositionParams{
+			TextDocument: protocol.TextDocumentIdentifier{URI: in.uri},
+			Position:     protocol.Position{Line: uint32(line), Character: uint32(col)},
+		}}
+	}
+}
+
+// benchmarkRequests opens the inputs in a server and measures a request on
+// each of them, in the "unchanged" and "edited" sub-benchmarks. A first
+// request on each input, left out of the measure, waits for them to open.
+func benchmarkRequests(b *testing.B, inputs []input, method string, params paramsFunc) {
+	b.Run("unchanged", func(b *testing.B) {
+		c := connect(b, inputs)
+		for _, in := range inputs {
+			c.call(b, method, params(in))
+		}
+		b.SetBytes(totalSize(inputs))
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, in := range inputs {
+				c.call(b, method, params(in))
+			}
+		}
+	})
+	b.Run("edited", func(b *testing.B) {
+		c := connect(b, inputs)
+		for _, in := range inputs {
+			c.call(b, method, params(in))
+		}
+		b.SetBytes(totalSize(inputs))
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, in := range inputs {
+				c.change(b, in)
+				c.call(b, method, params(in))
+			}
+		}
+	})
+}
+
+// client is a JSON-RPC client of a server running in the benchmark.
+type client struct {
+	conn    *jsonrpc2.Conn
+	version int32
+}
+
+// connect starts a server serving one end of an in-memory pipe, initializes
+// it from a client on the other end and opens the inputs. The client pulls
+// diagnostics, so that none are computed behind the requests measured.
+func connect(b *testing.B, inputs []input) *client {
+	b.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clientSide, serverSide := net.Pipe()
+	go lsp.NewServer("bench", "bench").ServeConn(ctx, serverSide)
+	conn := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(clientSide, jsonrpc2.VSCodeObjectCodec{}), jsonrpc2.HandlerWithError(
+		func(context.Context, *jsonrpc2.Conn, *jsonrpc2.Request) (any, error) { return nil, nil },
+	))
+	b.Cleanup(func() {
+		conn.Close()
+		cancel()
+	})
+
+	c := &client{conn: conn, version: 1}
+	c.call(b, "initialize", map[string]any{
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{"diagnostic": map[string]any{}},
+		},
+	})
+	c.notify(b, "initialized", map[string]any{})
+	for _, in := range inputs {
+		languageID := "tscn"
+		if strings.HasSuffix(in.uri, ".gdshader") {
+			languageID = "gdshader"
+		}
+		c.notify(b, "textDocument/didOpen", protocol.DidOpenTextDocumentParams{TextDocument: protocol.TextDocumentItem{
+			URI: in.uri, LanguageID: languageID, Version: c.version, Text: in.content,
+		}})
+	}
+	return c
+}
+
+// call sends a request and waits for its result.
+func (c *client) call(b *testing.B, method string, params any) {
+	b.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	// Left undecoded, so that the client's work is not measured
+	var result json.RawMessage
+	if err := c.conn.Call(ctx, method, params, &result); err != nil {
+		b.Fatalf("%s: %v", method, err)
+	}
+}
+
+// notify sends a notification.
+func (c *client) notify(b *testing.B, method string, params any) {
+	b.Helper()
+
+	if err := c.conn.Notify(context.Background(), method, params); err != nil {
+		b.Fatalf("%s: %v", method, err)
+	}
+}
+
+// change replaces the content of a document with a new version of the same
+// content, which the server parses again.
+func (c *client) change(b *testing.B, in input) {
+	b.Helper()
+
+	c.version++
+	c.notify(b, "textDocument/didChange", protocol.DidChangeTextDocumentParams{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: in.uri},
+			Version:                c.version,
+		},
+		ContentChanges: []any{protocol.TextDocumentContentChangeEventWhole{Text: in.content}},
+	})
+}
@@ -0,0 +1,202 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/andresperezl/gdls/internal/gdshader"
+	"github.com/andresperezl/gdls/internal/parser"
+)
+
+// Sizes of the generated inputs.
+const (
+	animationSceneSize = 10 << 20
+	shaderLines        = 2000
+)
+
+// input is a file the benchmarks run on.
+type input struct {
+	name    string
+	uri     string
+	content string
+}
+
+// The generated inputs are built once and shared by every benchmark.
+var (
+	animationOnce  sync.Once
+	animationInput input
+	shaderOnce     sync.Once
+	shaderInput    input
+)
+
+// animation returns a scene of at least animationSceneSize bytes holding
+// an animation with hundreds of tracks, the kind of file an imported model
+// with baked animations produces.
+func animation() input {
+	animationOnce.Do(func() {
+		animationInput = input{
+			name:    "animation",
+			uri:     "file:///bench/animation.tscn",
+			content: animationScene(animationSceneSize, 600),
+		}
+	})
+	return animationInput
+}
+
+// shader returns a spatial shader of at least shaderLines lines, with
+// uniforms, a struct and functions calling each other.
+func shader() input {
+	shaderOnce.Do(func() {
+		shaderInput = input{
+			name:    "shader",
+			uri:     "file:///bench/large.gdshader",
+			content: largeShader(shaderLines),
+		}
+	})
+	return shaderInput
+}
+
+// hextracer returns the scenes of the hextracer project, skipping the
+// benchmark when it is not checked out under test/hextracer.
+func hextracer(b *testing.B) []input {
+	b.Helper()
+
+	paths, _ := filepath.Glob("../hextracer/scenes/*.tscn")
+	if len(paths) == 0 {
+		b.Skip("hextracer scenes not found under test/hextracer")
+	}
+	inputs := make([]input, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		inputs = append(inputs, input{
+			name:    filepath.Base(path),
+			uri:     "file://" + filepath.ToSlash(abs),
+			content: string(content),
+		})
+	}
+	return inputs
+}
+
+// totalSize returns the size of the contents of inputs, for b.SetBytes.
+func totalSize(inputs []input) int64 {
+	var n int64
+	for _, in := range inputs {
+		n += int64(len(in.content))
+	}
+	return n
+}
+
+// animationScene returns a scene with an animation of tracks of the given
+// number of keys, adding tracks until the scene reaches size bytes.
+func animationScene(size, keys int) string {
+	var b strings.Builder
+	b.WriteString("[gd_scene load_steps=2 format=3]\n\n[sub_resource type=\"Animation\" id=\"Animation_walk\"]\nlength = 20.0\n")
+	for t := 0; b.Len() < size; t++ {
+		fmt.Fprintf(&b, "tracks/%d/type = \"value\"\ntracks/%d/path = NodePath(\"Skeleton:bones/%d\")\n", t, t, t)
+		fmt.Fprintf(&b, "tracks/%d/keys = {\n\"times\": PackedFloat32Array(", t)
+		for k := range keys {
+			if k > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%g", float64(k)*0.0333)
+		}
+		b.WriteString("),\n\"update\": 0,\n\"values\": [")
+		for k := range keys {
+			if k > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "Vector3(%d.5, -%d.25, 1e-3)", k, t)
+		}
+		b.WriteString("]\n}\n")
+	}
+	b.WriteString("\n[node name=\"Root\" type=\"Node3D\"]\n\n[node name=\"AnimationPlayer\" type=\"AnimationPlayer\" parent=\".\"]\n")
+	return b.String()
+}
+
+// largeShader returns a spatial shader of at least lines lines. Each wave_N
+// function calls the one declared before it, and the fragment function
+// calls the last.
+func largeShader(lines int) string {
+	var b strings.Builder
+	b.WriteString(`shader_type spatial;
+
+uniform float speed = 1.0;
+uniform vec4 tint : source_color = vec4(1.0);
+uniform sampler2D noise : hint_default_white;
+
+varying vec3 world_position;
+
+struct Ripple {
+	float height;
+	vec2 direction;
+};
+
+float wave_0(vec2 uv, float t) {
+	return sin(uv.x + t * speed);
+}
+`)
+	n := 1
+	for ; strings.Count(b.String(), "\n") < lines-12; n++ {
+		fmt.Fprintf(&b, `
+float wave_%d(vec2 uv, float t) {
+	float height = sin(uv.x * %d.0 + t * speed);
+	vec2 direction = vec2(cos(t), sin(t)) * height;
+	Ripple ripple = Ripple(height, direction);
+	for (int i = 0; i < 4; i++) {
+		ripple.height += texture(noise, uv + ripple.direction * float(i)).r * 0.25;
+	}
+	return ripple.height + wave_%d(uv, t) * 0.5;
+}
+`, n, n, n-1)
+	}
+	fmt.Fprintf(&b, `
+void vertex() {
+	world_position = (MODEL_MATRIX * vec4(VERTEX, 1.0)).xyz;
+}
+
+void fragment() {
+	float height = wave_%d(UV, TIME);
+	ALBEDO = tint.rgb * height;
+	ROUGHNESS = clamp(world_position.y, 0.0, 1.0);
+}
+`, n-1)
+	return b.String()
+}
+
+// TestInputs checks that the generated inputs are valid and of the sizes
+// the benchmarks are named after, so that they measure real work.
+func TestInputs(t *testing.T) {
+	scene := animation()
+	if len(scene.content) < animationSceneSize {
+		t.Errorf("animation scene is %d bytes, want at least %d", len(scene.content), animationSceneSize)
+	}
+	if doc := parser.Parse(scene.content); len(doc.Errors) > 0 {
+		t.Errorf("animation scene has parse errors: %v", doc.Errors[0])
+	}
+
+	sh := shader()
+	if got := strings.Count(sh.content, "\n"); got < shaderLines {
+		t.Errorf("shader has %d lines, want at least %d", got, shaderLines)
+	}
+	doc := gdshader.Parse(sh.content)
+	if len(doc.Errors) > 0 {
+		t.Fatalf("shader has parse errors: %v", doc.Errors[0])
+	}
+	for _, err := range gdshader.NewAnalyzer(doc).Analyze() {
+		if !err.Warning {
+			t.Errorf("shader has semantic errors: %s at %v", err.Message, err.Range)
+			break
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runGdls runs the gdls command with the given arguments and returns its
+// stdout and exit code.
+func runGdls(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+
+	projectRoot, err := findProjectRoot()
+	if err != nil {
+		t.Fatalf("failed to find project root: %v", err)
+	}
+
+	cmd := exec.Command("go", append([]string{"run", "./cmd/gdls"}, args...)...)
+	cmd.Dir = projectRoot
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("failed to run gdls: %v", err)
+	}
+	return stdout.String(), 0
+}
+
+func TestCheckCommand(t *testing.T) {
+	t.Parallel()
+
+	output, code := runGdls(t, "check", "--format", "json", "testdata/with_errors.tscn")
+	if code != 1 {
+		t.Errorf("expected exit code 1 for a file with errors, got %d", code)
+	}
+
+	var results []struct {
+		File     string `json:"file"`
+		Line     int    `json:"line"`
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("failed to parse JSON output: %v\n%s", err, output)
+	}
+
+	hasError := false
+	for _, r := range results {
+		if r.Severity == "error" && r.Line > 0 {
+			hasError = true
+		}
+	}
+	if !hasError {
+		t.Errorf("expected at least one error, got %+v", results)
+	}
+}
+
+func TestCheckCommandClean(t *testing.T) {
+	t.Parallel()
+
+	output, code := runGdls(t, "check", "testdata/simple.tscn")
+	if code != 0 {
+		t.Errorf("expected exit code 0 for a clean file, got %d", code)
+	}
+	if output != "" {
+		t.Errorf("expected no diagnostics, got %q", output)
+	}
+}
+
+func TestCheckCommandExportedScene(t *testing.T) {
+	t.Parallel()
+
+	// A Blender export in the Godot 3 format, with numeric ids and classes
+	// Godot 4 renamed, only gets the hint that it is imported
+	output, code := runGdls(t, "check", "--format", "json", "testdata/exported.escn")
+	if code != 0 {
+		t.Errorf("expected exit code 0 for an exported scene, got %d", code)
+	}
+
+	var results []struct {
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(output), &results); err != nil {
+		t.Fatalf("failed to parse JSON output: %v\n%s", err, output)
+	}
+	if len(results) != 1 || results[0].Severity != "hint" {
+		t.Errorf("expected only the exported scene hint, got %+v", results)
+	}
+}
+
+func TestFmtCommand(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.gdshader")
+	unformatted := "shader_type spatial;\n\n\nvoid fragment() {\n  ALBEDO = vec3(1.0);   \n}\n"
+	formatted := "shader_type spatial;\n\nvoid fragment() {\n\tALBEDO = vec3(1.0);\n}\n"
+	if err := os.WriteFile(path, []byte(unformatted), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	output, code := runGdls(t, "fmt", path)
+	if code != 1 {
+		t.Errorf("expected exit code 1 for an unformatted file, got %d", code)
+	}
+	if !strings.Contains(output, "+\tALBEDO = vec3(1.0);") {
+		t.Errorf("expected a diff, got:\n%s", output)
+	}
+
+	if _, code := runGdls(t, "fmt", "-w", path); code != 0 {
+		t.Errorf("expected exit code 0 when writing, got %d", code)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != formatted {
+		t.Errorf("unexpected formatted content:\n%q", content)
+	}
+
+	if output, code := runGdls(t, "fmt", path); code != 0 || output != "" {
+		t.Errorf("expected no diff for a formatted file, got code %d and:\n%s", code, output)
+	}
+}
+
+func TestFmtCommandScene(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "test.tscn")
+	unformatted := "[gd_scene format=3]\n[ext_resource type=\"Script\" path=\"res://b.gd\" id=\"2_b\"]\n[ext_resource type=\"Script\" path=\"res://a.gd\" id=\"1_a\"]\n[node name=\"Root\" type=\"Node\"]\nscript=ExtResource(\"1_a\")\n"
+	formatted := "[gd_scene format=3]\n\n[ext_resource type=\"Script\" path=\"res://a.gd\" id=\"1_a\"]\n[ext_resource type=\"Script\" path=\"res://b.gd\" id=\"2_b\"]\n\n[node name=\"Root\" type=\"Node\"]\nscript = ExtResource(\"1_a\")\n"
+	if err := os.WriteFile(path, []byte(unformatted), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	if _, code := runGdls(t, "fmt", "-w", path); code != 0 {
+		t.Errorf("expected exit code 0 when writing, got %d", code)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != formatted {
+		t.Errorf("unexpected formatted content:\n%q", content)
+	}
+}
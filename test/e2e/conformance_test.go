@@ -0,0 +1,191 @@
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// LSP and JSON-RPC error codes checked by the conformance tests.
+const (
+	codeInvalidRequest       = -32600
+	codeMethodNotFound       = -32601
+	codeServerNotInitialized = -32002
+)
+
+// expectErrorCode sends a request and checks it fails with the given code.
+func expectErrorCode(ctx context.Context, t *testing.T, client *testLSPClient, method string, code int) {
+	t.Helper()
+
+	resp, err := client.call(ctx, method, map[string]any{})
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	if resp.Error == nil {
+		t.Fatalf("%s: expected error %d, got result %s", method, code, resp.Result)
+	}
+	if resp.Error.Code != code {
+		t.Errorf("%s: expected error %d, got %d (%s)", method, code, resp.Error.Code, resp.Error.Message)
+	}
+}
+
+func TestConformanceRequestBeforeInitialize(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+	defer client.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	expectErrorCode(ctx, t, client, "textDocument/hover", codeServerNotInitialized)
+	expectErrorCode(ctx, t, client, "shutdown", codeServerNotInitialized)
+
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatalf("initialize after a refused request failed: %v", err)
+	}
+	expectErrorCode(ctx, t, client, "initialize", codeInvalidRequest)
+
+	if err := client.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	client.exit()
+}
+
+func TestConformanceRequestAfterShutdown(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := client.shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	expectErrorCode(ctx, t, client, "textDocument/hover", codeInvalidRequest)
+	expectErrorCode(ctx, t, client, "shutdown", codeInvalidRequest)
+
+	if err := client.exit(); err != nil {
+		t.Fatalf("exit failed: %v", err)
+	}
+	if code := client.close(); code != 0 {
+		t.Errorf("expected exit code 0 after shutdown, got %d", code)
+	}
+}
+
+func TestConformanceExitWithoutShutdown(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+	if err := client.exit(); err != nil {
+		t.Fatalf("exit failed: %v", err)
+	}
+
+	// Let the server exit on its own before stdin is closed
+	time.Sleep(100 * time.Millisecond)
+	if code := client.close(); code != 1 {
+		t.Errorf("expected exit code 1 without shutdown, got %d", code)
+	}
+}
+
+func TestConformanceUnknownMethods(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		client.shutdown(ctx)
+		client.exit()
+		client.close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	expectErrorCode(ctx, t, client, "gdls/doesNotExist", codeMethodNotFound)
+	expectErrorCode(ctx, t, client, "$/doesNotExist", codeMethodNotFound)
+
+	// Unknown notifications are ignored and the server keeps working
+	if err := client.sendNotification("$/doesNotExist", nil); err != nil {
+		t.Fatalf("notification failed: %v", err)
+	}
+	if _, err := client.sendRequest(ctx, "textDocument/documentSymbol", map[string]any{
+		"textDocument": map[string]string{"uri": "file:///test/missing.tscn"},
+	}); err != nil {
+		t.Errorf("request after unknown notification failed: %v", err)
+	}
+}
+
+func TestConformanceContentTypeHeader(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		client.shutdown(ctx)
+		client.exit()
+		client.close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	headers := []string{
+		"Content-Length: %d\r\nContent-Type: application/vscode-jsonrpc; charset=utf-8\r\n\r\n",
+		"Content-Type: application/vscode-jsonrpc; charset=utf8\r\nContent-Length: %d\r\n\r\n",
+		"content-length:%d\r\n\r\n",
+	}
+
+	for i, header := range headers {
+		// The first message initializes the server, the rest are harmless requests
+		method := "textDocument/documentSymbol"
+		params := any(map[string]any{"textDocument": map[string]string{"uri": "file:///test/missing.tscn"}})
+		if i == 0 {
+			method, params = "initialize", initializeParams{}
+		}
+
+		id := 1000 + i
+		msg, err := json.Marshal(jsonrpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		respCh := make(chan jsonrpcResponse, 1)
+		client.responsesMu.Lock()
+		client.responses[id] = respCh
+		client.responsesMu.Unlock()
+
+		if err := client.writeMessageWithHeader(msg, fmt.Sprintf(header, len(msg))); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+
+		select {
+		case resp := <-respCh:
+			if resp.Error != nil {
+				t.Errorf("header %q: unexpected error %d: %s", header, resp.Error.Code, resp.Error.Message)
+			}
+		case <-ctx.Done():
+			t.Fatalf("header %q: no response", header)
+		}
+	}
+}
@@ -177,9 +177,17 @@ type publishDiagnosticsParams struct {
 }
 
 type diagnostic struct {
-	Range    lspRange `json:"range"`
+	Range              lspRange                       `json:"range"`
+	Message            string                         `json:"message"`
+	Severity           *int                           `json:"severity,omitempty"`
+	Code               any                            `json:"code,omitempty"`
+	Source             string                         `json:"source,omitempty"`
+	RelatedInformation []diagnosticRelatedInformation `json:"relatedInformation,omitempty"`
+}
+
+type diagnosticRelatedInformation struct {
+	Location location `json:"location"`
 	Message  string   `json:"message"`
-	Severity *int     `json:"severity,omitempty"`
 }
 
 // =============================================================================
@@ -195,6 +203,9 @@ type testLSPClient struct {
 	nextID int
 	mu     sync.Mutex
 
+	// writeMu keeps the header and body of a message together
+	writeMu sync.Mutex
+
 	// For async notification handling
 	notifications chan jsonrpcResponse
 	responses     map[int]chan jsonrpcResponse
@@ -315,7 +326,15 @@ func (c *testLSPClient) startReader() {
 }
 
 func (c *testLSPClient) writeMessage(msg []byte) error {
-	header := fmt.Sprintf("Content-Length: %d\r\n\r\n", len(msg))
+	return c.writeMessageWithHeader(msg, fmt.Sprintf("Content-Length: %d\r\n\r\n", len(msg)))
+}
+
+// writeMessageWithHeader writes msg after a verbatim header part, for tests
+// of header parsing.
+func (c *testLSPClient) writeMessageWithHeader(msg []byte, header string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	if _, err := c.stdin.Write([]byte(header)); err != nil {
 		return fmt.Errorf("failed to write header: %w", err)
 	}
@@ -360,6 +379,18 @@ func (c *testLSPClient) readMessage() ([]byte, error) {
 }
 
 func (c *testLSPClient) sendRequest(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	resp, err := c.call(ctx, method, params)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("LSP error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	return resp.Result, nil
+}
+
+// call sends a request and returns the raw response, including any error.
+func (c *testLSPClient) call(ctx context.Context, method string, params any) (jsonrpcResponse, error) {
 	c.mu.Lock()
 	id := c.nextID
 	c.nextID++
@@ -374,7 +405,7 @@ func (c *testLSPClient) sendRequest(ctx context.Context, method string, params a
 
 	msg, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return jsonrpcResponse{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Create response channel
@@ -388,7 +419,7 @@ func (c *testLSPClient) sendRequest(ctx context.Context, method string, params a
 		c.responsesMu.Lock()
 		delete(c.responses, id)
 		c.responsesMu.Unlock()
-		return nil, err
+		return jsonrpcResponse{}, err
 	}
 
 	// Wait for response with timeout
@@ -397,12 +428,9 @@ func (c *testLSPClient) sendRequest(ctx context.Context, method string, params a
 		c.responsesMu.Lock()
 		delete(c.responses, id)
 		c.responsesMu.Unlock()
-		return nil, ctx.Err()
+		return jsonrpcResponse{}, ctx.Err()
 	case resp := <-respCh:
-		if resp.Error != nil {
-			return nil, fmt.Errorf("LSP error %d: %s", resp.Error.Code, resp.Error.Message)
-		}
-		return resp.Result, nil
+		return resp, nil
 	}
 }
 
@@ -883,6 +911,68 @@ func TestLSPDiagnosticsOnErrors(t *testing.T) {
 	if len(diagParams.Diagnostics) == 0 {
 		t.Error("expected diagnostics for file with errors, got none")
 	}
+
+	codes := make(map[any]bool)
+	for _, d := range diagParams.Diagnostics {
+		if d.Source != "gdls" || d.Code == nil {
+			t.Errorf("expected a gdls source and a code on %q, got %q and %v", d.Message, d.Source, d.Code)
+		}
+		codes[d.Code] = true
+	}
+	for _, code := range []string{"undefined-resource", "missing-parent", "load-steps"} {
+		if !codes[code] {
+			t.Errorf("expected a %s diagnostic, got codes %v", code, codes)
+		}
+	}
+}
+
+func TestLSPDiagnosticRelatedInformation(t *testing.T) {
+	t.Parallel()
+
+	client := newTestLSPClient(t)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		defer cancel()
+		client.shutdown(ctx)
+		client.exit()
+		client.close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	if _, err := client.initialize(ctx); err != nil {
+		t.Fatalf("initialize failed: %v", err)
+	}
+
+	uri := "file:///test/redefined.gdshader"
+	content := "shader_type spatial;\n\nuniform float speed;\nuniform float speed;\n\nvoid fragment() {}\n"
+	if err := client.openDocument(uri, content); err != nil {
+		t.Fatalf("failed to open document: %v", err)
+	}
+
+	notifCtx, notifCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer notifCancel()
+	params, err := client.waitForNotification(notifCtx, "textDocument/publishDiagnostics")
+	if err != nil {
+		t.Fatalf("failed to receive diagnostics notification: %v", err)
+	}
+	var diagParams publishDiagnosticsParams
+	if err := json.Unmarshal(params, &diagParams); err != nil {
+		t.Fatalf("failed to unmarshal diagnostics: %v", err)
+	}
+
+	if len(diagParams.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diagParams.Diagnostics)
+	}
+	d := diagParams.Diagnostics[0]
+	if d.Code != "redefined-symbol" || len(d.RelatedInformation) != 1 {
+		t.Fatalf("expected a redefined-symbol diagnostic pointing at the first definition, got %+v", d)
+	}
+	related := d.RelatedInformation[0]
+	if related.Location.URI != uri || related.Location.Range.Start.Line != 2 || related.Location.Range.Start.Character != 14 {
+		t.Errorf("expected the first definition at 2:14, got %+v", related.Location)
+	}
 }
 
 func TestLSPShutdownGracefully(t *testing.T) {
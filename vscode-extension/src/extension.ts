@@ -6,11 +6,13 @@ import {
     type OutputChannel,
     StatusBarAlignment,
     type StatusBarItem,
+    Uri,
     commands,
     window,
     workspace,
 } from 'vscode';
 import {
+    DidChangeConfigurationNotification,
     LanguageClient,
     type LanguageClientOptions,
     type ServerOptions,
@@ -44,6 +46,13 @@ export async function activate(context: ExtensionContext): Promise<void> {
         }),
     );
 
+    // Run by the "open file" code lens of ext_resource lines
+    context.subscriptions.push(
+        commands.registerCommand('gdls.openFile', async (uri: string) => {
+            await window.showTextDocument(Uri.parse(uri));
+        }),
+    );
+
     // Start the language server
     const config = workspace.getConfiguration('gdls');
     if (config.get<boolean>('server.enabled', true)) {
@@ -64,6 +73,19 @@ export async function activate(context: ExtensionContext): Promise<void> {
                 }
             } else if (e.affectsConfiguration('gdls.server.path')) {
                 await restartServer(context);
+            } else if (e.affectsConfiguration('gdls') && client) {
+                await client.sendNotification(
+                    DidChangeConfigurationNotification.type,
+                    { settings: serverSettings() },
+                );
+            }
+        }),
+        workspace.onDidGrantWorkspaceTrust(async () => {
+            if (client) {
+                await client.sendNotification(
+                    DidChangeConfigurationNotification.type,
+                    { settings: serverSettings() },
+                );
             }
         }),
     );
@@ -104,12 +126,16 @@ async function startServer(context: ExtensionContext): Promise<void> {
         documentSelector: [
             { scheme: 'file', language: 'tscn' },
             { scheme: 'file', language: 'gdshader' },
+            { scheme: 'file', language: 'glsl' },
+            { scheme: 'file', language: 'godot-project' },
+            { scheme: 'file', language: 'godot-config' },
         ],
         synchronize: {
             fileEvents: workspace.createFileSystemWatcher(
-                '**/*.{tscn,escn,gdshader,gdshaderinc}',
+                '**/*.{tscn,escn,tres,gdshader,gdshaderinc,glsl,godot,cfg,import,gdextension,gd,cs,uid}',
             ),
         },
+        initializationOptions: serverSettings(),
         outputChannel,
         traceOutputChannel: outputChannel,
     };
@@ -121,6 +147,16 @@ async function startServer(context: ExtensionContext): Promise<void> {
         clientOptions,
     );
 
+    client.onNotification(
+        'gdls/status',
+        (status: { message: string; skipped?: { uri: string; reason: string }[] }) => {
+            outputChannel.appendLine(status.message);
+            for (const skipped of status.skipped ?? []) {
+                outputChannel.appendLine(`  skipped ${skipped.uri}: ${skipped.reason}`);
+            }
+        },
+    );
+
     try {
         await client.start();
         outputChannel.appendLine('Godot language server started successfully');
@@ -153,6 +189,25 @@ async function restartServer(context: ExtensionContext): Promise<void> {
     await startServer(context);
 }
 
+function serverSettings(): Record<string, unknown> {
+    const config = workspace.getConfiguration('gdls');
+    return {
+        diagnosticsDelay: config.get<number>('diagnostics.delay', 300),
+        diagnosticSeverity: config.get<Record<string, string>>(
+            'diagnostics.severity',
+            {},
+        ),
+        godotVersion: config.get<string>('godotVersion', '4.6'),
+        // The engine is only run for trusted workspaces
+        godotPath: workspace.isTrusted
+            ? config.get<string>('godotPath', '')
+            : '',
+        maxFileSize: config.get<number>('maxFileSize', 16777216),
+        alignProperties: config.get<boolean>('format.alignProperties', false),
+        shaderSnippets: config.get<boolean>('completion.shaderSnippets', true),
+    };
+}
+
 function getServerPath(context: ExtensionContext): string | undefined {
     const config = workspace.getConfiguration('gdls');
 